@@ -0,0 +1,206 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+	"time"
+
+	"k8s.io/klog/v2"
+)
+
+// actionTimeout bounds how long a script or systemctl call may take, so that a
+// hung action does not block the health checks.
+const actionTimeout = 30 * time.Second
+
+// action is run when the local apiserver becomes healthy or unhealthy.
+type action interface {
+	// name identifies the action in logs and metrics.
+	name() string
+	// onSuccess is run when the apiserver became healthy.
+	onSuccess(vips []string) error
+	// onFailure is run when the apiserver became unhealthy.
+	onFailure(vips []string) error
+}
+
+// parseAction parses an --action flag value:
+//
+//	downfile               write <vip>.up / <vip>.down files in /run/cloud-routes
+//	script:<path>          run <path> up|down <vip>... on the node
+//	systemd-unit:<unit>    start <unit> when healthy, stop it when unhealthy
+func parseAction(spec string) (action, error) {
+	kind, arg, _ := strings.Cut(spec, ":")
+	switch kind {
+	case "downfile":
+		if arg != "" {
+			return nil, fmt.Errorf("action %q does not take an argument", kind)
+		}
+		return downFileAction{}, nil
+	case "script":
+		if !path.IsAbs(arg) {
+			return nil, fmt.Errorf("action %q needs an absolute path, got %q", kind, arg)
+		}
+		return scriptAction{path: arg}, nil
+	case "systemd-unit":
+		if arg == "" {
+			return nil, fmt.Errorf("action %q needs a unit name", kind)
+		}
+		return systemdUnitAction{unit: arg}, nil
+	default:
+		return nil, fmt.Errorf("unknown action %q, expected one of downfile, script:<path> or systemd-unit:<unit>", spec)
+	}
+}
+
+// downFileAction writes the flag files the provider-specific routes services watch.
+type downFileAction struct{}
+
+func (downFileAction) name() string {
+	return "downfile"
+}
+
+func (downFileAction) onFailure(vips []string) error {
+	for _, vip := range vips {
+		if err := writeVipStateFile(vip, "down"); err != nil {
+			return err
+		}
+		klog.Infof("healthcheck failed, created downfile %s.down", vip)
+		if err := removeVipStateFile(vip, "up"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (downFileAction) onSuccess(vips []string) error {
+	for _, vip := range vips {
+		if err := removeVipStateFile(vip, "down"); err != nil {
+			return err
+		}
+		klog.Infof("healthcheck succeeded, removed downfile %s.down", vip)
+		if err := writeVipStateFile(vip, "up"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// reconcile removes the state files of VIPs which are gone and makes sure
+// that every current VIP has exactly the state file for state.
+func (downFileAction) reconcile(vips []string, state trackerState) error {
+	want := "down"
+	if state == succeededTrackerState {
+		want = "up"
+	}
+	current := map[string]bool{}
+	for _, vip := range vips {
+		current[vip] = true
+	}
+
+	dir := path.Join(runOpts.rootMount, downFileDir)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", dir, err)
+	}
+	existing := map[string]bool{}
+	for _, entry := range entries {
+		ext := path.Ext(entry.Name())
+		vip := strings.TrimSuffix(entry.Name(), ext)
+		// Only touch the files written by this action, e.g. leave the lock file alone.
+		if entry.IsDir() || (ext != ".up" && ext != ".down") || net.ParseIP(vip) == nil {
+			continue
+		}
+		if current[vip] && ext == "."+want {
+			existing[vip] = true
+			continue
+		}
+		if err := removeVipStateFile(vip, strings.TrimPrefix(ext, ".")); err != nil {
+			return err
+		}
+		klog.Infof("Removed stale state file %s", entry.Name())
+	}
+
+	for _, vip := range vips {
+		if existing[vip] {
+			continue
+		}
+		if err := writeVipStateFile(vip, want); err != nil {
+			return err
+		}
+		klog.Infof("Created state file %s.%s", vip, want)
+	}
+	return nil
+}
+
+func writeVipStateFile(vip, state string) error {
+	file := path.Join(runOpts.rootMount, downFileDir, fmt.Sprintf("%s.%s", vip, state))
+	// Disable gosec here to avoid throwing
+	// G306: Expect WriteFile permissions to be 0600 or less
+	// #nosec
+	err := os.WriteFile(file, nil, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to create file (%s): %v", file, err)
+	}
+	return nil
+}
+
+func removeVipStateFile(vip, state string) error {
+	file := path.Join(runOpts.rootMount, downFileDir, fmt.Sprintf("%s.%s", vip, state))
+	err := os.Remove(file)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove file (%s): %v", file, err)
+	}
+	return nil
+}
+
+// scriptAction runs a script on the node with the new state and the VIPs as arguments.
+type scriptAction struct {
+	path string
+}
+
+func (a scriptAction) name() string {
+	return "script:" + a.path
+}
+
+func (a scriptAction) onFailure(vips []string) error {
+	return runOnNode(append([]string{a.path, "down"}, vips...)...)
+}
+
+func (a scriptAction) onSuccess(vips []string) error {
+	return runOnNode(append([]string{a.path, "up"}, vips...)...)
+}
+
+// systemdUnitAction starts a unit while the apiserver is healthy and stops it otherwise.
+type systemdUnitAction struct {
+	unit string
+}
+
+func (a systemdUnitAction) name() string {
+	return "systemd-unit:" + a.unit
+}
+
+func (a systemdUnitAction) onFailure(_ []string) error {
+	return runOnNode("systemctl", "stop", a.unit)
+}
+
+func (a systemdUnitAction) onSuccess(_ []string) error {
+	return runOnNode("systemctl", "start", a.unit)
+}
+
+// runOnNode runs a command chrooted into the nodes root filesystem.
+func runOnNode(args ...string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), actionTimeout)
+	defer cancel()
+
+	// #nosec G204
+	cmd := exec.CommandContext(ctx, "chroot", append([]string{runOpts.rootMount}, args...)...)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("running %s failed: %w: %s", strings.Join(args, " "), err, strings.TrimSpace(string(out)))
+	}
+	klog.Infof("Ran %s", strings.Join(args, " "))
+	return nil
+}
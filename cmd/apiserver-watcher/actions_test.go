@@ -0,0 +1,126 @@
+package main
+
+import (
+	"os"
+	"path"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseAction(t *testing.T) {
+	testCases := []struct {
+		spec        string
+		expected    action
+		errExpected bool
+	}{
+		{spec: "downfile", expected: downFileAction{}},
+		{spec: "downfile:arg", errExpected: true},
+		{spec: "script:/usr/local/bin/routes", expected: scriptAction{path: "/usr/local/bin/routes"}},
+		{spec: "script:routes", errExpected: true},
+		{spec: "script", errExpected: true},
+		{spec: "systemd-unit:gcp-routes.service", expected: systemdUnitAction{unit: "gcp-routes.service"}},
+		{spec: "systemd-unit:", errExpected: true},
+		{spec: "unknown", errExpected: true},
+		{spec: "", errExpected: true},
+	}
+
+	for _, testCase := range testCases {
+		testCase := testCase
+		t.Run(testCase.spec, func(t *testing.T) {
+			a, err := parseAction(testCase.spec)
+			if testCase.errExpected {
+				assert.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, testCase.expected, a)
+		})
+	}
+}
+
+func TestDownFileActionReconcile(t *testing.T) {
+	testCases := []struct {
+		name     string
+		existing []string
+		vips     []string
+		state    trackerState
+		expected []string
+	}{
+		{
+			name:     "Creates the state files",
+			vips:     []string{"10.0.0.1", "fd00::1"},
+			state:    failedTrackerState,
+			expected: []string{"10.0.0.1.down", "fd00::1.down"},
+		},
+		{
+			name:     "Replaces the state file for the other state",
+			existing: []string{"10.0.0.1.down"},
+			vips:     []string{"10.0.0.1"},
+			state:    succeededTrackerState,
+			expected: []string{"10.0.0.1.up"},
+		},
+		{
+			name:     "Removes the state files of removed VIPs",
+			existing: []string{"10.0.0.1.up", "10.0.0.2.up", "10.0.0.3.down"},
+			vips:     []string{"10.0.0.1"},
+			state:    succeededTrackerState,
+			expected: []string{"10.0.0.1.up"},
+		},
+		{
+			name:     "Leaves other files alone",
+			existing: []string{"lock", "notavip.down", "10.0.0.1.conf"},
+			vips:     []string{"10.0.0.1"},
+			state:    failedTrackerState,
+			expected: []string{"10.0.0.1.conf", "10.0.0.1.down", "lock", "notavip.down"},
+		},
+	}
+
+	for _, testCase := range testCases {
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			dir := setupRootMount(t)
+			for _, name := range testCase.existing {
+				require.NoError(t, os.WriteFile(path.Join(dir, name), nil, 0o644))
+			}
+
+			require.NoError(t, downFileAction{}.reconcile(testCase.vips, testCase.state))
+
+			assert.Equal(t, testCase.expected, listStateFiles(t, dir))
+		})
+	}
+}
+
+// setupRootMount points the root mount at a temporary directory and returns
+// the state file directory within it.
+func setupRootMount(t *testing.T) string {
+	t.Helper()
+
+	rootMount := runOpts.rootMount
+	t.Cleanup(func() {
+		runOpts.rootMount = rootMount
+	})
+
+	runOpts.rootMount = t.TempDir()
+	dir := path.Join(runOpts.rootMount, downFileDir)
+	require.NoError(t, os.MkdirAll(dir, 0o755))
+	return dir
+}
+
+// listStateFiles returns the sorted names of the files in dir.
+func listStateFiles(t *testing.T, dir string) []string {
+	t.Helper()
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+
+	names := []string{}
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"k8s.io/klog/v2"
+)
+
+// handler runs the actions for the VIPs of the apiserver. The VIPs are
+// resolved again periodically, so that changes to the load balancer, e.g.
+// adding an IPv6 VIP, are picked up without restarting the watcher.
+type handler struct {
+	sync.Mutex
+
+	hostname string
+	vips     []string
+	actions  []action
+
+	// state is the state the actions were last run for.
+	state trackerState
+}
+
+// reconciler is implemented by actions which keep state for each VIP.
+type reconciler interface {
+	// reconcile makes the state kept for vips match state, and removes the
+	// state kept for any other VIP.
+	reconcile(vips []string, state trackerState) error
+}
+
+func newHandler(hostname string, actions []action) (*handler, error) {
+	addrs, err := lookupVIPs(hostname)
+	if err != nil {
+		return nil, err
+	}
+
+	h := handler{
+		hostname: hostname,
+		vips:     addrs,
+		actions:  actions,
+		state:    unknownTrackerState,
+	}
+	vipCount.Set(float64(len(addrs)))
+	klog.Infof("Using VIPs %v", h.vips)
+	return &h, nil
+}
+
+// lookupHost resolves the hostname of the apiserver. Tests replace it.
+var lookupHost = net.LookupHost
+
+func lookupVIPs(hostname string) ([]string, error) {
+	addrs, err := lookupHost(hostname)
+	if err != nil {
+		return nil, fmt.Errorf("failed to lookup host %s: %v", hostname, err)
+	}
+	if len(addrs) == 0 {
+		return nil, fmt.Errorf("hostname %s has no addresses, expected at least 1 - aborting", hostname)
+	}
+	sort.Strings(addrs)
+	return addrs, nil
+}
+
+func (h *handler) getVIPs() []string {
+	h.Lock()
+	defer h.Unlock()
+	return append([]string{}, h.vips...)
+}
+
+// onFailure runs the failure side of all actions, e.g. writes the downfiles
+func (h *handler) onFailure() error {
+	h.Lock()
+	defer h.Unlock()
+
+	h.state = failedTrackerState
+	return h.runActions(h.vips, h.state)
+}
+
+// onSuccess runs the success side of all actions, e.g. removes the downfiles
+func (h *handler) onSuccess() error {
+	h.Lock()
+	defer h.Unlock()
+
+	h.state = succeededTrackerState
+	return h.runActions(h.vips, h.state)
+}
+
+func (h *handler) runActions(vips []string, state trackerState) error {
+	var errs []error
+	for _, a := range h.actions {
+		var err error
+		if state == succeededTrackerState {
+			err = a.onSuccess(vips)
+		} else {
+			err = a.onFailure(vips)
+		}
+		if err != nil {
+			actionErrors.WithLabelValues(a.name()).Inc()
+			errs = append(errs, fmt.Errorf("action %s: %w", a.name(), err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// watchVIPs resolves the VIPs again every interval. It never returns.
+func (h *handler) watchVIPs(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if err := h.syncVIPs(); err != nil {
+			klog.Warningf("Failed to sync VIPs: %v", err)
+		}
+	}
+}
+
+// syncVIPs resolves the VIPs and brings the actions in line with them. VIPs
+// which were removed have their state cleaned up, VIPs which were added are
+// initialized to the current state. A failed lookup keeps the current VIPs,
+// so that a DNS hiccup does not withdraw routes.
+func (h *handler) syncVIPs() error {
+	vips, err := lookupVIPs(h.hostname)
+	if err != nil {
+		vipResolutionErrors.Inc()
+		return err
+	}
+
+	h.Lock()
+	defer h.Unlock()
+
+	added := subtractVIPs(vips, h.vips)
+	removed := subtractVIPs(h.vips, vips)
+	if len(added) > 0 || len(removed) > 0 {
+		klog.Infof("VIPs changed from %v to %v", h.vips, vips)
+	}
+	h.vips = vips
+	vipCount.Set(float64(len(vips)))
+
+	// Until the first health check result is in there is no state to apply.
+	if h.state == unknownTrackerState {
+		return nil
+	}
+
+	var errs []error
+	for _, a := range h.actions {
+		var err error
+		if r, ok := a.(reconciler); ok {
+			err = r.reconcile(vips, h.state)
+		} else if len(added) > 0 {
+			if h.state == succeededTrackerState {
+				err = a.onSuccess(added)
+			} else {
+				err = a.onFailure(added)
+			}
+		}
+		if err != nil {
+			actionErrors.WithLabelValues(a.name()).Inc()
+			errs = append(errs, fmt.Errorf("action %s: %w", a.name(), err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// subtractVIPs returns the VIPs in a which are not in b.
+func subtractVIPs(a, b []string) []string {
+	in := map[string]bool{}
+	for _, vip := range b {
+		in[vip] = true
+	}
+	var out []string
+	for _, vip := range a {
+		if !in[vip] {
+			out = append(out, vip)
+		}
+	}
+	return out
+}
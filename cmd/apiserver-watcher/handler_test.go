@@ -0,0 +1,146 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingAction records the VIPs it is run for.
+type recordingAction struct {
+	succeeded [][]string
+	failed    [][]string
+}
+
+func (a *recordingAction) name() string {
+	return "recording"
+}
+
+func (a *recordingAction) onSuccess(vips []string) error {
+	a.succeeded = append(a.succeeded, vips)
+	return nil
+}
+
+func (a *recordingAction) onFailure(vips []string) error {
+	a.failed = append(a.failed, vips)
+	return nil
+}
+
+func TestSyncVIPs(t *testing.T) {
+	testCases := []struct {
+		name              string
+		oldVIPs           []string
+		newVIPs           []string
+		lookupErr         bool
+		state             trackerState
+		existing          []string
+		expectedVIPs      []string
+		expectedFiles     []string
+		expectedSucceeded [][]string
+		expectedFailed    [][]string
+		errExpected       bool
+	}{
+		{
+			name:              "VIP added",
+			oldVIPs:           []string{"10.0.0.1"},
+			newVIPs:           []string{"fd00::1", "10.0.0.1"},
+			state:             succeededTrackerState,
+			existing:          []string{"10.0.0.1.up"},
+			expectedVIPs:      []string{"10.0.0.1", "fd00::1"},
+			expectedFiles:     []string{"10.0.0.1.up", "fd00::1.up"},
+			expectedSucceeded: [][]string{{"fd00::1"}},
+		},
+		{
+			name:           "VIP added while unhealthy",
+			oldVIPs:        []string{"10.0.0.1"},
+			newVIPs:        []string{"10.0.0.1", "10.0.0.2"},
+			state:          failedTrackerState,
+			existing:       []string{"10.0.0.1.down"},
+			expectedVIPs:   []string{"10.0.0.1", "10.0.0.2"},
+			expectedFiles:  []string{"10.0.0.1.down", "10.0.0.2.down"},
+			expectedFailed: [][]string{{"10.0.0.2"}},
+		},
+		{
+			name:          "VIP removed",
+			oldVIPs:       []string{"10.0.0.1", "10.0.0.2"},
+			newVIPs:       []string{"10.0.0.1"},
+			state:         succeededTrackerState,
+			existing:      []string{"10.0.0.1.up", "10.0.0.2.up"},
+			expectedVIPs:  []string{"10.0.0.1"},
+			expectedFiles: []string{"10.0.0.1.up"},
+		},
+		{
+			name:          "Stale down-file removed",
+			oldVIPs:       []string{"10.0.0.1"},
+			newVIPs:       []string{"10.0.0.1"},
+			state:         succeededTrackerState,
+			existing:      []string{"10.0.0.1.up", "10.0.0.1.down", "10.0.0.9.down"},
+			expectedVIPs:  []string{"10.0.0.1"},
+			expectedFiles: []string{"10.0.0.1.up"},
+		},
+		{
+			name:          "Unknown state",
+			oldVIPs:       []string{"10.0.0.1"},
+			newVIPs:       []string{"10.0.0.2"},
+			state:         unknownTrackerState,
+			existing:      []string{"10.0.0.1.down"},
+			expectedVIPs:  []string{"10.0.0.2"},
+			expectedFiles: []string{"10.0.0.1.down"},
+		},
+		{
+			name:          "Failed lookup keeps the VIPs",
+			oldVIPs:       []string{"10.0.0.1"},
+			lookupErr:     true,
+			state:         succeededTrackerState,
+			existing:      []string{"10.0.0.1.up"},
+			expectedVIPs:  []string{"10.0.0.1"},
+			expectedFiles: []string{"10.0.0.1.up"},
+			errExpected:   true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			dir := setupRootMount(t)
+			for _, name := range testCase.existing {
+				require.NoError(t, os.WriteFile(path.Join(dir, name), nil, 0o644))
+			}
+
+			lookup := lookupHost
+			t.Cleanup(func() {
+				lookupHost = lookup
+			})
+			lookupHost = func(string) ([]string, error) {
+				if testCase.lookupErr {
+					return nil, fmt.Errorf("lookup failed")
+				}
+				return append([]string{}, testCase.newVIPs...), nil
+			}
+
+			recorder := &recordingAction{}
+			h := &handler{
+				hostname: "api-int.example.com",
+				vips:     testCase.oldVIPs,
+				actions:  []action{downFileAction{}, recorder},
+				state:    testCase.state,
+			}
+
+			err := h.syncVIPs()
+			if testCase.errExpected {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+
+			assert.Equal(t, testCase.expectedVIPs, h.getVIPs())
+			assert.Equal(t, testCase.expectedFiles, listStateFiles(t, dir))
+			assert.Equal(t, testCase.expectedSucceeded, recorder.succeeded)
+			assert.Equal(t, testCase.expectedFailed, recorder.failed)
+		})
+	}
+}
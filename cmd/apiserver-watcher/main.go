@@ -15,7 +15,7 @@ const (
 var (
 	rootCmd = &cobra.Command{
 		Use:           componentName,
-		Short:         "Monitors the local apiserver and writes cloud-routes downfiles",
+		Short:         "Monitors the local apiserver and updates cloud-routes state on changes",
 		Long:          "",
 		SilenceErrors: true,
 		SilenceUsage:  true,
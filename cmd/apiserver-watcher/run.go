@@ -9,8 +9,6 @@ import (
 	"net/url"
 	"os"
 	"os/signal"
-	"path"
-	"sync"
 	"time"
 
 	health "github.com/InVisionApp/go-health"
@@ -30,8 +28,15 @@ var (
 	}
 
 	runOpts struct {
-		rootMount      string
-		healthCheckURL string
+		rootMount          string
+		healthCheckURLs    []string
+		healthCheckMode    string
+		checkInterval      time.Duration
+		successThreshold   int
+		failureThreshold   int
+		statusAddress      string
+		actions            []string
+		vipResolveInterval time.Duration
 	}
 )
 
@@ -42,11 +47,20 @@ const downFileDir = "/run/cloud-routes"
 func init() {
 	rootCmd.AddCommand(runCmd)
 	runCmd.PersistentFlags().StringVar(&runOpts.rootMount, "root-mount", "/rootfs", "where the nodes root filesystem is mounted for writing down files or chrooting.")
-	runCmd.PersistentFlags().StringVar(&runOpts.healthCheckURL, "health-check-url", "", "HTTP(s) URL for the health check. The hostname is also used to determine the virtual IPs")
-}
-
-type handler struct {
-	vips []string
+	runCmd.PersistentFlags().StringArrayVar(&runOpts.healthCheckURLs, "health-check-url", nil, "HTTP(s) URL for a health check, may be repeated. The hostname of the first URL is also used to determine the virtual IPs")
+	runCmd.PersistentFlags().StringVar(&runOpts.healthCheckMode, "health-check-mode", string(allChecksMode), "Whether \"all\" or \"any\" of the health checks need to succeed for the apiserver to be considered healthy")
+	// careful: the timing here needs to correspond to the load balancer's
+	// parameters. We need to remove routes just after we've been removed
+	// as a backend in the load-balancer, and add routes before we've been
+	// re-added.
+	// see openshift/installer/data/data/gcp/network/lb-private.tf
+	// see openshift/installer/data/data/azure/vnet/internal-lb.tf
+	runCmd.PersistentFlags().DurationVar(&runOpts.checkInterval, "health-check-interval", 2*time.Second, "Interval between health checks")
+	runCmd.PersistentFlags().IntVar(&runOpts.successThreshold, "success-threshold", 1, "Number of consecutive successful checks before a health check is considered healthy")
+	runCmd.PersistentFlags().IntVar(&runOpts.failureThreshold, "failure-threshold", 8, "Number of consecutive failed checks before a health check is considered unhealthy") // LB = 6 seconds, plus 10 seconds for propagation
+	runCmd.PersistentFlags().StringVar(&runOpts.statusAddress, "status-address", "", "Address to serve the current state on /status and Prometheus metrics on /metrics, e.g. localhost:9444. Disabled if empty")
+	runCmd.PersistentFlags().DurationVar(&runOpts.vipResolveInterval, "vip-resolve-interval", time.Minute, "Interval at which the VIPs are resolved again and the state files reconciled. Disabled if 0")
+	runCmd.PersistentFlags().StringArrayVar(&runOpts.actions, "action", []string{"downfile"}, "Action to run when the apiserver becomes healthy or unhealthy, may be repeated: downfile, script:<path> (runs <path> up|down <vip>... on the node) or systemd-unit:<unit> (started when healthy, stopped when unhealthy)")
 }
 
 func runRunCmd(_ *cobra.Command, _ []string) error {
@@ -56,58 +70,99 @@ func runRunCmd(_ *cobra.Command, _ []string) error {
 	// To help debugging, immediately log version
 	klog.Infof("Version: %+v (%s)", version.Raw, version.Hash)
 
-	uri, err := url.Parse(runOpts.healthCheckURL)
-	if err != nil {
-		return fmt.Errorf("failed to parse health-check-url: %w", err)
+	if len(runOpts.healthCheckURLs) == 0 {
+		return fmt.Errorf("at least one health-check-url is required")
 	}
-	if !uri.IsAbs() {
-		return fmt.Errorf("invalid URI %q (no scheme)", uri)
+	if runOpts.successThreshold < 1 || runOpts.failureThreshold < 1 {
+		return fmt.Errorf("success-threshold and failure-threshold must be at least 1")
 	}
 
-	handler, err := newHandler(uri)
+	uris := make([]*url.URL, 0, len(runOpts.healthCheckURLs))
+	for _, rawURL := range runOpts.healthCheckURLs {
+		uri, err := url.Parse(rawURL)
+		if err != nil {
+			return fmt.Errorf("failed to parse health-check-url: %w", err)
+		}
+		if !uri.IsAbs() {
+			return fmt.Errorf("invalid URI %q (no scheme)", uri)
+		}
+		uris = append(uris, uri)
+	}
+
+	actions := make([]action, 0, len(runOpts.actions))
+	for _, spec := range runOpts.actions {
+		a, err := parseAction(spec)
+		if err != nil {
+			return err
+		}
+		actions = append(actions, a)
+	}
+
+	handler, err := newHandler(uris[0].Hostname(), actions)
 	if err != nil {
 		return err
 	}
 
-	// The health check should always connect to localhost, not be load-balanced
-	uri.Host = net.JoinHostPort("localhost", uri.Port())
-
-	httpCheck, err := checkers.NewHTTP(&checkers.HTTPConfig{
-		URL: uri,
-		Client: &http.Client{Transport: &http.Transport{
-			// #nosec G402
-			// health checks to https endpoints can use InsecureSkipVerify.
-			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
-		}},
-	})
+	checkNames := make([]string, 0, len(uris))
+	for _, uri := range uris {
+		checkNames = append(checkNames, uri.String())
+	}
+	agg, err := newAggregator(checkMode(runOpts.healthCheckMode), handler, checkNames)
 	if err != nil {
-		return fmt.Errorf("failed to create httpCheck: %w", err)
+		return err
 	}
+
 	errCh := make(chan error)
 
-	// careful: the timing here needs to correspond to the load balancer's
-	// parameters. We need to remove routes just after we've been removed
-	// as a backend in the load-balancer, and add routes before we've been
-	// re-added.
-	// see openshift/installer/data/data/gcp/network/lb-private.tf
-	// see openshift/installer/data/data/azure/vnet/internal-lb.tf
-	tracker := &healthTracker{
-		state:            unknownTrackerState,
-		ErrCh:            errCh,
-		SuccessThreshold: 1,
-		FailureThreshold: 8, // LB = 6 seconds, plus 10 seconds for propagation
-		OnFailure:        handler.onFailure,
-		OnSuccess:        handler.onSuccess,
+	h := health.New()
+	trackers := make([]*healthTracker, 0, len(uris))
+	for i, uri := range uris {
+		name := checkNames[i]
+
+		// The health check should always connect to localhost, not be load-balanced
+		uri.Host = net.JoinHostPort("localhost", uri.Port())
+
+		httpCheck, err := checkers.NewHTTP(&checkers.HTTPConfig{
+			URL: uri,
+			Client: &http.Client{Transport: &http.Transport{
+				// #nosec G402
+				// health checks to https endpoints can use InsecureSkipVerify.
+				TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+			}},
+		})
+		if err != nil {
+			return fmt.Errorf("failed to create httpCheck: %w", err)
+		}
+
+		tracker := &healthTracker{
+			Name:             name,
+			state:            unknownTrackerState,
+			ErrCh:            errCh,
+			SuccessThreshold: runOpts.successThreshold,
+			FailureThreshold: runOpts.failureThreshold,
+			OnFailure:        func() error { return agg.setCheckState(name, failedTrackerState) },
+			OnSuccess:        func() error { return agg.setCheckState(name, succeededTrackerState) },
+		}
+		trackers = append(trackers, tracker)
+
+		if err := h.AddCheck(&health.Config{
+			Name:       name,
+			Checker:    httpCheck,
+			Interval:   runOpts.checkInterval,
+			Fatal:      true,
+			OnComplete: tracker.OnComplete,
+		}); err != nil {
+			return fmt.Errorf("failed to add health check %s: %w", name, err)
+		}
 	}
 
-	h := health.New()
-	h.AddChecks([]*health.Config{{
-		Name:       "dependency-check",
-		Checker:    httpCheck,
-		Interval:   time.Duration(2) * time.Second,
-		Fatal:      true,
-		OnComplete: tracker.OnComplete,
-	}})
+	if runOpts.vipResolveInterval > 0 {
+		go handler.watchVIPs(runOpts.vipResolveInterval)
+	}
+
+	if runOpts.statusAddress != "" {
+		startStatusServer(runOpts.statusAddress, agg, trackers)
+	}
 
 	if err := h.Start(); err != nil {
 		return fmt.Errorf("failed to start heath checker: %v", err)
@@ -134,136 +189,3 @@ func runRunCmd(_ *cobra.Command, _ []string) error {
 		}
 	}
 }
-
-func newHandler(uri *url.URL) (*handler, error) {
-	addrs, err := net.LookupHost(uri.Hostname())
-	if err != nil {
-		return nil, fmt.Errorf("failed to lookup host %s: %v", uri.Hostname(), err)
-	}
-	if len(addrs) == 0 {
-		return nil, fmt.Errorf("hostname %s has no addresses, expected at least 1 - aborting", uri.Hostname())
-	}
-
-	h := handler{
-		vips: addrs,
-	}
-	klog.Infof("Using VIPs %v", h.vips)
-	return &h, nil
-}
-
-// onFailure: either stop the routes service, or write downfile
-func (h *handler) onFailure() error {
-	for _, vip := range h.vips {
-		if err := writeVipStateFile(vip, "down"); err != nil {
-			return err
-		}
-		klog.Infof("healthcheck failed, created downfile %s.down", vip)
-		if err := removeVipStateFile(vip, "up"); err != nil {
-			return err
-		}
-	}
-	return nil
-}
-
-// onSuccess: either start routes service, or remove down file
-func (h *handler) onSuccess() error {
-	for _, vip := range h.vips {
-		if err := removeVipStateFile(vip, "down"); err != nil {
-			return err
-		}
-		klog.Infof("healthcheck succeeded, removed downfile %s.down", vip)
-		if err := writeVipStateFile(vip, "up"); err != nil {
-			return err
-		}
-	}
-	return nil
-}
-
-func writeVipStateFile(vip, state string) error {
-	file := path.Join(runOpts.rootMount, downFileDir, fmt.Sprintf("%s.%s", vip, state))
-	// Disable gosec here to avoid throwing
-	// G306: Expect WriteFile permissions to be 0600 or less
-	// #nosec
-	err := os.WriteFile(file, nil, 0o644)
-	if err != nil {
-		return fmt.Errorf("failed to create file (%s): %v", file, err)
-	}
-	return nil
-}
-
-func removeVipStateFile(vip, state string) error {
-	file := path.Join(runOpts.rootMount, downFileDir, fmt.Sprintf("%s.%s", vip, state))
-	err := os.Remove(file)
-	if err != nil && !os.IsNotExist(err) {
-		return fmt.Errorf("failed to remove file (%s): %v", file, err)
-	}
-	return nil
-}
-
-type trackerState int
-
-const (
-	unknownTrackerState trackerState = iota
-	failedTrackerState
-	succeededTrackerState
-)
-
-type healthTracker struct {
-	sync.Mutex
-
-	state trackerState
-
-	succeeded int
-	failed    int
-
-	// ErrCh is used to collect errors
-	ErrCh chan<- error
-
-	// SuccessThreshold is the number of consecutive success
-	SuccessThreshold int
-
-	// FailureThreshold is the number of consecutive failure that trigger OnFailure func
-	FailureThreshold int
-
-	// OnFailure is the function that is triggered when the health check is in FAILED state
-	// Non nil error are sent over the ErrCh
-	// Only one OnFailure function will be active at a time.
-	OnFailure func() error
-
-	// OnSuccess is the function that is triggered when the health check is in SUCCEEDED state
-	// Non nil error are sent over the ErrCh
-	// Only one OnFailure function will be active at a time.
-	OnSuccess func() error
-}
-
-func (sl *healthTracker) OnComplete(state *health.State) {
-	sl.Lock()
-	defer sl.Unlock()
-
-	switch state.Status {
-	case "ok":
-		sl.failed = 0
-		sl.succeeded++
-		if sl.succeeded >= sl.SuccessThreshold {
-			if sl.state != succeededTrackerState {
-				klog.Info("Running OnSuccess trigger")
-				if err := sl.OnSuccess(); err != nil {
-					sl.ErrCh <- err
-				}
-			}
-			sl.state = succeededTrackerState
-		}
-	case "failed":
-		sl.succeeded = 0
-		sl.failed++
-		if sl.failed >= sl.FailureThreshold {
-			if sl.state != failedTrackerState {
-				klog.Info("Running OnFailure trigger")
-				if err := sl.OnFailure(); err != nil {
-					sl.ErrCh <- err
-				}
-			}
-			sl.state = failedTrackerState
-		}
-	}
-}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"k8s.io/klog/v2"
+)
+
+// apiserver-watcher metrics. The up metrics are 1 when healthy, 0 when
+// unhealthy and -1 while the state is still unknown.
+var (
+	// up is the combined state of all health checks
+	up = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "apiserver_watcher_up",
+			Help: "Whether the local apiserver is considered healthy.",
+		})
+
+	// checkUp is the state of each health check
+	checkUp = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "apiserver_watcher_check_up",
+			Help: "Whether the health check is considered healthy.",
+		}, []string{"check"})
+
+	// checkFailures tallys failed health checks
+	checkFailures = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "apiserver_watcher_check_failures_total",
+			Help: "Total number of failed health checks.",
+		}, []string{"check"})
+
+	// transitions tallys changes of the combined state
+	transitions = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "apiserver_watcher_transitions_total",
+			Help: "Total number of transitions of the combined health state, by new state.",
+		}, []string{"state"})
+
+	// vipResolutionErrors tallys failed lookups of the VIPs
+	vipResolutionErrors = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "apiserver_watcher_vip_resolution_errors_total",
+			Help: "Total number of errors resolving the VIPs.",
+		})
+
+	// vipCount is the number of VIPs currently managed
+	vipCount = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "apiserver_watcher_vips",
+			Help: "Number of VIPs the state is managed for.",
+		})
+
+	// actionErrors tallys failed actions
+	actionErrors = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "apiserver_watcher_action_errors_total",
+			Help: "Total number of errors running an action.",
+		}, []string{"action"})
+
+	metricsList = []prometheus.Collector{
+		up,
+		checkUp,
+		checkFailures,
+		transitions,
+		vipResolutionErrors,
+		vipCount,
+		actionErrors,
+	}
+)
+
+func init() {
+	for _, metric := range metricsList {
+		prometheus.MustRegister(metric)
+	}
+}
+
+type checkStatus struct {
+	Name                 string    `json:"name"`
+	State                string    `json:"state"`
+	ConsecutiveSuccesses int       `json:"consecutiveSuccesses"`
+	ConsecutiveFailures  int       `json:"consecutiveFailures"`
+	LastCheckTime        time.Time `json:"lastCheckTime,omitempty"`
+	LastError            string    `json:"lastError,omitempty"`
+}
+
+type watcherStatus struct {
+	State       string        `json:"state"`
+	Mode        checkMode     `json:"mode"`
+	VIPs        []string      `json:"vips"`
+	Actions     []string      `json:"actions"`
+	Checks      []checkStatus `json:"checks"`
+	Transitions []transition  `json:"transitions"`
+}
+
+// startStatusServer serves the tracker state on /status and the metrics on
+// /metrics. The status server is only informational, so errors are logged
+// rather than stopping the watcher.
+func startStatusServer(address string, agg *aggregator, trackers []*healthTracker) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	mux.HandleFunc("/status", func(w http.ResponseWriter, _ *http.Request) {
+		status := agg.status()
+		for _, tracker := range trackers {
+			status.Checks = append(status.Checks, tracker.status())
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(status); err != nil {
+			klog.Warningf("Failed to write status: %v", err)
+		}
+	})
+
+	s := &http.Server{
+		Addr:              address,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		klog.Infof("Serving status and metrics on %s", address)
+		if err := s.ListenAndServe(); err != nil {
+			klog.Errorf("Status server on %s failed: %v", address, err)
+		}
+	}()
+}
+
+// status returns the combined state and the transition history.
+func (a *aggregator) status() watcherStatus {
+	a.Lock()
+	defer a.Unlock()
+
+	status := watcherStatus{
+		State:       a.state.String(),
+		Mode:        a.mode,
+		VIPs:        a.handler.getVIPs(),
+		Transitions: append([]transition{}, a.transitions...),
+	}
+	for _, act := range a.handler.actions {
+		status.Actions = append(status.Actions, act.name())
+	}
+	return status
+}
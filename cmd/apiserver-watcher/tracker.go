@@ -0,0 +1,249 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	health "github.com/InVisionApp/go-health"
+	"k8s.io/klog/v2"
+)
+
+type trackerState int
+
+const (
+	unknownTrackerState trackerState = iota
+	failedTrackerState
+	succeededTrackerState
+)
+
+func (s trackerState) String() string {
+	switch s {
+	case failedTrackerState:
+		return "failed"
+	case succeededTrackerState:
+		return "succeeded"
+	default:
+		return "unknown"
+	}
+}
+
+type healthTracker struct {
+	sync.Mutex
+
+	state trackerState
+
+	succeeded int
+	failed    int
+
+	// lastState is the result of the last health check, for the status endpoint.
+	lastState health.State
+
+	// Name is the name of the health check.
+	Name string
+
+	// ErrCh is used to collect errors
+	ErrCh chan<- error
+
+	// SuccessThreshold is the number of consecutive success
+	SuccessThreshold int
+
+	// FailureThreshold is the number of consecutive failure that trigger OnFailure func
+	FailureThreshold int
+
+	// OnFailure is the function that is triggered when the health check is in FAILED state
+	// Non nil error are sent over the ErrCh
+	// Only one OnFailure function will be active at a time.
+	OnFailure func() error
+
+	// OnSuccess is the function that is triggered when the health check is in SUCCEEDED state
+	// Non nil error are sent over the ErrCh
+	// Only one OnFailure function will be active at a time.
+	OnSuccess func() error
+}
+
+func (sl *healthTracker) OnComplete(state *health.State) {
+	sl.Lock()
+	defer sl.Unlock()
+
+	sl.lastState = *state
+	switch state.Status {
+	case "ok":
+		sl.failed = 0
+		sl.succeeded++
+		if sl.succeeded >= sl.SuccessThreshold {
+			if sl.state != succeededTrackerState {
+				klog.Infof("Running OnSuccess trigger for %s", sl.Name)
+				if err := sl.OnSuccess(); err != nil {
+					sl.ErrCh <- err
+				}
+			}
+			sl.state = succeededTrackerState
+		}
+	case "failed":
+		checkFailures.WithLabelValues(sl.Name).Inc()
+		sl.succeeded = 0
+		sl.failed++
+		if sl.failed >= sl.FailureThreshold {
+			if sl.state != failedTrackerState {
+				klog.Infof("Running OnFailure trigger for %s", sl.Name)
+				if err := sl.OnFailure(); err != nil {
+					sl.ErrCh <- err
+				}
+			}
+			sl.state = failedTrackerState
+		}
+	}
+}
+
+// status returns the current state of the tracker.
+func (sl *healthTracker) status() checkStatus {
+	sl.Lock()
+	defer sl.Unlock()
+
+	return checkStatus{
+		Name:                 sl.Name,
+		State:                sl.state.String(),
+		ConsecutiveSuccesses: sl.succeeded,
+		ConsecutiveFailures:  sl.failed,
+		LastCheckTime:        sl.lastState.CheckTime,
+		LastError:            sl.lastState.Err,
+	}
+}
+
+// checkMode is how the results of several health checks are combined.
+type checkMode string
+
+const (
+	// allChecksMode considers the apiserver healthy when all checks succeed.
+	allChecksMode checkMode = "all"
+	// anyCheckMode considers the apiserver healthy when any check succeeds.
+	anyCheckMode checkMode = "any"
+)
+
+// maxTransitions is the number of state transitions kept for the status endpoint.
+const maxTransitions = 100
+
+type transition struct {
+	Time time.Time `json:"time"`
+	// Check is the health check which changed state, or empty for the combined state.
+	Check string `json:"check,omitempty"`
+	From  string `json:"from"`
+	To    string `json:"to"`
+}
+
+// aggregator combines the states of the health check trackers and runs the
+// handler when the combined state changes.
+type aggregator struct {
+	sync.Mutex
+
+	mode    checkMode
+	handler *handler
+
+	// checks is the last state of each health check, as reported by its tracker.
+	checks map[string]trackerState
+	state  trackerState
+
+	transitions []transition
+}
+
+func newAggregator(mode checkMode, handler *handler, checks []string) (*aggregator, error) {
+	if mode != allChecksMode && mode != anyCheckMode {
+		return nil, fmt.Errorf("invalid health check mode %q, expected %q or %q", mode, allChecksMode, anyCheckMode)
+	}
+	a := &aggregator{
+		mode:    mode,
+		handler: handler,
+		checks:  map[string]trackerState{},
+		state:   unknownTrackerState,
+	}
+	for _, check := range checks {
+		a.checks[check] = unknownTrackerState
+		checkUp.WithLabelValues(check).Set(-1)
+	}
+	up.Set(-1)
+	return a, nil
+}
+
+// setCheckState records the new state of a health check and runs the handler
+// if the combined state changed.
+func (a *aggregator) setCheckState(check string, state trackerState) error {
+	a.Lock()
+	defer a.Unlock()
+
+	if old := a.checks[check]; old != state {
+		a.addTransition(check, old, state)
+	}
+	a.checks[check] = state
+	checkUp.WithLabelValues(check).Set(stateMetricValue(state))
+
+	combined := a.combinedState()
+	if combined == a.state || combined == unknownTrackerState {
+		return nil
+	}
+	a.addTransition("", a.state, combined)
+	a.state = combined
+	up.Set(stateMetricValue(combined))
+	transitions.WithLabelValues(combined.String()).Inc()
+
+	if combined == succeededTrackerState {
+		return a.handler.onSuccess()
+	}
+	return a.handler.onFailure()
+}
+
+// combinedState returns the state of the apiserver according to the mode. The
+// state stays unknown until enough checks have reported to decide it.
+func (a *aggregator) combinedState() trackerState {
+	var succeeded, failed int
+	for _, state := range a.checks {
+		switch state {
+		case succeededTrackerState:
+			succeeded++
+		case failedTrackerState:
+			failed++
+		}
+	}
+
+	switch a.mode {
+	case anyCheckMode:
+		if succeeded > 0 {
+			return succeededTrackerState
+		}
+		if failed == len(a.checks) {
+			return failedTrackerState
+		}
+	default:
+		if failed > 0 {
+			return failedTrackerState
+		}
+		if succeeded == len(a.checks) {
+			return succeededTrackerState
+		}
+	}
+	return unknownTrackerState
+}
+
+func (a *aggregator) addTransition(check string, from, to trackerState) {
+	a.transitions = append(a.transitions, transition{
+		Time:  time.Now(),
+		Check: check,
+		From:  from.String(),
+		To:    to.String(),
+	})
+	if len(a.transitions) > maxTransitions {
+		a.transitions = a.transitions[len(a.transitions)-maxTransitions:]
+	}
+}
+
+// stateMetricValue maps a tracker state to the value of the up metrics.
+func stateMetricValue(state trackerState) float64 {
+	switch state {
+	case succeededTrackerState:
+		return 1
+	case failedTrackerState:
+		return 0
+	default:
+		return -1
+	}
+}
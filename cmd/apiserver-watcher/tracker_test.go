@@ -0,0 +1,182 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+
+	health "github.com/InVisionApp/go-health"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCombinedState(t *testing.T) {
+	testCases := []struct {
+		name     string
+		mode     checkMode
+		checks   []trackerState
+		expected trackerState
+	}{
+		{
+			name:     "All: all succeeded",
+			mode:     allChecksMode,
+			checks:   []trackerState{succeededTrackerState, succeededTrackerState},
+			expected: succeededTrackerState,
+		},
+		{
+			name:     "All: one failed",
+			mode:     allChecksMode,
+			checks:   []trackerState{succeededTrackerState, failedTrackerState},
+			expected: failedTrackerState,
+		},
+		{
+			name:     "All: one failed, one unknown",
+			mode:     allChecksMode,
+			checks:   []trackerState{failedTrackerState, unknownTrackerState},
+			expected: failedTrackerState,
+		},
+		{
+			name:     "All: one succeeded, one unknown",
+			mode:     allChecksMode,
+			checks:   []trackerState{succeededTrackerState, unknownTrackerState},
+			expected: unknownTrackerState,
+		},
+		{
+			name:     "Any: one succeeded",
+			mode:     anyCheckMode,
+			checks:   []trackerState{failedTrackerState, succeededTrackerState},
+			expected: succeededTrackerState,
+		},
+		{
+			name:     "Any: one succeeded, one unknown",
+			mode:     anyCheckMode,
+			checks:   []trackerState{succeededTrackerState, unknownTrackerState},
+			expected: succeededTrackerState,
+		},
+		{
+			name:     "Any: all failed",
+			mode:     anyCheckMode,
+			checks:   []trackerState{failedTrackerState, failedTrackerState},
+			expected: failedTrackerState,
+		},
+		{
+			name:     "Any: one failed, one unknown",
+			mode:     anyCheckMode,
+			checks:   []trackerState{failedTrackerState, unknownTrackerState},
+			expected: unknownTrackerState,
+		},
+		{
+			name:     "Unknown",
+			mode:     allChecksMode,
+			checks:   []trackerState{unknownTrackerState},
+			expected: unknownTrackerState,
+		},
+	}
+
+	for _, testCase := range testCases {
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			a := &aggregator{mode: testCase.mode, checks: map[string]trackerState{}}
+			for i, state := range testCase.checks {
+				a.checks[fmt.Sprintf("check-%d", i)] = state
+			}
+
+			assert.Equal(t, testCase.expected, a.combinedState())
+		})
+	}
+}
+
+func TestHealthTrackerOnComplete(t *testing.T) {
+	testCases := []struct {
+		name             string
+		successThreshold int
+		failureThreshold int
+		results          []string
+		expectedState    trackerState
+		// expectedCalls are the triggers which ran, in order.
+		expectedCalls []string
+	}{
+		{
+			name:             "Succeeds at the threshold",
+			successThreshold: 2,
+			failureThreshold: 2,
+			results:          []string{"ok", "ok"},
+			expectedState:    succeededTrackerState,
+			expectedCalls:    []string{"success"},
+		},
+		{
+			name:             "Below the success threshold",
+			successThreshold: 2,
+			failureThreshold: 2,
+			results:          []string{"ok"},
+			expectedState:    unknownTrackerState,
+		},
+		{
+			name:             "Fails at the threshold",
+			successThreshold: 1,
+			failureThreshold: 3,
+			results:          []string{"failed", "failed", "failed"},
+			expectedState:    failedTrackerState,
+			expectedCalls:    []string{"failure"},
+		},
+		{
+			name:             "A success resets the failures",
+			successThreshold: 2,
+			failureThreshold: 2,
+			results:          []string{"failed", "ok", "failed"},
+			expectedState:    unknownTrackerState,
+		},
+		{
+			name:             "Triggers run once per transition",
+			successThreshold: 1,
+			failureThreshold: 2,
+			results:          []string{"ok", "ok", "failed", "failed", "failed", "ok", "ok"},
+			expectedState:    succeededTrackerState,
+			expectedCalls:    []string{"success", "failure", "success"},
+		},
+	}
+
+	for _, testCase := range testCases {
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			var calls []string
+			tracker := &healthTracker{
+				Name:             testCase.name,
+				state:            unknownTrackerState,
+				SuccessThreshold: testCase.successThreshold,
+				FailureThreshold: testCase.failureThreshold,
+				OnSuccess: func() error {
+					calls = append(calls, "success")
+					return nil
+				},
+				OnFailure: func() error {
+					calls = append(calls, "failure")
+					return nil
+				},
+			}
+
+			for _, result := range testCase.results {
+				tracker.OnComplete(&health.State{Name: testCase.name, Status: result})
+			}
+
+			assert.Equal(t, testCase.expectedState, tracker.state)
+			assert.Equal(t, testCase.expectedCalls, calls)
+		})
+	}
+}
+
+// Tests that an error from a trigger is sent over the error channel.
+func TestHealthTrackerOnCompleteError(t *testing.T) {
+	errCh := make(chan error, 1)
+	tracker := &healthTracker{
+		Name:             "check",
+		state:            unknownTrackerState,
+		ErrCh:            errCh,
+		SuccessThreshold: 1,
+		FailureThreshold: 1,
+		OnSuccess:        func() error { return nil },
+		OnFailure:        func() error { return fmt.Errorf("action failed") },
+	}
+
+	tracker.OnComplete(&health.State{Name: "check", Status: "failed"})
+
+	assert.EqualError(t, <-errCh, "action failed")
+}
@@ -0,0 +1,325 @@
+package main
+
+import (
+	"bytes"
+	gojson "encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	configv1 "github.com/openshift/api/config/v1"
+	"github.com/openshift/library-go/pkg/operator/configobserver/featuregates"
+	"github.com/spf13/cobra"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/serializer"
+	"k8s.io/apimachinery/pkg/runtime/serializer/json"
+	"k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/klog/v2"
+
+	mcfgv1 "github.com/openshift/machine-config-operator/pkg/apis/machineconfiguration.openshift.io/v1"
+	"github.com/openshift/machine-config-operator/pkg/controller/template"
+	"github.com/openshift/machine-config-operator/pkg/version"
+)
+
+var (
+	renderTemplatesCmd = &cobra.Command{
+		Use:   "render-templates",
+		Short: "Renders the MachineConfig templates for a ControllerConfig without a cluster",
+		Long: `Renders the templates in the templates directory with the given ControllerConfig and
+feature gates, the same way the template controller does, and prints the resulting
+MachineConfigs.
+
+With --diff, a unified diff against an existing set of MachineConfigs is printed instead.
+With --lint, the templates are also checked for unused template functions, templates
+which render to nothing and colliding platform overrides; the command fails if any
+template content is silently dropped.`,
+		Run: runRenderTemplatesCmd,
+	}
+
+	renderTemplatesOpts struct {
+		controllerConfigFile string
+		pullSecretFile       string
+		featureGateFile      string
+		enabledFeatureGates  []string
+		disabledFeatureGates []string
+		destinationDir       string
+		diffAgainst          string
+		lint                 bool
+	}
+)
+
+func init() {
+	rootCmd.AddCommand(renderTemplatesCmd)
+	renderTemplatesCmd.PersistentFlags().StringVar(&renderTemplatesOpts.controllerConfigFile, "controller-config", "", "The ControllerConfig manifest to render the templates with.")
+	renderTemplatesCmd.PersistentFlags().StringVar(&renderTemplatesOpts.pullSecretFile, "pull-secret", "", "A file with the contents of the pull secret (.dockerconfigjson). Defaults to an empty pull secret.")
+	renderTemplatesCmd.PersistentFlags().StringVar(&renderTemplatesOpts.featureGateFile, "feature-gate", "", "A FeatureGate manifest to take the feature gates from.")
+	renderTemplatesCmd.PersistentFlags().StringSliceVar(&renderTemplatesOpts.enabledFeatureGates, "enabled-feature-gates", nil, "Feature gates to enable when no FeatureGate manifest is given.")
+	renderTemplatesCmd.PersistentFlags().StringSliceVar(&renderTemplatesOpts.disabledFeatureGates, "disabled-feature-gates", nil, "Feature gates to disable when no FeatureGate manifest is given.")
+	renderTemplatesCmd.PersistentFlags().StringVar(&renderTemplatesOpts.destinationDir, "dest-dir", "", "Write the rendered MachineConfigs to this dir instead of printing them.")
+	renderTemplatesCmd.PersistentFlags().StringVar(&renderTemplatesOpts.diffAgainst, "diff", "", "A file of MachineConfig, MachineConfigList or List manifests, or a dir of them, to diff the rendered MachineConfigs against.")
+	renderTemplatesCmd.PersistentFlags().BoolVar(&renderTemplatesOpts.lint, "lint", false, "Lint the templates for the given ControllerConfig.")
+}
+
+func runRenderTemplatesCmd(_ *cobra.Command, _ []string) {
+	flag.Set("logtostderr", "true")
+	flag.Parse()
+
+	if renderTemplatesOpts.controllerConfigFile == "" {
+		klog.Fatalf("--controller-config not set")
+	}
+
+	scheme := runtime.NewScheme()
+	mcfgv1.Install(scheme)
+	configv1.Install(scheme)
+	codecFactory := serializer.NewCodecFactory(scheme)
+	decoder := codecFactory.UniversalDecoder(mcfgv1.GroupVersion, configv1.GroupVersion)
+
+	cconfig := &mcfgv1.ControllerConfig{}
+	if err := decodeFile(decoder, renderTemplatesOpts.controllerConfigFile, cconfig); err != nil {
+		klog.Fatalf("error reading ControllerConfig: %v", err)
+	}
+
+	pullSecret := []byte("{}")
+	if renderTemplatesOpts.pullSecretFile != "" {
+		data, err := os.ReadFile(renderTemplatesOpts.pullSecretFile)
+		if err != nil {
+			klog.Fatalf("error reading pull secret: %v", err)
+		}
+		pullSecret = data
+	}
+
+	fgAccess, err := renderTemplatesFeatureGates(decoder)
+	if err != nil {
+		klog.Fatalf("error reading feature gates: %v", err)
+	}
+
+	if renderTemplatesOpts.lint {
+		if err := lintTemplates(cconfig, pullSecret, fgAccess); err != nil {
+			klog.Fatalf("error linting templates: %v", err)
+		}
+	}
+
+	// No pools are given, so the pull secret is rendered as for pools which do
+	// not encrypt sensitive files.
+	mcs, err := template.RunBootstrap(rootOpts.templates, cconfig, nil, pullSecret, fgAccess)
+	if err != nil {
+		klog.Fatalf("error rendering templates: %v", err)
+	}
+
+	if renderTemplatesOpts.diffAgainst != "" {
+		existing, err := readMachineConfigs(decoder, renderTemplatesOpts.diffAgainst)
+		if err != nil {
+			klog.Fatalf("error reading MachineConfigs to diff against: %v", err)
+		}
+		diff, err := template.DiffMachineConfigs(filterTemplateMachineConfigs(existing, mcs), mcs)
+		if err != nil {
+			klog.Fatalf("error diffing MachineConfigs: %v", err)
+		}
+		fmt.Print(diff)
+		return
+	}
+
+	encoder := codecFactory.EncoderForVersion(json.NewYAMLSerializer(json.DefaultMetaFactory, scheme, scheme), mcfgv1.GroupVersion)
+	for _, mc := range mcs {
+		buf := bytes.Buffer{}
+		if err := encoder.Encode(mc, &buf); err != nil {
+			klog.Fatalf("error encoding MachineConfig %s: %v", mc.Name, err)
+		}
+		if renderTemplatesOpts.destinationDir == "" {
+			fmt.Printf("---\n%s", buf.String())
+			continue
+		}
+		if err := os.MkdirAll(renderTemplatesOpts.destinationDir, 0o755); err != nil {
+			klog.Fatalf("error creating %s: %v", renderTemplatesOpts.destinationDir, err)
+		}
+		path := filepath.Join(renderTemplatesOpts.destinationDir, fmt.Sprintf("%s.yaml", mc.Name))
+		// #nosec
+		if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+			klog.Fatalf("error writing MachineConfig %s: %v", mc.Name, err)
+		}
+	}
+}
+
+// lintTemplates prints the lint issues and returns an error if any template
+// content is dropped.
+func lintTemplates(cconfig *mcfgv1.ControllerConfig, pullSecret []byte, fgAccess featuregates.FeatureGateAccess) error {
+	issues, err := template.LintTemplates(&template.RenderConfig{
+		ControllerConfigSpec: &cconfig.Spec,
+		PullSecret:           string(pullSecret),
+		FeatureGateAccess:    fgAccess,
+	}, rootOpts.templates)
+	if err != nil {
+		return err
+	}
+
+	failed := 0
+	for _, issue := range issues {
+		fmt.Fprintln(os.Stderr, issue)
+		if issue.Severity == template.LintError {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d lint errors", failed)
+	}
+	return nil
+}
+
+func renderTemplatesFeatureGates(decoder runtime.Decoder) (featuregates.FeatureGateAccess, error) {
+	if renderTemplatesOpts.featureGateFile == "" {
+		return featuregates.NewHardcodedFeatureGateAccess(toFeatureGateNames(renderTemplatesOpts.enabledFeatureGates), toFeatureGateNames(renderTemplatesOpts.disabledFeatureGates)), nil
+	}
+	if len(renderTemplatesOpts.enabledFeatureGates) > 0 || len(renderTemplatesOpts.disabledFeatureGates) > 0 {
+		return nil, fmt.Errorf("--feature-gate can not be combined with --enabled-feature-gates or --disabled-feature-gates")
+	}
+	featureGate := &configv1.FeatureGate{}
+	if err := decodeFile(decoder, renderTemplatesOpts.featureGateFile, featureGate); err != nil {
+		return nil, err
+	}
+	return featuregates.NewHardcodedFeatureGateAccessFromFeatureGate(featureGate, version.ReleaseVersion)
+}
+
+func toFeatureGateNames(names []string) []configv1.FeatureGateName {
+	out := make([]configv1.FeatureGateName, 0, len(names))
+	for _, name := range names {
+		out = append(out, configv1.FeatureGateName(name))
+	}
+	return out
+}
+
+func decodeFile(decoder runtime.Decoder, path string, into runtime.Object) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if _, _, err := decoder.Decode(data, nil, into); err != nil {
+		return fmt.Errorf("error decoding %s: %w", path, err)
+	}
+	return nil
+}
+
+// readMachineConfigs reads the MachineConfig, MachineConfigList and List
+// manifests in path, which is either a single file or a dir of them. A file may
+// hold several YAML documents.
+func readMachineConfigs(decoder runtime.Decoder, path string) ([]*mcfgv1.MachineConfig, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	paths := []string{path}
+	if info.IsDir() {
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return nil, err
+		}
+		paths = nil
+		for _, entry := range entries {
+			ext := filepath.Ext(entry.Name())
+			if entry.IsDir() || (ext != ".yaml" && ext != ".yml" && ext != ".json") {
+				continue
+			}
+			paths = append(paths, filepath.Join(path, entry.Name()))
+		}
+	}
+
+	var mcs []*mcfgv1.MachineConfig
+	for _, p := range paths {
+		f, err := os.Open(p)
+		if err != nil {
+			return nil, err
+		}
+		decoded, err := decodeMachineConfigs(decoder, f)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("error decoding %s: %w", p, err)
+		}
+		mcs = append(mcs, decoded...)
+	}
+	return mcs, nil
+}
+
+// decodeMachineConfigs decodes every document of a YAML or JSON stream, e.g.
+// the output of this command, into MachineConfigs. MachineConfigLists and v1
+// Lists, e.g. from "oc get mc -o yaml", are unpacked.
+func decodeMachineConfigs(decoder runtime.Decoder, r io.Reader) ([]*mcfgv1.MachineConfig, error) {
+	var mcs []*mcfgv1.MachineConfig
+	docs := yaml.NewYAMLOrJSONDecoder(r, 4096)
+	for {
+		raw := runtime.RawExtension{}
+		if err := docs.Decode(&raw); err == io.EOF {
+			return mcs, nil
+		} else if err != nil {
+			return nil, err
+		}
+		raw.Raw = bytes.TrimSpace(raw.Raw)
+		if len(raw.Raw) == 0 || bytes.Equal(raw.Raw, []byte("null")) {
+			continue
+		}
+		decoded, err := decodeMachineConfigObject(decoder, raw.Raw)
+		if err != nil {
+			return nil, err
+		}
+		mcs = append(mcs, decoded...)
+	}
+}
+
+func decodeMachineConfigObject(decoder runtime.Decoder, data []byte) ([]*mcfgv1.MachineConfig, error) {
+	typeMeta := metav1.TypeMeta{}
+	if err := gojson.Unmarshal(data, &typeMeta); err != nil {
+		return nil, err
+	}
+	if typeMeta.APIVersion == "v1" && typeMeta.Kind == "List" {
+		list := metav1.List{}
+		if err := gojson.Unmarshal(data, &list); err != nil {
+			return nil, err
+		}
+		var mcs []*mcfgv1.MachineConfig
+		for _, item := range list.Items {
+			decoded, err := decodeMachineConfigObject(decoder, item.Raw)
+			if err != nil {
+				return nil, err
+			}
+			mcs = append(mcs, decoded...)
+		}
+		return mcs, nil
+	}
+
+	obj, err := runtime.Decode(decoder, data)
+	if err != nil {
+		return nil, err
+	}
+	switch o := obj.(type) {
+	case *mcfgv1.MachineConfig:
+		return []*mcfgv1.MachineConfig{o}, nil
+	case *mcfgv1.MachineConfigList:
+		mcs := make([]*mcfgv1.MachineConfig, 0, len(o.Items))
+		for i := range o.Items {
+			mcs = append(mcs, &o.Items[i])
+		}
+		return mcs, nil
+	default:
+		return nil, fmt.Errorf("expected a MachineConfig or MachineConfigList, found %T", obj)
+	}
+}
+
+// filterTemplateMachineConfigs returns the MachineConfigs which were, or would
+// be, rendered from the templates, so that the diff is not cluttered with
+// user-provided or other controller-rendered MachineConfigs.
+func filterTemplateMachineConfigs(existing, rendered []*mcfgv1.MachineConfig) []*mcfgv1.MachineConfig {
+	names := map[string]bool{}
+	for _, mc := range rendered {
+		names[mc.Name] = true
+	}
+	var out []*mcfgv1.MachineConfig
+	for _, mc := range existing {
+		ref := metav1.GetControllerOf(mc)
+		if names[mc.Name] || (ref != nil && ref.Kind == "ControllerConfig" && !strings.HasPrefix(mc.Name, "rendered-")) {
+			out = append(out, mc)
+		}
+	}
+	return out
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/serializer"
+
+	mcfgv1 "github.com/openshift/machine-config-operator/pkg/apis/machineconfiguration.openshift.io/v1"
+)
+
+func TestDecodeMachineConfigs(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, mcfgv1.Install(scheme))
+	decoder := serializer.NewCodecFactory(scheme).UniversalDecoder(mcfgv1.GroupVersion)
+
+	testCases := []struct {
+		name        string
+		input       string
+		expected    []string
+		errExpected bool
+	}{
+		{
+			name: "Multiple documents",
+			input: `---
+apiVersion: machineconfiguration.openshift.io/v1
+kind: MachineConfig
+metadata:
+  name: 00-master
+---
+apiVersion: machineconfiguration.openshift.io/v1
+kind: MachineConfig
+metadata:
+  name: 00-worker
+`,
+			expected: []string{"00-master", "00-worker"},
+		},
+		{
+			name: "MachineConfigList",
+			input: `apiVersion: machineconfiguration.openshift.io/v1
+kind: MachineConfigList
+items:
+- metadata:
+    name: 00-master
+`,
+			expected: []string{"00-master"},
+		},
+		{
+			name: "v1 List",
+			input: `apiVersion: v1
+kind: List
+items:
+- apiVersion: machineconfiguration.openshift.io/v1
+  kind: MachineConfig
+  metadata:
+    name: 00-master
+- apiVersion: machineconfiguration.openshift.io/v1
+  kind: MachineConfig
+  metadata:
+    name: 00-worker
+`,
+			expected: []string{"00-master", "00-worker"},
+		},
+		{
+			name:     "JSON",
+			input:    `{"apiVersion": "machineconfiguration.openshift.io/v1", "kind": "MachineConfig", "metadata": {"name": "00-master"}}`,
+			expected: []string{"00-master"},
+		},
+		{
+			name: "Other kind",
+			input: `apiVersion: machineconfiguration.openshift.io/v1
+kind: ControllerConfig
+metadata:
+  name: machine-config-controller
+`,
+			errExpected: true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			mcs, err := decodeMachineConfigs(decoder, strings.NewReader(testCase.input))
+			if testCase.errExpected {
+				assert.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			names := []string{}
+			for _, mc := range mcs {
+				names = append(names, mc.Name)
+			}
+			assert.Equal(t, testCase.expected, names)
+		})
+	}
+}
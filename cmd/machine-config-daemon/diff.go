@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+
+	"github.com/spf13/cobra"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+
+	"github.com/openshift/machine-config-operator/internal/clients"
+	mcfgv1 "github.com/openshift/machine-config-operator/pkg/apis/machineconfiguration.openshift.io/v1"
+	ctrlcommon "github.com/openshift/machine-config-operator/pkg/controller/common"
+	"github.com/openshift/machine-config-operator/pkg/daemon"
+)
+
+var (
+	diffCmd = &cobra.Command{
+		Use:   "diff FROM TO",
+		Short: "Shows what changes on a node when it is updated from one MachineConfig to another",
+		Long: `Compares two MachineConfigs the way the daemon does when updating a node from FROM to
+TO, without looking at the node: files, systemd units and dropins with unified diffs of
+their contents, kernel arguments, extensions, kernel type, OS image and FIPS. It also
+predicts whether the update can be applied and what the daemon would do afterwards,
+e.g. reboot, reload crio or restart units.
+
+FROM and TO are each a file path, a URL, or the name of a MachineConfig in the cluster.
+The contents of the pull secret, of files which are not world readable and of encrypted
+files are not shown unless --show-sensitive is given. Encrypted files are decrypted with
+the content encryption key of the cluster; if it can not be read, they are skipped.`,
+		Args: cobra.ExactArgs(2),
+		Run:  runDiffCmd,
+	}
+
+	diffOpts struct {
+		kubeconfig    string
+		output        string
+		showSensitive bool
+	}
+)
+
+func init() {
+	rootCmd.AddCommand(diffCmd)
+	diffCmd.PersistentFlags().StringVar(&diffOpts.kubeconfig, "kubeconfig", "", "Kubeconfig file used to fetch MachineConfigs by name; defaults to $KUBECONFIG or the in-cluster config")
+	diffCmd.PersistentFlags().StringVarP(&diffOpts.output, "output", "o", "text", "Output format, text or json")
+	diffCmd.PersistentFlags().BoolVar(&diffOpts.showSensitive, "show-sensitive", false, "Show the contents of sensitive files")
+}
+
+func runDiffCmd(_ *cobra.Command, args []string) {
+	flag.Set("logtostderr", "true")
+	flag.Parse()
+
+	if diffOpts.output != "text" && diffOpts.output != "json" {
+		klog.Fatalf("--output must be text or json, got %q", diffOpts.output)
+	}
+
+	oldConfig, err := readMachineConfigForDiff(args[0])
+	if err != nil {
+		klog.Fatalf("%v", err)
+	}
+	newConfig, err := readMachineConfigForDiff(args[1])
+	if err != nil {
+		klog.Fatalf("%v", err)
+	}
+
+	report, err := daemon.NewMachineConfigDiffReport(oldConfig, newConfig, diffOpts.showSensitive)
+	if err != nil {
+		klog.Fatalf("could not diff MachineConfigs: %v", err)
+	}
+
+	if diffOpts.output == "text" {
+		fmt.Print(report.String())
+		return
+	}
+	out, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		klog.Fatalf("could not marshal report: %v", err)
+	}
+	fmt.Println(string(out))
+}
+
+// readMachineConfigForDiff reads the MachineConfig and decrypts its encrypted
+// file contents, if it can.
+func readMachineConfigForDiff(config string) (*mcfgv1.MachineConfig, error) {
+	var mc *mcfgv1.MachineConfig
+	var err error
+	if isMachineConfigName(config) {
+		mc, err = getMachineConfigFromCluster(diffOpts.kubeconfig, config)
+	} else {
+		mc, err = daemon.ReadMachineConfigFrom(config)
+	}
+	if err != nil || !ctrlcommon.IsMachineConfigEncrypted(mc) {
+		return mc, err
+	}
+
+	decrypted, err := decryptMachineConfigWithClusterKey(diffOpts.kubeconfig, mc)
+	if err != nil {
+		klog.Warningf("Could not decrypt MachineConfig %s, its encrypted files will not be diffed: %v", mc.Name, err)
+		return mc, nil
+	}
+	return decrypted, nil
+}
+
+// decryptMachineConfigWithClusterKey decrypts the file contents of mc with the
+// key derived for it from the content encryption key in the cluster.
+func decryptMachineConfigWithClusterKey(kubeconfig string, mc *mcfgv1.MachineConfig) (*mcfgv1.MachineConfig, error) {
+	cb, err := clients.NewBuilder(kubeconfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize ClientBuilder: %w", err)
+	}
+
+	kubeClient, err := cb.KubeClient(componentName)
+	if err != nil {
+		return nil, fmt.Errorf("cannot initialize kube client: %w", err)
+	}
+
+	secret, err := kubeClient.CoreV1().Secrets(ctrlcommon.MCONamespace).Get(context.TODO(), ctrlcommon.ContentEncryptionKeySecretName, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("could not get content encryption key: %w", err)
+	}
+
+	key := ctrlcommon.DeriveMachineConfigKey(secret.Data[ctrlcommon.ContentEncryptionKeySecretKey], mc.Name)
+	return ctrlcommon.DecryptMachineConfig(mc, key)
+}
@@ -31,6 +31,10 @@ var (
 		hypershiftDesiredConfigMap string
 		onceFrom                   string
 		skipReboot                 bool
+		onceFromSHA256             string
+		onceFromPublicKey          string
+		onceFromSignature          string
+		onceFromReport             string
 		fromIgnition               bool
 		kubeletHealthzEnabled      bool
 		kubeletHealthzEndpoint     string
@@ -46,6 +50,10 @@ func init() {
 	startCmd.PersistentFlags().StringVar(&startOpts.hypershiftDesiredConfigMap, "desired-configmap", "", "Runs the daemon for a Hypershift hosted cluster node. Requires a configmap with desired config as input.")
 	startCmd.PersistentFlags().StringVar(&startOpts.onceFrom, "once-from", "", "Runs the daemon once using a provided file path or URL endpoint as its machine config or ignition (.ign) file source")
 	startCmd.PersistentFlags().BoolVar(&startOpts.skipReboot, "skip-reboot", false, "Skips reboot after a sync, applies only in once-from")
+	startCmd.PersistentFlags().StringVar(&startOpts.onceFromSHA256, "once-from-sha256", "", "Expected sha256 checksum of the once-from content, applies only in once-from")
+	startCmd.PersistentFlags().StringVar(&startOpts.onceFromPublicKey, "once-from-public-key", "", "PEM public key used to verify a detached signature of the once-from content, applies only in once-from")
+	startCmd.PersistentFlags().StringVar(&startOpts.onceFromSignature, "once-from-signature", "", "File path or URL of the detached signature of the once-from content (defaults to the once-from source with a .sig suffix), applies only in once-from")
+	startCmd.PersistentFlags().StringVar(&startOpts.onceFromReport, "report", "", "Writes a JSON report of what was applied and skipped to the given path, applies only in once-from")
 	startCmd.PersistentFlags().BoolVar(&startOpts.kubeletHealthzEnabled, "kubelet-healthz-enabled", true, "kubelet healthz endpoint monitoring")
 	startCmd.PersistentFlags().StringVar(&startOpts.kubeletHealthzEndpoint, "kubelet-healthz-endpoint", "http://localhost:10248/healthz", "healthz endpoint to check health")
 	startCmd.PersistentFlags().StringVar(&startOpts.promMetricsURL, "metrics-url", "127.0.0.1:8797", "URL for prometheus metrics listener")
@@ -99,7 +107,13 @@ func runStartCmd(_ *cobra.Command, _ []string) {
 	// If we are asked to run once and it's a valid file system path use
 	// the bare Daemon
 	if startOpts.onceFrom != "" {
-		err = dn.RunOnceFrom(startOpts.onceFrom, startOpts.skipReboot)
+		err = dn.RunOnceFrom(startOpts.onceFrom, daemon.OnceFromOptions{
+			SkipReboot:    startOpts.skipReboot,
+			SHA256:        startOpts.onceFromSHA256,
+			PublicKeyPath: startOpts.onceFromPublicKey,
+			Signature:     startOpts.onceFromSignature,
+			ReportPath:    startOpts.onceFromReport,
+		})
 		if err != nil {
 			klog.Fatalf("%v", err)
 		}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+
+	"github.com/openshift/machine-config-operator/internal/clients"
+	mcfgv1 "github.com/openshift/machine-config-operator/pkg/apis/machineconfiguration.openshift.io/v1"
+	ctrlcommon "github.com/openshift/machine-config-operator/pkg/controller/common"
+	"github.com/openshift/machine-config-operator/pkg/daemon"
+	"github.com/openshift/machine-config-operator/pkg/daemon/constants"
+)
+
+// reexecMachineConfigPattern matches the files in which MachineConfigs fetched
+// from the cluster are handed over to the re-executed process.
+const reexecMachineConfigPattern = "/run/machine-config-daemon-verify-*.json"
+
+var (
+	verifyCmd = &cobra.Command{
+		Use:   "verify",
+		Short: "Verifies that the node matches a MachineConfig without modifying it",
+		Long: `Compares the files, units, kernel arguments, extensions, kernel type and OS image
+of the node against a rendered MachineConfig and reports every mismatch as JSON.
+Exits with a non-zero status if any mismatch is found or a check could not be run.`,
+		Args: cobra.NoArgs,
+		Run:  runVerifyCmd,
+	}
+
+	verifyOpts struct {
+		config     string
+		kubeconfig string
+		nodeName   string
+		rootMount  string
+		output     string
+	}
+)
+
+func init() {
+	rootCmd.AddCommand(verifyCmd)
+	verifyCmd.PersistentFlags().StringVar(&verifyOpts.config, "config", "", "MachineConfig to verify against: a file path (relative to the root mount), a URL, or the name of a MachineConfig in the cluster")
+	verifyCmd.PersistentFlags().StringVar(&verifyOpts.kubeconfig, "kubeconfig", "", "Kubeconfig file used to fetch the MachineConfig by name; defaults to the in-cluster config")
+	verifyCmd.PersistentFlags().StringVar(&verifyOpts.nodeName, "node-name", "", "Node whose key decrypts the encrypted file contents of a MachineConfig fetched by name; defaults to the NODE_NAME environment variable")
+	verifyCmd.PersistentFlags().StringVar(&verifyOpts.rootMount, "root-mount", "/rootfs", "where the nodes root filesystem is mounted for chroot and file inspection.")
+	verifyCmd.PersistentFlags().StringVar(&verifyOpts.output, "output", "", "Writes the JSON report to the given path instead of stdout")
+}
+
+func runVerifyCmd(_ *cobra.Command, _ []string) {
+	flag.Set("logtostderr", "true")
+	flag.Parse()
+
+	if verifyOpts.config == "" {
+		klog.Fatalf("--config is required")
+	}
+
+	// A MachineConfig referenced by name is fetched from the cluster before
+	// re-executing in the target root, where the kubeconfig may not be
+	// reachable, and handed over as a file in the target root. Its encrypted
+	// file contents are decrypted with the key handed out to the node;
+	// otherwise they are skipped by Verify.
+	var mc *mcfgv1.MachineConfig
+	if isMachineConfigName(verifyOpts.config) {
+		fetched, err := getMachineConfigFromCluster(verifyOpts.kubeconfig, verifyOpts.config)
+		if err != nil {
+			klog.Fatalf("%v", err)
+		}
+		mc = fetched
+		if ctrlcommon.IsMachineConfigEncrypted(mc) {
+			decrypted, err := decryptMachineConfigForNode(verifyOpts.kubeconfig, verifyOpts.nodeName, verifyOpts.rootMount, mc)
+			if err != nil {
+				klog.Warningf("Could not decrypt MachineConfig %s, its encrypted files will not be verified: %v", mc.Name, err)
+			} else {
+				mc = decrypted
+			}
+		}
+		if verifyOpts.rootMount != "/" {
+			configPath, err := writeMachineConfigForReexec(verifyOpts.rootMount, mc)
+			if err != nil {
+				klog.Fatalf("%v", err)
+			}
+			os.Args = append(os.Args, "--config="+configPath)
+		}
+	}
+
+	if err := daemon.ReexecuteForTargetRoot(verifyOpts.rootMount); err != nil {
+		klog.Fatalf("failed to re-exec: %+v", err)
+	}
+
+	if mc == nil {
+		fetched, err := daemon.ReadMachineConfigFrom(verifyOpts.config)
+		if err != nil {
+			klog.Fatalf("%v", err)
+		}
+		mc = fetched
+		if handedOver, _ := filepath.Match(reexecMachineConfigPattern, verifyOpts.config); handedOver {
+			if err := os.Remove(verifyOpts.config); err != nil {
+				klog.Warningf("could not remove %s: %v", verifyOpts.config, err)
+			}
+		}
+	}
+
+	exitCh := make(chan error)
+	defer close(exitCh)
+
+	dn, err := daemon.New(exitCh)
+	if err != nil {
+		klog.Fatalf("Failed to initialize daemon: %v", err)
+	}
+
+	report := dn.Verify(mc)
+
+	out, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		klog.Fatalf("could not marshal report: %v", err)
+	}
+
+	if verifyOpts.output != "" {
+		if err := os.WriteFile(verifyOpts.output, out, 0o644); err != nil {
+			klog.Fatalf("could not write report: %v", err)
+		}
+	} else {
+		fmt.Println(string(out))
+	}
+
+	if len(report.SkippedFiles) > 0 {
+		klog.Warningf("Skipped %d files of MachineConfig %s with encrypted contents", len(report.SkippedFiles), mc.Name)
+	}
+
+	if !report.Matches() {
+		klog.Errorf("Node does not match MachineConfig %s: %d mismatches, %d errors", mc.Name, len(report.Mismatches), len(report.Errors))
+		os.Exit(1)
+	}
+
+	klog.Infof("Node matches MachineConfig %s", mc.Name)
+}
+
+// isMachineConfigName returns true if config is neither a URL nor a path.
+func isMachineConfigName(config string) bool {
+	if strings.HasPrefix(config, "http://") || strings.HasPrefix(config, "https://") {
+		return false
+	}
+	return !daemon.ValidPath(config)
+}
+
+func getMachineConfigFromCluster(kubeconfig, name string) (*mcfgv1.MachineConfig, error) {
+	cb, err := clients.NewBuilder(kubeconfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize ClientBuilder: %w", err)
+	}
+
+	mcClient, err := cb.MachineConfigClient(componentName)
+	if err != nil {
+		return nil, fmt.Errorf("cannot initialize MachineConfig client: %w", err)
+	}
+
+	mc, err := mcClient.MachineconfigurationV1().MachineConfigs().Get(context.TODO(), name, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("could not get MachineConfig %s: %w", name, err)
+	}
+
+	return mc, nil
+}
+
+// decryptMachineConfigForNode decrypts the file contents of mc with the private
+// key of the node, read from the root mount, and the key handed out to the node
+// in its annotations.
+func decryptMachineConfigForNode(kubeconfig, nodeName, rootMount string, mc *mcfgv1.MachineConfig) (*mcfgv1.MachineConfig, error) {
+	if nodeName == "" {
+		nodeName = os.Getenv("NODE_NAME")
+	}
+	if nodeName == "" {
+		return nil, fmt.Errorf("--node-name is required to decrypt MachineConfig %s", mc.Name)
+	}
+
+	privateKey, err := daemon.LoadContentEncryptionKey(filepath.Join(rootMount, constants.ContentEncryptionPrivateKeyPath))
+	if err != nil {
+		return nil, fmt.Errorf("could not load content encryption key: %w", err)
+	}
+
+	cb, err := clients.NewBuilder(kubeconfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize ClientBuilder: %w", err)
+	}
+
+	kubeClient, err := cb.KubeClient(componentName)
+	if err != nil {
+		return nil, fmt.Errorf("cannot initialize kube client: %w", err)
+	}
+
+	node, err := kubeClient.CoreV1().Nodes().Get(context.TODO(), nodeName, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("could not get node %s: %w", nodeName, err)
+	}
+
+	return daemon.DecryptMachineConfigForNode(mc, node, privateKey)
+}
+
+// writeMachineConfigForReexec writes the MachineConfig under /run in the target
+// root and returns its path as seen from within the target root.
+func writeMachineConfigForReexec(rootMount string, mc *mcfgv1.MachineConfig) (string, error) {
+	mc = mc.DeepCopy()
+	mc.TypeMeta = metav1.TypeMeta{
+		APIVersion: mcfgv1.SchemeGroupVersion.String(),
+		Kind:       "MachineConfig",
+	}
+
+	data, err := json.Marshal(mc)
+	if err != nil {
+		return "", fmt.Errorf("could not marshal MachineConfig %s: %w", mc.Name, err)
+	}
+
+	configPath := strings.Replace(reexecMachineConfigPattern, "*", mc.Name, 1)
+	if err := os.WriteFile(filepath.Join(rootMount, configPath), data, 0o600); err != nil {
+		return "", fmt.Errorf("could not write MachineConfig %s: %w", mc.Name, err)
+	}
+
+	return configPath, nil
+}
@@ -57,6 +57,14 @@ func ensureMachineConfigSpec(modified *bool, existing *mcfgv1.MachineConfigSpec,
 		*modified = true
 		(*existing).Config = required.Config
 	}
+	if !equality.Semantic.DeepEqual(existing.FileReferences, required.FileReferences) {
+		*modified = true
+		(*existing).FileReferences = required.FileReferences
+	}
+	if !equality.Semantic.DeepEqual(existing.NodeSettings, required.NodeSettings) {
+		*modified = true
+		(*existing).NodeSettings = required.NodeSettings
+	}
 	if existing.FIPS != required.FIPS {
 		*modified = true
 		(*existing).FIPS = required.FIPS
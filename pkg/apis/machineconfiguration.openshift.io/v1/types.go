@@ -250,6 +250,124 @@ type MachineConfigSpec struct {
 
 	FIPS       bool   `json:"fips"`
 	KernelType string `json:"kernelType"`
+
+	// FileReferences are files whose contents are taken from a Secret or
+	// ConfigMap in the openshift-machine-config-operator namespace. They are
+	// resolved when the rendered MachineConfig is generated and override files
+	// with the same path in Config.
+	// +optional
+	FileReferences []MachineConfigFileReference `json:"fileReferences,omitempty"`
+
+	// NodeSettings are common node settings that are rendered into files and
+	// kernel arguments when the rendered MachineConfig is generated.
+	// +optional
+	NodeSettings *MachineConfigNodeSettings `json:"nodeSettings,omitempty"`
+}
+
+// MachineConfigFileReference is a file whose contents are taken from a key of
+// a Secret or ConfigMap. Exactly one of SecretKeyRef and ConfigMapKeyRef must
+// be set.
+type MachineConfigFileReference struct {
+	// Path is the absolute path of the file on the node.
+	Path string `json:"path"`
+
+	// Mode is the file mode. Defaults to 0600 for Secrets and 0644 for ConfigMaps.
+	// +optional
+	Mode *int `json:"mode,omitempty"`
+
+	// SecretKeyRef selects a key of a Secret.
+	// +optional
+	SecretKeyRef *corev1.SecretKeySelector `json:"secretKeyRef,omitempty"`
+
+	// ConfigMapKeyRef selects a key of a ConfigMap.
+	// +optional
+	ConfigMapKeyRef *corev1.ConfigMapKeySelector `json:"configMapKeyRef,omitempty"`
+}
+
+// MachineConfigNodeSettings are typed node settings. Changing sysctls, loaded
+// kernel modules, chrony and journald restarts the service applying them
+// instead of rebooting the node.
+type MachineConfigNodeSettings struct {
+	// Sysctls are kernel parameters set with systemd-sysctl.
+	// +optional
+	Sysctls []MachineConfigSysctl `json:"sysctls,omitempty"`
+
+	// KernelModules are kernel modules to load at boot or to blacklist.
+	// +optional
+	KernelModules *MachineConfigKernelModules `json:"kernelModules,omitempty"`
+
+	// Chrony replaces /etc/chrony.conf with one using the given time sources.
+	// +optional
+	Chrony *MachineConfigChrony `json:"chrony,omitempty"`
+
+	// Journald sets limits of the systemd journal.
+	// +optional
+	Journald *MachineConfigJournald `json:"journald,omitempty"`
+
+	// Hugepages are allocated at boot with kernel arguments.
+	// +optional
+	Hugepages *MachineConfigHugepages `json:"hugepages,omitempty"`
+}
+
+// MachineConfigSysctl is a kernel parameter, e.g. net.ipv4.ip_forward.
+type MachineConfigSysctl struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// MachineConfigKernelModules lists kernel modules by name.
+type MachineConfigKernelModules struct {
+	// Load are loaded with systemd-modules-load.
+	// +optional
+	Load []string `json:"load,omitempty"`
+
+	// Blacklist are never loaded automatically. Blacklisting takes effect after a reboot.
+	// +optional
+	Blacklist []string `json:"blacklist,omitempty"`
+}
+
+// MachineConfigChrony are the time sources of chronyd.
+type MachineConfigChrony struct {
+	// +optional
+	Servers []string `json:"servers,omitempty"`
+	// +optional
+	Pools []string `json:"pools,omitempty"`
+}
+
+// MachineConfigJournald are settings of journald.conf(5).
+type MachineConfigJournald struct {
+	// Storage is one of volatile, persistent, auto and none.
+	// +optional
+	Storage string `json:"storage,omitempty"`
+	// SystemMaxUse is a size like 4G.
+	// +optional
+	SystemMaxUse string `json:"systemMaxUse,omitempty"`
+	// RuntimeMaxUse is a size like 512M.
+	// +optional
+	RuntimeMaxUse string `json:"runtimeMaxUse,omitempty"`
+	// MaxRetentionSec is a time span like 1week.
+	// +optional
+	MaxRetentionSec string `json:"maxRetentionSec,omitempty"`
+	// RateLimitIntervalSec is a time span like 30s.
+	// +optional
+	RateLimitIntervalSec string `json:"rateLimitIntervalSec,omitempty"`
+	// +optional
+	RateLimitBurst *int32 `json:"rateLimitBurst,omitempty"`
+}
+
+// MachineConfigHugepages are the hugepages to allocate at boot.
+type MachineConfigHugepages struct {
+	// DefaultSize is the default hugepage size, 2M or 1G.
+	// +optional
+	DefaultSize string `json:"defaultSize,omitempty"`
+	// Pages are the number of pages to allocate by size.
+	Pages []MachineConfigHugepagesPage `json:"pages"`
+}
+
+// MachineConfigHugepagesPage is a number of hugepages of a size, 2M or 1G.
+type MachineConfigHugepagesPage struct {
+	Size  string `json:"size"`
+	Count int32  `json:"count"`
 }
 
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
@@ -339,6 +457,16 @@ type MachineConfigPoolStatus struct {
 
 	// certExpirys keeps track of important certificate expiration data
 	CertExpirys []CertExpiry `json:"certExpirys"`
+
+	// nodeUpdateProgress reports the update phase of each machine in the pool
+	// which is applying a MachineConfig.
+	// +optional
+	NodeUpdateProgress []NodeUpdateProgress `json:"nodeUpdateProgress,omitempty"`
+
+	// configConflicts lists the files, systemd units and dropins which more
+	// than one source MachineConfig of the pool defines differently.
+	// +optional
+	ConfigConflicts []MachineConfigConflict `json:"configConflicts,omitempty"`
 }
 
 // ceryExpiry contains the bundle name and the expiry date
@@ -348,6 +476,77 @@ type CertExpiry struct {
 	Expiry  string `json:"expiry"`
 }
 
+// NodeUpdatePhase is a step the machine config daemon goes through while
+// applying a MachineConfig to a node.
+type NodeUpdatePhase string
+
+const (
+	// NodeUpdatePhaseDrainRequested means the daemon asked the controller to drain the node.
+	NodeUpdatePhaseDrainRequested NodeUpdatePhase = "DrainRequested"
+	// NodeUpdatePhaseDrained means the controller finished draining the node.
+	NodeUpdatePhaseDrained NodeUpdatePhase = "Drained"
+	// NodeUpdatePhaseFilesWritten means the files and units of the new config were written.
+	NodeUpdatePhaseFilesWritten NodeUpdatePhase = "FilesWritten"
+	// NodeUpdatePhaseOSStaged means the OS changes of the new config were staged.
+	NodeUpdatePhaseOSStaged NodeUpdatePhase = "OSStaged"
+	// NodeUpdatePhaseRebooting means the daemon initiated a reboot into the new config.
+	NodeUpdatePhaseRebooting NodeUpdatePhase = "Rebooting"
+	// NodeUpdatePhasePostRebootValidation means the node came back up and the daemon is
+	// validating the on-disk state against the new config.
+	NodeUpdatePhasePostRebootValidation NodeUpdatePhase = "PostRebootValidation"
+	// NodeUpdatePhaseUncordoned means the node was uncordoned and the update is complete.
+	NodeUpdatePhaseUncordoned NodeUpdatePhase = "Uncordoned"
+)
+
+// NodeUpdateProgress is the last update phase reported by the daemon of a node.
+type NodeUpdateProgress struct {
+	// name is the name of the node.
+	Name string `json:"name"`
+
+	// targetConfig is the MachineConfig the node is updating to.
+	TargetConfig string `json:"targetConfig"`
+
+	// phase is the last phase the node entered. It is empty if the daemon
+	// has not reported any phase for the target config yet.
+	// +optional
+	Phase NodeUpdatePhase `json:"phase,omitempty"`
+
+	// lastTransitionTime is when the node entered the phase.
+	// +nullable
+	// +optional
+	LastTransitionTime metav1.Time `json:"lastTransitionTime,omitempty"`
+}
+
+// MachineConfigConflictType is the kind of object source MachineConfigs conflict on.
+type MachineConfigConflictType string
+
+const (
+	// MachineConfigConflictFile is a file defined with different contents, mode or owner.
+	MachineConfigConflictFile MachineConfigConflictType = "File"
+	// MachineConfigConflictUnit is a systemd unit defined with different contents.
+	MachineConfigConflictUnit MachineConfigConflictType = "Unit"
+	// MachineConfigConflictDropin is a systemd dropin defined with different contents.
+	MachineConfigConflictDropin MachineConfigConflictType = "Dropin"
+)
+
+// MachineConfigConflict is a file, unit or dropin which more than one source
+// MachineConfig defines differently. The definition of the lexically last
+// MachineConfig ends up in the rendered MachineConfig.
+type MachineConfigConflict struct {
+	// type is the kind of object the MachineConfigs conflict on.
+	Type MachineConfigConflictType `json:"type"`
+
+	// name is the path of the file, the name of the unit, or the name of the
+	// unit and the dropin separated by a slash.
+	Name string `json:"name"`
+
+	// winner is the MachineConfig whose definition is rendered.
+	Winner string `json:"winner"`
+
+	// losers are the MachineConfigs whose differing definitions are overridden.
+	Losers []string `json:"losers"`
+}
+
 // MachineConfigPoolStatusConfiguration stores the current configuration for the pool, and
 // optionally also stores the list of MachineConfig objects used to generate the configuration.
 type MachineConfigPoolStatusConfiguration struct {
@@ -605,3 +804,119 @@ type ContainerRuntimeConfigList struct {
 
 	Items []ContainerRuntimeConfig `json:"items"`
 }
+
+// +genclient
+// +genclient:nonNamespaced
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// MachineConfigTemplate describes files and units which are rendered as Go templates
+// against the ControllerConfig into a MachineConfig for a role.
+type MachineConfigTemplate struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	// +required
+	Spec MachineConfigTemplateSpec `json:"spec"`
+	// +optional
+	Status MachineConfigTemplateStatus `json:"status"`
+}
+
+// MachineConfigTemplateSpec defines the desired state of MachineConfigTemplate
+type MachineConfigTemplateSpec struct {
+	// role is set as the machineconfiguration.openshift.io/role label of the rendered
+	// MachineConfig, which selects the pools it is applied to.
+	Role string `json:"role"`
+
+	// files are written to the machines of the role.
+	// +optional
+	Files []MachineConfigTemplateFile `json:"files,omitempty"`
+
+	// units are systemd units written to the machines of the role.
+	// +optional
+	Units []MachineConfigTemplateUnit `json:"units,omitempty"`
+}
+
+// MachineConfigTemplateFile is a file whose contents are a template.
+type MachineConfigTemplateFile struct {
+	// path is the absolute path of the file.
+	Path string `json:"path"`
+
+	// mode is the permissions of the file in decimal, e.g. 420 for 0644. Defaults to 420.
+	// +optional
+	Mode *int `json:"mode,omitempty"`
+
+	// contents is a Go template which is rendered with the same data and functions as
+	// the MachineConfigs of the MCO itself. The file is omitted if it renders to
+	// nothing but whitespace.
+	Contents string `json:"contents"`
+}
+
+// MachineConfigTemplateUnit is a systemd unit whose contents are a template.
+type MachineConfigTemplateUnit struct {
+	// name is the name of the unit, e.g. example.service.
+	Name string `json:"name"`
+
+	// enabled is whether the unit is enabled. If unset, the unit is left as is.
+	// +optional
+	Enabled *bool `json:"enabled,omitempty"`
+
+	// contents is a Go template which is rendered the same way as the contents of files.
+	// The unit is omitted if it renders to nothing but whitespace.
+	Contents string `json:"contents"`
+}
+
+// MachineConfigTemplateStatus defines the observed state of a MachineConfigTemplate
+type MachineConfigTemplateStatus struct {
+	// observedGeneration represents the generation observed by the controller.
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// machineConfig is the name of the MachineConfig rendered from the template.
+	// +optional
+	MachineConfig string `json:"machineConfig,omitempty"`
+
+	// conditions represents the latest available observations of current state.
+	// +optional
+	Conditions []MachineConfigTemplateCondition `json:"conditions"`
+}
+
+// MachineConfigTemplateCondition defines the state of the MachineConfigTemplate
+type MachineConfigTemplateCondition struct {
+	// type specifies the state of the operator's reconciliation functionality.
+	Type MachineConfigTemplateStatusConditionType `json:"type"`
+
+	// status of the condition, one of True, False, Unknown.
+	Status corev1.ConditionStatus `json:"status"`
+
+	// lastTransitionTime is the time of the last update to the current status object.
+	// +nullable
+	LastTransitionTime metav1.Time `json:"lastTransitionTime"`
+
+	// reason is the reason for the condition's last transition.  Reasons are PascalCase
+	Reason string `json:"reason,omitempty"`
+
+	// message provides additional information about the current condition.
+	// This is only to be consumed by humans.
+	Message string `json:"message,omitempty"`
+}
+
+// MachineConfigTemplateStatusConditionType is the state of the operator's reconciliation functionality.
+type MachineConfigTemplateStatusConditionType string
+
+const (
+	// MachineConfigTemplateSuccess designates a successful rendering of a MachineConfigTemplate CR.
+	MachineConfigTemplateSuccess MachineConfigTemplateStatusConditionType = "Success"
+
+	// MachineConfigTemplateFailure designates a failure rendering a MachineConfigTemplate CR.
+	MachineConfigTemplateFailure MachineConfigTemplateStatusConditionType = "Failure"
+)
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// MachineConfigTemplateList is a list of MachineConfigTemplate resources
+type MachineConfigTemplateList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata"`
+
+	Items []MachineConfigTemplate `json:"items"`
+}
@@ -13,6 +13,22 @@ import (
 	intstr "k8s.io/apimachinery/pkg/util/intstr"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CertExpiry) DeepCopyInto(out *CertExpiry) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CertExpiry.
+func (in *CertExpiry) DeepCopy() *CertExpiry {
+	if in == nil {
+		return nil
+	}
+	out := new(CertExpiry)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ContainerRuntimeConfig) DeepCopyInto(out *ContainerRuntimeConfig) {
 	*out = *in
@@ -163,6 +179,22 @@ func (in *ContainerRuntimeConfiguration) DeepCopy() *ContainerRuntimeConfigurati
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ControllerCertificate) DeepCopyInto(out *ControllerCertificate) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ControllerCertificate.
+func (in *ControllerCertificate) DeepCopy() *ControllerCertificate {
+	if in == nil {
+		return nil
+	}
+	out := new(ControllerCertificate)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ControllerConfig) DeepCopyInto(out *ControllerConfig) {
 	*out = *in
@@ -247,6 +279,20 @@ func (in *ControllerConfigSpec) DeepCopyInto(out *ControllerConfigSpec) {
 		*out = make([]byte, len(*in))
 		copy(*out, *in)
 	}
+	if in.ImageRegistryBundleUserData != nil {
+		in, out := &in.ImageRegistryBundleUserData, &out.ImageRegistryBundleUserData
+		*out = make([]ImageRegistryBundle, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.ImageRegistryBundleData != nil {
+		in, out := &in.ImageRegistryBundleData, &out.ImageRegistryBundleData
+		*out = make([]ImageRegistryBundle, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 	if in.PullSecret != nil {
 		in, out := &in.PullSecret, &out.PullSecret
 		*out = new(corev1.ObjectReference)
@@ -259,20 +305,6 @@ func (in *ControllerConfigSpec) DeepCopyInto(out *ControllerConfigSpec) {
 			(*out)[key] = val
 		}
 	}
-	if in.ImageRegistryBundleData != nil {
-		in, out := &in.ImageRegistryBundleData, &out.ImageRegistryBundleData
-		*out = make([]ImageRegistryBundle, len(*in))
-		for i := range *in {
-			(*in)[i].DeepCopyInto(&(*out)[i])
-		}
-	}
-	if in.ImageRegistryBundleUserData != nil {
-		in, out := &in.ImageRegistryBundleUserData, &out.ImageRegistryBundleUserData
-		*out = make([]ImageRegistryBundle, len(*in))
-		for i := range *in {
-			(*in)[i].DeepCopyInto(&(*out)[i])
-		}
-	}
 	if in.Proxy != nil {
 		in, out := &in.Proxy, &out.Proxy
 		*out = new(configv1.ProxyStatus)
@@ -306,12 +338,6 @@ func (in *ControllerConfigSpec) DeepCopy() *ControllerConfigSpec {
 	return out
 }
 
-func (in *ControllerCertificate) DeepCopyInto(out *ControllerCertificate) {
-	*out = *in
-	return
-
-}
-
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ControllerConfigStatus) DeepCopyInto(out *ControllerConfigStatus) {
 	*out = *in
@@ -325,9 +351,7 @@ func (in *ControllerConfigStatus) DeepCopyInto(out *ControllerConfigStatus) {
 	if in.ControllerCertificates != nil {
 		in, out := &in.ControllerCertificates, &out.ControllerCertificates
 		*out = make([]ControllerCertificate, len(*in))
-		for i := range *in {
-			(*in)[i].DeepCopyInto(&(*out)[i])
-		}
+		copy(*out, *in)
 	}
 	return
 }
@@ -342,14 +366,6 @@ func (in *ControllerConfigStatus) DeepCopy() *ControllerConfigStatus {
 	return out
 }
 
-
-func (in *ImageRegistryBundle) DeepCopyInto(out *ImageRegistryBundle) {
-	*out = *in
-	return
-
-}
-
-
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ControllerConfigStatusCondition) DeepCopyInto(out *ControllerConfigStatusCondition) {
 	*out = *in
@@ -367,13 +383,23 @@ func (in *ControllerConfigStatusCondition) DeepCopy() *ControllerConfigStatusCon
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ImageRegistryBundle) DeepCopyInto(out *ImageRegistryBundle) {
+	*out = *in
+	if in.Data != nil {
+		in, out := &in.Data, &out.Data
+		*out = make([]byte, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ControllerCertificate.
-func (in *ControllerCertificate) DeepCopy() *ControllerCertificate {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ImageRegistryBundle.
+func (in *ImageRegistryBundle) DeepCopy() *ImageRegistryBundle {
 	if in == nil {
 		return nil
 	}
-	out := new(ControllerCertificate)
+	out := new(ImageRegistryBundle)
 	in.DeepCopyInto(out)
 	return out
 }
@@ -547,6 +573,168 @@ func (in *MachineConfig) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MachineConfigChrony) DeepCopyInto(out *MachineConfigChrony) {
+	*out = *in
+	if in.Servers != nil {
+		in, out := &in.Servers, &out.Servers
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Pools != nil {
+		in, out := &in.Pools, &out.Pools
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MachineConfigChrony.
+func (in *MachineConfigChrony) DeepCopy() *MachineConfigChrony {
+	if in == nil {
+		return nil
+	}
+	out := new(MachineConfigChrony)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MachineConfigConflict) DeepCopyInto(out *MachineConfigConflict) {
+	*out = *in
+	if in.Losers != nil {
+		in, out := &in.Losers, &out.Losers
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MachineConfigConflict.
+func (in *MachineConfigConflict) DeepCopy() *MachineConfigConflict {
+	if in == nil {
+		return nil
+	}
+	out := new(MachineConfigConflict)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MachineConfigFileReference) DeepCopyInto(out *MachineConfigFileReference) {
+	*out = *in
+	if in.Mode != nil {
+		in, out := &in.Mode, &out.Mode
+		*out = new(int)
+		**out = **in
+	}
+	if in.SecretKeyRef != nil {
+		in, out := &in.SecretKeyRef, &out.SecretKeyRef
+		*out = new(corev1.SecretKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ConfigMapKeyRef != nil {
+		in, out := &in.ConfigMapKeyRef, &out.ConfigMapKeyRef
+		*out = new(corev1.ConfigMapKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MachineConfigFileReference.
+func (in *MachineConfigFileReference) DeepCopy() *MachineConfigFileReference {
+	if in == nil {
+		return nil
+	}
+	out := new(MachineConfigFileReference)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MachineConfigHugepages) DeepCopyInto(out *MachineConfigHugepages) {
+	*out = *in
+	if in.Pages != nil {
+		in, out := &in.Pages, &out.Pages
+		*out = make([]MachineConfigHugepagesPage, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MachineConfigHugepages.
+func (in *MachineConfigHugepages) DeepCopy() *MachineConfigHugepages {
+	if in == nil {
+		return nil
+	}
+	out := new(MachineConfigHugepages)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MachineConfigHugepagesPage) DeepCopyInto(out *MachineConfigHugepagesPage) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MachineConfigHugepagesPage.
+func (in *MachineConfigHugepagesPage) DeepCopy() *MachineConfigHugepagesPage {
+	if in == nil {
+		return nil
+	}
+	out := new(MachineConfigHugepagesPage)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MachineConfigJournald) DeepCopyInto(out *MachineConfigJournald) {
+	*out = *in
+	if in.RateLimitBurst != nil {
+		in, out := &in.RateLimitBurst, &out.RateLimitBurst
+		*out = new(int32)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MachineConfigJournald.
+func (in *MachineConfigJournald) DeepCopy() *MachineConfigJournald {
+	if in == nil {
+		return nil
+	}
+	out := new(MachineConfigJournald)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MachineConfigKernelModules) DeepCopyInto(out *MachineConfigKernelModules) {
+	*out = *in
+	if in.Load != nil {
+		in, out := &in.Load, &out.Load
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Blacklist != nil {
+		in, out := &in.Blacklist, &out.Blacklist
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MachineConfigKernelModules.
+func (in *MachineConfigKernelModules) DeepCopy() *MachineConfigKernelModules {
+	if in == nil {
+		return nil
+	}
+	out := new(MachineConfigKernelModules)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *MachineConfigList) DeepCopyInto(out *MachineConfigList) {
 	*out = *in
@@ -581,42 +769,66 @@ func (in *MachineConfigList) DeepCopyObject() runtime.Object {
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *MachineConfigPool) DeepCopyInto(out *MachineConfigPool) {
+func (in *MachineConfigNodeSettings) DeepCopyInto(out *MachineConfigNodeSettings) {
 	*out = *in
-	out.TypeMeta = in.TypeMeta
-	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
-	in.Spec.DeepCopyInto(&out.Spec)
-	in.Status.DeepCopyInto(&out.Status)
+	if in.Sysctls != nil {
+		in, out := &in.Sysctls, &out.Sysctls
+		*out = make([]MachineConfigSysctl, len(*in))
+		copy(*out, *in)
+	}
+	if in.KernelModules != nil {
+		in, out := &in.KernelModules, &out.KernelModules
+		*out = new(MachineConfigKernelModules)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Chrony != nil {
+		in, out := &in.Chrony, &out.Chrony
+		*out = new(MachineConfigChrony)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Journald != nil {
+		in, out := &in.Journald, &out.Journald
+		*out = new(MachineConfigJournald)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Hugepages != nil {
+		in, out := &in.Hugepages, &out.Hugepages
+		*out = new(MachineConfigHugepages)
+		(*in).DeepCopyInto(*out)
+	}
 	return
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MachineConfigPool.
-func (in *MachineConfigPool) DeepCopy() *MachineConfigPool {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MachineConfigNodeSettings.
+func (in *MachineConfigNodeSettings) DeepCopy() *MachineConfigNodeSettings {
 	if in == nil {
 		return nil
 	}
-	out := new(MachineConfigPool)
+	out := new(MachineConfigNodeSettings)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *CertExpiry) DeepCopyInto(out *CertExpiry) {
+func (in *MachineConfigPool) DeepCopyInto(out *MachineConfigPool) {
 	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
 	return
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CertExpiry.
-func (in *CertExpiry) DeepCopy() *CertExpiry {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MachineConfigPool.
+func (in *MachineConfigPool) DeepCopy() *MachineConfigPool {
 	if in == nil {
 		return nil
 	}
-	out := new(CertExpiry)
+	out := new(MachineConfigPool)
 	in.DeepCopyInto(out)
 	return out
 }
 
-
 // DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
 func (in *MachineConfigPool) DeepCopyObject() runtime.Object {
 	if c := in.DeepCopy(); c != nil {
@@ -718,6 +930,25 @@ func (in *MachineConfigPoolStatus) DeepCopyInto(out *MachineConfigPoolStatus) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.CertExpirys != nil {
+		in, out := &in.CertExpirys, &out.CertExpirys
+		*out = make([]CertExpiry, len(*in))
+		copy(*out, *in)
+	}
+	if in.NodeUpdateProgress != nil {
+		in, out := &in.NodeUpdateProgress, &out.NodeUpdateProgress
+		*out = make([]NodeUpdateProgress, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.ConfigConflicts != nil {
+		in, out := &in.ConfigConflicts, &out.ConfigConflicts
+		*out = make([]MachineConfigConflict, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 	return
 }
 
@@ -767,6 +998,18 @@ func (in *MachineConfigSpec) DeepCopyInto(out *MachineConfigSpec) {
 		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
+	if in.FileReferences != nil {
+		in, out := &in.FileReferences, &out.FileReferences
+		*out = make([]MachineConfigFileReference, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.NodeSettings != nil {
+		in, out := &in.NodeSettings, &out.NodeSettings
+		*out = new(MachineConfigNodeSettings)
+		(*in).DeepCopyInto(*out)
+	}
 	return
 }
 
@@ -780,6 +1023,195 @@ func (in *MachineConfigSpec) DeepCopy() *MachineConfigSpec {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MachineConfigSysctl) DeepCopyInto(out *MachineConfigSysctl) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MachineConfigSysctl.
+func (in *MachineConfigSysctl) DeepCopy() *MachineConfigSysctl {
+	if in == nil {
+		return nil
+	}
+	out := new(MachineConfigSysctl)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MachineConfigTemplate) DeepCopyInto(out *MachineConfigTemplate) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MachineConfigTemplate.
+func (in *MachineConfigTemplate) DeepCopy() *MachineConfigTemplate {
+	if in == nil {
+		return nil
+	}
+	out := new(MachineConfigTemplate)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *MachineConfigTemplate) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MachineConfigTemplateCondition) DeepCopyInto(out *MachineConfigTemplateCondition) {
+	*out = *in
+	in.LastTransitionTime.DeepCopyInto(&out.LastTransitionTime)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MachineConfigTemplateCondition.
+func (in *MachineConfigTemplateCondition) DeepCopy() *MachineConfigTemplateCondition {
+	if in == nil {
+		return nil
+	}
+	out := new(MachineConfigTemplateCondition)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MachineConfigTemplateFile) DeepCopyInto(out *MachineConfigTemplateFile) {
+	*out = *in
+	if in.Mode != nil {
+		in, out := &in.Mode, &out.Mode
+		*out = new(int)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MachineConfigTemplateFile.
+func (in *MachineConfigTemplateFile) DeepCopy() *MachineConfigTemplateFile {
+	if in == nil {
+		return nil
+	}
+	out := new(MachineConfigTemplateFile)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MachineConfigTemplateList) DeepCopyInto(out *MachineConfigTemplateList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]MachineConfigTemplate, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MachineConfigTemplateList.
+func (in *MachineConfigTemplateList) DeepCopy() *MachineConfigTemplateList {
+	if in == nil {
+		return nil
+	}
+	out := new(MachineConfigTemplateList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *MachineConfigTemplateList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MachineConfigTemplateSpec) DeepCopyInto(out *MachineConfigTemplateSpec) {
+	*out = *in
+	if in.Files != nil {
+		in, out := &in.Files, &out.Files
+		*out = make([]MachineConfigTemplateFile, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Units != nil {
+		in, out := &in.Units, &out.Units
+		*out = make([]MachineConfigTemplateUnit, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MachineConfigTemplateSpec.
+func (in *MachineConfigTemplateSpec) DeepCopy() *MachineConfigTemplateSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(MachineConfigTemplateSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MachineConfigTemplateStatus) DeepCopyInto(out *MachineConfigTemplateStatus) {
+	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]MachineConfigTemplateCondition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MachineConfigTemplateStatus.
+func (in *MachineConfigTemplateStatus) DeepCopy() *MachineConfigTemplateStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(MachineConfigTemplateStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MachineConfigTemplateUnit) DeepCopyInto(out *MachineConfigTemplateUnit) {
+	*out = *in
+	if in.Enabled != nil {
+		in, out := &in.Enabled, &out.Enabled
+		*out = new(bool)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MachineConfigTemplateUnit.
+func (in *MachineConfigTemplateUnit) DeepCopy() *MachineConfigTemplateUnit {
+	if in == nil {
+		return nil
+	}
+	out := new(MachineConfigTemplateUnit)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *NetworkInfo) DeepCopyInto(out *NetworkInfo) {
 	*out = *in
@@ -800,3 +1232,20 @@ func (in *NetworkInfo) DeepCopy() *NetworkInfo {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NodeUpdateProgress) DeepCopyInto(out *NodeUpdateProgress) {
+	*out = *in
+	in.LastTransitionTime.DeepCopyInto(&out.LastTransitionTime)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NodeUpdateProgress.
+func (in *NodeUpdateProgress) DeepCopy() *NodeUpdateProgress {
+	if in == nil {
+		return nil
+	}
+	out := new(NodeUpdateProgress)
+	in.DeepCopyInto(out)
+	return out
+}
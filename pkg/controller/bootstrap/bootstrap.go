@@ -16,6 +16,8 @@ import (
 	"k8s.io/apimachinery/pkg/runtime/serializer/json"
 	yamlutil "k8s.io/apimachinery/pkg/util/yaml"
 	kscheme "k8s.io/client-go/kubernetes/scheme"
+	corelisterv1 "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
 	"k8s.io/klog/v2"
 
 	apicfgv1 "github.com/openshift/api/config/v1"
@@ -72,8 +74,9 @@ func (b *Bootstrap) Run(destDir string) error {
 	mcfgv1.Install(scheme)
 	apioperatorsv1alpha1.Install(scheme)
 	apicfgv1.Install(scheme)
+	corev1.AddToScheme(scheme)
 	codecFactory := serializer.NewCodecFactory(scheme)
-	decoder := codecFactory.UniversalDecoder(mcfgv1.GroupVersion, apioperatorsv1alpha1.GroupVersion, apicfgv1.GroupVersion)
+	decoder := codecFactory.UniversalDecoder(mcfgv1.GroupVersion, apioperatorsv1alpha1.GroupVersion, apicfgv1.GroupVersion, corev1.SchemeGroupVersion)
 
 	var cconfig *mcfgv1.ControllerConfig
 	var featureGate *apicfgv1.FeatureGate
@@ -82,10 +85,14 @@ func (b *Bootstrap) Run(destDir string) error {
 	var pools []*mcfgv1.MachineConfigPool
 	var configs []*mcfgv1.MachineConfig
 	var crconfigs []*mcfgv1.ContainerRuntimeConfig
+	var mcts []*mcfgv1.MachineConfigTemplate
 	var icspRules []*apioperatorsv1alpha1.ImageContentSourcePolicy
 	var idmsRules []*apicfgv1.ImageDigestMirrorSet
 	var itmsRules []*apicfgv1.ImageTagMirrorSet
 	var imgCfg *apicfgv1.Image
+	// Secrets and ConfigMaps in the MCO namespace, for MachineConfig file references
+	secretIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	configMapIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
 	for _, info := range infos {
 		if info.IsDir() {
 			continue
@@ -120,6 +127,8 @@ func (b *Bootstrap) Run(destDir string) error {
 				configs = append(configs, obj)
 			case *mcfgv1.ControllerConfig:
 				cconfig = obj
+			case *mcfgv1.MachineConfigTemplate:
+				mcts = append(mcts, obj)
 			case *mcfgv1.ContainerRuntimeConfig:
 				crconfigs = append(crconfigs, obj)
 			case *mcfgv1.KubeletConfig:
@@ -140,6 +149,18 @@ func (b *Bootstrap) Run(destDir string) error {
 				if obj.GetName() == ctrlcommon.ClusterNodeInstanceName {
 					nodeConfig = obj
 				}
+			case *corev1.Secret:
+				if obj.GetNamespace() == ctrlcommon.MCONamespace {
+					if err := secretIndexer.Add(obj); err != nil {
+						return err
+					}
+				}
+			case *corev1.ConfigMap:
+				if obj.GetNamespace() == ctrlcommon.MCONamespace {
+					if err := configMapIndexer.Add(obj); err != nil {
+						return err
+					}
+				}
 			default:
 				klog.Infof("skipping %q [%d] manifest because of unhandled %T", file.Name(), idx+1, obji)
 			}
@@ -159,12 +180,31 @@ func (b *Bootstrap) Run(destDir string) error {
 		return fmt.Errorf("error creating feature gate access: %w", err)
 	}
 
-	iconfigs, err := template.RunBootstrap(b.templatesDir, cconfig, psraw, fgAccess)
+	iconfigs, err := template.RunBootstrap(b.templatesDir, cconfig, pools, psraw, fgAccess)
 	if err != nil {
 		return err
 	}
 	configs = append(configs, iconfigs...)
 
+	// The 00-<role> configs of pools encrypting sensitive files reference the
+	// pull secret, which the template controller keeps in a Secret once the
+	// cluster is up.
+	nodePullSecret, err := template.NewNodePullSecret(psraw)
+	if err != nil {
+		return err
+	}
+	if err := secretIndexer.Add(nodePullSecret); err != nil {
+		return err
+	}
+
+	if len(mcts) > 0 {
+		tconfigs, err := template.RunMachineConfigTemplateBootstrap(mcts, cconfig, psraw, fgAccess)
+		if err != nil {
+			return err
+		}
+		configs = append(configs, tconfigs...)
+	}
+
 	rconfigs, err := containerruntimeconfig.RunImageBootstrap(b.templatesDir, cconfig, pools, icspRules, idmsRules, itmsRules, imgCfg, fgAccess)
 	if err != nil {
 		return err
@@ -210,7 +250,11 @@ func (b *Bootstrap) Run(destDir string) error {
 		configs = append(configs, kconfigs...)
 	}
 
-	fpools, gconfigs, err := render.RunBootstrap(pools, configs, cconfig)
+	fileReferences := &ctrlcommon.FileReferenceResolver{
+		Secrets:    corelisterv1.NewSecretLister(secretIndexer).Secrets(ctrlcommon.MCONamespace),
+		ConfigMaps: corelisterv1.NewConfigMapLister(configMapIndexer).ConfigMaps(ctrlcommon.MCONamespace),
+	}
+	fpools, gconfigs, err := render.RunBootstrap(pools, configs, cconfig, fileReferences)
 	if err != nil {
 		return err
 	}
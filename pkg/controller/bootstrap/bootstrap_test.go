@@ -197,6 +197,11 @@ func TestBootstrapRun(t *testing.T) {
 			assert.Contains(t, string(contents), "insecure-reg-2.io")
 			assert.Contains(t, string(contents), "blocked-reg.io")
 			assert.NotContains(t, string(contents), "release-registry.product.example.org")
+
+			// The pull secret referenced by the 00-<role> configs is resolved.
+			pullSecret, err := ctrlcommon.GetIgnitionFileDataByPath(&ignCfg, "/var/lib/kubelet/config.json")
+			require.NoError(t, err)
+			assert.Contains(t, string(pullSecret), "auths")
 		})
 	}
 }
@@ -13,6 +13,12 @@ mkdir -p "$build_context/machineconfig"
 # Copy the Dockerfile and Machineconfigs from configmaps into our build context.
 cp /tmp/dockerfile/Dockerfile "$build_context"
 cp /tmp/machineconfig/machineconfig.json.gz "$build_context/machineconfig/"
+cp /tmp/machineconfig/provenance.json "$build_context/machineconfig/"
+
+# Copy the user-supplied build inputs, if any, into our build context.
+if [ -d /tmp/build-inputs ]; then
+	cp -RL /tmp/build-inputs "$build_context/"
+fi
 
 # Build our image using Buildah.
 buildah bud \
@@ -21,9 +27,24 @@ buildah bud \
 	--tag "$TAG" \
 	--file="$build_context/Dockerfile" "$build_context"
 
+# Sign our image while pushing it if a signing key was provided. The
+# signature is stored in the registry alongside the image as a sigstore
+# attachment.
+push_args=()
+if [ -n "${SIGNING_KEY:-}" ]; then
+	mkdir -p "$HOME/.config/containers/registries.d"
+	printf 'default-docker:\n  use-sigstore-attachments: true\n' > "$HOME/.config/containers/registries.d/sigstore.yaml"
+	push_args+=(--sign-by-sigstore-private-key "$SIGNING_KEY")
+
+	if [ -n "${SIGNING_PASSPHRASE:-}" ]; then
+		push_args+=(--sign-passphrase-file "$SIGNING_PASSPHRASE")
+	fi
+fi
+
 # Push our built image.
 buildah push \
 	--storage-driver vfs \
 	--authfile="$FINAL_IMAGE_PUSH_CREDS" \
 	--digestfile="/tmp/done/digestfile" \
+	"${push_args[@]}" \
 	--cert-dir /var/run/secrets/kubernetes.io/serviceaccount "$TAG"
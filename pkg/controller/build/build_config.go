@@ -0,0 +1,395 @@
+package build
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	mcfgv1 "github.com/openshift/machine-config-operator/pkg/apis/machineconfiguration.openshift.io/v1"
+	ctrlcommon "github.com/openshift/machine-config-operator/pkg/controller/common"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// Label which scopes a ConfigMap in the MCO namespace to a single
+	// MachineConfigPool. Its value is the name of the pool. The keys of this
+	// ConfigMap take precedence over those of the on-cluster-build-config
+	// ConfigMap.
+	onClusterBuildConfigPoolLabel = "machineconfiguration.openshift.io/on-cluster-build-config-pool"
+
+	// The BuildFailed condition reason used when the on-cluster build
+	// configuration of a pool is invalid.
+	buildConfigInvalidReason = "InvalidBuildConfig"
+)
+
+// Describes the resources, placement and timeout of the pod which performs
+// the build.
+type BuildPodConfig struct {
+	Resources    corev1.ResourceRequirements
+	NodeSelector map[string]string
+	Tolerations  []corev1.Toleration
+	// How long the build may take before it is failed; zero means no timeout.
+	Timeout time.Duration
+}
+
+// Populates the build pod config from the on-cluster-build-config ConfigMap.
+func newBuildPodConfig(onClusterBuildConfigMap *corev1.ConfigMap) (BuildPodConfig, error) {
+	podConfig := BuildPodConfig{}
+
+	requests, err := parseResourceList(onClusterBuildConfigMap.Data[buildResourceRequestsConfigKey])
+	if err != nil {
+		return podConfig, fmt.Errorf("invalid %s: %w", buildResourceRequestsConfigKey, err)
+	}
+
+	if len(requests) != 0 {
+		podConfig.Resources.Requests = requests
+	}
+
+	limits, err := parseResourceList(onClusterBuildConfigMap.Data[buildResourceLimitsConfigKey])
+	if err != nil {
+		return podConfig, fmt.Errorf("invalid %s: %w", buildResourceLimitsConfigKey, err)
+	}
+
+	if len(limits) != 0 {
+		podConfig.Resources.Limits = limits
+	}
+
+	for name, request := range requests {
+		if limit, ok := limits[name]; ok && request.Cmp(limit) > 0 {
+			return podConfig, fmt.Errorf("%s request %s exceeds limit %s", name, request.String(), limit.String())
+		}
+	}
+
+	nodeSelector, err := parseNodeSelector(onClusterBuildConfigMap.Data[buildNodeSelectorConfigKey])
+	if err != nil {
+		return podConfig, fmt.Errorf("invalid %s: %w", buildNodeSelectorConfigKey, err)
+	}
+
+	if len(nodeSelector) != 0 {
+		podConfig.NodeSelector = nodeSelector
+	}
+
+	podConfig.Tolerations, err = parseTolerations(onClusterBuildConfigMap.Data[buildTolerationsConfigKey])
+	if err != nil {
+		return podConfig, fmt.Errorf("invalid %s: %w", buildTolerationsConfigKey, err)
+	}
+
+	if val, ok := onClusterBuildConfigMap.Data[buildTimeoutConfigKey]; ok {
+		timeout, err := time.ParseDuration(strings.TrimSpace(val))
+		if err != nil {
+			return podConfig, fmt.Errorf("invalid %s %q: %w", buildTimeoutConfigKey, val, err)
+		}
+
+		// Kubernetes deadlines are in whole seconds.
+		if timeout < time.Second {
+			return podConfig, fmt.Errorf("invalid %s %q: must be at least 1s", buildTimeoutConfigKey, val)
+		}
+
+		podConfig.Timeout = timeout
+	}
+
+	return podConfig, nil
+}
+
+// Gets the timeout of the build in whole seconds, as used by Kubernetes
+// deadlines. Returns nil if there is no timeout.
+func (b BuildPodConfig) getDeadlineSeconds() *int64 {
+	if b.Timeout == 0 {
+		return nil
+	}
+
+	seconds := int64(b.Timeout.Seconds())
+	return &seconds
+}
+
+// Parses a comma-separated list of resource quantities (e.g.,
+// cpu=500m,memory=4Gi).
+func parseResourceList(value string) (corev1.ResourceList, error) {
+	resources := corev1.ResourceList{}
+
+	for _, item := range splitConfigList(value) {
+		name, quantity, ok := strings.Cut(item, "=")
+		if !ok {
+			return nil, fmt.Errorf("expected <resource>=<quantity>, got %q", item)
+		}
+
+		parsed, err := resource.ParseQuantity(strings.TrimSpace(quantity))
+		if err != nil {
+			return nil, fmt.Errorf("could not parse quantity for %s: %w", name, err)
+		}
+
+		resources[corev1.ResourceName(strings.TrimSpace(name))] = parsed
+	}
+
+	return resources, nil
+}
+
+// Parses a comma-separated list of node labels (e.g.,
+// node-role.kubernetes.io/infra=,kubernetes.io/arch=amd64).
+func parseNodeSelector(value string) (map[string]string, error) {
+	nodeSelector := map[string]string{}
+
+	for _, item := range splitConfigList(value) {
+		key, val, ok := strings.Cut(item, "=")
+		if !ok {
+			return nil, fmt.Errorf("expected <label>=<value>, got %q", item)
+		}
+
+		key = strings.TrimSpace(key)
+		val = strings.TrimSpace(val)
+
+		if errs := validation.IsQualifiedName(key); len(errs) != 0 {
+			return nil, fmt.Errorf("invalid label %q: %s", key, strings.Join(errs, "; "))
+		}
+
+		if errs := validation.IsValidLabelValue(val); len(errs) != 0 {
+			return nil, fmt.Errorf("invalid value %q for label %q: %s", val, key, strings.Join(errs, "; "))
+		}
+
+		nodeSelector[key] = val
+	}
+
+	return nodeSelector, nil
+}
+
+// Parses a comma-separated list of taints to tolerate, in the same format as
+// "oc adm taint" (e.g., key=value:NoSchedule). A taint without a value
+// tolerates any value and a taint without an effect tolerates any effect.
+func parseTolerations(value string) ([]corev1.Toleration, error) {
+	var tolerations []corev1.Toleration
+
+	for _, item := range splitConfigList(value) {
+		taint, effect, _ := strings.Cut(item, ":")
+		key, val, hasValue := strings.Cut(taint, "=")
+
+		toleration := corev1.Toleration{
+			Key:      strings.TrimSpace(key),
+			Operator: corev1.TolerationOpExists,
+			Effect:   corev1.TaintEffect(strings.TrimSpace(effect)),
+		}
+
+		if hasValue {
+			toleration.Operator = corev1.TolerationOpEqual
+			toleration.Value = strings.TrimSpace(val)
+		}
+
+		if errs := validation.IsQualifiedName(toleration.Key); len(errs) != 0 {
+			return nil, fmt.Errorf("invalid taint key %q: %s", toleration.Key, strings.Join(errs, "; "))
+		}
+
+		switch toleration.Effect {
+		case "", corev1.TaintEffectNoSchedule, corev1.TaintEffectPreferNoSchedule, corev1.TaintEffectNoExecute:
+		default:
+			return nil, fmt.Errorf("invalid taint effect %q for %q", toleration.Effect, toleration.Key)
+		}
+
+		tolerations = append(tolerations, toleration)
+	}
+
+	return tolerations, nil
+}
+
+// Ensures that the build pod config is valid and supported by the selected
+// image builder.
+func (ctrl *Controller) validateBuildPodConfig(onClusterBuildConfigMap *corev1.ConfigMap) error {
+	podConfig, err := newBuildPodConfig(onClusterBuildConfigMap)
+	if err != nil {
+		return err
+	}
+
+	builderType := ImageBuilderType(onClusterBuildConfigMap.Data[imageBuilderTypeConfigKey])
+	if builderType == "" {
+		builderType = ctrl.imageBuilder.defaultType
+	}
+
+	// The OpenShift Build API does not allow the build pod to tolerate taints.
+	if builderType == OpenShiftImageBuilder && len(podConfig.Tolerations) != 0 {
+		return fmt.Errorf("%s is not supported by the %s image builder", buildTolerationsConfigKey, builderType)
+	}
+
+	return nil
+}
+
+// Holds the ConfigMaps which make up the on-cluster build configuration of a
+// given MachineConfigPool. Either one may be nil, but not both.
+type buildConfigSources struct {
+	global *corev1.ConfigMap
+	pool   *corev1.ConfigMap
+}
+
+// Merges the pool-scoped ConfigMap into the on-cluster-build-config
+// ConfigMap. The result is named after the pool-scoped ConfigMap, if any.
+func (s buildConfigSources) merged() *corev1.ConfigMap {
+	var merged *corev1.ConfigMap
+	if s.pool != nil {
+		merged = s.pool.DeepCopy()
+	} else {
+		merged = s.global.DeepCopy()
+	}
+
+	merged.Data = map[string]string{}
+
+	if s.global != nil {
+		for key, val := range s.global.Data {
+			merged.Data[key] = val
+		}
+	}
+
+	if s.pool != nil {
+		for key, val := range s.pool.Data {
+			merged.Data[key] = val
+		}
+	}
+
+	return merged
+}
+
+// Gets the ConfigMap which supplies the given key.
+func (s buildConfigSources) sourceOf(key string) *corev1.ConfigMap {
+	if s.pool != nil {
+		if _, ok := s.pool.Data[key]; ok {
+			return s.pool
+		}
+	}
+
+	return s.global
+}
+
+// Gets the on-cluster-build-config ConfigMap and the ConfigMap scoped to the
+// given MachineConfigPool. The on-cluster-build-config ConfigMap is optional
+// when a pool-scoped ConfigMap exists.
+func (ctrl *Controller) getBuildConfigSources(pool *mcfgv1.MachineConfigPool) (buildConfigSources, error) {
+	sources := buildConfigSources{}
+
+	poolConfigMaps, err := ctrl.cmLister.ConfigMaps(ctrlcommon.MCONamespace).List(labels.SelectorFromSet(labels.Set{onClusterBuildConfigPoolLabel: pool.Name}))
+	if err != nil {
+		return sources, fmt.Errorf("could not list build controller configs for pool %q: %w", pool.Name, err)
+	}
+
+	switch len(poolConfigMaps) {
+	case 0:
+	case 1:
+		// Copy the ConfigMaps from the lister since the canonicalized secret
+		// names are written back to them.
+		sources.pool = poolConfigMaps[0].DeepCopy()
+	default:
+		names := []string{}
+		for _, cm := range poolConfigMaps {
+			names = append(names, cm.Name)
+		}
+		sort.Strings(names)
+		return sources, fmt.Errorf("expected at most one build controller config for pool %q, found: %s", pool.Name, strings.Join(names, ", "))
+	}
+
+	global, err := ctrl.cmLister.ConfigMaps(ctrlcommon.MCONamespace).Get(onClusterBuildConfigMapName)
+	if err == nil {
+		sources.global = global.DeepCopy()
+	} else if !k8serrors.IsNotFound(err) || sources.pool == nil {
+		return sources, fmt.Errorf("could not get build controller config %q: %w", onClusterBuildConfigMapName, err)
+	}
+
+	return sources, nil
+}
+
+// Gets the on-cluster build configuration for the given MachineConfigPool
+// without validating it.
+func (ctrl *Controller) getBuildConfigForPool(pool *mcfgv1.MachineConfigPool) (*corev1.ConfigMap, error) {
+	sources, err := ctrl.getBuildConfigSources(pool)
+	if err != nil {
+		return nil, err
+	}
+
+	return sources.merged(), nil
+}
+
+// Determines if a MachineConfigPool cannot build because its on-cluster build
+// configuration is invalid.
+func isBuildConfigInvalid(pool *mcfgv1.MachineConfigPool) bool {
+	condition := mcfgv1.GetMachineConfigPoolCondition(pool.Status, mcfgv1.MachineConfigPoolBuildFailed)
+	return condition != nil && condition.Status == corev1.ConditionTrue && condition.Reason == buildConfigInvalidReason
+}
+
+// Marks a given MachineConfigPool as unable to build because its on-cluster
+// build configuration is invalid. The pool is not degraded; the build starts
+// once the configuration has been fixed. Returns the validation error so that
+// the pool is requeued.
+func (ctrl *Controller) markBuildConfigInvalid(pool *mcfgv1.MachineConfigPool, validationErr error) error {
+	klog.Errorf("Invalid build config for pool %s: %s", pool.Name, validationErr)
+
+	if !isBuildConfigInvalid(pool) {
+		ctrl.eventRecorder.Eventf(pool, corev1.EventTypeWarning, buildConfigInvalidReason, "Invalid build config: %s", validationErr)
+	}
+
+	setMCPBuildConditions(pool, []mcfgv1.MachineConfigPoolCondition{
+		{
+			Type:    mcfgv1.MachineConfigPoolBuildFailed,
+			Reason:  buildConfigInvalidReason,
+			Message: validationErr.Error(),
+			Status:  corev1.ConditionTrue,
+		},
+		{
+			Type:   mcfgv1.MachineConfigPoolBuildSuccess,
+			Status: corev1.ConditionFalse,
+		},
+		{
+			Type:   mcfgv1.MachineConfigPoolBuilding,
+			Status: corev1.ConditionFalse,
+		},
+		{
+			Type:   mcfgv1.MachineConfigPoolBuildPending,
+			Status: corev1.ConditionFalse,
+		},
+	})
+
+	if err := ctrl.syncAvailableStatus(pool); err != nil {
+		return err
+	}
+
+	return validationErr
+}
+
+// Fires whenever a ConfigMap in the MCO namespace changes. If it is part of
+// the on-cluster build configuration, the pools waiting for a valid
+// configuration are requeued.
+func (ctrl *Controller) handleBuildConfigMapEvent(obj interface{}) {
+	cm, ok := obj.(*corev1.ConfigMap)
+	if !ok {
+		tombstone, ok := obj.(cache.DeletedFinalStateUnknown)
+		if !ok {
+			return
+		}
+		cm, ok = tombstone.Obj.(*corev1.ConfigMap)
+		if !ok {
+			return
+		}
+	}
+
+	poolName, isPoolScoped := cm.Labels[onClusterBuildConfigPoolLabel]
+	if cm.Name != onClusterBuildConfigMapName && !isPoolScoped {
+		return
+	}
+
+	pools, err := ctrl.mcpLister.List(labels.Everything())
+	if err != nil {
+		klog.Errorf("Could not list MachineConfigPools: %s", err)
+		return
+	}
+
+	for _, pool := range pools {
+		if isPoolScoped && pool.Name != poolName {
+			continue
+		}
+
+		if ctrlcommon.IsLayeredPool(pool) && isBuildConfigInvalid(pool) {
+			klog.V(4).Infof("Build config %s changed, requeueing MachineConfigPool %s", cm.Name, pool.Name)
+			ctrl.enqueueMachineConfigPool(pool)
+		}
+	}
+}
@@ -0,0 +1,364 @@
+package build
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	mcfgv1 "github.com/openshift/machine-config-operator/pkg/apis/machineconfiguration.openshift.io/v1"
+	ctrlcommon "github.com/openshift/machine-config-operator/pkg/controller/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Gets an example ConfigMap scoped to the given pool.
+func getPoolBuildConfigMap(poolName string, data map[string]string) *corev1.ConfigMap {
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "on-cluster-build-config-" + poolName,
+			Namespace: ctrlcommon.MCONamespace,
+			Labels: map[string]string{
+				onClusterBuildConfigPoolLabel: poolName,
+			},
+		},
+		Data: data,
+	}
+}
+
+// Tests that the build pod resources and placement are parsed.
+func TestNewBuildPodConfig(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name        string
+		data        map[string]string
+		expected    BuildPodConfig
+		errExpected bool
+	}{
+		{
+			name: "Unset",
+		},
+		{
+			name: "Resource requests and node selector",
+			data: map[string]string{
+				buildResourceRequestsConfigKey: "cpu=500m, memory=4Gi",
+				buildNodeSelectorConfigKey:     "node-role.kubernetes.io/infra=,kubernetes.io/arch=amd64",
+			},
+			expected: BuildPodConfig{
+				Resources: corev1.ResourceRequirements{
+					Requests: corev1.ResourceList{
+						corev1.ResourceCPU:    resource.MustParse("500m"),
+						corev1.ResourceMemory: resource.MustParse("4Gi"),
+					},
+				},
+				NodeSelector: map[string]string{
+					"node-role.kubernetes.io/infra": "",
+					"kubernetes.io/arch":            "amd64",
+				},
+			},
+		},
+		{
+			name: "Resource limits, tolerations and timeout",
+			data: map[string]string{
+				buildResourceRequestsConfigKey: "memory=4Gi",
+				buildResourceLimitsConfigKey:   "memory=8Gi",
+				buildTolerationsConfigKey:      "node-role.kubernetes.io/infra:NoSchedule,dedicated=builds",
+				buildTimeoutConfigKey:          "2h",
+			},
+			expected: BuildPodConfig{
+				Resources: corev1.ResourceRequirements{
+					Requests: corev1.ResourceList{
+						corev1.ResourceMemory: resource.MustParse("4Gi"),
+					},
+					Limits: corev1.ResourceList{
+						corev1.ResourceMemory: resource.MustParse("8Gi"),
+					},
+				},
+				Tolerations: []corev1.Toleration{
+					{
+						Key:      "node-role.kubernetes.io/infra",
+						Operator: corev1.TolerationOpExists,
+						Effect:   corev1.TaintEffectNoSchedule,
+					},
+					{
+						Key:      "dedicated",
+						Operator: corev1.TolerationOpEqual,
+						Value:    "builds",
+					},
+				},
+				Timeout: 2 * time.Hour,
+			},
+		},
+		{
+			name: "Request exceeds limit",
+			data: map[string]string{
+				buildResourceRequestsConfigKey: "memory=8Gi",
+				buildResourceLimitsConfigKey:   "memory=4Gi",
+			},
+			errExpected: true,
+		},
+		{
+			name:        "Invalid taint effect",
+			data:        map[string]string{buildTolerationsConfigKey: "dedicated=builds:Sometimes"},
+			errExpected: true,
+		},
+		{
+			name:        "Invalid timeout",
+			data:        map[string]string{buildTimeoutConfigKey: "forever"},
+			errExpected: true,
+		},
+		{
+			name:        "Timeout too short",
+			data:        map[string]string{buildTimeoutConfigKey: "500ms"},
+			errExpected: true,
+		},
+		{
+			name:        "Malformed resource request",
+			data:        map[string]string{buildResourceRequestsConfigKey: "cpu"},
+			errExpected: true,
+		},
+		{
+			name:        "Invalid quantity",
+			data:        map[string]string{buildResourceRequestsConfigKey: "memory=lots"},
+			errExpected: true,
+		},
+		{
+			name:        "Invalid node label",
+			data:        map[string]string{buildNodeSelectorConfigKey: "not a label=true"},
+			errExpected: true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			podConfig, err := newBuildPodConfig(&corev1.ConfigMap{Data: testCase.data})
+			if testCase.errExpected {
+				assert.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, testCase.expected, podConfig)
+		})
+	}
+}
+
+// Tests that the build pod config is applied to each kind of build object.
+func TestImageBuildRequestWithBuildPodConfig(t *testing.T) {
+	t.Parallel()
+
+	onClusterBuildConfigMap := getOnClusterBuildConfigMap()
+	onClusterBuildConfigMap.Data[buildResourceRequestsConfigKey] = "cpu=1,memory=8Gi"
+	onClusterBuildConfigMap.Data[buildNodeSelectorConfigKey] = "node-role.kubernetes.io/infra="
+	onClusterBuildConfigMap.Data[buildTolerationsConfigKey] = "node-role.kubernetes.io/infra:NoSchedule"
+	onClusterBuildConfigMap.Data[buildTimeoutConfigKey] = "90m"
+
+	podConfig, err := newBuildPodConfig(onClusterBuildConfigMap)
+	require.NoError(t, err)
+
+	ibr := newImageBuildRequestWithConfigMap(newMachineConfigPool("worker", "rendered-worker-1"), getOSImageURLConfigMap(), onClusterBuildConfigMap)
+	ibr.BuildPod = podConfig
+
+	for name, podSpec := range map[string]corev1.PodSpec{
+		"Pod": ibr.toBuildPod().Spec,
+		"Job": ibr.toBuildJob().Spec.Template.Spec,
+	} {
+		assert.Equal(t, podConfig.NodeSelector, podSpec.NodeSelector, name)
+		assert.Equal(t, podConfig.Resources, podSpec.Containers[0].Resources, name)
+		assert.Equal(t, podConfig.Tolerations, podSpec.Tolerations, name)
+	}
+
+	pod := ibr.toBuildPod()
+	assert.Equal(t, int64(5400), *pod.Spec.ActiveDeadlineSeconds)
+	assert.Equal(t, "1h30m0s", pod.Annotations[buildTimeoutAnnotationKey])
+
+	job := ibr.toBuildJob()
+	assert.Equal(t, int64(5400), *job.Spec.ActiveDeadlineSeconds)
+
+	build := ibr.toBuild()
+	assert.Equal(t, podConfig.Resources, build.Spec.Resources)
+	assert.Equal(t, podConfig.NodeSelector, map[string]string(build.Spec.NodeSelector))
+	assert.Equal(t, int64(5400), *build.Spec.CompletionDeadlineSeconds)
+}
+
+// Tests that tolerations are rejected for the OpenShift image builder, which
+// cannot set them on its build pods.
+func TestValidateBuildPodConfigTolerations(t *testing.T) {
+	t.Parallel()
+
+	b := &buildControllerTestFixture{}
+
+	cm := getOnClusterBuildConfigMap()
+	cm.Data[buildTolerationsConfigKey] = "node-role.kubernetes.io/infra:NoSchedule"
+
+	assert.NoError(t, NewWithCustomPodBuilder(b.getConfig(), b.setupClients()).validateBuildPodConfig(cm))
+	assert.Error(t, NewWithImageBuilder(b.getConfig(), b.setupClients()).validateBuildPodConfig(cm))
+}
+
+// Tests that the pool-scoped ConfigMap takes precedence over the
+// on-cluster-build-config ConfigMap.
+func TestGetOnClusterBuildConfigForPool(t *testing.T) {
+	t.Parallel()
+
+	b := &buildControllerTestFixture{}
+
+	testCases := []struct {
+		name             string
+		global           bool
+		poolConfigMaps   []*corev1.ConfigMap
+		expectedPullspec string
+		expectedName     string
+		errExpected      bool
+	}{
+		{
+			name:             "Global only",
+			global:           true,
+			expectedPullspec: "registry.hostname.com/org/repo:rendered-worker-1",
+			expectedName:     onClusterBuildConfigMapName,
+		},
+		{
+			name:   "Pool-scoped overrides global",
+			global: true,
+			poolConfigMaps: []*corev1.ConfigMap{
+				getPoolBuildConfigMap("worker", map[string]string{
+					finalImagePullspecConfigKey: "registry.hostname.com/org/worker-repo:latest",
+				}),
+			},
+			expectedPullspec: "registry.hostname.com/org/worker-repo:rendered-worker-1",
+			expectedName:     "on-cluster-build-config-worker",
+		},
+		{
+			name: "Pool-scoped only",
+			poolConfigMaps: []*corev1.ConfigMap{
+				getPoolBuildConfigMap("worker", getOnClusterBuildConfigMap().Data),
+			},
+			expectedPullspec: "registry.hostname.com/org/repo:rendered-worker-1",
+			expectedName:     "on-cluster-build-config-worker",
+		},
+		{
+			name:   "Other pool ignored",
+			global: true,
+			poolConfigMaps: []*corev1.ConfigMap{
+				getPoolBuildConfigMap("infra", map[string]string{
+					finalImagePullspecConfigKey: "registry.hostname.com/org/infra-repo:latest",
+				}),
+			},
+			expectedPullspec: "registry.hostname.com/org/repo:rendered-worker-1",
+			expectedName:     onClusterBuildConfigMapName,
+		},
+		{
+			name: "Pool-scoped missing required key",
+			poolConfigMaps: []*corev1.ConfigMap{
+				getPoolBuildConfigMap("worker", map[string]string{
+					finalImagePullspecConfigKey: "registry.hostname.com/org/worker-repo:latest",
+				}),
+			},
+			errExpected: true,
+		},
+		{
+			name:   "Multiple pool-scoped",
+			global: true,
+			poolConfigMaps: []*corev1.ConfigMap{
+				getPoolBuildConfigMap("worker", map[string]string{}),
+				func() *corev1.ConfigMap {
+					cm := getPoolBuildConfigMap("worker", map[string]string{})
+					cm.Name = "another-worker-config"
+					return cm
+				}(),
+			},
+			errExpected: true,
+		},
+		{
+			name:   "Invalid build pod config",
+			global: true,
+			poolConfigMaps: []*corev1.ConfigMap{
+				getPoolBuildConfigMap("worker", map[string]string{
+					buildResourceRequestsConfigKey: "cpu=lots",
+				}),
+			},
+			errExpected: true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			cs := b.setupClients()
+			ctrl := NewWithCustomPodBuilder(b.getConfig(), cs)
+
+			if !testCase.global {
+				require.NoError(t, cs.kubeclient.CoreV1().ConfigMaps(ctrlcommon.MCONamespace).Delete(context.TODO(), onClusterBuildConfigMapName, metav1.DeleteOptions{}))
+			}
+
+			for _, cm := range testCase.poolConfigMaps {
+				_, err := cs.kubeclient.CoreV1().ConfigMaps(ctrlcommon.MCONamespace).Create(context.TODO(), cm, metav1.CreateOptions{})
+				require.NoError(t, err)
+			}
+
+			// The informers are not started, so fill the ConfigMap lister from the client.
+			configMaps, err := cs.kubeclient.CoreV1().ConfigMaps(ctrlcommon.MCONamespace).List(context.TODO(), metav1.ListOptions{})
+			require.NoError(t, err)
+			for i := range configMaps.Items {
+				require.NoError(t, ctrl.cmInformer.Informer().GetIndexer().Add(&configMaps.Items[i]))
+			}
+
+			onClusterBuildConfigMap, err := ctrl.getOnClusterBuildConfig(newMachineConfigPool("worker", "rendered-worker-1"))
+			if testCase.errExpected {
+				assert.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, testCase.expectedName, onClusterBuildConfigMap.Name)
+			assert.Equal(t, testCase.expectedPullspec, onClusterBuildConfigMap.Data[finalImagePullspecConfigKey])
+		})
+	}
+}
+
+// Tests that an invalid build config is surfaced as a pool condition without
+// degrading the pool, and that the build starts once it has been fixed.
+func TestBuildControllerWithInvalidPoolBuildConfig(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	t.Cleanup(cancel)
+
+	cs, fake := startBuildControllerWithFakeImageBuilder(ctx, t)
+
+	poolConfigMap, err := cs.kubeclient.CoreV1().ConfigMaps(ctrlcommon.MCONamespace).Create(ctx, getPoolBuildConfigMap("worker", map[string]string{
+		finalImagePullspecConfigKey: "registry.hostname.com/org/worker-repo:latest",
+		buildNodeSelectorConfigKey:  "node-role.kubernetes.io/infra",
+	}), metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	mcp := optInMCP(ctx, t, cs, "worker")
+
+	assertMachineConfigPoolReachesState(ctx, t, cs, "worker", func(mcp *mcfgv1.MachineConfigPool) bool {
+		return isBuildConfigInvalid(mcp) && !isPoolDegraded(mcp)
+	})
+
+	// Fix the pool-scoped ConfigMap.
+	poolConfigMap.Data[buildNodeSelectorConfigKey] = "node-role.kubernetes.io/infra="
+	_, err = cs.kubeclient.CoreV1().ConfigMaps(ctrlcommon.MCONamespace).Update(ctx, poolConfigMap, metav1.UpdateOptions{})
+	require.NoError(t, err)
+
+	name := newImageBuildRequest(mcp).getBuildName()
+	fake.waitForBuild(ctx, t, name)
+
+	assertMachineConfigPoolReachesState(ctx, t, cs, "worker", func(mcp *mcfgv1.MachineConfigPool) bool {
+		return mcfgv1.IsMachineConfigPoolConditionTrue(mcp.Status.Conditions, mcfgv1.MachineConfigPoolBuildPending) && !isBuildConfigInvalid(mcp)
+	})
+
+	ibr, ok := fake.getRequest(name)
+	require.True(t, ok)
+	assert.Equal(t, "registry.hostname.com/org/worker-repo:rendered-worker-1", ibr.FinalImage.Pullspec)
+	assert.Equal(t, map[string]string{"node-role.kubernetes.io/infra": ""}, ibr.BuildPod.NodeSelector)
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"strconv"
 	"strings"
 	"time"
 
@@ -11,6 +12,7 @@ import (
 	buildv1 "github.com/openshift/api/build/v1"
 	mcfgv1 "github.com/openshift/machine-config-operator/pkg/apis/machineconfiguration.openshift.io/v1"
 	"github.com/openshift/machine-config-operator/pkg/generated/clientset/versioned/scheme"
+	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
 	aggerrors "k8s.io/apimachinery/pkg/util/errors"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
@@ -35,7 +37,9 @@ import (
 	mcfglistersv1 "github.com/openshift/machine-config-operator/pkg/generated/listers/machineconfiguration.openshift.io/v1"
 
 	coreinformers "k8s.io/client-go/informers"
+	batchinformersv1 "k8s.io/client-go/informers/batch/v1"
 	coreinformersv1 "k8s.io/client-go/informers/core/v1"
+	corelisterv1 "k8s.io/client-go/listers/core/v1"
 
 	ctrlcommon "github.com/openshift/machine-config-operator/pkg/controller/common"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -64,6 +68,45 @@ const (
 
 	// The on-cluster-build-config ConfigMap key which contains the pullspec of where to push the final OS image (e.g., registry.hostname.com/org/repo:tag).
 	finalImagePullspecConfigKey = "finalImagePullspec"
+
+	// The optional on-cluster-build-config ConfigMap key which contains Containerfile content appended to the final stage of the build.
+	containerfileConfigKey = "containerfile"
+
+	// The optional on-cluster-build-config ConfigMap key which contains a comma-separated list of ConfigMaps to add to the build context.
+	buildInputConfigMapsConfigKey = "buildInputConfigMaps"
+
+	// The optional on-cluster-build-config ConfigMap key which contains a comma-separated list of Secrets to add to the build context.
+	buildInputSecretsConfigKey = "buildInputSecrets"
+
+	// The optional on-cluster-build-config ConfigMap key which contains the number of times a failed build is automatically retried.
+	buildRetriesConfigKey = "buildRetries"
+
+	// The optional on-cluster-build-config ConfigMap key which contains the initial delay before retrying a failed build (e.g., 1m). The delay doubles with each attempt.
+	buildRetryBackoffConfigKey = "buildRetryBackoff"
+
+	// The optional on-cluster-build-config ConfigMap key which contains the number of build records kept per MachineConfigPool.
+	buildHistoryLimitConfigKey = "buildHistoryLimit"
+
+	// The optional on-cluster-build-config ConfigMap key which selects the image builder backend (e.g., job-builder).
+	imageBuilderTypeConfigKey = "imageBuilderType"
+
+	// The optional on-cluster-build-config ConfigMap key which contains a K8s secret holding the key pair used to sign the final OS image.
+	imageSigningKeySecretNameConfigKey = "imageSigningKeySecretName"
+
+	// The optional on-cluster-build-config ConfigMap key which contains a comma-separated list of resource requests for the build pod (e.g., cpu=500m,memory=4Gi).
+	buildResourceRequestsConfigKey = "buildResourceRequests"
+
+	// The optional on-cluster-build-config ConfigMap key which contains a comma-separated list of node labels that the build pod is scheduled onto (e.g., node-role.kubernetes.io/infra=).
+	buildNodeSelectorConfigKey = "buildNodeSelector"
+
+	// The optional on-cluster-build-config ConfigMap key which contains a comma-separated list of resource limits for the build pod (e.g., cpu=2,memory=16Gi).
+	buildResourceLimitsConfigKey = "buildResourceLimits"
+
+	// The optional on-cluster-build-config ConfigMap key which contains a comma-separated list of taints that the build pod tolerates (e.g., node-role.kubernetes.io/infra:NoSchedule).
+	buildTolerationsConfigKey = "buildTolerations"
+
+	// The optional on-cluster-build-config ConfigMap key which contains how long a build may take before it is failed (e.g., 2h).
+	buildTimeoutConfigKey = "buildTimeout"
 )
 
 // machine-config-osimageurl ConfigMap keys.
@@ -107,14 +150,6 @@ type BuildControllerConfig struct {
 	MaxRetries int
 }
 
-type ImageBuilder interface {
-	Run(context.Context, int)
-	StartBuild(ImageBuildRequest) (*corev1.ObjectReference, error)
-	IsBuildRunning(*mcfgv1.MachineConfigPool) (bool, error)
-	DeleteBuildObject(*mcfgv1.MachineConfigPool) error
-	FinalPullspec(*mcfgv1.MachineConfigPool) (string, error)
-}
-
 // Controller defines the build controller.
 type Controller struct {
 	*Clients
@@ -127,15 +162,19 @@ type Controller struct {
 
 	ccLister  mcfglistersv1.ControllerConfigLister
 	mcpLister mcfglistersv1.MachineConfigPoolLister
+	cmLister  corelisterv1.ConfigMapLister
 
 	ccListerSynced  cache.InformerSynced
 	mcpListerSynced cache.InformerSynced
 	podListerSynced cache.InformerSynced
+	cmListerSynced  cache.InformerSynced
 
 	queue workqueue.RateLimitingInterface
 
 	config       BuildControllerConfig
-	imageBuilder ImageBuilder
+	imageBuilder *imageBuilderSet
+
+	registryImageLookup registryImageLookupFunc
 }
 
 // Creates a BuildControllerConfig with sensible production defaults.
@@ -171,6 +210,8 @@ type informers struct {
 	mcpInformer   mcfginformersv1.MachineConfigPoolInformer
 	buildInformer buildinformersv1.BuildInformer
 	podInformer   coreinformersv1.PodInformer
+	jobInformer   batchinformersv1.JobInformer
+	cmInformer    coreinformersv1.ConfigMapInformer
 	toStart       []interface{ Start(<-chan struct{}) }
 }
 
@@ -193,6 +234,8 @@ func newInformers(bcc *Clients) *informers {
 		mcpInformer:   mcpInformer.Machineconfiguration().V1().MachineConfigPools(),
 		buildInformer: buildInformer.Build().V1().Builds(),
 		podInformer:   podInformer.Core().V1().Pods(),
+		jobInformer:   podInformer.Batch().V1().Jobs(),
+		cmInformer:    podInformer.Core().V1().ConfigMaps(),
 		toStart: []interface{ Start(<-chan struct{}) }{
 			ccInformer,
 			mcpInformer,
@@ -225,14 +268,23 @@ func newBuildController(
 		DeleteFunc: ctrl.deleteMachineConfigPool,
 	})
 
+	ctrl.cmInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    ctrl.handleBuildConfigMapEvent,
+		UpdateFunc: func(_, cur interface{}) { ctrl.handleBuildConfigMapEvent(cur) },
+		DeleteFunc: ctrl.handleBuildConfigMapEvent,
+	})
+
 	ctrl.syncHandler = ctrl.syncMachineConfigPool
 	ctrl.enqueueMachineConfigPool = ctrl.enqueueDefault
+	ctrl.registryImageLookup = inspectRegistryImage
 
 	ctrl.ccLister = ctrl.ccInformer.Lister()
 	ctrl.mcpLister = ctrl.mcpInformer.Lister()
+	ctrl.cmLister = ctrl.cmInformer.Lister()
 
 	ctrl.ccListerSynced = ctrl.ccInformer.Informer().HasSynced
 	ctrl.mcpListerSynced = ctrl.mcpInformer.Informer().HasSynced
+	ctrl.cmListerSynced = ctrl.cmInformer.Informer().HasSynced
 
 	return ctrl
 }
@@ -244,7 +296,9 @@ func NewWithCustomPodBuilder(
 	clients *Clients,
 ) *Controller {
 	ctrl := newBuildController(ctrlConfig, clients)
-	ctrl.imageBuilder = newPodBuildController(ctrlConfig, clients, ctrl.customBuildPodUpdater)
+	ctrl.imageBuilder = newImageBuilderSet(CustomPodImageBuilder, map[ImageBuilderType]ImageBuilder{
+		CustomPodImageBuilder: ctrl.newImageBuilder(CustomPodImageBuilder),
+	})
 	return ctrl
 }
 
@@ -255,10 +309,64 @@ func NewWithImageBuilder(
 	clients *Clients,
 ) *Controller {
 	ctrl := newBuildController(ctrlConfig, clients)
-	ctrl.imageBuilder = newImageBuildController(ctrlConfig, clients, ctrl.imageBuildUpdater)
+	ctrl.imageBuilder = newImageBuilderSet(OpenShiftImageBuilder, map[ImageBuilderType]ImageBuilder{
+		OpenShiftImageBuilder: ctrl.newImageBuilder(OpenShiftImageBuilder),
+	})
 	return ctrl
 }
 
+// Creates a Build Controller instance with a Kubernetes Job implementation
+// for the ImageBuilder.
+func NewWithJobBuilder(
+	ctrlConfig BuildControllerConfig,
+	clients *Clients,
+) *Controller {
+	ctrl := newBuildController(ctrlConfig, clients)
+	ctrl.imageBuilder = newImageBuilderSet(JobImageBuilder, map[ImageBuilderType]ImageBuilder{
+		JobImageBuilder: ctrl.newImageBuilder(JobImageBuilder),
+	})
+	return ctrl
+}
+
+// Creates a Build Controller instance with each of the given ImageBuilder
+// backends enabled. The default backend is used unless the
+// on-cluster-build-config ConfigMap selects another enabled backend.
+func New(
+	ctrlConfig BuildControllerConfig,
+	clients *Clients,
+	defaultType ImageBuilderType,
+	additionalTypes ...ImageBuilderType,
+) (*Controller, error) {
+	ctrl := newBuildController(ctrlConfig, clients)
+
+	builders := map[ImageBuilderType]ImageBuilder{}
+	for _, builderType := range append([]ImageBuilderType{defaultType}, additionalTypes...) {
+		if !isValidImageBuilderType(builderType) {
+			return nil, fmt.Errorf("unknown image builder %q", builderType)
+		}
+
+		builders[builderType] = ctrl.newImageBuilder(builderType)
+	}
+
+	ctrl.imageBuilder = newImageBuilderSet(defaultType, builders)
+	return ctrl, nil
+}
+
+// Creates the ImageBuilder backend for the given type, wired up to report
+// its builds back to this controller. Returns nil for an unknown type.
+func (ctrl *Controller) newImageBuilder(builderType ImageBuilderType) ImageBuilder {
+	switch builderType {
+	case OpenShiftImageBuilder:
+		return newImageBuildController(ctrl.config, ctrl.Clients, ctrl.imageBuildUpdater)
+	case CustomPodImageBuilder:
+		return newPodBuildController(ctrl.config, ctrl.Clients, ctrl.customBuildPodUpdater)
+	case JobImageBuilder:
+		return newJobBuildController(ctrl.config, ctrl.Clients, ctrl.buildJobUpdater)
+	default:
+		return nil
+	}
+}
+
 // Run executes the render controller.
 // TODO: Make this use a context instead of a stop channel.
 func (ctrl *Controller) Run(parentCtx context.Context, workers int) {
@@ -273,7 +381,7 @@ func (ctrl *Controller) Run(parentCtx context.Context, workers int) {
 
 	ctrl.informers.start(ctx)
 
-	if !cache.WaitForCacheSync(ctx.Done(), ctrl.mcpListerSynced, ctrl.ccListerSynced) {
+	if !cache.WaitForCacheSync(ctx.Done(), ctrl.mcpListerSynced, ctrl.ccListerSynced, ctrl.cmListerSynced) {
 		return
 	}
 
@@ -342,37 +450,37 @@ func (ctrl *Controller) processNextWorkItem() bool {
 	return true
 }
 
-// Reconciles the MachineConfigPool state with the state of an OpenShift Image
-// Builder object.
-func (ctrl *Controller) imageBuildUpdater(build *buildv1.Build) error {
-	pool, err := ctrl.mcfgclient.MachineconfigurationV1().MachineConfigPools().Get(context.TODO(), build.Labels[targetMachineConfigPoolLabel], metav1.GetOptions{})
+// Reconciles the MachineConfigPool state with the state of a build as
+// reported by any ImageBuilder backend.
+func (ctrl *Controller) buildStatusUpdater(status buildStatus) error {
+	pool, err := ctrl.mcfgclient.MachineconfigurationV1().MachineConfigPools().Get(context.TODO(), status.PoolName, metav1.GetOptions{})
 	if err != nil {
 		return err
 	}
 
-	klog.Infof("Build (%s) is %s", build.Name, build.Status.Phase)
-
-	objRef := toObjectRef(build)
-
-	switch build.Status.Phase {
-	case buildv1.BuildPhaseNew, buildv1.BuildPhasePending:
+	switch status.State {
+	case buildStatePending:
 		if !mcfgv1.IsMachineConfigPoolConditionTrue(pool.Status.Conditions, mcfgv1.MachineConfigPoolBuildPending) {
-			err = ctrl.markBuildPendingWithObjectRef(pool, *objRef)
+			err = ctrl.markBuildPendingWithObjectRef(pool, *status.ObjectRef)
 		}
-	case buildv1.BuildPhaseRunning:
+	case buildStateRunning:
 		// If we're running, then there's nothing to do right now.
 		if !mcfgv1.IsMachineConfigPoolConditionTrue(pool.Status.Conditions, mcfgv1.MachineConfigPoolBuilding) {
 			err = ctrl.markBuildInProgress(pool)
 		}
-	case buildv1.BuildPhaseComplete:
+	case buildStateSucceeded:
 		// If we've succeeded, we need to update the pool to indicate that.
 		if !mcfgv1.IsMachineConfigPoolConditionTrue(pool.Status.Conditions, mcfgv1.MachineConfigPoolBuildSuccess) {
 			err = ctrl.markBuildSucceeded(pool)
 		}
-	case buildv1.BuildPhaseFailed, buildv1.BuildPhaseError, buildv1.BuildPhaseCancelled:
-		// If we've failed, errored, or cancelled, we need to update the pool to indicate that.
+	case buildStateFailed:
+		// If we've failed, we need to update the pool to indicate that.
 		if !mcfgv1.IsMachineConfigPoolConditionTrue(pool.Status.Conditions, mcfgv1.MachineConfigPoolBuildFailed) {
-			err = ctrl.markBuildFailed(pool)
+			conditionReason := "BuildFailed"
+			if status.TimedOut {
+				conditionReason = buildTimedOutReason
+			}
+			err = ctrl.markBuildFailedWithReason(pool, conditionReason, status.FailureReason)
 		}
 	}
 
@@ -384,44 +492,83 @@ func (ctrl *Controller) imageBuildUpdater(build *buildv1.Build) error {
 	return nil
 }
 
-// Reconciles the MachineConfigPool state with the state of a custom pod object.
-func (ctrl *Controller) customBuildPodUpdater(pod *corev1.Pod) error {
-	pool, err := ctrl.mcfgclient.MachineconfigurationV1().MachineConfigPools().Get(context.TODO(), pod.Labels[targetMachineConfigPoolLabel], metav1.GetOptions{})
-	if err != nil {
-		return err
+// Reconciles the MachineConfigPool state with the state of an OpenShift Image
+// Builder object.
+func (ctrl *Controller) imageBuildUpdater(build *buildv1.Build) error {
+	klog.Infof("Build (%s) is %s", build.Name, build.Status.Phase)
+
+	status := buildStatus{
+		PoolName:  build.Labels[targetMachineConfigPoolLabel],
+		ObjectRef: toObjectRef(build),
+	}
+
+	switch build.Status.Phase {
+	case buildv1.BuildPhaseNew, buildv1.BuildPhasePending:
+		status.State = buildStatePending
+	case buildv1.BuildPhaseRunning:
+		status.State = buildStateRunning
+	case buildv1.BuildPhaseComplete:
+		status.State = buildStateSucceeded
+	case buildv1.BuildPhaseFailed, buildv1.BuildPhaseError, buildv1.BuildPhaseCancelled:
+		status.State = buildStateFailed
+		status.FailureReason = getBuildFailureReason(build)
+
+		if build.Status.Reason == deadlineExceededReason {
+			status.TimedOut = true
+			status.FailureReason = getBuildTimedOutReason(build)
+		}
 	}
 
+	return ctrl.buildStatusUpdater(status)
+}
+
+// Reconciles the MachineConfigPool state with the state of a custom pod object.
+func (ctrl *Controller) customBuildPodUpdater(pod *corev1.Pod) error {
 	klog.Infof("Build pod (%s) is %s", pod.Name, pod.Status.Phase)
 
+	status := buildStatus{
+		PoolName:  pod.Labels[targetMachineConfigPoolLabel],
+		ObjectRef: toObjectRef(pod),
+	}
+
 	switch pod.Status.Phase {
 	case corev1.PodPending:
-		if !mcfgv1.IsMachineConfigPoolConditionTrue(pool.Status.Conditions, mcfgv1.MachineConfigPoolBuildPending) {
-			objRef := toObjectRef(pod)
-			err = ctrl.markBuildPendingWithObjectRef(pool, *objRef)
-		}
+		status.State = buildStatePending
 	case corev1.PodRunning:
-		// If we're running, then there's nothing to do right now.
-		if !mcfgv1.IsMachineConfigPoolConditionTrue(pool.Status.Conditions, mcfgv1.MachineConfigPoolBuilding) {
-			err = ctrl.markBuildInProgress(pool)
-		}
+		status.State = buildStateRunning
 	case corev1.PodSucceeded:
-		// If we've succeeded, we need to update the pool to indicate that.
-		if !mcfgv1.IsMachineConfigPoolConditionTrue(pool.Status.Conditions, mcfgv1.MachineConfigPoolBuildSuccess) {
-			err = ctrl.markBuildSucceeded(pool)
-		}
+		status.State = buildStateSucceeded
 	case corev1.PodFailed:
-		// If we've failed, we need to update the pool to indicate that.
-		if !mcfgv1.IsMachineConfigPoolConditionTrue(pool.Status.Conditions, mcfgv1.MachineConfigPoolBuildFailed) {
-			err = ctrl.markBuildFailed(pool)
+		status.State = buildStateFailed
+		status.FailureReason = getBuildPodFailureReason(pod)
+
+		if pod.Status.Reason == deadlineExceededReason {
+			status.TimedOut = true
+			status.FailureReason = getBuildTimedOutReason(pod)
 		}
 	}
 
-	if err != nil {
-		return err
+	return ctrl.buildStatusUpdater(status)
+}
+
+// Reconciles the MachineConfigPool state with the state of a build Job.
+func (ctrl *Controller) buildJobUpdater(job *batchv1.Job) error {
+	state, failureReason := getBuildJobState(job)
+	timedOut := isBuildJobTimedOut(job)
+
+	if timedOut {
+		failureReason = getBuildTimedOutReason(job)
 	}
 
-	ctrl.enqueueMachineConfigPool(pool)
-	return nil
+	klog.Infof("Build job (%s) is %s", job.Name, state)
+
+	return ctrl.buildStatusUpdater(buildStatus{
+		PoolName:      job.Labels[targetMachineConfigPoolLabel],
+		State:         state,
+		ObjectRef:     toObjectRef(job),
+		FailureReason: failureReason,
+		TimedOut:      timedOut,
+	})
 }
 
 func (ctrl *Controller) handleErr(err error, key interface{}) {
@@ -497,6 +644,11 @@ func (ctrl *Controller) syncMachineConfigPool(key string) error {
 	case mcfgv1.IsMachineConfigPoolConditionTrue(pool.Status.Conditions, mcfgv1.MachineConfigPoolBuildSuccess):
 		klog.V(4).Infof("MachineConfigPool %s has successfully built", pool.Name)
 		return nil
+	case isBuildRetryPending(pool):
+		return ctrl.retryBuildForMachineConfigPool(pool)
+	case isBuildConfigInvalid(pool):
+		klog.V(4).Infof("MachineConfigPool %s has an invalid build config, revalidating", pool.Name)
+		return ctrl.startBuildForMachineConfigPool(pool)
 	default:
 		shouldBuild, err := shouldWeDoABuild(ctrl.imageBuilder, pool, pool)
 		if err != nil {
@@ -514,15 +666,49 @@ func (ctrl *Controller) syncMachineConfigPool(key string) error {
 	return ctrl.syncAvailableStatus(pool)
 }
 
-// Marks a given MachineConfigPool as a failed build.
-func (ctrl *Controller) markBuildFailed(pool *mcfgv1.MachineConfigPool) error {
-	klog.Errorf("Build failed for pool %s", pool.Name)
+// Marks a given MachineConfigPool as a failed build. If the build policy
+// allows for another attempt, the build is scheduled to be retried instead.
+func (ctrl *Controller) markBuildFailed(pool *mcfgv1.MachineConfigPool, reason string) error {
+	return ctrl.markBuildFailedWithReason(pool, "BuildFailed", reason)
+}
+
+// Marks a given MachineConfigPool as a failed build, using the given reason
+// for the BuildFailed condition once no retries remain.
+func (ctrl *Controller) markBuildFailedWithReason(pool *mcfgv1.MachineConfigPool, conditionReason, reason string) error {
+	klog.Errorf("Build failed for pool %s: %s", pool.Name, reason)
+
+	// Record the outcome before the build object and its logs are removed.
+	if err := ctrl.recordBuildFinished(pool, BuildResultFailed, "", reason); err != nil {
+		klog.Warningf("Could not record build failure for pool %s: %s", pool.Name, err)
+	}
+
+	policy, err := ctrl.getBuildPolicy(pool)
+	if err != nil {
+		klog.Warningf("Could not get build policy, will not retry build for pool %s: %s", pool.Name, err)
+	}
+
+	attempt := getBuildAttempt(pool)
+	if err == nil && attempt <= policy.Retries {
+		return ctrl.markBuildRetryPending(pool, attempt+1, policy.backoff(attempt+1), reason)
+	}
+
+	// We're out of retries, so there's no need to keep track of the attempts.
+	if _, ok := pool.Annotations[buildAttemptAnnotationKey]; ok {
+		clearBuildAttempt(pool)
+		updatedPool, err := ctrl.mcfgclient.MachineconfigurationV1().MachineConfigPools().Update(context.TODO(), pool, metav1.UpdateOptions{})
+		if err != nil {
+			return fmt.Errorf("could not update MachineConfigPool %q: %w", pool.Name, err)
+		}
+		updatedPool.Status = pool.Status
+		pool = updatedPool
+	}
 
 	setMCPBuildConditions(pool, []mcfgv1.MachineConfigPoolCondition{
 		{
-			Type:   mcfgv1.MachineConfigPoolBuildFailed,
-			Reason: "BuildFailed",
-			Status: corev1.ConditionTrue,
+			Type:    mcfgv1.MachineConfigPoolBuildFailed,
+			Reason:  conditionReason,
+			Message: reason,
+			Status:  corev1.ConditionTrue,
 		},
 		{
 			Type:   mcfgv1.MachineConfigPoolBuildSuccess,
@@ -545,6 +731,68 @@ func (ctrl *Controller) markBuildFailed(pool *mcfgv1.MachineConfigPool) error {
 	return ctrl.syncFailingStatus(pool, fmt.Errorf("build failed"))
 }
 
+// Removes the failed build and schedules the next build attempt for a given
+// MachineConfigPool after the backoff has elapsed.
+func (ctrl *Controller) markBuildRetryPending(pool *mcfgv1.MachineConfigPool, nextAttempt int, backoff time.Duration, reason string) error {
+	klog.Infof("Retrying build for pool %s in %s (attempt %d)", pool.Name, backoff, nextAttempt)
+
+	// Remove the failed build so that the next attempt can reuse its name.
+	if err := ctrl.postBuildCleanup(pool, true); err != nil {
+		return fmt.Errorf("could not clean up failed build: %w", err)
+	}
+
+	deleteBuildRefFromMachineConfigPool(pool)
+
+	if pool.Annotations == nil {
+		pool.Annotations = map[string]string{}
+	}
+	pool.Annotations[buildAttemptAnnotationKey] = strconv.Itoa(nextAttempt)
+	pool.Annotations[buildRetryAfterAnnotationKey] = time.Now().Add(backoff).UTC().Format(time.RFC3339)
+
+	ctrl.eventRecorder.Eventf(pool, corev1.EventTypeWarning, "BuildRetrying", "Build failed, retrying in %s (attempt %d): %s", backoff, nextAttempt, reason)
+
+	setMCPBuildConditions(pool, []mcfgv1.MachineConfigPoolCondition{
+		{
+			Type:    mcfgv1.MachineConfigPoolBuildFailed,
+			Reason:  buildRetryPendingReason,
+			Message: reason,
+			Status:  corev1.ConditionTrue,
+		},
+		{
+			Type:   mcfgv1.MachineConfigPoolBuildSuccess,
+			Status: corev1.ConditionFalse,
+		},
+		{
+			Type:   mcfgv1.MachineConfigPoolBuilding,
+			Status: corev1.ConditionFalse,
+		},
+		{
+			Type:   mcfgv1.MachineConfigPoolBuildPending,
+			Status: corev1.ConditionFalse,
+		},
+	})
+
+	if err := ctrl.updatePoolAndSyncStatus(pool, ctrl.syncAvailableStatus); err != nil {
+		return err
+	}
+
+	ctrl.enqueueAfter(pool, backoff)
+	return nil
+}
+
+// Starts the next build attempt for a MachineConfigPool once its retry
+// backoff has elapsed.
+func (ctrl *Controller) retryBuildForMachineConfigPool(pool *mcfgv1.MachineConfigPool) error {
+	if remaining := time.Until(getBuildRetryAfter(pool)); remaining > 0 {
+		klog.V(4).Infof("MachineConfigPool %s will retry build in %s", pool.Name, remaining)
+		ctrl.enqueueAfter(pool, remaining)
+		return nil
+	}
+
+	klog.Infof("Retrying build for MachineConfigPool %s (attempt %d)", pool.Name, getBuildAttempt(pool))
+	return ctrl.startBuildForMachineConfigPool(pool)
+}
+
 // Marks a given MachineConfigPool as the build is in progress.
 func (ctrl *Controller) markBuildInProgress(pool *mcfgv1.MachineConfigPool) error {
 	klog.Infof("Build in progress for MachineConfigPool %s, config %s", pool.Name, pool.Spec.Configuration.Name)
@@ -644,17 +892,39 @@ func (ctrl *Controller) markBuildSucceeded(pool *mcfgv1.MachineConfigPool) error
 		return fmt.Errorf("image pullspec empty for pool %s", pool.Name)
 	}
 
+	// Record the outcome before the cleanup removes the build logs.
+	if err := ctrl.recordBuildFinished(pool, BuildResultSucceeded, imagePullspec, ""); err != nil {
+		klog.Warningf("Could not record build success for pool %s: %s", pool.Name, err)
+	}
+
 	// Perform the post-build cleanup.
 	if err := ctrl.postBuildCleanup(pool, false); err != nil {
 		return fmt.Errorf("could not do post-build cleanup: %w", err)
 	}
 
+	return ctrl.setImagePullspecAndMarkBuildSucceeded(pool, imagePullspec, "BuildSucceeded")
+}
+
+// Marks a given MachineConfigPool as build successful without performing a
+// build because a matching image already exists in the registry.
+func (ctrl *Controller) markBuildReused(pool *mcfgv1.MachineConfigPool, imagePullspec string) error {
+	klog.Infof("Reusing existing image %s for MachineConfigPool %s, config %s", imagePullspec, pool.Name, pool.Spec.Configuration.Name)
+
+	ctrl.eventRecorder.Eventf(pool, corev1.EventTypeNormal, "BuildSkipped", "Reusing existing image %s for %s", imagePullspec, pool.Spec.Configuration.Name)
+
+	return ctrl.setImagePullspecAndMarkBuildSucceeded(pool, imagePullspec, "ImageReused")
+}
+
+// Points the MachineConfigPool at the given image pullspec and sets the build
+// conditions to indicate success.
+func (ctrl *Controller) setImagePullspecAndMarkBuildSucceeded(pool *mcfgv1.MachineConfigPool, imagePullspec, reason string) error {
 	// Set the annotation or field to point to the newly-built container image.
 	klog.V(4).Infof("Setting new image pullspec for %s to %s", pool.Name, imagePullspec)
 	if pool.Annotations == nil {
 		pool.Annotations = map[string]string{}
 	}
 	pool.Annotations[ctrlcommon.ExperimentalNewestLayeredImageEquivalentConfigAnnotationKey] = imagePullspec
+	clearBuildAttempt(pool)
 
 	// Remove the build object reference from the MachineConfigPool since we're
 	// not using it anymore.
@@ -668,7 +938,7 @@ func (ctrl *Controller) markBuildSucceeded(pool *mcfgv1.MachineConfigPool) error
 		},
 		{
 			Type:   mcfgv1.MachineConfigPoolBuildSuccess,
-			Reason: "BuildSucceeded",
+			Reason: reason,
 			Status: corev1.ConditionTrue,
 		},
 		{
@@ -813,34 +1083,54 @@ func (ctrl *Controller) startBuildForMachineConfigPool(pool *mcfgv1.MachineConfi
 
 	onClusterBuildConfigMap, err := ctrl.getOnClusterBuildConfig(pool)
 	if err != nil {
-		return fmt.Errorf("could not get configmap %q: %w", onClusterBuildConfigMapName, err)
+		return ctrl.markBuildConfigInvalid(pool, err)
 	}
 
 	ibr := newImageBuildRequestWithConfigMap(pool, osImageURLConfigMap, onClusterBuildConfigMap)
 
+	ibr.BuildInputs.Hash, err = ctrl.hashBuildInputs(ibr.BuildInputs)
+	if err != nil {
+		return err
+	}
+
+	ibr.SigningKey, err = ctrl.getImageSigningKey(onClusterBuildConfigMap)
+	if err != nil {
+		return ctrl.markBuildConfigInvalid(pool, err)
+	}
+
+	// This was validated by getOnClusterBuildConfig.
+	ibr.BuildPod, err = newBuildPodConfig(onClusterBuildConfigMap)
+	if err != nil {
+		return err
+	}
+
 	return ctrl.handleImageBuildRequest(ibr)
 }
 
 // Gets the ConfigMap which specifies the name of the base image pull secret, final image pull secret, and final image pullspec.
+// The keys of the ConfigMap scoped to the given pool, if any, take precedence over those of the on-cluster-build-config ConfigMap.
 func (ctrl *Controller) getOnClusterBuildConfig(pool *mcfgv1.MachineConfigPool) (*corev1.ConfigMap, error) {
-	onClusterBuildConfigMap, err := ctrl.kubeclient.CoreV1().ConfigMaps(ctrlcommon.MCONamespace).Get(context.TODO(), onClusterBuildConfigMapName, metav1.GetOptions{})
+	sources, err := ctrl.getBuildConfigSources(pool)
 	if err != nil {
-		return nil, fmt.Errorf("could not get build controller config %q: %w", onClusterBuildConfigMapName, err)
+		return nil, err
 	}
 
+	onClusterBuildConfigMap := sources.merged()
+
 	requiredKeys := []string{
 		baseImagePullSecretNameConfigKey,
 		finalImagePushSecretNameConfigKey,
 		finalImagePullspecConfigKey,
 	}
 
-	needToUpdateConfigMap := false
+	// The ConfigMaps which supplied a canonicalized secret.
+	configMapsToUpdate := map[string]*corev1.ConfigMap{}
 	finalImagePullspecWithTag := ""
 
 	for _, key := range requiredKeys {
 		val, ok := onClusterBuildConfigMap.Data[key]
 		if !ok {
-			return nil, fmt.Errorf("missing required key %q in configmap %s", key, onClusterBuildConfigMapName)
+			return nil, fmt.Errorf("missing required key %q in configmap %s", key, onClusterBuildConfigMap.Name)
 		}
 
 		if key == baseImagePullSecretNameConfigKey || key == finalImagePushSecretNameConfigKey {
@@ -850,17 +1140,19 @@ func (ctrl *Controller) getOnClusterBuildConfig(pool *mcfgv1.MachineConfigPool)
 			}
 
 			if strings.Contains(secret.Name, "canonical") {
-				klog.Infof("Updating build controller config %s to indicate we have a canonicalized secret %s", onClusterBuildConfigMapName, secret.Name)
+				source := sources.sourceOf(key)
+				klog.Infof("Updating build controller config %s to indicate we have a canonicalized secret %s", source.Name, secret.Name)
 				onClusterBuildConfigMap.Data[key] = secret.Name
-				needToUpdateConfigMap = true
+				source.Data[key] = secret.Name
+				configMapsToUpdate[source.Name] = source
 			}
 		}
 
 		if key == finalImagePullspecConfigKey {
 			// Replace the user-supplied tag (if present) with the name of the
-			// rendered MachineConfig for uniqueness. This will also allow us to
-			// eventually do a pre-build registry query to determine if we need to
-			// perform a build.
+			// rendered MachineConfig for uniqueness. This also allows us to do a
+			// pre-build registry query to determine if we need to perform a build
+			// (see findExistingImage()).
 			named, err := reference.ParseNamed(val)
 			if err != nil {
 				return nil, fmt.Errorf("could not parse %s with %q: %w", finalImagePullspecConfigKey, val, err)
@@ -875,15 +1167,34 @@ func (ctrl *Controller) getOnClusterBuildConfig(pool *mcfgv1.MachineConfigPool)
 		}
 	}
 
+	if err := ctrl.validateBuildInputs(onClusterBuildConfigMap); err != nil {
+		return nil, err
+	}
+
+	if _, err := newBuildPolicy(onClusterBuildConfigMap); err != nil {
+		return nil, fmt.Errorf("invalid build policy in configmap %s: %w", onClusterBuildConfigMap.Name, err)
+	}
+
+	if err := ctrl.validateBuildPodConfig(onClusterBuildConfigMap); err != nil {
+		return nil, fmt.Errorf("invalid build pod config in configmap %s: %w", onClusterBuildConfigMap.Name, err)
+	}
+
+	if _, err := ctrl.imageBuilder.get(ImageBuilderType(onClusterBuildConfigMap.Data[imageBuilderTypeConfigKey])); err != nil {
+		return nil, fmt.Errorf("invalid %s in configmap %s: %w", imageBuilderTypeConfigKey, onClusterBuildConfigMap.Name, err)
+	}
+
+	if err := ctrl.validateImageSigning(onClusterBuildConfigMap); err != nil {
+		return nil, fmt.Errorf("invalid %s in configmap %s: %w", imageSigningKeySecretNameConfigKey, onClusterBuildConfigMap.Name, err)
+	}
+
 	// If we had to canonicalize a secret, that means the ConfigMap no longer
 	// points to the expected secret. So let's update the ConfigMap in the API
 	// server for the sake of consistency.
-	if needToUpdateConfigMap {
-		klog.Infof("Updating build controller config")
+	for _, cm := range configMapsToUpdate {
+		klog.Infof("Updating build controller config %s", cm.Name)
 		// TODO: Figure out why this causes failures with resourceVersions.
-		onClusterBuildConfigMap, err = ctrl.kubeclient.CoreV1().ConfigMaps(ctrlcommon.MCONamespace).Update(context.TODO(), onClusterBuildConfigMap, metav1.UpdateOptions{})
-		if err != nil {
-			return nil, fmt.Errorf("could not update configmap %q: %w", onClusterBuildConfigMapName, err)
+		if _, err := ctrl.kubeclient.CoreV1().ConfigMaps(ctrlcommon.MCONamespace).Update(context.TODO(), cm, metav1.UpdateOptions{}); err != nil {
+			return nil, fmt.Errorf("could not update configmap %q: %w", cm.Name, err)
 		}
 	}
 
@@ -892,7 +1203,55 @@ func (ctrl *Controller) getOnClusterBuildConfig(pool *mcfgv1.MachineConfigPool)
 	// object so that it's generated on-demand instead.
 	onClusterBuildConfigMap.Data[finalImagePullspecConfigKey] = finalImagePullspecWithTag
 
-	return onClusterBuildConfigMap, err
+	return onClusterBuildConfigMap, nil
+}
+
+// Ensures that the user-supplied Containerfile does not start a new stage and
+// that the ConfigMaps and Secrets to add to the build context exist.
+func (ctrl *Controller) validateBuildInputs(onClusterBuildConfigMap *corev1.ConfigMap) error {
+	if err := validateContainerfile(onClusterBuildConfigMap.Data[containerfileConfigKey]); err != nil {
+		return fmt.Errorf("invalid %s in configmap %s: %w", containerfileConfigKey, onClusterBuildConfigMap.Name, err)
+	}
+
+	buildInputs := newBuildInputs(onClusterBuildConfigMap)
+
+	for _, name := range buildInputs.ConfigMaps {
+		if _, err := ctrl.kubeclient.CoreV1().ConfigMaps(ctrlcommon.MCONamespace).Get(context.TODO(), name, metav1.GetOptions{}); err != nil {
+			return fmt.Errorf("could not get build input configmap %q: %w", name, err)
+		}
+	}
+
+	for _, name := range buildInputs.Secrets {
+		if _, err := ctrl.kubeclient.CoreV1().Secrets(ctrlcommon.MCONamespace).Get(context.TODO(), name, metav1.GetOptions{}); err != nil {
+			return fmt.Errorf("could not get build input secret %q: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+// Hashes the contents of the build input ConfigMaps and Secrets so that an
+// existing image is only reused if it was built from the same contents.
+func (ctrl *Controller) hashBuildInputs(buildInputs BuildInputs) (string, error) {
+	configMaps := []*corev1.ConfigMap{}
+	for _, name := range buildInputs.ConfigMaps {
+		cm, err := ctrl.kubeclient.CoreV1().ConfigMaps(ctrlcommon.MCONamespace).Get(context.TODO(), name, metav1.GetOptions{})
+		if err != nil {
+			return "", fmt.Errorf("could not get build input configmap %q: %w", name, err)
+		}
+		configMaps = append(configMaps, cm)
+	}
+
+	secrets := []*corev1.Secret{}
+	for _, name := range buildInputs.Secrets {
+		secret, err := ctrl.kubeclient.CoreV1().Secrets(ctrlcommon.MCONamespace).Get(context.TODO(), name, metav1.GetOptions{})
+		if err != nil {
+			return "", fmt.Errorf("could not get build input secret %q: %w", name, err)
+		}
+		secrets = append(secrets, secret)
+	}
+
+	return getBuildInputsHash(configMaps, secrets), nil
 }
 
 // Ensure that the supplied pull secret exists, is in the correct format, etc.
@@ -960,9 +1319,63 @@ func (ctrl *Controller) handleCanonicalizedPullSecret(secret *corev1.Secret) (*c
 	return out, nil
 }
 
+// Looks for an image in the registry which was built from the same inputs as
+// the given Image Build Request. Returns the digested pullspec of the image if
+// one is found or an empty string if not.
+func (ctrl *Controller) findExistingImage(ibr ImageBuildRequest) (string, error) {
+	secret, err := ctrl.kubeclient.CoreV1().Secrets(ctrlcommon.MCONamespace).Get(context.TODO(), ibr.FinalImage.PullSecret.Name, metav1.GetOptions{})
+	if err != nil {
+		return "", fmt.Errorf("could not get push secret %q: %w", ibr.FinalImage.PullSecret.Name, err)
+	}
+
+	key, err := getPullSecretKey(secret)
+	if err != nil {
+		return "", err
+	}
+
+	dockerConfigJSON, _, err := canonicalizePullSecretBytes(secret.Data[key])
+	if err != nil {
+		return "", err
+	}
+
+	img, err := ctrl.registryImageLookup(context.TODO(), ibr.FinalImage.Pullspec, dockerConfigJSON)
+	if err != nil {
+		return "", err
+	}
+
+	if img == nil {
+		klog.V(4).Infof("No existing image %s found for MachineConfigPool %s", ibr.FinalImage.Pullspec, ibr.Pool.Name)
+		return "", nil
+	}
+
+	if !ibr.matchesImageLabels(img.Labels) {
+		klog.Infof("Existing image %s does not match the build inputs for MachineConfigPool %s, will rebuild", ibr.FinalImage.Pullspec, ibr.Pool.Name)
+		return "", nil
+	}
+
+	return parseImagePullspecWithDigest(ibr.FinalImage.Pullspec, img.Digest)
+}
+
 // Starts a build for a given Image Build Request.
 func (ctrl *Controller) handleImageBuildRequest(ibr ImageBuildRequest) error {
-	err := ctrl.prepareMachineConfigForPool(ibr)
+	if ibr.ImageBuilderType == "" {
+		ibr.ImageBuilderType = ctrl.imageBuilder.defaultType
+	}
+
+	// If an image built from the same inputs already exists in the registry
+	// (e.g., because layering was re-enabled or the pool was recreated), reuse
+	// it instead of performing a build. Failing to query the registry should
+	// not prevent the build.
+	existingPullspec, err := ctrl.findExistingImage(ibr)
+	if err != nil {
+		klog.Warningf("Could not query registry for an existing image for MachineConfigPool %s, will build: %s", ibr.Pool.Name, err)
+	}
+
+	if existingPullspec != "" {
+		return ctrl.markBuildReused(ibr.Pool, existingPullspec)
+	}
+
+	err = ctrl.prepareMachineConfigForPool(ibr)
 	if err != nil {
 		return fmt.Errorf("could not start build for MachineConfigPool %s: %w", ibr.Pool.Name, err)
 	}
@@ -973,6 +1386,26 @@ func (ctrl *Controller) handleImageBuildRequest(ibr ImageBuildRequest) error {
 		return err
 	}
 
+	// Build records are informational, so failing to create one should not
+	// fail the build.
+	if err := ctrl.recordBuildStarted(ibr, getBuildAttempt(ibr.Pool)); err != nil {
+		klog.Warningf("Could not record build start for pool %s: %s", ibr.Pool.Name, err)
+	}
+
+	// Record the Containerfile hash so that the build name can be determined
+	// from the MachineConfigPool for the remainder of the build.
+	if ibr.Pool.Annotations == nil {
+		ibr.Pool.Annotations = map[string]string{}
+	}
+	if ibr.ContainerfileHash == "" {
+		delete(ibr.Pool.Annotations, containerfileHashAnnotationKey)
+	} else {
+		ibr.Pool.Annotations[containerfileHashAnnotationKey] = ibr.ContainerfileHash
+	}
+
+	// Likewise, record which backend is performing the build.
+	ibr.Pool.Annotations[imageBuilderTypeAnnotationKey] = string(ibr.ImageBuilderType)
+
 	return ctrl.markBuildPendingWithObjectRef(ibr.Pool, *objRef)
 }
 
@@ -986,6 +1419,7 @@ func (ctrl *Controller) finalizeOptOut(pool *mcfgv1.MachineConfigPool) error {
 	deleteBuildRefFromMachineConfigPool(pool)
 
 	delete(pool.Annotations, ctrlcommon.ExperimentalNewestLayeredImageEquivalentConfigAnnotationKey)
+	clearBuildAttempt(pool)
 
 	conditions := []mcfgv1.MachineConfigPoolCondition{}
 
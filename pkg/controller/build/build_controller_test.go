@@ -81,6 +81,44 @@ func TestBuildControllerSingleOptedInPool(t *testing.T) {
 		})
 	})
 
+	t.Run("Existing Image Is Reused", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+		t.Cleanup(cancel)
+
+		lookup := newFakeRegistryImageLookup(t, func(ibr ImageBuildRequest) map[string]string {
+			return ibr.getExpectedImageLabels()
+		})
+
+		newBuildControllerTestFixtureWithRegistryLookup(ctx, t, lookup).runTestFuncs(t, testFuncs{
+			imageBuilder:     testOptInMCPReusesExistingImage,
+			customPodBuilder: testOptInMCPReusesExistingImage,
+		})
+	})
+
+	t.Run("Existing Image With Different Inputs Is Rebuilt", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+		t.Cleanup(cancel)
+
+		lookup := newFakeRegistryImageLookup(t, func(ibr ImageBuildRequest) map[string]string {
+			labels := ibr.getExpectedImageLabels()
+			labels["containerfileHash"] = "abcd1234"
+			return labels
+		})
+
+		newBuildControllerTestFixtureWithRegistryLookup(ctx, t, lookup).runTestFuncs(t, testFuncs{
+			imageBuilder: func(ctx context.Context, t *testing.T, cs *Clients) {
+				testOptInMCPImageBuilder(ctx, t, cs, pool)
+			},
+			customPodBuilder: func(ctx context.Context, t *testing.T, cs *Clients) {
+				testOptInMCPCustomBuildPod(ctx, t, cs, pool)
+			},
+		})
+	})
+
 	t.Run("Build Failure", func(t *testing.T) {
 		t.Parallel()
 
@@ -98,6 +136,19 @@ func TestBuildControllerSingleOptedInPool(t *testing.T) {
 		})
 	})
 
+	t.Run("Build Failure Is Retried", func(t *testing.T) {
+		t.Parallel()
+
+		newBuildControllerTestFixture(t).runTestFuncs(t, testFuncs{
+			imageBuilder: func(ctx context.Context, t *testing.T, cs *Clients) {
+				testFailedBuildIsRetried(ctx, t, cs, failBuild, testOptInMCPImageBuilder)
+			},
+			customPodBuilder: func(ctx context.Context, t *testing.T, cs *Clients) {
+				testFailedBuildIsRetried(ctx, t, cs, failBuildPod, testOptInMCPCustomBuildPod)
+			},
+		})
+	})
+
 	t.Run("Degraded Pool", func(t *testing.T) {
 		t.Parallel()
 
@@ -221,6 +272,7 @@ type buildControllerTestFixture struct {
 	t                      *testing.T
 	imageBuilderClient     *Clients
 	customPodBuilderClient *Clients
+	registryImageLookup    registryImageLookupFunc
 }
 
 type testFuncs struct {
@@ -229,9 +281,16 @@ type testFuncs struct {
 }
 
 func newBuildControllerTestFixtureWithContext(ctx context.Context, t *testing.T) *buildControllerTestFixture {
+	return newBuildControllerTestFixtureWithRegistryLookup(ctx, t, noRegistryImageLookup)
+}
+
+// Creates a test fixture whose BuildControllers query the registry using the
+// supplied lookup func instead of a real registry.
+func newBuildControllerTestFixtureWithRegistryLookup(ctx context.Context, t *testing.T, lookup registryImageLookupFunc) *buildControllerTestFixture {
 	b := &buildControllerTestFixture{
-		ctx: ctx,
-		t:   t,
+		ctx:                 ctx,
+		t:                   t,
+		registryImageLookup: lookup,
 	}
 
 	b.imageBuilderClient = b.startBuildControllerWithImageBuilder()
@@ -339,6 +398,7 @@ func (b *buildControllerTestFixture) startBuildControllerWithImageBuilder() *Cli
 	clients := b.setupClients()
 
 	ctrl := NewWithImageBuilder(b.getConfig(), clients)
+	ctrl.registryImageLookup = b.registryImageLookup
 
 	go ctrl.Run(b.ctx, 5)
 
@@ -349,6 +409,7 @@ func (b *buildControllerTestFixture) startBuildControllerWithCustomPodBuilder()
 	clients := b.setupClients()
 
 	ctrl := NewWithCustomPodBuilder(b.getConfig(), clients)
+	ctrl.registryImageLookup = b.registryImageLookup
 
 	go ctrl.Run(b.ctx, 5)
 
@@ -400,6 +461,86 @@ func testOptInMCPImageBuilder(ctx context.Context, t *testing.T, cs *Clients, po
 	assertMachineConfigPoolReachesState(ctx, t, cs, poolName, isMCPBuildSuccess)
 }
 
+// Opts a given MachineConfigPool into layering and asserts that it reuses the
+// image from the registry without performing a build.
+func testOptInMCPReusesExistingImage(ctx context.Context, t *testing.T, cs *Clients) {
+	optInMCP(ctx, t, cs, "worker")
+	assertMachineConfigPoolReachesState(ctx, t, cs, "worker", func(mcp *mcfgv1.MachineConfigPool) bool {
+		return isMCPBuildSuccess(mcp) &&
+			mcfgv1.GetMachineConfigPoolCondition(mcp.Status, mcfgv1.MachineConfigPoolBuildSuccess).Reason == "ImageReused"
+	})
+	assertNoBuildPods(ctx, t, cs)
+	assertNoBuilds(ctx, t, cs)
+}
+
+// Fails the Build for a given ImageBuildRequest once it is created.
+func failBuild(ctx context.Context, t *testing.T, cs *Clients, ibr ImageBuildRequest) {
+	require.True(t, assertBuildIsCreated(ctx, t, cs, ibr))
+
+	build, err := cs.buildclient.BuildV1().Builds(ctrlcommon.MCONamespace).Get(ctx, ibr.getBuildName(), metav1.GetOptions{})
+	require.NoError(t, err)
+
+	build.Status.Phase = buildv1.BuildPhaseFailed
+	build.Status.Reason = buildv1.StatusReasonGenericBuildFailed
+	build.Status.Message = "Generic Build failure"
+
+	_, err = cs.buildclient.BuildV1().Builds(ctrlcommon.MCONamespace).Update(ctx, build, metav1.UpdateOptions{})
+	require.NoError(t, err)
+}
+
+// Fails the build pod for a given ImageBuildRequest once it is created.
+func failBuildPod(ctx context.Context, t *testing.T, cs *Clients, ibr ImageBuildRequest) {
+	require.True(t, assertBuildPodIsCreated(ctx, t, cs, ibr))
+
+	pod, err := cs.kubeclient.CoreV1().Pods(ctrlcommon.MCONamespace).Get(ctx, ibr.getBuildName(), metav1.GetOptions{})
+	require.NoError(t, err)
+
+	pod.Status.Phase = corev1.PodFailed
+	pod.Status.ContainerStatuses = []corev1.ContainerStatus{
+		{
+			Name: "image-build",
+			State: corev1.ContainerState{
+				Terminated: &corev1.ContainerStateTerminated{ExitCode: 1, Reason: "Error"},
+			},
+		},
+	}
+
+	_, err = cs.kubeclient.CoreV1().Pods(ctrlcommon.MCONamespace).Update(ctx, pod, metav1.UpdateOptions{})
+	require.NoError(t, err)
+}
+
+// Opts a MachineConfigPool into layering with a retry policy, fails the first
+// build, and asserts that the build is retried and both attempts are recorded.
+func testFailedBuildIsRetried(ctx context.Context, t *testing.T, cs *Clients, failFunc func(context.Context, *testing.T, *Clients, ImageBuildRequest), optInFunc optInFunc) {
+	poolName := "worker"
+
+	setBuildRetryPolicy(ctx, t, cs, 1, time.Millisecond)
+
+	mcp := optInMCP(ctx, t, cs, poolName)
+	ibr := newImageBuildRequest(mcp)
+
+	failFunc(ctx, t, cs, ibr)
+
+	record, _ := assertBuildRecordReachesResult(ctx, t, cs, ibr.getBuildRecordConfigMapName(1), BuildResultFailed)
+	assert.Equal(t, 1, record.Attempt)
+	assert.NotEmpty(t, record.FailureReason)
+	assert.NotNil(t, record.EndTime)
+
+	// Wait for the second attempt to start before driving it to completion.
+	assertBuildRecordReachesResult(ctx, t, cs, ibr.getBuildRecordConfigMapName(2), BuildResultRunning)
+
+	optInFunc(ctx, t, cs, poolName)
+
+	record, _ = assertBuildRecordReachesResult(ctx, t, cs, ibr.getBuildRecordConfigMapName(2), BuildResultSucceeded)
+	assert.Equal(t, 2, record.Attempt)
+	assert.Equal(t, expectedImagePullspecWithSHA, record.FinalPullspec)
+
+	mcp, err := cs.mcfgclient.MachineconfigurationV1().MachineConfigPools().Get(ctx, poolName, metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.NotContains(t, mcp.Annotations, buildAttemptAnnotationKey)
+	assert.NotContains(t, mcp.Annotations, buildRetryAfterAnnotationKey)
+}
+
 // Mutates all MachineConfigPools that are not opted in to ensure they are ignored.
 func testNoMCPsOptedIn(ctx context.Context, t *testing.T, cs *Clients) {
 	// Set an unrelated label to force a sync.
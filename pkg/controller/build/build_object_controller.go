@@ -0,0 +1,175 @@
+package build
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+	"k8s.io/klog/v2"
+)
+
+// buildObjectController holds what the image builders which run a build as a
+// Kubernetes object, such as a pod or a Job, have in common: a queue of build
+// objects and the workers which sync them.
+type buildObjectController struct {
+	// The kind of build object, e.g. "pod" or "job", for log messages.
+	kind string
+
+	syncHandler func(key string) error
+
+	queue workqueue.RateLimitingInterface
+
+	config BuildControllerConfig
+}
+
+// Returns a new build object controller with a queue of the given name.
+func newBuildObjectController(kind, name string, config BuildControllerConfig) *buildObjectController {
+	return &buildObjectController{
+		kind:   kind,
+		queue:  workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), name),
+		config: config,
+	}
+}
+
+// enqueueAfter will enqueue a build object after the provided amount of time.
+func (ctrl *buildObjectController) enqueueAfter(obj interface{}, after time.Duration) {
+	key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(obj)
+	if err != nil {
+		utilruntime.HandleError(fmt.Errorf("Couldn't get key for object %#v: %v", obj, err))
+		return
+	}
+
+	ctrl.queue.AddAfter(key, after)
+}
+
+// enqueueDefault calls a default enqueue function
+func (ctrl *buildObjectController) enqueueDefault(obj interface{}) {
+	ctrl.enqueueAfter(obj, ctrl.config.UpdateDelay)
+}
+
+// Gets the build object for the given key with the get function and passes it
+// to the handle function, unless it was deleted or is not a build object.
+func (ctrl *buildObjectController) syncBuildObject(key string, get func(name string) (metav1.Object, error), handle func(metav1.Object) error) error {
+	start := time.Now()
+	defer func() {
+		klog.Infof("Finished syncing %s %s: %s", ctrl.kind, key, time.Since(start))
+	}()
+	klog.Infof("Started syncing %s %s", ctrl.kind, key)
+
+	_, name, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		return err
+	}
+
+	obj, err := get(name)
+	if k8serrors.IsNotFound(err) {
+		klog.V(2).Infof("%s %v has been deleted", ctrl.kind, key)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	// If we don't have all of the OS build labels attached to this object, we
+	// ignore it. There is probably something we can do along the lines looking
+	// at ownership though.
+	if !hasAllRequiredOSBuildLabels(obj.GetLabels()) {
+		klog.Infof("Ignoring non-build %s %s", ctrl.kind, obj.GetName())
+		return nil
+	}
+
+	return handle(obj)
+}
+
+// Starts the workers once the informers are synced and blocks until the
+// context is done.
+func (ctrl *buildObjectController) run(ctx context.Context, name string, workers int, startInformers func(context.Context), synced cache.InformerSynced) {
+	defer utilruntime.HandleCrash()
+	defer ctrl.queue.ShutDown()
+
+	startInformers(ctx)
+
+	if !cache.WaitForCacheSync(ctx.Done(), synced) {
+		return
+	}
+
+	klog.Infof("Starting %s", name)
+	defer klog.Infof("Shutting down %s", name)
+
+	for i := 0; i < workers; i++ {
+		go wait.Until(ctrl.worker, time.Second, ctx.Done())
+	}
+
+	<-ctx.Done()
+}
+
+func (ctrl *buildObjectController) handleErr(err error, key interface{}) {
+	if err == nil {
+		ctrl.queue.Forget(key)
+		return
+	}
+
+	if ctrl.queue.NumRequeues(key) < ctrl.config.MaxRetries {
+		klog.V(2).Infof("Error syncing %s %v: %v", ctrl.kind, key, err)
+		ctrl.queue.AddRateLimited(key)
+		return
+	}
+
+	utilruntime.HandleError(err)
+	klog.V(2).Infof("Dropping %s %q out of the queue: %v", ctrl.kind, key, err)
+	ctrl.queue.Forget(key)
+	ctrl.queue.AddAfter(key, 1*time.Minute)
+}
+
+// worker runs a worker thread that just dequeues items, processes them, and marks them done.
+// It enforces that the syncHandler is never invoked concurrently with the same key.
+func (ctrl *buildObjectController) worker() {
+	for ctrl.processNextWorkItem() {
+	}
+}
+
+func (ctrl *buildObjectController) processNextWorkItem() bool {
+	key, quit := ctrl.queue.Get()
+	if quit {
+		return false
+	}
+	defer ctrl.queue.Done(key)
+
+	err := ctrl.syncHandler(key.(string))
+	ctrl.handleErr(err, key)
+
+	return true
+}
+
+// Gets the existing build object for a build request with the get function.
+// Returns nil if there is none, so that a new build object can be created.
+func getExistingBuildObject(ibr ImageBuildRequest, get func(name string) (metav1.Object, error)) (metav1.Object, error) {
+	targetMC := ibr.Pool.Spec.Configuration.Name
+
+	// TODO: Find a constant for this:
+	if !strings.HasPrefix(targetMC, "rendered-") {
+		return nil, fmt.Errorf("%s is not a rendered MachineConfig", targetMC)
+	}
+
+	// First check if we have a build in progress for this MachineConfigPool and rendered config.
+	obj, err := get(ibr.getBuildName())
+	if k8serrors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if !hasAllRequiredOSBuildLabels(obj.GetLabels()) {
+		return nil, nil
+	}
+
+	return obj, nil
+}
@@ -0,0 +1,452 @@
+package build
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	buildv1 "github.com/openshift/api/build/v1"
+	mcfgv1 "github.com/openshift/machine-config-operator/pkg/apis/machineconfiguration.openshift.io/v1"
+	ctrlcommon "github.com/openshift/machine-config-operator/pkg/controller/common"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	clientset "k8s.io/client-go/kubernetes"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// Label which identifies a ConfigMap as a build record.
+	buildRecordLabel = "machineconfiguration.openshift.io/build-record"
+
+	// Pool annotation which contains the current build attempt for the rendered
+	// MachineConfig. Only present while a failed build is being retried.
+	buildAttemptAnnotationKey = "machineconfiguration.openshift.io/build-attempt"
+
+	// Pool annotation which contains the time (RFC3339) after which a failed
+	// build may be retried.
+	buildRetryAfterAnnotationKey = "machineconfiguration.openshift.io/build-retry-after"
+
+	// The BuildFailed condition reason used while waiting to retry a build.
+	buildRetryPendingReason = "BuildRetryPending"
+
+	// Build record ConfigMap keys.
+	buildRecordConfigMapKey = "record.json"
+	buildLogsConfigMapKey   = "logs"
+
+	// How much of the build log is kept in a build record. ConfigMaps are
+	// limited to 1 MiB, so the tail of the log is trimmed to fit.
+	buildLogTailLines = 500
+	maxBuildLogBytes  = 256 * 1024
+
+	// Defaults for the on-cluster-build-config build policy keys.
+	defaultBuildRetries      = 0
+	defaultBuildRetryBackoff = time.Minute
+	defaultBuildHistoryLimit = 5
+
+	// The longest we'll wait before retrying a build.
+	maxBuildRetryBackoff = 30 * time.Minute
+)
+
+// The outcome of a single build attempt.
+type BuildResult string
+
+const (
+	BuildResultRunning   BuildResult = "Running"
+	BuildResultSucceeded BuildResult = "Succeeded"
+	BuildResultFailed    BuildResult = "Failed"
+)
+
+// Records a single build attempt for a MachineConfigPool. It is stored in a
+// ConfigMap alongside an excerpt of the build logs so that it outlives the
+// build pod or Build object.
+type BuildRecord struct {
+	Pool                 string       `json:"pool"`
+	MachineConfig        string       `json:"machineConfig"`
+	Attempt              int          `json:"attempt"`
+	Builder              string       `json:"builder"`
+	BuildName            string       `json:"buildName"`
+	StartTime            metav1.Time  `json:"startTime"`
+	EndTime              *metav1.Time `json:"endTime,omitempty"`
+	BaseImage            string       `json:"baseImage"`
+	ExtensionsImage      string       `json:"extensionsImage,omitempty"`
+	ReleaseVersion       string       `json:"releaseVersion"`
+	ContainerfileHash    string       `json:"containerfileHash,omitempty"`
+	SigningKeyHash       string       `json:"signingKeyHash,omitempty"`
+	BuildInputConfigMaps []string     `json:"buildInputConfigMaps,omitempty"`
+	BuildInputSecrets    []string     `json:"buildInputSecrets,omitempty"`
+	Result               BuildResult  `json:"result"`
+	FinalPullspec        string       `json:"finalPullspec,omitempty"`
+	FailureReason        string       `json:"failureReason,omitempty"`
+}
+
+// Controls how failed builds are retried and how many build records are kept.
+// Populated from the on-cluster-build-config ConfigMap.
+type buildPolicy struct {
+	Retries      int
+	RetryBackoff time.Duration
+	HistoryLimit int
+}
+
+// Parses the build policy from the on-cluster-build-config ConfigMap, using
+// defaults for any keys which are not set.
+func newBuildPolicy(onClusterBuildConfigMap *corev1.ConfigMap) (buildPolicy, error) {
+	policy := buildPolicy{
+		Retries:      defaultBuildRetries,
+		RetryBackoff: defaultBuildRetryBackoff,
+		HistoryLimit: defaultBuildHistoryLimit,
+	}
+
+	parseInt := func(key string, minimum int, out *int) error {
+		val, ok := onClusterBuildConfigMap.Data[key]
+		if !ok {
+			return nil
+		}
+
+		parsed, err := strconv.Atoi(val)
+		if err != nil {
+			return fmt.Errorf("invalid %s %q: %w", key, val, err)
+		}
+
+		if parsed < minimum {
+			return fmt.Errorf("invalid %s %d: must be at least %d", key, parsed, minimum)
+		}
+
+		*out = parsed
+		return nil
+	}
+
+	if err := parseInt(buildRetriesConfigKey, 0, &policy.Retries); err != nil {
+		return policy, err
+	}
+
+	if err := parseInt(buildHistoryLimitConfigKey, 1, &policy.HistoryLimit); err != nil {
+		return policy, err
+	}
+
+	if val, ok := onClusterBuildConfigMap.Data[buildRetryBackoffConfigKey]; ok {
+		backoff, err := time.ParseDuration(val)
+		if err != nil {
+			return policy, fmt.Errorf("invalid %s %q: %w", buildRetryBackoffConfigKey, val, err)
+		}
+
+		if backoff <= 0 {
+			return policy, fmt.Errorf("invalid %s %q: must be positive", buildRetryBackoffConfigKey, val)
+		}
+
+		policy.RetryBackoff = backoff
+	}
+
+	return policy, nil
+}
+
+// Computes how long to wait before starting the given attempt. The backoff
+// doubles with each attempt, up to maxBuildRetryBackoff.
+func (b buildPolicy) backoff(attempt int) time.Duration {
+	backoff := b.RetryBackoff
+	for i := 2; i < attempt && backoff < maxBuildRetryBackoff; i++ {
+		backoff *= 2
+	}
+
+	if backoff > maxBuildRetryBackoff {
+		return maxBuildRetryBackoff
+	}
+
+	return backoff
+}
+
+// Gets the current build attempt for a MachineConfigPool. The first attempt is 1.
+func getBuildAttempt(pool *mcfgv1.MachineConfigPool) int {
+	attempt, err := strconv.Atoi(pool.Annotations[buildAttemptAnnotationKey])
+	if err != nil || attempt < 1 {
+		return 1
+	}
+
+	return attempt
+}
+
+// Determines whether a MachineConfigPool is waiting for a failed build to be retried.
+func isBuildRetryPending(pool *mcfgv1.MachineConfigPool) bool {
+	condition := mcfgv1.GetMachineConfigPoolCondition(pool.Status, mcfgv1.MachineConfigPoolBuildFailed)
+	return condition != nil && condition.Status == corev1.ConditionTrue && condition.Reason == buildRetryPendingReason
+}
+
+// Gets the time after which a failed build may be retried. Returns the zero
+// time if it is not set.
+func getBuildRetryAfter(pool *mcfgv1.MachineConfigPool) time.Time {
+	retryAfter, err := time.Parse(time.RFC3339, pool.Annotations[buildRetryAfterAnnotationKey])
+	if err != nil {
+		return time.Time{}
+	}
+
+	return retryAfter
+}
+
+// Removes the build retry annotations from a MachineConfigPool.
+func clearBuildAttempt(pool *mcfgv1.MachineConfigPool) {
+	delete(pool.Annotations, buildAttemptAnnotationKey)
+	delete(pool.Annotations, buildRetryAfterAnnotationKey)
+}
+
+// Gets the tail of the logs for a given build pod and container. An empty
+// container name selects the only container in the pod.
+func getBuildPodLogs(kubeclient clientset.Interface, podName, container string) (string, error) {
+	tailLines := int64(buildLogTailLines)
+	limitBytes := int64(maxBuildLogBytes)
+
+	opts := &corev1.PodLogOptions{
+		Container:  container,
+		TailLines:  &tailLines,
+		LimitBytes: &limitBytes,
+	}
+
+	out, err := kubeclient.CoreV1().Pods(ctrlcommon.MCONamespace).GetLogs(podName, opts).DoRaw(context.TODO())
+	if err != nil {
+		return "", fmt.Errorf("could not get logs for build pod %s: %w", podName, err)
+	}
+
+	return string(out), nil
+}
+
+// Creates a new build record for the given Image Build Request.
+func newBuildRecord(ibr ImageBuildRequest, builder string, attempt int) BuildRecord {
+	return BuildRecord{
+		Pool:                 ibr.Pool.Name,
+		MachineConfig:        ibr.Pool.Spec.Configuration.Name,
+		Attempt:              attempt,
+		Builder:              builder,
+		BuildName:            ibr.getBuildName(),
+		StartTime:            metav1.Now(),
+		BaseImage:            ibr.BaseImage.Pullspec,
+		ExtensionsImage:      ibr.ExtensionsImage.Pullspec,
+		ReleaseVersion:       ibr.ReleaseVersion,
+		ContainerfileHash:    ibr.ContainerfileHash,
+		SigningKeyHash:       ibr.SigningKey.PublicKeyHash,
+		BuildInputConfigMaps: ibr.BuildInputs.ConfigMaps,
+		BuildInputSecrets:    ibr.BuildInputs.Secrets,
+		Result:               BuildResultRunning,
+	}
+}
+
+// Computes the name of the build record ConfigMap for a given build attempt.
+func (i ImageBuildRequest) getBuildRecordConfigMapName(attempt int) string {
+	return fmt.Sprintf("%s-record-%d", i.getBuildName(), attempt)
+}
+
+// Converts a build record into a ConfigMap.
+func (i ImageBuildRequest) buildRecordToConfigMap(record BuildRecord, logs string) (*corev1.ConfigMap, error) {
+	out, err := json.Marshal(record)
+	if err != nil {
+		return nil, fmt.Errorf("could not encode build record: %w", err)
+	}
+
+	cm := &corev1.ConfigMap{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "v1",
+			Kind:       "ConfigMap",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      i.getBuildRecordConfigMapName(record.Attempt),
+			Namespace: ctrlcommon.MCONamespace,
+			Labels: map[string]string{
+				buildRecordLabel:             "",
+				targetMachineConfigPoolLabel: i.Pool.Name,
+				desiredConfigLabel:           i.Pool.Spec.Configuration.Name,
+			},
+		},
+		Data: map[string]string{
+			buildRecordConfigMapKey: string(out),
+		},
+	}
+
+	if logs != "" {
+		cm.Data[buildLogsConfigMapKey] = logs
+	}
+
+	return cm, nil
+}
+
+// Decodes the build record from a build record ConfigMap.
+func buildRecordFromConfigMap(cm *corev1.ConfigMap) (BuildRecord, error) {
+	record := BuildRecord{}
+	if err := json.Unmarshal([]byte(cm.Data[buildRecordConfigMapKey]), &record); err != nil {
+		return record, fmt.Errorf("could not decode build record %s: %w", cm.Name, err)
+	}
+
+	return record, nil
+}
+
+// Stores a build record for a newly-started build and prunes the oldest
+// records for the pool beyond the history limit.
+func (ctrl *Controller) recordBuildStarted(ibr ImageBuildRequest, attempt int) error {
+	record := newBuildRecord(ibr, string(ibr.ImageBuilderType), attempt)
+
+	cm, err := ibr.buildRecordToConfigMap(record, "")
+	if err != nil {
+		return err
+	}
+
+	_, err = ctrl.kubeclient.CoreV1().ConfigMaps(ctrlcommon.MCONamespace).Create(context.TODO(), cm, metav1.CreateOptions{})
+	if err != nil && !k8serrors.IsAlreadyExists(err) {
+		return fmt.Errorf("could not create build record %s: %w", cm.Name, err)
+	}
+
+	if err == nil {
+		klog.Infof("Created build record %s for pool %s", cm.Name, ibr.Pool.Name)
+	}
+
+	policy, err := ctrl.getBuildPolicy(ibr.Pool)
+	if err != nil {
+		return err
+	}
+
+	return ctrl.pruneBuildRecords(ibr.Pool, policy.HistoryLimit)
+}
+
+// Updates the build record for the current build attempt of the pool with
+// its outcome and an excerpt of the build logs.
+func (ctrl *Controller) recordBuildFinished(pool *mcfgv1.MachineConfigPool, result BuildResult, finalPullspec, failureReason string) error {
+	ibr := newImageBuildRequest(pool)
+	name := ibr.getBuildRecordConfigMapName(getBuildAttempt(pool))
+
+	cm, err := ctrl.kubeclient.CoreV1().ConfigMaps(ctrlcommon.MCONamespace).Get(context.TODO(), name, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("could not get build record %s: %w", name, err)
+	}
+
+	record, err := buildRecordFromConfigMap(cm)
+	if err != nil {
+		return err
+	}
+
+	now := metav1.Now()
+	record.EndTime = &now
+	record.Result = result
+	record.FinalPullspec = finalPullspec
+	record.FailureReason = failureReason
+
+	logs, err := ctrl.imageBuilder.BuildLogs(pool)
+	if err != nil {
+		// The logs are nice to have, but their absence should not prevent us
+		// from recording the outcome.
+		klog.Warningf("Could not get logs for build %s: %s", record.BuildName, err)
+	}
+
+	updated, err := ibr.buildRecordToConfigMap(record, logs)
+	if err != nil {
+		return err
+	}
+
+	cm.Data = updated.Data
+
+	if _, err := ctrl.kubeclient.CoreV1().ConfigMaps(ctrlcommon.MCONamespace).Update(context.TODO(), cm, metav1.UpdateOptions{}); err != nil {
+		return fmt.Errorf("could not update build record %s: %w", name, err)
+	}
+
+	klog.Infof("Updated build record %s for pool %s: %s", name, pool.Name, result)
+
+	return nil
+}
+
+// Lists the build records for a given pool, newest first.
+func (ctrl *Controller) listBuildRecords(pool *mcfgv1.MachineConfigPool) ([]corev1.ConfigMap, error) {
+	selector := labels.SelectorFromSet(labels.Set{
+		buildRecordLabel:             "",
+		targetMachineConfigPoolLabel: pool.Name,
+	})
+
+	cmList, err := ctrl.kubeclient.CoreV1().ConfigMaps(ctrlcommon.MCONamespace).List(context.TODO(), metav1.ListOptions{LabelSelector: selector.String()})
+	if err != nil {
+		return nil, fmt.Errorf("could not list build records for pool %s: %w", pool.Name, err)
+	}
+
+	startTimes := map[string]time.Time{}
+	for _, cm := range cmList.Items {
+		record, err := buildRecordFromConfigMap(&cm)
+		if err != nil {
+			klog.Warningf("Ignoring build record: %s", err)
+		}
+		startTimes[cm.Name] = record.StartTime.Time
+	}
+
+	records := cmList.Items
+	sort.SliceStable(records, func(i, j int) bool {
+		return startTimes[records[i].Name].After(startTimes[records[j].Name])
+	})
+
+	return records, nil
+}
+
+// Deletes the oldest build records for a pool so that at most limit remain.
+func (ctrl *Controller) pruneBuildRecords(pool *mcfgv1.MachineConfigPool, limit int) error {
+	records, err := ctrl.listBuildRecords(pool)
+	if err != nil {
+		return err
+	}
+
+	if len(records) <= limit {
+		return nil
+	}
+
+	for _, cm := range records[limit:] {
+		if err := ignoreIsNotFoundErr(ctrl.kubeclient.CoreV1().ConfigMaps(ctrlcommon.MCONamespace).Delete(context.TODO(), cm.Name, metav1.DeleteOptions{})); err != nil {
+			return fmt.Errorf("could not delete build record %s: %w", cm.Name, err)
+		}
+
+		klog.Infof("Pruned build record %s for pool %s", cm.Name, pool.Name)
+	}
+
+	return nil
+}
+
+// Gets the build policy of a given MachineConfigPool from its on-cluster build
+// configuration.
+func (ctrl *Controller) getBuildPolicy(pool *mcfgv1.MachineConfigPool) (buildPolicy, error) {
+	onClusterBuildConfigMap, err := ctrl.getBuildConfigForPool(pool)
+	if err != nil {
+		return buildPolicy{}, err
+	}
+
+	return newBuildPolicy(onClusterBuildConfigMap)
+}
+
+// Describes why a given Build failed.
+func getBuildFailureReason(build *buildv1.Build) string {
+	switch {
+	case build.Status.Reason != "" && build.Status.Message != "":
+		return fmt.Sprintf("%s: %s", build.Status.Reason, build.Status.Message)
+	case build.Status.Reason != "":
+		return string(build.Status.Reason)
+	case build.Status.Message != "":
+		return build.Status.Message
+	default:
+		return fmt.Sprintf("build %s is %s", build.Name, build.Status.Phase)
+	}
+}
+
+// Describes why a given build pod failed.
+func getBuildPodFailureReason(pod *corev1.Pod) string {
+	for _, status := range pod.Status.ContainerStatuses {
+		terminated := status.State.Terminated
+		if terminated == nil || terminated.ExitCode == 0 {
+			continue
+		}
+
+		reason := fmt.Sprintf("container %s exited with code %d", status.Name, terminated.ExitCode)
+		if terminated.Reason != "" {
+			reason = fmt.Sprintf("%s (%s)", reason, terminated.Reason)
+		}
+
+		return reason
+	}
+
+	if pod.Status.Reason != "" {
+		return fmt.Sprintf("%s: %s", pod.Status.Reason, pod.Status.Message)
+	}
+
+	return fmt.Sprintf("build pod %s failed", pod.Name)
+}
@@ -0,0 +1,201 @@
+package build
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	buildv1 "github.com/openshift/api/build/v1"
+	mcfgv1 "github.com/openshift/machine-config-operator/pkg/apis/machineconfiguration.openshift.io/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	fakecorev1client "k8s.io/client-go/kubernetes/fake"
+)
+
+// Tests that the build policy is parsed from the on-cluster-build-config
+// ConfigMap with the expected defaults and validation.
+func TestNewBuildPolicy(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name        string
+		data        map[string]string
+		expected    buildPolicy
+		errExpected bool
+	}{
+		{
+			name: "Defaults",
+			expected: buildPolicy{
+				Retries:      defaultBuildRetries,
+				RetryBackoff: defaultBuildRetryBackoff,
+				HistoryLimit: defaultBuildHistoryLimit,
+			},
+		},
+		{
+			name: "All keys set",
+			data: map[string]string{
+				buildRetriesConfigKey:      "3",
+				buildRetryBackoffConfigKey: "30s",
+				buildHistoryLimitConfigKey: "10",
+			},
+			expected: buildPolicy{
+				Retries:      3,
+				RetryBackoff: 30 * time.Second,
+				HistoryLimit: 10,
+			},
+		},
+		{
+			name:        "Non-numeric retries",
+			data:        map[string]string{buildRetriesConfigKey: "many"},
+			errExpected: true,
+		},
+		{
+			name:        "Negative retries",
+			data:        map[string]string{buildRetriesConfigKey: "-1"},
+			errExpected: true,
+		},
+		{
+			name:        "Zero history limit",
+			data:        map[string]string{buildHistoryLimitConfigKey: "0"},
+			errExpected: true,
+		},
+		{
+			name:        "Invalid backoff",
+			data:        map[string]string{buildRetryBackoffConfigKey: "soon"},
+			errExpected: true,
+		},
+		{
+			name:        "Zero backoff",
+			data:        map[string]string{buildRetryBackoffConfigKey: "0s"},
+			errExpected: true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			cm := getOnClusterBuildConfigMap()
+			for key, val := range testCase.data {
+				cm.Data[key] = val
+			}
+
+			policy, err := newBuildPolicy(cm)
+			if testCase.errExpected {
+				assert.Error(t, err)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, testCase.expected, policy)
+		})
+	}
+}
+
+// Tests that the retry backoff doubles with each attempt and is capped.
+func TestBuildPolicyBackoff(t *testing.T) {
+	t.Parallel()
+
+	policy := buildPolicy{RetryBackoff: time.Minute}
+
+	assert.Equal(t, time.Minute, policy.backoff(2))
+	assert.Equal(t, 2*time.Minute, policy.backoff(3))
+	assert.Equal(t, 4*time.Minute, policy.backoff(4))
+	assert.Equal(t, maxBuildRetryBackoff, policy.backoff(20))
+}
+
+// Tests that only the newest build records for a pool are kept.
+func TestPruneBuildRecords(t *testing.T) {
+	t.Parallel()
+
+	worker := newMachineConfigPool("worker", "rendered-worker-1")
+	master := newMachineConfigPool("master", "rendered-master-1")
+
+	objects := []runtime.Object{}
+	start := time.Now()
+
+	for i, pool := range []*mcfgv1.MachineConfigPool{worker, master} {
+		ibr := newImageBuildRequestWithConfigMap(pool, getOSImageURLConfigMap(), getOnClusterBuildConfigMap())
+		for attempt := 1; attempt <= 4; attempt++ {
+			record := newBuildRecord(ibr, string(CustomPodImageBuilder), attempt)
+			record.StartTime = metav1.NewTime(start.Add(time.Duration(i*10+attempt) * time.Minute))
+
+			cm, err := ibr.buildRecordToConfigMap(record, "")
+			require.NoError(t, err)
+			objects = append(objects, cm)
+		}
+	}
+
+	ctrl := &Controller{
+		Clients: &Clients{
+			kubeclient: fakecorev1client.NewSimpleClientset(objects...),
+		},
+	}
+
+	require.NoError(t, ctrl.pruneBuildRecords(worker, 2))
+
+	names := func(pool *mcfgv1.MachineConfigPool) []string {
+		records, err := ctrl.listBuildRecords(pool)
+		require.NoError(t, err)
+
+		out := []string{}
+		for _, record := range records {
+			out = append(out, record.Name)
+		}
+		return out
+	}
+
+	assert.Equal(t, []string{
+		"build-rendered-worker-1-record-4",
+		"build-rendered-worker-1-record-3",
+	}, names(worker))
+
+	// Records for other pools are left alone.
+	assert.Len(t, names(master), 4)
+}
+
+// Tests that build and build pod failures are described.
+func TestBuildFailureReasons(t *testing.T) {
+	t.Parallel()
+
+	build := &buildv1.Build{
+		ObjectMeta: metav1.ObjectMeta{Name: "build-rendered-worker-1"},
+		Status: buildv1.BuildStatus{
+			Phase:   buildv1.BuildPhaseFailed,
+			Reason:  buildv1.StatusReasonPushImageToRegistryFailed,
+			Message: "Failed to push the image to the registry.",
+		},
+	}
+
+	assert.Equal(t, "PushImageToRegistryFailed: Failed to push the image to the registry.", getBuildFailureReason(build))
+
+	build.Status.Reason = ""
+	build.Status.Message = ""
+	assert.Equal(t, "build build-rendered-worker-1 is Failed", getBuildFailureReason(build))
+
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "build-rendered-worker-1"},
+		Status: corev1.PodStatus{
+			Phase: corev1.PodFailed,
+			ContainerStatuses: []corev1.ContainerStatus{
+				{
+					Name:  "wait-for-done",
+					State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{ExitCode: 0}},
+				},
+				{
+					Name:  "image-build",
+					State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{ExitCode: 125, Reason: "Error"}},
+				},
+			},
+		},
+	}
+
+	assert.Equal(t, "container image-build exited with code 125 (Error)", getBuildPodFailureReason(pod))
+
+	pod.Status.ContainerStatuses = nil
+	assert.Equal(t, fmt.Sprintf("build pod %s failed", pod.Name), getBuildPodFailureReason(pod))
+}
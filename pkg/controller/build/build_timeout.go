@@ -0,0 +1,61 @@
+package build
+
+import (
+	"fmt"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// Annotation on build objects which contains how long the build may take
+	// (e.g., 2h0m0s). It is counted from the creation of the build object so
+	// that builds which never get scheduled also time out.
+	buildTimeoutAnnotationKey = "machineconfiguration.openshift.io/build-timeout"
+
+	// The BuildFailed condition reason used when a build takes longer than its
+	// timeout.
+	buildTimedOutReason = "BuildTimedOut"
+
+	// The reason set by Kubernetes when a pod or Job exceeds its
+	// activeDeadlineSeconds.
+	deadlineExceededReason = "DeadlineExceeded"
+)
+
+// Gets the time by which the given build object must have finished. Returns
+// false if the build has no timeout.
+func getBuildDeadline(obj metav1.Object) (time.Time, bool) {
+	timeout, err := time.ParseDuration(obj.GetAnnotations()[buildTimeoutAnnotationKey])
+	if err != nil || timeout <= 0 {
+		return time.Time{}, false
+	}
+
+	// The creation timestamp is always set by the API server.
+	created := obj.GetCreationTimestamp()
+	if created.IsZero() {
+		return time.Time{}, false
+	}
+
+	return created.Add(timeout), true
+}
+
+// Determines whether the given build object has exceeded its timeout. If it
+// has not, returns how long remains until it does, or zero if the build has no
+// timeout.
+func checkBuildDeadline(obj metav1.Object, now time.Time) (bool, time.Duration) {
+	deadline, ok := getBuildDeadline(obj)
+	if !ok {
+		return false, 0
+	}
+
+	if remaining := deadline.Sub(now); remaining > 0 {
+		return false, remaining
+	}
+
+	return true, 0
+}
+
+// Describes a build which exceeded its timeout.
+func getBuildTimedOutReason(obj metav1.Object) string {
+	return fmt.Sprintf("build %s exceeded its timeout of %s", obj.GetName(), obj.GetAnnotations()[buildTimeoutAnnotationKey])
+}
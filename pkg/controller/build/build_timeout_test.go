@@ -0,0 +1,220 @@
+package build
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	buildv1 "github.com/openshift/api/build/v1"
+	mcfgv1 "github.com/openshift/machine-config-operator/pkg/apis/machineconfiguration.openshift.io/v1"
+	ctrlcommon "github.com/openshift/machine-config-operator/pkg/controller/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+// Tests that the deadline of a build object is derived from its creation time
+// and timeout annotation.
+func TestCheckBuildDeadline(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+
+	testCases := []struct {
+		name              string
+		created           time.Time
+		timeout           string
+		expectedTimedOut  bool
+		expectedRemaining time.Duration
+	}{
+		{
+			name:    "No timeout",
+			created: now.Add(-time.Hour),
+		},
+		{
+			name:    "Invalid timeout",
+			created: now.Add(-time.Hour),
+			timeout: "soon",
+		},
+		{
+			name:    "No creation timestamp",
+			timeout: "1m0s",
+		},
+		{
+			name:              "Within timeout",
+			created:           now.Add(-time.Minute),
+			timeout:           "1h0m0s",
+			expectedRemaining: 59 * time.Minute,
+		},
+		{
+			name:             "Timed out",
+			created:          now.Add(-2 * time.Hour),
+			timeout:          "1h0m0s",
+			expectedTimedOut: true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			pod := &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:              "build-rendered-worker-1",
+					CreationTimestamp: metav1.NewTime(testCase.created),
+					Annotations:       map[string]string{},
+				},
+			}
+
+			if testCase.timeout != "" {
+				pod.Annotations[buildTimeoutAnnotationKey] = testCase.timeout
+			}
+
+			timedOut, remaining := checkBuildDeadline(pod, now)
+			assert.Equal(t, testCase.expectedTimedOut, timedOut)
+			assert.Equal(t, testCase.expectedRemaining, remaining)
+		})
+	}
+}
+
+// Tests that a Job which exceeded its deadline is recognized as timed out.
+func TestIsBuildJobTimedOut(t *testing.T) {
+	t.Parallel()
+
+	job := &batchv1.Job{
+		Status: batchv1.JobStatus{
+			Conditions: []batchv1.JobCondition{
+				{Type: batchv1.JobFailed, Status: corev1.ConditionTrue, Reason: "BackoffLimitExceeded"},
+			},
+		},
+	}
+
+	assert.False(t, isBuildJobTimedOut(job))
+
+	job.Status.Conditions[0].Reason = deadlineExceededReason
+	assert.True(t, isBuildJobTimedOut(job))
+}
+
+// Sets the build timeout in the on-cluster-build-config ConfigMap.
+func setBuildTimeout(ctx context.Context, t *testing.T, cs *Clients, timeout string) {
+	t.Helper()
+
+	cm, err := cs.kubeclient.CoreV1().ConfigMaps(ctrlcommon.MCONamespace).Get(ctx, onClusterBuildConfigMapName, metav1.GetOptions{})
+	require.NoError(t, err)
+
+	cm.Data[buildTimeoutConfigKey] = timeout
+
+	_, err = cs.kubeclient.CoreV1().ConfigMaps(ctrlcommon.MCONamespace).Update(ctx, cm, metav1.UpdateOptions{})
+	require.NoError(t, err)
+}
+
+// Asserts that the pool reaches the BuildFailed state because its build timed out.
+func assertMCPBuildTimedOut(ctx context.Context, t *testing.T, cs *Clients) {
+	t.Helper()
+
+	assertMachineConfigPoolReachesState(ctx, t, cs, "worker", func(mcp *mcfgv1.MachineConfigPool) bool {
+		condition := mcfgv1.GetMachineConfigPoolCondition(mcp.Status, mcfgv1.MachineConfigPoolBuildFailed)
+		return isMCPBuildFailure(mcp) && condition.Reason == buildTimedOutReason
+	})
+}
+
+// Tests that the build controllers fail builds which exceed their timeout.
+func TestBuildTimeout(t *testing.T) {
+	t.Parallel()
+
+	newFixture := func(t *testing.T) *buildControllerTestFixture {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+		t.Cleanup(cancel)
+
+		return newBuildControllerTestFixtureWithContext(ctx, t)
+	}
+
+	t.Run("Unscheduled build pod", func(t *testing.T) {
+		t.Parallel()
+
+		b := newFixture(t)
+		ctx, cs := b.ctx, b.customPodBuilderClient
+
+		setBuildTimeout(ctx, t, cs, "1h")
+
+		ibr := newImageBuildRequest(optInMCP(ctx, t, cs, "worker"))
+		require.True(t, assertBuildPodIsCreated(ctx, t, cs, ibr))
+
+		pod, err := cs.kubeclient.CoreV1().Pods(ctrlcommon.MCONamespace).Get(ctx, ibr.getBuildName(), metav1.GetOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, int64(3600), *pod.Spec.ActiveDeadlineSeconds)
+		assert.Equal(t, "1h0m0s", pod.Annotations[buildTimeoutAnnotationKey])
+
+		// Pretend that the pod has been waiting to be scheduled for longer than
+		// the timeout.
+		pod.CreationTimestamp = metav1.NewTime(time.Now().Add(-2 * time.Hour))
+		_, err = cs.kubeclient.CoreV1().Pods(ctrlcommon.MCONamespace).Update(ctx, pod, metav1.UpdateOptions{})
+		require.NoError(t, err)
+
+		assertMCPBuildTimedOut(ctx, t, cs)
+
+		err = wait.PollImmediateInfiniteWithContext(ctx, time.Millisecond, func(ctx context.Context) (bool, error) {
+			_, err := cs.kubeclient.CoreV1().Pods(ctrlcommon.MCONamespace).Get(ctx, ibr.getBuildName(), metav1.GetOptions{})
+			return k8serrors.IsNotFound(err), nil
+		})
+		assert.NoError(t, err, "expected timed out build pod to be deleted")
+	})
+
+	t.Run("Build pod deadline exceeded", func(t *testing.T) {
+		t.Parallel()
+
+		b := newFixture(t)
+		ctx, cs := b.ctx, b.customPodBuilderClient
+
+		setBuildTimeout(ctx, t, cs, "1h")
+
+		ibr := newImageBuildRequest(optInMCP(ctx, t, cs, "worker"))
+		require.True(t, assertBuildPodIsCreated(ctx, t, cs, ibr))
+
+		pod, err := cs.kubeclient.CoreV1().Pods(ctrlcommon.MCONamespace).Get(ctx, ibr.getBuildName(), metav1.GetOptions{})
+		require.NoError(t, err)
+
+		// This is what the kubelet reports once activeDeadlineSeconds has elapsed.
+		pod.Status.Phase = corev1.PodFailed
+		pod.Status.Reason = deadlineExceededReason
+		pod.Status.Message = "Pod was active on the node longer than the specified deadline"
+		_, err = cs.kubeclient.CoreV1().Pods(ctrlcommon.MCONamespace).Update(ctx, pod, metav1.UpdateOptions{})
+		require.NoError(t, err)
+
+		assertMCPBuildTimedOut(ctx, t, cs)
+	})
+
+	t.Run("Unscheduled Build", func(t *testing.T) {
+		t.Parallel()
+
+		b := newFixture(t)
+		ctx, cs := b.ctx, b.imageBuilderClient
+
+		setBuildTimeout(ctx, t, cs, "1h")
+
+		ibr := newImageBuildRequest(optInMCP(ctx, t, cs, "worker"))
+		require.True(t, assertBuildIsCreated(ctx, t, cs, ibr))
+
+		build, err := cs.buildclient.BuildV1().Builds(ctrlcommon.MCONamespace).Get(ctx, ibr.getBuildName(), metav1.GetOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, int64(3600), *build.Spec.CompletionDeadlineSeconds)
+
+		build.Status.Phase = buildv1.BuildPhasePending
+		build.CreationTimestamp = metav1.NewTime(time.Now().Add(-2 * time.Hour))
+		_, err = cs.buildclient.BuildV1().Builds(ctrlcommon.MCONamespace).Update(ctx, build, metav1.UpdateOptions{})
+		require.NoError(t, err)
+
+		assertMCPBuildTimedOut(ctx, t, cs)
+
+		err = wait.PollImmediateInfiniteWithContext(ctx, time.Millisecond, func(ctx context.Context) (bool, error) {
+			build, err := cs.buildclient.BuildV1().Builds(ctrlcommon.MCONamespace).Get(ctx, ibr.getBuildName(), metav1.GetOptions{})
+			return err == nil && build.Status.Cancelled, nil
+		})
+		assert.NoError(t, err, "expected timed out build to be cancelled")
+	})
+}
@@ -11,6 +11,7 @@ import (
 	ign3types "github.com/coreos/ignition/v2/config/v3_4/types"
 	"github.com/davecgh/go-spew/spew"
 	"github.com/ghodss/yaml"
+	"github.com/opencontainers/go-digest"
 	buildv1 "github.com/openshift/api/build/v1"
 	mcfgv1 "github.com/openshift/machine-config-operator/pkg/apis/machineconfiguration.openshift.io/v1"
 	ctrlcommon "github.com/openshift/machine-config-operator/pkg/controller/common"
@@ -54,6 +55,32 @@ func getOnClusterBuildConfigMap() *corev1.ConfigMap {
 	}
 }
 
+// A registry image lookup which never finds an existing image.
+func noRegistryImageLookup(context.Context, string, []byte) (*registryImage, error) {
+	return nil, nil
+}
+
+// Creates a registry image lookup which finds an image with the labels
+// returned by labelsFunc for the tagged final image pullspec of the worker
+// pool, and no image otherwise.
+func newFakeRegistryImageLookup(t *testing.T, labelsFunc func(ImageBuildRequest) map[string]string) registryImageLookupFunc {
+	pool := newMachineConfigPool("worker", "rendered-worker-1")
+	ibr := newImageBuildRequestWithConfigMap(pool, getOSImageURLConfigMap(), getOnClusterBuildConfigMap())
+
+	return func(_ context.Context, pullspec string, dockerConfigJSON []byte) (*registryImage, error) {
+		assert.Contains(t, string(dockerConfigJSON), `"auths"`)
+
+		if pullspec != "registry.hostname.com/org/repo:rendered-worker-1" {
+			return nil, nil
+		}
+
+		return &registryImage{
+			Digest: digest.Digest(expectedImageSHA),
+			Labels: labelsFunc(ibr),
+		}, nil
+	}
+}
+
 // Creates a new MachineConfigPool and the corresponding MachineConfigs.
 func newMachineConfigPoolAndConfigs(name string, params ...string) []runtime.Object {
 	mcp := newMachineConfigPool(name, params...)
@@ -580,3 +607,44 @@ func dumpToYAMLFile(t *testing.T, obj interface{}, filename string) {
 
 	require.NoError(t, ioutil.WriteFile(filename, out, 0755))
 }
+
+// Sets the build retry policy in the on-cluster-build-config ConfigMap.
+func setBuildRetryPolicy(ctx context.Context, t *testing.T, cs *Clients, retries int, backoff time.Duration) {
+	t.Helper()
+
+	cm, err := cs.kubeclient.CoreV1().ConfigMaps(ctrlcommon.MCONamespace).Get(ctx, onClusterBuildConfigMapName, metav1.GetOptions{})
+	require.NoError(t, err)
+
+	cm.Data[buildRetriesConfigKey] = fmt.Sprintf("%d", retries)
+	cm.Data[buildRetryBackoffConfigKey] = backoff.String()
+
+	_, err = cs.kubeclient.CoreV1().ConfigMaps(ctrlcommon.MCONamespace).Update(ctx, cm, metav1.UpdateOptions{})
+	require.NoError(t, err)
+}
+
+// Polls until a build record reaches the expected result and returns it.
+func assertBuildRecordReachesResult(ctx context.Context, t *testing.T, cs *Clients, name string, result BuildResult) (BuildRecord, *corev1.ConfigMap) {
+	t.Helper()
+
+	var record BuildRecord
+	var recordConfigMap *corev1.ConfigMap
+
+	err := wait.PollImmediateInfiniteWithContext(ctx, time.Millisecond, func(ctx context.Context) (bool, error) {
+		cm, err := cs.kubeclient.CoreV1().ConfigMaps(ctrlcommon.MCONamespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return false, nil
+		}
+
+		record, err = buildRecordFromConfigMap(cm)
+		if err != nil {
+			return false, err
+		}
+
+		recordConfigMap = cm
+		return record.Result == result, nil
+	})
+
+	assert.NoError(t, err, "build record %s did not reach result %s", name, result)
+
+	return record, recordConfigMap
+}
@@ -7,6 +7,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"strings"
 
 	"github.com/containers/image/v5/docker/reference"
 	"github.com/opencontainers/go-digest"
@@ -219,3 +220,17 @@ func ignoreIsNotFoundErr(err error) error {
 
 	return nil
 }
+
+// Ensures that a user-supplied Containerfile does not contain a FROM
+// instruction, since it is appended to the final stage of the build and must
+// not start a new one.
+func validateContainerfile(containerfile string) error {
+	for _, line := range strings.Split(containerfile, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) > 0 && strings.EqualFold(fields[0], "FROM") {
+			return fmt.Errorf("FROM instructions are not allowed: %q", strings.TrimSpace(line))
+		}
+	}
+
+	return nil
+}
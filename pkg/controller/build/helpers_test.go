@@ -147,3 +147,12 @@ func TestCanonicalizePullSecret(t *testing.T) {
 		})
 	}
 }
+
+// Tests that a user-supplied Containerfile cannot start a new build stage.
+func TestValidateContainerfile(t *testing.T) {
+	t.Parallel()
+
+	assert.NoError(t, validateContainerfile(""))
+	assert.NoError(t, validateContainerfile("RUN dnf install -y tmux\nLABEL from=user"))
+	assert.Error(t, validateContainerfile("RUN true\n  from quay.io/fedora/fedora:latest"))
+}
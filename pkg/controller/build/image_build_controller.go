@@ -152,6 +152,20 @@ func (ctrl *ImageBuildController) syncBuild(key string) error { //nolint:dupl //
 		return nil
 	}
 
+	// The completion deadline of a Build is only counted once its pod has been
+	// scheduled, so builds which are stuck before then are timed out here.
+	switch build.Status.Phase {
+	case buildv1.BuildPhaseNew, buildv1.BuildPhasePending, buildv1.BuildPhaseRunning:
+		timedOut, remaining := checkBuildDeadline(build, time.Now())
+		if timedOut {
+			return ctrl.failTimedOutBuild(build)
+		}
+
+		if remaining > 0 {
+			ctrl.enqueueAfter(build, remaining)
+		}
+	}
+
 	if err := ctrl.buildHandler(build); err != nil {
 		return fmt.Errorf("unable to update with build status: %w", err)
 	}
@@ -161,6 +175,36 @@ func (ctrl *ImageBuildController) syncBuild(key string) error { //nolint:dupl //
 	return nil
 }
 
+// Reports a Build which exceeded its timeout as failed and cancels it.
+func (ctrl *ImageBuildController) failTimedOutBuild(build *buildv1.Build) error {
+	klog.Infof("Build %s exceeded its timeout of %s", build.Name, build.Annotations[buildTimeoutAnnotationKey])
+
+	timedOut := build.DeepCopy()
+	timedOut.Status.Phase = buildv1.BuildPhaseFailed
+	timedOut.Status.Reason = deadlineExceededReason
+	timedOut.Status.Message = getBuildTimedOutReason(build)
+
+	handlerErr := ctrl.buildHandler(timedOut)
+
+	// The build controller may have already removed the Build in order to
+	// retry it.
+	cancelled, err := ctrl.buildclient.BuildV1().Builds(ctrlcommon.MCONamespace).Get(context.TODO(), build.Name, metav1.GetOptions{})
+	if err == nil && !cancelled.Status.Cancelled {
+		cancelled.Status.Cancelled = true
+		_, err = ctrl.buildclient.BuildV1().Builds(ctrlcommon.MCONamespace).Update(context.TODO(), cancelled, metav1.UpdateOptions{})
+	}
+
+	if err != nil && !k8serrors.IsNotFound(err) {
+		return fmt.Errorf("could not cancel timed out build %s: %w", build.Name, err)
+	}
+
+	if handlerErr != nil {
+		return fmt.Errorf("unable to update with build status: %w", handlerErr)
+	}
+
+	return nil
+}
+
 // Starts the Image Build Controller.
 func (ctrl *ImageBuildController) Run(ctx context.Context, workers int) {
 	defer utilruntime.HandleCrash()
@@ -205,6 +249,23 @@ func (ctrl *ImageBuildController) FinalPullspec(pool *mcfgv1.MachineConfigPool)
 	return parseImagePullspec(build.Status.OutputDockerImageReference, build.Status.Output.To.ImageDigest)
 }
 
+// Gets the tail of the logs from the pod which ran the Build.
+func (ctrl *ImageBuildController) BuildLogs(pool *mcfgv1.MachineConfigPool) (string, error) {
+	buildName := newImageBuildRequest(pool).getBuildName()
+
+	build, err := ctrl.buildclient.BuildV1().Builds(ctrlcommon.MCONamespace).Get(context.TODO(), buildName, metav1.GetOptions{})
+	if err != nil {
+		return "", fmt.Errorf("could not get build %s for pool %s: %w", buildName, pool.Name, err)
+	}
+
+	podName, ok := build.Annotations[buildv1.BuildPodNameAnnotation]
+	if !ok {
+		return "", fmt.Errorf("build %s has no build pod", buildName)
+	}
+
+	return getBuildPodLogs(ctrl.kubeclient, podName, "")
+}
+
 // Deletes the underlying Build object.
 func (ctrl *ImageBuildController) DeleteBuildObject(pool *mcfgv1.MachineConfigPool) error {
 	buildName := newImageBuildRequest(pool).getBuildName()
@@ -1,9 +1,12 @@
 package build
 
 import (
+	"crypto/sha256"
 	_ "embed"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"sort"
 	"strings"
 	"text/template"
 
@@ -11,6 +14,7 @@ import (
 	mcfgv1 "github.com/openshift/machine-config-operator/pkg/apis/machineconfiguration.openshift.io/v1"
 	ctrlcommon "github.com/openshift/machine-config-operator/pkg/controller/common"
 	"github.com/openshift/machine-config-operator/test/helpers"
+	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
@@ -19,6 +23,12 @@ const (
 	mcPoolAnnotation          string = "machineconfiguration.openshift.io/pool"
 	machineConfigJSONFilename string = "machineconfig.json.gz"
 	buildahImagePullspec      string = "quay.io/buildah/stable:latest"
+	// Records the hash of the user-supplied Containerfile used for the current
+	// build on the MachineConfigPool so that the build name can be computed
+	// from the pool alone.
+	containerfileHashAnnotationKey string = "machineconfiguration.openshift.io/containerfile-hash"
+	// Where the build inputs are found within the build context.
+	buildInputsDir string = "build-inputs"
 )
 
 //go:embed assets/Dockerfile.on-cluster-build-template
@@ -53,15 +63,113 @@ type ImageBuildRequest struct {
 	FinalImage ImageInfo
 	// The OpenShift release version (derived from the machine-config-osimageurl ConfigMap)
 	ReleaseVersion string
+	// User-supplied Containerfile content appended to the final stage (from the on-cluster-build-config ConfigMap)
+	Containerfile string
+	// The truncated sha256 of the Containerfile content, if any
+	ContainerfileHash string
+	// Additional ConfigMaps and Secrets made available in the build context (from the on-cluster-build-config ConfigMap)
+	BuildInputs BuildInputs
+	// The image builder backend which performs the build (from the on-cluster-build-config ConfigMap); empty selects the default
+	ImageBuilderType ImageBuilderType
+	// The key used to sign the final image, if any (from the on-cluster-build-config ConfigMap)
+	SigningKey ImageSigningKey
+	// The resources and placement of the build pod (from the on-cluster-build-config ConfigMap)
+	BuildPod BuildPodConfig
+}
+
+// Represents the ConfigMaps and Secrets which are placed into the build
+// context under build-inputs/configmaps/<name> and build-inputs/secrets/<name>
+// for use by the user-supplied Containerfile.
+type BuildInputs struct {
+	ConfigMaps []string
+	Secrets    []string
+	// The truncated sha256 of the contents of the ConfigMaps and Secrets, if any
+	Hash string
 }
 
 // Constructs a simple ImageBuildRequest.
 func newImageBuildRequest(pool *mcfgv1.MachineConfigPool) ImageBuildRequest {
 	return ImageBuildRequest{
-		Pool: pool.DeepCopy(),
+		Pool:              pool.DeepCopy(),
+		ContainerfileHash: pool.Annotations[containerfileHashAnnotationKey],
+		ImageBuilderType:  ImageBuilderType(pool.Annotations[imageBuilderTypeAnnotationKey]),
+	}
+}
+
+// Computes the truncated sha256 of the user-supplied Containerfile content.
+// Returns an empty string if no Containerfile was supplied.
+func getContainerfileHash(containerfile string) string {
+	if containerfile == "" {
+		return ""
+	}
+
+	sum := sha256.Sum256([]byte(containerfile))
+	return hex.EncodeToString(sum[:])[:8]
+}
+
+// Populates the build inputs from the on-cluster-build-config ConfigMap.
+func newBuildInputs(onClusterBuildConfigMap *corev1.ConfigMap) BuildInputs {
+	return BuildInputs{
+		ConfigMaps: splitConfigList(onClusterBuildConfigMap.Data[buildInputConfigMapsConfigKey]),
+		Secrets:    splitConfigList(onClusterBuildConfigMap.Data[buildInputSecretsConfigKey]),
 	}
 }
 
+// Computes the truncated sha256 of the names and contents of the build input
+// ConfigMaps and Secrets. Returns an empty string if there are none.
+func getBuildInputsHash(configMaps []*corev1.ConfigMap, secrets []*corev1.Secret) string {
+	if len(configMaps) == 0 && len(secrets) == 0 {
+		return ""
+	}
+
+	h := sha256.New()
+	write := func(kind, name string, data map[string][]byte) {
+		keys := make([]string, 0, len(data))
+		for key := range data {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		fmt.Fprintf(h, "%s/%s\n", kind, name)
+		for _, key := range keys {
+			fmt.Fprintf(h, "%s=%d:", key, len(data[key]))
+			h.Write(data[key])
+		}
+	}
+
+	sortedConfigMaps := append([]*corev1.ConfigMap{}, configMaps...)
+	sort.Slice(sortedConfigMaps, func(i, j int) bool { return sortedConfigMaps[i].Name < sortedConfigMaps[j].Name })
+	for _, cm := range sortedConfigMaps {
+		data := map[string][]byte{}
+		for key, val := range cm.Data {
+			data[key] = []byte(val)
+		}
+		for key, val := range cm.BinaryData {
+			data[key] = val
+		}
+		write("configmaps", cm.Name, data)
+	}
+
+	sortedSecrets := append([]*corev1.Secret{}, secrets...)
+	sort.Slice(sortedSecrets, func(i, j int) bool { return sortedSecrets[i].Name < sortedSecrets[j].Name })
+	for _, secret := range sortedSecrets {
+		write("secrets", secret.Name, secret.Data)
+	}
+
+	return hex.EncodeToString(h.Sum(nil))[:8]
+}
+
+// Splits a comma-separated list of names, ignoring empty entries.
+func splitConfigList(value string) []string {
+	out := []string{}
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
 // Populates the final image info from the on-cluster-build-config ConfigMap.
 func newFinalImageInfo(onClusterBuildConfigMap *corev1.ConfigMap) ImageInfo {
 	return ImageInfo{
@@ -96,12 +204,20 @@ func newExtensionsImageInfo(osImageURLConfigMap, onClusterBuildConfigMap *corev1
 
 // Constructs an ImageBuildRequest with all of the images populated from ConfigMaps
 func newImageBuildRequestWithConfigMap(pool *mcfgv1.MachineConfigPool, osImageURLConfigMap, onClusterBuildConfigMap *corev1.ConfigMap) ImageBuildRequest {
+	// The ConfigMap value has a trailing newline when written as a YAML block
+	// scalar, which should not affect the hash.
+	containerfile := strings.TrimSpace(onClusterBuildConfigMap.Data[containerfileConfigKey])
+
 	return ImageBuildRequest{
-		Pool:            pool.DeepCopy(),
-		BaseImage:       newBaseImageInfo(osImageURLConfigMap, onClusterBuildConfigMap),
-		FinalImage:      newFinalImageInfo(onClusterBuildConfigMap),
-		ExtensionsImage: newExtensionsImageInfo(osImageURLConfigMap, onClusterBuildConfigMap),
-		ReleaseVersion:  osImageURLConfigMap.Data[releaseVersionConfigKey],
+		Pool:              pool.DeepCopy(),
+		BaseImage:         newBaseImageInfo(osImageURLConfigMap, onClusterBuildConfigMap),
+		FinalImage:        newFinalImageInfo(onClusterBuildConfigMap),
+		ExtensionsImage:   newExtensionsImageInfo(osImageURLConfigMap, onClusterBuildConfigMap),
+		ReleaseVersion:    osImageURLConfigMap.Data[releaseVersionConfigKey],
+		Containerfile:     containerfile,
+		ContainerfileHash: getContainerfileHash(containerfile),
+		BuildInputs:       newBuildInputs(onClusterBuildConfigMap),
+		ImageBuilderType:  ImageBuilderType(onClusterBuildConfigMap.Data[imageBuilderTypeConfigKey]),
 	}
 }
 
@@ -140,11 +256,19 @@ func (i ImageBuildRequest) toConfigMap(mc *mcfgv1.MachineConfig) (*corev1.Config
 		return nil, fmt.Errorf("could not compress or encode MachineConfig %s: %w", mc.Name, err)
 	}
 
+	// The build provenance is placed into the final image alongside the
+	// MachineConfig.
+	provenance, err := i.renderProvenance(mc, out)
+	if err != nil {
+		return nil, err
+	}
+
 	configmap := &corev1.ConfigMap{
 		TypeMeta:   metav1.TypeMeta{},
 		ObjectMeta: i.getObjectMeta(i.getMCConfigMapName()),
 		Data: map[string]string{
 			machineConfigJSONFilename: compressed.String(),
+			provenanceFilename:        provenance,
 		},
 	}
 
@@ -184,6 +308,39 @@ func (i ImageBuildRequest) toBuild() *buildv1.Build {
 	// override it via a ConfigMap.
 	dockerfile := "FROM scratch"
 
+	configMapSources := []buildv1.ConfigMapBuildSource{
+		{
+			// Provides the rendered MachineConfig in a gzipped /
+			// base64-encoded format.
+			ConfigMap: corev1.LocalObjectReference{
+				Name: i.getMCConfigMapName(),
+			},
+			DestinationDir: "machineconfig",
+		},
+		{
+			// Provides the rendered Dockerfile.
+			ConfigMap: corev1.LocalObjectReference{
+				Name: i.getDockerfileConfigMapName(),
+			},
+		},
+	}
+
+	// Provides the user-supplied build inputs.
+	for _, name := range i.BuildInputs.ConfigMaps {
+		configMapSources = append(configMapSources, buildv1.ConfigMapBuildSource{
+			ConfigMap:      corev1.LocalObjectReference{Name: name},
+			DestinationDir: getBuildInputDir("configmaps", name),
+		})
+	}
+
+	secretSources := []buildv1.SecretBuildSource{}
+	for _, name := range i.BuildInputs.Secrets {
+		secretSources = append(secretSources, buildv1.SecretBuildSource{
+			Secret:         corev1.LocalObjectReference{Name: name},
+			DestinationDir: getBuildInputDir("secrets", name),
+		})
+	}
+
 	return &buildv1.Build{
 		TypeMeta:   metav1.TypeMeta{},
 		ObjectMeta: i.getObjectMeta(i.getBuildName()),
@@ -194,22 +351,8 @@ func (i ImageBuildRequest) toBuild() *buildv1.Build {
 				Source: buildv1.BuildSource{
 					Type:       buildv1.BuildSourceDockerfile,
 					Dockerfile: &dockerfile,
-					ConfigMaps: []buildv1.ConfigMapBuildSource{
-						{
-							// Provides the rendered MachineConfig in a gzipped /
-							// base64-encoded format.
-							ConfigMap: corev1.LocalObjectReference{
-								Name: i.getMCConfigMapName(),
-							},
-							DestinationDir: "machineconfig",
-						},
-						{
-							// Provides the rendered Dockerfile.
-							ConfigMap: corev1.LocalObjectReference{
-								Name: i.getDockerfileConfigMapName(),
-							},
-						},
-					},
+					ConfigMaps: configMapSources,
+					Secrets:    secretSources,
 				},
 				Strategy: buildv1.BuildStrategy{
 					DockerStrategy: &buildv1.DockerBuildStrategy{
@@ -224,11 +367,12 @@ func (i ImageBuildRequest) toBuild() *buildv1.Build {
 						Name: i.FinalImage.Pullspec,
 						Kind: "DockerImage",
 					},
-					PushSecret: &i.FinalImage.PullSecret,
-					ImageLabels: []buildv1.ImageLabel{
-						{Name: "io.openshift.machineconfig.pool", Value: i.Pool.Name},
-					},
+					PushSecret:  &i.FinalImage.PullSecret,
+					ImageLabels: i.getImageLabels(),
 				},
+				Resources:                 i.BuildPod.Resources,
+				NodeSelector:              i.BuildPod.NodeSelector,
+				CompletionDeadlineSeconds: i.BuildPod.getDeadlineSeconds(),
 			},
 		},
 	}
@@ -240,6 +384,59 @@ func (i ImageBuildRequest) toBuildPod() *corev1.Pod {
 	return i.toBuildahPod()
 }
 
+// Creates a Kubernetes Job which builds the final OS image with Buildah as a
+// non-root user. The pod template reuses the custom build pod and configures
+// Buildah to build without any privileges. It also asks for a user namespace
+// of its own, which only takes effect on clusters with the alpha
+// UserNamespacesSupport feature gate enabled; elsewhere the API server drops
+// hostUsers. The pod template does not carry all of the OS build labels so
+// that the pods created by the Job are not mistaken for custom build pods.
+func (i ImageBuildRequest) toBuildJob() *batchv1.Job {
+	podSpec := i.toBuildahPod().Spec
+
+	hostUsers := false
+	podSpec.HostUsers = &hostUsers
+
+	runAsNonRoot := true
+	for n := range podSpec.Containers {
+		podSpec.Containers[n].SecurityContext.RunAsNonRoot = &runAsNonRoot
+
+		if podSpec.Containers[n].Name == "image-build" {
+			podSpec.Containers[n].Env = append(podSpec.Containers[n].Env,
+				corev1.EnvVar{Name: "BUILDAH_ISOLATION", Value: "chroot"},
+				corev1.EnvVar{Name: "STORAGE_DRIVER", Value: "vfs"},
+			)
+		}
+	}
+
+	// Failed builds are retried by the build controller (see buildRetries), so
+	// the Job should not retry on its own.
+	var backoffLimit int32 = 0
+
+	return &batchv1.Job{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "batch/v1",
+			Kind:       "Job",
+		},
+		ObjectMeta: i.getObjectMeta(i.getBuildName()),
+		Spec: batchv1.JobSpec{
+			BackoffLimit: &backoffLimit,
+			// Unlike the deadline of the pod, this includes the time it takes to
+			// schedule the pod.
+			ActiveDeadlineSeconds: i.BuildPod.getDeadlineSeconds(),
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: map[string]string{
+						targetMachineConfigPoolLabel: i.Pool.Name,
+						desiredConfigLabel:           i.Pool.Spec.Configuration.Name,
+					},
+				},
+				Spec: podSpec,
+			},
+		},
+	}
+}
+
 // This reflects an attempt to use Podman to perform the OS build.
 // Unfortunately, it was difficult to get this to run unprivileged and I was
 // not able to figure out a solution. Nevertheless, I will leave it here for
@@ -472,6 +669,9 @@ func (i ImageBuildRequest) toBuildahPod() *corev1.Pod {
 		},
 	}
 
+	buildInputVolumes, buildInputVolumeMounts := i.getBuildInputVolumes()
+	signingKeyVolumes, signingKeyVolumeMounts, signingKeyEnv := i.getSigningKeyVolumes()
+
 	// TODO: We need pull creds with permissions to pull the base image. By
 	// default, none of the MCO pull secrets can directly pull it. We can use the
 	// pull-secret creds from openshift-config to do that, though we'll need to
@@ -491,11 +691,12 @@ func (i ImageBuildRequest) toBuildahPod() *corev1.Pod {
 					Name: "image-build",
 					// TODO: Figure out how to not hard-code this here.
 					Image:           buildahImagePullspec,
-					Env:             env,
+					Env:             append(env, signingKeyEnv...),
 					Command:         append(command, buildahBuildScript),
 					ImagePullPolicy: corev1.PullAlways,
 					SecurityContext: securityContext,
-					VolumeMounts:    volumeMounts,
+					VolumeMounts:    append(append(volumeMounts, buildInputVolumeMounts...), signingKeyVolumeMounts...),
+					Resources:       i.BuildPod.Resources,
 				},
 				{
 					// This container waits for the aforementioned container to finish
@@ -512,8 +713,11 @@ func (i ImageBuildRequest) toBuildahPod() *corev1.Pod {
 					VolumeMounts:    volumeMounts,
 				},
 			},
-			ServiceAccountName: "machine-os-builder",
-			Volumes: []corev1.Volume{
+			ServiceAccountName:    "machine-os-builder",
+			NodeSelector:          i.BuildPod.NodeSelector,
+			Tolerations:           i.BuildPod.Tolerations,
+			ActiveDeadlineSeconds: i.BuildPod.getDeadlineSeconds(),
+			Volumes: append([]corev1.Volume{
 				{
 					// Provides the rendered Dockerfile.
 					Name: "dockerfile",
@@ -578,14 +782,124 @@ func (i ImageBuildRequest) toBuildahPod() *corev1.Pod {
 						},
 					},
 				},
-			},
+			}, append(buildInputVolumes, signingKeyVolumes...)...),
 		},
 	}
 }
 
+// Creates the volumes and volume mounts which provide the user-supplied build
+// inputs to the build pod. The build script copies them into the build context.
+func (i ImageBuildRequest) getBuildInputVolumes() ([]corev1.Volume, []corev1.VolumeMount) {
+	volumes := []corev1.Volume{}
+	volumeMounts := []corev1.VolumeMount{}
+
+	for n, name := range i.BuildInputs.ConfigMaps {
+		volumeName := fmt.Sprintf("build-input-configmap-%d", n)
+		volumes = append(volumes, corev1.Volume{
+			Name: volumeName,
+			VolumeSource: corev1.VolumeSource{
+				ConfigMap: &corev1.ConfigMapVolumeSource{
+					LocalObjectReference: corev1.LocalObjectReference{Name: name},
+				},
+			},
+		})
+		volumeMounts = append(volumeMounts, corev1.VolumeMount{
+			Name:      volumeName,
+			MountPath: "/tmp/" + getBuildInputDir("configmaps", name),
+		})
+	}
+
+	for n, name := range i.BuildInputs.Secrets {
+		volumeName := fmt.Sprintf("build-input-secret-%d", n)
+		volumes = append(volumes, corev1.Volume{
+			Name: volumeName,
+			VolumeSource: corev1.VolumeSource{
+				Secret: &corev1.SecretVolumeSource{SecretName: name},
+			},
+		})
+		volumeMounts = append(volumeMounts, corev1.VolumeMount{
+			Name:      volumeName,
+			MountPath: "/tmp/" + getBuildInputDir("secrets", name),
+			ReadOnly:  true,
+		})
+	}
+
+	return volumes, volumeMounts
+}
+
+// Computes the directory of a build input relative to the build context.
+func getBuildInputDir(kind, name string) string {
+	return fmt.Sprintf("%s/%s/%s", buildInputsDir, kind, name)
+}
+
+// Gets the labels applied to the final image by the OpenShift Image Builder.
+// The custom build pod gets its labels from the Dockerfile.
+func (i ImageBuildRequest) getImageLabels() []buildv1.ImageLabel {
+	labels := []buildv1.ImageLabel{
+		{Name: "io.openshift.machineconfig.pool", Value: i.Pool.Name},
+	}
+
+	if i.ContainerfileHash != "" {
+		labels = append(labels, buildv1.ImageLabel{Name: "containerfileHash", Value: i.ContainerfileHash})
+	}
+
+	if i.BuildInputs.Hash != "" {
+		labels = append(labels, buildv1.ImageLabel{Name: "buildInputsHash", Value: i.BuildInputs.Hash})
+	}
+
+	return labels
+}
+
+// Returns the labels which the rendered Dockerfile sets on the final image.
+// An existing image with all of these labels was built from the same inputs
+// and can be reused instead of performing a new build.
+func (i ImageBuildRequest) getExpectedImageLabels() map[string]string {
+	labels := map[string]string{
+		"machineconfig":        i.Pool.Spec.Configuration.Name,
+		"machineconfigpool":    i.Pool.Name,
+		"releaseversion":       i.ReleaseVersion,
+		"baseOSContainerImage": i.BaseImage.Pullspec,
+	}
+
+	if i.ContainerfileHash != "" {
+		labels["containerfileHash"] = i.ContainerfileHash
+	}
+
+	if i.BuildInputs.Hash != "" {
+		labels["buildInputsHash"] = i.BuildInputs.Hash
+	}
+
+	// Only images which were signed with the same key can be reused.
+	if i.SigningKey.PublicKeyHash != "" {
+		labels["signingKeyHash"] = i.SigningKey.PublicKeyHash
+	}
+
+	return labels
+}
+
+// Determines whether the labels of an existing image match this build
+// request. An image built with a Containerfile or build inputs does not match
+// a request without them, and vice versa.
+func (i ImageBuildRequest) matchesImageLabels(labels map[string]string) bool {
+	expected := i.getExpectedImageLabels()
+	for key, val := range expected {
+		if labels[key] != val {
+			return false
+		}
+	}
+
+	for _, key := range []string{"containerfileHash", "buildInputsHash"} {
+		if _, ok := expected[key]; !ok && labels[key] != "" {
+			return false
+		}
+	}
+
+	return true
+}
+
 // Constructs a common metav1.ObjectMeta object with the namespace, labels, and annotations set.
 func (i ImageBuildRequest) getObjectMeta(name string) metav1.ObjectMeta {
-	return metav1.ObjectMeta{
+	objectMeta := metav1.ObjectMeta{
 		Name:      name,
 		Namespace: ctrlcommon.MCONamespace,
 		Labels: map[string]string{
@@ -597,6 +911,12 @@ func (i ImageBuildRequest) getObjectMeta(name string) metav1.ObjectMeta {
 			mcPoolAnnotation: "",
 		},
 	}
+
+	if i.BuildPod.Timeout != 0 {
+		objectMeta.Annotations[buildTimeoutAnnotationKey] = i.BuildPod.Timeout.String()
+	}
+
+	return objectMeta
 }
 
 // Computes the Dockerfile ConfigMap name based upon the MachineConfigPool name.
@@ -609,8 +929,13 @@ func (i ImageBuildRequest) getMCConfigMapName() string {
 	return fmt.Sprintf("mc-%s", i.Pool.Spec.Configuration.Name)
 }
 
-// Computes the build name based upon the MachineConfigPool name.
+// Computes the build name based upon the MachineConfigPool name and the hash
+// of the user-supplied Containerfile, if any.
 func (i ImageBuildRequest) getBuildName() string {
+	if i.ContainerfileHash != "" {
+		return fmt.Sprintf("build-%s-%s", i.Pool.Spec.Configuration.Name, i.ContainerfileHash)
+	}
+
 	return fmt.Sprintf("build-%s", i.Pool.Spec.Configuration.Name)
 }
 
@@ -3,7 +3,11 @@ package build
 import (
 	"testing"
 
+	buildv1 "github.com/openshift/api/build/v1"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 // Tests that Image Build Requests is constructed as expected and does a
@@ -78,3 +82,143 @@ func TestImageBuildRequestMissingExtensionsImage(t *testing.T) {
 
 	assert.NotContains(t, dockerfile, "AS extensions")
 }
+
+// Tests that the user-supplied Containerfile and build inputs are wired into
+// the Dockerfile, the build objects and the build name.
+func TestImageBuildRequestWithContainerfile(t *testing.T) {
+	t.Parallel()
+
+	mcp := newMachineConfigPool("worker", "rendered-worker-1")
+
+	osImageURLConfigMap := getOSImageURLConfigMap()
+	onClusterBuildConfigMap := getOnClusterBuildConfigMap()
+
+	containerfile := "RUN dnf install -y tmux\nCOPY ./build-inputs/configmaps/certs/ca.pem /etc/pki/ca-trust/source/anchors/"
+	onClusterBuildConfigMap.Data[containerfileConfigKey] = containerfile + "\n"
+	onClusterBuildConfigMap.Data[buildInputConfigMapsConfigKey] = "certs, "
+	onClusterBuildConfigMap.Data[buildInputSecretsConfigKey] = "agent-token"
+
+	ibr := newImageBuildRequestWithConfigMap(mcp, osImageURLConfigMap, onClusterBuildConfigMap)
+
+	assert.Equal(t, containerfile, ibr.Containerfile)
+	assert.Len(t, ibr.ContainerfileHash, 8)
+	assert.Equal(t, BuildInputs{ConfigMaps: []string{"certs"}, Secrets: []string{"agent-token"}}, ibr.BuildInputs)
+
+	dockerfile, err := ibr.renderDockerfile()
+	assert.NoError(t, err)
+	assert.Contains(t, dockerfile, containerfile)
+	assert.Contains(t, dockerfile, "LABEL containerfileHash="+ibr.ContainerfileHash)
+
+	assert.Equal(t, "build-rendered-worker-1-"+ibr.ContainerfileHash, ibr.getBuildName())
+
+	// The build name can be computed from the pool once the hash is recorded.
+	mcp.Annotations = map[string]string{containerfileHashAnnotationKey: ibr.ContainerfileHash}
+	assert.Equal(t, ibr.getBuildName(), newImageBuildRequest(mcp).getBuildName())
+
+	build := ibr.toBuild()
+	assert.Contains(t, build.Spec.Source.ConfigMaps, buildv1.ConfigMapBuildSource{
+		ConfigMap:      corev1.LocalObjectReference{Name: "certs"},
+		DestinationDir: "build-inputs/configmaps/certs",
+	})
+	assert.Contains(t, build.Spec.Source.Secrets, buildv1.SecretBuildSource{
+		Secret:         corev1.LocalObjectReference{Name: "agent-token"},
+		DestinationDir: "build-inputs/secrets/agent-token",
+	})
+	assert.Contains(t, build.Spec.Output.ImageLabels, buildv1.ImageLabel{Name: "containerfileHash", Value: ibr.ContainerfileHash})
+
+	pod := ibr.toBuildPod()
+	mountPaths := []string{}
+	for _, volumeMount := range pod.Spec.Containers[0].VolumeMounts {
+		mountPaths = append(mountPaths, volumeMount.MountPath)
+	}
+	assert.Contains(t, mountPaths, "/tmp/build-inputs/configmaps/certs")
+	assert.Contains(t, mountPaths, "/tmp/build-inputs/secrets/agent-token")
+	assert.Len(t, pod.Spec.Volumes, 7)
+}
+
+// Tests that only images built from the same inputs match the build request.
+func TestImageBuildRequestMatchesImageLabels(t *testing.T) {
+	t.Parallel()
+
+	mcp := newMachineConfigPool("worker", "rendered-worker-1")
+	ibr := newImageBuildRequestWithConfigMap(mcp, getOSImageURLConfigMap(), getOnClusterBuildConfigMap())
+
+	withLabel := func(key, val string) map[string]string {
+		labels := ibr.getExpectedImageLabels()
+		labels[key] = val
+		return labels
+	}
+
+	testCases := []struct {
+		name     string
+		labels   map[string]string
+		expected bool
+	}{
+		{
+			name:     "Same inputs",
+			labels:   ibr.getExpectedImageLabels(),
+			expected: true,
+		},
+		{
+			name:     "Extra unrelated label",
+			labels:   withLabel("io.openshift.machineconfig.pool", "worker"),
+			expected: true,
+		},
+		{
+			name:   "Different rendered MachineConfig",
+			labels: withLabel("machineconfig", "rendered-worker-2"),
+		},
+		{
+			name:   "Different base OS image",
+			labels: withLabel("baseOSContainerImage", "registry.hostname.com/org/os:other"),
+		},
+		{
+			name:   "Built with a Containerfile",
+			labels: withLabel("containerfileHash", "abcd1234"),
+		},
+		{
+			name:   "Built with build inputs",
+			labels: withLabel("buildInputsHash", "abcd1234"),
+		},
+		{
+			name: "No labels",
+		},
+	}
+
+	for _, testCase := range testCases {
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, testCase.expected, ibr.matchesImageLabels(testCase.labels))
+		})
+	}
+}
+
+// Tests that the build inputs hash changes with the contents of the build input
+// ConfigMaps and Secrets, but not with their order.
+func TestGetBuildInputsHash(t *testing.T) {
+	t.Parallel()
+
+	certs := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "certs"}, Data: map[string]string{"ca.crt": "ca"}}
+	certsChanged := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "certs"}, Data: map[string]string{"ca.crt": "new-ca"}}
+	repos := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "repos"}, Data: map[string]string{"extra.repo": "[extra]"}}
+	token := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "agent-token"}, Data: map[string][]byte{"token": []byte("secret")}}
+	tokenChanged := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "agent-token"}, Data: map[string][]byte{"token": []byte("rotated")}}
+
+	assert.Equal(t, "", getBuildInputsHash(nil, nil))
+
+	hash := getBuildInputsHash([]*corev1.ConfigMap{certs, repos}, []*corev1.Secret{token})
+	assert.Len(t, hash, 8)
+	assert.Equal(t, hash, getBuildInputsHash([]*corev1.ConfigMap{repos, certs}, []*corev1.Secret{token}))
+	assert.NotEqual(t, hash, getBuildInputsHash([]*corev1.ConfigMap{certsChanged, repos}, []*corev1.Secret{token}))
+	assert.NotEqual(t, hash, getBuildInputsHash([]*corev1.ConfigMap{certs, repos}, []*corev1.Secret{tokenChanged}))
+	assert.NotEqual(t, hash, getBuildInputsHash([]*corev1.ConfigMap{certs}, []*corev1.Secret{token}))
+
+	ibr := newImageBuildRequestWithConfigMap(newMachineConfigPool("worker", "rendered-worker-1"), getOSImageURLConfigMap(), getOnClusterBuildConfigMap())
+	ibr.BuildInputs.Hash = hash
+	assert.Equal(t, hash, ibr.getExpectedImageLabels()["buildInputsHash"])
+	dockerfile, err := ibr.renderDockerfile()
+	require.NoError(t, err)
+	assert.Contains(t, dockerfile, "LABEL buildInputsHash="+hash)
+}
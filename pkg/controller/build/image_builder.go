@@ -0,0 +1,195 @@
+package build
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	mcfgv1 "github.com/openshift/machine-config-operator/pkg/apis/machineconfiguration.openshift.io/v1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// Records which image builder backend performs the current build on the
+// MachineConfigPool so that the build can be looked up, cleaned up, etc. from
+// the pool alone.
+const imageBuilderTypeAnnotationKey string = "machineconfiguration.openshift.io/image-builder-type"
+
+// Identifies an image builder backend. It is selected with the
+// imageBuilderType key of the on-cluster-build-config ConfigMap.
+type ImageBuilderType string
+
+const (
+	// Builds the image using the OpenShift Build API.
+	OpenShiftImageBuilder ImageBuilderType = "openshift-image-builder"
+	// Builds the image using Buildah in a custom build pod.
+	CustomPodImageBuilder ImageBuilderType = "custom-pod-builder"
+	// Builds the image using Buildah as a non-root user in a Kubernetes Job.
+	JobImageBuilder ImageBuilderType = "job-builder"
+)
+
+// Determines whether the given type names a known image builder backend.
+func isValidImageBuilderType(builderType ImageBuilderType) bool {
+	switch builderType {
+	case OpenShiftImageBuilder, CustomPodImageBuilder, JobImageBuilder:
+		return true
+	default:
+		return false
+	}
+}
+
+// ImageBuilder is implemented by each image builder backend. The build
+// controller decides when a build is needed and prepares its inputs (the
+// rendered MachineConfig and Dockerfile ConfigMaps); the backend is only
+// responsible for running the build and reporting on it.
+//
+// Backends are keyed by the build name of the MachineConfigPool (see
+// ImageBuildRequest.getBuildName()), so that every method except StartBuild
+// can locate the build from the pool alone. A backend reports the progress of
+// the build back to the build controller with a status handler provided at
+// construction time, which it calls whenever the build changes state.
+type ImageBuilder interface {
+	// Starts the backend. Blocks until the context is cancelled.
+	Run(context.Context, int)
+	// Starts a build for the given request, returning a reference to the
+	// object performing it. If the build is already running, a reference to the
+	// existing object is returned instead.
+	StartBuild(ImageBuildRequest) (*corev1.ObjectReference, error)
+	// Determines whether a build exists for the given pool.
+	IsBuildRunning(*mcfgv1.MachineConfigPool) (bool, error)
+	// Deletes the build and any ephemeral objects the backend created for it.
+	// Objects which are already gone are ignored.
+	DeleteBuildObject(*mcfgv1.MachineConfigPool) error
+	// Gets the digested pullspec of the image produced by a successful build.
+	FinalPullspec(*mcfgv1.MachineConfigPool) (string, error)
+	// Gets the tail of the build logs.
+	BuildLogs(*mcfgv1.MachineConfigPool) (string, error)
+}
+
+// Represents the backend-neutral state of an image build.
+type buildState string
+
+const (
+	buildStatePending   buildState = "Pending"
+	buildStateRunning   buildState = "Running"
+	buildStateSucceeded buildState = "Succeeded"
+	buildStateFailed    buildState = "Failed"
+)
+
+// Represents the state of an image build as reported by an ImageBuilder backend.
+type buildStatus struct {
+	// The name of the MachineConfigPool the build is for.
+	PoolName string
+	// The current state of the build. Unknown states are ignored.
+	State buildState
+	// A reference to the object performing the build.
+	ObjectRef *corev1.ObjectReference
+	// Describes why the build failed.
+	FailureReason string
+	// Whether the build failed because it exceeded its timeout.
+	TimedOut bool
+}
+
+// Holds each of the image builder backends which the build controller may
+// use and routes each call to the appropriate one. The backend is chosen by
+// the ImageBuildRequest when starting a build and by the MachineConfigPool
+// annotation thereafter.
+type imageBuilderSet struct {
+	builders    map[ImageBuilderType]ImageBuilder
+	defaultType ImageBuilderType
+}
+
+var _ ImageBuilder = (*imageBuilderSet)(nil)
+
+// Creates an imageBuilderSet which uses the given default backend unless
+// another is selected.
+func newImageBuilderSet(defaultType ImageBuilderType, builders map[ImageBuilderType]ImageBuilder) *imageBuilderSet {
+	return &imageBuilderSet{
+		builders:    builders,
+		defaultType: defaultType,
+	}
+}
+
+// Gets the backend for the given type. An empty type selects the default backend.
+func (s *imageBuilderSet) get(builderType ImageBuilderType) (ImageBuilder, error) {
+	if builderType == "" {
+		builderType = s.defaultType
+	}
+
+	builder, ok := s.builders[builderType]
+	if !ok {
+		return nil, fmt.Errorf("image builder %q is not enabled, expected one of: %s", builderType, strings.Join(s.types(), ", "))
+	}
+
+	return builder, nil
+}
+
+// Gets the backend which performs the build for the given MachineConfigPool.
+func (s *imageBuilderSet) forPool(pool *mcfgv1.MachineConfigPool) (ImageBuilder, error) {
+	return s.get(newImageBuildRequest(pool).ImageBuilderType)
+}
+
+// Lists the enabled backend types in a stable order.
+func (s *imageBuilderSet) types() []string {
+	out := []string{}
+	for builderType := range s.builders {
+		out = append(out, string(builderType))
+	}
+
+	sort.Strings(out)
+	return out
+}
+
+// Starts each of the backends.
+func (s *imageBuilderSet) Run(ctx context.Context, workers int) {
+	for _, builder := range s.builders {
+		go builder.Run(ctx, workers)
+	}
+
+	<-ctx.Done()
+}
+
+func (s *imageBuilderSet) StartBuild(ibr ImageBuildRequest) (*corev1.ObjectReference, error) {
+	builder, err := s.get(ibr.ImageBuilderType)
+	if err != nil {
+		return nil, err
+	}
+
+	return builder.StartBuild(ibr)
+}
+
+func (s *imageBuilderSet) IsBuildRunning(pool *mcfgv1.MachineConfigPool) (bool, error) {
+	builder, err := s.forPool(pool)
+	if err != nil {
+		return false, err
+	}
+
+	return builder.IsBuildRunning(pool)
+}
+
+func (s *imageBuilderSet) DeleteBuildObject(pool *mcfgv1.MachineConfigPool) error {
+	builder, err := s.forPool(pool)
+	if err != nil {
+		return err
+	}
+
+	return builder.DeleteBuildObject(pool)
+}
+
+func (s *imageBuilderSet) FinalPullspec(pool *mcfgv1.MachineConfigPool) (string, error) {
+	builder, err := s.forPool(pool)
+	if err != nil {
+		return "", err
+	}
+
+	return builder.FinalPullspec(pool)
+}
+
+func (s *imageBuilderSet) BuildLogs(pool *mcfgv1.MachineConfigPool) (string, error) {
+	builder, err := s.forPool(pool)
+	if err != nil {
+		return "", err
+	}
+
+	return builder.BuildLogs(pool)
+}
@@ -0,0 +1,281 @@
+package build
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	mcfgv1 "github.com/openshift/machine-config-operator/pkg/apis/machineconfiguration.openshift.io/v1"
+	ctrlcommon "github.com/openshift/machine-config-operator/pkg/controller/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+const fakeImageBuilderType ImageBuilderType = "fake-builder"
+
+// An in-memory ImageBuilder backend which allows the build controller to be
+// tested without any build objects. Tests drive each build through its states
+// and the fake reports them to the build controller.
+type fakeImageBuilder struct {
+	mu            sync.Mutex
+	builds        map[string]*fakeBuild
+	statusHandler func(buildStatus) error
+}
+
+type fakeBuild struct {
+	ibr   ImageBuildRequest
+	state buildState
+}
+
+var _ ImageBuilder = (*fakeImageBuilder)(nil)
+
+func newFakeImageBuilder(statusHandler func(buildStatus) error) *fakeImageBuilder {
+	return &fakeImageBuilder{
+		builds:        map[string]*fakeBuild{},
+		statusHandler: statusHandler,
+	}
+}
+
+func (f *fakeImageBuilder) objectRef(name string) *corev1.ObjectReference {
+	return &corev1.ObjectReference{
+		Kind:      "FakeBuild",
+		Name:      name,
+		Namespace: ctrlcommon.MCONamespace,
+	}
+}
+
+func (f *fakeImageBuilder) Run(ctx context.Context, _ int) {
+	<-ctx.Done()
+}
+
+func (f *fakeImageBuilder) StartBuild(ibr ImageBuildRequest) (*corev1.ObjectReference, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	name := ibr.getBuildName()
+	if _, ok := f.builds[name]; !ok {
+		f.builds[name] = &fakeBuild{ibr: ibr, state: buildStatePending}
+	}
+
+	return f.objectRef(name), nil
+}
+
+func (f *fakeImageBuilder) IsBuildRunning(pool *mcfgv1.MachineConfigPool) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	_, ok := f.builds[newImageBuildRequest(pool).getBuildName()]
+	return ok, nil
+}
+
+func (f *fakeImageBuilder) DeleteBuildObject(pool *mcfgv1.MachineConfigPool) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	delete(f.builds, newImageBuildRequest(pool).getBuildName())
+	return nil
+}
+
+func (f *fakeImageBuilder) FinalPullspec(pool *mcfgv1.MachineConfigPool) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	name := newImageBuildRequest(pool).getBuildName()
+	build, ok := f.builds[name]
+	if !ok || build.state != buildStateSucceeded {
+		return "", fmt.Errorf("build %s has not succeeded", name)
+	}
+
+	return parseImagePullspec(build.ibr.FinalImage.Pullspec, expectedImageSHA)
+}
+
+func (f *fakeImageBuilder) BuildLogs(pool *mcfgv1.MachineConfigPool) (string, error) {
+	return fmt.Sprintf("fake build logs for %s", newImageBuildRequest(pool).getBuildName()), nil
+}
+
+// Moves the named build to the given state and reports it.
+func (f *fakeImageBuilder) setState(name string, state buildState, failureReason string) error {
+	f.mu.Lock()
+	build, ok := f.builds[name]
+	if ok {
+		build.state = state
+	}
+	f.mu.Unlock()
+
+	if !ok {
+		return fmt.Errorf("build %s not found", name)
+	}
+
+	return f.statusHandler(buildStatus{
+		PoolName:      build.ibr.Pool.Name,
+		State:         state,
+		ObjectRef:     f.objectRef(name),
+		FailureReason: failureReason,
+	})
+}
+
+// Gets the ImageBuildRequest of the named build.
+func (f *fakeImageBuilder) getRequest(name string) (ImageBuildRequest, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	build, ok := f.builds[name]
+	if !ok {
+		return ImageBuildRequest{}, false
+	}
+
+	return build.ibr, true
+}
+
+// Polls until the fake has started the named build.
+func (f *fakeImageBuilder) waitForBuild(ctx context.Context, t *testing.T, name string) {
+	t.Helper()
+
+	err := wait.PollImmediateInfiniteWithContext(ctx, time.Millisecond, func(context.Context) (bool, error) {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+
+		_, ok := f.builds[name]
+		return ok, nil
+	})
+
+	require.NoError(t, err, "build %s was never started", name)
+}
+
+// Starts a build controller which uses the fake image builder.
+func startBuildControllerWithFakeImageBuilder(ctx context.Context, t *testing.T) (*Clients, *fakeImageBuilder) {
+	b := &buildControllerTestFixture{ctx: ctx, t: t}
+	clients := b.setupClients()
+
+	ctrl := newBuildController(b.getConfig(), clients)
+	fake := newFakeImageBuilder(ctrl.buildStatusUpdater)
+	ctrl.imageBuilder = newImageBuilderSet(fakeImageBuilderType, map[ImageBuilderType]ImageBuilder{
+		fakeImageBuilderType: fake,
+	})
+	ctrl.registryImageLookup = noRegistryImageLookup
+
+	go ctrl.Run(ctx, 5)
+
+	return clients, fake
+}
+
+// Tests the build controller against the in-memory image builder backend.
+func TestBuildControllerWithFakeImageBuilder(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Build Succeeds", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+		t.Cleanup(cancel)
+
+		cs, fake := startBuildControllerWithFakeImageBuilder(ctx, t)
+
+		mcp := optInMCP(ctx, t, cs, "worker")
+		name := newImageBuildRequest(mcp).getBuildName()
+		fake.waitForBuild(ctx, t, name)
+
+		assertMachineConfigPoolReachesState(ctx, t, cs, "worker", func(mcp *mcfgv1.MachineConfigPool) bool {
+			return mcfgv1.IsMachineConfigPoolConditionTrue(mcp.Status.Conditions, mcfgv1.MachineConfigPoolBuildPending) &&
+				mcp.Annotations[imageBuilderTypeAnnotationKey] == string(fakeImageBuilderType)
+		})
+
+		require.NoError(t, fake.setState(name, buildStateRunning, ""))
+		assertMachineConfigPoolReachesState(ctx, t, cs, "worker", func(mcp *mcfgv1.MachineConfigPool) bool {
+			return mcfgv1.IsMachineConfigPoolConditionTrue(mcp.Status.Conditions, mcfgv1.MachineConfigPoolBuilding)
+		})
+
+		require.NoError(t, fake.setState(name, buildStateSucceeded, ""))
+		assertMachineConfigPoolReachesState(ctx, t, cs, "worker", isMCPBuildSuccess)
+
+		record, _ := assertBuildRecordReachesResult(ctx, t, cs, newImageBuildRequest(mcp).getBuildRecordConfigMapName(1), BuildResultSucceeded)
+		assert.Equal(t, string(fakeImageBuilderType), record.Builder)
+
+		running, err := fake.IsBuildRunning(mcp)
+		require.NoError(t, err)
+		assert.False(t, running, "expected build to be cleaned up")
+	})
+
+	t.Run("Build Fails", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+		t.Cleanup(cancel)
+
+		cs, fake := startBuildControllerWithFakeImageBuilder(ctx, t)
+
+		mcp := optInMCP(ctx, t, cs, "worker")
+		name := newImageBuildRequest(mcp).getBuildName()
+		fake.waitForBuild(ctx, t, name)
+
+		// The build controller reports a failed build as an error so that the
+		// backend requeues it.
+		assert.Error(t, fake.setState(name, buildStateFailed, "out of disk space"))
+		assertMachineConfigPoolReachesState(ctx, t, cs, "worker", func(mcp *mcfgv1.MachineConfigPool) bool {
+			condition := mcfgv1.GetMachineConfigPoolCondition(mcp.Status, mcfgv1.MachineConfigPoolBuildFailed)
+			return isMCPBuildFailure(mcp) && condition.Message == "out of disk space"
+		})
+	})
+}
+
+// Tests that the imageBuilderSet routes each call to the selected backend.
+func TestImageBuilderSet(t *testing.T) {
+	t.Parallel()
+
+	defaultBuilder := newFakeImageBuilder(nil)
+	otherBuilder := newFakeImageBuilder(nil)
+
+	set := newImageBuilderSet(CustomPodImageBuilder, map[ImageBuilderType]ImageBuilder{
+		CustomPodImageBuilder: defaultBuilder,
+		JobImageBuilder:       otherBuilder,
+	})
+
+	pool := newMachineConfigPool("worker", "rendered-worker-1")
+
+	// A request without a backend goes to the default one.
+	_, err := set.StartBuild(ImageBuildRequest{Pool: pool})
+	require.NoError(t, err)
+	assert.Len(t, defaultBuilder.builds, 1)
+	assert.Len(t, otherBuilder.builds, 0)
+
+	// The pool annotation routes subsequent calls.
+	pool.Annotations = map[string]string{imageBuilderTypeAnnotationKey: string(JobImageBuilder)}
+	running, err := set.IsBuildRunning(pool)
+	require.NoError(t, err)
+	assert.False(t, running)
+
+	_, err = set.StartBuild(ImageBuildRequest{Pool: pool, ImageBuilderType: JobImageBuilder})
+	require.NoError(t, err)
+	assert.Len(t, otherBuilder.builds, 1)
+
+	running, err = set.IsBuildRunning(pool)
+	require.NoError(t, err)
+	assert.True(t, running)
+
+	// Backends which are not enabled are rejected.
+	pool.Annotations[imageBuilderTypeAnnotationKey] = string(OpenShiftImageBuilder)
+	_, err = set.IsBuildRunning(pool)
+	assert.ErrorContains(t, err, `image builder "openshift-image-builder" is not enabled, expected one of: custom-pod-builder, job-builder`)
+
+	_, err = set.StartBuild(ImageBuildRequest{Pool: pool, ImageBuilderType: "kaniko"})
+	assert.Error(t, err)
+}
+
+// Tests that only known backends can be enabled.
+func TestNewWithImageBuilderTypes(t *testing.T) {
+	t.Parallel()
+
+	b := &buildControllerTestFixture{}
+
+	ctrl, err := New(b.getConfig(), b.setupClients(), JobImageBuilder, CustomPodImageBuilder)
+	require.NoError(t, err)
+	assert.Equal(t, JobImageBuilder, ctrl.imageBuilder.defaultType)
+	assert.Equal(t, []string{string(CustomPodImageBuilder), string(JobImageBuilder)}, ctrl.imageBuilder.types())
+
+	_, err = New(b.getConfig(), b.setupClients(), "kaniko")
+	assert.Error(t, err)
+}
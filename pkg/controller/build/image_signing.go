@@ -0,0 +1,219 @@
+package build
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	"github.com/containers/image/v5/docker/reference"
+	mcfgv1 "github.com/openshift/machine-config-operator/pkg/apis/machineconfiguration.openshift.io/v1"
+	ctrlcommon "github.com/openshift/machine-config-operator/pkg/controller/common"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// The keys of the image signing Secret. These match the files written by
+	// "cosign generate-key-pair".
+	signingPrivateKeySecretKey string = "cosign.key"
+	signingPublicKeySecretKey  string = "cosign.pub"
+	signingPassphraseSecretKey string = "cosign.password"
+
+	// Where the signing key is mounted into the image build container.
+	signingKeyMountPath string = "/tmp/signing-key"
+
+	// The name of the build provenance within the MachineConfig ConfigMap and
+	// the build context.
+	provenanceFilename string = "provenance.json"
+
+	// Identifies the format of the build provenance document.
+	provenanceType string = "machineconfiguration.openshift.io/on-cluster-build-provenance/v1"
+)
+
+// Describes the key used to sign the final image.
+type ImageSigningKey struct {
+	// The name of the K8s secret which holds the key pair.
+	SecretName string
+	// The truncated sha256 of the public key; empty when signing is disabled.
+	PublicKeyHash string
+	// Whether the private key is protected by a passphrase.
+	HasPassphrase bool
+}
+
+// Determines whether the final image should be signed.
+func (s ImageSigningKey) isEnabled() bool {
+	return s.SecretName != ""
+}
+
+// Describes the inputs of an on-cluster build. It is placed into the final
+// image so that it is covered by the image signature.
+type BuildProvenance struct {
+	Type                 string   `json:"type"`
+	Pool                 string   `json:"pool"`
+	BuildName            string   `json:"buildName"`
+	Builder              string   `json:"builder"`
+	MachineConfig        string   `json:"machineConfig"`
+	MachineConfigSHA256  string   `json:"machineConfigSHA256"`
+	ReleaseVersion       string   `json:"releaseVersion"`
+	BaseImage            string   `json:"baseImage"`
+	BaseImageDigest      string   `json:"baseImageDigest,omitempty"`
+	ExtensionsImage      string   `json:"extensionsImage,omitempty"`
+	Extensions           []string `json:"extensions,omitempty"`
+	ContainerfileHash    string   `json:"containerfileHash,omitempty"`
+	BuildInputConfigMaps []string `json:"buildInputConfigMaps,omitempty"`
+	BuildInputSecrets    []string `json:"buildInputSecrets,omitempty"`
+}
+
+// Creates the build provenance for the given Image Build Request and the
+// rendered MachineConfig it builds.
+func newBuildProvenance(ibr ImageBuildRequest, mc *mcfgv1.MachineConfig, mcJSON []byte) BuildProvenance {
+	sum := sha256.Sum256(mcJSON)
+
+	return BuildProvenance{
+		Type:                 provenanceType,
+		Pool:                 ibr.Pool.Name,
+		BuildName:            ibr.getBuildName(),
+		Builder:              string(ibr.ImageBuilderType),
+		MachineConfig:        mc.Name,
+		MachineConfigSHA256:  hex.EncodeToString(sum[:]),
+		ReleaseVersion:       ibr.ReleaseVersion,
+		BaseImage:            ibr.BaseImage.Pullspec,
+		BaseImageDigest:      getImageDigest(ibr.BaseImage.Pullspec),
+		ExtensionsImage:      ibr.ExtensionsImage.Pullspec,
+		Extensions:           mc.Spec.Extensions,
+		ContainerfileHash:    ibr.ContainerfileHash,
+		BuildInputConfigMaps: ibr.BuildInputs.ConfigMaps,
+		BuildInputSecrets:    ibr.BuildInputs.Secrets,
+	}
+}
+
+// Encodes the build provenance for the given Image Build Request.
+func (i ImageBuildRequest) renderProvenance(mc *mcfgv1.MachineConfig, mcJSON []byte) (string, error) {
+	out, err := json.MarshalIndent(newBuildProvenance(i, mc, mcJSON), "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("could not encode build provenance: %w", err)
+	}
+
+	return string(out), nil
+}
+
+// Gets the digest of a digested image pullspec. Returns an empty string if
+// the pullspec is not digested.
+func getImageDigest(pullspec string) string {
+	named, err := reference.ParseNamed(pullspec)
+	if err != nil {
+		return ""
+	}
+
+	digested, ok := named.(reference.Digested)
+	if !ok {
+		return ""
+	}
+
+	return digested.Digest().String()
+}
+
+// Creates the volume, volume mount, and environment variables which provide
+// the signing key to the image build container. Returns nothing when signing
+// is disabled.
+func (i ImageBuildRequest) getSigningKeyVolumes() ([]corev1.Volume, []corev1.VolumeMount, []corev1.EnvVar) {
+	if !i.SigningKey.isEnabled() {
+		return nil, nil, nil
+	}
+
+	items := []corev1.KeyToPath{
+		{Key: signingPrivateKeySecretKey, Path: signingPrivateKeySecretKey},
+	}
+
+	env := []corev1.EnvVar{
+		{Name: "SIGNING_KEY", Value: signingKeyMountPath + "/" + signingPrivateKeySecretKey},
+	}
+
+	if i.SigningKey.HasPassphrase {
+		items = append(items, corev1.KeyToPath{Key: signingPassphraseSecretKey, Path: signingPassphraseSecretKey})
+		env = append(env, corev1.EnvVar{Name: "SIGNING_PASSPHRASE", Value: signingKeyMountPath + "/" + signingPassphraseSecretKey})
+	}
+
+	volumes := []corev1.Volume{
+		{
+			// Provides the key used to sign the final OS image.
+			Name: "signing-key",
+			VolumeSource: corev1.VolumeSource{
+				Secret: &corev1.SecretVolumeSource{
+					SecretName: i.SigningKey.SecretName,
+					Items:      items,
+				},
+			},
+		},
+	}
+
+	volumeMounts := []corev1.VolumeMount{
+		{
+			Name:      "signing-key",
+			MountPath: signingKeyMountPath,
+			ReadOnly:  true,
+		},
+	}
+
+	return volumes, volumeMounts, env
+}
+
+// Gets the image signing key named in the on-cluster-build-config ConfigMap.
+// Returns an empty ImageSigningKey if signing is not configured.
+func (ctrl *Controller) getImageSigningKey(onClusterBuildConfigMap *corev1.ConfigMap) (ImageSigningKey, error) {
+	name := onClusterBuildConfigMap.Data[imageSigningKeySecretNameConfigKey]
+	if name == "" {
+		return ImageSigningKey{}, nil
+	}
+
+	secret, err := ctrl.kubeclient.CoreV1().Secrets(ctrlcommon.MCONamespace).Get(context.TODO(), name, metav1.GetOptions{})
+	if err != nil {
+		return ImageSigningKey{}, fmt.Errorf("could not get image signing key secret %q: %w", name, err)
+	}
+
+	return newImageSigningKey(secret)
+}
+
+// Validates an image signing key Secret.
+func newImageSigningKey(secret *corev1.Secret) (ImageSigningKey, error) {
+	for _, key := range []string{signingPrivateKeySecretKey, signingPublicKeySecretKey} {
+		if len(secret.Data[key]) == 0 {
+			return ImageSigningKey{}, fmt.Errorf("image signing key secret %q is missing key %q", secret.Name, key)
+		}
+	}
+
+	sum := sha256.Sum256(secret.Data[signingPublicKeySecretKey])
+
+	return ImageSigningKey{
+		SecretName:    secret.Name,
+		PublicKeyHash: hex.EncodeToString(sum[:])[:8],
+		HasPassphrase: len(secret.Data[signingPassphraseSecretKey]) != 0,
+	}, nil
+}
+
+// Ensures that the image signing key, if any, is usable and that the selected
+// image builder is able to sign images.
+func (ctrl *Controller) validateImageSigning(onClusterBuildConfigMap *corev1.ConfigMap) error {
+	signingKey, err := ctrl.getImageSigningKey(onClusterBuildConfigMap)
+	if err != nil {
+		return err
+	}
+
+	if !signingKey.isEnabled() {
+		return nil
+	}
+
+	builderType := ImageBuilderType(onClusterBuildConfigMap.Data[imageBuilderTypeConfigKey])
+	if builderType == "" {
+		builderType = ctrl.imageBuilder.defaultType
+	}
+
+	// The OpenShift Build API pushes the image itself and has no way to sign it.
+	if builderType == OpenShiftImageBuilder {
+		return fmt.Errorf("image signing is not supported by the %s image builder", builderType)
+	}
+
+	return nil
+}
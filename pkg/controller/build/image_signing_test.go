@@ -0,0 +1,253 @@
+package build
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	mcfgv1 "github.com/openshift/machine-config-operator/pkg/apis/machineconfiguration.openshift.io/v1"
+	ctrlcommon "github.com/openshift/machine-config-operator/pkg/controller/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func newImageSigningKeySecret(name string, data map[string]string) *corev1.Secret {
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: ctrlcommon.MCONamespace,
+		},
+		Data: map[string][]byte{},
+	}
+
+	for key, val := range data {
+		secret.Data[key] = []byte(val)
+	}
+
+	return secret
+}
+
+// Tests that the image signing key Secret is validated.
+func TestNewImageSigningKey(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name        string
+		data        map[string]string
+		errExpected bool
+		passphrase  bool
+	}{
+		{
+			name: "Key pair",
+			data: map[string]string{
+				signingPrivateKeySecretKey: "private",
+				signingPublicKeySecretKey:  "public",
+			},
+		},
+		{
+			name: "Key pair with passphrase",
+			data: map[string]string{
+				signingPrivateKeySecretKey: "private",
+				signingPublicKeySecretKey:  "public",
+				signingPassphraseSecretKey: "s3kr1t",
+			},
+			passphrase: true,
+		},
+		{
+			name: "Missing public key",
+			data: map[string]string{
+				signingPrivateKeySecretKey: "private",
+			},
+			errExpected: true,
+		},
+		{
+			name: "Missing private key",
+			data: map[string]string{
+				signingPublicKeySecretKey: "public",
+			},
+			errExpected: true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			signingKey, err := newImageSigningKey(newImageSigningKeySecret("signing-key", testCase.data))
+			if testCase.errExpected {
+				assert.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, "signing-key", signingKey.SecretName)
+			assert.Len(t, signingKey.PublicKeyHash, 8)
+			assert.Equal(t, testCase.passphrase, signingKey.HasPassphrase)
+		})
+	}
+}
+
+// Tests that the signing key is wired into the image build container, the
+// Dockerfile and the expected image labels.
+func TestImageBuildRequestWithSigningKey(t *testing.T) {
+	t.Parallel()
+
+	mcp := newMachineConfigPool("worker", "rendered-worker-1")
+	ibr := newImageBuildRequestWithConfigMap(mcp, getOSImageURLConfigMap(), getOnClusterBuildConfigMap())
+
+	ibr.SigningKey = ImageSigningKey{
+		SecretName:    "signing-key",
+		PublicKeyHash: "abcd1234",
+		HasPassphrase: true,
+	}
+
+	dockerfile, err := ibr.renderDockerfile()
+	require.NoError(t, err)
+	assert.Contains(t, dockerfile, "LABEL signingKeyHash=abcd1234")
+	assert.Equal(t, "abcd1234", ibr.getExpectedImageLabels()["signingKeyHash"])
+
+	// An unsigned image cannot be reused when signing is required.
+	unsigned := ibr.getExpectedImageLabels()
+	delete(unsigned, "signingKeyHash")
+	assert.False(t, ibr.matchesImageLabels(unsigned))
+
+	for _, builder := range []struct {
+		name    string
+		podSpec corev1.PodSpec
+	}{
+		{name: "Pod", podSpec: ibr.toBuildPod().Spec},
+		{name: "Job", podSpec: ibr.toBuildJob().Spec.Template.Spec},
+	} {
+		for _, container := range builder.podSpec.Containers {
+			hasSigningKey := false
+			for _, volumeMount := range container.VolumeMounts {
+				if volumeMount.Name == "signing-key" {
+					hasSigningKey = true
+				}
+			}
+
+			// Only the image build container should have access to the signing key.
+			if container.Name == "image-build" {
+				assert.True(t, hasSigningKey, builder.name)
+				assert.Contains(t, container.Env, corev1.EnvVar{Name: "SIGNING_KEY", Value: "/tmp/signing-key/cosign.key"}, builder.name)
+				assert.Contains(t, container.Env, corev1.EnvVar{Name: "SIGNING_PASSPHRASE", Value: "/tmp/signing-key/cosign.password"}, builder.name)
+			} else {
+				assert.False(t, hasSigningKey, builder.name)
+			}
+		}
+	}
+}
+
+// Tests that the build provenance is stored with the MachineConfig and lists
+// the inputs of the build.
+func TestImageBuildRequestProvenance(t *testing.T) {
+	t.Parallel()
+
+	mcp := newMachineConfigPool("worker", "rendered-worker-1")
+	ibr := newImageBuildRequestWithConfigMap(mcp, getOSImageURLConfigMap(), getOnClusterBuildConfigMap())
+	ibr.ImageBuilderType = JobImageBuilder
+
+	mc := &mcfgv1.MachineConfig{
+		ObjectMeta: metav1.ObjectMeta{Name: "rendered-worker-1"},
+		Spec: mcfgv1.MachineConfigSpec{
+			Extensions: []string{"usbguard"},
+		},
+	}
+
+	cm, err := ibr.toConfigMap(mc)
+	require.NoError(t, err)
+
+	dockerfile, err := ibr.renderDockerfile()
+	require.NoError(t, err)
+	assert.Contains(t, dockerfile, "COPY ./machineconfig/provenance.json /usr/share/machine-config/provenance.json")
+
+	provenance := BuildProvenance{}
+	require.NoError(t, json.Unmarshal([]byte(cm.Data[provenanceFilename]), &provenance))
+
+	assert.Equal(t, provenanceType, provenance.Type)
+	assert.Equal(t, "worker", provenance.Pool)
+	assert.Equal(t, "rendered-worker-1", provenance.MachineConfig)
+	assert.Len(t, provenance.MachineConfigSHA256, 64)
+	assert.Equal(t, string(JobImageBuilder), provenance.Builder)
+	assert.Equal(t, ibr.BaseImage.Pullspec, provenance.BaseImage)
+	assert.Equal(t, "sha256:12e89d631c0ca1700262583acfb856b6e7dbe94800cb38035d68ee5cc912411c", provenance.BaseImageDigest)
+	assert.Equal(t, ibr.ExtensionsImage.Pullspec, provenance.ExtensionsImage)
+	assert.Equal(t, []string{"usbguard"}, provenance.Extensions)
+}
+
+// Tests that the image signing configuration is validated against the
+// selected image builder.
+func TestValidateImageSigning(t *testing.T) {
+	t.Parallel()
+
+	b := &buildControllerTestFixture{}
+
+	testCases := []struct {
+		name        string
+		ctrl        func(*Clients) *Controller
+		data        map[string]string
+		errExpected bool
+	}{
+		{
+			name: "Signing disabled",
+			ctrl: func(cs *Clients) *Controller { return NewWithImageBuilder(b.getConfig(), cs) },
+		},
+		{
+			name: "Custom build pod",
+			ctrl: func(cs *Clients) *Controller { return NewWithCustomPodBuilder(b.getConfig(), cs) },
+			data: map[string]string{imageSigningKeySecretNameConfigKey: "signing-key"},
+		},
+		{
+			name:        "OpenShift Image Builder",
+			ctrl:        func(cs *Clients) *Controller { return NewWithImageBuilder(b.getConfig(), cs) },
+			data:        map[string]string{imageSigningKeySecretNameConfigKey: "signing-key"},
+			errExpected: true,
+		},
+		{
+			name: "Job builder selected",
+			ctrl: func(cs *Clients) *Controller {
+				ctrl, _ := New(b.getConfig(), cs, OpenShiftImageBuilder, JobImageBuilder)
+				return ctrl
+			},
+			data: map[string]string{
+				imageSigningKeySecretNameConfigKey: "signing-key",
+				imageBuilderTypeConfigKey:          string(JobImageBuilder),
+			},
+		},
+		{
+			name:        "Missing secret",
+			ctrl:        func(cs *Clients) *Controller { return NewWithCustomPodBuilder(b.getConfig(), cs) },
+			data:        map[string]string{imageSigningKeySecretNameConfigKey: "missing"},
+			errExpected: true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			cs := b.setupClients()
+			_, err := cs.kubeclient.CoreV1().Secrets(ctrlcommon.MCONamespace).Create(context.TODO(), newImageSigningKeySecret("signing-key", map[string]string{
+				signingPrivateKeySecretKey: "private",
+				signingPublicKeySecretKey:  "public",
+			}), metav1.CreateOptions{})
+			require.NoError(t, err)
+
+			onClusterBuildConfigMap := getOnClusterBuildConfigMap()
+			for key, val := range testCase.data {
+				onClusterBuildConfigMap.Data[key] = val
+			}
+
+			err = testCase.ctrl(cs).validateImageSigning(onClusterBuildConfigMap)
+			if testCase.errExpected {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
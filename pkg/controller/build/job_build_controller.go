@@ -0,0 +1,291 @@
+package build
+
+import (
+	"context"
+	"fmt"
+
+	mcfgv1 "github.com/openshift/machine-config-operator/pkg/apis/machineconfiguration.openshift.io/v1"
+	ctrlcommon "github.com/openshift/machine-config-operator/pkg/controller/common"
+	"github.com/openshift/machine-config-operator/pkg/generated/clientset/versioned/scheme"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	aggerrors "k8s.io/apimachinery/pkg/util/errors"
+	coreclientsetv1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	batchlistersv1 "k8s.io/client-go/listers/batch/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/klog/v2"
+)
+
+// JobBuildController builds the final OS image with Buildah as a non-root user
+// in a Kubernetes Job. Unlike a bare build pod, the Job gives us a place to hang
+// scheduling and lifecycle policy off of, and it works on clusters without the
+// OpenShift Build API.
+type JobBuildController struct {
+	*Clients
+	*informers
+
+	eventRecorder record.EventRecorder
+
+	// The function to call whenever we've encountered a build Job. This
+	// function is responsible for examining the build Job to determine what
+	// state its in and map that state to the appropriate MachineConfigPool
+	// object.
+	jobHandler func(*batchv1.Job) error
+
+	enqueueJob func(*batchv1.Job)
+
+	jobLister batchlistersv1.JobLister
+
+	jobListerSynced cache.InformerSynced
+
+	*buildObjectController
+}
+
+var _ ImageBuilder = (*JobBuildController)(nil)
+
+// Returns a new job build controller.
+func newJobBuildController(
+	ctrlConfig BuildControllerConfig,
+	clients *Clients,
+	jobHandler func(*batchv1.Job) error,
+) *JobBuildController {
+	eventBroadcaster := record.NewBroadcaster()
+	eventBroadcaster.StartLogging(klog.Infof)
+	eventBroadcaster.StartRecordingToSink(&coreclientsetv1.EventSinkImpl{Interface: clients.kubeclient.CoreV1().Events("")})
+
+	ctrl := &JobBuildController{
+		Clients:               clients,
+		informers:             newInformers(clients),
+		eventRecorder:         eventBroadcaster.NewRecorder(scheme.Scheme, corev1.EventSource{Component: "machineosbuilder-jobbuildcontroller"}),
+		buildObjectController: newBuildObjectController("job", "machineosbuilder-jobbuildcontroller", ctrlConfig),
+		jobHandler:            jobHandler,
+	}
+
+	ctrl.jobInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    ctrl.addJob,
+		UpdateFunc: ctrl.updateJob,
+		DeleteFunc: ctrl.deleteJob,
+	})
+
+	ctrl.jobLister = ctrl.jobInformer.Lister()
+
+	ctrl.jobListerSynced = ctrl.jobInformer.Informer().HasSynced
+
+	ctrl.syncHandler = ctrl.syncJob
+	ctrl.enqueueJob = func(job *batchv1.Job) { ctrl.enqueueDefault(job) }
+
+	return ctrl
+}
+
+// Syncs jobs.
+func (ctrl *JobBuildController) syncJob(key string) error {
+	get := func(name string) (metav1.Object, error) {
+		job, err := ctrl.jobLister.Jobs(ctrlcommon.MCONamespace).Get(name)
+		if err != nil {
+			return nil, err
+		}
+
+		return ctrl.kubeclient.BatchV1().Jobs(ctrlcommon.MCONamespace).Get(context.TODO(), job.Name, metav1.GetOptions{})
+	}
+
+	return ctrl.syncBuildObject(key, get, func(obj metav1.Object) error {
+		job := obj.(*batchv1.Job)
+
+		if err := ctrl.jobHandler(job); err != nil {
+			return fmt.Errorf("unable to update with build job status: %w", err)
+		}
+
+		klog.Infof("Updated MachineConfigPool with build job status. Build job %s", job.Name)
+
+		return nil
+	})
+}
+
+// Starts the Job Build Controller.
+func (ctrl *JobBuildController) Run(ctx context.Context, workers int) {
+	ctrl.run(ctx, "MachineOSBuilder-JobBuildController", workers, ctrl.informers.start, ctrl.jobListerSynced)
+}
+
+// Gets the final image pullspec by retrieving the ConfigMap that the build
+// Job creates from the Buildah digestfile.
+func (ctrl *JobBuildController) FinalPullspec(pool *mcfgv1.MachineConfigPool) (string, error) {
+	return getFinalPullspecFromDigestConfigMap(ctrl.kubeclient, pool)
+}
+
+// Gets the tail of the logs from the image build container of the most
+// recent pod created by the build Job.
+func (ctrl *JobBuildController) BuildLogs(pool *mcfgv1.MachineConfigPool) (string, error) {
+	jobName := newImageBuildRequest(pool).getBuildName()
+
+	selector := labels.SelectorFromSet(labels.Set{batchv1.JobNameLabel: jobName})
+	pods, err := ctrl.kubeclient.CoreV1().Pods(ctrlcommon.MCONamespace).List(context.TODO(), metav1.ListOptions{LabelSelector: selector.String()})
+	if err != nil {
+		return "", fmt.Errorf("could not list pods for build job %s: %w", jobName, err)
+	}
+
+	if len(pods.Items) == 0 {
+		return "", fmt.Errorf("no pods found for build job %s", jobName)
+	}
+
+	newest := pods.Items[0]
+	for _, pod := range pods.Items[1:] {
+		if newest.CreationTimestamp.Before(&pod.CreationTimestamp) {
+			newest = pod
+		}
+	}
+
+	return getBuildPodLogs(ctrl.kubeclient, newest.Name, "image-build")
+}
+
+// Deletes the underlying build Job along with its pods.
+func (ctrl *JobBuildController) DeleteBuildObject(pool *mcfgv1.MachineConfigPool) error {
+	// Without a propagation policy, the pods created by the Job are orphaned.
+	propagationPolicy := metav1.DeletePropagationBackground
+
+	return aggerrors.AggregateGoroutines(
+		func() error {
+			ibr := newImageBuildRequest(pool)
+			return ignoreIsNotFoundErr(ctrl.kubeclient.BatchV1().Jobs(ctrlcommon.MCONamespace).Delete(context.TODO(), ibr.getBuildName(), metav1.DeleteOptions{PropagationPolicy: &propagationPolicy}))
+		},
+		func() error {
+			ibr := newImageBuildRequest(pool)
+			return ignoreIsNotFoundErr(ctrl.kubeclient.CoreV1().ConfigMaps(ctrlcommon.MCONamespace).Delete(context.TODO(), ibr.getDigestConfigMapName(), metav1.DeleteOptions{}))
+		},
+	)
+}
+
+// Determines if a build is currently running by looking for a corresponding Job.
+func (ctrl *JobBuildController) IsBuildRunning(pool *mcfgv1.MachineConfigPool) (bool, error) {
+	ibr := newImageBuildRequest(pool)
+
+	_, err := ctrl.kubeclient.BatchV1().Jobs(ctrlcommon.MCONamespace).Get(context.TODO(), ibr.getBuildName(), metav1.GetOptions{})
+	if err != nil && !k8serrors.IsNotFound(err) {
+		return false, err
+	}
+
+	return err == nil, nil
+}
+
+// Starts a new build Job, assuming one is not found first. In that case, it
+// returns an object reference to the preexisting build Job.
+func (ctrl *JobBuildController) StartBuild(ibr ImageBuildRequest) (*corev1.ObjectReference, error) {
+	existing, err := getExistingBuildObject(ibr, func(name string) (metav1.Object, error) {
+		return ctrl.kubeclient.BatchV1().Jobs(ctrlcommon.MCONamespace).Get(context.TODO(), name, metav1.GetOptions{})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if existing != nil {
+		klog.Infof("Found preexisting build job (%s) for pool %s", existing.GetName(), ibr.Pool.Name)
+		return toObjectRef(existing.(*batchv1.Job)), nil
+	}
+
+	klog.Infof("Starting build job %s for pool %s", ibr.getBuildName(), ibr.Pool.Name)
+	klog.Infof("Final image will be pushed to %q, using secret %q", ibr.FinalImage.Pullspec, ibr.FinalImage.PullSecret.Name)
+
+	job, err := ctrl.kubeclient.BatchV1().Jobs(ctrlcommon.MCONamespace).Create(context.TODO(), ibr.toBuildJob(), metav1.CreateOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("could not create build job: %w", err)
+	}
+
+	klog.Infof("Build job started for pool %s in %s!", ibr.Pool.Name, job.Name)
+
+	return toObjectRef(job), nil
+}
+
+// Fires whenever a new job is started.
+func (ctrl *JobBuildController) addJob(obj interface{}) {
+	job := obj.(*batchv1.Job).DeepCopy()
+	isBuildJob := hasAllRequiredOSBuildLabels(job.Labels)
+	klog.V(4).Infof("Adding Job %s. Is build job? %v", job.Name, isBuildJob)
+	if isBuildJob {
+		ctrl.enqueueJob(job)
+	}
+}
+
+// Fires whenever a job is updated.
+func (ctrl *JobBuildController) updateJob(_, curObj interface{}) {
+	curJob := curObj.(*batchv1.Job).DeepCopy()
+
+	// Ignore non-build jobs.
+	if !hasAllRequiredOSBuildLabels(curJob.Labels) {
+		return
+	}
+
+	klog.Infof("Job %s updated", curJob.Name)
+
+	ctrl.enqueueJob(curJob)
+}
+
+// Fires whenever a job is deleted.
+func (ctrl *JobBuildController) deleteJob(obj interface{}) {
+	job, ok := obj.(*batchv1.Job)
+	if !ok {
+		return
+	}
+	job = job.DeepCopy()
+	klog.V(4).Infof("Deleting Job %s. Is build job? %v", job.Name, hasAllRequiredOSBuildLabels(job.Labels))
+	ctrl.enqueueJob(job)
+}
+
+// Maps the status of a build Job onto a build state. A Job without ready pods
+// is considered pending, since its pod may not have been scheduled yet.
+func getBuildJobState(job *batchv1.Job) (buildState, string) {
+	for _, condition := range job.Status.Conditions {
+		if condition.Status != corev1.ConditionTrue {
+			continue
+		}
+
+		switch condition.Type {
+		case batchv1.JobComplete:
+			return buildStateSucceeded, ""
+		case batchv1.JobFailed:
+			return buildStateFailed, getBuildJobFailureReason(job, condition)
+		}
+	}
+
+	switch {
+	case job.Status.Succeeded > 0:
+		return buildStateSucceeded, ""
+	case job.Status.Failed > 0:
+		return buildStateFailed, fmt.Sprintf("build job %s failed", job.Name)
+	case job.Status.Ready != nil && *job.Status.Ready > 0:
+		return buildStateRunning, ""
+	// Older clusters do not report ready pods, so fall back to active pods.
+	case job.Status.Ready == nil && job.Status.Active > 0:
+		return buildStateRunning, ""
+	default:
+		return buildStatePending, ""
+	}
+}
+
+// Determines whether a build Job failed because it exceeded its
+// activeDeadlineSeconds.
+func isBuildJobTimedOut(job *batchv1.Job) bool {
+	for _, condition := range job.Status.Conditions {
+		if condition.Type == batchv1.JobFailed && condition.Status == corev1.ConditionTrue && condition.Reason == deadlineExceededReason {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Describes why a given build Job failed.
+func getBuildJobFailureReason(job *batchv1.Job, condition batchv1.JobCondition) string {
+	switch {
+	case condition.Reason != "" && condition.Message != "":
+		return fmt.Sprintf("%s: %s", condition.Reason, condition.Message)
+	case condition.Reason != "":
+		return condition.Reason
+	case condition.Message != "":
+		return condition.Message
+	default:
+		return fmt.Sprintf("build job %s failed", job.Name)
+	}
+}
@@ -0,0 +1,203 @@
+package build
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	mcfgv1 "github.com/openshift/machine-config-operator/pkg/apis/machineconfiguration.openshift.io/v1"
+	ctrlcommon "github.com/openshift/machine-config-operator/pkg/controller/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+// Tests that the status of a build Job is mapped onto the expected build state.
+func TestGetBuildJobState(t *testing.T) {
+	t.Parallel()
+
+	ready := func(n int32) *int32 {
+		return &n
+	}
+
+	testCases := []struct {
+		name           string
+		status         batchv1.JobStatus
+		expectedState  buildState
+		expectedReason string
+	}{
+		{
+			name:          "No pods yet",
+			expectedState: buildStatePending,
+		},
+		{
+			name:          "Active but not ready",
+			status:        batchv1.JobStatus{Active: 1, Ready: ready(0)},
+			expectedState: buildStatePending,
+		},
+		{
+			name:          "Ready",
+			status:        batchv1.JobStatus{Active: 1, Ready: ready(1)},
+			expectedState: buildStateRunning,
+		},
+		{
+			name:          "Active without ready count",
+			status:        batchv1.JobStatus{Active: 1},
+			expectedState: buildStateRunning,
+		},
+		{
+			name: "Complete",
+			status: batchv1.JobStatus{
+				Succeeded:  1,
+				Conditions: []batchv1.JobCondition{{Type: batchv1.JobComplete, Status: corev1.ConditionTrue}},
+			},
+			expectedState: buildStateSucceeded,
+		},
+		{
+			name: "Failed condition",
+			status: batchv1.JobStatus{
+				Failed: 1,
+				Conditions: []batchv1.JobCondition{
+					{Type: batchv1.JobFailed, Status: corev1.ConditionTrue, Reason: "BackoffLimitExceeded", Message: "Job has reached the specified backoff limit"},
+				},
+			},
+			expectedState:  buildStateFailed,
+			expectedReason: "BackoffLimitExceeded: Job has reached the specified backoff limit",
+		},
+		{
+			name:           "Failed pod without condition",
+			status:         batchv1.JobStatus{Failed: 1},
+			expectedState:  buildStateFailed,
+			expectedReason: "build job build-rendered-worker-1 failed",
+		},
+	}
+
+	for _, testCase := range testCases {
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			job := &batchv1.Job{
+				ObjectMeta: metav1.ObjectMeta{Name: "build-rendered-worker-1"},
+				Status:     testCase.status,
+			}
+
+			state, reason := getBuildJobState(job)
+			assert.Equal(t, testCase.expectedState, state)
+			assert.Equal(t, testCase.expectedReason, reason)
+		})
+	}
+}
+
+// Tests that the build Job runs Buildah as a non-root user and that its pods are not
+// mistaken for custom build pods.
+func TestToBuildJob(t *testing.T) {
+	t.Parallel()
+
+	pool := newMachineConfigPool("worker", "rendered-worker-1")
+	ibr := newImageBuildRequestWithConfigMap(pool, getOSImageURLConfigMap(), getOnClusterBuildConfigMap())
+
+	job := ibr.toBuildJob()
+
+	assert.Equal(t, ibr.getBuildName(), job.Name)
+	assert.True(t, hasAllRequiredOSBuildLabels(job.Labels))
+	assert.False(t, hasAllRequiredOSBuildLabels(job.Spec.Template.Labels))
+	assert.Equal(t, int32(0), *job.Spec.BackoffLimit)
+
+	podSpec := job.Spec.Template.Spec
+	assert.Equal(t, corev1.RestartPolicyNever, podSpec.RestartPolicy)
+	require.NotNil(t, podSpec.HostUsers)
+	assert.False(t, *podSpec.HostUsers)
+
+	for _, container := range podSpec.Containers {
+		assert.True(t, *container.SecurityContext.RunAsNonRoot, container.Name)
+
+		if container.Name == "image-build" {
+			assert.Contains(t, container.Env, corev1.EnvVar{Name: "BUILDAH_ISOLATION", Value: "chroot"})
+		}
+	}
+}
+
+// Tests that the build controller uses the Job backend when it is selected in
+// the on-cluster-build-config ConfigMap.
+func TestBuildControllerWithJobBuilder(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	t.Cleanup(cancel)
+
+	b := &buildControllerTestFixture{ctx: ctx, t: t}
+	cs := b.setupClients()
+
+	onClusterBuildConfigMap, err := cs.kubeclient.CoreV1().ConfigMaps(ctrlcommon.MCONamespace).Get(ctx, onClusterBuildConfigMapName, metav1.GetOptions{})
+	require.NoError(t, err)
+
+	onClusterBuildConfigMap.Data[imageBuilderTypeConfigKey] = string(JobImageBuilder)
+	_, err = cs.kubeclient.CoreV1().ConfigMaps(ctrlcommon.MCONamespace).Update(ctx, onClusterBuildConfigMap, metav1.UpdateOptions{})
+	require.NoError(t, err)
+
+	ctrl, err := New(b.getConfig(), cs, CustomPodImageBuilder, JobImageBuilder)
+	require.NoError(t, err)
+	ctrl.registryImageLookup = noRegistryImageLookup
+
+	go ctrl.Run(ctx, 5)
+
+	mcp := optInMCP(ctx, t, cs, "worker")
+	ibr := newImageBuildRequest(mcp)
+
+	// Wait for the build Job to be created.
+	err = wait.PollImmediateInfiniteWithContext(ctx, time.Millisecond, func(ctx context.Context) (bool, error) {
+		_, err := cs.kubeclient.BatchV1().Jobs(ctrlcommon.MCONamespace).Get(ctx, ibr.getBuildName(), metav1.GetOptions{})
+		return err == nil, nil
+	})
+	require.NoError(t, err)
+
+	assertNoBuildPods(ctx, t, cs)
+
+	assertMachineConfigPoolReachesState(ctx, t, cs, "worker", func(mcp *mcfgv1.MachineConfigPool) bool {
+		return mcfgv1.IsMachineConfigPoolConditionTrue(mcp.Status.Conditions, mcfgv1.MachineConfigPoolBuildPending) &&
+			mcp.Annotations[imageBuilderTypeAnnotationKey] == string(JobImageBuilder) &&
+			machineConfigPoolHasBuildRef(mcp)
+	})
+
+	updateJobStatus := func(status batchv1.JobStatus) {
+		job, err := cs.kubeclient.BatchV1().Jobs(ctrlcommon.MCONamespace).Get(ctx, ibr.getBuildName(), metav1.GetOptions{})
+		require.NoError(t, err)
+
+		job.Status = status
+		_, err = cs.kubeclient.BatchV1().Jobs(ctrlcommon.MCONamespace).UpdateStatus(ctx, job, metav1.UpdateOptions{})
+		require.NoError(t, err)
+	}
+
+	var readyPods int32 = 1
+	updateJobStatus(batchv1.JobStatus{Active: 1, Ready: &readyPods})
+
+	assertMachineConfigPoolReachesState(ctx, t, cs, "worker", func(mcp *mcfgv1.MachineConfigPool) bool {
+		return mcfgv1.IsMachineConfigPoolConditionTrue(mcp.Status.Conditions, mcfgv1.MachineConfigPoolBuilding)
+	})
+
+	// Create the ConfigMap that the build Job creates with the resulting image digest.
+	_, err = cs.kubeclient.CoreV1().ConfigMaps(ctrlcommon.MCONamespace).Create(ctx, &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      ibr.getDigestConfigMapName(),
+			Namespace: ctrlcommon.MCONamespace,
+		},
+		Data: map[string]string{
+			"digest": expectedImageSHA,
+		},
+	}, metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	updateJobStatus(batchv1.JobStatus{
+		Succeeded:  1,
+		Conditions: []batchv1.JobCondition{{Type: batchv1.JobComplete, Status: corev1.ConditionTrue}},
+	})
+
+	assertMachineConfigPoolReachesState(ctx, t, cs, "worker", isMCPBuildSuccess)
+
+	_, err = cs.kubeclient.BatchV1().Jobs(ctrlcommon.MCONamespace).Get(ctx, ibr.getBuildName(), metav1.GetOptions{})
+	assert.Error(t, err, "expected build job to be deleted")
+}
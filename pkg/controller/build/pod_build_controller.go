@@ -3,7 +3,6 @@ package build
 import (
 	"context"
 	"fmt"
-	"strings"
 	"time"
 
 	mcfgv1 "github.com/openshift/machine-config-operator/pkg/apis/machineconfiguration.openshift.io/v1"
@@ -13,13 +12,11 @@ import (
 	k8serrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	aggerrors "k8s.io/apimachinery/pkg/util/errors"
-	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
-	"k8s.io/apimachinery/pkg/util/wait"
+	clientset "k8s.io/client-go/kubernetes"
 	coreclientsetv1 "k8s.io/client-go/kubernetes/typed/core/v1"
 	corelistersv1 "k8s.io/client-go/listers/core/v1"
 	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/tools/record"
-	"k8s.io/client-go/util/workqueue"
 	"k8s.io/klog/v2"
 )
 
@@ -35,16 +32,13 @@ type PodBuildController struct {
 	// that state to the appropriate MachineConfigPool object.
 	podHandler func(*corev1.Pod) error
 
-	syncHandler func(pod string) error
-	enqueuePod  func(*corev1.Pod)
+	enqueuePod func(*corev1.Pod)
 
 	podLister corelistersv1.PodLister
 
 	podListerSynced cache.InformerSynced
 
-	queue workqueue.RateLimitingInterface
-
-	config BuildControllerConfig
+	*buildObjectController
 }
 
 var _ ImageBuilder = (*PodBuildController)(nil)
@@ -60,12 +54,11 @@ func newPodBuildController(
 	eventBroadcaster.StartRecordingToSink(&coreclientsetv1.EventSinkImpl{Interface: clients.kubeclient.CoreV1().Events("")})
 
 	ctrl := &PodBuildController{
-		Clients:       clients,
-		informers:     newInformers(clients),
-		eventRecorder: eventBroadcaster.NewRecorder(scheme.Scheme, corev1.EventSource{Component: "machineosbuilder-podbuildcontroller"}),
-		queue:         workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "machineosbuilder-podbuildcontroller"),
-		config:        ctrlConfig,
-		podHandler:    podHandler,
+		Clients:               clients,
+		informers:             newInformers(clients),
+		eventRecorder:         eventBroadcaster.NewRecorder(scheme.Scheme, corev1.EventSource{Component: "machineosbuilder-podbuildcontroller"}),
+		buildObjectController: newBuildObjectController("pod", "machineosbuilder-podbuildcontroller", ctrlConfig),
+		podHandler:            podHandler,
 	}
 
 	// As an aside, why doesn't the constructor here set up all the informers?
@@ -80,116 +73,88 @@ func newPodBuildController(
 	ctrl.podListerSynced = ctrl.podInformer.Informer().HasSynced
 
 	ctrl.syncHandler = ctrl.syncPod
-	ctrl.enqueuePod = ctrl.enqueueDefault
+	ctrl.enqueuePod = func(pod *corev1.Pod) { ctrl.enqueueDefault(pod) }
 
 	return ctrl
 }
 
-func (ctrl *PodBuildController) enqueue(pod *corev1.Pod) {
-	key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(pod)
-	if err != nil {
-		utilruntime.HandleError(fmt.Errorf("Couldn't get key for object %#v: %v", pod, err))
-		return
+// Syncs pods.
+func (ctrl *PodBuildController) syncPod(key string) error {
+	get := func(name string) (metav1.Object, error) {
+		// TODO: Why do I need to set the namespace here?
+		pod, err := ctrl.podLister.Pods(ctrlcommon.MCONamespace).Get(name)
+		if err != nil {
+			return nil, err
+		}
+
+		return ctrl.kubeclient.CoreV1().Pods(ctrlcommon.MCONamespace).Get(context.TODO(), pod.Name, metav1.GetOptions{})
 	}
 
-	ctrl.queue.Add(key)
-}
+	return ctrl.syncBuildObject(key, get, func(obj metav1.Object) error {
+		pod := obj.(*corev1.Pod)
 
-func (ctrl *PodBuildController) enqueueRateLimited(pod *corev1.Pod) {
-	key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(pod)
-	if err != nil {
-		utilruntime.HandleError(fmt.Errorf("Couldn't get key for object %#v: %v", pod, err))
-		return
-	}
+		// The kubelet only enforces the deadline of pods which have started, so
+		// builds which are stuck waiting to be scheduled are timed out here.
+		if pod.Status.Phase != corev1.PodSucceeded && pod.Status.Phase != corev1.PodFailed {
+			timedOut, remaining := checkBuildDeadline(pod, time.Now())
+			if timedOut {
+				return ctrl.failTimedOutBuildPod(pod)
+			}
 
-	ctrl.queue.AddRateLimited(key)
-}
+			if remaining > 0 {
+				ctrl.enqueueAfter(pod, remaining)
+			}
+		}
 
-// enqueueAfter will enqueue a pod after the provided amount of time.
-func (ctrl *PodBuildController) enqueueAfter(pod *corev1.Pod, after time.Duration) {
-	key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(pod)
-	if err != nil {
-		utilruntime.HandleError(fmt.Errorf("Couldn't get key for object %#v: %v", pod, err))
-		return
-	}
+		if err := ctrl.podHandler(pod); err != nil {
+			return fmt.Errorf("unable to update with build pod status: %w", err)
+		}
 
-	ctrl.queue.AddAfter(key, after)
-}
+		klog.Infof("Updated MachineConfigPool with build pod status. Build pod %s in %s", pod.Name, pod.Status.Phase)
 
-// enqueueDefault calls a default enqueue function
-func (ctrl *PodBuildController) enqueueDefault(pod *corev1.Pod) {
-	ctrl.enqueueAfter(pod, ctrl.config.UpdateDelay)
+		return nil
+	})
 }
 
-// Syncs pods.
-func (ctrl *PodBuildController) syncPod(key string) error { //nolint:dupl // This does have commonality with the ImageBuildController.
-	start := time.Now()
-	defer func() {
-		klog.Infof("Finished syncing pod %s: %s", key, time.Since(start))
-	}()
-	klog.Infof("Started syncing pod %s", key)
-
-	_, name, err := cache.SplitMetaNamespaceKey(key)
-	if err != nil {
-		return err
-	}
-	// TODO: Why do I need to set the namespace here?
-	pod, err := ctrl.podLister.Pods(ctrlcommon.MCONamespace).Get(name)
-	if k8serrors.IsNotFound(err) {
-		klog.V(2).Infof("Pod %v has been deleted", key)
-		return nil
-	}
-	if err != nil {
-		return err
-	}
+// Reports a build pod which exceeded its timeout as failed, in the same way
+// that the kubelet does, and deletes it so that it cannot start later.
+func (ctrl *PodBuildController) failTimedOutBuildPod(pod *corev1.Pod) error {
+	klog.Infof("Build pod %s exceeded its timeout of %s", pod.Name, pod.Annotations[buildTimeoutAnnotationKey])
 
-	pod, err = ctrl.kubeclient.CoreV1().Pods(ctrlcommon.MCONamespace).Get(context.TODO(), pod.Name, metav1.GetOptions{})
-	if err != nil {
-		return err
-	}
+	timedOut := pod.DeepCopy()
+	timedOut.Status.Phase = corev1.PodFailed
+	timedOut.Status.Reason = deadlineExceededReason
+	timedOut.Status.Message = getBuildTimedOutReason(pod)
 
-	// If we don't have all of the OS build labels attached to this pod, we
-	// ignore it. There is probably something we can do along the lines looking
-	// at ownership though.
-	if !hasAllRequiredOSBuildLabels(pod.Labels) {
-		klog.Infof("Ignoring non-build pod %s", pod.Name)
-		return nil
-	}
+	handlerErr := ctrl.podHandler(timedOut)
 
-	if err := ctrl.podHandler(pod); err != nil {
-		return fmt.Errorf("unable to update with build pod status: %w", err)
+	err := ctrl.kubeclient.CoreV1().Pods(ctrlcommon.MCONamespace).Delete(context.TODO(), pod.Name, metav1.DeleteOptions{})
+	if err != nil && !k8serrors.IsNotFound(err) {
+		return fmt.Errorf("could not delete timed out build pod %s: %w", pod.Name, err)
 	}
 
-	klog.Infof("Updated MachineConfigPool with build pod status. Build pod %s in %s", pod.Name, pod.Status.Phase)
+	if handlerErr != nil {
+		return fmt.Errorf("unable to update with build pod status: %w", handlerErr)
+	}
 
 	return nil
 }
 
 // Starts the Pod Build Controller.
 func (ctrl *PodBuildController) Run(ctx context.Context, workers int) {
-	defer utilruntime.HandleCrash()
-	defer ctrl.queue.ShutDown()
-
-	ctrl.informers.start(ctx)
-
-	if !cache.WaitForCacheSync(ctx.Done(), ctrl.podListerSynced) {
-		return
-	}
-
-	klog.Info("Starting MachineOSBuilder-PodBuildController")
-	defer klog.Info("Shutting down MachineOSBuilder-PodBuildController")
-
-	for i := 0; i < workers; i++ {
-		go wait.Until(ctrl.worker, time.Second, ctx.Done())
-	}
-
-	<-ctx.Done()
+	ctrl.run(ctx, "MachineOSBuilder-PodBuildController", workers, ctrl.informers.start, ctrl.podListerSynced)
 }
 
 // Gets the final image pullspec by retrieving the ConfigMap that the build pod
 // creates from the Buildah digestfile.
 func (ctrl *PodBuildController) FinalPullspec(pool *mcfgv1.MachineConfigPool) (string, error) {
-	onClusterBuildConfigMap, err := ctrl.kubeclient.CoreV1().ConfigMaps(ctrlcommon.MCONamespace).Get(context.TODO(), onClusterBuildConfigMapName, metav1.GetOptions{})
+	return getFinalPullspecFromDigestConfigMap(ctrl.kubeclient, pool)
+}
+
+// Gets the final image pullspec from the ConfigMap which the "wait-for-done"
+// container creates from the Buildah digestfile.
+func getFinalPullspecFromDigestConfigMap(kubeclient clientset.Interface, pool *mcfgv1.MachineConfigPool) (string, error) {
+	onClusterBuildConfigMap, err := kubeclient.CoreV1().ConfigMaps(ctrlcommon.MCONamespace).Get(context.TODO(), onClusterBuildConfigMapName, metav1.GetOptions{})
 	if err != nil {
 		return "", err
 	}
@@ -197,7 +162,7 @@ func (ctrl *PodBuildController) FinalPullspec(pool *mcfgv1.MachineConfigPool) (s
 	finalImageInfo := newFinalImageInfo(onClusterBuildConfigMap)
 	ibr := newImageBuildRequest(pool)
 
-	digestConfigMap, err := ctrl.kubeclient.CoreV1().ConfigMaps(ctrlcommon.MCONamespace).Get(context.TODO(), ibr.getDigestConfigMapName(), metav1.GetOptions{})
+	digestConfigMap, err := kubeclient.CoreV1().ConfigMaps(ctrlcommon.MCONamespace).Get(context.TODO(), ibr.getDigestConfigMapName(), metav1.GetOptions{})
 	if err != nil {
 		return "", err
 	}
@@ -205,6 +170,11 @@ func (ctrl *PodBuildController) FinalPullspec(pool *mcfgv1.MachineConfigPool) (s
 	return parseImagePullspec(finalImageInfo.Pullspec, digestConfigMap.Data["digest"])
 }
 
+// Gets the tail of the logs from the image build container of the build pod.
+func (ctrl *PodBuildController) BuildLogs(pool *mcfgv1.MachineConfigPool) (string, error) {
+	return getBuildPodLogs(ctrl.kubeclient, newImageBuildRequest(pool).getBuildName(), "image-build")
+}
+
 // Deletes the underlying build pod.
 func (ctrl *PodBuildController) DeleteBuildObject(pool *mcfgv1.MachineConfigPool) error {
 	// We want to ignore when a pod or ConfigMap is deleted if it is not found.
@@ -238,30 +208,24 @@ func (ctrl *PodBuildController) IsBuildRunning(pool *mcfgv1.MachineConfigPool) (
 
 // Starts a new build pod, assuming one is not found first. In that case, it returns an object reference to the preexisting build pod.
 func (ctrl *PodBuildController) StartBuild(ibr ImageBuildRequest) (*corev1.ObjectReference, error) {
-	targetMC := ibr.Pool.Spec.Configuration.Name
-
-	// TODO: Find a constant for this:
-	if !strings.HasPrefix(targetMC, "rendered-") {
-		return nil, fmt.Errorf("%s is not a rendered MachineConfig", targetMC)
-	}
-
-	// First check if we have a build in progress for this MachineConfigPool and rendered config.
-	pod, err := ctrl.kubeclient.CoreV1().Pods(ctrlcommon.MCONamespace).Get(context.TODO(), ibr.getBuildName(), metav1.GetOptions{})
-	if err != nil && !k8serrors.IsNotFound(err) {
+	existing, err := getExistingBuildObject(ibr, func(name string) (metav1.Object, error) {
+		return ctrl.kubeclient.CoreV1().Pods(ctrlcommon.MCONamespace).Get(context.TODO(), name, metav1.GetOptions{})
+	})
+	if err != nil {
 		return nil, err
 	}
 
 	// This means we found a preexisting build pod.
-	if pod != nil && err == nil && hasAllRequiredOSBuildLabels(pod.Labels) {
-		klog.Infof("Found preexisting build pod (%s) for pool %s", pod.Name, ibr.Pool.Name)
-		return toObjectRef(pod), nil
+	if existing != nil {
+		klog.Infof("Found preexisting build pod (%s) for pool %s", existing.GetName(), ibr.Pool.Name)
+		return toObjectRef(existing.(*corev1.Pod)), nil
 	}
 
 	klog.Infof("Starting build for pool %s", ibr.Pool.Name)
 	klog.Infof("Build pod name: %s", ibr.getBuildName())
 	klog.Infof("Final image will be pushed to %q, using secret %q", ibr.FinalImage.Pullspec, ibr.FinalImage.PullSecret.Name)
 
-	pod, err = ctrl.kubeclient.CoreV1().Pods(ctrlcommon.MCONamespace).Create(context.TODO(), ibr.toBuildPod(), metav1.CreateOptions{})
+	pod, err := ctrl.kubeclient.CoreV1().Pods(ctrlcommon.MCONamespace).Create(context.TODO(), ibr.toBuildPod(), metav1.CreateOptions{})
 	if err != nil {
 		return nil, fmt.Errorf("could not create build pod: %w", err)
 	}
@@ -305,24 +269,6 @@ func (ctrl *PodBuildController) updatePod(oldObj, curObj interface{}) {
 	ctrl.enqueuePod(curPod)
 }
 
-func (ctrl *PodBuildController) handleErr(err error, key interface{}) {
-	if err == nil {
-		ctrl.queue.Forget(key)
-		return
-	}
-
-	if ctrl.queue.NumRequeues(key) < ctrl.config.MaxRetries {
-		klog.V(2).Infof("Error syncing pod %v: %v", key, err)
-		ctrl.queue.AddRateLimited(key)
-		return
-	}
-
-	utilruntime.HandleError(err)
-	klog.V(2).Infof("Dropping pod %q out of the queue: %v", key, err)
-	ctrl.queue.Forget(key)
-	ctrl.queue.AddAfter(key, 1*time.Minute)
-}
-
 // Fires whenever a pod is deleted.
 func (ctrl *PodBuildController) deletePod(obj interface{}) {
 	pod, ok := obj.(*corev1.Pod)
@@ -333,23 +279,3 @@ func (ctrl *PodBuildController) deletePod(obj interface{}) {
 	klog.V(4).Infof("Deleting Pod %s. Is build pod? %v", pod.Name, hasAllRequiredOSBuildLabels(pod.Labels))
 	ctrl.enqueuePod(pod)
 }
-
-// worker runs a worker thread that just dequeues items, processes them, and marks them done.
-// It enforces that the syncHandler is never invoked concurrently with the same key.
-func (ctrl *PodBuildController) worker() {
-	for ctrl.processNextWorkItem() {
-	}
-}
-
-func (ctrl *PodBuildController) processNextWorkItem() bool {
-	key, quit := ctrl.queue.Get()
-	if quit {
-		return false
-	}
-	defer ctrl.queue.Done(key)
-
-	err := ctrl.syncHandler(key.(string))
-	ctrl.handleErr(err, key)
-
-	return true
-}
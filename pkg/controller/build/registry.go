@@ -0,0 +1,119 @@
+package build
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/containers/common/pkg/retry"
+	"github.com/containers/image/v5/docker"
+	"github.com/containers/image/v5/image"
+	"github.com/containers/image/v5/manifest"
+	"github.com/containers/image/v5/types"
+	"github.com/docker/distribution/registry/api/errcode"
+	v2 "github.com/docker/distribution/registry/api/v2"
+	"github.com/opencontainers/go-digest"
+)
+
+const (
+	// Number of times to retry a registry query before giving up.
+	registryRetriesCount = 2
+)
+
+// Represents an image which was found in a registry.
+type registryImage struct {
+	// The digest of the image manifest.
+	Digest digest.Digest
+	// The labels set on the image config.
+	Labels map[string]string
+}
+
+// Looks up the given image pullspec in its registry using the supplied
+// dockerconfigjson for authentication. Returns nil if the image does not
+// exist.
+type registryImageLookupFunc func(ctx context.Context, pullspec string, dockerConfigJSON []byte) (*registryImage, error)
+
+// Inspects an image in a registry using containers/image. Heavily inspired by
+// imageInspect() in pkg/daemon/image-inspect.go, except that the credentials
+// are supplied by the caller instead of being read from the kubelet auth file.
+func inspectRegistryImage(ctx context.Context, pullspec string, dockerConfigJSON []byte) (*registryImage, error) {
+	authfile, err := os.CreateTemp("", "authfile")
+	if err != nil {
+		return nil, fmt.Errorf("could not create authfile: %w", err)
+	}
+
+	defer os.Remove(authfile.Name())
+
+	if _, err := authfile.Write(dockerConfigJSON); err != nil {
+		authfile.Close()
+		return nil, fmt.Errorf("could not write authfile: %w", err)
+	}
+
+	if err := authfile.Close(); err != nil {
+		return nil, fmt.Errorf("could not close authfile: %w", err)
+	}
+
+	sys := &types.SystemContext{AuthFilePath: authfile.Name()}
+
+	retryOpts := retry.Options{
+		MaxRetry: registryRetriesCount,
+	}
+
+	ref, err := docker.ParseReference("//" + pullspec)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse image pullspec %q: %w", pullspec, err)
+	}
+
+	var src types.ImageSource
+	if err := retry.IfNecessary(ctx, func() error {
+		src, err = ref.NewImageSource(ctx, sys)
+		return err
+	}, &retryOpts); err != nil {
+		return nil, fmt.Errorf("could not create image source for %q: %w", pullspec, err)
+	}
+
+	defer src.Close()
+
+	var rawManifest []byte
+	if err := retry.IfNecessary(ctx, func() error {
+		rawManifest, _, err = src.GetManifest(ctx, nil)
+		return err
+	}, &retryOpts); err != nil {
+		if isManifestUnknownError(err) {
+			return nil, nil
+		}
+
+		return nil, fmt.Errorf("could not retrieve image manifest for %q: %w", pullspec, err)
+	}
+
+	imageDigest, err := manifest.Digest(rawManifest)
+	if err != nil {
+		return nil, fmt.Errorf("could not compute image digest for %q: %w", pullspec, err)
+	}
+
+	img, err := image.FromUnparsedImage(ctx, sys, image.UnparsedInstance(src, nil))
+	if err != nil {
+		return nil, fmt.Errorf("could not parse manifest for image %q: %w", pullspec, err)
+	}
+
+	var inspect *types.ImageInspectInfo
+	if err := retry.IfNecessary(ctx, func() error {
+		inspect, err = img.Inspect(ctx)
+		return err
+	}, &retryOpts); err != nil {
+		return nil, fmt.Errorf("could not inspect image %q: %w", pullspec, err)
+	}
+
+	return &registryImage{
+		Digest: imageDigest,
+		Labels: inspect.Labels,
+	}, nil
+}
+
+// Determines whether a registry error indicates that the requested manifest
+// does not exist. containers/image does not export its own equivalent.
+func isManifestUnknownError(err error) bool {
+	var ec errcode.ErrorCoder
+	return errors.As(err, &ec) && ec.ErrorCode() == v2.ErrorCodeManifestUnknown
+}
@@ -0,0 +1,127 @@
+package common
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+
+	ign3types "github.com/coreos/ignition/v2/config/v3_4/types"
+
+	mcfgv1 "github.com/openshift/machine-config-operator/pkg/apis/machineconfiguration.openshift.io/v1"
+)
+
+// configDefinition is the definition of a file, unit contents or dropin
+// contents by a source MachineConfig.
+type configDefinition struct {
+	config     *mcfgv1.MachineConfig
+	definition interface{}
+}
+
+// DetectMachineConfigConflicts returns the files, units and dropins which more
+// than one of configs defines differently, in the order of their type and
+// name. Units only conflict on their contents, their enabled and masked states
+// are merged. Conflicts only between MachineConfigs generated by the
+// controllers are left out, as those override each other by design, e.g. a
+// KubeletConfig overriding the kubelet configuration of the templates.
+func DetectMachineConfigConflicts(configs []*mcfgv1.MachineConfig) ([]mcfgv1.MachineConfigConflict, error) {
+	sorted := append([]*mcfgv1.MachineConfig{}, configs...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Name < sorted[j].Name })
+
+	definitions := map[mcfgv1.MachineConfigConflictType]map[string][]configDefinition{
+		mcfgv1.MachineConfigConflictFile:   {},
+		mcfgv1.MachineConfigConflictUnit:   {},
+		mcfgv1.MachineConfigConflictDropin: {},
+	}
+	add := func(conflictType mcfgv1.MachineConfigConflictType, name string, config *mcfgv1.MachineConfig, definition interface{}) {
+		definitions[conflictType][name] = append(definitions[conflictType][name], configDefinition{config: config, definition: definition})
+	}
+	for _, config := range sorted {
+		if config.Spec.Config.Raw == nil {
+			continue
+		}
+		ignCfg, err := ParseAndConvertConfig(config.Spec.Config.Raw)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse Ignition config of MachineConfig %s: %w", config.Name, err)
+		}
+		for _, file := range ignCfg.Storage.Files {
+			// The overwrite flag is defaulted when merging.
+			file.Overwrite = nil
+			add(mcfgv1.MachineConfigConflictFile, file.Path, config, file)
+		}
+		for _, unit := range ignCfg.Systemd.Units {
+			if unit.Contents != nil {
+				add(mcfgv1.MachineConfigConflictUnit, unit.Name, config, *unit.Contents)
+			}
+			for _, dropin := range unit.Dropins {
+				add(mcfgv1.MachineConfigConflictDropin, unit.Name+"/"+dropin.Name, config, dropinContents(dropin))
+			}
+		}
+	}
+
+	var conflicts []mcfgv1.MachineConfigConflict
+	for _, conflictType := range []mcfgv1.MachineConfigConflictType{mcfgv1.MachineConfigConflictFile, mcfgv1.MachineConfigConflictUnit, mcfgv1.MachineConfigConflictDropin} {
+		byName := definitions[conflictType]
+		names := make([]string, 0, len(byName))
+		for name := range byName {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if conflict := findConflict(conflictType, name, byName[name]); conflict != nil {
+				conflicts = append(conflicts, *conflict)
+			}
+		}
+	}
+	return conflicts, nil
+}
+
+// findConflict returns the conflict between the definitions, which are in the
+// order of the names of their MachineConfigs, or nil if there is none.
+func findConflict(conflictType mcfgv1.MachineConfigConflictType, name string, definitions []configDefinition) *mcfgv1.MachineConfigConflict {
+	winner := definitions[len(definitions)-1]
+	generatedOnly := isGeneratedByController(winner.config)
+	var losers []string
+	for _, definition := range definitions[:len(definitions)-1] {
+		if definition.config == winner.config || reflect.DeepEqual(definition.definition, winner.definition) {
+			continue
+		}
+		losers = append(losers, definition.config.Name)
+		generatedOnly = generatedOnly && isGeneratedByController(definition.config)
+	}
+	if len(losers) == 0 || generatedOnly {
+		return nil
+	}
+	return &mcfgv1.MachineConfigConflict{
+		Type:   conflictType,
+		Name:   name,
+		Winner: winner.config.Name,
+		Losers: losers,
+	}
+}
+
+func isGeneratedByController(config *mcfgv1.MachineConfig) bool {
+	_, ok := config.Annotations[GeneratedByControllerVersionAnnotationKey]
+	return ok
+}
+
+func dropinContents(dropin ign3types.Dropin) string {
+	if dropin.Contents == nil {
+		return ""
+	}
+	return *dropin.Contents
+}
+
+// ConfigConflictsError is returned when rendering is refused because of
+// conflicting source MachineConfigs.
+type ConfigConflictsError struct {
+	Conflicts []mcfgv1.MachineConfigConflict
+}
+
+func (e *ConfigConflictsError) Error() string {
+	return fmt.Sprintf("%d conflicts between MachineConfigs, first: %s", len(e.Conflicts), FormatMachineConfigConflict(e.Conflicts[0]))
+}
+
+// FormatMachineConfigConflict describes a conflict for humans.
+func FormatMachineConfigConflict(conflict mcfgv1.MachineConfigConflict) string {
+	return fmt.Sprintf("%s %s is defined by %s, overriding %v", conflict.Type, conflict.Name, conflict.Winner, conflict.Losers)
+}
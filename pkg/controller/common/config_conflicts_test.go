@@ -0,0 +1,75 @@
+package common
+
+import (
+	"testing"
+
+	ign3types "github.com/coreos/ignition/v2/config/v3_4/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	mcfgv1 "github.com/openshift/machine-config-operator/pkg/apis/machineconfiguration.openshift.io/v1"
+	"github.com/openshift/machine-config-operator/test/helpers"
+)
+
+func newConflictTestMachineConfig(name string, files []ign3types.File, units []ign3types.Unit) *mcfgv1.MachineConfig {
+	ignCfg := NewIgnConfig()
+	ignCfg.Storage.Files = files
+	ignCfg.Systemd.Units = units
+	mc := helpers.CreateMachineConfigFromIgnition(ignCfg)
+	mc.Name = name
+	return mc
+}
+
+func TestDetectMachineConfigConflicts(t *testing.T) {
+	chrony := func(contents string) ign3types.File {
+		file := NewIgnFile("/etc/chrony.conf", contents)
+		file.Overwrite = helpers.BoolToPtr(true)
+		return file
+	}
+	dropin := func(contents string) ign3types.Unit {
+		return ign3types.Unit{Name: "crio.service", Dropins: []ign3types.Dropin{{Name: "10-proxy.conf", Contents: helpers.StrToPtr(contents)}}}
+	}
+
+	base := newConflictTestMachineConfig("00-worker", []ign3types.File{chrony("pool 2.rhel.pool.ntp.org iburst\n")}, []ign3types.Unit{
+		{Name: "kubelet.service", Contents: helpers.StrToPtr("[Service]\n"), Enabled: helpers.BoolToPtr(true)},
+		dropin("[Service]\n"),
+	})
+	base.Annotations = map[string]string{GeneratedByControllerVersionAnnotationKey: "v1"}
+	kubelet := newConflictTestMachineConfig("01-worker-kubelet", []ign3types.File{NewIgnFile("/etc/kubernetes/kubelet.conf", "a")}, nil)
+	kubelet.Annotations = map[string]string{GeneratedByControllerVersionAnnotationKey: "v1"}
+	vendor := newConflictTestMachineConfig("50-vendor", []ign3types.File{
+		chrony("server vendor.example.com iburst\n"),
+		NewIgnFile("/etc/motd", "same"),
+	}, []ign3types.Unit{
+		// Only changing the enabled state is not a conflict.
+		{Name: "kubelet.service", Enabled: helpers.BoolToPtr(false)},
+	})
+	generatedKubelet := newConflictTestMachineConfig("99-worker-generated-kubelet", []ign3types.File{NewIgnFile("/etc/kubernetes/kubelet.conf", "b")}, nil)
+	generatedKubelet.Annotations = map[string]string{GeneratedByControllerVersionAnnotationKey: "v1"}
+	user := newConflictTestMachineConfig("99-worker-chrony", []ign3types.File{
+		// Overwrite is defaulted when merging, so this is the same as the vendor file.
+		NewIgnFile("/etc/chrony.conf", "server vendor.example.com iburst\n"),
+		NewIgnFile("/etc/motd", "same"),
+	}, []ign3types.Unit{dropin("[Service]\nEnvironment=HTTP_PROXY=http://proxy\n")})
+
+	conflicts, err := DetectMachineConfigConflicts([]*mcfgv1.MachineConfig{user, generatedKubelet, vendor, kubelet, base})
+	require.NoError(t, err)
+	assert.Equal(t, []mcfgv1.MachineConfigConflict{
+		{Type: mcfgv1.MachineConfigConflictFile, Name: "/etc/chrony.conf", Winner: "99-worker-chrony", Losers: []string{"00-worker"}},
+		{Type: mcfgv1.MachineConfigConflictDropin, Name: "crio.service/10-proxy.conf", Winner: "99-worker-chrony", Losers: []string{"00-worker"}},
+	}, conflicts)
+	assert.Equal(t, "File /etc/chrony.conf is defined by 99-worker-chrony, overriding [00-worker]", FormatMachineConfigConflict(conflicts[0]))
+
+	// The unit contents are overridden now.
+	user.Spec.Config.Raw = newConflictTestMachineConfig("", nil, []ign3types.Unit{{Name: "kubelet.service", Contents: helpers.StrToPtr("[Unit]\n")}}).Spec.Config.Raw
+	conflicts, err = DetectMachineConfigConflicts([]*mcfgv1.MachineConfig{base, kubelet, vendor, generatedKubelet, user})
+	require.NoError(t, err)
+	assert.Equal(t, []mcfgv1.MachineConfigConflict{
+		{Type: mcfgv1.MachineConfigConflictFile, Name: "/etc/chrony.conf", Winner: "50-vendor", Losers: []string{"00-worker"}},
+		{Type: mcfgv1.MachineConfigConflictUnit, Name: "kubelet.service", Winner: "99-worker-chrony", Losers: []string{"00-worker"}},
+	}, conflicts)
+
+	conflicts, err = DetectMachineConfigConflicts([]*mcfgv1.MachineConfig{base, kubelet, generatedKubelet})
+	require.NoError(t, err)
+	assert.Empty(t, conflicts)
+}
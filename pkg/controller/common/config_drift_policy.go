@@ -0,0 +1,111 @@
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sort"
+
+	mcfgv1 "github.com/openshift/machine-config-operator/pkg/apis/machineconfiguration.openshift.io/v1"
+)
+
+// ConfigDriftAction is how the Config Drift Monitor reacts to drift on a path.
+type ConfigDriftAction string
+
+const (
+	// ConfigDriftActionIgnore skips the path entirely.
+	ConfigDriftActionIgnore ConfigDriftAction = "Ignore"
+	// ConfigDriftActionWarn emits an event and a metric but leaves the node alone.
+	ConfigDriftActionWarn ConfigDriftAction = "Warn"
+	// ConfigDriftActionDegrade marks the node Degraded. This is the default.
+	ConfigDriftActionDegrade ConfigDriftAction = "Degrade"
+)
+
+// ConfigDriftPolicyRule applies an action to the files, units and dropins
+// whose path matches a glob pattern (as understood by filepath.Match).
+type ConfigDriftPolicyRule struct {
+	Path   string            `json:"path"`
+	Action ConfigDriftAction `json:"action"`
+}
+
+// ConfigDriftPolicy is an ordered list of rules. The first rule matching a
+// path wins; paths without a matching rule are degraded on drift.
+type ConfigDriftPolicy []ConfigDriftPolicyRule
+
+// ActionForPath returns the action of the first rule matching path.
+func (p ConfigDriftPolicy) ActionForPath(path string) ConfigDriftAction {
+	for _, rule := range p {
+		// Patterns are validated by ParseConfigDriftPolicy, so the error can
+		// be ignored here.
+		if matched, _ := filepath.Match(rule.Path, path); matched {
+			return rule.Action
+		}
+	}
+	return ConfigDriftActionDegrade
+}
+
+// ParseConfigDriftPolicy parses and validates a JSON encoded ConfigDriftPolicy.
+func ParseConfigDriftPolicy(data string) (ConfigDriftPolicy, error) {
+	policy := ConfigDriftPolicy{}
+	if data == "" {
+		return policy, nil
+	}
+
+	if err := json.Unmarshal([]byte(data), &policy); err != nil {
+		return nil, fmt.Errorf("could not parse config drift policy: %w", err)
+	}
+
+	for _, rule := range policy {
+		if !filepath.IsAbs(rule.Path) {
+			return nil, fmt.Errorf("config drift policy path %q must be absolute", rule.Path)
+		}
+		if _, err := filepath.Match(rule.Path, ""); err != nil {
+			return nil, fmt.Errorf("config drift policy path %q is not a valid pattern: %w", rule.Path, err)
+		}
+		switch rule.Action {
+		case ConfigDriftActionIgnore, ConfigDriftActionWarn, ConfigDriftActionDegrade:
+		default:
+			return nil, fmt.Errorf("config drift policy action %q for path %q is invalid", rule.Action, rule.Path)
+		}
+	}
+
+	return policy, nil
+}
+
+// GetConfigDriftPolicy returns the ConfigDriftPolicy of a MachineConfig.
+func GetConfigDriftPolicy(mc *mcfgv1.MachineConfig) (ConfigDriftPolicy, error) {
+	policy, err := ParseConfigDriftPolicy(mc.Annotations[ConfigDriftPolicyAnnotationKey])
+	if err != nil {
+		return nil, fmt.Errorf("MachineConfig %s: %w", mc.Name, err)
+	}
+	return policy, nil
+}
+
+// MergeConfigDriftPolicies concatenates the policies of the given
+// MachineConfigs and returns them JSON encoded, or an empty string if none of
+// them has a policy. Like MergeMachineConfigs, the lexically last
+// MachineConfig wins, so its rules come first.
+func MergeConfigDriftPolicies(configs []*mcfgv1.MachineConfig) (string, error) {
+	sorted := make([]*mcfgv1.MachineConfig, len(configs))
+	copy(sorted, configs)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Name > sorted[j].Name })
+
+	merged := ConfigDriftPolicy{}
+	for _, config := range sorted {
+		policy, err := GetConfigDriftPolicy(config)
+		if err != nil {
+			return "", err
+		}
+		merged = append(merged, policy...)
+	}
+
+	if len(merged) == 0 {
+		return "", nil
+	}
+
+	out, err := json.Marshal(merged)
+	if err != nil {
+		return "", fmt.Errorf("could not marshal config drift policy: %w", err)
+	}
+	return string(out), nil
+}
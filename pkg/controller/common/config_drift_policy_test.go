@@ -0,0 +1,67 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseConfigDriftPolicy(t *testing.T) {
+	testCases := []struct {
+		name        string
+		data        string
+		errExpected bool
+		actions     map[string]ConfigDriftAction
+	}{
+		{
+			name:    "empty policy",
+			actions: map[string]ConfigDriftAction{"/etc/foo": ConfigDriftActionDegrade},
+		},
+		{
+			name: "first match wins",
+			data: `[{"path":"/etc/resolv.conf.d/*","action":"Ignore"},{"path":"/etc/*","action":"Warn"},{"path":"/etc/resolv.conf.d/foo","action":"Degrade"}]`,
+			actions: map[string]ConfigDriftAction{
+				"/etc/resolv.conf.d/foo": ConfigDriftActionIgnore,
+				"/etc/chrony.conf":       ConfigDriftActionWarn,
+				"/etc/sysconfig/foo":     ConfigDriftActionDegrade,
+				"/var/lib/foo":           ConfigDriftActionDegrade,
+			},
+		},
+		{
+			name:        "invalid json",
+			data:        `{"path":"/etc/foo"}`,
+			errExpected: true,
+		},
+		{
+			name:        "invalid action",
+			data:        `[{"path":"/etc/foo","action":"Explode"}]`,
+			errExpected: true,
+		},
+		{
+			name:        "relative path",
+			data:        `[{"path":"etc/foo","action":"Ignore"}]`,
+			errExpected: true,
+		},
+		{
+			name:        "invalid pattern",
+			data:        `[{"path":"/etc/[foo","action":"Ignore"}]`,
+			errExpected: true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			policy, err := ParseConfigDriftPolicy(testCase.data)
+			if testCase.errExpected {
+				assert.Error(t, err)
+				return
+			}
+
+			assert.NoError(t, err)
+			for path, action := range testCase.actions {
+				assert.Equal(t, action, policy.ActionForPath(path), path)
+			}
+		})
+	}
+}
@@ -13,6 +13,51 @@ const (
 	// OSImageURLOverriddenKey is used to tag a rendered machineconfig when OSImageURL has been overridden from default using machineconfig
 	OSImageURLOverriddenKey = "machineconfiguration.openshift.io/os-image-url-overridden"
 
+	// ConfigDriftPolicyAnnotationKey is set on a MachineConfig to choose how the Config Drift Monitor reacts to drift
+	// on the paths it matches. The render controller merges the policies of all source MachineConfigs into the rendered one.
+	ConfigDriftPolicyAnnotationKey = "machineconfiguration.openshift.io/config-drift-policy"
+
+	// FileReferencesAnnotationKey is set on a rendered MachineConfig to the Secrets and ConfigMaps, with a hash of
+	// the files resolved from them, that the file references of its source MachineConfigs were resolved from.
+	FileReferencesAnnotationKey = "machineconfiguration.openshift.io/file-references"
+
+	// EncryptSensitiveFilesAnnotationKey is set to "true" on a MachineConfigPool to encrypt the contents of sensitive files,
+	// i.e. the pull secret and files referencing Secrets, in its rendered MachineConfigs.
+	EncryptSensitiveFilesAnnotationKey = "machineconfiguration.openshift.io/encrypt-sensitive-files"
+
+	// EncryptedFilesAnnotationKey is set on a rendered MachineConfig to the comma separated paths of the files whose
+	// contents are encrypted.
+	EncryptedFilesAnnotationKey = "machineconfiguration.openshift.io/encrypted-files"
+
+	// ContentEncryptionKeySecretName is the Secret in the MCO namespace holding the key the keys of rendered
+	// MachineConfigs with encrypted file contents are derived from.
+	ContentEncryptionKeySecretName = "machine-config-content-encryption-key"
+
+	// NodePullSecretName is the Secret in the MCO namespace holding the pull secret of the nodes. The 00-<role>
+	// MachineConfigs selected by pools with EncryptSensitiveFilesAnnotationKey reference it instead of holding the
+	// pull secret, so that only rendered MachineConfigs do.
+	NodePullSecretName = "machine-config-node-pull-secret"
+
+	// RestartServicesAnnotationKey is set on a rendered MachineConfig to the JSON encoded map from the paths of the
+	// files rendered from node settings to the systemd unit that applies them, which the daemon restarts instead of
+	// rebooting when only such files change.
+	RestartServicesAnnotationKey = "machineconfiguration.openshift.io/restart-services"
+
+	// SourceConfigsAnnotationKey is set on a rendered MachineConfig to the JSON encoded list of the source
+	// MachineConfigs it was rendered from, with their resourceVersions, generations and content hashes.
+	SourceConfigsAnnotationKey = "machineconfiguration.openshift.io/source-configs"
+
+	// ConfigConflictPolicyAnnotationKey is set on a MachineConfigPool to choose how the render controller reacts to
+	// source MachineConfigs defining the same file, unit or dropin differently: ConfigConflictPolicyWarn (the
+	// default) records them in the pool status, ConfigConflictPolicyDeny also refuses to render.
+	ConfigConflictPolicyAnnotationKey = "machineconfiguration.openshift.io/config-conflict-policy"
+
+	// ConfigConflictPolicyWarn records conflicts between source MachineConfigs and renders anyway.
+	ConfigConflictPolicyWarn = "Warn"
+
+	// ConfigConflictPolicyDeny refuses to render conflicting source MachineConfigs.
+	ConfigConflictPolicyDeny = "Deny"
+
 	// ControllerConfigName is the name of the ControllerConfig object that controllers use
 	ControllerConfigName = "machine-config-controller"
 
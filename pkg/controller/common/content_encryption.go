@@ -0,0 +1,266 @@
+package common
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	ign3types "github.com/coreos/ignition/v2/config/v3_4/types"
+
+	mcfgv1 "github.com/openshift/machine-config-operator/pkg/apis/machineconfiguration.openshift.io/v1"
+)
+
+const (
+	// ContentEncryptionKeySecretKey is the key of ContentEncryptionKeySecretName holding the key.
+	ContentEncryptionKeySecretKey = "key"
+
+	// encryptedContentsPrefix starts the data URL of encrypted file contents. The
+	// data is the nonce followed by the sealed data URL of the plaintext contents.
+	encryptedContentsPrefix = "data:application/vnd.openshift.machineconfig.encrypted;base64,"
+
+	// kubeletPullSecretPath is the pull secret written from the templates.
+	kubeletPullSecretPath = "/var/lib/kubelet/config.json"
+
+	contentEncryptionKeySize = 32
+)
+
+// ContentEncryptionKeys is the value of the node annotation holding the keys
+// of rendered MachineConfigs wrapped for the node.
+type ContentEncryptionKeys struct {
+	// PublicKey is the public key of the node the keys are wrapped for.
+	PublicKey string `json:"publicKey"`
+	// Keys are the wrapped keys by rendered MachineConfig name.
+	Keys map[string]string `json:"keys"`
+}
+
+// NewContentEncryptionKey returns a new random key for ContentEncryptionKeySecretName.
+func NewContentEncryptionKey() ([]byte, error) {
+	key := make([]byte, contentEncryptionKeySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// DeriveMachineConfigKey returns the key the file contents of the rendered
+// MachineConfig called name are encrypted with.
+func DeriveMachineConfigKey(encryptionKey []byte, name string) []byte {
+	mac := hmac.New(sha256.New, encryptionKey)
+	mac.Write([]byte("machineconfig/" + name))
+	return mac.Sum(nil)
+}
+
+// SensitiveFilePaths returns the paths of the files in the Ignition config of
+// merged which are sensitive: the pull secret and the files the configs
+// reference Secrets for.
+func SensitiveFilePaths(configs []*mcfgv1.MachineConfig, merged *mcfgv1.MachineConfig) ([]string, error) {
+	sensitive := map[string]bool{kubeletPullSecretPath: true}
+	for _, config := range configs {
+		for _, ref := range config.Spec.FileReferences {
+			if ref.SecretKeyRef != nil {
+				sensitive[ref.Path] = true
+			}
+		}
+	}
+
+	ignCfg, err := ParseAndConvertConfig(merged.Spec.Config.Raw)
+	if err != nil {
+		return nil, err
+	}
+	var paths []string
+	for _, file := range ignCfg.Storage.Files {
+		if sensitive[file.Path] && file.Contents.Source != nil {
+			paths = append(paths, file.Path)
+		}
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// EncryptMachineConfigFiles encrypts the contents of the files at paths in the
+// Ignition config of config with key. The encryption is deterministic, so that
+// rendering the same config again results in the same MachineConfig.
+func EncryptMachineConfigFiles(config *mcfgv1.MachineConfig, key []byte, paths []string) error {
+	ignCfg, err := ParseAndConvertConfig(config.Spec.Config.Raw)
+	if err != nil {
+		return err
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return err
+	}
+
+	encrypt := map[string]bool{}
+	for _, path := range paths {
+		encrypt[path] = true
+	}
+	for idx, file := range ignCfg.Storage.Files {
+		if !encrypt[file.Path] || file.Contents.Source == nil || IsEncryptedFileSource(*file.Contents.Source) {
+			continue
+		}
+		source := *file.Contents.Source
+		mac := hmac.New(sha256.New, key)
+		mac.Write([]byte(file.Path + "\x00" + source))
+		nonce := mac.Sum(nil)[:aead.NonceSize()]
+		sealed := aead.Seal(nonce, nonce, []byte(source), []byte(file.Path))
+		encrypted := encryptedContentsPrefix + base64.StdEncoding.EncodeToString(sealed)
+		ignCfg.Storage.Files[idx].Contents.Source = &encrypted
+	}
+
+	raw, err := json.Marshal(ignCfg)
+	if err != nil {
+		return err
+	}
+	config.Spec.Config.Raw = raw
+	return nil
+}
+
+// IsMachineConfigEncrypted returns whether config has encrypted file contents.
+func IsMachineConfigEncrypted(config *mcfgv1.MachineConfig) bool {
+	return config.Annotations[EncryptedFilesAnnotationKey] != ""
+}
+
+// DecryptMachineConfig returns a copy of config with the file contents
+// encrypted by EncryptMachineConfigFiles decrypted with key.
+func DecryptMachineConfig(config *mcfgv1.MachineConfig, key []byte) (*mcfgv1.MachineConfig, error) {
+	ignCfg, err := ParseAndConvertConfig(config.Spec.Config.Raw)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+
+	for idx, file := range ignCfg.Storage.Files {
+		if file.Contents.Source == nil || !IsEncryptedFileSource(*file.Contents.Source) {
+			continue
+		}
+		source, err := decryptSource(aead, file)
+		if err != nil {
+			return nil, fmt.Errorf("could not decrypt contents of %s in MachineConfig %s: %w", file.Path, config.Name, err)
+		}
+		ignCfg.Storage.Files[idx].Contents.Source = &source
+	}
+
+	raw, err := json.Marshal(ignCfg)
+	if err != nil {
+		return nil, err
+	}
+	out := config.DeepCopy()
+	out.Spec.Config.Raw = raw
+	return out, nil
+}
+
+func decryptSource(aead cipher.AEAD, file ign3types.File) (string, error) {
+	sealed, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(*file.Contents.Source, encryptedContentsPrefix))
+	if err != nil {
+		return "", err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return "", fmt.Errorf("encrypted contents too short")
+	}
+	source, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], []byte(file.Path))
+	if err != nil {
+		return "", err
+	}
+	return string(source), nil
+}
+
+// IsEncryptedFileSource returns whether the contents source of a file was
+// encrypted by EncryptMachineConfigFiles.
+func IsEncryptedFileSource(source string) bool {
+	return strings.HasPrefix(source, encryptedContentsPrefix)
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// EncodeContentEncryptionPublicKey returns the annotation value for the public
+// key of a node.
+func EncodeContentEncryptionPublicKey(key *ecdh.PublicKey) string {
+	return base64.StdEncoding.EncodeToString(key.Bytes())
+}
+
+// WrapMachineConfigKey encrypts key for the node with the given base64 encoded
+// X25519 public key, using a new ephemeral key pair for every call.
+func WrapMachineConfigKey(publicKey string, key []byte) (string, error) {
+	pubBytes, err := base64.StdEncoding.DecodeString(publicKey)
+	if err != nil {
+		return "", fmt.Errorf("invalid public key: %w", err)
+	}
+	pub, err := ecdh.X25519().NewPublicKey(pubBytes)
+	if err != nil {
+		return "", fmt.Errorf("invalid public key: %w", err)
+	}
+	ephemeral, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return "", err
+	}
+	aead, err := wrappingAEAD(ephemeral, pub, ephemeral.PublicKey(), pub)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	out := append([]byte{}, ephemeral.PublicKey().Bytes()...)
+	out = append(out, nonce...)
+	out = aead.Seal(out, nonce, key, nil)
+	return base64.StdEncoding.EncodeToString(out), nil
+}
+
+// UnwrapMachineConfigKey decrypts a key wrapped by WrapMachineConfigKey with
+// the private key of the node.
+func UnwrapMachineConfigKey(privateKey *ecdh.PrivateKey, wrapped string) ([]byte, error) {
+	data, err := base64.StdEncoding.DecodeString(wrapped)
+	if err != nil {
+		return nil, err
+	}
+	pubSize := len(privateKey.PublicKey().Bytes())
+	if len(data) < pubSize {
+		return nil, fmt.Errorf("wrapped key too short")
+	}
+	ephemeral, err := ecdh.X25519().NewPublicKey(data[:pubSize])
+	if err != nil {
+		return nil, err
+	}
+	aead, err := wrappingAEAD(privateKey, ephemeral, ephemeral, privateKey.PublicKey())
+	if err != nil {
+		return nil, err
+	}
+	data = data[pubSize:]
+	if len(data) < aead.NonceSize() {
+		return nil, fmt.Errorf("wrapped key too short")
+	}
+	return aead.Open(nil, data[:aead.NonceSize()], data[aead.NonceSize():], nil)
+}
+
+// wrappingAEAD returns the cipher for the shared secret of priv and peer. The
+// ephemeral and node public keys are mixed in, so the key is bound to both.
+func wrappingAEAD(priv *ecdh.PrivateKey, peer, ephemeral, node *ecdh.PublicKey) (cipher.AEAD, error) {
+	shared, err := priv.ECDH(peer)
+	if err != nil {
+		return nil, err
+	}
+	h := sha256.New()
+	h.Write(shared)
+	h.Write(ephemeral.Bytes())
+	h.Write(node.Bytes())
+	return newAEAD(h.Sum(nil))
+}
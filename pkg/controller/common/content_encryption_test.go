@@ -0,0 +1,90 @@
+package common
+
+import (
+	"crypto/ecdh"
+	"crypto/rand"
+	"testing"
+
+	ign3types "github.com/coreos/ignition/v2/config/v3_4/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	mcfgv1 "github.com/openshift/machine-config-operator/pkg/apis/machineconfiguration.openshift.io/v1"
+	"github.com/openshift/machine-config-operator/test/helpers"
+)
+
+func TestEncryptMachineConfigFiles(t *testing.T) {
+	kek, err := NewContentEncryptionKey()
+	require.NoError(t, err)
+	key := DeriveMachineConfigKey(kek, "rendered-worker-1")
+	assert.NotEqual(t, key, DeriveMachineConfigKey(kek, "rendered-worker-2"))
+
+	withRefs := helpers.NewMachineConfig("99-refs", nil, "", []ign3types.File{})
+	withRefs.Spec.FileReferences = []mcfgv1.MachineConfigFileReference{
+		secretFileReference("/etc/token", "creds", "token", false),
+		configMapFileReference("/etc/chrony.conf", "settings", "chrony.conf"),
+		secretFileReference("/etc/absent", "creds", "other", true),
+	}
+	mc := helpers.NewMachineConfig("rendered-worker-1", nil, "", []ign3types.File{
+		NewIgnFile("/var/lib/kubelet/config.json", "pull-secret"),
+		NewIgnFile("/etc/token", "s3cr3t"),
+		NewIgnFile("/etc/chrony.conf", "pool example.com iburst\n"),
+	})
+
+	paths, err := SensitiveFilePaths([]*mcfgv1.MachineConfig{withRefs}, mc)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"/etc/token", "/var/lib/kubelet/config.json"}, paths)
+
+	encrypted := mc.DeepCopy()
+	require.NoError(t, EncryptMachineConfigFiles(encrypted, key, paths))
+	ignCfg, err := ParseAndConvertConfig(encrypted.Spec.Config.Raw)
+	require.NoError(t, err)
+	for _, file := range ignCfg.Storage.Files {
+		assert.Equal(t, file.Path != "/etc/chrony.conf", IsEncryptedFileSource(*file.Contents.Source), file.Path)
+	}
+
+	// Encrypting is deterministic and idempotent.
+	again := mc.DeepCopy()
+	require.NoError(t, EncryptMachineConfigFiles(again, key, paths))
+	assert.Equal(t, encrypted.Spec.Config.Raw, again.Spec.Config.Raw)
+	require.NoError(t, EncryptMachineConfigFiles(again, key, paths))
+	assert.Equal(t, encrypted.Spec.Config.Raw, again.Spec.Config.Raw)
+
+	decrypted, err := DecryptMachineConfig(encrypted, key)
+	require.NoError(t, err)
+	ignCfg, err = ParseAndConvertConfig(decrypted.Spec.Config.Raw)
+	require.NoError(t, err)
+	for path, expected := range map[string]string{
+		"/var/lib/kubelet/config.json": "pull-secret",
+		"/etc/token":                   "s3cr3t",
+		"/etc/chrony.conf":             "pool example.com iburst\n",
+	} {
+		contents, err := GetIgnitionFileDataByPath(&ignCfg, path)
+		require.NoError(t, err)
+		assert.Equal(t, expected, string(contents), path)
+	}
+
+	_, err = DecryptMachineConfig(encrypted, DeriveMachineConfigKey(kek, "rendered-worker-2"))
+	assert.Error(t, err)
+}
+
+func TestWrapMachineConfigKey(t *testing.T) {
+	nodeKey, err := ecdh.X25519().GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	otherKey, err := ecdh.X25519().GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	key := DeriveMachineConfigKey([]byte("kek"), "rendered-worker-1")
+
+	wrapped, err := WrapMachineConfigKey(EncodeContentEncryptionPublicKey(nodeKey.PublicKey()), key)
+	require.NoError(t, err)
+
+	unwrapped, err := UnwrapMachineConfigKey(nodeKey, wrapped)
+	require.NoError(t, err)
+	assert.Equal(t, key, unwrapped)
+
+	_, err = UnwrapMachineConfigKey(otherKey, wrapped)
+	assert.Error(t, err)
+
+	_, err = WrapMachineConfigKey("not a key", key)
+	assert.Error(t, err)
+}
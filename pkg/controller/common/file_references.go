@@ -0,0 +1,237 @@
+package common
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sort"
+
+	ign3types "github.com/coreos/ignition/v2/config/v3_4/types"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	corelisterv1 "k8s.io/client-go/listers/core/v1"
+
+	mcfgv1 "github.com/openshift/machine-config-operator/pkg/apis/machineconfiguration.openshift.io/v1"
+)
+
+const (
+	// defaultSecretFileMode is the mode of files referencing a Secret.
+	defaultSecretFileMode = 0o600
+	// defaultConfigMapFileMode is the mode of files referencing a ConfigMap.
+	defaultConfigMapFileMode = 0o644
+)
+
+// FileReferenceResolver looks up the Secrets and ConfigMaps that MachineConfig
+// file references point to. Both listers are scoped to the MCO namespace.
+type FileReferenceResolver struct {
+	Secrets    corelisterv1.SecretNamespaceLister
+	ConfigMaps corelisterv1.ConfigMapNamespaceLister
+}
+
+// ValidateFileReferences checks that every file reference has an absolute
+// path and exactly one complete key selector.
+func ValidateFileReferences(refs []mcfgv1.MachineConfigFileReference) error {
+	paths := map[string]bool{}
+	for _, ref := range refs {
+		if !filepath.IsAbs(ref.Path) {
+			return fmt.Errorf("file reference path %q must be absolute", ref.Path)
+		}
+		if paths[ref.Path] {
+			return fmt.Errorf("file reference path %q is referenced more than once", ref.Path)
+		}
+		paths[ref.Path] = true
+
+		switch {
+		case ref.SecretKeyRef != nil && ref.ConfigMapKeyRef != nil:
+			return fmt.Errorf("file reference %s must set only one of secretKeyRef and configMapKeyRef", ref.Path)
+		case ref.SecretKeyRef != nil:
+			if ref.SecretKeyRef.Name == "" || ref.SecretKeyRef.Key == "" {
+				return fmt.Errorf("file reference %s: secretKeyRef needs a name and a key", ref.Path)
+			}
+		case ref.ConfigMapKeyRef != nil:
+			if ref.ConfigMapKeyRef.Name == "" || ref.ConfigMapKeyRef.Key == "" {
+				return fmt.Errorf("file reference %s: configMapKeyRef needs a name and a key", ref.Path)
+			}
+		default:
+			return fmt.Errorf("file reference %s must set one of secretKeyRef and configMapKeyRef", ref.Path)
+		}
+		if ref.Mode != nil && (*ref.Mode < 0 || *ref.Mode > 0o7777) {
+			return fmt.Errorf("file reference %s has invalid mode %o", ref.Path, *ref.Mode)
+		}
+	}
+	return nil
+}
+
+// ResolveFileReferences returns configs with the file references replaced by
+// Ignition files holding the referenced contents. The files replace those with
+// the same path in the Ignition config of the same MachineConfig, so the merge
+// order of the MachineConfigs is kept. MachineConfigs without file references
+// are returned as is, the others are copied.
+//
+// The second return value lists the referenced objects as Kind/name@hash,
+// sorted and without duplicates. The hash covers the files resolved from the
+// object rather than its resourceVersion, so that the same contents give the
+// same rendered config during bootstrap and in the cluster. References to
+// missing optional objects or keys are skipped, other missing references are
+// an error.
+func ResolveFileReferences(configs []*mcfgv1.MachineConfig, resolver *FileReferenceResolver) ([]*mcfgv1.MachineConfig, []string, error) {
+	resolved := make([]*mcfgv1.MachineConfig, 0, len(configs))
+	// The resolved files of each referenced object, for hashing.
+	sources := map[string][]byte{}
+	for _, config := range configs {
+		if len(config.Spec.FileReferences) == 0 {
+			resolved = append(resolved, config)
+			continue
+		}
+		if resolver == nil {
+			return nil, nil, fmt.Errorf("MachineConfig %s has file references, but they can not be resolved here", config.Name)
+		}
+
+		var files []ign3types.File
+		for _, ref := range config.Spec.FileReferences {
+			file, source, err := resolver.resolve(ref)
+			if err != nil {
+				return nil, nil, fmt.Errorf("could not resolve file reference %s of MachineConfig %s: %w", ref.Path, config.Name, err)
+			}
+			if source == "" {
+				continue
+			}
+			if _, ok := sources[source]; !ok {
+				sources[source] = []byte{}
+			}
+			if file != nil {
+				data, err := json.Marshal(file)
+				if err != nil {
+					return nil, nil, fmt.Errorf("could not hash file reference %s of MachineConfig %s: %w", ref.Path, config.Name, err)
+				}
+				sources[source] = append(sources[source], data...)
+				files = append(files, *file)
+			}
+		}
+
+		out, err := replaceIgnFiles(config, files)
+		if err != nil {
+			return nil, nil, fmt.Errorf("could not add file references to MachineConfig %s: %w", config.Name, err)
+		}
+		out.Spec.FileReferences = nil
+		resolved = append(resolved, out)
+	}
+
+	sourceList := make([]string, 0, len(sources))
+	for source, data := range sources {
+		sourceList = append(sourceList, fmt.Sprintf("%s@%x", source, sha256.Sum256(data)))
+	}
+	sort.Strings(sourceList)
+	return resolved, sourceList, nil
+}
+
+// resolve returns the Ignition file for a file reference and the object it was
+// read from. The file is nil when an optional reference is missing.
+func (r *FileReferenceResolver) resolve(ref mcfgv1.MachineConfigFileReference) (*ign3types.File, string, error) {
+	var (
+		contents []byte
+		mode     int
+		source   string
+		found    bool
+		optional bool
+	)
+
+	switch {
+	case ref.SecretKeyRef != nil:
+		sel := ref.SecretKeyRef
+		optional = sel.Optional != nil && *sel.Optional
+		mode = defaultSecretFileMode
+		secret, err := r.Secrets.Get(sel.Name)
+		if err != nil && !apierrors.IsNotFound(err) {
+			return nil, "", err
+		}
+		if err == nil {
+			source = "Secret/" + secret.Name
+			contents, found = secret.Data[sel.Key]
+		}
+		if !found && !optional {
+			return nil, "", fmt.Errorf("key %q of Secret %s/%s not found", sel.Key, MCONamespace, sel.Name)
+		}
+	case ref.ConfigMapKeyRef != nil:
+		sel := ref.ConfigMapKeyRef
+		optional = sel.Optional != nil && *sel.Optional
+		mode = defaultConfigMapFileMode
+		cm, err := r.ConfigMaps.Get(sel.Name)
+		if err != nil && !apierrors.IsNotFound(err) {
+			return nil, "", err
+		}
+		if err == nil {
+			source = "ConfigMap/" + cm.Name
+			var s string
+			s, found = cm.Data[sel.Key]
+			contents = []byte(s)
+			if !found {
+				contents, found = cm.BinaryData[sel.Key]
+			}
+		}
+		if !found && !optional {
+			return nil, "", fmt.Errorf("key %q of ConfigMap %s/%s not found", sel.Key, MCONamespace, sel.Name)
+		}
+	default:
+		return nil, "", fmt.Errorf("neither secretKeyRef nor configMapKeyRef is set")
+	}
+
+	if !found {
+		return nil, source, nil
+	}
+
+	if ref.Mode != nil {
+		mode = *ref.Mode
+	}
+	file := NewIgnFileBytesOverwriting(ref.Path, contents)
+	file.Mode = &mode
+	return &file, source, nil
+}
+
+// replaceIgnFiles returns a copy of config with files added to its Ignition
+// config, replacing files with the same path.
+func replaceIgnFiles(config *mcfgv1.MachineConfig, files []ign3types.File) (*mcfgv1.MachineConfig, error) {
+	ignCfg := NewIgnConfig()
+	if config.Spec.Config.Raw != nil {
+		var err error
+		ignCfg, err = ParseAndConvertConfig(config.Spec.Config.Raw)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	replaced := map[string]bool{}
+	for _, file := range files {
+		replaced[file.Path] = true
+	}
+	kept := []ign3types.File{}
+	for _, file := range ignCfg.Storage.Files {
+		if !replaced[file.Path] {
+			kept = append(kept, file)
+		}
+	}
+	ignCfg.Storage.Files = append(kept, files...)
+
+	raw, err := json.Marshal(ignCfg)
+	if err != nil {
+		return nil, err
+	}
+
+	out := config.DeepCopy()
+	out.Spec.Config.Raw = raw
+	return out, nil
+}
+
+// MachineConfigReferencesObject returns whether config has a file reference to
+// the object of the given kind, Secret or ConfigMap, called name.
+func MachineConfigReferencesObject(config *mcfgv1.MachineConfig, kind, name string) bool {
+	for _, ref := range config.Spec.FileReferences {
+		switch {
+		case ref.SecretKeyRef != nil && kind == "Secret" && ref.SecretKeyRef.Name == name:
+			return true
+		case ref.ConfigMapKeyRef != nil && kind == "ConfigMap" && ref.ConfigMapKeyRef.Name == name:
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,217 @@
+package common
+
+import (
+	"testing"
+
+	ign3types "github.com/coreos/ignition/v2/config/v3_4/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	corelisterv1 "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+
+	mcfgv1 "github.com/openshift/machine-config-operator/pkg/apis/machineconfiguration.openshift.io/v1"
+	"github.com/openshift/machine-config-operator/test/helpers"
+)
+
+func newTestFileReferenceResolver(t *testing.T) *FileReferenceResolver {
+	secrets := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	configMaps := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	require.NoError(t, secrets.Add(&corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "creds", Namespace: MCONamespace, ResourceVersion: "7"},
+		Data:       map[string][]byte{"token": []byte("s3cr3t")},
+	}))
+	require.NoError(t, configMaps.Add(&corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "settings", Namespace: MCONamespace, ResourceVersion: "3"},
+		Data:       map[string]string{"chrony.conf": "pool example.com iburst\n"},
+	}))
+	// Objects in other namespaces must not be visible.
+	require.NoError(t, secrets.Add(&corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "elsewhere", Namespace: "default", ResourceVersion: "1"},
+		Data:       map[string][]byte{"token": []byte("nope")},
+	}))
+
+	return &FileReferenceResolver{
+		Secrets:    corelisterv1.NewSecretLister(secrets).Secrets(MCONamespace),
+		ConfigMaps: corelisterv1.NewConfigMapLister(configMaps).ConfigMaps(MCONamespace),
+	}
+}
+
+func secretFileReference(path, name, key string, optional bool) mcfgv1.MachineConfigFileReference {
+	return mcfgv1.MachineConfigFileReference{
+		Path: path,
+		SecretKeyRef: &corev1.SecretKeySelector{
+			LocalObjectReference: corev1.LocalObjectReference{Name: name},
+			Key:                  key,
+			Optional:             &optional,
+		},
+	}
+}
+
+func configMapFileReference(path, name, key string) mcfgv1.MachineConfigFileReference {
+	return mcfgv1.MachineConfigFileReference{
+		Path: path,
+		ConfigMapKeyRef: &corev1.ConfigMapKeySelector{
+			LocalObjectReference: corev1.LocalObjectReference{Name: name},
+			Key:                  key,
+		},
+	}
+}
+
+func TestResolveFileReferences(t *testing.T) {
+	resolver := newTestFileReferenceResolver(t)
+
+	plain := helpers.NewMachineConfig("00-plain", nil, "", []ign3types.File{})
+	withRefs := helpers.NewMachineConfig("99-refs", nil, "", []ign3types.File{
+		NewIgnFile("/etc/chrony.conf", "replaced"),
+		NewIgnFile("/etc/kept", "kept"),
+	})
+	mode := 0o640
+	withRefs.Spec.FileReferences = []mcfgv1.MachineConfigFileReference{
+		secretFileReference("/etc/token", "creds", "token", false),
+		configMapFileReference("/etc/chrony.conf", "settings", "chrony.conf"),
+		secretFileReference("/etc/optional", "missing", "token", true),
+	}
+	withRefs.Spec.FileReferences[1].Mode = &mode
+
+	resolved, referenced, err := ResolveFileReferences([]*mcfgv1.MachineConfig{plain, withRefs}, resolver)
+	require.NoError(t, err)
+	require.Len(t, referenced, 2)
+	assert.Regexp(t, "^ConfigMap/settings@[0-9a-f]{64}$", referenced[0])
+	assert.Regexp(t, "^Secret/creds@[0-9a-f]{64}$", referenced[1])
+
+	// MachineConfigs without references are passed through, the others are
+	// copied so that the lister cache is not modified.
+	assert.Same(t, plain, resolved[0])
+	assert.NotSame(t, withRefs, resolved[1])
+	assert.Len(t, withRefs.Spec.FileReferences, 3)
+	assert.Empty(t, resolved[1].Spec.FileReferences)
+
+	ignCfg, err := ParseAndConvertConfig(resolved[1].Spec.Config.Raw)
+	require.NoError(t, err)
+	modes := map[string]int{}
+	for _, file := range ignCfg.Storage.Files {
+		modes[file.Path] = *file.Mode
+	}
+	assert.Equal(t, map[string]int{"/etc/kept": 0o644, "/etc/token": 0o600, "/etc/chrony.conf": 0o640}, modes)
+
+	contents, err := GetIgnitionFileDataByPath(&ignCfg, "/etc/token")
+	require.NoError(t, err)
+	assert.Equal(t, "s3cr3t", string(contents))
+	contents, err = GetIgnitionFileDataByPath(&ignCfg, "/etc/chrony.conf")
+	require.NoError(t, err)
+	assert.Equal(t, "pool example.com iburst\n", string(contents))
+}
+
+func TestResolveFileReferencesErrors(t *testing.T) {
+	resolver := newTestFileReferenceResolver(t)
+
+	testCases := []struct {
+		name     string
+		ref      mcfgv1.MachineConfigFileReference
+		resolver *FileReferenceResolver
+	}{
+		{
+			name:     "missing secret",
+			ref:      secretFileReference("/etc/token", "missing", "token", false),
+			resolver: resolver,
+		},
+		{
+			name:     "missing key",
+			ref:      secretFileReference("/etc/token", "creds", "password", false),
+			resolver: resolver,
+		},
+		{
+			name:     "other namespace",
+			ref:      secretFileReference("/etc/token", "elsewhere", "token", false),
+			resolver: resolver,
+		},
+		{
+			name:     "missing configmap",
+			ref:      configMapFileReference("/etc/chrony.conf", "missing", "chrony.conf"),
+			resolver: resolver,
+		},
+		{
+			name: "no resolver",
+			ref:  configMapFileReference("/etc/chrony.conf", "settings", "chrony.conf"),
+		},
+	}
+
+	for _, testCase := range testCases {
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			mc := helpers.NewMachineConfig("99-refs", nil, "", []ign3types.File{})
+			mc.Spec.FileReferences = []mcfgv1.MachineConfigFileReference{testCase.ref}
+			_, _, err := ResolveFileReferences([]*mcfgv1.MachineConfig{mc}, testCase.resolver)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestValidateFileReferences(t *testing.T) {
+	both := secretFileReference("/etc/token", "creds", "token", false)
+	both.ConfigMapKeyRef = &corev1.ConfigMapKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "settings"}, Key: "a"}
+	badMode := secretFileReference("/etc/token", "creds", "token", false)
+	mode := 0o10000
+	badMode.Mode = &mode
+
+	testCases := []struct {
+		name        string
+		refs        []mcfgv1.MachineConfigFileReference
+		errExpected bool
+	}{
+		{
+			name: "valid",
+			refs: []mcfgv1.MachineConfigFileReference{
+				secretFileReference("/etc/token", "creds", "token", false),
+				configMapFileReference("/etc/chrony.conf", "settings", "chrony.conf"),
+			},
+		},
+		{
+			name:        "relative path",
+			refs:        []mcfgv1.MachineConfigFileReference{secretFileReference("etc/token", "creds", "token", false)},
+			errExpected: true,
+		},
+		{
+			name: "duplicate path",
+			refs: []mcfgv1.MachineConfigFileReference{
+				secretFileReference("/etc/token", "creds", "token", false),
+				configMapFileReference("/etc/token", "settings", "chrony.conf"),
+			},
+			errExpected: true,
+		},
+		{
+			name:        "no selector",
+			refs:        []mcfgv1.MachineConfigFileReference{{Path: "/etc/token"}},
+			errExpected: true,
+		},
+		{
+			name:        "both selectors",
+			refs:        []mcfgv1.MachineConfigFileReference{both},
+			errExpected: true,
+		},
+		{
+			name:        "no key",
+			refs:        []mcfgv1.MachineConfigFileReference{configMapFileReference("/etc/chrony.conf", "settings", "")},
+			errExpected: true,
+		},
+		{
+			name:        "invalid mode",
+			refs:        []mcfgv1.MachineConfigFileReference{badMode},
+			errExpected: true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			err := ValidateFileReferences(testCase.refs)
+			if testCase.errExpected {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
@@ -589,7 +589,11 @@ func ValidateMachineConfig(cfg mcfgv1.MachineConfigSpec) error {
 			return err
 		}
 	}
-	return nil
+	if err := ValidateFileReferences(cfg.FileReferences); err != nil {
+		return err
+	}
+
+	return ValidateNodeSettings(cfg.NodeSettings)
 }
 
 // IgnParseWrapper parses rawIgn for both V2 and V3 ignition configs and returns
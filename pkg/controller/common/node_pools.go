@@ -0,0 +1,146 @@
+package common
+
+import (
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/klog/v2"
+
+	mcfgv1 "github.com/openshift/machine-config-operator/pkg/apis/machineconfiguration.openshift.io/v1"
+)
+
+// OSLabel is used to identify which type of OS the node has
+const OSLabel = "kubernetes.io/os"
+
+// IsWindowsNode checks if given node is a Windows node or a Linux node
+func IsWindowsNode(node *corev1.Node) bool {
+	windowsOsValue := "windows"
+	if value, ok := node.ObjectMeta.Labels[OSLabel]; ok {
+		if value == windowsOsValue {
+			return true
+		}
+		return false
+	}
+	// All the nodes should have a OS label populated by kubelet, if not just to maintain
+	// backwards compatibility, we can returning true here.
+	return false
+}
+
+// GetPoolsForNode chooses the MachineConfigPools from the given pools that should be used for a given node.
+// It disambiguates in the case where e.g. a node has both master/worker roles applied,
+// and where a custom role may be used. It returns a slice of all the pools the node belongs to.
+// It also ignores the Windows nodes. It sets the MCCPoolAlert metric of the node.
+func GetPoolsForNode(pl []*mcfgv1.MachineConfigPool, node *corev1.Node) ([]*mcfgv1.MachineConfigPool, error) {
+	if IsWindowsNode(node) {
+		// This is not an error, is this a Windows Node and it won't be managed by MCO. We're explicitly logging
+		// here at a high level to disambiguate this from other pools = nil  scenario
+		klog.V(4).Infof("Node %v is a windows node so won't be managed by MCO", node.Name)
+		return nil, nil
+	}
+
+	pools, shadowed, err := matchPoolsForNode(pl, node)
+	if err != nil || pools == nil {
+		return nil, err
+	}
+
+	if shadowed != nil {
+		klog.Infof("Found master node that matches selector for custom pool %v, defaulting to master. This node will not have any custom role configuration as a result. Please review the node to make sure this is intended", shadowed.Name)
+		MCCPoolAlert.WithLabelValues(node.Name).Set(1)
+	} else {
+		MCCPoolAlert.WithLabelValues(node.Name).Set(0)
+	}
+	return pools, nil
+}
+
+// GetPrimaryPoolForNode uses GetPoolsForNode and returns the first one which is the one the node targets
+func GetPrimaryPoolForNode(pl []*mcfgv1.MachineConfigPool, node *corev1.Node) (*mcfgv1.MachineConfigPool, error) {
+	pools, err := GetPoolsForNode(pl, node)
+	if err != nil {
+		return nil, err
+	}
+	if pools == nil {
+		return nil, nil
+	}
+	return pools[0], nil
+}
+
+// FindPrimaryPoolForNode returns the pool the node targets like
+// GetPrimaryPoolForNode, but neither logs nor sets the MCCPoolAlert metric, for
+// callers outside of the node controller.
+func FindPrimaryPoolForNode(pl []*mcfgv1.MachineConfigPool, node *corev1.Node) (*mcfgv1.MachineConfigPool, error) {
+	if IsWindowsNode(node) {
+		return nil, nil
+	}
+	pools, _, err := matchPoolsForNode(pl, node)
+	if err != nil || pools == nil {
+		return nil, err
+	}
+	return pools[0], nil
+}
+
+// matchPoolsForNode returns the pools the node belongs to, the one it targets
+// first, and the custom pool which is ignored because the node is a master, if
+// any. It returns nil if the node belongs to no pool.
+func matchPoolsForNode(pl []*mcfgv1.MachineConfigPool, node *corev1.Node) ([]*mcfgv1.MachineConfigPool, *mcfgv1.MachineConfigPool, error) {
+	var pools []*mcfgv1.MachineConfigPool
+	for _, p := range pl {
+		selector, err := metav1.LabelSelectorAsSelector(p.Spec.NodeSelector)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid label selector: %w", err)
+		}
+
+		// If a pool with a nil or empty selector creeps in, it should match nothing, not everything.
+		if selector.Empty() || !selector.Matches(labels.Set(node.Labels)) {
+			continue
+		}
+
+		pools = append(pools, p)
+	}
+
+	if len(pools) == 0 {
+		// This is not an error, as there might be nodes in cluster that are not managed by machineconfigpool.
+		return nil, nil, nil
+	}
+
+	var master, worker *mcfgv1.MachineConfigPool
+	var custom []*mcfgv1.MachineConfigPool
+	for _, pool := range pools {
+		if pool.Name == MachineConfigPoolMaster {
+			master = pool
+		} else if pool.Name == MachineConfigPoolWorker {
+			worker = pool
+		} else {
+			custom = append(custom, pool)
+		}
+	}
+
+	if len(custom) > 1 {
+		return nil, nil, fmt.Errorf("node %s belongs to %d custom roles, cannot proceed with this Node", node.Name, len(custom))
+	} else if len(custom) == 1 {
+		pls := []*mcfgv1.MachineConfigPool{}
+		var shadowed *mcfgv1.MachineConfigPool
+		if master != nil {
+			// if we have a custom pool and master, defer to master and return.
+			shadowed = custom[0]
+			pls = append(pls, master)
+		} else {
+			pls = append(pls, custom[0])
+		}
+		if worker != nil {
+			pls = append(pls, worker)
+		}
+		// this allows us to have master, worker, infra but be in the master pool.
+		// or if !worker and !master then we just use the custom pool.
+		return pls, shadowed, nil
+	} else if master != nil {
+		// In the case where a node is both master/worker, have it live under
+		// the master pool. This occurs in CodeReadyContainers and general
+		// "single node" deployments, which one may want to do for testing bare
+		// metal, etc.
+		return []*mcfgv1.MachineConfigPool{master}, nil, nil
+	}
+	// Otherwise, it's a worker with no custom roles.
+	return []*mcfgv1.MachineConfigPool{worker}, nil, nil
+}
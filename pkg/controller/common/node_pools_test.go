@@ -0,0 +1,89 @@
+package common
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	mcfgv1 "github.com/openshift/machine-config-operator/pkg/apis/machineconfiguration.openshift.io/v1"
+	"github.com/openshift/machine-config-operator/test/helpers"
+)
+
+func TestGetPrimaryPoolForNode(t *testing.T) {
+	pools := []*mcfgv1.MachineConfigPool{
+		helpers.NewMachineConfigPool("worker", nil, helpers.WorkerSelector, "v0"),
+		helpers.NewMachineConfigPool("infra", nil, helpers.InfraSelector, "v0"),
+		helpers.NewMachineConfigPool("master", nil, helpers.MasterSelector, "v0"),
+		helpers.NewMachineConfigPool("other", nil, metav1.AddLabelToSelector(&metav1.LabelSelector{}, "node-role/other", ""), "v0"),
+	}
+
+	testCases := []struct {
+		roles       []string
+		windows     bool
+		expected    string
+		errExpected bool
+	}{
+		{roles: []string{"worker"}, expected: "worker"},
+		{roles: []string{"worker", "infra"}, expected: "infra"},
+		{roles: []string{"master", "worker"}, expected: "master"},
+		{roles: []string{"master", "infra"}, expected: "master"},
+		{roles: []string{"worker"}, windows: true},
+		{roles: []string{"unknown"}},
+		{roles: []string{"worker", "infra", "other"}, errExpected: true},
+	}
+
+	for _, testCase := range testCases {
+		node := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node", Labels: map[string]string{}}}
+		for _, role := range testCase.roles {
+			node.Labels[fmt.Sprintf("node-role/%s", role)] = ""
+		}
+		if testCase.windows {
+			node.Labels[OSLabel] = "windows"
+		}
+
+		pool, err := GetPrimaryPoolForNode(pools, node)
+		found, findErr := FindPrimaryPoolForNode(pools, node)
+		assert.Equal(t, pool, found, "%v", testCase.roles)
+		if testCase.errExpected {
+			assert.Error(t, err, "%v", testCase.roles)
+			assert.Error(t, findErr, "%v", testCase.roles)
+			continue
+		}
+		assert.NoError(t, findErr, "%v", testCase.roles)
+
+		assert.NoError(t, err, "%v", testCase.roles)
+		if testCase.expected == "" {
+			assert.Nil(t, pool, "%v", testCase.roles)
+		} else if assert.NotNil(t, pool, "%v", testCase.roles) {
+			assert.Equal(t, testCase.expected, pool.Name, "%v", testCase.roles)
+		}
+	}
+}
+
+// Tests that only GetPrimaryPoolForNode sets the MCCPoolAlert metric of a
+// master which also matches a custom pool.
+func TestFindPrimaryPoolForNodeHasNoSideEffects(t *testing.T) {
+	pools := []*mcfgv1.MachineConfigPool{
+		helpers.NewMachineConfigPool("master", nil, helpers.MasterSelector, "v0"),
+		helpers.NewMachineConfigPool("infra", nil, helpers.InfraSelector, "v0"),
+	}
+	node := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "shadowed-master", Labels: map[string]string{
+		"node-role/master": "",
+		"node-role/infra":  "",
+	}}}
+	MCCPoolAlert.DeleteLabelValues(node.Name)
+
+	pool, err := FindPrimaryPoolForNode(pools, node)
+	assert.NoError(t, err)
+	assert.Equal(t, "master", pool.Name)
+	assert.False(t, MCCPoolAlert.DeleteLabelValues(node.Name))
+
+	pool, err = GetPrimaryPoolForNode(pools, node)
+	assert.NoError(t, err)
+	assert.Equal(t, "master", pool.Name)
+	assert.Equal(t, float64(1), testutil.ToFloat64(MCCPoolAlert.WithLabelValues(node.Name)))
+}
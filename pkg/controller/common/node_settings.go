@@ -0,0 +1,302 @@
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	ign3types "github.com/coreos/ignition/v2/config/v3_4/types"
+
+	mcfgv1 "github.com/openshift/machine-config-operator/pkg/apis/machineconfiguration.openshift.io/v1"
+)
+
+const (
+	// chronyConfPath is replaced as a whole, chrony has no drop-in directory.
+	chronyConfPath = "/etc/chrony.conf"
+
+	sysctlUnit            = "systemd-sysctl.service"
+	modulesLoadUnit       = "systemd-modules-load.service"
+	chronydUnit           = "chronyd.service"
+	journaldUnit          = "systemd-journald.service"
+	nodeSettingsMode      = 0o644
+	hugepagesSize2M       = "2M"
+	hugepagesSize1G       = "1G"
+	nodeSettingsHeaderFmt = "# Generated by the machine-config-operator from MachineConfig %s\n"
+)
+
+var (
+	sysctlNameRegexp    = regexp.MustCompile(`^[a-z][a-z0-9_]*([./][a-zA-Z0-9_-]+)+$`)
+	kernelModuleRegexp  = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+	timeSourceRegexp    = regexp.MustCompile(`^[a-zA-Z0-9.:_\[\]-]+$`)
+	journaldSizeRegexp  = regexp.MustCompile(`^[0-9]+[KMGTPE]?$`)
+	journaldSpanRegexp  = regexp.MustCompile(`^[0-9]+(us|ms|s|min|h|d|day|w|week|M|month|y|year)?$`)
+	journaldStorageVals = []string{"volatile", "persistent", "auto", "none"}
+)
+
+// ValidateNodeSettings checks that the node settings can be rendered into
+// valid configuration files.
+//
+//nolint:gocyclo
+func ValidateNodeSettings(settings *mcfgv1.MachineConfigNodeSettings) error {
+	if settings == nil {
+		return nil
+	}
+
+	for _, sysctl := range settings.Sysctls {
+		if !sysctlNameRegexp.MatchString(sysctl.Name) {
+			return fmt.Errorf("invalid sysctl name %q", sysctl.Name)
+		}
+		if sysctl.Value == "" || strings.ContainsAny(sysctl.Value, "\n\r") {
+			return fmt.Errorf("invalid value %q of sysctl %s", sysctl.Value, sysctl.Name)
+		}
+	}
+
+	if modules := settings.KernelModules; modules != nil {
+		for _, module := range append(append([]string{}, modules.Load...), modules.Blacklist...) {
+			if !kernelModuleRegexp.MatchString(module) {
+				return fmt.Errorf("invalid kernel module name %q", module)
+			}
+		}
+		for _, module := range modules.Load {
+			if InSlice(module, modules.Blacklist) {
+				return fmt.Errorf("kernel module %s can not be both loaded and blacklisted", module)
+			}
+		}
+	}
+
+	if chrony := settings.Chrony; chrony != nil {
+		if len(chrony.Servers) == 0 && len(chrony.Pools) == 0 {
+			return fmt.Errorf("chrony needs at least one server or pool")
+		}
+		for _, source := range append(append([]string{}, chrony.Servers...), chrony.Pools...) {
+			if !timeSourceRegexp.MatchString(source) {
+				return fmt.Errorf("invalid chrony time source %q", source)
+			}
+		}
+	}
+
+	if journald := settings.Journald; journald != nil {
+		if journald.Storage != "" && !InSlice(journald.Storage, journaldStorageVals) {
+			return fmt.Errorf("invalid journald storage %q, must be one of %v", journald.Storage, journaldStorageVals)
+		}
+		for name, size := range map[string]string{"systemMaxUse": journald.SystemMaxUse, "runtimeMaxUse": journald.RuntimeMaxUse} {
+			if size != "" && !journaldSizeRegexp.MatchString(size) {
+				return fmt.Errorf("invalid journald %s %q", name, size)
+			}
+		}
+		for name, span := range map[string]string{"maxRetentionSec": journald.MaxRetentionSec, "rateLimitIntervalSec": journald.RateLimitIntervalSec} {
+			if span != "" && !journaldSpanRegexp.MatchString(span) {
+				return fmt.Errorf("invalid journald %s %q", name, span)
+			}
+		}
+		if journald.RateLimitBurst != nil && *journald.RateLimitBurst < 0 {
+			return fmt.Errorf("invalid journald rateLimitBurst %d", *journald.RateLimitBurst)
+		}
+	}
+
+	if hugepages := settings.Hugepages; hugepages != nil {
+		sizes := []string{hugepagesSize2M, hugepagesSize1G}
+		if hugepages.DefaultSize != "" && !InSlice(hugepages.DefaultSize, sizes) {
+			return fmt.Errorf("invalid default hugepage size %q, must be one of %v", hugepages.DefaultSize, sizes)
+		}
+		seen := map[string]bool{}
+		for _, page := range hugepages.Pages {
+			if !InSlice(page.Size, sizes) {
+				return fmt.Errorf("invalid hugepage size %q, must be one of %v", page.Size, sizes)
+			}
+			if seen[page.Size] {
+				return fmt.Errorf("hugepage size %s is given more than once", page.Size)
+			}
+			seen[page.Size] = true
+			if page.Count < 0 {
+				return fmt.Errorf("invalid count %d of %s hugepages", page.Count, page.Size)
+			}
+		}
+	}
+	return nil
+}
+
+// RenderNodeSettings returns configs with their node settings rendered into
+// Ignition files and kernel arguments. Like file references, the files replace
+// those with the same path in the Ignition config of the same MachineConfig.
+// MachineConfigs without node settings are returned as is, the others are
+// copied.
+//
+// The second return value maps the paths of the rendered files to the systemd
+// unit to restart when they change, see RestartServicesAnnotationKey.
+func RenderNodeSettings(configs []*mcfgv1.MachineConfig) ([]*mcfgv1.MachineConfig, map[string]string, error) {
+	rendered := make([]*mcfgv1.MachineConfig, 0, len(configs))
+	restartServices := map[string]string{}
+	for _, config := range configs {
+		settings := config.Spec.NodeSettings
+		if settings == nil {
+			rendered = append(rendered, config)
+			continue
+		}
+
+		files, units := nodeSettingsFiles(config.Name, settings)
+		for path, unit := range units {
+			restartServices[path] = unit
+		}
+		out, err := replaceIgnFiles(config, files)
+		if err != nil {
+			return nil, nil, fmt.Errorf("could not add node settings to MachineConfig %s: %w", config.Name, err)
+		}
+		out.Spec.KernelArguments = append(out.Spec.KernelArguments, hugepagesKernelArgs(settings.Hugepages)...)
+		out.Spec.NodeSettings = nil
+		rendered = append(rendered, out)
+	}
+	return rendered, restartServices, nil
+}
+
+func nodeSettingsFiles(name string, settings *mcfgv1.MachineConfigNodeSettings) ([]ign3types.File, map[string]string) {
+	var files []ign3types.File
+	units := map[string]string{}
+	header := fmt.Sprintf(nodeSettingsHeaderFmt, name)
+	add := func(path, contents, unit string) {
+		file := NewIgnFileBytesOverwriting(path, []byte(header+contents))
+		mode := nodeSettingsMode
+		file.Mode = &mode
+		files = append(files, file)
+		if unit != "" {
+			units[path] = unit
+		}
+	}
+
+	if len(settings.Sysctls) > 0 {
+		var b strings.Builder
+		for _, sysctl := range settings.Sysctls {
+			fmt.Fprintf(&b, "%s = %s\n", sysctl.Name, sysctl.Value)
+		}
+		add(fmt.Sprintf("/etc/sysctl.d/99-mco-%s.conf", name), b.String(), sysctlUnit)
+	}
+
+	if modules := settings.KernelModules; modules != nil {
+		if len(modules.Load) > 0 {
+			add(fmt.Sprintf("/etc/modules-load.d/mco-%s.conf", name), strings.Join(modules.Load, "\n")+"\n", modulesLoadUnit)
+		}
+		if len(modules.Blacklist) > 0 {
+			var b strings.Builder
+			for _, module := range modules.Blacklist {
+				fmt.Fprintf(&b, "blacklist %s\n", module)
+			}
+			// Modules which are already loaded stay loaded, so this needs a reboot.
+			add(fmt.Sprintf("/etc/modprobe.d/mco-%s-blacklist.conf", name), b.String(), "")
+		}
+	}
+
+	if chrony := settings.Chrony; chrony != nil {
+		var b strings.Builder
+		for _, server := range chrony.Servers {
+			fmt.Fprintf(&b, "server %s iburst\n", server)
+		}
+		for _, pool := range chrony.Pools {
+			fmt.Fprintf(&b, "pool %s iburst\n", pool)
+		}
+		b.WriteString("driftfile /var/lib/chrony/drift\nmakestep 1.0 3\nrtcsync\nlogdir /var/log/chrony\n")
+		add(chronyConfPath, b.String(), chronydUnit)
+	}
+
+	if journald := settings.Journald; journald != nil {
+		var b strings.Builder
+		b.WriteString("[Journal]\n")
+		for _, option := range []struct{ key, value string }{
+			{"Storage", journald.Storage},
+			{"SystemMaxUse", journald.SystemMaxUse},
+			{"RuntimeMaxUse", journald.RuntimeMaxUse},
+			{"MaxRetentionSec", journald.MaxRetentionSec},
+			{"RateLimitIntervalSec", journald.RateLimitIntervalSec},
+		} {
+			if option.value != "" {
+				fmt.Fprintf(&b, "%s=%s\n", option.key, option.value)
+			}
+		}
+		if journald.RateLimitBurst != nil {
+			fmt.Fprintf(&b, "RateLimitBurst=%d\n", *journald.RateLimitBurst)
+		}
+		add(fmt.Sprintf("/etc/systemd/journald.conf.d/mco-%s.conf", name), b.String(), journaldUnit)
+	}
+
+	return files, units
+}
+
+func hugepagesKernelArgs(hugepages *mcfgv1.MachineConfigHugepages) []string {
+	if hugepages == nil {
+		return nil
+	}
+	var kargs []string
+	if hugepages.DefaultSize != "" {
+		kargs = append(kargs, "default_hugepagesz="+hugepages.DefaultSize)
+	}
+	for _, page := range hugepages.Pages {
+		kargs = append(kargs, "hugepagesz="+page.Size, fmt.Sprintf("hugepages=%d", page.Count))
+	}
+	return kargs
+}
+
+// RemoveUnrevertedRestarts removes the paths from restartServices whose files
+// lose entries from oldIgn to newIgn which restarting their unit does not
+// revert: systemd-sysctl does not reset removed sysctls, and
+// systemd-modules-load does not unload removed modules. Such changes need a
+// reboot instead.
+func RemoveUnrevertedRestarts(restartServices map[string]string, oldIgn, newIgn *ign3types.Config) error {
+	for path, unit := range restartServices {
+		if unit != sysctlUnit && unit != modulesLoadUnit {
+			continue
+		}
+		oldContents, err := GetIgnitionFileDataByPath(oldIgn, path)
+		if err != nil {
+			return fmt.Errorf("could not read %s of the old config: %w", path, err)
+		}
+		newContents, err := GetIgnitionFileDataByPath(newIgn, path)
+		if err != nil {
+			return fmt.Errorf("could not read %s of the new config: %w", path, err)
+		}
+		kept := nodeSettingsEntries(unit, newContents)
+		for entry := range nodeSettingsEntries(unit, oldContents) {
+			if !kept[entry] {
+				delete(restartServices, path)
+				break
+			}
+		}
+	}
+	return nil
+}
+
+// nodeSettingsEntries returns the sysctl names or kernel modules in a file
+// rendered from node settings, depending on the unit which applies it.
+func nodeSettingsEntries(unit string, contents []byte) map[string]bool {
+	entries := map[string]bool{}
+	for _, line := range strings.Split(string(contents), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if unit == sysctlUnit {
+			line, _, _ = strings.Cut(line, "=")
+		}
+		entries[strings.TrimSpace(line)] = true
+	}
+	return entries
+}
+
+// GetRestartServices returns the systemd units to restart by path, as recorded
+// in the RestartServicesAnnotationKey annotation of the given configs.
+func GetRestartServices(configs ...*mcfgv1.MachineConfig) (map[string]string, error) {
+	restartServices := map[string]string{}
+	for _, config := range configs {
+		value, ok := config.Annotations[RestartServicesAnnotationKey]
+		if !ok {
+			continue
+		}
+		units := map[string]string{}
+		if err := json.Unmarshal([]byte(value), &units); err != nil {
+			return nil, fmt.Errorf("could not parse %s annotation of MachineConfig %s: %w", RestartServicesAnnotationKey, config.Name, err)
+		}
+		for path, unit := range units {
+			restartServices[path] = unit
+		}
+	}
+	return restartServices, nil
+}
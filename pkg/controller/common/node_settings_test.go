@@ -0,0 +1,207 @@
+package common
+
+import (
+	"testing"
+
+	ign3types "github.com/coreos/ignition/v2/config/v3_4/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	mcfgv1 "github.com/openshift/machine-config-operator/pkg/apis/machineconfiguration.openshift.io/v1"
+	"github.com/openshift/machine-config-operator/test/helpers"
+)
+
+func TestRenderNodeSettings(t *testing.T) {
+	burst := int32(1000)
+	plain := helpers.NewMachineConfig("00-plain", nil, "", []ign3types.File{})
+	withSettings := helpers.NewMachineConfig("99-settings", nil, "", []ign3types.File{
+		NewIgnFile("/etc/chrony.conf", "replaced"),
+	})
+	withSettings.Spec.KernelArguments = []string{"nosmt"}
+	withSettings.Spec.NodeSettings = &mcfgv1.MachineConfigNodeSettings{
+		Sysctls: []mcfgv1.MachineConfigSysctl{{Name: "net.ipv4.ip_forward", Value: "1"}, {Name: "vm.swappiness", Value: "10"}},
+		KernelModules: &mcfgv1.MachineConfigKernelModules{
+			Load:      []string{"br_netfilter"},
+			Blacklist: []string{"sctp"},
+		},
+		Chrony:   &mcfgv1.MachineConfigChrony{Servers: []string{"ntp.example.com"}, Pools: []string{"pool.example.com"}},
+		Journald: &mcfgv1.MachineConfigJournald{SystemMaxUse: "4G", RateLimitBurst: &burst},
+		Hugepages: &mcfgv1.MachineConfigHugepages{
+			DefaultSize: "1G",
+			Pages:       []mcfgv1.MachineConfigHugepagesPage{{Size: "1G", Count: 4}},
+		},
+	}
+
+	rendered, restartServices, err := RenderNodeSettings([]*mcfgv1.MachineConfig{plain, withSettings})
+	require.NoError(t, err)
+	assert.Same(t, plain, rendered[0])
+	assert.NotNil(t, withSettings.Spec.NodeSettings)
+	assert.Nil(t, rendered[1].Spec.NodeSettings)
+	assert.Equal(t, []string{"nosmt", "default_hugepagesz=1G", "hugepagesz=1G", "hugepages=4"}, rendered[1].Spec.KernelArguments)
+	assert.Equal(t, map[string]string{
+		"/etc/sysctl.d/99-mco-99-settings.conf":             "systemd-sysctl.service",
+		"/etc/modules-load.d/mco-99-settings.conf":          "systemd-modules-load.service",
+		"/etc/chrony.conf":                                  "chronyd.service",
+		"/etc/systemd/journald.conf.d/mco-99-settings.conf": "systemd-journald.service",
+	}, restartServices)
+
+	ignCfg, err := ParseAndConvertConfig(rendered[1].Spec.Config.Raw)
+	require.NoError(t, err)
+	assert.Len(t, ignCfg.Storage.Files, 5)
+	header := "# Generated by the machine-config-operator from MachineConfig 99-settings\n"
+	for path, expected := range map[string]string{
+		"/etc/sysctl.d/99-mco-99-settings.conf":             "net.ipv4.ip_forward = 1\nvm.swappiness = 10\n",
+		"/etc/modules-load.d/mco-99-settings.conf":          "br_netfilter\n",
+		"/etc/modprobe.d/mco-99-settings-blacklist.conf":    "blacklist sctp\n",
+		"/etc/chrony.conf":                                  "server ntp.example.com iburst\npool pool.example.com iburst\ndriftfile /var/lib/chrony/drift\nmakestep 1.0 3\nrtcsync\nlogdir /var/log/chrony\n",
+		"/etc/systemd/journald.conf.d/mco-99-settings.conf": "[Journal]\nSystemMaxUse=4G\nRateLimitBurst=1000\n",
+	} {
+		contents, err := GetIgnitionFileDataByPath(&ignCfg, path)
+		require.NoError(t, err)
+		assert.Equal(t, header+expected, string(contents), path)
+	}
+}
+
+func TestValidateNodeSettings(t *testing.T) {
+	negative := int32(-1)
+	testCases := []struct {
+		name        string
+		settings    *mcfgv1.MachineConfigNodeSettings
+		errExpected bool
+	}{
+		{
+			name: "nil",
+		},
+		{
+			name: "valid",
+			settings: &mcfgv1.MachineConfigNodeSettings{
+				Sysctls:       []mcfgv1.MachineConfigSysctl{{Name: "net.ipv4.conf.all.rp_filter", Value: "1"}},
+				KernelModules: &mcfgv1.MachineConfigKernelModules{Load: []string{"br_netfilter"}},
+				Chrony:        &mcfgv1.MachineConfigChrony{Servers: []string{"10.0.0.1", "[fd00::1]"}},
+				Journald:      &mcfgv1.MachineConfigJournald{Storage: "persistent", MaxRetentionSec: "1week", RuntimeMaxUse: "512M"},
+				Hugepages:     &mcfgv1.MachineConfigHugepages{Pages: []mcfgv1.MachineConfigHugepagesPage{{Size: "2M", Count: 512}}},
+			},
+		},
+		{
+			name:        "invalid sysctl name",
+			settings:    &mcfgv1.MachineConfigNodeSettings{Sysctls: []mcfgv1.MachineConfigSysctl{{Name: "vm swappiness", Value: "1"}}},
+			errExpected: true,
+		},
+		{
+			name:        "multi-line sysctl value",
+			settings:    &mcfgv1.MachineConfigNodeSettings{Sysctls: []mcfgv1.MachineConfigSysctl{{Name: "vm.swappiness", Value: "1\nkernel.panic = 1"}}},
+			errExpected: true,
+		},
+		{
+			name:        "module loaded and blacklisted",
+			settings:    &mcfgv1.MachineConfigNodeSettings{KernelModules: &mcfgv1.MachineConfigKernelModules{Load: []string{"sctp"}, Blacklist: []string{"sctp"}}},
+			errExpected: true,
+		},
+		{
+			name:        "chrony without sources",
+			settings:    &mcfgv1.MachineConfigNodeSettings{Chrony: &mcfgv1.MachineConfigChrony{}},
+			errExpected: true,
+		},
+		{
+			name:        "chrony option injection",
+			settings:    &mcfgv1.MachineConfigNodeSettings{Chrony: &mcfgv1.MachineConfigChrony{Servers: []string{"ntp.example.com prefer"}}},
+			errExpected: true,
+		},
+		{
+			name:        "invalid journald size",
+			settings:    &mcfgv1.MachineConfigNodeSettings{Journald: &mcfgv1.MachineConfigJournald{SystemMaxUse: "4 gigs"}},
+			errExpected: true,
+		},
+		{
+			name:        "negative journald burst",
+			settings:    &mcfgv1.MachineConfigNodeSettings{Journald: &mcfgv1.MachineConfigJournald{RateLimitBurst: &negative}},
+			errExpected: true,
+		},
+		{
+			name:        "invalid hugepage size",
+			settings:    &mcfgv1.MachineConfigNodeSettings{Hugepages: &mcfgv1.MachineConfigHugepages{Pages: []mcfgv1.MachineConfigHugepagesPage{{Size: "4M", Count: 1}}}},
+			errExpected: true,
+		},
+		{
+			name: "duplicate hugepage size",
+			settings: &mcfgv1.MachineConfigNodeSettings{Hugepages: &mcfgv1.MachineConfigHugepages{Pages: []mcfgv1.MachineConfigHugepagesPage{
+				{Size: "2M", Count: 1}, {Size: "2M", Count: 2},
+			}}},
+			errExpected: true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			err := ValidateNodeSettings(testCase.settings)
+			if testCase.errExpected {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestRemoveUnrevertedRestarts(t *testing.T) {
+	ignConfig := func(settings *mcfgv1.MachineConfigNodeSettings) *ign3types.Config {
+		files, _ := nodeSettingsFiles("99-settings", settings)
+		return &ign3types.Config{Storage: ign3types.Storage{Files: files}}
+	}
+	sysctls := func(sysctls ...mcfgv1.MachineConfigSysctl) *mcfgv1.MachineConfigNodeSettings {
+		return &mcfgv1.MachineConfigNodeSettings{Sysctls: sysctls}
+	}
+	modules := func(modules ...string) *mcfgv1.MachineConfigNodeSettings {
+		return &mcfgv1.MachineConfigNodeSettings{KernelModules: &mcfgv1.MachineConfigKernelModules{Load: modules}}
+	}
+	swappiness10 := mcfgv1.MachineConfigSysctl{Name: "vm.swappiness", Value: "10"}
+	swappiness20 := mcfgv1.MachineConfigSysctl{Name: "vm.swappiness", Value: "20"}
+	ipForward := mcfgv1.MachineConfigSysctl{Name: "net.ipv4.ip_forward", Value: "1"}
+
+	testCases := []struct {
+		name      string
+		old, new  *mcfgv1.MachineConfigNodeSettings
+		restarted bool
+	}{
+		{
+			name:      "changed sysctl",
+			old:       sysctls(swappiness10),
+			new:       sysctls(swappiness20),
+			restarted: true,
+		},
+		{
+			name:      "added sysctl",
+			old:       sysctls(swappiness10),
+			new:       sysctls(swappiness10, ipForward),
+			restarted: true,
+		},
+		{
+			name: "removed sysctl",
+			old:  sysctls(swappiness10, ipForward),
+			new:  sysctls(swappiness20),
+		},
+		{
+			name:      "added module",
+			old:       modules("br_netfilter"),
+			new:       modules("br_netfilter", "ip_vs"),
+			restarted: true,
+		},
+		{
+			name: "removed module",
+			old:  modules("br_netfilter", "ip_vs"),
+			new:  modules("ip_vs"),
+		},
+	}
+
+	for _, testCase := range testCases {
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			newIgn := ignConfig(testCase.new)
+			_, restartServices := nodeSettingsFiles("99-settings", testCase.new)
+			require.Len(t, restartServices, 1)
+			require.NoError(t, RemoveUnrevertedRestarts(restartServices, ignConfig(testCase.old), newIgn))
+			assert.Equal(t, testCase.restarted, len(restartServices) == 1)
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	mcfgv1 "github.com/openshift/machine-config-operator/pkg/apis/machineconfiguration.openshift.io/v1"
+	"github.com/openshift/machine-config-operator/pkg/daemon/constants"
+)
+
+// maxNodeUpdatePhases caps how many phase transitions are kept for a single
+// update so that retries cannot grow the node annotation without bound.
+const maxNodeUpdatePhases = 32
+
+// NodeUpdatePhaseTransition records when a node entered an update phase.
+type NodeUpdatePhaseTransition struct {
+	Phase mcfgv1.NodeUpdatePhase `json:"phase"`
+	Time  metav1.Time            `json:"time"`
+}
+
+// NodeUpdateTimeline is the value of the update phases annotation of a node.
+// It holds the phases of the update to TargetConfig in the order the daemon
+// entered them.
+type NodeUpdateTimeline struct {
+	TargetConfig string                      `json:"targetConfig"`
+	Phases       []NodeUpdatePhaseTransition `json:"phases"`
+}
+
+// GetNodeUpdateTimeline reads the update phases annotation of the node. It
+// returns nil if the node has no such annotation.
+func GetNodeUpdateTimeline(node *corev1.Node) (*NodeUpdateTimeline, error) {
+	value, ok := node.Annotations[constants.NodeUpdatePhasesAnnotationKey]
+	if !ok || value == "" {
+		return nil, nil
+	}
+	timeline := &NodeUpdateTimeline{}
+	if err := json.Unmarshal([]byte(value), timeline); err != nil {
+		return nil, fmt.Errorf("could not parse %s annotation of node %s: %w", constants.NodeUpdatePhasesAnnotationKey, node.Name, err)
+	}
+	return timeline, nil
+}
+
+// SetNodeUpdateTimeline writes the timeline to the update phases annotation
+// of the node.
+func SetNodeUpdateTimeline(node *corev1.Node, timeline *NodeUpdateTimeline) error {
+	value, err := json.Marshal(timeline)
+	if err != nil {
+		return err
+	}
+	if node.Annotations == nil {
+		node.Annotations = map[string]string{}
+	}
+	node.Annotations[constants.NodeUpdatePhasesAnnotationKey] = string(value)
+	return nil
+}
+
+// AddPhase returns a copy of the timeline with the given phase of the update
+// to targetConfig appended. A new timeline is started if t is nil or belongs
+// to a different target config.
+func (t *NodeUpdateTimeline) AddPhase(targetConfig string, phase mcfgv1.NodeUpdatePhase, now time.Time) *NodeUpdateTimeline {
+	out := &NodeUpdateTimeline{TargetConfig: targetConfig}
+	if t != nil && t.TargetConfig == targetConfig {
+		out.Phases = append(out.Phases, t.Phases...)
+	}
+
+	out.Phases = append(out.Phases, NodeUpdatePhaseTransition{Phase: phase, Time: metav1.NewTime(now)})
+	if len(out.Phases) > maxNodeUpdatePhases {
+		out.Phases = out.Phases[len(out.Phases)-maxNodeUpdatePhases:]
+	}
+	return out
+}
+
+// LastPhase returns the last phase the node entered, if any.
+func (t *NodeUpdateTimeline) LastPhase() *NodeUpdatePhaseTransition {
+	if t == nil || len(t.Phases) == 0 {
+		return nil
+	}
+	return &t.Phases[len(t.Phases)-1]
+}
@@ -0,0 +1,67 @@
+package common
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	mcfgv1 "github.com/openshift/machine-config-operator/pkg/apis/machineconfiguration.openshift.io/v1"
+	"github.com/openshift/machine-config-operator/pkg/daemon/constants"
+)
+
+func TestNodeUpdateTimelineAddPhase(t *testing.T) {
+	start := time.Date(2023, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	var timeline *NodeUpdateTimeline
+	timeline = timeline.AddPhase("rendered-worker-1", mcfgv1.NodeUpdatePhaseDrainRequested, start)
+	timeline = timeline.AddPhase("rendered-worker-1", mcfgv1.NodeUpdatePhaseDrained, start.Add(time.Minute))
+
+	assert.Equal(t, "rendered-worker-1", timeline.TargetConfig)
+	assert.Equal(t, []NodeUpdatePhaseTransition{
+		{Phase: mcfgv1.NodeUpdatePhaseDrainRequested, Time: metav1.NewTime(start)},
+		{Phase: mcfgv1.NodeUpdatePhaseDrained, Time: metav1.NewTime(start.Add(time.Minute))},
+	}, timeline.Phases)
+	assert.Equal(t, mcfgv1.NodeUpdatePhaseDrained, timeline.LastPhase().Phase)
+
+	// A new target config starts a new timeline without modifying the old one.
+	next := timeline.AddPhase("rendered-worker-2", mcfgv1.NodeUpdatePhaseFilesWritten, start.Add(time.Hour))
+	assert.Equal(t, "rendered-worker-2", next.TargetConfig)
+	assert.Len(t, next.Phases, 1)
+	assert.Len(t, timeline.Phases, 2)
+
+	// Retries cannot grow the timeline without bound.
+	for i := 0; i < 2*maxNodeUpdatePhases; i++ {
+		next = next.AddPhase("rendered-worker-2", mcfgv1.NodeUpdatePhaseDrainRequested, start.Add(time.Duration(i)*time.Minute))
+	}
+	assert.Len(t, next.Phases, maxNodeUpdatePhases)
+	assert.Equal(t, metav1.NewTime(start.Add(time.Duration(2*maxNodeUpdatePhases-1)*time.Minute)), next.LastPhase().Time)
+}
+
+func TestNodeUpdateTimelineAnnotation(t *testing.T) {
+	node := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-0"}}
+
+	timeline, err := GetNodeUpdateTimeline(node)
+	assert.NoError(t, err)
+	assert.Nil(t, timeline)
+	assert.Nil(t, timeline.LastPhase())
+
+	now := time.Date(2023, 6, 1, 12, 0, 0, 0, time.UTC)
+	expected := timeline.AddPhase("rendered-worker-1", mcfgv1.NodeUpdatePhaseRebooting, now)
+	require.NoError(t, SetNodeUpdateTimeline(node, expected))
+	assert.JSONEq(t, `{"targetConfig":"rendered-worker-1","phases":[{"phase":"Rebooting","time":"2023-06-01T12:00:00Z"}]}`, node.Annotations[constants.NodeUpdatePhasesAnnotationKey])
+
+	timeline, err = GetNodeUpdateTimeline(node)
+	require.NoError(t, err)
+	assert.Equal(t, expected.TargetConfig, timeline.TargetConfig)
+	require.Len(t, timeline.Phases, 1)
+	assert.Equal(t, mcfgv1.NodeUpdatePhaseRebooting, timeline.Phases[0].Phase)
+	assert.True(t, timeline.Phases[0].Time.Equal(&expected.Phases[0].Time))
+
+	node.Annotations[constants.NodeUpdatePhasesAnnotationKey] = "not json"
+	_, err = GetNodeUpdateTimeline(node)
+	assert.Error(t, err)
+}
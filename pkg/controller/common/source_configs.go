@@ -0,0 +1,120 @@
+package common
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	mcfgv1 "github.com/openshift/machine-config-operator/pkg/apis/machineconfiguration.openshift.io/v1"
+)
+
+// SourceConfig identifies the version of a source MachineConfig a rendered
+// MachineConfig was rendered from, see SourceConfigsAnnotationKey.
+type SourceConfig struct {
+	Name            string `json:"name"`
+	ResourceVersion string `json:"resourceVersion,omitempty"`
+	Generation      int64  `json:"generation,omitempty"`
+	// Hash is the SHA-256 of the parts of the MachineConfig that end up in
+	// the rendered one, so that changes to its metadata do not count.
+	Hash string `json:"hash"`
+}
+
+// NewSourceConfigs returns the source configs recording the given configs.
+func NewSourceConfigs(configs []*mcfgv1.MachineConfig) ([]SourceConfig, error) {
+	sources := make([]SourceConfig, 0, len(configs))
+	for _, config := range configs {
+		data, err := json.Marshal(config.Spec)
+		if err != nil {
+			return nil, fmt.Errorf("could not hash MachineConfig %s: %w", config.Name, err)
+		}
+		// The config drift policy is merged into the rendered config as well.
+		data = append(data, []byte(config.Annotations[ConfigDriftPolicyAnnotationKey])...)
+		sources = append(sources, SourceConfig{
+			Name:            config.Name,
+			ResourceVersion: config.ResourceVersion,
+			Generation:      config.Generation,
+			Hash:            fmt.Sprintf("%x", sha256.Sum256(data)),
+		})
+	}
+	sort.Slice(sources, func(i, j int) bool { return sources[i].Name < sources[j].Name })
+	return sources, nil
+}
+
+// GetSourceConfigs returns the source configs recorded on a rendered
+// MachineConfig, or nil if it was rendered before they were recorded.
+func GetSourceConfigs(config *mcfgv1.MachineConfig) ([]SourceConfig, error) {
+	value, ok := config.Annotations[SourceConfigsAnnotationKey]
+	if !ok {
+		return nil, nil
+	}
+	var sources []SourceConfig
+	if err := json.Unmarshal([]byte(value), &sources); err != nil {
+		return nil, fmt.Errorf("could not parse %s annotation of MachineConfig %s: %w", SourceConfigsAnnotationKey, config.Name, err)
+	}
+	return sources, nil
+}
+
+// SourceConfigsDiff lists the names of the source MachineConfigs that differ
+// between two rendered MachineConfigs.
+type SourceConfigsDiff struct {
+	Added    []string
+	Removed  []string
+	Modified []string
+}
+
+// Empty returns whether the source MachineConfigs are the same.
+func (d SourceConfigsDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Modified) == 0
+}
+
+func (d SourceConfigsDiff) String() string {
+	if d.Empty() {
+		return "no source MachineConfig changed"
+	}
+	s := ""
+	for _, part := range []struct {
+		verb  string
+		names []string
+	}{{"added", d.Added}, {"removed", d.Removed}, {"modified", d.Modified}} {
+		if len(part.names) == 0 {
+			continue
+		}
+		if s != "" {
+			s += "; "
+		}
+		s += fmt.Sprintf("%s %v", part.verb, part.names)
+	}
+	return s
+}
+
+// DiffSourceConfigs compares the sources of two rendered MachineConfigs. A
+// source without a hash, as when only the names of the previous sources are
+// known, is never reported as modified.
+func DiffSourceConfigs(previous, current []SourceConfig) SourceConfigsDiff {
+	var diff SourceConfigsDiff
+	hashes := map[string]string{}
+	for _, source := range previous {
+		hashes[source.Name] = source.Hash
+	}
+	seen := map[string]bool{}
+	for _, source := range current {
+		seen[source.Name] = true
+		hash, ok := hashes[source.Name]
+		switch {
+		case !ok:
+			diff.Added = append(diff.Added, source.Name)
+		case hash != "" && source.Hash != "" && hash != source.Hash:
+			diff.Modified = append(diff.Modified, source.Name)
+		}
+	}
+	for _, source := range previous {
+		if !seen[source.Name] {
+			diff.Removed = append(diff.Removed, source.Name)
+		}
+	}
+	sort.Strings(diff.Added)
+	sort.Strings(diff.Removed)
+	sort.Strings(diff.Modified)
+	return diff
+}
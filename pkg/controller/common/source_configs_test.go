@@ -0,0 +1,47 @@
+package common
+
+import (
+	"testing"
+
+	ign3types "github.com/coreos/ignition/v2/config/v3_4/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	mcfgv1 "github.com/openshift/machine-config-operator/pkg/apis/machineconfiguration.openshift.io/v1"
+	"github.com/openshift/machine-config-operator/test/helpers"
+)
+
+func TestDiffSourceConfigs(t *testing.T) {
+	base := helpers.NewMachineConfig("00-worker", nil, "", []ign3types.File{})
+	base.ResourceVersion = "1"
+	ssh := helpers.NewMachineConfig("99-worker-ssh", nil, "", []ign3types.File{})
+	previous, err := NewSourceConfigs([]*mcfgv1.MachineConfig{ssh, base})
+	require.NoError(t, err)
+	assert.Equal(t, "00-worker", previous[0].Name)
+	assert.Equal(t, "1", previous[0].ResourceVersion)
+
+	// Metadata only changes are not modifications.
+	relabeled := base.DeepCopy()
+	relabeled.ResourceVersion = "2"
+	relabeled.Labels = map[string]string{"foo": "bar"}
+	modified := ssh.DeepCopy()
+	modified.Spec.KernelArguments = []string{"nosmt"}
+	added := helpers.NewMachineConfig("99-worker-chrony", nil, "", []ign3types.File{})
+	current, err := NewSourceConfigs([]*mcfgv1.MachineConfig{relabeled, modified, added})
+	require.NoError(t, err)
+	assert.Equal(t, previous[0].Hash, current[0].Hash)
+
+	diff := DiffSourceConfigs(previous, current)
+	assert.Equal(t, SourceConfigsDiff{Added: []string{"99-worker-chrony"}, Modified: []string{"99-worker-ssh"}}, diff)
+	assert.Equal(t, "added [99-worker-chrony]; modified [99-worker-ssh]", diff.String())
+
+	diff = DiffSourceConfigs(current, previous)
+	assert.Equal(t, SourceConfigsDiff{Removed: []string{"99-worker-chrony"}, Modified: []string{"99-worker-ssh"}}, diff)
+
+	// Without hashes nothing is known to be modified.
+	diff = DiffSourceConfigs([]SourceConfig{{Name: "00-worker"}, {Name: "99-worker-ssh"}}, current)
+	assert.Equal(t, SourceConfigsDiff{Added: []string{"99-worker-chrony"}}, diff)
+
+	assert.True(t, DiffSourceConfigs(current, current).Empty())
+	assert.Equal(t, "no source MachineConfig changed", DiffSourceConfigs(current, current).String())
+}
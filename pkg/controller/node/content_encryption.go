@@ -0,0 +1,136 @@
+package node
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/openshift/machine-config-operator/internal"
+	mcfgv1 "github.com/openshift/machine-config-operator/pkg/apis/machineconfiguration.openshift.io/v1"
+	ctrlcommon "github.com/openshift/machine-config-operator/pkg/controller/common"
+	daemonconsts "github.com/openshift/machine-config-operator/pkg/daemon/constants"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/klog/v2"
+)
+
+// setContentEncryptionKeysAnnotation hands out the keys of the encrypted
+// rendered configs the nodes of pool need: their current and desired configs
+// and the target config of the pool, so that the key is in place before the
+// node is asked to update. The keys are wrapped for the public key the daemon
+// publishes, and keys of configs a node no longer needs are dropped.
+func (ctrl *Controller) setContentEncryptionKeysAnnotation(pool *mcfgv1.MachineConfigPool, nodes []*corev1.Node) error {
+	var encryptionKey []byte
+	for _, node := range nodes {
+		publicKey := node.Annotations[daemonconsts.ContentEncryptionPublicKeyAnnotationKey]
+		if publicKey == "" {
+			// The daemon has not started yet, keys can be wrapped once it has.
+			continue
+		}
+		names, err := ctrl.getEncryptedConfigsForNode(pool, node)
+		if err != nil {
+			return err
+		}
+
+		value, hasKeys := node.Annotations[daemonconsts.ContentEncryptionKeysAnnotationKey]
+		if len(names) == 0 {
+			if hasKeys {
+				if _, err := internal.UpdateNodeRetry(ctrl.kubeClient.CoreV1().Nodes(), ctrl.nodeLister, node.Name, func(node *corev1.Node) {
+					delete(node.Annotations, daemonconsts.ContentEncryptionKeysAnnotationKey)
+				}); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+
+		var existing ctrlcommon.ContentEncryptionKeys
+		if hasKeys {
+			if err := json.Unmarshal([]byte(value), &existing); err != nil {
+				klog.Warningf("Replacing invalid %s annotation of node %s: %v", daemonconsts.ContentEncryptionKeysAnnotationKey, node.Name, err)
+				existing = ctrlcommon.ContentEncryptionKeys{}
+			}
+		}
+		if existing.PublicKey != publicKey {
+			// The node has a new key pair, e.g. after a reinstall.
+			existing.Keys = nil
+		}
+
+		keys := ctrlcommon.ContentEncryptionKeys{PublicKey: publicKey, Keys: map[string]string{}}
+		for _, name := range names {
+			if wrapped, ok := existing.Keys[name]; ok {
+				keys.Keys[name] = wrapped
+				continue
+			}
+			if encryptionKey == nil {
+				encryptionKey, err = ctrl.getContentEncryptionKey()
+				if err != nil {
+					return err
+				}
+			}
+			wrapped, err := ctrlcommon.WrapMachineConfigKey(publicKey, ctrlcommon.DeriveMachineConfigKey(encryptionKey, name))
+			if err != nil {
+				return fmt.Errorf("could not wrap key of %s for node %s: %w", name, node.Name, err)
+			}
+			keys.Keys[name] = wrapped
+		}
+		if existing.PublicKey == publicKey && reflect.DeepEqual(existing.Keys, keys.Keys) {
+			continue
+		}
+
+		data, err := json.Marshal(keys)
+		if err != nil {
+			return err
+		}
+		if _, err := internal.UpdateNodeRetry(ctrl.kubeClient.CoreV1().Nodes(), ctrl.nodeLister, node.Name, func(node *corev1.Node) {
+			node.Annotations[daemonconsts.ContentEncryptionKeysAnnotationKey] = string(data)
+		}); err != nil {
+			return err
+		}
+		klog.V(4).Infof("Updated content encryption keys of node %s for %v", node.Name, names)
+	}
+	return nil
+}
+
+// getEncryptedConfigsForNode returns the sorted names of the rendered configs
+// with encrypted file contents that node needs.
+func (ctrl *Controller) getEncryptedConfigsForNode(pool *mcfgv1.MachineConfigPool, node *corev1.Node) ([]string, error) {
+	candidates := map[string]bool{
+		node.Annotations[daemonconsts.CurrentMachineConfigAnnotationKey]: true,
+		node.Annotations[daemonconsts.DesiredMachineConfigAnnotationKey]: true,
+		pool.Spec.Configuration.Name:                                     true,
+	}
+	var names []string
+	for name := range candidates {
+		if name == "" {
+			continue
+		}
+		mc, err := ctrl.mcLister.Get(name)
+		if errors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if ctrlcommon.IsMachineConfigEncrypted(mc) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// getContentEncryptionKey returns the key the render controller derived the
+// keys of the encrypted rendered configs from.
+func (ctrl *Controller) getContentEncryptionKey() ([]byte, error) {
+	secret, err := ctrl.secretLister.Get(ctrlcommon.ContentEncryptionKeySecretName)
+	if err != nil {
+		return nil, fmt.Errorf("could not get content encryption key: %w", err)
+	}
+	key := secret.Data[ctrlcommon.ContentEncryptionKeySecretKey]
+	if len(key) == 0 {
+		return nil, fmt.Errorf("Secret %s/%s has no %q key", ctrlcommon.MCONamespace, ctrlcommon.ContentEncryptionKeySecretName, ctrlcommon.ContentEncryptionKeySecretKey)
+	}
+	return key, nil
+}
@@ -0,0 +1,85 @@
+package node
+
+import (
+	"context"
+	"crypto/ecdh"
+	"crypto/rand"
+	"encoding/json"
+	"testing"
+
+	ign3types "github.com/coreos/ignition/v2/config/v3_4/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	corelisterv1 "k8s.io/client-go/listers/core/v1"
+	core "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/cache"
+
+	ctrlcommon "github.com/openshift/machine-config-operator/pkg/controller/common"
+	daemonconsts "github.com/openshift/machine-config-operator/pkg/daemon/constants"
+	mcfglistersv1 "github.com/openshift/machine-config-operator/pkg/generated/listers/machineconfiguration.openshift.io/v1"
+	"github.com/openshift/machine-config-operator/test/helpers"
+)
+
+func TestSetContentEncryptionKeysAnnotation(t *testing.T) {
+	nodeKey, err := ecdh.X25519().GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	encryptionKey := []byte("content-encryption-key")
+
+	pool := helpers.NewMachineConfigPool("worker", nil, helpers.WorkerSelector, "rendered-worker-2")
+	current := helpers.NewMachineConfig("rendered-worker-1", nil, "", []ign3types.File{})
+	target := helpers.NewMachineConfig("rendered-worker-2", nil, "", []ign3types.File{})
+	target.Annotations = map[string]string{ctrlcommon.EncryptedFilesAnnotationKey: "/var/lib/kubelet/config.json"}
+
+	node := newNodeWithReady("node-0", "rendered-worker-1", "rendered-worker-1", corev1.ConditionTrue)
+	withoutKey := newNodeWithReady("node-1", "rendered-worker-1", "rendered-worker-1", corev1.ConditionTrue)
+	node.Annotations[daemonconsts.ContentEncryptionPublicKeyAnnotationKey] = ctrlcommon.EncodeContentEncryptionPublicKey(nodeKey.PublicKey())
+
+	mcIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	require.NoError(t, mcIndexer.Add(current))
+	require.NoError(t, mcIndexer.Add(target))
+	nodeIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	require.NoError(t, nodeIndexer.Add(node))
+	require.NoError(t, nodeIndexer.Add(withoutKey))
+	secretIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	require.NoError(t, secretIndexer.Add(&corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: ctrlcommon.ContentEncryptionKeySecretName, Namespace: ctrlcommon.MCONamespace},
+		Data:       map[string][]byte{ctrlcommon.ContentEncryptionKeySecretKey: encryptionKey},
+	}))
+
+	kubeClient := k8sfake.NewSimpleClientset(node, withoutKey)
+	ctrl := &Controller{
+		kubeClient:   kubeClient,
+		mcLister:     mcfglistersv1.NewMachineConfigLister(mcIndexer),
+		nodeLister:   corelisterv1.NewNodeLister(nodeIndexer),
+		secretLister: corelisterv1.NewSecretLister(secretIndexer).Secrets(ctrlcommon.MCONamespace),
+	}
+
+	require.NoError(t, ctrl.setContentEncryptionKeysAnnotation(pool, []*corev1.Node{node, withoutKey}))
+	require.Len(t, kubeClient.Actions(), 1)
+	assert.Equal(t, "node-0", kubeClient.Actions()[0].(core.PatchAction).GetName())
+
+	// The key of the target config is handed out ahead of the update.
+	updated, err := kubeClient.CoreV1().Nodes().Get(context.TODO(), "node-0", metav1.GetOptions{})
+	require.NoError(t, err)
+	var keys ctrlcommon.ContentEncryptionKeys
+	require.NoError(t, json.Unmarshal([]byte(updated.Annotations[daemonconsts.ContentEncryptionKeysAnnotationKey]), &keys))
+	require.Len(t, keys.Keys, 1)
+	key, err := ctrlcommon.UnwrapMachineConfigKey(nodeKey, keys.Keys["rendered-worker-2"])
+	require.NoError(t, err)
+	assert.Equal(t, ctrlcommon.DeriveMachineConfigKey(encryptionKey, "rendered-worker-2"), key)
+
+	// Nothing changes on the next sync.
+	require.NoError(t, nodeIndexer.Update(updated))
+	require.NoError(t, ctrl.setContentEncryptionKeysAnnotation(pool, []*corev1.Node{updated}))
+	assert.Len(t, kubeClient.Actions(), 2)
+
+	// Keys no longer needed are dropped.
+	pool.Spec.Configuration.Name = "rendered-worker-1"
+	require.NoError(t, ctrl.setContentEncryptionKeysAnnotation(pool, []*corev1.Node{updated}))
+	updated, err = kubeClient.CoreV1().Nodes().Get(context.TODO(), "node-0", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.NotContains(t, updated.Annotations, daemonconsts.ContentEncryptionKeysAnnotationKey)
+}
@@ -57,9 +57,6 @@ const (
 	// https://github.com/openshift/machine-config-operator/issues/301
 	updateDelay = 5 * time.Second
 
-	// osLabel is used to identify which type of OS the node has
-	osLabel = "kubernetes.io/os"
-
 	// zoneLabel is for https://kubernetes.io/docs/setup/best-practices/multiple-zones/
 	zoneLabel = "topology.kubernetes.io/zone"
 
@@ -90,6 +87,10 @@ type Controller struct {
 	schedulerList         cligolistersv1.SchedulerLister
 	schedulerListerSynced cache.InformerSynced
 
+	// secretLister is scoped to the MCO namespace, for the content encryption key.
+	secretLister       corelisterv1.SecretNamespaceLister
+	secretListerSynced cache.InformerSynced
+
 	queue workqueue.RateLimitingInterface
 }
 
@@ -101,6 +102,7 @@ func New(
 	nodeInformer coreinformersv1.NodeInformer,
 	podInformer coreinformersv1.PodInformer,
 	schedulerInformer cligoinformersv1.SchedulerInformer,
+	secretInformer coreinformersv1.SecretInformer,
 	kubeClient clientset.Interface,
 	mcfgClient mcfgclientset.Interface,
 ) *Controller {
@@ -146,6 +148,9 @@ func New(
 	ctrl.schedulerList = schedulerInformer.Lister()
 	ctrl.schedulerListerSynced = schedulerInformer.Informer().HasSynced
 
+	ctrl.secretLister = secretInformer.Lister().Secrets(ctrlcommon.MCONamespace)
+	ctrl.secretListerSynced = secretInformer.Informer().HasSynced
+
 	return ctrl
 }
 
@@ -154,7 +159,7 @@ func (ctrl *Controller) Run(workers int, stopCh <-chan struct{}) {
 	defer utilruntime.HandleCrash()
 	defer ctrl.queue.ShutDown()
 
-	if !cache.WaitForCacheSync(stopCh, ctrl.ccListerSynced, ctrl.mcListerSynced, ctrl.mcpListerSynced, ctrl.nodeListerSynced, ctrl.schedulerListerSynced) {
+	if !cache.WaitForCacheSync(stopCh, ctrl.ccListerSynced, ctrl.mcListerSynced, ctrl.mcpListerSynced, ctrl.nodeListerSynced, ctrl.schedulerListerSynced, ctrl.secretListerSynced) {
 		return
 	}
 
@@ -347,20 +352,6 @@ func (ctrl *Controller) isMaster(node *corev1.Node) bool {
 	return master
 }
 
-// isWindows checks if given node is a Windows node or a Linux node
-func isWindows(node *corev1.Node) bool {
-	windowsOsValue := "windows"
-	if value, ok := node.ObjectMeta.Labels[osLabel]; ok {
-		if value == windowsOsValue {
-			return true
-		}
-		return false
-	}
-	// All the nodes should have a OS label populated by kubelet, if not just to maintain
-	// backwards compatibility, we can returning true here.
-	return false
-}
-
 // Given a master Node, ensure it reflects the current mastersSchedulable setting
 func (ctrl *Controller) reconcileMaster(node *corev1.Node) {
 	mastersSchedulable, err := ctrl.getMastersSchedulable()
@@ -497,6 +488,7 @@ func (ctrl *Controller) updateNode(old, cur interface{}) {
 			daemonconsts.DesiredMachineConfigAnnotationKey,
 			daemonconsts.MachineConfigDaemonStateAnnotationKey,
 			daemonconsts.MachineConfigDaemonReasonAnnotationKey,
+			daemonconsts.ContentEncryptionPublicKeyAnnotationKey,
 		}
 		for _, anno := range annos {
 			newValue := curNode.Annotations[anno]
@@ -573,95 +565,25 @@ func (ctrl *Controller) deleteNode(obj interface{}) {
 }
 
 // getPoolsForNode chooses the MachineConfigPools that should be used for a given node.
-// It disambiguates in the case where e.g. a node has both master/worker roles applied,
-// and where a custom role may be used. It returns a slice of all the pools the node belongs to.
-// It also ignores the Windows nodes.
+// See ctrlcommon.GetPoolsForNode.
 func (ctrl *Controller) getPoolsForNode(node *corev1.Node) ([]*mcfgv1.MachineConfigPool, error) {
-	if isWindows(node) {
-		// This is not an error, is this a Windows Node and it won't be managed by MCO. We're explicitly logging
-		// here at a high level to disambiguate this from other pools = nil  scenario
-		klog.V(4).Infof("Node %v is a windows node so won't be managed by MCO", node.Name)
-		return nil, nil
-	}
 	pl, err := ctrl.mcpLister.List(labels.Everything())
 	if err != nil {
 		return nil, err
 	}
 
-	var pools []*mcfgv1.MachineConfigPool
-	for _, p := range pl {
-		selector, err := metav1.LabelSelectorAsSelector(p.Spec.NodeSelector)
-		if err != nil {
-			return nil, fmt.Errorf("invalid label selector: %w", err)
-		}
-
-		// If a pool with a nil or empty selector creeps in, it should match nothing, not everything.
-		if selector.Empty() || !selector.Matches(labels.Set(node.Labels)) {
-			continue
-		}
-
-		pools = append(pools, p)
-	}
-
-	if len(pools) == 0 {
-		// This is not an error, as there might be nodes in cluster that are not managed by machineconfigpool.
-		return nil, nil
-	}
-
-	var master, worker *mcfgv1.MachineConfigPool
-	var custom []*mcfgv1.MachineConfigPool
-	for _, pool := range pools {
-		if pool.Name == ctrlcommon.MachineConfigPoolMaster {
-			master = pool
-		} else if pool.Name == ctrlcommon.MachineConfigPoolWorker {
-			worker = pool
-		} else {
-			custom = append(custom, pool)
-		}
-	}
-
-	if len(custom) > 1 {
-		return nil, fmt.Errorf("node %s belongs to %d custom roles, cannot proceed with this Node", node.Name, len(custom))
-	} else if len(custom) == 1 {
-		pls := []*mcfgv1.MachineConfigPool{}
-		if master != nil {
-			// if we have a custom pool and master, defer to master and return.
-			klog.Infof("Found master node that matches selector for custom pool %v, defaulting to master. This node will not have any custom role configuration as a result. Please review the node to make sure this is intended", custom[0].Name)
-			ctrlcommon.MCCPoolAlert.WithLabelValues(node.Name).Set(1)
-			pls = append(pls, master)
-		} else {
-			ctrlcommon.MCCPoolAlert.WithLabelValues(node.Name).Set(0)
-			pls = append(pls, custom[0])
-		}
-		if worker != nil {
-			pls = append(pls, worker)
-		}
-		// this allows us to have master, worker, infra but be in the master pool.
-		// or if !worker and !master then we just use the custom pool.
-		return pls, nil
-	} else if master != nil {
-		// In the case where a node is both master/worker, have it live under
-		// the master pool. This occurs in CodeReadyContainers and general
-		// "single node" deployments, which one may want to do for testing bare
-		// metal, etc.
-		ctrlcommon.MCCPoolAlert.WithLabelValues(node.Name).Set(0)
-		return []*mcfgv1.MachineConfigPool{master}, nil
-	}
-	// Otherwise, it's a worker with no custom roles.
-	ctrlcommon.MCCPoolAlert.WithLabelValues(node.Name).Set(0)
-	return []*mcfgv1.MachineConfigPool{worker}, nil
+	return ctrlcommon.GetPoolsForNode(pl, node)
 }
 
-// getPrimaryPoolForNode uses getPoolsForNode and returns the first one which is the one the node targets
+// getPrimaryPoolForNode returns the pool which the node targets.
+// See ctrlcommon.GetPrimaryPoolForNode.
 func (ctrl *Controller) getPrimaryPoolForNode(node *corev1.Node) (*mcfgv1.MachineConfigPool, error) {
-	pools, err := ctrl.getPoolsForNode(node)
+	pl, err := ctrl.mcpLister.List(labels.Everything())
 	if err != nil {
 		return nil, err
 	}
-	if pools == nil {
-		return nil, nil
-	}
-	return pools[0], nil
+
+	return ctrlcommon.GetPrimaryPoolForNode(pl, node)
 }
 
 func (ctrl *Controller) enqueue(pool *mcfgv1.MachineConfigPool) {
@@ -811,6 +733,9 @@ func (ctrl *Controller) syncMachineConfigPool(key string) error {
 	if err := ctrl.setClusterConfigAnnotation(nodes); err != nil {
 		return fmt.Errorf("error setting clusterConfig Annotation for node in pool %q, error: %w", pool.Name, err)
 	}
+	if err := ctrl.setContentEncryptionKeysAnnotation(pool, nodes); err != nil {
+		return fmt.Errorf("error setting content encryption keys for nodes in pool %q: %w", pool.Name, err)
+	}
 	// Taint all the nodes in the node pool, irrespective of their upgrade status.
 	ctx := context.TODO()
 	for _, node := range nodes {
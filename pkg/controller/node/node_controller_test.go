@@ -80,12 +80,13 @@ func (f *fixture) newController() *Controller {
 	k8sI := kubeinformers.NewSharedInformerFactory(f.kubeclient, noResyncPeriodFunc())
 	ci := configv1informer.NewSharedInformerFactory(f.schedulerClient, noResyncPeriodFunc())
 	c := New(i.Machineconfiguration().V1().ControllerConfigs(), i.Machineconfiguration().V1().MachineConfigs(), i.Machineconfiguration().V1().MachineConfigPools(), k8sI.Core().V1().Nodes(),
-		k8sI.Core().V1().Pods(), ci.Config().V1().Schedulers(), f.kubeclient, f.client)
+		k8sI.Core().V1().Pods(), ci.Config().V1().Schedulers(), k8sI.Core().V1().Secrets(), f.kubeclient, f.client)
 
 	c.ccListerSynced = alwaysReady
 	c.mcpListerSynced = alwaysReady
 	c.nodeListerSynced = alwaysReady
 	c.schedulerListerSynced = alwaysReady
+	c.secretListerSynced = alwaysReady
 	c.eventRecorder = &record.FakeRecorder{}
 
 	stopCh := make(chan struct{})
@@ -221,7 +222,9 @@ func filterInformerActions(actions []core.Action) []core.Action {
 				action.Matches("list", "nodes") ||
 				action.Matches("watch", "nodes") ||
 				action.Matches("list", "pods") ||
-				action.Matches("watch", "pods")) {
+				action.Matches("watch", "pods") ||
+				action.Matches("list", "secrets") ||
+				action.Matches("watch", "secrets")) {
 			continue
 		}
 		ret = append(ret, action)
@@ -271,14 +274,14 @@ func TestGetNodesForPool(t *testing.T) {
 		{
 			// Mixed cluster with both Windows and Linux worker nodes. Only Linux nodes should be managed by MCO
 			pool:     helpers.NewMachineConfigPool("worker", nil, helpers.WorkerSelector, "v0"),
-			nodes:    append(newMixedNodeSet(3, map[string]string{"node-role/master": ""}, map[string]string{"node-role/worker": "", "node-role/infra": ""}), newNodeWithLabels("windowsNode", map[string]string{osLabel: "windows"})),
+			nodes:    append(newMixedNodeSet(3, map[string]string{"node-role/master": ""}, map[string]string{"node-role/worker": "", "node-role/infra": ""}), newNodeWithLabels("windowsNode", map[string]string{ctrlcommon.OSLabel: "windows"})),
 			expected: 3,
 			err:      false,
 		},
 		{
 			// Single Windows node is the cluster, so shouldn't be managed by MCO
 			pool:     helpers.NewMachineConfigPool("worker", nil, helpers.WorkerSelector, "v0"),
-			nodes:    []*corev1.Node{newNodeWithLabels("windowsNode", map[string]string{osLabel: "windows"})},
+			nodes:    []*corev1.Node{newNodeWithLabels("windowsNode", map[string]string{ctrlcommon.OSLabel: "windows"})},
 			expected: 0,
 			err:      false,
 		},
@@ -384,7 +387,7 @@ func TestGetPrimaryPoolForNode(t *testing.T) {
 		pools: []*mcfgv1.MachineConfigPool{
 			helpers.NewMachineConfigPool("worker", nil, helpers.WorkerSelector, "v0"),
 		},
-		nodeLabel: map[string]string{"node-role/master": "", "node-role/worker": "", osLabel: "windows"},
+		nodeLabel: map[string]string{"node-role/master": "", "node-role/worker": "", ctrlcommon.OSLabel: "windows"},
 		expected:  nil,
 		err:       false,
 	},
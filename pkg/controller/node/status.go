@@ -3,6 +3,7 @@ package node
 import (
 	"context"
 	"fmt"
+	"sort"
 	"strings"
 
 	mcfgv1 "github.com/openshift/machine-config-operator/pkg/apis/machineconfiguration.openshift.io/v1"
@@ -86,8 +87,11 @@ func calculateStatus(cconfig *v1.ControllerConfig, pool *mcfgv1.MachineConfigPoo
 		UnavailableMachineCount: unavailableMachineCount,
 		DegradedMachineCount:    degradedMachineCount,
 		CertExpirys:             certExpirys,
+		NodeUpdateProgress:      getNodeUpdateProgress(nodes),
 	}
 	status.Configuration = pool.Status.Configuration
+	// The conflicts are recorded by the render controller.
+	status.ConfigConflicts = pool.Status.ConfigConflicts
 
 	conditions := pool.Status.Conditions
 	for i := range conditions {
@@ -153,7 +157,7 @@ func calculateStatus(cconfig *v1.ControllerConfig, pool *mcfgv1.MachineConfigPoo
 
 // isNodeManaged checks whether the MCD has ever run on a node
 func isNodeManaged(node *corev1.Node) bool {
-	if isWindows(node) {
+	if ctrlcommon.IsWindowsNode(node) {
 		klog.V(4).Infof("Node %v is a windows node so won't be managed by MCO", node.Name)
 		return false
 	}
@@ -221,6 +225,38 @@ func getUpdatedMachines(poolTargetConfig string, nodes []*corev1.Node) []*corev1
 	return updated
 }
 
+// getNodeUpdateProgress returns the last update phase reported by each of the
+// provided nodes which has not finished applying its desired config.
+func getNodeUpdateProgress(nodes []*corev1.Node) []mcfgv1.NodeUpdateProgress {
+	var progress []mcfgv1.NodeUpdateProgress
+	for _, node := range nodes {
+		if isNodeDone(node) {
+			continue
+		}
+		targetConfig := node.Annotations[daemonconsts.DesiredMachineConfigAnnotationKey]
+		if targetConfig == "" {
+			continue
+		}
+		nodeProgress := mcfgv1.NodeUpdateProgress{
+			Name:         node.Name,
+			TargetConfig: targetConfig,
+		}
+		timeline, err := ctrlcommon.GetNodeUpdateTimeline(node)
+		if err != nil {
+			klog.Warningf("Ignoring update phases of node %s: %v", node.Name, err)
+		}
+		// The timeline may still be from a previous update if the daemon has
+		// not reported any phase for the target config yet.
+		if last := timeline.LastPhase(); last != nil && timeline.TargetConfig == targetConfig {
+			nodeProgress.Phase = last.Phase
+			nodeProgress.LastTransitionTime = last.Time
+		}
+		progress = append(progress, nodeProgress)
+	}
+	sort.Slice(progress, func(i, j int) bool { return progress[i].Name < progress[j].Name })
+	return progress
+}
+
 // getReadyMachines filters the provided nodes to return the nodes
 // that are updated and marked ready
 func getReadyMachines(currentConfig string, nodes []*corev1.Node) []*corev1.Node {
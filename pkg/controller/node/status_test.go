@@ -4,10 +4,13 @@ import (
 	"fmt"
 	"reflect"
 	"testing"
+	"time"
 
 	mcfgv1 "github.com/openshift/machine-config-operator/pkg/apis/machineconfiguration.openshift.io/v1"
+	ctrlcommon "github.com/openshift/machine-config-operator/pkg/controller/common"
 	daemonconsts "github.com/openshift/machine-config-operator/pkg/daemon/constants"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -646,3 +649,69 @@ func TestCalculateStatus(t *testing.T) {
 		})
 	}
 }
+
+func newNodeWithUpdatePhases(name string, currentConfig, desiredConfig string, timeline *ctrlcommon.NodeUpdateTimeline) *corev1.Node {
+	node := newNode(name, currentConfig, desiredConfig)
+	if timeline != nil {
+		if err := ctrlcommon.SetNodeUpdateTimeline(node, timeline); err != nil {
+			panic(err)
+		}
+	}
+	return node
+}
+
+func TestGetNodeUpdateProgress(t *testing.T) {
+	drained := metav1.NewTime(time.Date(2023, 6, 1, 12, 0, 0, 0, time.UTC))
+	rebooting := metav1.NewTime(drained.Add(5 * time.Minute))
+
+	var timeline *ctrlcommon.NodeUpdateTimeline
+	timeline = timeline.AddPhase("v1", mcfgv1.NodeUpdatePhaseDrained, drained.Time)
+	timeline = timeline.AddPhase("v1", mcfgv1.NodeUpdatePhaseRebooting, rebooting.Time)
+
+	nodes := []*corev1.Node{
+		// done nodes are not reported, even if they have a timeline
+		newNodeWithUpdatePhases("node-0", "v1", "v1", timeline),
+		newNodeWithUpdatePhases("node-3", "v0", "v1", timeline),
+		// the timeline is from a previous update
+		newNodeWithUpdatePhases("node-2", "v1", "v2", timeline),
+		// the daemon has not reported any phase yet
+		newNodeWithUpdatePhases("node-1", "v0", "v1", nil),
+		// no desired config
+		newNode("node-4", "", ""),
+	}
+	nodes[4].Annotations = map[string]string{daemonconsts.MachineConfigDaemonStateAnnotationKey: daemonconsts.MachineConfigDaemonStateWorking}
+
+	expected := []mcfgv1.NodeUpdateProgress{
+		{Name: "node-1", TargetConfig: "v1"},
+		{Name: "node-2", TargetConfig: "v2"},
+		{Name: "node-3", TargetConfig: "v1", Phase: mcfgv1.NodeUpdatePhaseRebooting, LastTransitionTime: rebooting},
+	}
+	if got := getNodeUpdateProgress(nodes); !equality.Semantic.DeepEqual(expected, got) {
+		t.Errorf("expected: %v, got %v", expected, got)
+	}
+
+	status := calculateStatus(nil, &mcfgv1.MachineConfigPool{
+		Spec: mcfgv1.MachineConfigPoolSpec{Configuration: mcfgv1.MachineConfigPoolStatusConfiguration{ObjectReference: corev1.ObjectReference{Name: "v1"}}},
+	}, nodes)
+	if !equality.Semantic.DeepEqual(expected, status.NodeUpdateProgress) {
+		t.Errorf("expected: %v, got %v", expected, status.NodeUpdateProgress)
+	}
+
+	if got := getNodeUpdateProgress(nodes[:1]); got != nil {
+		t.Errorf("expected no progress for updated nodes, got %v", got)
+	}
+}
+
+func TestCalculateStatusKeepsConfigConflicts(t *testing.T) {
+	conflicts := []mcfgv1.MachineConfigConflict{
+		{Type: mcfgv1.MachineConfigConflictFile, Name: "/etc/chrony.conf", Winner: "99-worker-chrony", Losers: []string{"50-vendor-chrony"}},
+	}
+	pool := &mcfgv1.MachineConfigPool{
+		Spec:   mcfgv1.MachineConfigPoolSpec{Configuration: mcfgv1.MachineConfigPoolStatusConfiguration{ObjectReference: corev1.ObjectReference{Name: "v1"}}},
+		Status: mcfgv1.MachineConfigPoolStatus{ConfigConflicts: conflicts},
+	}
+	status := calculateStatus(nil, pool, []*corev1.Node{newNode("node-0", "v1", "v1")})
+	if !equality.Semantic.DeepEqual(conflicts, status.ConfigConflicts) {
+		t.Errorf("expected: %v, got %v", conflicts, status.ConfigConflicts)
+	}
+}
@@ -7,6 +7,7 @@ import (
 
 	"github.com/ghodss/yaml"
 	mcfgv1 "github.com/openshift/machine-config-operator/pkg/apis/machineconfiguration.openshift.io/v1"
+	ctrlcommon "github.com/openshift/machine-config-operator/pkg/controller/common"
 )
 
 var (
@@ -33,6 +34,26 @@ func getMachineConfigHashedName(pool *mcfgv1.MachineConfigPool, config *mcfgv1.M
 		return "", err
 	}
 
+	// The config drift policy is not part of the spec, but changing it has to
+	// roll out a new rendered config for the daemons to pick it up. It is only
+	// hashed when set so that existing rendered config names do not change.
+	if policy, ok := config.Annotations[ctrlcommon.ConfigDriftPolicyAnnotationKey]; ok {
+		data = append(data, []byte(policy)...)
+	}
+
+	// Likewise for the Secrets and ConfigMaps that file references were resolved
+	// from. They are recorded with a hash of the resolved files, not their
+	// resourceVersions, so bootstrap and the cluster agree on the name.
+	if referenced, ok := config.Annotations[ctrlcommon.FileReferencesAnnotationKey]; ok {
+		data = append(data, []byte(referenced)...)
+	}
+
+	// And for the files whose contents are encrypted, which are hashed before
+	// the encryption, as the key is derived from the name.
+	if encrypted, ok := config.Annotations[ctrlcommon.EncryptedFilesAnnotationKey]; ok {
+		data = append(data, []byte(encrypted)...)
+	}
+
 	h, err := hashData(data)
 	if err != nil {
 		return "", err
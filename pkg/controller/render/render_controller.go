@@ -2,8 +2,12 @@ package render
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"reflect"
+	"sort"
+	"strings"
 	"time"
 
 	mcoResourceApply "github.com/openshift/machine-config-operator/lib/resourceapply"
@@ -16,12 +20,12 @@ import (
 	mcfglistersv1 "github.com/openshift/machine-config-operator/pkg/generated/listers/machineconfiguration.openshift.io/v1"
 	"github.com/openshift/machine-config-operator/pkg/version"
 	corev1 "k8s.io/api/core/v1"
-	"k8s.io/apimachinery/pkg/api/errors"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	"k8s.io/apimachinery/pkg/util/wait"
+	coreinformersv1 "k8s.io/client-go/informers/core/v1"
 	clientset "k8s.io/client-go/kubernetes"
 	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
 	"k8s.io/client-go/tools/cache"
@@ -41,6 +45,10 @@ const (
 	// renderDelay is a pause to avoid churn in MachineConfigs; see
 	// https://github.com/openshift/machine-config-operator/issues/301
 	renderDelay = 5 * time.Second
+
+	// configConflictsReason is the reason of the RenderDegraded condition when
+	// rendering is refused because of conflicts between MachineConfigs.
+	configConflictsReason = "ConfigConflicts"
 )
 
 var (
@@ -53,6 +61,7 @@ var (
 // Controller defines the render controller.
 type Controller struct {
 	client        mcfgclientset.Interface
+	kubeClient    clientset.Interface
 	eventRecorder record.EventRecorder
 
 	syncHandler              func(mcp string) error
@@ -67,6 +76,11 @@ type Controller struct {
 	ccLister       mcfglistersv1.ControllerConfigLister
 	ccListerSynced cache.InformerSynced
 
+	// fileReferences resolves the Secrets and ConfigMaps referenced by MachineConfigs.
+	fileReferences        *ctrlcommon.FileReferenceResolver
+	secretListerSynced    cache.InformerSynced
+	configMapListerSynced cache.InformerSynced
+
 	queue workqueue.RateLimitingInterface
 }
 
@@ -75,6 +89,8 @@ func New(
 	mcpInformer mcfginformersv1.MachineConfigPoolInformer,
 	mcInformer mcfginformersv1.MachineConfigInformer,
 	ccInformer mcfginformersv1.ControllerConfigInformer,
+	secretInformer coreinformersv1.SecretInformer,
+	configMapInformer coreinformersv1.ConfigMapInformer,
 	kubeClient clientset.Interface,
 	mcfgClient mcfgclientset.Interface,
 ) *Controller {
@@ -84,6 +100,7 @@ func New(
 
 	ctrl := &Controller{
 		client:        mcfgClient,
+		kubeClient:    kubeClient,
 		eventRecorder: ctrlcommon.NamespacedEventRecorder(eventBroadcaster.NewRecorder(scheme.Scheme, corev1.EventSource{Component: "machineconfigcontroller-rendercontroller"})),
 		queue:         workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "machineconfigcontroller-rendercontroller"),
 	}
@@ -98,6 +115,16 @@ func New(
 		UpdateFunc: ctrl.updateMachineConfig,
 		DeleteFunc: ctrl.deleteMachineConfig,
 	})
+	secretInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    ctrl.addReferencedObject,
+		UpdateFunc: ctrl.updateReferencedObject,
+		DeleteFunc: ctrl.deleteReferencedObject,
+	})
+	configMapInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    ctrl.addReferencedObject,
+		UpdateFunc: ctrl.updateReferencedObject,
+		DeleteFunc: ctrl.deleteReferencedObject,
+	})
 
 	ctrl.syncHandler = ctrl.syncMachineConfigPool
 	ctrl.enqueueMachineConfigPool = ctrl.enqueueDefault
@@ -108,6 +135,12 @@ func New(
 	ctrl.mcListerSynced = mcInformer.Informer().HasSynced
 	ctrl.ccLister = ccInformer.Lister()
 	ctrl.ccListerSynced = ccInformer.Informer().HasSynced
+	ctrl.fileReferences = &ctrlcommon.FileReferenceResolver{
+		Secrets:    secretInformer.Lister().Secrets(ctrlcommon.MCONamespace),
+		ConfigMaps: configMapInformer.Lister().ConfigMaps(ctrlcommon.MCONamespace),
+	}
+	ctrl.secretListerSynced = secretInformer.Informer().HasSynced
+	ctrl.configMapListerSynced = configMapInformer.Informer().HasSynced
 
 	return ctrl
 }
@@ -117,7 +150,7 @@ func (ctrl *Controller) Run(workers int, stopCh <-chan struct{}) {
 	defer utilruntime.HandleCrash()
 	defer ctrl.queue.ShutDown()
 
-	if !cache.WaitForCacheSync(stopCh, ctrl.mcpListerSynced, ctrl.mcListerSynced, ctrl.ccListerSynced) {
+	if !cache.WaitForCacheSync(stopCh, ctrl.mcpListerSynced, ctrl.mcListerSynced, ctrl.ccListerSynced, ctrl.secretListerSynced, ctrl.configMapListerSynced) {
 		return
 	}
 
@@ -261,6 +294,62 @@ func (ctrl *Controller) deleteMachineConfig(obj interface{}) {
 	}
 }
 
+func (ctrl *Controller) addReferencedObject(obj interface{}) {
+	ctrl.enqueuePoolsReferencing(obj)
+}
+
+func (ctrl *Controller) updateReferencedObject(old, cur interface{}) {
+	oldObj, oldOK := old.(metav1.Object)
+	curObj, curOK := cur.(metav1.Object)
+	// Resyncs do not change the resourceVersion and need no new rendered config.
+	if oldOK && curOK && oldObj.GetResourceVersion() == curObj.GetResourceVersion() {
+		return
+	}
+	ctrl.enqueuePoolsReferencing(cur)
+}
+
+func (ctrl *Controller) deleteReferencedObject(obj interface{}) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	ctrl.enqueuePoolsReferencing(obj)
+}
+
+// enqueuePoolsReferencing enqueues the pools of the MachineConfigs with a file
+// reference to the given Secret or ConfigMap.
+func (ctrl *Controller) enqueuePoolsReferencing(obj interface{}) {
+	var kind, name string
+	switch o := obj.(type) {
+	case *corev1.Secret:
+		kind, name = "Secret", o.Name
+	case *corev1.ConfigMap:
+		kind, name = "ConfigMap", o.Name
+	default:
+		utilruntime.HandleError(fmt.Errorf("Couldn't get Secret or ConfigMap from %#v", obj))
+		return
+	}
+
+	mcs, err := ctrl.mcLister.List(labels.Everything())
+	if err != nil {
+		utilruntime.HandleError(fmt.Errorf("Couldn't list MachineConfigs: %w", err))
+		return
+	}
+	for _, mc := range mcs {
+		if !ctrlcommon.MachineConfigReferencesObject(mc, kind, name) {
+			continue
+		}
+		pools, err := ctrl.getPoolsForMachineConfig(mc)
+		if err != nil {
+			klog.Errorf("error finding pools for machineconfig: %v", err)
+			continue
+		}
+		klog.V(4).Infof("%s %s referenced by MachineConfig %s changed", kind, name, mc.Name)
+		for _, p := range pools {
+			ctrl.enqueueMachineConfigPool(p)
+		}
+	}
+}
+
 func (ctrl *Controller) resolveControllerRef(controllerRef *metav1.OwnerReference) *mcfgv1.MachineConfigPool {
 	// We can't look up by UID, so look up by Name and then verify UID.
 	// Don't even try to look up by Name if it's the wrong Kind.
@@ -399,7 +488,7 @@ func (ctrl *Controller) syncMachineConfigPool(key string) error {
 		return err
 	}
 	machineconfigpool, err := ctrl.mcpLister.Get(name)
-	if errors.IsNotFound(err) {
+	if apierrors.IsNotFound(err) {
 		klog.V(2).Infof("MachineConfigPool %v has been deleted", key)
 		return nil
 	}
@@ -454,7 +543,12 @@ func (ctrl *Controller) syncAvailableStatus(pool *mcfgv1.MachineConfigPool) erro
 }
 
 func (ctrl *Controller) syncFailingStatus(pool *mcfgv1.MachineConfigPool, err error) error {
-	sdegraded := mcfgv1.NewMachineConfigPoolCondition(mcfgv1.MachineConfigPoolRenderDegraded, corev1.ConditionTrue, "", fmt.Sprintf("Failed to render configuration for pool %s: %v", pool.Name, err))
+	reason := ""
+	var conflictsErr *ctrlcommon.ConfigConflictsError
+	if errors.As(err, &conflictsErr) {
+		reason = configConflictsReason
+	}
+	sdegraded := mcfgv1.NewMachineConfigPoolCondition(mcfgv1.MachineConfigPoolRenderDegraded, corev1.ConditionTrue, reason, fmt.Sprintf("Failed to render configuration for pool %s: %v", pool.Name, err))
 	mcfgv1.SetMachineConfigPoolCondition(&pool.Status, *sdegraded)
 	if _, updateErr := ctrl.client.MachineconfigurationV1().MachineConfigPools().UpdateStatus(context.TODO(), pool, metav1.UpdateOptions{}); updateErr != nil {
 		klog.Errorf("Error updating MachineConfigPool %s: %v", pool.Name, updateErr)
@@ -482,11 +576,27 @@ func (ctrl *Controller) syncGeneratedMachineConfig(pool *mcfgv1.MachineConfigPoo
 		return err
 	}
 
-	generated, err := generateRenderedMachineConfig(pool, configs, cc)
+	generated, conflicts, err := renderMachineConfig(pool, configs, cc, ctrl.fileReferences)
+	var conflictsErr *ctrlcommon.ConfigConflictsError
+	if err == nil || errors.As(err, &conflictsErr) {
+		if statusErr := ctrl.syncConfigConflictsStatus(pool, conflicts); statusErr != nil {
+			return statusErr
+		}
+	}
 	if err != nil {
 		return err
 	}
 
+	if pool.Annotations[ctrlcommon.EncryptSensitiveFilesAnnotationKey] == "true" {
+		key, err := ctrl.getContentEncryptionKey()
+		if err != nil {
+			return fmt.Errorf("could not get content encryption key: %w", err)
+		}
+		if err := encryptSensitiveFiles(pool, configs, generated, key); err != nil {
+			return err
+		}
+	}
+
 	// Emit event and collect metric when OSImageURL was overridden.
 	if generated.Spec.OSImageURL != ctrlcommon.GetDefaultBaseImageContainer(&cc.Spec) {
 		ctrlcommon.OSImageURLOverride.WithLabelValues(pool.Name).Set(1)
@@ -527,6 +637,8 @@ func (ctrl *Controller) syncGeneratedMachineConfig(pool *mcfgv1.MachineConfigPoo
 		return err
 	}
 
+	ctrl.emitRenderedConfigChangedEvent(pool, generated)
+
 	newPool.Spec.Configuration.Name = generated.Name
 	// TODO(walters) Use subresource or JSON patch, but the latter isn't supported by the unit test mocks
 	pool, err = ctrl.client.MachineconfigurationV1().MachineConfigPools().Update(context.TODO(), newPool, metav1.UpdateOptions{})
@@ -538,16 +650,85 @@ func (ctrl *Controller) syncGeneratedMachineConfig(pool *mcfgv1.MachineConfigPoo
 	return ctrl.garbageCollectRenderedConfigs(pool)
 }
 
+// syncConfigConflictsStatus records the conflicts between the source
+// MachineConfigs in the pool status when they change. pool is updated in
+// place, so that it can be updated again afterwards.
+func (ctrl *Controller) syncConfigConflictsStatus(pool *mcfgv1.MachineConfigPool, conflicts []mcfgv1.MachineConfigConflict) error {
+	if len(pool.Status.ConfigConflicts) == 0 && len(conflicts) == 0 || reflect.DeepEqual(pool.Status.ConfigConflicts, conflicts) {
+		return nil
+	}
+
+	for _, conflict := range conflicts {
+		klog.Warningf("Pool %s: %s", pool.Name, ctrlcommon.FormatMachineConfigConflict(conflict))
+	}
+	if len(conflicts) > 0 {
+		ctrl.eventRecorder.Eventf(pool, corev1.EventTypeWarning, "ConfigConflicts", "%d files, units or dropins are defined differently by more than one MachineConfig, first: %s",
+			len(conflicts), ctrlcommon.FormatMachineConfigConflict(conflicts[0]))
+	}
+
+	pool.Status.ConfigConflicts = conflicts
+	updated, err := ctrl.client.MachineconfigurationV1().MachineConfigPools().UpdateStatus(context.TODO(), pool, metav1.UpdateOptions{})
+	if err != nil {
+		return err
+	}
+	updated.DeepCopyInto(pool)
+	return nil
+}
+
+// emitRenderedConfigChangedEvent summarises which source MachineConfigs
+// changed between the rendered config the pool targets and generated. If the
+// targeted config predates the recorded sources, only the names of its sources
+// in the pool are compared.
+func (ctrl *Controller) emitRenderedConfigChangedEvent(pool *mcfgv1.MachineConfigPool, generated *mcfgv1.MachineConfig) {
+	previousName := pool.Spec.Configuration.Name
+	if previousName == "" {
+		return
+	}
+	current, err := ctrlcommon.GetSourceConfigs(generated)
+	if err != nil {
+		klog.Warningf("Could not summarise changes of %s: %v", generated.Name, err)
+		return
+	}
+
+	var previous []ctrlcommon.SourceConfig
+	if previousConfig, err := ctrl.mcLister.Get(previousName); err == nil {
+		if previous, err = ctrlcommon.GetSourceConfigs(previousConfig); err != nil {
+			klog.Warningf("Could not summarise changes of %s: %v", generated.Name, err)
+			return
+		}
+	}
+	if previous == nil {
+		for _, source := range pool.Spec.Configuration.Source {
+			previous = append(previous, ctrlcommon.SourceConfig{Name: source.Name})
+		}
+	}
+
+	diff := ctrlcommon.DiffSourceConfigs(previous, current)
+	klog.V(2).Infof("Pool %s: %s replaces %s: %s", pool.Name, generated.Name, previousName, diff)
+	ctrl.eventRecorder.Eventf(pool, corev1.EventTypeNormal, "RenderedConfigChanged", "%s replaces %s: %s", generated.Name, previousName, diff)
+}
+
 // generateRenderedMachineConfig takes all MCs for a given pool and returns a single rendered MC. For ex master-XXXX or worker-XXXX
-func generateRenderedMachineConfig(pool *mcfgv1.MachineConfigPool, configs []*mcfgv1.MachineConfig, cconfig *mcfgv1.ControllerConfig) (*mcfgv1.MachineConfig, error) {
+// File references of the MCs are resolved with fileReferences, which may be nil if none of the MCs has any.
+func generateRenderedMachineConfig(pool *mcfgv1.MachineConfigPool, configs []*mcfgv1.MachineConfig, cconfig *mcfgv1.ControllerConfig, fileReferences *ctrlcommon.FileReferenceResolver) (*mcfgv1.MachineConfig, error) {
+	generated, _, err := renderMachineConfig(pool, configs, cconfig, fileReferences)
+	return generated, err
+}
+
+// renderMachineConfig is generateRenderedMachineConfig, but also returns the
+// conflicts between the MCs. If the pool denies conflicts and there are any,
+// they are returned with a *ctrlcommon.ConfigConflictsError.
+//
+//nolint:gocyclo
+func renderMachineConfig(pool *mcfgv1.MachineConfigPool, configs []*mcfgv1.MachineConfig, cconfig *mcfgv1.ControllerConfig, fileReferences *ctrlcommon.FileReferenceResolver) (*mcfgv1.MachineConfig, []mcfgv1.MachineConfigConflict, error) {
 	// Suppress rendered config generation until a corresponding new controller can roll out too.
 	// https://bugzilla.redhat.com/show_bug.cgi?id=1879099
 	if genver, ok := cconfig.Annotations[daemonconsts.GeneratedByVersionAnnotationKey]; ok {
 		if genver != version.Raw {
-			return nil, fmt.Errorf("Ignoring controller config generated from %s (my version: %s)", genver, version.Raw)
+			return nil, nil, fmt.Errorf("Ignoring controller config generated from %s (my version: %s)", genver, version.Raw)
 		}
 	} else {
-		return nil, fmt.Errorf("Ignoring controller config generated without %s annotation (my version: %s)", daemonconsts.GeneratedByVersionAnnotationKey, version.Raw)
+		return nil, nil, fmt.Errorf("Ignoring controller config generated without %s annotation (my version: %s)", daemonconsts.GeneratedByVersionAnnotationKey, version.Raw)
 	}
 
 	// As an additional check, we should wait until all MCO-owned configs have been regenerated by the newest controller,
@@ -555,7 +736,7 @@ func generateRenderedMachineConfig(pool *mcfgv1.MachineConfigPool, configs []*mc
 	for _, config := range configs {
 		generatedByControllerVersion := config.Annotations[ctrlcommon.GeneratedByControllerVersionAnnotationKey]
 		if generatedByControllerVersion != "" && generatedByControllerVersion != version.Hash {
-			return nil, fmt.Errorf("Ignoring MC %s generated by older version %s (my version: %s)", config.Name, generatedByControllerVersion, version.Hash)
+			return nil, nil, fmt.Errorf("Ignoring MC %s generated by older version %s (my version: %s)", config.Name, generatedByControllerVersion, version.Hash)
 		}
 	}
 
@@ -568,18 +749,80 @@ func generateRenderedMachineConfig(pool *mcfgv1.MachineConfigPool, configs []*mc
 	// Before merging all MCs for a specific pool, let's make sure MachineConfigs are valid
 	for _, config := range configs {
 		if err := ctrlcommon.ValidateMachineConfig(config.Spec); err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 	}
 
-	merged, err := ctrlcommon.MergeMachineConfigs(configs, cconfig)
+	// MergeMachineConfigs sorts the configs it is given by name; the pool's
+	// configuration source relies on configs being sorted the same way.
+	sort.SliceStable(configs, func(i, j int) bool { return configs[i].Name < configs[j].Name })
+	resolved, referenced, err := ctrlcommon.ResolveFileReferences(configs, fileReferences)
+	if err != nil {
+		return nil, nil, err
+	}
+	resolved, restartServices, err := ctrlcommon.RenderNodeSettings(resolved)
+	if err != nil {
+		return nil, nil, err
+	}
 
+	// Conflicts are looked for after resolving, as file references and node
+	// settings add files as well.
+	conflicts, err := ctrlcommon.DetectMachineConfigConflicts(resolved)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+	switch policy := pool.Annotations[ctrlcommon.ConfigConflictPolicyAnnotationKey]; policy {
+	case "", ctrlcommon.ConfigConflictPolicyWarn:
+	case ctrlcommon.ConfigConflictPolicyDeny:
+		if len(conflicts) > 0 {
+			return nil, conflicts, &ctrlcommon.ConfigConflictsError{Conflicts: conflicts}
+		}
+	default:
+		return nil, nil, fmt.Errorf("invalid %s annotation %q, must be one of %s and %s", ctrlcommon.ConfigConflictPolicyAnnotationKey, policy, ctrlcommon.ConfigConflictPolicyWarn, ctrlcommon.ConfigConflictPolicyDeny)
+	}
+
+	merged, err := ctrlcommon.MergeMachineConfigs(resolved, cconfig)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// The referenced objects are recorded, and hashed into the name, so that
+	// it is visible which contents of them a rendered config was built from.
+	if len(referenced) > 0 {
+		if merged.Annotations == nil {
+			merged.Annotations = map[string]string{}
+		}
+		merged.Annotations[ctrlcommon.FileReferencesAnnotationKey] = strings.Join(referenced, ",")
+	}
+
+	// The daemon restarts these units instead of rebooting when only their
+	// files change. They follow from the spec, so they need no hashing.
+	if len(restartServices) > 0 {
+		data, err := json.Marshal(restartServices)
+		if err != nil {
+			return nil, nil, err
+		}
+		if merged.Annotations == nil {
+			merged.Annotations = map[string]string{}
+		}
+		merged.Annotations[ctrlcommon.RestartServicesAnnotationKey] = string(data)
+	}
+
+	driftPolicy, err := ctrlcommon.MergeConfigDriftPolicies(configs)
+	if err != nil {
+		return nil, nil, err
+	}
+	if driftPolicy != "" {
+		if merged.Annotations == nil {
+			merged.Annotations = map[string]string{}
+		}
+		merged.Annotations[ctrlcommon.ConfigDriftPolicyAnnotationKey] = driftPolicy
 	}
+
 	hashedName, err := getMachineConfigHashedName(pool, merged)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	oref := metav1.NewControllerRef(pool, controllerKind)
 
@@ -591,6 +834,18 @@ func generateRenderedMachineConfig(pool *mcfgv1.MachineConfigPool, configs []*mc
 	merged.Annotations[ctrlcommon.GeneratedByControllerVersionAnnotationKey] = version.Hash
 	merged.Annotations[ctrlcommon.ReleaseImageVersionAnnotationKey] = cconfig.Annotations[ctrlcommon.ReleaseImageVersionAnnotationKey]
 
+	// The sources are recorded after hashing, so that rendered config names do
+	// not change with the resourceVersions of their sources.
+	sources, err := ctrlcommon.NewSourceConfigs(configs)
+	if err != nil {
+		return nil, nil, err
+	}
+	data, err := json.Marshal(sources)
+	if err != nil {
+		return nil, nil, err
+	}
+	merged.Annotations[ctrlcommon.SourceConfigsAnnotationKey] = string(data)
+
 	// The operator needs to know the user overrode this, so it knows if it needs to skip the
 	// OSImageURL check during upgrade -- if the user took over managing OS upgrades this way,
 	// the operator shouldn't stop the rest of the upgrade from progressing/completing.
@@ -598,13 +853,71 @@ func generateRenderedMachineConfig(pool *mcfgv1.MachineConfigPool, configs []*mc
 		merged.Annotations[ctrlcommon.OSImageURLOverriddenKey] = "true"
 	}
 
-	return merged, nil
+	return merged, conflicts, nil
+}
+
+// getContentEncryptionKey returns the key the sensitive file contents of
+// rendered configs are encrypted with, creating it on first use.
+func (ctrl *Controller) getContentEncryptionKey() ([]byte, error) {
+	secret, err := ctrl.fileReferences.Secrets.Get(ctrlcommon.ContentEncryptionKeySecretName)
+	if apierrors.IsNotFound(err) {
+		var key []byte
+		key, err = ctrlcommon.NewContentEncryptionKey()
+		if err != nil {
+			return nil, err
+		}
+		secret = &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      ctrlcommon.ContentEncryptionKeySecretName,
+				Namespace: ctrlcommon.MCONamespace,
+			},
+			Data: map[string][]byte{ctrlcommon.ContentEncryptionKeySecretKey: key},
+		}
+		secret, err = ctrl.kubeClient.CoreV1().Secrets(ctrlcommon.MCONamespace).Create(context.TODO(), secret, metav1.CreateOptions{})
+		if apierrors.IsAlreadyExists(err) {
+			secret, err = ctrl.kubeClient.CoreV1().Secrets(ctrlcommon.MCONamespace).Get(context.TODO(), ctrlcommon.ContentEncryptionKeySecretName, metav1.GetOptions{})
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	key := secret.Data[ctrlcommon.ContentEncryptionKeySecretKey]
+	if len(key) == 0 {
+		return nil, fmt.Errorf("Secret %s/%s has no %q key", ctrlcommon.MCONamespace, ctrlcommon.ContentEncryptionKeySecretName, ctrlcommon.ContentEncryptionKeySecretKey)
+	}
+	return key, nil
+}
+
+// encryptSensitiveFiles encrypts the sensitive file contents of the rendered
+// config generated from configs. The encrypted paths are recorded, and hashed
+// into the name, so that opting a pool in rolls out a new rendered config. The
+// contents are encrypted with a key derived from that name, which the node
+// controller hands out to the nodes of the pool.
+func encryptSensitiveFiles(pool *mcfgv1.MachineConfigPool, configs []*mcfgv1.MachineConfig, generated *mcfgv1.MachineConfig, key []byte) error {
+	paths, err := ctrlcommon.SensitiveFilePaths(configs, generated)
+	if err != nil {
+		return err
+	}
+	if len(paths) == 0 {
+		return nil
+	}
+
+	generated.Annotations[ctrlcommon.EncryptedFilesAnnotationKey] = strings.Join(paths, ",")
+	hashedName, err := getMachineConfigHashedName(pool, generated)
+	if err != nil {
+		return err
+	}
+	generated.SetName(hashedName)
+
+	return ctrlcommon.EncryptMachineConfigFiles(generated, ctrlcommon.DeriveMachineConfigKey(key, hashedName), paths)
 }
 
 // RunBootstrap runs the render controller in bootstrap mode.
 // For each pool, it matches the machineconfigs based on label selector and
 // returns the generated machineconfigs and pool with CurrentMachineConfig status field set.
-func RunBootstrap(pools []*mcfgv1.MachineConfigPool, configs []*mcfgv1.MachineConfig, cconfig *mcfgv1.ControllerConfig) ([]*mcfgv1.MachineConfigPool, []*mcfgv1.MachineConfig, error) {
+// fileReferences resolves the file references of the machineconfigs and may be nil if there are none.
+func RunBootstrap(pools []*mcfgv1.MachineConfigPool, configs []*mcfgv1.MachineConfig, cconfig *mcfgv1.ControllerConfig, fileReferences *ctrlcommon.FileReferenceResolver) ([]*mcfgv1.MachineConfigPool, []*mcfgv1.MachineConfig, error) {
 	var (
 		opools   []*mcfgv1.MachineConfigPool
 		oconfigs []*mcfgv1.MachineConfig
@@ -615,7 +928,7 @@ func RunBootstrap(pools []*mcfgv1.MachineConfigPool, configs []*mcfgv1.MachineCo
 			return nil, nil, err
 		}
 
-		generated, err := generateRenderedMachineConfig(pool, pcs, cconfig)
+		generated, err := generateRenderedMachineConfig(pool, pcs, cconfig, fileReferences)
 		if err != nil {
 			return nil, nil, err
 		}
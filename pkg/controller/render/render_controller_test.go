@@ -1,6 +1,7 @@
 package render
 
 import (
+	"context"
 	"fmt"
 	"reflect"
 	"testing"
@@ -19,7 +20,9 @@ import (
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/diff"
 	utilrand "k8s.io/apimachinery/pkg/util/rand"
+	kubeinformers "k8s.io/client-go/informers"
 	k8sfake "k8s.io/client-go/kubernetes/fake"
+	corelisterv1 "k8s.io/client-go/listers/core/v1"
 	core "k8s.io/client-go/testing"
 	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/tools/record"
@@ -29,6 +32,7 @@ import (
 	daemonconsts "github.com/openshift/machine-config-operator/pkg/daemon/constants"
 	"github.com/openshift/machine-config-operator/pkg/generated/clientset/versioned/fake"
 	informers "github.com/openshift/machine-config-operator/pkg/generated/informers/externalversions"
+	mcfglistersv1 "github.com/openshift/machine-config-operator/pkg/generated/listers/machineconfiguration.openshift.io/v1"
 	"github.com/openshift/machine-config-operator/pkg/version"
 	"github.com/openshift/machine-config-operator/test/helpers"
 )
@@ -47,6 +51,9 @@ type fixture struct {
 	mcLister  []*mcfgv1.MachineConfig
 	ccLister  []*mcfgv1.ControllerConfig
 
+	// kubeObjects are the Secrets and ConfigMaps file references can point to.
+	kubeObjects []runtime.Object
+
 	actions []core.Action
 
 	objects []runtime.Object
@@ -63,13 +70,17 @@ func (f *fixture) newController() *Controller {
 	f.client = fake.NewSimpleClientset(f.objects...)
 
 	i := informers.NewSharedInformerFactory(f.client, noResyncPeriodFunc())
+	k8sI := kubeinformers.NewSharedInformerFactory(k8sfake.NewSimpleClientset(), noResyncPeriodFunc())
 
 	c := New(i.Machineconfiguration().V1().MachineConfigPools(), i.Machineconfiguration().V1().MachineConfigs(),
-		i.Machineconfiguration().V1().ControllerConfigs(), k8sfake.NewSimpleClientset(), f.client)
+		i.Machineconfiguration().V1().ControllerConfigs(), k8sI.Core().V1().Secrets(), k8sI.Core().V1().ConfigMaps(),
+		k8sfake.NewSimpleClientset(), f.client)
 
 	c.mcpListerSynced = alwaysReady
 	c.mcListerSynced = alwaysReady
 	c.ccListerSynced = alwaysReady
+	c.secretListerSynced = alwaysReady
+	c.configMapListerSynced = alwaysReady
 	c.eventRecorder = ctrlcommon.NamespacedEventRecorder(&record.FakeRecorder{})
 
 	stopCh := make(chan struct{})
@@ -90,6 +101,14 @@ func (f *fixture) newController() *Controller {
 	for _, m := range f.ccLister {
 		i.Machineconfiguration().V1().ControllerConfigs().Informer().GetIndexer().Add(m)
 	}
+	for _, o := range f.kubeObjects {
+		switch obj := o.(type) {
+		case *corev1.Secret:
+			k8sI.Core().V1().Secrets().Informer().GetIndexer().Add(obj)
+		case *corev1.ConfigMap:
+			k8sI.Core().V1().ConfigMaps().Informer().GetIndexer().Add(obj)
+		}
+	}
 
 	return c
 }
@@ -290,7 +309,7 @@ func TestIgnValidationGenerateRenderedMachineConfig(t *testing.T) {
 	}
 	cc := newControllerConfig(ctrlcommon.ControllerConfigName)
 
-	_, err := generateRenderedMachineConfig(mcp, mcs, cc)
+	_, err := generateRenderedMachineConfig(mcp, mcs, cc, nil)
 	require.Nil(t, err)
 
 	// verify that an invalid ignition config (here a config with content and an empty version,
@@ -302,7 +321,7 @@ func TestIgnValidationGenerateRenderedMachineConfig(t *testing.T) {
 	require.Nil(t, err)
 	mcs[1].Spec.Config.Raw = rawIgnCfg
 
-	_, err = generateRenderedMachineConfig(mcp, mcs, cc)
+	_, err = generateRenderedMachineConfig(mcp, mcs, cc, nil)
 	require.NotNil(t, err)
 
 	// verify that a machine config with no ignition content will not fail validation
@@ -311,7 +330,7 @@ func TestIgnValidationGenerateRenderedMachineConfig(t *testing.T) {
 	require.Nil(t, err)
 	mcs[1].Spec.Config.Raw = rawEmptyIgnCfg
 	mcs[1].Spec.KernelArguments = append(mcs[1].Spec.KernelArguments, "test1")
-	_, err = generateRenderedMachineConfig(mcp, mcs, cc)
+	_, err = generateRenderedMachineConfig(mcp, mcs, cc, nil)
 	require.Nil(t, err)
 
 }
@@ -334,7 +353,7 @@ func TestUpdatesGeneratedMachineConfig(t *testing.T) {
 	}
 	cc := newControllerConfig(ctrlcommon.ControllerConfigName)
 
-	gmc, err := generateRenderedMachineConfig(mcp, mcs, cc)
+	gmc, err := generateRenderedMachineConfig(mcp, mcs, cc, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -353,7 +372,7 @@ func TestUpdatesGeneratedMachineConfig(t *testing.T) {
 	f.mcLister = append(f.mcLister, gmc)
 	f.objects = append(f.objects, gmc)
 
-	expmc, err := generateRenderedMachineConfig(mcp, mcs, cc)
+	expmc, err := generateRenderedMachineConfig(mcp, mcs, cc, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -379,13 +398,320 @@ func TestGenerateMachineConfigOverrideOSImageURL(t *testing.T) {
 
 	cc := newControllerConfig(ctrlcommon.ControllerConfigName)
 
-	gmc, err := generateRenderedMachineConfig(mcp, mcs, cc)
+	gmc, err := generateRenderedMachineConfig(mcp, mcs, cc, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
 	assert.Equal(t, "dummy-change", gmc.Spec.OSImageURL)
 }
 
+func TestGenerateMachineConfigConfigDriftPolicy(t *testing.T) {
+	mcp := helpers.NewMachineConfigPool("test-cluster-master", helpers.MasterSelector, nil, "")
+	mcs := []*mcfgv1.MachineConfig{
+		helpers.NewMachineConfig("00-test-cluster-master", map[string]string{"node-role/master": ""}, "dummy-test-1", []ign3types.File{}),
+		helpers.NewMachineConfig("99-test-cluster-master", map[string]string{"node-role/master": ""}, "", []ign3types.File{}),
+	}
+
+	cc := newControllerConfig(ctrlcommon.ControllerConfigName)
+
+	withoutPolicy, err := generateRenderedMachineConfig(mcp, mcs, cc, nil)
+	require.NoError(t, err)
+	assert.NotContains(t, withoutPolicy.Annotations, ctrlcommon.ConfigDriftPolicyAnnotationKey)
+
+	mcs[0].Annotations = map[string]string{ctrlcommon.ConfigDriftPolicyAnnotationKey: `[{"path":"/etc/*","action":"Warn"}]`}
+	mcs[1].Annotations = map[string]string{ctrlcommon.ConfigDriftPolicyAnnotationKey: `[{"path":"/etc/resolv.conf","action":"Ignore"}]`}
+
+	withPolicy, err := generateRenderedMachineConfig(mcp, mcs, cc, nil)
+	require.NoError(t, err)
+
+	// The lexically last MachineConfig's rules take precedence.
+	assert.Equal(t, `[{"path":"/etc/resolv.conf","action":"Ignore"},{"path":"/etc/*","action":"Warn"}]`, withPolicy.Annotations[ctrlcommon.ConfigDriftPolicyAnnotationKey])
+	// Changing the policy has to roll out a new rendered config.
+	assert.NotEqual(t, withoutPolicy.Name, withPolicy.Name)
+
+	mcs[1].Annotations[ctrlcommon.ConfigDriftPolicyAnnotationKey] = `[{"path":"/etc/resolv.conf","action":"Explode"}]`
+	_, err = generateRenderedMachineConfig(mcp, mcs, cc, nil)
+	assert.Error(t, err)
+}
+
+func TestGenerateMachineConfigFileReferences(t *testing.T) {
+	mcp := helpers.NewMachineConfigPool("test-cluster-master", helpers.MasterSelector, nil, "")
+	mcs := []*mcfgv1.MachineConfig{
+		helpers.NewMachineConfig("00-test-cluster-master", map[string]string{"node-role/master": ""}, "dummy-test-1", []ign3types.File{}),
+		helpers.NewMachineConfig("99-test-cluster-master", map[string]string{"node-role/master": ""}, "", []ign3types.File{}),
+	}
+	cc := newControllerConfig(ctrlcommon.ControllerConfigName)
+
+	withoutRefs, err := generateRenderedMachineConfig(mcp, mcs, cc, nil)
+	require.NoError(t, err)
+	assert.NotContains(t, withoutRefs.Annotations, ctrlcommon.FileReferencesAnnotationKey)
+
+	secrets := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	resolver := &ctrlcommon.FileReferenceResolver{
+		Secrets:    corelisterv1.NewSecretLister(secrets).Secrets(ctrlcommon.MCONamespace),
+		ConfigMaps: corelisterv1.NewConfigMapLister(cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})).ConfigMaps(ctrlcommon.MCONamespace),
+	}
+	mcs[1].Spec.FileReferences = []mcfgv1.MachineConfigFileReference{{
+		Path: "/etc/token",
+		SecretKeyRef: &corev1.SecretKeySelector{
+			LocalObjectReference: corev1.LocalObjectReference{Name: "creds"},
+			Key:                  "token",
+		},
+	}}
+
+	// A missing Secret fails the render.
+	_, err = generateRenderedMachineConfig(mcp, mcs, cc, resolver)
+	assert.Error(t, err)
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "creds", Namespace: ctrlcommon.MCONamespace, ResourceVersion: "1"},
+		Data:       map[string][]byte{"token": []byte("s3cr3t")},
+	}
+	require.NoError(t, secrets.Add(secret))
+	withRefs, err := generateRenderedMachineConfig(mcp, mcs, cc, resolver)
+	require.NoError(t, err)
+	assert.Regexp(t, "^Secret/creds@[0-9a-f]{64}$", withRefs.Annotations[ctrlcommon.FileReferencesAnnotationKey])
+	assert.Empty(t, withRefs.Spec.FileReferences)
+	ignCfg, err := ctrlcommon.ParseAndConvertConfig(withRefs.Spec.Config.Raw)
+	require.NoError(t, err)
+	contents, err := ctrlcommon.GetIgnitionFileDataByPath(&ignCfg, "/etc/token")
+	require.NoError(t, err)
+	assert.Equal(t, "s3cr3t", string(contents))
+	// The source MachineConfig keeps its reference.
+	assert.Len(t, mcs[1].Spec.FileReferences, 1)
+
+	// The name does not depend on the resourceVersion of the Secret, which is
+	// empty when rendering during bootstrap.
+	bootstrap := secret.DeepCopy()
+	bootstrap.ResourceVersion = ""
+	require.NoError(t, secrets.Update(bootstrap))
+	withBootstrapRefs, err := generateRenderedMachineConfig(mcp, mcs, cc, resolver)
+	require.NoError(t, err)
+	assert.Equal(t, withRefs.Annotations[ctrlcommon.FileReferencesAnnotationKey], withBootstrapRefs.Annotations[ctrlcommon.FileReferencesAnnotationKey])
+	assert.Equal(t, withRefs.Name, withBootstrapRefs.Name)
+
+	// Other keys of the Secret do not matter either.
+	unrelated := secret.DeepCopy()
+	unrelated.ResourceVersion = "2"
+	unrelated.Data["other"] = []byte("unrelated")
+	require.NoError(t, secrets.Update(unrelated))
+	withUnrelatedRefs, err := generateRenderedMachineConfig(mcp, mcs, cc, resolver)
+	require.NoError(t, err)
+	assert.Equal(t, withRefs.Name, withUnrelatedRefs.Name)
+
+	// New contents of the referenced key roll out a new rendered config.
+	updated := secret.DeepCopy()
+	updated.ResourceVersion = "3"
+	updated.Data["token"] = []byte("n3w-s3cr3t")
+	require.NoError(t, secrets.Update(updated))
+	withUpdatedRefs, err := generateRenderedMachineConfig(mcp, mcs, cc, resolver)
+	require.NoError(t, err)
+	assert.NotEqual(t, withRefs.Annotations[ctrlcommon.FileReferencesAnnotationKey], withUpdatedRefs.Annotations[ctrlcommon.FileReferencesAnnotationKey])
+	assert.NotEqual(t, withRefs.Name, withUpdatedRefs.Name)
+}
+
+func TestGenerateMachineConfigNodeSettings(t *testing.T) {
+	mcp := helpers.NewMachineConfigPool("test-cluster-master", helpers.MasterSelector, nil, "")
+	mcs := []*mcfgv1.MachineConfig{
+		helpers.NewMachineConfig("00-test-cluster-master", map[string]string{"node-role/master": ""}, "dummy-test-1", []ign3types.File{}),
+		helpers.NewMachineConfig("99-test-cluster-master", map[string]string{"node-role/master": ""}, "", []ign3types.File{}),
+	}
+	cc := newControllerConfig(ctrlcommon.ControllerConfigName)
+
+	withoutSettings, err := generateRenderedMachineConfig(mcp, mcs, cc, nil)
+	require.NoError(t, err)
+	assert.NotContains(t, withoutSettings.Annotations, ctrlcommon.RestartServicesAnnotationKey)
+
+	mcs[1].Spec.NodeSettings = &mcfgv1.MachineConfigNodeSettings{
+		Sysctls:   []mcfgv1.MachineConfigSysctl{{Name: "vm.swappiness", Value: "10"}},
+		Hugepages: &mcfgv1.MachineConfigHugepages{Pages: []mcfgv1.MachineConfigHugepagesPage{{Size: "2M", Count: 128}}},
+	}
+	withSettings, err := generateRenderedMachineConfig(mcp, mcs, cc, nil)
+	require.NoError(t, err)
+	assert.NotEqual(t, withoutSettings.Name, withSettings.Name)
+	assert.Nil(t, withSettings.Spec.NodeSettings)
+	assert.Equal(t, []string{"hugepagesz=2M", "hugepages=128"}, withSettings.Spec.KernelArguments)
+	assert.Equal(t, `{"/etc/sysctl.d/99-mco-99-test-cluster-master.conf":"systemd-sysctl.service"}`, withSettings.Annotations[ctrlcommon.RestartServicesAnnotationKey])
+	ignCfg, err := ctrlcommon.ParseAndConvertConfig(withSettings.Spec.Config.Raw)
+	require.NoError(t, err)
+	contents, err := ctrlcommon.GetIgnitionFileDataByPath(&ignCfg, "/etc/sysctl.d/99-mco-99-test-cluster-master.conf")
+	require.NoError(t, err)
+	assert.Contains(t, string(contents), "vm.swappiness = 10\n")
+}
+
+func TestRenderedConfigChangedEvent(t *testing.T) {
+	mcp := helpers.NewMachineConfigPool("test-cluster-master", helpers.MasterSelector, nil, "")
+	mcs := []*mcfgv1.MachineConfig{
+		helpers.NewMachineConfig("00-test-cluster-master", map[string]string{"node-role/master": ""}, "dummy-test-1", []ign3types.File{}),
+		helpers.NewMachineConfig("99-test-cluster-master", map[string]string{"node-role/master": ""}, "", []ign3types.File{}),
+	}
+	cc := newControllerConfig(ctrlcommon.ControllerConfigName)
+
+	previous, err := generateRenderedMachineConfig(mcp, mcs, cc, nil)
+	require.NoError(t, err)
+	sources, err := ctrlcommon.GetSourceConfigs(previous)
+	require.NoError(t, err)
+	require.Len(t, sources, 2)
+	assert.Equal(t, "00-test-cluster-master", sources[0].Name)
+
+	// New resourceVersions of the sources are recorded, but keep the name.
+	mcs[0].ResourceVersion = "2"
+	again, err := generateRenderedMachineConfig(mcp, mcs, cc, nil)
+	require.NoError(t, err)
+	assert.Equal(t, previous.Name, again.Name)
+	assert.NotEqual(t, previous.Annotations[ctrlcommon.SourceConfigsAnnotationKey], again.Annotations[ctrlcommon.SourceConfigsAnnotationKey])
+
+	mcs[1].Spec.KernelArguments = []string{"nosmt"}
+	mcs = append(mcs, helpers.NewMachineConfig("99-test-cluster-master-chrony", map[string]string{"node-role/master": ""}, "", []ign3types.File{}))
+	generated, err := generateRenderedMachineConfig(mcp, mcs, cc, nil)
+	require.NoError(t, err)
+
+	mcIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	require.NoError(t, mcIndexer.Add(previous))
+	recorder := record.NewFakeRecorder(10)
+	ctrl := &Controller{
+		mcLister:      mcfglistersv1.NewMachineConfigLister(mcIndexer),
+		eventRecorder: recorder,
+	}
+
+	mcp.Spec.Configuration.Name = previous.Name
+	ctrl.emitRenderedConfigChangedEvent(mcp, generated)
+	assert.Equal(t, fmt.Sprintf("Normal RenderedConfigChanged %s replaces %s: added [99-test-cluster-master-chrony]; modified [99-test-cluster-master]", generated.Name, previous.Name), <-recorder.Events)
+
+	// Without the previous rendered config only the names in the pool are compared.
+	mcp.Spec.Configuration.Name = "rendered-test-cluster-master-gone"
+	mcp.Spec.Configuration.Source = []corev1.ObjectReference{{Name: "00-test-cluster-master"}, {Name: "99-test-cluster-master"}}
+	ctrl.emitRenderedConfigChangedEvent(mcp, generated)
+	assert.Equal(t, fmt.Sprintf("Normal RenderedConfigChanged %s replaces rendered-test-cluster-master-gone: added [99-test-cluster-master-chrony]", generated.Name), <-recorder.Events)
+}
+
+func TestConfigConflicts(t *testing.T) {
+	for _, policy := range []string{"", ctrlcommon.ConfigConflictPolicyDeny} {
+		policy := policy
+		t.Run(policy, func(t *testing.T) {
+			f := newFixture(t)
+			mcp := helpers.NewMachineConfigPool("test-cluster-master", helpers.MasterSelector, nil, "")
+			if policy != "" {
+				mcp.Annotations = map[string]string{ctrlcommon.ConfigConflictPolicyAnnotationKey: policy}
+			}
+			mcs := []*mcfgv1.MachineConfig{
+				helpers.NewMachineConfig("00-test-cluster-master", map[string]string{"node-role/master": ""}, "dummy://", []ign3types.File{
+					ctrlcommon.NewIgnFile("/etc/chrony.conf", "pool 2.rhel.pool.ntp.org iburst\n"),
+				}),
+				helpers.NewMachineConfig("99-test-cluster-master-chrony", map[string]string{"node-role/master": ""}, "", []ign3types.File{
+					ctrlcommon.NewIgnFile("/etc/chrony.conf", "server ntp.example.com iburst\n"),
+				}),
+			}
+			f.ccLister = append(f.ccLister, newControllerConfig(ctrlcommon.ControllerConfigName))
+			f.mcpLister = append(f.mcpLister, mcp)
+			f.objects = append(f.objects, mcp)
+			f.mcLister = append(f.mcLister, mcs...)
+			for idx := range mcs {
+				f.objects = append(f.objects, mcs[idx])
+			}
+
+			c := f.newController()
+			err := c.syncHandler(getKey(mcp, t))
+			pool, getErr := f.client.MachineconfigurationV1().MachineConfigPools().Get(context.TODO(), mcp.Name, metav1.GetOptions{})
+			require.NoError(t, getErr)
+			assert.Equal(t, []mcfgv1.MachineConfigConflict{{
+				Type:   mcfgv1.MachineConfigConflictFile,
+				Name:   "/etc/chrony.conf",
+				Winner: "99-test-cluster-master-chrony",
+				Losers: []string{"00-test-cluster-master"},
+			}}, pool.Status.ConfigConflicts)
+
+			degraded := mcfgv1.GetMachineConfigPoolCondition(pool.Status, mcfgv1.MachineConfigPoolRenderDegraded)
+			if policy == ctrlcommon.ConfigConflictPolicyDeny {
+				var conflictsErr *ctrlcommon.ConfigConflictsError
+				assert.ErrorAs(t, err, &conflictsErr)
+				assert.Empty(t, pool.Spec.Configuration.Name)
+				require.NotNil(t, degraded)
+				assert.Equal(t, corev1.ConditionTrue, degraded.Status)
+				assert.Equal(t, configConflictsReason, degraded.Reason)
+			} else {
+				assert.NoError(t, err)
+				assert.NotEmpty(t, pool.Spec.Configuration.Name)
+			}
+		})
+	}
+}
+
+func TestEncryptSensitiveFiles(t *testing.T) {
+	mcp := helpers.NewMachineConfigPool("test-cluster-master", helpers.MasterSelector, nil, "")
+	mcs := []*mcfgv1.MachineConfig{
+		helpers.NewMachineConfig("00-test-cluster-master", map[string]string{"node-role/master": ""}, "dummy-test-1", []ign3types.File{
+			ctrlcommon.NewIgnFile("/etc/motd", "hello"),
+		}),
+	}
+	cc := newControllerConfig(ctrlcommon.ControllerConfigName)
+	key := []byte("content-encryption-key")
+
+	// Without sensitive files the rendered config is left alone.
+	plain, err := generateRenderedMachineConfig(mcp, mcs, cc, nil)
+	require.NoError(t, err)
+	unchanged := plain.DeepCopy()
+	require.NoError(t, encryptSensitiveFiles(mcp, mcs, unchanged, key))
+	assert.Equal(t, plain, unchanged)
+
+	mcs = append(mcs, helpers.NewMachineConfig("01-test-cluster-master-kubelet", map[string]string{"node-role/master": ""}, "", []ign3types.File{
+		ctrlcommon.NewIgnFile("/var/lib/kubelet/config.json", "pull-secret"),
+	}))
+	generated, err := generateRenderedMachineConfig(mcp, mcs, cc, nil)
+	require.NoError(t, err)
+	encrypted := generated.DeepCopy()
+	require.NoError(t, encryptSensitiveFiles(mcp, mcs, encrypted, key))
+	assert.Equal(t, "/var/lib/kubelet/config.json", encrypted.Annotations[ctrlcommon.EncryptedFilesAnnotationKey])
+	assert.NotEqual(t, generated.Name, encrypted.Name)
+
+	// Rendering again results in the same config.
+	again := generated.DeepCopy()
+	require.NoError(t, encryptSensitiveFiles(mcp, mcs, again, key))
+	assert.Equal(t, encrypted, again)
+
+	decrypted, err := ctrlcommon.DecryptMachineConfig(encrypted, ctrlcommon.DeriveMachineConfigKey(key, encrypted.Name))
+	require.NoError(t, err)
+	ignCfg, err := ctrlcommon.ParseAndConvertConfig(decrypted.Spec.Config.Raw)
+	require.NoError(t, err)
+	for path, expected := range map[string]string{"/var/lib/kubelet/config.json": "pull-secret", "/etc/motd": "hello"} {
+		contents, err := ctrlcommon.GetIgnitionFileDataByPath(&ignCfg, path)
+		require.NoError(t, err)
+		assert.Equal(t, expected, string(contents))
+	}
+}
+
+func TestReferencedObjectChangeEnqueuesPool(t *testing.T) {
+	f := newFixture(t)
+	master := helpers.NewMachineConfigPool("test-cluster-master", helpers.MasterSelector, nil, "")
+	worker := helpers.NewMachineConfigPool("test-cluster-worker", helpers.WorkerSelector, nil, "")
+	mc := helpers.NewMachineConfig("99-test-cluster-master", map[string]string{"node-role/master": ""}, "", []ign3types.File{})
+	mc.Spec.FileReferences = []mcfgv1.MachineConfigFileReference{{
+		Path: "/etc/token",
+		SecretKeyRef: &corev1.SecretKeySelector{
+			LocalObjectReference: corev1.LocalObjectReference{Name: "creds"},
+			Key:                  "token",
+		},
+	}}
+	f.mcpLister = append(f.mcpLister, master, worker)
+	f.mcLister = append(f.mcLister, mc)
+	c := f.newController()
+	var enqueued []string
+	c.enqueueMachineConfigPool = func(pool *mcfgv1.MachineConfigPool) {
+		enqueued = append(enqueued, pool.Name)
+	}
+
+	oldSecret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "creds", Namespace: ctrlcommon.MCONamespace, ResourceVersion: "1"}}
+	c.updateReferencedObject(oldSecret, oldSecret)
+	assert.Empty(t, enqueued, "resyncs must not enqueue pools")
+
+	c.updateReferencedObject(oldSecret, &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "creds", Namespace: ctrlcommon.MCONamespace, ResourceVersion: "2"}})
+	assert.Equal(t, []string{master.Name}, enqueued)
+
+	enqueued = nil
+	c.addReferencedObject(&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "creds", Namespace: ctrlcommon.MCONamespace}})
+	assert.Empty(t, enqueued, "a ConfigMap with the name of a referenced Secret is not referenced")
+}
+
 func TestVersionSkew(t *testing.T) {
 	mcp := helpers.NewMachineConfigPool("test-cluster-master", helpers.MasterSelector, nil, "")
 	mcs := []*mcfgv1.MachineConfig{
@@ -395,12 +721,12 @@ func TestVersionSkew(t *testing.T) {
 
 	cc := newControllerConfig(ctrlcommon.ControllerConfigName)
 	cc.Annotations[daemonconsts.GeneratedByVersionAnnotationKey] = "different-version"
-	_, err := generateRenderedMachineConfig(mcp, mcs, cc)
+	_, err := generateRenderedMachineConfig(mcp, mcs, cc, nil)
 	require.NotNil(t, err)
 
 	// Now the same thing without overriding the version
 	cc = newControllerConfig(ctrlcommon.ControllerConfigName)
-	gmc, err := generateRenderedMachineConfig(mcp, mcs, cc)
+	gmc, err := generateRenderedMachineConfig(mcp, mcs, cc, nil)
 	require.Nil(t, err)
 	require.NotNil(t, gmc)
 }
@@ -413,14 +739,14 @@ func TestGenerateRenderedConfigOnLatestControllerVersionOnly(t *testing.T) {
 	}
 	version.Hash = "2"
 	cc := newControllerConfig(ctrlcommon.ControllerConfigName)
-	_, err := generateRenderedMachineConfig(mcp, mcs, cc)
+	_, err := generateRenderedMachineConfig(mcp, mcs, cc, nil)
 	require.NotNil(t, err)
 
 	mcs = []*mcfgv1.MachineConfig{
 		helpers.NewMachineConfigWithAnnotation("00-updated-conf", map[string]string{"node-role/master": ""}, map[string]string{ctrlcommon.GeneratedByControllerVersionAnnotationKey: "2"}, "dummy-test-1", []ign3types.File{}),
 		helpers.NewMachineConfigWithAnnotation("99-user-conf", map[string]string{"node-role/master": ""}, map[string]string{ctrlcommon.GeneratedByControllerVersionAnnotationKey: ""}, "user-data", []ign3types.File{}),
 	}
-	_, err = generateRenderedMachineConfig(mcp, mcs, cc)
+	_, err = generateRenderedMachineConfig(mcp, mcs, cc, nil)
 	require.Nil(t, err)
 }
 
@@ -442,7 +768,7 @@ func TestDoNothing(t *testing.T) {
 	}
 	cc := newControllerConfig(ctrlcommon.ControllerConfigName)
 
-	gmc, err := generateRenderedMachineConfig(mcp, mcs, cc)
+	gmc, err := generateRenderedMachineConfig(mcp, mcs, cc, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
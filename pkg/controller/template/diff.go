@@ -0,0 +1,140 @@
+package template
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pmezard/go-difflib/difflib"
+
+	mcfgv1 "github.com/openshift/machine-config-operator/pkg/apis/machineconfiguration.openshift.io/v1"
+	ctrlcommon "github.com/openshift/machine-config-operator/pkg/controller/common"
+)
+
+// DiffMachineConfigs returns a unified diff between two sets of MachineConfigs,
+// matched by name. File contents are decoded so that the diff shows the
+// changed lines rather than changed data URLs. An empty string means that both
+// sets are equal.
+func DiffMachineConfigs(from, to []*mcfgv1.MachineConfig) (string, error) {
+	fromByName := map[string]*mcfgv1.MachineConfig{}
+	toByName := map[string]*mcfgv1.MachineConfig{}
+	names := map[string]struct{}{}
+	for _, mc := range from {
+		fromByName[mc.Name] = mc
+		names[mc.Name] = struct{}{}
+	}
+	for _, mc := range to {
+		toByName[mc.Name] = mc
+		names[mc.Name] = struct{}{}
+	}
+
+	sorted := make([]string, 0, len(names))
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+
+	var out strings.Builder
+	for _, name := range sorted {
+		fromText, err := machineConfigText(fromByName[name])
+		if err != nil {
+			return "", err
+		}
+		toText, err := machineConfigText(toByName[name])
+		if err != nil {
+			return "", err
+		}
+		diff, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+			A:        difflib.SplitLines(fromText),
+			B:        difflib.SplitLines(toText),
+			FromFile: "a/" + name,
+			ToFile:   "b/" + name,
+			Context:  3,
+		})
+		if err != nil {
+			return "", fmt.Errorf("could not diff MachineConfig %s: %w", name, err)
+		}
+		out.WriteString(diff)
+	}
+	return out.String(), nil
+}
+
+// machineConfigText returns a stable, human readable representation of the
+// parts of a MachineConfig which end up on the node.
+func machineConfigText(mc *mcfgv1.MachineConfig) (string, error) {
+	if mc == nil {
+		return "", nil
+	}
+
+	ign, err := ctrlcommon.ParseAndConvertConfig(mc.Spec.Config.Raw)
+	if err != nil {
+		return "", fmt.Errorf("could not parse Ignition config of MachineConfig %s: %w", mc.Name, err)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "role: %s\n", mc.Labels[mcfgv1.MachineConfigRoleLabelKey])
+	if mc.Spec.OSImageURL != "" {
+		fmt.Fprintf(&b, "osImageURL: %s\n", mc.Spec.OSImageURL)
+	}
+	if len(mc.Spec.KernelArguments) > 0 {
+		fmt.Fprintf(&b, "kernelArguments: %s\n", strings.Join(mc.Spec.KernelArguments, " "))
+	}
+	if len(mc.Spec.Extensions) > 0 {
+		fmt.Fprintf(&b, "extensions: %s\n", strings.Join(mc.Spec.Extensions, " "))
+	}
+	if mc.Spec.KernelType != "" {
+		fmt.Fprintf(&b, "kernelType: %s\n", mc.Spec.KernelType)
+	}
+	if mc.Spec.FIPS {
+		b.WriteString("fips: true\n")
+	}
+
+	files := ign.Storage.Files
+	sort.SliceStable(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+	for _, f := range files {
+		mode := "default"
+		if f.Mode != nil {
+			mode = fmt.Sprintf("%04o", *f.Mode)
+		}
+		fmt.Fprintf(&b, "file %s (mode %s)\n", f.Path, mode)
+		contents, err := ctrlcommon.DecodeIgnitionFileContents(f.Contents.Source, f.Contents.Compression)
+		if err != nil {
+			return "", fmt.Errorf("could not decode file %s in MachineConfig %s: %w", f.Path, mc.Name, err)
+		}
+		writeIndented(&b, string(contents))
+	}
+
+	units := ign.Systemd.Units
+	sort.SliceStable(units, func(i, j int) bool { return units[i].Name < units[j].Name })
+	for _, u := range units {
+		fmt.Fprintf(&b, "unit %s", u.Name)
+		if u.Enabled != nil {
+			fmt.Fprintf(&b, " (enabled %t)", *u.Enabled)
+		}
+		if u.Mask != nil && *u.Mask {
+			b.WriteString(" (masked)")
+		}
+		b.WriteString("\n")
+		if u.Contents != nil {
+			writeIndented(&b, *u.Contents)
+		}
+		dropins := u.Dropins
+		sort.SliceStable(dropins, func(i, j int) bool { return dropins[i].Name < dropins[j].Name })
+		for _, d := range dropins {
+			fmt.Fprintf(&b, "dropin %s/%s\n", u.Name, d.Name)
+			if d.Contents != nil {
+				writeIndented(&b, *d.Contents)
+			}
+		}
+	}
+
+	return b.String(), nil
+}
+
+func writeIndented(b *strings.Builder, s string) {
+	for _, line := range strings.Split(strings.TrimSuffix(s, "\n"), "\n") {
+		b.WriteString("    ")
+		b.WriteString(line)
+		b.WriteString("\n")
+	}
+}
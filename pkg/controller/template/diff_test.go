@@ -0,0 +1,55 @@
+package template
+
+import (
+	"strings"
+	"testing"
+
+	ign3types "github.com/coreos/ignition/v2/config/v3_4/types"
+
+	mcfgv1 "github.com/openshift/machine-config-operator/pkg/apis/machineconfiguration.openshift.io/v1"
+	ctrlcommon "github.com/openshift/machine-config-operator/pkg/controller/common"
+)
+
+func newDiffMachineConfig(t *testing.T, name, contents string) *mcfgv1.MachineConfig {
+	ignCfg := ctrlcommon.NewIgnConfig()
+	ignCfg.Storage.Files = append(ignCfg.Storage.Files, ctrlcommon.NewIgnFile("/etc/example", contents))
+	unitContents := "[Unit]\nDescription=example\n"
+	ignCfg.Systemd.Units = append(ignCfg.Systemd.Units, ign3types.Unit{Name: "example.service", Contents: &unitContents})
+	mc, err := ctrlcommon.MachineConfigFromIgnConfig("worker", name, ignCfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return mc
+}
+
+func TestDiffMachineConfigs(t *testing.T) {
+	unchanged := newDiffMachineConfig(t, "00-unchanged", "same\n")
+	from := []*mcfgv1.MachineConfig{unchanged, newDiffMachineConfig(t, "01-changed", "old\n"), newDiffMachineConfig(t, "02-removed", "removed\n")}
+	to := []*mcfgv1.MachineConfig{unchanged, newDiffMachineConfig(t, "01-changed", "new\n")}
+
+	diff, err := DiffMachineConfigs(from, to)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(diff, "00-unchanged") {
+		t.Errorf("expected no diff for unchanged MachineConfig, got:\n%s", diff)
+	}
+	for _, expected := range []string{
+		"--- a/01-changed\n+++ b/01-changed\n",
+		"-    old\n+    new\n",
+		"--- a/02-removed\n+++ b/02-removed\n",
+		"-file /etc/example (mode 0644)\n-    removed\n",
+	} {
+		if !strings.Contains(diff, expected) {
+			t.Errorf("expected diff to contain %q, got:\n%s", expected, diff)
+		}
+	}
+
+	diff, err = DiffMachineConfigs(to, to)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff != "" {
+		t.Errorf("expected no diff between equal sets, got:\n%s", diff)
+	}
+}
@@ -0,0 +1,367 @@
+package template
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+	"text/template/parse"
+
+	fcctbase "github.com/coreos/fcct/base/v0_1"
+	"github.com/ghodss/yaml"
+
+	ctrlcommon "github.com/openshift/machine-config-operator/pkg/controller/common"
+)
+
+// LintSeverity is the severity of a LintIssue.
+type LintSeverity string
+
+const (
+	// LintWarning is an issue which does not change the rendered MachineConfigs,
+	// but likely points at dead or unintended template content.
+	LintWarning LintSeverity = "warning"
+	// LintError is an issue where template content is silently dropped from the
+	// rendered MachineConfigs.
+	LintError LintSeverity = "error"
+)
+
+// LintIssue is a problem found in the templates directory.
+type LintIssue struct {
+	Severity LintSeverity
+	// Path is the template file the issue was found in, relative to the templates directory.
+	Path    string
+	Message string
+}
+
+func (i LintIssue) String() string {
+	return fmt.Sprintf("%s: %s: %s", i.Severity, i.Path, i.Message)
+}
+
+// templateSource is a template file which was rendered for a MachineConfig.
+type templateSource struct {
+	path     string
+	rendered string
+}
+
+// LintTemplates checks the templates in templateDir for:
+//   - template functions which are not used by any template,
+//   - templates which render to nothing for the given config, and
+//   - templates from the _base, on-prem, platform and sno directories which
+//     override each other or write the same destination, so that one of
+//     them is silently dropped.
+//
+// The returned issues are sorted by path.
+func LintTemplates(config *RenderConfig, templateDir string) ([]LintIssue, error) {
+	issues, err := lintUnusedFunctions(templateDir)
+	if err != nil {
+		return nil, err
+	}
+
+	infos, err := ctrlcommon.ReadDir(templateDir)
+	if err != nil {
+		return nil, err
+	}
+	for _, info := range infos {
+		if !info.IsDir() || info.Name() == "common" {
+			continue
+		}
+		roleIssues, err := lintRole(config, info.Name(), templateDir)
+		if err != nil {
+			return nil, fmt.Errorf("failed to lint templates for role %s: %w", info.Name(), err)
+		}
+		issues = append(issues, roleIssues...)
+	}
+
+	sort.SliceStable(issues, func(i, j int) bool { return issues[i].Path < issues[j].Path })
+
+	// Templates in common are rendered for every role, report them only once.
+	deduped := []LintIssue{}
+	seen := map[LintIssue]bool{}
+	for _, issue := range issues {
+		if !seen[issue] {
+			seen[issue] = true
+			deduped = append(deduped, issue)
+		}
+	}
+	return deduped, nil
+}
+
+// lintRole renders the templates of a role the same way GenerateMachineConfigsForRole
+// does, but keeps track of which template every file and unit came from.
+func lintRole(config *RenderConfig, role, templateDir string) ([]LintIssue, error) {
+	path := filepath.Join(templateDir, role)
+	infos, err := ctrlcommon.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var issues []LintIssue
+	// destinations maps the files, units and dropins written by the role to
+	// the template which writes them.
+	destinations := map[string]string{}
+	var commonAdded bool
+	for _, info := range infos {
+		if !info.IsDir() {
+			continue
+		}
+		platformDirs, err := platformDirsForName(config, templateDir, filepath.Join(path, info.Name()), &commonAdded)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, typeDir := range []string{filesDir, unitsDir} {
+			sources := map[string]templateSource{}
+			for _, platformDir := range platformDirs {
+				p := filepath.Join(platformDir, typeDir)
+				exists, err := existsDir(p)
+				if err != nil {
+					return nil, err
+				}
+				if !exists {
+					continue
+				}
+				dirIssues, err := lintTemplateDir(config, templateDir, p, typeDir, sources)
+				if err != nil {
+					return nil, err
+				}
+				issues = append(issues, dirIssues...)
+			}
+
+			names := make([]string, 0, len(sources))
+			for name := range sources {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			for _, name := range names {
+				src := sources[name]
+				dests, err := templateDestinations(typeDir, src.rendered)
+				if err != nil {
+					return nil, fmt.Errorf("failed to parse rendered template %s: %w", src.path, err)
+				}
+				for _, dest := range dests {
+					if other, ok := destinations[dest]; ok {
+						issues = append(issues, LintIssue{
+							Severity: LintError,
+							Path:     src.path,
+							Message:  fmt.Sprintf("writes %s, which is also written by %s", dest, other),
+						})
+						continue
+					}
+					destinations[dest] = src.path
+				}
+			}
+		}
+	}
+
+	return issues, nil
+}
+
+// lintTemplateDir renders the templates in dir into sources, keyed by the
+// template name like filterTemplates does, and reports templates which
+// render to nothing and overrides which change the destination.
+func lintTemplateDir(config *RenderConfig, templateDir, dir, typeDir string, sources map[string]templateSource) ([]LintIssue, error) {
+	var issues []LintIssue
+	walkFn := func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+
+		// empty templates remove a template from a lower level on purpose
+		if info.Size() == 0 {
+			delete(sources, info.Name())
+			return nil
+		}
+
+		filedata, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read file %q: %w", path, err)
+		}
+		renderedData, err := renderTemplate(*config, path, filedata)
+		if err != nil {
+			return err
+		}
+		path = relPath(templateDir, path)
+		if len(bytes.TrimSpace(renderedData)) == 0 {
+			issues = append(issues, LintIssue{
+				Severity: LintWarning,
+				Path:     path,
+				Message:  "renders to empty output and is omitted",
+			})
+			return nil
+		}
+
+		rendered := string(renderedData)
+		if prev, ok := sources[info.Name()]; ok {
+			prevDests, err := templateDestinations(typeDir, prev.rendered)
+			if err != nil {
+				return fmt.Errorf("failed to parse rendered template %s: %w", prev.path, err)
+			}
+			dests, err := templateDestinations(typeDir, rendered)
+			if err != nil {
+				return fmt.Errorf("failed to parse rendered template %s: %w", path, err)
+			}
+			if missing := subtract(prevDests, dests); len(missing) > 0 {
+				issues = append(issues, LintIssue{
+					Severity: LintError,
+					Path:     path,
+					Message:  fmt.Sprintf("overrides %s, which writes %s, but does not write it itself", prev.path, strings.Join(missing, ", ")),
+				})
+			}
+		}
+		sources[info.Name()] = templateSource{path: path, rendered: rendered}
+		return nil
+	}
+
+	if err := filepath.Walk(dir, walkFn); err != nil {
+		return nil, err
+	}
+	return issues, nil
+}
+
+// templateDestinations returns the files, units and dropins written by a rendered template.
+func templateDestinations(typeDir, rendered string) ([]string, error) {
+	if typeDir == filesDir {
+		f := fcctbase.File{}
+		if err := yaml.Unmarshal([]byte(rendered), &f); err != nil {
+			return nil, err
+		}
+		return []string{"file " + f.Path}, nil
+	}
+
+	u := fcctbase.Unit{}
+	if err := yaml.Unmarshal([]byte(rendered), &u); err != nil {
+		return nil, err
+	}
+	var dests []string
+	// A template which only enables or masks a unit does not write anything.
+	if u.Contents != nil {
+		dests = append(dests, "unit "+u.Name)
+	}
+	for _, dropin := range u.Dropins {
+		dests = append(dests, fmt.Sprintf("dropin %s/%s", u.Name, dropin.Name))
+	}
+	return dests, nil
+}
+
+// relPath returns path relative to the templates directory.
+func relPath(templateDir, path string) string {
+	if rel, err := filepath.Rel(templateDir, path); err == nil {
+		return rel
+	}
+	return path
+}
+
+// subtract returns the entries of a which are not in b.
+func subtract(a, b []string) []string {
+	var out []string
+	for _, x := range a {
+		found := false
+		for _, y := range b {
+			if x == y {
+				found = true
+				break
+			}
+		}
+		if !found {
+			out = append(out, x)
+		}
+	}
+	return out
+}
+
+// lintUnusedFunctions reports template functions which are not called from
+// any template in templateDir. Unlike the other checks this does not depend on
+// the config, so it is reported once against the templates directory.
+func lintUnusedFunctions(templateDir string) ([]LintIssue, error) {
+	funcs := templateFuncs()
+	used := map[string]bool{}
+
+	walkFn := func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || info.Size() == 0 {
+			return nil
+		}
+		filedata, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read file %q: %w", path, err)
+		}
+		tmpl, err := template.New(path).Funcs(funcs).Parse(string(filedata))
+		if err != nil {
+			return fmt.Errorf("failed to parse template %s: %w", path, err)
+		}
+		for _, t := range tmpl.Templates() {
+			if t.Tree != nil {
+				collectIdentifiers(t.Tree.Root, used)
+			}
+		}
+		return nil
+	}
+	if err := filepath.Walk(templateDir, walkFn); err != nil {
+		return nil, err
+	}
+
+	var issues []LintIssue
+	for name := range funcs {
+		if !used[name] {
+			issues = append(issues, LintIssue{
+				Severity: LintWarning,
+				Path:     ".",
+				Message:  fmt.Sprintf("template function %q is not used by any template", name),
+			})
+		}
+	}
+	sort.Slice(issues, func(i, j int) bool { return issues[i].Message < issues[j].Message })
+	return issues, nil
+}
+
+// collectIdentifiers records the functions called in the parse tree rooted at node.
+func collectIdentifiers(node parse.Node, used map[string]bool) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, c := range n.Nodes {
+			collectIdentifiers(c, used)
+		}
+	case *parse.ActionNode:
+		collectIdentifiers(n.Pipe, used)
+	case *parse.PipeNode:
+		if n == nil {
+			return
+		}
+		for _, cmd := range n.Cmds {
+			collectIdentifiers(cmd, used)
+		}
+	case *parse.CommandNode:
+		for _, arg := range n.Args {
+			collectIdentifiers(arg, used)
+		}
+	case *parse.ChainNode:
+		collectIdentifiers(n.Node, used)
+	case *parse.IdentifierNode:
+		used[n.Ident] = true
+	case *parse.IfNode:
+		collectBranch(&n.BranchNode, used)
+	case *parse.RangeNode:
+		collectBranch(&n.BranchNode, used)
+	case *parse.WithNode:
+		collectBranch(&n.BranchNode, used)
+	case *parse.TemplateNode:
+		collectIdentifiers(n.Pipe, used)
+	}
+}
+
+func collectBranch(n *parse.BranchNode, used map[string]bool) {
+	collectIdentifiers(n.Pipe, used)
+	collectIdentifiers(n.List, used)
+	collectIdentifiers(n.ElseList, used)
+}
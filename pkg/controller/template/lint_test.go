@@ -0,0 +1,101 @@
+package template
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	configv1 "github.com/openshift/api/config/v1"
+	"github.com/openshift/library-go/pkg/cloudprovider"
+	"github.com/openshift/library-go/pkg/operator/configobserver/featuregates"
+)
+
+func lintRenderConfig(t *testing.T, config string) *RenderConfig {
+	controllerConfig, err := controllerConfigFromFile(configs[config])
+	if err != nil {
+		t.Fatalf("failed to get controllerconfig config: %v", err)
+	}
+	fgAccess := featuregates.NewHardcodedFeatureGateAccess(nil, []configv1.FeatureGateName{cloudprovider.ExternalCloudProviderFeature, cloudprovider.ExternalCloudProviderFeatureAzure, cloudprovider.ExternalCloudProviderFeatureGCP, cloudprovider.ExternalCloudProviderFeatureExternal})
+	return &RenderConfig{&controllerConfig.Spec, `{"dummy":"dummy"}`, fgAccess, nil, nil}
+}
+
+func writeTemplate(t *testing.T, dir, path, contents string) {
+	path = filepath.Join(dir, path)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(contents), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLintTemplates(t *testing.T) {
+	dir := t.TempDir()
+	// The AWS template overrides the base template, but writes somewhere else.
+	writeTemplate(t, dir, "worker/00-worker/_base/files/moved.yaml", "path: /etc/moved\ncontents:\n  inline: base\n")
+	writeTemplate(t, dir, "worker/00-worker/aws/files/moved.yaml", "path: /etc/moved-aws\ncontents:\n  inline: aws\n")
+	// Two differently named templates write the same file in different MachineConfigs.
+	writeTemplate(t, dir, "worker/00-worker/_base/files/first.yaml", "path: /etc/duplicate\ncontents:\n  inline: first\n")
+	writeTemplate(t, dir, "worker/01-worker-kubelet/_base/files/second.yaml", "path: /etc/duplicate\ncontents:\n  inline: second\n")
+	// Only renders on bare metal.
+	writeTemplate(t, dir, "worker/00-worker/_base/units/baremetal.service.yaml", `{{if eq .Infra.Status.PlatformStatus.Type "BareMetal"}}name: baremetal.service
+contents: |
+  [Unit]
+{{end}}`)
+	// Overriding a template with the same destination is how platforms are supported.
+	writeTemplate(t, dir, "worker/00-worker/_base/units/kubelet.service.yaml", "name: kubelet.service\ncontents: |\n  [Unit]\n  Description={{cloudProvider .}}\n")
+	writeTemplate(t, dir, "worker/00-worker/aws/units/kubelet.service.yaml", "name: kubelet.service\ncontents: |\n  [Unit]\n  Description=aws\n")
+
+	issues, err := LintTemplates(lintRenderConfig(t, "aws"), dir)
+	if err != nil {
+		t.Fatalf("failed to lint templates: %v", err)
+	}
+
+	var got []string
+	for _, issue := range issues {
+		// The unused function warnings are covered below.
+		if strings.Contains(issue.Message, "template function") {
+			continue
+		}
+		got = append(got, issue.String())
+	}
+	expected := []string{
+		"warning: worker/00-worker/_base/units/baremetal.service.yaml: renders to empty output and is omitted",
+		"error: worker/00-worker/aws/files/moved.yaml: overrides worker/00-worker/_base/files/moved.yaml, which writes file /etc/moved, but does not write it itself",
+		"error: worker/01-worker-kubelet/_base/files/second.yaml: writes file /etc/duplicate, which is also written by worker/00-worker/_base/files/first.yaml",
+	}
+	if !reflect.DeepEqual(expected, got) {
+		t.Errorf("unexpected lint issues:\nexpected: %q\ngot:      %q", expected, got)
+	}
+
+	var unused []string
+	for _, issue := range issues {
+		if strings.Contains(issue.Message, "template function") {
+			unused = append(unused, issue.Message)
+		}
+	}
+	if len(unused) != len(templateFuncs())-1 {
+		t.Errorf("expected all template functions but cloudProvider to be unused, got %q", unused)
+	}
+	for _, msg := range unused {
+		if strings.Contains(msg, `"cloudProvider"`) {
+			t.Errorf("cloudProvider is used, but reported as unused")
+		}
+	}
+}
+
+func TestLintTemplatesNoErrors(t *testing.T) {
+	for config := range configs {
+		issues, err := LintTemplates(lintRenderConfig(t, config), templateDir)
+		if err != nil {
+			t.Fatalf("failed to lint templates for %s: %v", config, err)
+		}
+		for _, issue := range issues {
+			if issue.Severity == LintError {
+				t.Errorf("%s: %s", config, issue)
+			}
+		}
+	}
+}
@@ -2,6 +2,7 @@ package template
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"net/url"
 	"os"
@@ -11,6 +12,10 @@ import (
 	"strings"
 	"text/template"
 
+	ign3types "github.com/coreos/ignition/v2/config/v3_4/types"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/klog/v2"
 
 	configv1 "github.com/openshift/api/config/v1"
@@ -29,11 +34,19 @@ type RenderConfig struct {
 	PullSecret        string
 	FeatureGateAccess featuregates.FeatureGateAccess
 
+	// NodePullSecretSelectors are the MachineConfig selectors of the pools
+	// which encrypt sensitive files. The 00-<role> MachineConfigs they select
+	// reference the pull secret instead of holding it, see NewNodePullSecret.
+	NodePullSecretSelectors []labels.Selector
+
 	// no need to set this, will be automatically configured
 	Constants map[string]string
 }
 
 const (
+	// nodePullSecretPath is where the pull secret of the nodes is written.
+	nodePullSecretPath = "/var/lib/kubelet/config.json"
+
 	filesDir       = "files"
 	unitsDir       = "units"
 	platformBase   = "_base"
@@ -218,7 +231,9 @@ func getPaths(config *RenderConfig, platformString string) []string {
 	return platformBasedPaths
 }
 
-func generateMachineConfigForName(config *RenderConfig, role, name, templateDir, path string, commonAdded *bool) (*mcfgv1.MachineConfig, error) {
+// platformDirsForName returns the template directories which make up the
+// MachineConfig at path, in the order in which they override each other.
+func platformDirsForName(config *RenderConfig, templateDir, path string, commonAdded *bool) ([]string, error) {
 	platformString, err := platformStringFromControllerConfigSpec(config.ControllerConfigSpec)
 	if err != nil {
 		return nil, err
@@ -254,6 +269,17 @@ func generateMachineConfigForName(config *RenderConfig, role, name, templateDir,
 		platformDirs = append(platformDirs, platformPath)
 	}
 
+	return platformDirs, nil
+}
+
+func generateMachineConfigForName(config *RenderConfig, role, name, templateDir, path string, commonAdded *bool) (*mcfgv1.MachineConfig, error) {
+	// The common templates only go into the first config of the role, 00-<role>.
+	addsCommon := !*commonAdded
+	platformDirs, err := platformDirsForName(config, templateDir, path, commonAdded)
+	if err != nil {
+		return nil, err
+	}
+
 	files := map[string]string{}
 	units := map[string]string{}
 	// walk all role dirs, with later ones taking precedence
@@ -302,6 +328,18 @@ func generateMachineConfigForName(config *RenderConfig, role, name, templateDir,
 	if err != nil {
 		return nil, fmt.Errorf("error transpiling CoreOS config to Ignition config: %w", err)
 	}
+	// The pull secret file rendered from the common templates is replaced by
+	// a reference to the Secret returned by NewNodePullSecret.
+	referencesPullSecret := addsCommon && referencesNodePullSecret(config, role)
+	if referencesPullSecret {
+		ignFiles := ignCfg.Storage.Files[:0]
+		for _, file := range ignCfg.Storage.Files {
+			if file.Path != nodePullSecretPath {
+				ignFiles = append(ignFiles, file)
+			}
+		}
+		ignCfg.Storage.Files = ignFiles
+	}
 	mcfg, err := ctrlcommon.MachineConfigFromIgnConfig(role, name, ignCfg)
 	if err != nil {
 		return nil, fmt.Errorf("error creating MachineConfig from Ignition config: %w", err)
@@ -315,25 +353,78 @@ func generateMachineConfigForName(config *RenderConfig, role, name, templateDir,
 	// And inject the osimageurl here
 	mcfg.Spec.OSImageURL = ctrlcommon.GetDefaultBaseImageContainer(config.ControllerConfigSpec)
 
+	if referencesPullSecret {
+		mcfg.Spec.FileReferences = []mcfgv1.MachineConfigFileReference{nodePullSecretFileReference()}
+	}
+
 	return mcfg, nil
 }
 
+// referencesNodePullSecret returns whether the MachineConfigs of role are
+// selected by a pool which encrypts sensitive files, and so should not hold
+// the pull secret.
+func referencesNodePullSecret(config *RenderConfig, role string) bool {
+	for _, selector := range config.NodePullSecretSelectors {
+		if selector.Matches(labels.Set{mcfgv1.MachineConfigRoleLabelKey: role}) {
+			return true
+		}
+	}
+	return false
+}
+
+// NodePullSecretSelectors returns the MachineConfig selectors of the pools
+// which encrypt sensitive files, for RenderConfig.NodePullSecretSelectors.
+func NodePullSecretSelectors(pools []*mcfgv1.MachineConfigPool) ([]labels.Selector, error) {
+	var selectors []labels.Selector
+	for _, pool := range pools {
+		if pool.Annotations[ctrlcommon.EncryptSensitiveFilesAnnotationKey] != "true" {
+			continue
+		}
+		selector, err := metav1.LabelSelectorAsSelector(pool.Spec.MachineConfigSelector)
+		if err != nil {
+			return nil, fmt.Errorf("invalid MachineConfig selector of pool %s: %w", pool.Name, err)
+		}
+		selectors = append(selectors, selector)
+	}
+	return selectors, nil
+}
+
+// nodePullSecretFileReference returns the file reference which writes the pull
+// secret of the nodes from the Secret returned by NewNodePullSecret.
+func nodePullSecretFileReference() mcfgv1.MachineConfigFileReference {
+	return mcfgv1.MachineConfigFileReference{
+		Path: nodePullSecretPath,
+		SecretKeyRef: &corev1.SecretKeySelector{
+			LocalObjectReference: corev1.LocalObjectReference{Name: ctrlcommon.NodePullSecretName},
+			Key:                  corev1.DockerConfigJsonKey,
+		},
+	}
+}
+
+// NewNodePullSecret returns the Secret in the MCO namespace which the 00-<role>
+// MachineConfigs of pools encrypting sensitive files read the pull secret of
+// the nodes from.
+func NewNodePullSecret(pullSecretRaw []byte) (*corev1.Secret, error) {
+	buf := &bytes.Buffer{}
+	if err := json.Compact(buf, pullSecretRaw); err != nil {
+		return nil, fmt.Errorf("couldn't compact pullsecret: %w", err)
+	}
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      ctrlcommon.NodePullSecretName,
+			Namespace: ctrlcommon.MCONamespace,
+		},
+		Type: corev1.SecretTypeDockerConfigJson,
+		Data: map[string][]byte{
+			corev1.DockerConfigJsonKey: append(buf.Bytes(), '\n'),
+		},
+	}, nil
+}
+
 // renderTemplate renders a template file with values from a RenderConfig
 // returns the rendered file data
 func renderTemplate(config RenderConfig, path string, b []byte) ([]byte, error) {
-	funcs := ctrlcommon.GetTemplateFuncMap()
-	funcs["skip"] = skipMissing
-	funcs["cloudProvider"] = cloudProvider
-	funcs["cloudConfigFlag"] = cloudConfigFlag
-	funcs["onPremPlatformAPIServerInternalIP"] = onPremPlatformAPIServerInternalIP
-	funcs["onPremPlatformAPIServerInternalIPs"] = onPremPlatformAPIServerInternalIPs
-	funcs["onPremPlatformIngressIP"] = onPremPlatformIngressIP
-	funcs["onPremPlatformIngressIPs"] = onPremPlatformIngressIPs
-	funcs["onPremPlatformShortName"] = onPremPlatformShortName
-	funcs["urlHost"] = urlHost
-	funcs["urlPort"] = urlPort
-	funcs["isOpenShiftManagedDefaultLB"] = isOpenShiftManagedDefaultLB
-	tmpl, err := template.New(path).Funcs(funcs).Parse(string(b))
+	tmpl, err := template.New(path).Funcs(templateFuncs()).Parse(string(b))
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse template %s: %w", path, err)
 	}
@@ -350,6 +441,23 @@ func renderTemplate(config RenderConfig, path string, b []byte) ([]byte, error)
 	return buf.Bytes(), nil
 }
 
+// templateFuncs returns the functions available to templates.
+func templateFuncs() template.FuncMap {
+	funcs := ctrlcommon.GetTemplateFuncMap()
+	funcs["skip"] = skipMissing
+	funcs["cloudProvider"] = cloudProvider
+	funcs["cloudConfigFlag"] = cloudConfigFlag
+	funcs["onPremPlatformAPIServerInternalIP"] = onPremPlatformAPIServerInternalIP
+	funcs["onPremPlatformAPIServerInternalIPs"] = onPremPlatformAPIServerInternalIPs
+	funcs["onPremPlatformIngressIP"] = onPremPlatformIngressIP
+	funcs["onPremPlatformIngressIPs"] = onPremPlatformIngressIPs
+	funcs["onPremPlatformShortName"] = onPremPlatformShortName
+	funcs["urlHost"] = urlHost
+	funcs["urlPort"] = urlPort
+	funcs["isOpenShiftManagedDefaultLB"] = isOpenShiftManagedDefaultLB
+	return funcs
+}
+
 var skipKeyValidate = regexp.MustCompile(`^[_a-z]\w*$`)
 
 // Keys labelled with skip ie. {{skip "key"}}, don't need to be templated in now because at Ignition request they will be templated in with query params
@@ -686,3 +794,75 @@ func isOpenShiftManagedDefaultLB(cfg RenderConfig) bool {
 	}
 	return false
 }
+
+// machineConfigNameForTemplate returns the name of the MachineConfig rendered
+// from the MachineConfigTemplate. The 99- prefix orders it after the
+// MachineConfigs of the MCO itself, so that it can override them.
+func machineConfigNameForTemplate(mct *mcfgv1.MachineConfigTemplate) string {
+	return fmt.Sprintf("99-%s-template", mct.Name)
+}
+
+// generateMachineConfigForTemplate renders the files and units of a MachineConfigTemplate
+// into a MachineConfig for its role. The contents are rendered with the same
+// data and functions as the templates in the templates directory.
+func generateMachineConfigForTemplate(config *RenderConfig, mct *mcfgv1.MachineConfigTemplate) (*mcfgv1.MachineConfig, error) {
+	if mct.Spec.Role == "" {
+		return nil, fmt.Errorf("role must be set")
+	}
+
+	ignCfg := ctrlcommon.NewIgnConfig()
+
+	for _, file := range mct.Spec.Files {
+		data, err := renderTemplate(*config, filepath.Join(mct.Name, filesDir, file.Path), []byte(file.Contents))
+		if err != nil {
+			return nil, err
+		}
+		// Like the templates in the templates directory, files which render to
+		// nothing are omitted.
+		if len(bytes.TrimSpace(data)) == 0 {
+			continue
+		}
+		ignFile := ctrlcommon.NewIgnFileBytesOverwriting(file.Path, data)
+		if file.Mode != nil {
+			mode := *file.Mode
+			ignFile.Mode = &mode
+		}
+		ignCfg.Storage.Files = append(ignCfg.Storage.Files, ignFile)
+	}
+
+	for _, unit := range mct.Spec.Units {
+		data, err := renderTemplate(*config, filepath.Join(mct.Name, unitsDir, unit.Name), []byte(unit.Contents))
+		if err != nil {
+			return nil, err
+		}
+		if len(bytes.TrimSpace(data)) == 0 {
+			continue
+		}
+		contents := string(data)
+		ignUnit := ign3types.Unit{
+			Name:     unit.Name,
+			Contents: &contents,
+		}
+		if unit.Enabled != nil {
+			enabled := *unit.Enabled
+			ignUnit.Enabled = &enabled
+		}
+		ignCfg.Systemd.Units = append(ignCfg.Systemd.Units, ignUnit)
+	}
+
+	if err := ctrlcommon.ValidateIgnition(ignCfg); err != nil {
+		return nil, fmt.Errorf("rendered Ignition config is invalid: %w", err)
+	}
+
+	mcfg, err := ctrlcommon.MachineConfigFromIgnConfig(mct.Spec.Role, machineConfigNameForTemplate(mct), ignCfg)
+	if err != nil {
+		return nil, fmt.Errorf("error creating MachineConfig from Ignition config: %w", err)
+	}
+	mcfg.Annotations = map[string]string{
+		ctrlcommon.GeneratedByControllerVersionAnnotationKey: version.Hash,
+	}
+	oref := metav1.NewControllerRef(mct, templateKind)
+	mcfg.SetOwnerReferences([]metav1.OwnerReference{*oref})
+
+	return mcfg, nil
+}
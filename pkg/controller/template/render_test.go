@@ -14,6 +14,7 @@ import (
 	configv1 "github.com/openshift/api/config/v1"
 	"github.com/openshift/library-go/pkg/cloudprovider"
 	"github.com/openshift/library-go/pkg/operator/configobserver/featuregates"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes/scheme"
 
 	mcfgv1 "github.com/openshift/machine-config-operator/pkg/apis/machineconfiguration.openshift.io/v1"
@@ -133,7 +134,7 @@ func TestCloudProvider(t *testing.T) {
 				fgAccess = featuregates.NewHardcodedFeatureGateAccess(nil, nil)
 			}
 
-			got, err := renderTemplate(RenderConfig{&config.Spec, `{"dummy":"dummy"}`, fgAccess, nil}, name, dummyTemplate)
+			got, err := renderTemplate(RenderConfig{&config.Spec, `{"dummy":"dummy"}`, fgAccess, nil, nil}, name, dummyTemplate)
 			if err != nil {
 				t.Fatalf("expected nil error %v", err)
 			}
@@ -286,7 +287,7 @@ func TestCloudConfigFlag(t *testing.T) {
 				fgAccess = featuregates.NewHardcodedFeatureGateAccess(nil, nil)
 			}
 
-			got, err := renderTemplate(RenderConfig{&config.Spec, `{"dummy":"dummy"}`, fgAccess, nil}, name, dummyTemplate)
+			got, err := renderTemplate(RenderConfig{&config.Spec, `{"dummy":"dummy"}`, fgAccess, nil, nil}, name, dummyTemplate)
 			if err != nil {
 				t.Fatalf("expected nil error %v", err)
 			}
@@ -382,14 +383,14 @@ func TestInvalidPlatform(t *testing.T) {
 
 	// we must treat unrecognized constants as "none"
 	controllerConfig.Spec.Infra.Status.PlatformStatus.Type = "_bad_"
-	_, err = generateTemplateMachineConfigs(&RenderConfig{&controllerConfig.Spec, `{"dummy":"dummy"}`, fgAccess, nil}, templateDir)
+	_, err = generateTemplateMachineConfigs(&RenderConfig{&controllerConfig.Spec, `{"dummy":"dummy"}`, fgAccess, nil, nil}, templateDir)
 	if err != nil {
 		t.Errorf("expect nil error, got: %v", err)
 	}
 
 	// explicitly blocked
 	controllerConfig.Spec.Infra.Status.PlatformStatus.Type = "_base"
-	_, err = generateTemplateMachineConfigs(&RenderConfig{&controllerConfig.Spec, `{"dummy":"dummy"}`, fgAccess, nil}, templateDir)
+	_, err = generateTemplateMachineConfigs(&RenderConfig{&controllerConfig.Spec, `{"dummy":"dummy"}`, fgAccess, nil, nil}, templateDir)
 	expectErr(err, "failed to create MachineConfig for role master: platform _base unsupported")
 }
 
@@ -402,7 +403,7 @@ func TestGenerateMachineConfigs(t *testing.T) {
 
 		fgAccess := featuregates.NewHardcodedFeatureGateAccess(nil, []configv1.FeatureGateName{cloudprovider.ExternalCloudProviderFeature, cloudprovider.ExternalCloudProviderFeatureAzure, cloudprovider.ExternalCloudProviderFeatureGCP, cloudprovider.ExternalCloudProviderFeatureExternal})
 
-		cfgs, err := generateTemplateMachineConfigs(&RenderConfig{&controllerConfig.Spec, `{"dummy":"dummy"}`, fgAccess, nil}, templateDir)
+		cfgs, err := generateTemplateMachineConfigs(&RenderConfig{&controllerConfig.Spec, `{"dummy":"dummy"}`, fgAccess, nil, nil}, templateDir)
 		if err != nil {
 			t.Fatalf("failed to generate machine configs: %v", err)
 		}
@@ -493,6 +494,121 @@ func TestGenerateMachineConfigs(t *testing.T) {
 	}
 }
 
+// Tests that only the 00-<role> configs selected by pools encrypting sensitive
+// files reference the pull secret instead of holding it.
+func TestGenerateMachineConfigsNodePullSecret(t *testing.T) {
+	controllerConfig, err := controllerConfigFromFile(configs["aws"])
+	if err != nil {
+		t.Fatalf("failed to get controllerconfig config: %v", err)
+	}
+	fgAccess := featuregates.NewHardcodedFeatureGateAccess(nil, nil)
+	selectors, err := NodePullSecretSelectors([]*mcfgv1.MachineConfigPool{newEncryptingPool("worker")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfgs, err := generateTemplateMachineConfigs(&RenderConfig{&controllerConfig.Spec, `{"dummy":"dummy"}`, fgAccess, selectors, nil}, templateDir)
+	if err != nil {
+		t.Fatalf("failed to generate machine configs: %v", err)
+	}
+
+	for _, cfg := range cfgs {
+		ign, err := ctrlcommon.ParseAndConvertConfig(cfg.Spec.Config.Raw)
+		if err != nil {
+			t.Fatalf("Failed to parse Ignition config for %s: %v", cfg.Name, err)
+		}
+		switch cfg.Name {
+		case "00-master":
+			if !findIgnFile(ign.Storage.Files, nodePullSecretPath, t) || findPullSecretReference(cfg) {
+				t.Errorf("expected %s to hold the pull secret", cfg.Name)
+			}
+		case "00-worker":
+			if findIgnFile(ign.Storage.Files, nodePullSecretPath, t) || !findPullSecretReference(cfg) {
+				t.Errorf("expected %s to reference the pull secret", cfg.Name)
+			}
+		default:
+			if len(cfg.Spec.FileReferences) != 0 {
+				t.Errorf("unexpected file references in %s", cfg.Name)
+			}
+		}
+	}
+}
+
+func TestGenerateMachineConfigForTemplate(t *testing.T) {
+	controllerConfig, err := controllerConfigFromFile(configs["aws"])
+	if err != nil {
+		t.Fatalf("failed to get controllerconfig config: %v", err)
+	}
+	fgAccess := featuregates.NewHardcodedFeatureGateAccess(nil, nil)
+	rc := &RenderConfig{&controllerConfig.Spec, `{"dummy":"dummy"}`, fgAccess, nil, nil}
+
+	mode := 0o600
+	enabled := true
+	mct := &mcfgv1.MachineConfigTemplate{
+		ObjectMeta: metav1.ObjectMeta{Name: "example", UID: "example"},
+		Spec: mcfgv1.MachineConfigTemplateSpec{
+			Role: "worker",
+			Files: []mcfgv1.MachineConfigTemplateFile{{
+				Path:     "/etc/example/api",
+				Mode:     &mode,
+				Contents: "{{.Infra.Status.APIServerURL}}",
+			}, {
+				Path:     "/etc/example/skipped",
+				Contents: "{{if false}}skipped{{end}}",
+			}},
+			Units: []mcfgv1.MachineConfigTemplateUnit{{
+				Name:     "example.service",
+				Enabled:  &enabled,
+				Contents: "[Service]\nEnvironment=PLATFORM={{.Infra.Status.PlatformStatus.Type}}\n",
+			}},
+		},
+	}
+
+	mc, err := generateMachineConfigForTemplate(rc, mct)
+	if err != nil {
+		t.Fatalf("failed to generate machine config: %v", err)
+	}
+	if mc.Name != "99-example-template" {
+		t.Errorf("unexpected MachineConfig name %s", mc.Name)
+	}
+	if role := mc.Labels[mcfgv1.MachineConfigRoleLabelKey]; role != "worker" {
+		t.Errorf("expected role worker, got %q", role)
+	}
+	if ref := metav1.GetControllerOf(mc); ref == nil || ref.UID != mct.UID {
+		t.Errorf("expected MachineConfig to be owned by the template, got %v", ref)
+	}
+
+	ign, err := ctrlcommon.ParseAndConvertConfig(mc.Spec.Config.Raw)
+	if err != nil {
+		t.Fatalf("failed to parse Ignition config: %v", err)
+	}
+	if len(ign.Storage.Files) != 1 {
+		t.Fatalf("expected only the non-empty file to be rendered, got %d files", len(ign.Storage.Files))
+	}
+	file := ign.Storage.Files[0]
+	contents, err := ctrlcommon.DecodeIgnitionFileContents(file.Contents.Source, file.Contents.Compression)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(contents) != controllerConfig.Spec.Infra.Status.APIServerURL {
+		t.Errorf("unexpected file contents %q", string(contents))
+	}
+	if file.Mode == nil || *file.Mode != mode {
+		t.Errorf("expected file mode %o, got %v", mode, file.Mode)
+	}
+	if len(ign.Systemd.Units) != 1 || ign.Systemd.Units[0].Enabled == nil || !*ign.Systemd.Units[0].Enabled {
+		t.Fatalf("expected an enabled unit, got %+v", ign.Systemd.Units)
+	}
+	if !strings.Contains(*ign.Systemd.Units[0].Contents, "PLATFORM=AWS") {
+		t.Errorf("unexpected unit contents %q", *ign.Systemd.Units[0].Contents)
+	}
+
+	mct.Spec.Files[0].Path = "relative/path"
+	if _, err := generateMachineConfigForTemplate(rc, mct); err == nil {
+		t.Error("expected an error for a relative file path")
+	}
+}
+
 func TestGetPaths(t *testing.T) {
 	cases := []struct {
 		platform configv1.PlatformType
@@ -529,7 +645,7 @@ func TestGetPaths(t *testing.T) {
 			}
 			c.res = append(c.res, platformBase)
 
-			got := getPaths(&RenderConfig{&config.Spec, `{"dummy":"dummy"}`, nil, nil}, config.Spec.Platform)
+			got := getPaths(&RenderConfig{&config.Spec, `{"dummy":"dummy"}`, nil, nil, nil}, config.Spec.Platform)
 			if reflect.DeepEqual(got, c.res) {
 				t.Fatalf("mismatch got: %s want: %s", got, c.res)
 			}
@@ -562,6 +678,15 @@ func findIgnFile(files []ign3types.File, path string, t *testing.T) bool {
 	return false
 }
 
+func findPullSecretReference(cfg *mcfgv1.MachineConfig) bool {
+	for _, ref := range cfg.Spec.FileReferences {
+		if ref.Path == nodePullSecretPath && ref.SecretKeyRef != nil && ref.SecretKeyRef.Name == ctrlcommon.NodePullSecretName {
+			return true
+		}
+	}
+	return false
+}
+
 func findIgnUnit(units []ign3types.Unit, name string, t *testing.T) bool {
 	for _, u := range units {
 		if u.Name == name {
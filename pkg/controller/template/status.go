@@ -108,3 +108,45 @@ func updateControllerConfigStatus(name string,
 		return err
 	})
 }
+
+// syncTemplateStatus records the outcome of rendering the MachineConfigTemplate.
+// The condition keeps its lastTransitionTime while the outcome is unchanged, so
+// that periodic resyncs do not update the template.
+func (ctrl *Controller) syncTemplateStatus(mct *mcfgv1.MachineConfigTemplate, machineConfig string, oerr error) error {
+	cond := mcfgv1.MachineConfigTemplateCondition{
+		Type:               mcfgv1.MachineConfigTemplateSuccess,
+		Status:             corev1.ConditionTrue,
+		LastTransitionTime: metav1.Now(),
+		Message:            fmt.Sprintf("Rendered MachineConfig %s", machineConfig),
+	}
+	if oerr != nil {
+		cond.Type = mcfgv1.MachineConfigTemplateFailure
+		cond.Message = fmt.Sprintf("Failed to render: %v", oerr)
+	}
+
+	return retry.RetryOnConflict(retry.DefaultBackoff, func() error {
+		old, err := ctrl.mctLister.Get(mct.Name)
+		if err != nil {
+			return err
+		}
+		newMCT := old.DeepCopy()
+		newMCT.Status.ObservedGeneration = old.GetGeneration()
+		// Keep pointing at the last successfully rendered MachineConfig on failure.
+		if oerr == nil {
+			newMCT.Status.MachineConfig = machineConfig
+		}
+		if len(old.Status.Conditions) == 1 {
+			last := old.Status.Conditions[0]
+			if last.Type == cond.Type && last.Status == cond.Status && last.Message == cond.Message {
+				cond.LastTransitionTime = last.LastTransitionTime
+			}
+		}
+		newMCT.Status.Conditions = []mcfgv1.MachineConfigTemplateCondition{cond}
+
+		if equality.Semantic.DeepEqual(old, newMCT) {
+			return nil
+		}
+		_, err = ctrl.client.MachineconfigurationV1().MachineConfigTemplates().UpdateStatus(context.TODO(), newMCT, metav1.UpdateOptions{})
+		return err
+	})
+}
@@ -28,6 +28,7 @@ import (
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	"k8s.io/apimachinery/pkg/util/wait"
 	coreinformersv1 "k8s.io/client-go/informers/core/v1"
@@ -50,6 +51,9 @@ const (
 // controllerKind contains the schema.GroupVersionKind for this controller type.
 var controllerKind = mcfgv1.SchemeGroupVersion.WithKind("ControllerConfig")
 
+// templateKind contains the schema.GroupVersionKind of the user-defined templates this controller renders.
+var templateKind = mcfgv1.SchemeGroupVersion.WithKind("MachineConfigTemplate")
+
 // Controller defines the template controller
 type Controller struct {
 	templatesDir string
@@ -61,11 +65,15 @@ type Controller struct {
 	syncHandler             func(ccKey string) error
 	enqueueControllerConfig func(*mcfgv1.ControllerConfig)
 
-	ccLister mcfglistersv1.ControllerConfigLister
-	mcLister mcfglistersv1.MachineConfigLister
+	ccLister  mcfglistersv1.ControllerConfigLister
+	mcLister  mcfglistersv1.MachineConfigLister
+	mctLister mcfglistersv1.MachineConfigTemplateLister
+	mcpLister mcfglistersv1.MachineConfigPoolLister
 
 	ccListerSynced        cache.InformerSynced
 	mcListerSynced        cache.InformerSynced
+	mctListerSynced       cache.InformerSynced
+	mcpListerSynced       cache.InformerSynced
 	secretsInformerSynced cache.InformerSynced
 
 	featureGateAccess featuregates.FeatureGateAccess
@@ -78,6 +86,8 @@ func New(
 	templatesDir string,
 	ccInformer mcfginformersv1.ControllerConfigInformer,
 	mcInformer mcfginformersv1.MachineConfigInformer,
+	mctInformer mcfginformersv1.MachineConfigTemplateInformer,
+	mcpInformer mcfginformersv1.MachineConfigPoolInformer,
 	secretsInformer coreinformersv1.SecretInformer,
 	kubeClient clientset.Interface,
 	mcfgClient mcfgclientset.Interface,
@@ -108,6 +118,18 @@ func New(
 		DeleteFunc: ctrl.deleteMachineConfig,
 	})
 
+	mctInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    ctrl.addMachineConfigTemplate,
+		UpdateFunc: ctrl.updateMachineConfigTemplate,
+		DeleteFunc: ctrl.deleteMachineConfigTemplate,
+	})
+
+	mcpInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    ctrl.addMachineConfigPool,
+		UpdateFunc: ctrl.updateMachineConfigPool,
+		DeleteFunc: ctrl.deleteMachineConfigPool,
+	})
+
 	secretsInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
 		AddFunc:    ctrl.addSecret,
 		UpdateFunc: ctrl.updateSecret,
@@ -119,8 +141,12 @@ func New(
 
 	ctrl.ccLister = ccInformer.Lister()
 	ctrl.mcLister = mcInformer.Lister()
+	ctrl.mctLister = mctInformer.Lister()
+	ctrl.mcpLister = mcpInformer.Lister()
 	ctrl.ccListerSynced = ccInformer.Informer().HasSynced
 	ctrl.mcListerSynced = mcInformer.Informer().HasSynced
+	ctrl.mctListerSynced = mctInformer.Informer().HasSynced
+	ctrl.mcpListerSynced = mcpInformer.Informer().HasSynced
 	ctrl.secretsInformerSynced = secretsInformer.Informer().HasSynced
 
 	ctrl.featureGateAccess = fgAccess
@@ -223,12 +249,87 @@ func (ctrl *Controller) deleteFeature(obj interface{}) {
 	ctrl.enqueueController()
 }
 
+func (ctrl *Controller) addMachineConfigTemplate(obj interface{}) {
+	mct := obj.(*mcfgv1.MachineConfigTemplate)
+	klog.V(4).Infof("Adding MachineConfigTemplate %s", mct.Name)
+	ctrl.enqueueController()
+}
+
+func (ctrl *Controller) updateMachineConfigTemplate(old, cur interface{}) {
+	oldMCT := old.(*mcfgv1.MachineConfigTemplate)
+	curMCT := cur.(*mcfgv1.MachineConfigTemplate)
+	// Skip our own status updates.
+	if !reflect.DeepEqual(oldMCT.Spec, curMCT.Spec) {
+		klog.V(4).Infof("Updating MachineConfigTemplate %s", curMCT.Name)
+		ctrl.enqueueController()
+	}
+}
+
+func (ctrl *Controller) deleteMachineConfigTemplate(obj interface{}) {
+	mct, ok := obj.(*mcfgv1.MachineConfigTemplate)
+	if !ok {
+		tombstone, ok := obj.(cache.DeletedFinalStateUnknown)
+		if !ok {
+			utilruntime.HandleError(fmt.Errorf("couldn't get object from tombstone %#v", obj))
+			return
+		}
+		mct, ok = tombstone.Obj.(*mcfgv1.MachineConfigTemplate)
+		if !ok {
+			utilruntime.HandleError(fmt.Errorf("tombstone contained object that is not a MachineConfigTemplate %#v", obj))
+			return
+		}
+	}
+	// The rendered MachineConfig is garbage collected through its owner reference.
+	klog.V(4).Infof("Deleting MachineConfigTemplate %s", mct.Name)
+}
+
+// Pools only matter for whether they encrypt sensitive files, which decides
+// where the 00-<role> MachineConfigs they select take the pull secret from.
+func (ctrl *Controller) addMachineConfigPool(obj interface{}) {
+	pool := obj.(*mcfgv1.MachineConfigPool)
+	if pool.Annotations[ctrlcommon.EncryptSensitiveFilesAnnotationKey] == "true" {
+		klog.V(4).Infof("Adding MachineConfigPool %s", pool.Name)
+		ctrl.enqueueController()
+	}
+}
+
+func (ctrl *Controller) updateMachineConfigPool(old, cur interface{}) {
+	oldPool := old.(*mcfgv1.MachineConfigPool)
+	curPool := cur.(*mcfgv1.MachineConfigPool)
+	// Skip the frequent status updates.
+	if oldPool.Annotations[ctrlcommon.EncryptSensitiveFilesAnnotationKey] != curPool.Annotations[ctrlcommon.EncryptSensitiveFilesAnnotationKey] ||
+		!reflect.DeepEqual(oldPool.Spec.MachineConfigSelector, curPool.Spec.MachineConfigSelector) {
+		klog.V(4).Infof("Updating MachineConfigPool %s", curPool.Name)
+		ctrl.enqueueController()
+	}
+}
+
+func (ctrl *Controller) deleteMachineConfigPool(obj interface{}) {
+	pool, ok := obj.(*mcfgv1.MachineConfigPool)
+	if !ok {
+		tombstone, ok := obj.(cache.DeletedFinalStateUnknown)
+		if !ok {
+			utilruntime.HandleError(fmt.Errorf("couldn't get object from tombstone %#v", obj))
+			return
+		}
+		pool, ok = tombstone.Obj.(*mcfgv1.MachineConfigPool)
+		if !ok {
+			utilruntime.HandleError(fmt.Errorf("tombstone contained object that is not a MachineConfigPool %#v", obj))
+			return
+		}
+	}
+	if pool.Annotations[ctrlcommon.EncryptSensitiveFilesAnnotationKey] == "true" {
+		klog.V(4).Infof("Deleting MachineConfigPool %s", pool.Name)
+		ctrl.enqueueController()
+	}
+}
+
 // Run executes the template controller
 func (ctrl *Controller) Run(workers int, stopCh <-chan struct{}) {
 	defer utilruntime.HandleCrash()
 	defer ctrl.queue.ShutDown()
 
-	if !cache.WaitForCacheSync(stopCh, ctrl.ccListerSynced, ctrl.mcListerSynced, ctrl.secretsInformerSynced) {
+	if !cache.WaitForCacheSync(stopCh, ctrl.ccListerSynced, ctrl.mcListerSynced, ctrl.mctListerSynced, ctrl.mcpListerSynced, ctrl.secretsInformerSynced) {
 		return
 	}
 
@@ -339,6 +440,19 @@ func (ctrl *Controller) deleteMachineConfig(obj interface{}) {
 }
 
 func (ctrl *Controller) resolveControllerRef(controllerRef *metav1.OwnerReference) *mcfgv1.ControllerConfig {
+	// MachineConfigs rendered from templates are synced along with the ControllerConfig.
+	if controllerRef.Kind == templateKind.Kind {
+		mct, err := ctrl.mctLister.Get(controllerRef.Name)
+		if err != nil || mct.UID != controllerRef.UID {
+			return nil
+		}
+		cfg, err := ctrl.ccLister.Get(ctrlcommon.ControllerConfigName)
+		if err != nil {
+			return nil
+		}
+		return cfg
+	}
+
 	// We can't look up by UID, so look up by Name and then verify UID.
 	// Don't even try to look up by Name if it's the wrong Kind.
 	if controllerRef.Kind != controllerKind.Kind {
@@ -566,11 +680,36 @@ func (ctrl *Controller) syncControllerConfig(key string) error {
 		pullSecretRaw = secret.Data[corev1.DockerConfigJsonKey]
 	}
 
-	mcs, err := getMachineConfigsForControllerConfig(ctrl.templatesDir, cfg, pullSecretRaw, ctrl.featureGateAccess)
+	rc, err := newRenderConfig(cfg, pullSecretRaw, ctrl.featureGateAccess)
+	if err != nil {
+		return ctrl.syncFailingStatus(cfg, err)
+	}
+	pools, err := ctrl.mcpLister.List(labels.Everything())
+	if err != nil {
+		return ctrl.syncFailingStatus(cfg, err)
+	}
+	rc.NodePullSecretSelectors, err = NodePullSecretSelectors(pools)
+	if err != nil {
+		return ctrl.syncFailingStatus(cfg, err)
+	}
+
+	mcs, err := getMachineConfigsForRenderConfig(ctrl.templatesDir, cfg, rc)
 	if err != nil {
 		return ctrl.syncFailingStatus(cfg, err)
 	}
 
+	// The Secret goes first, so that the MachineConfigs referencing it can be rendered.
+	referencesPullSecret := len(rc.NodePullSecretSelectors) > 0
+	if referencesPullSecret {
+		nodePullSecret, err := NewNodePullSecret(pullSecretRaw)
+		if err != nil {
+			return ctrl.syncFailingStatus(cfg, err)
+		}
+		if err := ctrl.syncNodePullSecret(nodePullSecret); err != nil {
+			return ctrl.syncFailingStatus(cfg, err)
+		}
+	}
+
 	for _, mc := range mcs {
 		_, updated, err := mcoResourceApply.ApplyMachineConfig(ctrl.client.MachineconfigurationV1(), mc)
 		if err != nil {
@@ -581,19 +720,141 @@ func (ctrl *Controller) syncControllerConfig(key string) error {
 		}
 	}
 
+	// Once no MachineConfig references it, no stale copy of the pull secret
+	// is left behind.
+	if !referencesPullSecret {
+		if err := ctrl.deleteNodePullSecret(); err != nil {
+			return ctrl.syncFailingStatus(cfg, err)
+		}
+	}
+
+	if err := ctrl.syncMachineConfigTemplates(rc); err != nil {
+		return ctrl.syncFailingStatus(cfg, err)
+	}
+
 	return ctrl.syncCompletedStatus(cfg)
 }
 
-func getMachineConfigsForControllerConfig(templatesDir string, config *mcfgv1.ControllerConfig, pullSecretRaw []byte, featureGateAccess featuregates.FeatureGateAccess) ([]*mcfgv1.MachineConfig, error) {
+// syncNodePullSecret creates or updates the Secret the 00-<role>
+// MachineConfigs of pools encrypting sensitive files read the pull secret of
+// the nodes from.
+func (ctrl *Controller) syncNodePullSecret(required *corev1.Secret) error {
+	secrets := ctrl.kubeClient.CoreV1().Secrets(required.Namespace)
+	existing, err := secrets.Get(context.TODO(), required.Name, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		_, err = secrets.Create(context.TODO(), required, metav1.CreateOptions{})
+		return err
+	}
+	if err != nil {
+		return err
+	}
+	if reflect.DeepEqual(existing.Data, required.Data) {
+		return nil
+	}
+
+	updated := existing.DeepCopy()
+	updated.Data = required.Data
+	_, err = secrets.Update(context.TODO(), updated, metav1.UpdateOptions{})
+	if err == nil {
+		klog.V(4).Infof("Secret %s/%s was updated", required.Namespace, required.Name)
+	}
+	return err
+}
+
+// deleteNodePullSecret deletes the Secret created by syncNodePullSecret, if any.
+func (ctrl *Controller) deleteNodePullSecret() error {
+	secrets := ctrl.kubeClient.CoreV1().Secrets(ctrlcommon.MCONamespace)
+	if _, err := secrets.Get(context.TODO(), ctrlcommon.NodePullSecretName, metav1.GetOptions{}); errors.IsNotFound(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	err := secrets.Delete(context.TODO(), ctrlcommon.NodePullSecretName, metav1.DeleteOptions{})
+	if errors.IsNotFound(err) {
+		return nil
+	}
+	if err == nil {
+		klog.Infof("Deleted Secret %s/%s, as no pool encrypts sensitive files", ctrlcommon.MCONamespace, ctrlcommon.NodePullSecretName)
+	}
+	return err
+}
+
+// syncMachineConfigTemplates renders every MachineConfigTemplate and applies
+// the resulting MachineConfigs. Templates which fail to render are reported
+// in their own status rather than failing the ControllerConfig; only errors
+// talking to the API server are returned.
+func (ctrl *Controller) syncMachineConfigTemplates(rc *RenderConfig) error {
+	mcts, err := ctrl.mctLister.List(labels.Everything())
+	if err != nil {
+		return err
+	}
+
+	for _, mct := range mcts {
+		mc, err := generateMachineConfigForTemplate(rc, mct)
+		if err == nil {
+			err = ctrl.checkTemplateMachineConfigOwner(mct, mc.Name)
+		}
+		if err != nil {
+			ctrl.eventRecorder.Eventf(mct, corev1.EventTypeWarning, "RenderFailed", "Failed to render MachineConfigTemplate %s: %v", mct.Name, err)
+			if err := ctrl.syncTemplateStatus(mct, "", err); err != nil {
+				return err
+			}
+			continue
+		}
+
+		_, updated, err := mcoResourceApply.ApplyMachineConfig(ctrl.client.MachineconfigurationV1(), mc)
+		if err != nil {
+			return err
+		}
+		if updated {
+			klog.V(4).Infof("Machineconfig %s was updated from MachineConfigTemplate %s", mc.Name, mct.Name)
+		}
+		if err := ctrl.syncTemplateStatus(mct, mc.Name, nil); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// checkTemplateMachineConfigOwner makes sure that we do not overwrite a
+// MachineConfig which was not rendered from the template.
+func (ctrl *Controller) checkTemplateMachineConfigOwner(mct *mcfgv1.MachineConfigTemplate, name string) error {
+	existing, err := ctrl.mcLister.Get(name)
+	if errors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if ref := metav1.GetControllerOf(existing); ref == nil || ref.UID != mct.UID {
+		return fmt.Errorf("MachineConfig %s already exists and is not owned by MachineConfigTemplate %s", name, mct.Name)
+	}
+	return nil
+}
+
+// newRenderConfig returns the data the templates are rendered with.
+func newRenderConfig(config *mcfgv1.ControllerConfig, pullSecretRaw []byte, featureGateAccess featuregates.FeatureGateAccess) (*RenderConfig, error) {
 	buf := &bytes.Buffer{}
 	if err := json.Compact(buf, pullSecretRaw); err != nil {
 		return nil, fmt.Errorf("couldn't compact pullsecret %q: %w", string(pullSecretRaw), err)
 	}
-	rc := &RenderConfig{
+	return &RenderConfig{
 		ControllerConfigSpec: &config.Spec,
 		PullSecret:           string(buf.Bytes()),
 		FeatureGateAccess:    featureGateAccess,
+	}, nil
+}
+
+func getMachineConfigsForControllerConfig(templatesDir string, config *mcfgv1.ControllerConfig, pullSecretRaw []byte, featureGateAccess featuregates.FeatureGateAccess) ([]*mcfgv1.MachineConfig, error) {
+	rc, err := newRenderConfig(config, pullSecretRaw, featureGateAccess)
+	if err != nil {
+		return nil, err
 	}
+	return getMachineConfigsForRenderConfig(templatesDir, config, rc)
+}
+
+func getMachineConfigsForRenderConfig(templatesDir string, config *mcfgv1.ControllerConfig, rc *RenderConfig) ([]*mcfgv1.MachineConfig, error) {
 	mcs, err := generateTemplateMachineConfigs(rc, templatesDir)
 	if err != nil {
 		return nil, err
@@ -609,6 +870,33 @@ func getMachineConfigsForControllerConfig(templatesDir string, config *mcfgv1.Co
 }
 
 // RunBootstrap runs the tempate controller in boostrap mode.
-func RunBootstrap(templatesDir string, config *mcfgv1.ControllerConfig, pullSecretRaw []byte, featureGateAccess featuregates.FeatureGateAccess) ([]*mcfgv1.MachineConfig, error) {
-	return getMachineConfigsForControllerConfig(templatesDir, config, pullSecretRaw, featureGateAccess)
+func RunBootstrap(templatesDir string, config *mcfgv1.ControllerConfig, pools []*mcfgv1.MachineConfigPool, pullSecretRaw []byte, featureGateAccess featuregates.FeatureGateAccess) ([]*mcfgv1.MachineConfig, error) {
+	rc, err := newRenderConfig(config, pullSecretRaw, featureGateAccess)
+	if err != nil {
+		return nil, err
+	}
+	rc.NodePullSecretSelectors, err = NodePullSecretSelectors(pools)
+	if err != nil {
+		return nil, err
+	}
+	return getMachineConfigsForRenderConfig(templatesDir, config, rc)
+}
+
+// RunMachineConfigTemplateBootstrap renders the given MachineConfigTemplates in bootstrap mode.
+// Unlike the controller, a template that fails to render fails the bootstrap.
+func RunMachineConfigTemplateBootstrap(mcts []*mcfgv1.MachineConfigTemplate, config *mcfgv1.ControllerConfig, pullSecretRaw []byte, featureGateAccess featuregates.FeatureGateAccess) ([]*mcfgv1.MachineConfig, error) {
+	rc, err := newRenderConfig(config, pullSecretRaw, featureGateAccess)
+	if err != nil {
+		return nil, err
+	}
+
+	var mcs []*mcfgv1.MachineConfig
+	for _, mct := range mcts {
+		mc, err := generateMachineConfigForTemplate(rc, mct)
+		if err != nil {
+			return nil, fmt.Errorf("could not render MachineConfigTemplate %q: %w", mct.Name, err)
+		}
+		mcs = append(mcs, mc)
+	}
+	return mcs, nil
 }
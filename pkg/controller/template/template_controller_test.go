@@ -17,6 +17,7 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/diff"
 	coreinformersv1 "k8s.io/client-go/informers"
 	k8sfake "k8s.io/client-go/kubernetes/fake"
@@ -42,8 +43,10 @@ type fixture struct {
 	kubeclient *k8sfake.Clientset
 	oseclient  *oseconfigfake.Clientset
 
-	ccLister []*mcfgv1.ControllerConfig
-	mcLister []*mcfgv1.MachineConfig
+	ccLister  []*mcfgv1.ControllerConfig
+	mcLister  []*mcfgv1.MachineConfig
+	mctLister []*mcfgv1.MachineConfigTemplate
+	mcpLister []*mcfgv1.MachineConfigPool
 
 	kubeactions []core.Action
 	actions     []core.Action
@@ -86,6 +89,34 @@ func newControllerConfig(name string) *mcfgv1.ControllerConfig {
 	}
 }
 
+func newMachineConfigTemplate(name, role, contents string) *mcfgv1.MachineConfigTemplate {
+	return &mcfgv1.MachineConfigTemplate{
+		TypeMeta:   metav1.TypeMeta{APIVersion: mcfgv1.SchemeGroupVersion.String(), Kind: "MachineConfigTemplate"},
+		ObjectMeta: metav1.ObjectMeta{Name: name, UID: types.UID(name), Generation: 1},
+		Spec: mcfgv1.MachineConfigTemplateSpec{
+			Role: role,
+			Files: []mcfgv1.MachineConfigTemplateFile{{
+				Path:     "/etc/" + name,
+				Contents: contents,
+			}},
+		},
+	}
+}
+
+// newEncryptingPool returns a pool of role which encrypts sensitive files.
+func newEncryptingPool(role string) *mcfgv1.MachineConfigPool {
+	return &mcfgv1.MachineConfigPool{
+		TypeMeta: metav1.TypeMeta{APIVersion: mcfgv1.SchemeGroupVersion.String()},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        role,
+			Annotations: map[string]string{ctrlcommon.EncryptSensitiveFilesAnnotationKey: "true"},
+		},
+		Spec: mcfgv1.MachineConfigPoolSpec{
+			MachineConfigSelector: metav1.AddLabelToSelector(&metav1.LabelSelector{}, mcfgv1.MachineConfigRoleLabelKey, role),
+		},
+	}
+}
+
 func newPullSecret(name string, contents []byte) *corev1.Secret {
 	return &corev1.Secret{
 		TypeMeta:   metav1.TypeMeta{APIVersion: corev1.SchemeGroupVersion.String()},
@@ -104,11 +135,15 @@ func (f *fixture) newController() *Controller {
 	cinformer := coreinformersv1.NewSharedInformerFactory(f.kubeclient, noResyncPeriodFunc())
 	i := informers.NewSharedInformerFactory(f.client, noResyncPeriodFunc())
 	c := New(templateDir,
-		i.Machineconfiguration().V1().ControllerConfigs(), i.Machineconfiguration().V1().MachineConfigs(), cinformer.Core().V1().Secrets(),
+		i.Machineconfiguration().V1().ControllerConfigs(), i.Machineconfiguration().V1().MachineConfigs(),
+		i.Machineconfiguration().V1().MachineConfigTemplates(), i.Machineconfiguration().V1().MachineConfigPools(),
+		cinformer.Core().V1().Secrets(),
 		f.kubeclient, f.client, fgAccess)
 
 	c.ccListerSynced = alwaysReady
 	c.mcListerSynced = alwaysReady
+	c.mctListerSynced = alwaysReady
+	c.mcpListerSynced = alwaysReady
 	c.eventRecorder = &record.FakeRecorder{}
 
 	stopCh := make(chan struct{})
@@ -124,6 +159,14 @@ func (f *fixture) newController() *Controller {
 		i.Machineconfiguration().V1().MachineConfigs().Informer().GetIndexer().Add(m)
 	}
 
+	for _, m := range f.mctLister {
+		i.Machineconfiguration().V1().MachineConfigTemplates().Informer().GetIndexer().Add(m)
+	}
+
+	for _, p := range f.mcpLister {
+		i.Machineconfiguration().V1().MachineConfigPools().Informer().GetIndexer().Add(p)
+	}
+
 	return c
 }
 
@@ -223,6 +266,11 @@ func checkAction(expected, actual core.Action, t *testing.T) {
 
 func filterTimeFromControllerStatus(objs ...runtime.Object) {
 	for i, o := range objs {
+		if mct, ok := o.(*mcfgv1.MachineConfigTemplate); ok {
+			for j := range mct.Status.Conditions {
+				mct.Status.Conditions[j].LastTransitionTime = metav1.Time{}
+			}
+		}
 		if _, ok := o.(*mcfgv1.ControllerConfig); ok {
 			cfg := objs[i].(*mcfgv1.ControllerConfig)
 			for j := range cfg.Status.Conditions {
@@ -242,7 +290,11 @@ func filterInformerActions(actions []core.Action) []core.Action {
 			(action.Matches("list", "controllerconfigs") ||
 				action.Matches("watch", "controllerconfigs") ||
 				action.Matches("list", "machineconfigs") ||
-				action.Matches("watch", "machineconfigs")) {
+				action.Matches("watch", "machineconfigs") ||
+				action.Matches("list", "machineconfigtemplates") ||
+				action.Matches("watch", "machineconfigtemplates") ||
+				action.Matches("list", "machineconfigpools") ||
+				action.Matches("watch", "machineconfigpools")) {
 			continue
 		}
 		ret = append(ret, action)
@@ -267,6 +319,16 @@ func (f *fixture) expectGetSecretAction(secret *corev1.Secret) {
 	f.kubeactions = append(f.kubeactions, core.NewGetAction(schema.GroupVersionResource{Resource: "secrets"}, secret.Namespace, secret.Name))
 }
 
+// expectGetNodePullSecretAction expects the controller to look for the Secret
+// the nodes read the pull secret from, which it deletes when no pool needs it.
+func (f *fixture) expectGetNodePullSecretAction() {
+	f.kubeactions = append(f.kubeactions, core.NewGetAction(schema.GroupVersionResource{Resource: "secrets"}, ctrlcommon.MCONamespace, ctrlcommon.NodePullSecretName))
+}
+
+func (f *fixture) expectUpdateMachineConfigTemplateStatus(mct *mcfgv1.MachineConfigTemplate) {
+	f.actions = append(f.actions, core.NewRootUpdateSubresourceAction(schema.GroupVersionResource{Resource: "machineconfigtemplates"}, "status", mct))
+}
+
 func (f *fixture) expectUpdateControllerConfigStatus(status *mcfgv1.ControllerConfig) {
 	f.actions = append(f.actions, core.NewRootUpdateSubresourceAction(schema.GroupVersionResource{Resource: "controllerconfigs"}, "status", status))
 }
@@ -291,6 +353,7 @@ func TestCreatesMachineConfigs(t *testing.T) {
 	rcc.Status.Conditions = []mcfgv1.ControllerConfigStatusCondition{{Type: mcfgv1.TemplateControllerRunning, Status: corev1.ConditionTrue, Message: "syncing towards (1) generation using controller version v0.0.0-was-not-built-properly"}}
 	f.expectUpdateControllerConfigStatus(rcc)
 	f.expectGetSecretAction(ps)
+	f.expectGetNodePullSecretAction()
 
 	for idx := range expMCs {
 		f.expectGetMachineConfigAction(expMCs[idx])
@@ -327,6 +390,7 @@ func TestCreatesMachineConfigsWithFeatureGate(t *testing.T) {
 	rcc.Status.Conditions = []mcfgv1.ControllerConfigStatusCondition{{Type: mcfgv1.TemplateControllerRunning, Status: corev1.ConditionTrue, Message: "syncing towards (1) generation using controller version v0.0.0-was-not-built-properly"}}
 	f.expectUpdateControllerConfigStatus(rcc)
 	f.expectGetSecretAction(ps)
+	f.expectGetNodePullSecretAction()
 
 	for idx := range expMCs {
 		f.expectGetMachineConfigAction(expMCs[idx])
@@ -368,6 +432,60 @@ func TestDoNothing(t *testing.T) {
 	rcc.Status.Conditions = []mcfgv1.ControllerConfigStatusCondition{{Type: mcfgv1.TemplateControllerRunning, Status: corev1.ConditionTrue, Message: "syncing towards (1) generation using controller version v0.0.0-was-not-built-properly"}}
 	f.expectUpdateControllerConfigStatus(rcc)
 	f.expectGetSecretAction(ps)
+	f.expectGetNodePullSecretAction()
+	for idx := range mcs {
+		f.expectGetMachineConfigAction(mcs[idx])
+	}
+	ccc := cc.DeepCopy()
+	ccc.Status.ObservedGeneration = 1
+	ccc.Status.Conditions = []mcfgv1.ControllerConfigStatusCondition{
+		{Type: mcfgv1.TemplateControllerCompleted, Status: corev1.ConditionTrue, Message: "sync completed towards (1) generation using controller version v0.0.0-was-not-built-properly"},
+		{Type: mcfgv1.TemplateControllerRunning, Status: corev1.ConditionFalse},
+		{Type: mcfgv1.TemplateControllerFailing, Status: corev1.ConditionFalse},
+	}
+	f.expectUpdateControllerConfigStatus(ccc)
+
+	f.run(getKey(cc, t))
+}
+
+// Tests that the Secret the nodes read the pull secret from follows the pull
+// secret while a pool encrypts sensitive files.
+func TestUpdateNodePullSecret(t *testing.T) {
+	f := newFixture(t)
+	cc := newControllerConfig("test-cluster")
+	ps := newPullSecret("coreos-pull-secret", []byte(`{"dummy": "dummy"}`))
+	fgAccess := featuregates.NewHardcodedFeatureGateAccess([]configv1.FeatureGateName{cloudprovider.ExternalCloudProviderFeature}, nil)
+	pool := newEncryptingPool("worker")
+
+	mcs, err := RunBootstrap(templateDir, cc, []*mcfgv1.MachineConfigPool{pool}, []byte(`{"dummy": "dummy"}`), fgAccess)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale, err := NewNodePullSecret([]byte(`{"old": "old"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	nodePullSecret, err := NewNodePullSecret(ps.Data[corev1.DockerConfigJsonKey])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f.ccLister = append(f.ccLister, cc)
+	f.objects = append(f.objects, cc)
+	f.kubeobjects = append(f.kubeobjects, ps, stale)
+	f.mcpLister = append(f.mcpLister, pool)
+	f.mcLister = append(f.mcLister, mcs...)
+	for idx := range mcs {
+		f.objects = append(f.objects, mcs[idx])
+	}
+
+	rcc := cc.DeepCopy()
+	rcc.Status.ObservedGeneration = 1
+	rcc.Status.Conditions = []mcfgv1.ControllerConfigStatusCondition{{Type: mcfgv1.TemplateControllerRunning, Status: corev1.ConditionTrue, Message: "syncing towards (1) generation using controller version v0.0.0-was-not-built-properly"}}
+	f.expectUpdateControllerConfigStatus(rcc)
+	f.expectGetSecretAction(ps)
+	f.expectGetSecretAction(nodePullSecret)
+	f.kubeactions = append(f.kubeactions, core.NewUpdateAction(schema.GroupVersionResource{Resource: "secrets"}, nodePullSecret.Namespace, nodePullSecret))
 	for idx := range mcs {
 		f.expectGetMachineConfigAction(mcs[idx])
 	}
@@ -383,6 +501,53 @@ func TestDoNothing(t *testing.T) {
 	f.run(getKey(cc, t))
 }
 
+// Tests that the Secret the nodes read the pull secret from is deleted once no
+// pool encrypts sensitive files.
+func TestDeleteNodePullSecret(t *testing.T) {
+	f := newFixture(t)
+	cc := newControllerConfig("test-cluster")
+	ps := newPullSecret("coreos-pull-secret", []byte(`{"dummy": "dummy"}`))
+	fgAccess := featuregates.NewHardcodedFeatureGateAccess([]configv1.FeatureGateName{cloudprovider.ExternalCloudProviderFeature}, nil)
+
+	mcs, err := getMachineConfigsForControllerConfig(templateDir, cc, []byte(`{"dummy": "dummy"}`), fgAccess)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nodePullSecret, err := NewNodePullSecret(ps.Data[corev1.DockerConfigJsonKey])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f.ccLister = append(f.ccLister, cc)
+	f.objects = append(f.objects, cc)
+	f.kubeobjects = append(f.kubeobjects, ps, nodePullSecret)
+	f.mcLister = append(f.mcLister, mcs...)
+	for idx := range mcs {
+		f.objects = append(f.objects, mcs[idx])
+	}
+
+	rcc := cc.DeepCopy()
+	rcc.Status.ObservedGeneration = 1
+	rcc.Status.Conditions = []mcfgv1.ControllerConfigStatusCondition{{Type: mcfgv1.TemplateControllerRunning, Status: corev1.ConditionTrue, Message: "syncing towards (1) generation using controller version v0.0.0-was-not-built-properly"}}
+	f.expectUpdateControllerConfigStatus(rcc)
+	f.expectGetSecretAction(ps)
+	for idx := range mcs {
+		f.expectGetMachineConfigAction(mcs[idx])
+	}
+	f.expectGetNodePullSecretAction()
+	f.kubeactions = append(f.kubeactions, core.NewDeleteAction(schema.GroupVersionResource{Resource: "secrets"}, nodePullSecret.Namespace, nodePullSecret.Name))
+	ccc := cc.DeepCopy()
+	ccc.Status.ObservedGeneration = 1
+	ccc.Status.Conditions = []mcfgv1.ControllerConfigStatusCondition{
+		{Type: mcfgv1.TemplateControllerCompleted, Status: corev1.ConditionTrue, Message: "sync completed towards (1) generation using controller version v0.0.0-was-not-built-properly"},
+		{Type: mcfgv1.TemplateControllerRunning, Status: corev1.ConditionFalse},
+		{Type: mcfgv1.TemplateControllerFailing, Status: corev1.ConditionFalse},
+	}
+	f.expectUpdateControllerConfigStatus(ccc)
+
+	f.run(getKey(cc, t))
+}
+
 func TestRecreateMachineConfig(t *testing.T) {
 	f := newFixture(t)
 	cc := newControllerConfig("test-cluster")
@@ -407,6 +572,7 @@ func TestRecreateMachineConfig(t *testing.T) {
 	rcc.Status.Conditions = []mcfgv1.ControllerConfigStatusCondition{{Type: mcfgv1.TemplateControllerRunning, Status: corev1.ConditionTrue, Message: "syncing towards (1) generation using controller version v0.0.0-was-not-built-properly"}}
 	f.expectUpdateControllerConfigStatus(rcc)
 	f.expectGetSecretAction(ps)
+	f.expectGetNodePullSecretAction()
 
 	for idx := range mcs {
 		f.expectGetMachineConfigAction(mcs[idx])
@@ -458,6 +624,7 @@ func TestUpdateMachineConfig(t *testing.T) {
 	rcc.Status.Conditions = []mcfgv1.ControllerConfigStatusCondition{{Type: mcfgv1.TemplateControllerRunning, Status: corev1.ConditionTrue, Message: "syncing towards (1) generation using controller version v0.0.0-was-not-built-properly"}}
 	f.expectUpdateControllerConfigStatus(rcc)
 	f.expectGetSecretAction(ps)
+	f.expectGetNodePullSecretAction()
 	for idx := range expmcs {
 		f.expectGetMachineConfigAction(expmcs[idx])
 	}
@@ -473,6 +640,112 @@ func TestUpdateMachineConfig(t *testing.T) {
 	f.run(getKey(cc, t))
 }
 
+func TestCreatesMachineConfigFromTemplate(t *testing.T) {
+	f := newFixture(t)
+	cc := newControllerConfig("test-cluster")
+	ps := newPullSecret("coreos-pull-secret", []byte(`{"dummy": "dummy"}`))
+	fgAccess := featuregates.NewHardcodedFeatureGateAccess(nil, nil)
+	mct := newMachineConfigTemplate("api-url", "worker", "{{.Infra.Status.APIServerURL}}")
+
+	f.ccLister = append(f.ccLister, cc)
+	f.mctLister = append(f.mctLister, mct)
+	f.objects = append(f.objects, cc, mct)
+	f.kubeobjects = append(f.kubeobjects, ps)
+
+	expMCs, err := getMachineConfigsForControllerConfig(templateDir, cc, []byte(`{"dummy": "dummy"}`), fgAccess)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rc, err := newRenderConfig(cc, []byte(`{"dummy": "dummy"}`), fgAccess)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expMC, err := generateMachineConfigForTemplate(rc, mct)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rcc := cc.DeepCopy()
+	rcc.Status.ObservedGeneration = 1
+	rcc.Status.Conditions = []mcfgv1.ControllerConfigStatusCondition{{Type: mcfgv1.TemplateControllerRunning, Status: corev1.ConditionTrue, Message: "syncing towards (1) generation using controller version v0.0.0-was-not-built-properly"}}
+	f.expectUpdateControllerConfigStatus(rcc)
+	f.expectGetSecretAction(ps)
+	f.expectGetNodePullSecretAction()
+	for idx := range expMCs {
+		f.expectGetMachineConfigAction(expMCs[idx])
+		f.expectCreateMachineConfigAction(expMCs[idx])
+	}
+	f.expectGetMachineConfigAction(expMC)
+	f.expectCreateMachineConfigAction(expMC)
+	smct := mct.DeepCopy()
+	smct.Status.ObservedGeneration = 1
+	smct.Status.MachineConfig = expMC.Name
+	smct.Status.Conditions = []mcfgv1.MachineConfigTemplateCondition{{Type: mcfgv1.MachineConfigTemplateSuccess, Status: corev1.ConditionTrue, Message: "Rendered MachineConfig 99-api-url-template"}}
+	f.expectUpdateMachineConfigTemplateStatus(smct)
+	ccc := cc.DeepCopy()
+	ccc.Status.ObservedGeneration = 1
+	ccc.Status.Conditions = []mcfgv1.ControllerConfigStatusCondition{
+		{Type: mcfgv1.TemplateControllerCompleted, Status: corev1.ConditionTrue, Message: "sync completed towards (1) generation using controller version v0.0.0-was-not-built-properly"},
+		{Type: mcfgv1.TemplateControllerRunning, Status: corev1.ConditionFalse},
+		{Type: mcfgv1.TemplateControllerFailing, Status: corev1.ConditionFalse},
+	}
+	f.expectUpdateControllerConfigStatus(ccc)
+
+	f.run(getKey(cc, t))
+}
+
+func TestMachineConfigTemplateRenderFailure(t *testing.T) {
+	f := newFixture(t)
+	cc := newControllerConfig("test-cluster")
+	ps := newPullSecret("coreos-pull-secret", []byte(`{"dummy": "dummy"}`))
+	fgAccess := featuregates.NewHardcodedFeatureGateAccess(nil, nil)
+	mct := newMachineConfigTemplate("broken", "worker", "{{.DoesNotExist}}")
+
+	f.ccLister = append(f.ccLister, cc)
+	f.mctLister = append(f.mctLister, mct)
+	f.objects = append(f.objects, cc, mct)
+	f.kubeobjects = append(f.kubeobjects, ps)
+
+	expMCs, err := getMachineConfigsForControllerConfig(templateDir, cc, []byte(`{"dummy": "dummy"}`), fgAccess)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rc, err := newRenderConfig(cc, []byte(`{"dummy": "dummy"}`), fgAccess)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, renderErr := generateMachineConfigForTemplate(rc, mct)
+	if renderErr == nil {
+		t.Fatal("expected rendering the template to fail")
+	}
+
+	rcc := cc.DeepCopy()
+	rcc.Status.ObservedGeneration = 1
+	rcc.Status.Conditions = []mcfgv1.ControllerConfigStatusCondition{{Type: mcfgv1.TemplateControllerRunning, Status: corev1.ConditionTrue, Message: "syncing towards (1) generation using controller version v0.0.0-was-not-built-properly"}}
+	f.expectUpdateControllerConfigStatus(rcc)
+	f.expectGetSecretAction(ps)
+	f.expectGetNodePullSecretAction()
+	for idx := range expMCs {
+		f.expectGetMachineConfigAction(expMCs[idx])
+		f.expectCreateMachineConfigAction(expMCs[idx])
+	}
+	// A broken template is reported on the template and does not fail the ControllerConfig.
+	smct := mct.DeepCopy()
+	smct.Status.ObservedGeneration = 1
+	smct.Status.Conditions = []mcfgv1.MachineConfigTemplateCondition{{Type: mcfgv1.MachineConfigTemplateFailure, Status: corev1.ConditionTrue, Message: fmt.Sprintf("Failed to render: %v", renderErr)}}
+	f.expectUpdateMachineConfigTemplateStatus(smct)
+	ccc := cc.DeepCopy()
+	ccc.Status.ObservedGeneration = 1
+	ccc.Status.Conditions = []mcfgv1.ControllerConfigStatusCondition{
+		{Type: mcfgv1.TemplateControllerCompleted, Status: corev1.ConditionTrue, Message: "sync completed towards (1) generation using controller version v0.0.0-was-not-built-properly"},
+		{Type: mcfgv1.TemplateControllerRunning, Status: corev1.ConditionFalse},
+		{Type: mcfgv1.TemplateControllerFailing, Status: corev1.ConditionFalse},
+	}
+	f.expectUpdateControllerConfigStatus(ccc)
+
+	f.run(getKey(cc, t))
+}
+
 func getKey(config *mcfgv1.ControllerConfig, t *testing.T) string {
 	key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(config)
 	if err != nil {
@@ -0,0 +1,483 @@
+package daemon
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	ign3types "github.com/coreos/ignition/v2/config/v3_4/types"
+	"github.com/pmezard/go-difflib/difflib"
+
+	mcfgv1 "github.com/openshift/machine-config-operator/pkg/apis/machineconfiguration.openshift.io/v1"
+	ctrlcommon "github.com/openshift/machine-config-operator/pkg/controller/common"
+)
+
+// DiffChange tells how a file, unit or dropin differs between two MachineConfigs.
+type DiffChange string
+
+const (
+	// DiffChangeAdded is only in the new MachineConfig.
+	DiffChangeAdded DiffChange = "added"
+	// DiffChangeRemoved is only in the old MachineConfig.
+	DiffChangeRemoved DiffChange = "removed"
+	// DiffChangeModified is in both MachineConfigs, but differs.
+	DiffChangeModified DiffChange = "modified"
+)
+
+// FileDiff describes a file that differs.
+type FileDiff struct {
+	Path   string     `json:"path"`
+	Change DiffChange `json:"change"`
+	// Mode is set to "<old> -> <new>" when the mode of a modified file changes.
+	Mode string `json:"mode,omitempty"`
+	// Diff is the unified diff of the contents, or a note why it is not shown.
+	Diff string `json:"diff,omitempty"`
+}
+
+// UnitDiff describes a systemd unit, or a dropin of one, that differs.
+type UnitDiff struct {
+	Name string `json:"name"`
+	// Dropin is the name of the dropin, if this describes a dropin of the unit.
+	Dropin string     `json:"dropin,omitempty"`
+	Change DiffChange `json:"change"`
+	// State lists changes of the enabled and masked state of a modified unit.
+	State []string `json:"state,omitempty"`
+	Diff  string   `json:"diff,omitempty"`
+}
+
+// ListDiff describes the items added to and removed from a list.
+type ListDiff struct {
+	Added   []string `json:"added,omitempty"`
+	Removed []string `json:"removed,omitempty"`
+}
+
+// ValueDiff describes a value that changed.
+type ValueDiff struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// MachineConfigDiffReport is the semantic difference between two MachineConfigs
+// as the daemon sees it when updating a node from one to the other.
+type MachineConfigDiffReport struct {
+	From            string     `json:"from"`
+	To              string     `json:"to"`
+	Files           []FileDiff `json:"files,omitempty"`
+	Units           []UnitDiff `json:"units,omitempty"`
+	KernelArguments *ListDiff  `json:"kernelArguments,omitempty"`
+	Extensions      *ListDiff  `json:"extensions,omitempty"`
+	KernelType      *ValueDiff `json:"kernelType,omitempty"`
+	OSImageURL      *ValueDiff `json:"osImageURL,omitempty"`
+	FIPS            *ValueDiff `json:"fips,omitempty"`
+	// Passwd is true when the SSH keys or password of the core user change.
+	Passwd bool `json:"passwd,omitempty"`
+	// Unreconcilable is why the daemon would refuse the update, if it would.
+	Unreconcilable string `json:"unreconcilable,omitempty"`
+	// PostConfigChangeActions are what the daemon would do after writing the
+	// new config: reboot, reload crio, restart units or nothing at all.
+	PostConfigChangeActions []string `json:"postConfigChangeActions,omitempty"`
+	// SkippedFiles lists the files whose contents are encrypted in either
+	// MachineConfig. They are left out of Files and PostConfigChangeActions.
+	SkippedFiles []string `json:"skippedFiles,omitempty"`
+}
+
+// Empty returns true if the daemon has nothing to do to go from one
+// MachineConfig to the other.
+func (r *MachineConfigDiffReport) Empty() bool {
+	return len(r.Files) == 0 && len(r.Units) == 0 && r.KernelArguments == nil && r.Extensions == nil &&
+		r.KernelType == nil && r.OSImageURL == nil && r.FIPS == nil && !r.Passwd && r.Unreconcilable == ""
+}
+
+// NewMachineConfigDiffReport compares two MachineConfigs the way the daemon
+// does, without looking at the node. The contents of the pull secret, of files
+// which are not world readable and of encrypted files are only diffed when
+// showSensitive is set. Files whose contents are still encrypted can not be
+// compared, as their key differs for every rendered MachineConfig: pass
+// decrypted MachineConfigs to diff them.
+//
+//nolint:gocyclo
+func NewMachineConfigDiffReport(oldConfig, newConfig *mcfgv1.MachineConfig, showSensitive bool) (*MachineConfigDiffReport, error) {
+	oldIgn, err := ctrlcommon.ParseAndConvertConfig(oldConfig.Spec.Config.Raw)
+	if err != nil {
+		return nil, fmt.Errorf("parsing Ignition config of %s failed with error: %w", oldConfig.Name, err)
+	}
+	newIgn, err := ctrlcommon.ParseAndConvertConfig(newConfig.Spec.Config.Raw)
+	if err != nil {
+		return nil, fmt.Errorf("parsing Ignition config of %s failed with error: %w", newConfig.Name, err)
+	}
+	skipped := sortedUnique(append(encryptedFilePaths(oldIgn), encryptedFilePaths(newIgn)...))
+	if len(skipped) > 0 {
+		if oldConfig, oldIgn, err = withoutFiles(oldConfig, oldIgn, skipped); err != nil {
+			return nil, err
+		}
+		if newConfig, newIgn, err = withoutFiles(newConfig, newIgn, skipped); err != nil {
+			return nil, err
+		}
+	}
+	mcDiff, err := compareMachineConfigs(oldConfig, newConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &MachineConfigDiffReport{
+		From:         oldConfig.Name,
+		To:           newConfig.Name,
+		Passwd:       mcDiff.passwd,
+		SkippedFiles: skipped,
+	}
+
+	encrypted := map[string]bool{}
+	for _, config := range []*mcfgv1.MachineConfig{oldConfig, newConfig} {
+		if paths := config.Annotations[ctrlcommon.EncryptedFilesAnnotationKey]; paths != "" {
+			for _, path := range strings.Split(paths, ",") {
+				encrypted[path] = true
+			}
+		}
+	}
+	files, err := diffIgnitionFiles(oldIgn.Storage.Files, newIgn.Storage.Files, func(f ign3types.File) string {
+		switch {
+		case showSensitive:
+			return ""
+		case encrypted[f.Path]:
+			return "(encrypted contents not shown)"
+		case f.Path == kubeletAuthFile || (f.Mode != nil && *f.Mode&0o004 == 0):
+			return "(sensitive contents not shown)"
+		}
+		return ""
+	})
+	if err != nil {
+		return nil, err
+	}
+	report.Files = files
+	report.Units = diffIgnitionUnits(oldIgn.Systemd.Units, newIgn.Systemd.Units)
+
+	if mcDiff.kargs {
+		report.KernelArguments = diffLists(oldConfig.Spec.KernelArguments, newConfig.Spec.KernelArguments)
+	}
+	if mcDiff.extensions {
+		report.Extensions = diffLists(oldConfig.Spec.Extensions, newConfig.Spec.Extensions)
+	}
+	if mcDiff.kernelType {
+		report.KernelType = &ValueDiff{From: canonicalizeKernelType(oldConfig.Spec.KernelType), To: canonicalizeKernelType(newConfig.Spec.KernelType)}
+	}
+	if mcDiff.osUpdate {
+		report.OSImageURL = &ValueDiff{From: oldConfig.Spec.OSImageURL, To: newConfig.Spec.OSImageURL}
+	}
+	if mcDiff.fips {
+		report.FIPS = &ValueDiff{From: fmt.Sprint(oldConfig.Spec.FIPS), To: fmt.Sprint(newConfig.Spec.FIPS)}
+	}
+
+	if err := reconcilableIgnition(oldConfig, newConfig); err != nil {
+		report.Unreconcilable = err.Error()
+		return report, nil
+	}
+	if mcDiff.fips {
+		report.Unreconcilable = "FIPS mode can not be changed on a running node"
+		return report, nil
+	}
+	if report.Empty() {
+		return report, nil
+	}
+
+	restartServices, err := getRestartServices(&oldIgn, &newIgn, newConfig)
+	if err != nil {
+		return nil, err
+	}
+	report.PostConfigChangeActions = calculatePostConfigChangeActionFromDiff(mcDiff, ctrlcommon.CalculateConfigFileDiffs(&oldIgn, &newIgn), restartServices)
+	return report, nil
+}
+
+// diffIgnitionFiles diffs files by path. hidden returns a note to show instead
+// of the diff of the contents of a file, or "" to show the diff.
+func diffIgnitionFiles(oldFiles, newFiles []ign3types.File, hidden func(ign3types.File) string) ([]FileDiff, error) {
+	var paths []string
+	oldByPath := map[string]ign3types.File{}
+	for _, f := range oldFiles {
+		oldByPath[f.Path] = f
+		paths = append(paths, f.Path)
+	}
+	newByPath := map[string]ign3types.File{}
+	for _, f := range newFiles {
+		newByPath[f.Path] = f
+		paths = append(paths, f.Path)
+	}
+
+	var diffs []FileDiff
+	for _, path := range sortedUnique(paths) {
+		oldFile, inOld := oldByPath[path]
+		newFile, inNew := newByPath[path]
+		if inOld && inNew && reflect.DeepEqual(oldFile, newFile) {
+			continue
+		}
+
+		diff := FileDiff{Path: path, Change: DiffChangeModified}
+		var oldContents, newContents []byte
+		var err error
+		if inOld {
+			if oldContents, err = ctrlcommon.DecodeIgnitionFileContents(oldFile.Contents.Source, oldFile.Contents.Compression); err != nil {
+				return nil, fmt.Errorf("could not decode old contents of %s: %w", path, err)
+			}
+		}
+		if inNew {
+			if newContents, err = ctrlcommon.DecodeIgnitionFileContents(newFile.Contents.Source, newFile.Contents.Compression); err != nil {
+				return nil, fmt.Errorf("could not decode new contents of %s: %w", path, err)
+			}
+		}
+		switch {
+		case !inOld:
+			diff.Change = DiffChangeAdded
+		case !inNew:
+			diff.Change = DiffChangeRemoved
+		default:
+			if oldMode, newMode := fileModeString(oldFile.Mode), fileModeString(newFile.Mode); oldMode != newMode {
+				diff.Mode = oldMode + " -> " + newMode
+			}
+		}
+
+		note := ""
+		if inOld {
+			note = hidden(oldFile)
+		}
+		if inNew && note == "" {
+			note = hidden(newFile)
+		}
+		switch {
+		case string(oldContents) == string(newContents):
+		case note != "":
+			diff.Diff = note
+		case !utf8.Valid(oldContents) || !utf8.Valid(newContents):
+			diff.Diff = "(binary contents differ)"
+		default:
+			if diff.Diff, err = unifiedDiff(path, string(oldContents), string(newContents)); err != nil {
+				return nil, err
+			}
+		}
+		diffs = append(diffs, diff)
+	}
+	return diffs, nil
+}
+
+func fileModeString(mode *int) string {
+	if mode == nil {
+		return "default"
+	}
+	return fmt.Sprintf("%04o", *mode)
+}
+
+// diffIgnitionUnits diffs units by name and their dropins by unit and name.
+func diffIgnitionUnits(oldUnits, newUnits []ign3types.Unit) []UnitDiff {
+	var names []string
+	oldByName := map[string]ign3types.Unit{}
+	for _, u := range oldUnits {
+		oldByName[u.Name] = u
+		names = append(names, u.Name)
+	}
+	newByName := map[string]ign3types.Unit{}
+	for _, u := range newUnits {
+		newByName[u.Name] = u
+		names = append(names, u.Name)
+	}
+
+	var diffs []UnitDiff
+	for _, name := range sortedUnique(names) {
+		oldUnit, inOld := oldByName[name]
+		newUnit, inNew := newByName[name]
+		if inOld && inNew && reflect.DeepEqual(oldUnit, newUnit) {
+			continue
+		}
+
+		diff := UnitDiff{Name: name, Change: DiffChangeModified}
+		switch {
+		case !inOld:
+			diff.Change = DiffChangeAdded
+		case !inNew:
+			diff.Change = DiffChangeRemoved
+		default:
+			if oldEnabled, newEnabled := boolPtrString(oldUnit.Enabled), boolPtrString(newUnit.Enabled); oldEnabled != newEnabled {
+				diff.State = append(diff.State, fmt.Sprintf("enabled: %s -> %s", oldEnabled, newEnabled))
+			}
+			if oldMask, newMask := boolPtrString(oldUnit.Mask), boolPtrString(newUnit.Mask); oldMask != newMask {
+				diff.State = append(diff.State, fmt.Sprintf("mask: %s -> %s", oldMask, newMask))
+			}
+		}
+		if oldContents, newContents := stringPtrValue(oldUnit.Contents), stringPtrValue(newUnit.Contents); oldContents != newContents {
+			// The error is only ever returned by the writer.
+			diff.Diff, _ = unifiedDiff(name, oldContents, newContents)
+		}
+		if diff.Change != DiffChangeModified || len(diff.State) > 0 || diff.Diff != "" {
+			diffs = append(diffs, diff)
+		}
+
+		var dropinNames []string
+		oldDropins := map[string]ign3types.Dropin{}
+		for _, d := range oldUnit.Dropins {
+			oldDropins[d.Name] = d
+			dropinNames = append(dropinNames, d.Name)
+		}
+		newDropins := map[string]ign3types.Dropin{}
+		for _, d := range newUnit.Dropins {
+			newDropins[d.Name] = d
+			dropinNames = append(dropinNames, d.Name)
+		}
+		for _, dropinName := range sortedUnique(dropinNames) {
+			oldDropin, inOld := oldDropins[dropinName]
+			newDropin, inNew := newDropins[dropinName]
+			oldContents, newContents := stringPtrValue(oldDropin.Contents), stringPtrValue(newDropin.Contents)
+			if inOld && inNew && oldContents == newContents {
+				continue
+			}
+			dropinDiff := UnitDiff{Name: name, Dropin: dropinName, Change: DiffChangeModified}
+			if !inOld {
+				dropinDiff.Change = DiffChangeAdded
+			} else if !inNew {
+				dropinDiff.Change = DiffChangeRemoved
+			}
+			dropinDiff.Diff, _ = unifiedDiff(name+".d/"+dropinName, oldContents, newContents)
+			diffs = append(diffs, dropinDiff)
+		}
+	}
+	return diffs
+}
+
+// diffLists returns the items of newList not in oldList and vice versa. A list
+// which is only reordered has neither.
+func diffLists(oldList, newList []string) *ListDiff {
+	diff := &ListDiff{}
+	for _, item := range newList {
+		if !ctrlcommon.InSlice(item, oldList) {
+			diff.Added = append(diff.Added, item)
+		}
+	}
+	for _, item := range oldList {
+		if !ctrlcommon.InSlice(item, newList) {
+			diff.Removed = append(diff.Removed, item)
+		}
+	}
+	return diff
+}
+
+func unifiedDiff(name, oldText, newText string) (string, error) {
+	diff, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        splitLines(oldText),
+		B:        splitLines(newText),
+		FromFile: "a/" + strings.TrimPrefix(name, "/"),
+		ToFile:   "b/" + strings.TrimPrefix(name, "/"),
+		Context:  3,
+	})
+	if err != nil {
+		return "", fmt.Errorf("could not diff %s: %w", name, err)
+	}
+	return diff, nil
+}
+
+// splitLines splits text into lines for difflib. Unlike difflib.SplitLines, it
+// does not add an empty line to text that ends with a newline.
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	lines := strings.SplitAfter(strings.TrimSuffix(text, "\n"), "\n")
+	lines[len(lines)-1] += "\n"
+	return lines
+}
+
+func sortedUnique(names []string) []string {
+	sort.Strings(names)
+	var unique []string
+	for i, name := range names {
+		if i == 0 || name != names[i-1] {
+			unique = append(unique, name)
+		}
+	}
+	return unique
+}
+
+func boolPtrString(b *bool) string {
+	if b == nil {
+		return "unset"
+	}
+	return fmt.Sprint(*b)
+}
+
+func stringPtrValue(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+// String renders the report for humans.
+func (r *MachineConfigDiffReport) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "MachineConfig %s -> %s\n", r.From, r.To)
+	if r.Empty() {
+		b.WriteString("no changes\n")
+	}
+	for _, path := range r.SkippedFiles {
+		fmt.Fprintf(&b, "file %s skipped (encrypted contents can not be compared)\n", path)
+	}
+	if r.Empty() {
+		return b.String()
+	}
+	if r.Unreconcilable != "" {
+		fmt.Fprintf(&b, "unreconcilable: %s\n", r.Unreconcilable)
+	} else {
+		fmt.Fprintf(&b, "post config change actions: %s\n", strings.Join(r.PostConfigChangeActions, ", "))
+	}
+	for _, value := range []struct {
+		name string
+		diff *ValueDiff
+	}{{"osImageURL", r.OSImageURL}, {"kernelType", r.KernelType}, {"fips", r.FIPS}} {
+		if value.diff != nil {
+			fmt.Fprintf(&b, "%s: %s -> %s\n", value.name, value.diff.From, value.diff.To)
+		}
+	}
+	for _, list := range []struct {
+		name string
+		diff *ListDiff
+	}{{"kernelArguments", r.KernelArguments}, {"extensions", r.Extensions}} {
+		switch {
+		case list.diff == nil:
+		case len(list.diff.Added) == 0 && len(list.diff.Removed) == 0:
+			fmt.Fprintf(&b, "%s: reordered\n", list.name)
+		default:
+			fmt.Fprintf(&b, "%s: added %v, removed %v\n", list.name, list.diff.Added, list.diff.Removed)
+		}
+	}
+	if r.Passwd {
+		b.WriteString("passwd: changed\n")
+	}
+	for _, f := range r.Files {
+		fmt.Fprintf(&b, "file %s %s", f.Path, f.Change)
+		if f.Mode != "" {
+			fmt.Fprintf(&b, " (mode %s)", f.Mode)
+		}
+		b.WriteString("\n")
+		writeDiffText(&b, f.Diff)
+	}
+	for _, u := range r.Units {
+		if u.Dropin != "" {
+			fmt.Fprintf(&b, "dropin %s/%s %s\n", u.Name, u.Dropin, u.Change)
+		} else {
+			fmt.Fprintf(&b, "unit %s %s", u.Name, u.Change)
+			if len(u.State) > 0 {
+				fmt.Fprintf(&b, " (%s)", strings.Join(u.State, ", "))
+			}
+			b.WriteString("\n")
+		}
+		writeDiffText(&b, u.Diff)
+	}
+	return b.String()
+}
+
+func writeDiffText(b *strings.Builder, diff string) {
+	if diff == "" {
+		return
+	}
+	b.WriteString(diff)
+	if !strings.HasSuffix(diff, "\n") {
+		b.WriteString("\n")
+	}
+}
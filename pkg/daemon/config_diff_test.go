@@ -0,0 +1,149 @@
+package daemon
+
+import (
+	"testing"
+
+	ign3types "github.com/coreos/ignition/v2/config/v3_4/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	mcfgv1 "github.com/openshift/machine-config-operator/pkg/apis/machineconfiguration.openshift.io/v1"
+	ctrlcommon "github.com/openshift/machine-config-operator/pkg/controller/common"
+	"github.com/openshift/machine-config-operator/test/helpers"
+)
+
+func newDiffTestMachineConfig(name string, files []ign3types.File, units []ign3types.Unit) *mcfgv1.MachineConfig {
+	ignCfg := ctrlcommon.NewIgnConfig()
+	ignCfg.Storage.Files = files
+	ignCfg.Systemd.Units = units
+	mc := helpers.CreateMachineConfigFromIgnition(ignCfg)
+	mc.Name = name
+	return mc
+}
+
+func TestNewMachineConfigDiffReport(t *testing.T) {
+	secret := ctrlcommon.NewIgnFile("/etc/token", "old-token\n")
+	secret.Mode = helpers.IntToPtr(0o600)
+	newSecret := ctrlcommon.NewIgnFile("/etc/token", "new-token\n")
+	newSecret.Mode = helpers.IntToPtr(0o600)
+	oldConfig := newDiffTestMachineConfig("rendered-worker-1", []ign3types.File{
+		ctrlcommon.NewIgnFile("/etc/motd", "hello\nworld\n"),
+		ctrlcommon.NewIgnFile("/etc/removed", "bye\n"),
+		secret,
+	}, []ign3types.Unit{
+		{Name: "kubelet.service", Contents: helpers.StrToPtr("[Service]\nExecStart=/usr/bin/kubelet\n"), Enabled: helpers.BoolToPtr(true)},
+		{Name: "crio.service", Dropins: []ign3types.Dropin{{Name: "10-mco.conf", Contents: helpers.StrToPtr("[Service]\n")}}},
+	})
+	oldConfig.Spec.KernelArguments = []string{"nosmt", "quiet"}
+	oldConfig.Spec.OSImageURL = "quay.io/os@sha256:1"
+
+	newConfig := newDiffTestMachineConfig("rendered-worker-2", []ign3types.File{
+		ctrlcommon.NewIgnFile("/etc/motd", "hello\nthere\n"),
+		ctrlcommon.NewIgnFile("/etc/added", "hi\n"),
+		newSecret,
+	}, []ign3types.Unit{
+		{Name: "kubelet.service", Contents: helpers.StrToPtr("[Service]\nExecStart=/usr/bin/kubelet\n"), Enabled: helpers.BoolToPtr(false)},
+		{Name: "crio.service", Dropins: []ign3types.Dropin{{Name: "20-mco.conf", Contents: helpers.StrToPtr("[Service]\n")}}},
+	})
+	newConfig.Spec.KernelArguments = []string{"quiet", "hugepages=4"}
+	newConfig.Spec.OSImageURL = "quay.io/os@sha256:2"
+	newConfig.Spec.KernelType = ctrlcommon.KernelTypeRealtime
+
+	report, err := NewMachineConfigDiffReport(oldConfig, newConfig, false)
+	require.NoError(t, err)
+	assert.Equal(t, []FileDiff{
+		{Path: "/etc/added", Change: DiffChangeAdded, Diff: "--- a/etc/added\n+++ b/etc/added\n@@ -0,0 +1 @@\n+hi\n"},
+		{Path: "/etc/motd", Change: DiffChangeModified, Diff: "--- a/etc/motd\n+++ b/etc/motd\n@@ -1,2 +1,2 @@\n hello\n-world\n+there\n"},
+		{Path: "/etc/removed", Change: DiffChangeRemoved, Diff: "--- a/etc/removed\n+++ b/etc/removed\n@@ -1 +0,0 @@\n-bye\n"},
+		{Path: "/etc/token", Change: DiffChangeModified, Diff: "(sensitive contents not shown)"},
+	}, report.Files)
+	assert.Equal(t, []UnitDiff{
+		{Name: "crio.service", Dropin: "10-mco.conf", Change: DiffChangeRemoved, Diff: "--- a/crio.service.d/10-mco.conf\n+++ b/crio.service.d/10-mco.conf\n@@ -1 +0,0 @@\n-[Service]\n"},
+		{Name: "crio.service", Dropin: "20-mco.conf", Change: DiffChangeAdded, Diff: "--- a/crio.service.d/20-mco.conf\n+++ b/crio.service.d/20-mco.conf\n@@ -0,0 +1 @@\n+[Service]\n"},
+		{Name: "kubelet.service", Change: DiffChangeModified, State: []string{"enabled: true -> false"}},
+	}, report.Units)
+	assert.Equal(t, &ListDiff{Added: []string{"hugepages=4"}, Removed: []string{"nosmt"}}, report.KernelArguments)
+	assert.Equal(t, &ValueDiff{From: ctrlcommon.KernelTypeDefault, To: ctrlcommon.KernelTypeRealtime}, report.KernelType)
+	assert.Equal(t, &ValueDiff{From: "quay.io/os@sha256:1", To: "quay.io/os@sha256:2"}, report.OSImageURL)
+	assert.Empty(t, report.Unreconcilable)
+	assert.Equal(t, []string{postConfigChangeActionReboot}, report.PostConfigChangeActions)
+
+	shown, err := NewMachineConfigDiffReport(oldConfig, newConfig, true)
+	require.NoError(t, err)
+	assert.Contains(t, shown.Files[3].Diff, "+new-token")
+}
+
+func TestNewMachineConfigDiffReportPostConfigChangeActions(t *testing.T) {
+	oldConfig := newDiffTestMachineConfig("rendered-worker-1", []ign3types.File{
+		ctrlcommon.NewIgnFile("/etc/containers/registries.conf", "old"),
+	}, nil)
+	newConfig := newDiffTestMachineConfig("rendered-worker-2", []ign3types.File{
+		ctrlcommon.NewIgnFile("/etc/containers/registries.conf", "new"),
+	}, nil)
+
+	report, err := NewMachineConfigDiffReport(oldConfig, newConfig, false)
+	require.NoError(t, err)
+	assert.Equal(t, []string{postConfigChangeActionReloadCrio}, report.PostConfigChangeActions)
+	assert.Contains(t, report.String(), "post config change actions: reload crio\nfile /etc/containers/registries.conf modified\n")
+
+	same, err := NewMachineConfigDiffReport(oldConfig, oldConfig, false)
+	require.NoError(t, err)
+	assert.True(t, same.Empty())
+	assert.Empty(t, same.PostConfigChangeActions)
+	assert.Equal(t, "MachineConfig rendered-worker-1 -> rendered-worker-1\nno changes\n", same.String())
+
+	appended := newConfig.DeepCopy()
+	ignCfg, err := ctrlcommon.ParseAndConvertConfig(appended.Spec.Config.Raw)
+	require.NoError(t, err)
+	ignCfg.Storage.Files[0].Append = []ign3types.Resource{{Source: helpers.StrToPtr("data:,more")}}
+	appended.Spec.Config.Raw = helpers.MarshalOrDie(ignCfg)
+	unreconcilable, err := NewMachineConfigDiffReport(oldConfig, appended, false)
+	require.NoError(t, err)
+	assert.Contains(t, unreconcilable.Unreconcilable, "append")
+	assert.Empty(t, unreconcilable.PostConfigChangeActions)
+}
+
+func TestNewMachineConfigDiffReportEncryptedFiles(t *testing.T) {
+	newEncrypted := func(name, token string) (*mcfgv1.MachineConfig, []byte) {
+		mc := newDiffTestMachineConfig(name, []ign3types.File{
+			ctrlcommon.NewIgnFile("/etc/token", token),
+			ctrlcommon.NewIgnFile("/etc/motd", "hello\n"),
+		}, nil)
+		mc.Annotations = map[string]string{ctrlcommon.EncryptedFilesAnnotationKey: "/etc/token"}
+		key := ctrlcommon.DeriveMachineConfigKey([]byte("content-encryption-key"), name)
+		require.NoError(t, ctrlcommon.EncryptMachineConfigFiles(mc, key, []string{"/etc/token"}))
+		return mc, key
+	}
+
+	// The same contents are encrypted with a different key for every rendered
+	// MachineConfig, so they can not be compared without decrypting them.
+	oldConfig, oldKey := newEncrypted("rendered-worker-1", "s3cr3t\n")
+	newConfig, newKey := newEncrypted("rendered-worker-2", "s3cr3t\n")
+	report, err := NewMachineConfigDiffReport(oldConfig, newConfig, true)
+	require.NoError(t, err)
+	assert.True(t, report.Empty())
+	assert.Equal(t, []string{"/etc/token"}, report.SkippedFiles)
+	assert.Empty(t, report.PostConfigChangeActions)
+	assert.Contains(t, report.String(), "file /etc/token skipped")
+
+	decryptedOld, err := ctrlcommon.DecryptMachineConfig(oldConfig, oldKey)
+	require.NoError(t, err)
+	decryptedNew, err := ctrlcommon.DecryptMachineConfig(newConfig, newKey)
+	require.NoError(t, err)
+	report, err = NewMachineConfigDiffReport(decryptedOld, decryptedNew, false)
+	require.NoError(t, err)
+	assert.True(t, report.Empty())
+	assert.Empty(t, report.SkippedFiles)
+
+	// Decrypted contents which differ are diffed, but only shown on request.
+	changedConfig, changedKey := newEncrypted("rendered-worker-3", "changed\n")
+	decryptedChanged, err := ctrlcommon.DecryptMachineConfig(changedConfig, changedKey)
+	require.NoError(t, err)
+	report, err = NewMachineConfigDiffReport(decryptedOld, decryptedChanged, false)
+	require.NoError(t, err)
+	assert.Equal(t, []FileDiff{{Path: "/etc/token", Change: DiffChangeModified, Diff: "(encrypted contents not shown)"}}, report.Files)
+	assert.Equal(t, []string{postConfigChangeActionReboot}, report.PostConfigChangeActions)
+	shown, err := NewMachineConfigDiffReport(decryptedOld, decryptedChanged, true)
+	require.NoError(t, err)
+	assert.Contains(t, shown.Files[0].Diff, "+changed")
+}
@@ -6,6 +6,7 @@ import (
 	"os"
 	"path/filepath"
 	"sync"
+	"time"
 
 	ign2types "github.com/coreos/ignition/config/v2_2/types"
 	ign3types "github.com/coreos/ignition/v2/config/v3_4/types"
@@ -31,6 +32,27 @@ type unitConfigDriftErr struct {
 	error
 }
 
+// Error type for rpm-ostree deployment config drifts, such as kernel
+// arguments, extensions, kernel type and OS image
+type deploymentConfigDriftErr struct {
+	error
+	Mismatches []Mismatch
+	// Warnings are only reported, they do not degrade the node.
+	Warnings []Mismatch
+}
+
+// Error type for config drifts on paths the config drift policy only warns about
+type configDriftWarning struct {
+	Mismatch
+}
+
+func (w *configDriftWarning) Error() string {
+	if w.Path == "" {
+		return fmt.Sprintf("config drift (warn only): %s", w.Message)
+	}
+	return fmt.Sprintf("config drift on %s (warn only): %s", w.Path, w.Message)
+}
+
 type ConfigDriftMonitor interface {
 	Start(ConfigDriftMonitorOpts) error
 	Done() <-chan struct{}
@@ -41,6 +63,9 @@ type ConfigDriftMonitor interface {
 type ConfigDriftMonitorOpts struct {
 	// Called whenever a config drift is detected.
 	OnDrift func(error)
+	// Called whenever a config drift is detected on a path which the config
+	// drift policy of the MachineConfig only warns about. Optional.
+	OnWarn func(error)
 	// The currently applied MachineConfig.
 	MachineConfig *mcfgv1.MachineConfig
 	// The Systemd dropin path location.
@@ -48,8 +73,18 @@ type ConfigDriftMonitorOpts struct {
 	SystemdPath string
 	// Channel to report unknown errors
 	ErrChan chan<- error
+	// Called periodically to check for config drift which cannot be watched
+	// for on the filesystem, such as kernel arguments, extensions, kernel type
+	// and OS image. Should return a deploymentConfigDriftErr on drift.
+	// Optional.
+	CheckDeployment func(*mcfgv1.MachineConfig) error
+	// How often CheckDeployment is called.
+	// Defaults to 5 minutes
+	DeploymentCheckInterval time.Duration
 }
 
+const defaultDeploymentCheckInterval = 5 * time.Minute
+
 // Holds the Config Drift Watcher and ensures we only have a single instance
 // running at a given time.
 type configDriftMonitor struct {
@@ -63,8 +98,12 @@ type configDriftWatcher struct {
 	ConfigDriftMonitorOpts
 	watcher   *fsnotify.Watcher
 	filePaths sets.Set[string]
+	policy    ctrlcommon.ConfigDriftPolicy
 	wg        sync.WaitGroup
 	stopCh    chan struct{}
+	// The last deployment drift reported, used to avoid reporting the same
+	// drift on every check.
+	lastDeploymentDrift string
 }
 
 // Holds a single Config Drift Watcher and starts / stops it as necessary while
@@ -157,6 +196,10 @@ func newConfigDriftWatcher(opts ConfigDriftMonitorOpts) (*configDriftWatcher, er
 		opts.SystemdPath = pathSystemd
 	}
 
+	if opts.DeploymentCheckInterval == 0 {
+		opts.DeploymentCheckInterval = defaultDeploymentCheckInterval
+	}
+
 	c := &configDriftWatcher{
 		ConfigDriftMonitorOpts: opts,
 		stopCh:                 make(chan struct{}),
@@ -195,6 +238,18 @@ func (c *configDriftWatcher) initialize() error {
 		return fmt.Errorf("could not get file paths from machine config: %w", err)
 	}
 
+	// There is no point in watching the paths the config drift policy ignores.
+	c.policy, err = ctrlcommon.GetConfigDriftPolicy(c.MachineConfig)
+	if err != nil {
+		return fmt.Errorf("could not get config drift policy: %w", err)
+	}
+	for filePath := range c.filePaths {
+		if c.policy.ActionForPath(filePath) == ctrlcommon.ConfigDriftActionIgnore {
+			klog.V(4).Infof("Not watching %s per config drift policy", filePath)
+			c.filePaths.Delete(filePath)
+		}
+	}
+
 	// fsnotify (presently) uses inotify instead of fanotify on Linux.
 	// See: https://github.com/fsnotify/fsnotify/issues/114
 	//
@@ -219,11 +274,24 @@ func (c *configDriftWatcher) initialize() error {
 
 // Starts the Config Drift Watcher
 func (c *configDriftWatcher) start() {
+	// Only drift against the MachineConfig being watched is of interest.
+	mcdConfigDriftDetected.Set(0)
+
 	c.wg = sync.WaitGroup{}
 	c.wg.Add(1)
 
 	go func() {
 		defer c.wg.Done()
+
+		// A nil channel blocks forever, so the deployment is never checked if
+		// no check function was provided.
+		var deploymentCheckCh <-chan time.Time
+		if c.CheckDeployment != nil {
+			ticker := time.NewTicker(c.DeploymentCheckInterval)
+			defer ticker.Stop()
+			deploymentCheckCh = ticker.C
+		}
+
 		for {
 			select {
 			case event := <-c.watcher.Events:
@@ -232,6 +300,11 @@ func (c *configDriftWatcher) start() {
 					// Send unknown file event errors to the error channel.
 					c.ErrChan <- err
 				}
+			case <-deploymentCheckCh:
+				if err := c.handleDeploymentCheck(); err != nil {
+					// Send unknown deployment check errors to the error channel.
+					c.ErrChan <- err
+				}
 			case err := <-c.watcher.Errors:
 				// Send fsnotify errors directly to the error channel.
 				c.ErrChan <- fmt.Errorf("fsnotify error: %w", err)
@@ -274,6 +347,40 @@ func (c *configDriftWatcher) handleFileEvent(event fsnotify.Event) error {
 	return fmt.Errorf("unknown config drift error: %w", err)
 }
 
+// Checks the rpm-ostree deployment for config drift and filters any config
+// drift errors to the provided callback. A drift is only reported once until
+// it changes or goes away.
+func (c *configDriftWatcher) handleDeploymentCheck() error {
+	err := c.CheckDeployment(c.MachineConfig)
+
+	if err == nil {
+		c.lastDeploymentDrift = ""
+		return nil
+	}
+
+	var dErr *deploymentConfigDriftErr
+	if errors.As(err, &dErr) {
+		if dErr.Error() != c.lastDeploymentDrift {
+			c.lastDeploymentDrift = dErr.Error()
+			for _, warning := range dErr.Warnings {
+				recordConfigDrift(warning, ctrlcommon.ConfigDriftActionWarn)
+				if c.OnWarn != nil {
+					c.OnWarn(&configDriftWarning{warning})
+				}
+			}
+			if len(dErr.Mismatches) > 0 {
+				for _, mismatch := range dErr.Mismatches {
+					recordConfigDrift(mismatch, ctrlcommon.ConfigDriftActionDegrade)
+				}
+				c.OnDrift(&configDriftErr{dErr})
+			}
+		}
+		return nil
+	}
+
+	return fmt.Errorf("unknown deployment config drift error: %w", err)
+}
+
 // Validates on disk state for potential config drift.
 func (c *configDriftWatcher) checkMachineConfigForEvent(event fsnotify.Event) error {
 	// Ignore events for files not found in the MachineConfig.
@@ -281,7 +388,27 @@ func (c *configDriftWatcher) checkMachineConfigForEvent(event fsnotify.Event) er
 		return nil
 	}
 
-	if err := validateOnDiskState(c.MachineConfig, c.SystemdPath); err != nil {
+	// Only warn about the path of this event; other drifted warn-only paths
+	// were reported when their own events fired.
+	onWarn := func(err error) {
+		var warning *configDriftWarning
+		if errors.As(err, &warning) && warning.Path == event.Name {
+			recordConfigDrift(warning.Mismatch, ctrlcommon.ConfigDriftActionWarn)
+			if c.OnWarn != nil {
+				c.OnWarn(err)
+			}
+		}
+	}
+
+	degraded, err := validateOnDiskMismatches(c.MachineConfig, c.SystemdPath, onWarn)
+	if err != nil {
+		// Only record the drift on the path of this event; drifts on other
+		// paths were recorded when their own events fired.
+		for _, mismatch := range degraded {
+			if mismatch.Path == event.Name {
+				recordConfigDrift(mismatch, ctrlcommon.ConfigDriftActionDegrade)
+			}
+		}
 		return &configDriftErr{err}
 	}
 
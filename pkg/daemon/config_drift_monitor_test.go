@@ -1,6 +1,7 @@
 package daemon
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"os"
@@ -11,6 +12,7 @@ import (
 
 	ign3types "github.com/coreos/ignition/v2/config/v3_4/types"
 	mcfgv1 "github.com/openshift/machine-config-operator/pkg/apis/machineconfiguration.openshift.io/v1"
+	ctrlcommon "github.com/openshift/machine-config-operator/pkg/controller/common"
 	"github.com/openshift/machine-config-operator/test/helpers"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -197,6 +199,41 @@ func TestConfigDriftMonitor(t *testing.T) {
 			expectedErr:  unitErr,
 			mutateDropin: chmodFile,
 		},
+		// Config drift policy tests
+		// These set a config drift policy on the MachineConfig and mutate the
+		// file or dropin it targets.
+		{
+			name:        "ign file content drift ignored by policy",
+			mutateFile:  changeFileContent,
+			driftPolicy: ctrlcommon.ConfigDriftPolicy{{Path: "/etc/a-config-*", Action: ctrlcommon.ConfigDriftActionIgnore}},
+		},
+		{
+			name:        "ign file content drift warned by policy",
+			expectWarn:  true,
+			mutateFile:  changeFileContent,
+			driftPolicy: ctrlcommon.ConfigDriftPolicy{{Path: "/etc/a-config-file", Action: ctrlcommon.ConfigDriftActionWarn}},
+		},
+		{
+			name:        "ign file content drift degraded by policy",
+			expectedErr: fileErr,
+			mutateFile:  changeFileContent,
+			driftPolicy: ctrlcommon.ConfigDriftPolicy{
+				{Path: "/etc/a-config-file", Action: ctrlcommon.ConfigDriftActionDegrade},
+				{Path: "/etc/*", Action: ctrlcommon.ConfigDriftActionIgnore},
+			},
+		},
+		{
+			name:        "ign file content drift not matched by policy",
+			expectedErr: fileErr,
+			mutateFile:  changeFileContent,
+			driftPolicy: ctrlcommon.ConfigDriftPolicy{{Path: "/etc/a-compressed-file", Action: ctrlcommon.ConfigDriftActionIgnore}},
+		},
+		{
+			name:         "ign dropin content drift warned by policy",
+			expectWarn:   true,
+			mutateDropin: changeFileContent,
+			driftPolicy:  ctrlcommon.ConfigDriftPolicy{{Path: filepath.Join(pathSystemd, "unittest.service.d", "*"), Action: ctrlcommon.ConfigDriftActionWarn}},
+		},
 	}
 
 	// Create a mutex for our test cases The mutex is needed because we now
@@ -232,6 +269,10 @@ type configDriftMonitorTestCase struct {
 	name string
 	// The expected error, if any
 	expectedErr error
+	// Whether a config drift warning is expected
+	expectWarn bool
+	// The config drift policy, with paths relative to the tmpdir
+	driftPolicy ctrlcommon.ConfigDriftPolicy
 	// The tmpdir for the test case (assigned at runtime)
 	tmpDir string
 	// The systemdroot for the test case (assigned at runtime)
@@ -295,6 +336,7 @@ func (tc configDriftMonitorTestCase) run(t *testing.T) {
 	}()
 
 	onDriftCalled := false
+	onWarnCalled := false
 
 	// To listen on when
 	onDriftChan := make(chan struct{})
@@ -311,6 +353,14 @@ func (tc configDriftMonitorTestCase) run(t *testing.T) {
 			onDriftCalled = true
 			tc.onDriftFunc(t, err)
 		},
+		OnWarn: func(err error) {
+			go func() {
+				onDriftChan <- struct{}{}
+			}()
+			onWarnCalled = true
+			var warning *configDriftWarning
+			assert.ErrorAs(t, err, &warning)
+		},
 	}
 
 	// Start the config drift monitor
@@ -332,7 +382,7 @@ func (tc configDriftMonitorTestCase) run(t *testing.T) {
 	case <-onDriftChan:
 		t.Logf("Took %v to fire", time.Since(start))
 	case <-time.After(timeout):
-		if tc.expectedErr != nil {
+		if tc.expectedErr != nil || tc.expectWarn {
 			t.Errorf("expected onDrift to be called, but timed out after: %v", timeout)
 		}
 	}
@@ -360,6 +410,8 @@ func (tc configDriftMonitorTestCase) run(t *testing.T) {
 	} else {
 		assert.True(t, onDriftCalled, "expected onDrift to be called")
 	}
+
+	assert.Equal(t, tc.expectWarn, onWarnCalled, "expected onWarn to be called: %v", tc.expectWarn)
 }
 
 // Permissions in CI are a bit more complicated than they are on an end-user
@@ -461,6 +513,16 @@ func (tc configDriftMonitorTestCase) getFixtures(t *testing.T) (ign3types.Config
 	mc := helpers.CreateMachineConfigFromIgnition(ignConfig)
 	mc.Name = "config-drift-monitor" + string(uuid.NewUUID())
 
+	if len(tc.driftPolicy) > 0 {
+		policy := ctrlcommon.ConfigDriftPolicy{}
+		for _, rule := range tc.driftPolicy {
+			policy = append(policy, ctrlcommon.ConfigDriftPolicyRule{Path: filepath.Join(tc.tmpDir, rule.Path), Action: rule.Action})
+		}
+		out, err := json.Marshal(policy)
+		require.NoError(t, err)
+		mc.Annotations = map[string]string{ctrlcommon.ConfigDriftPolicyAnnotationKey: string(out)}
+	}
+
 	return ignConfig, mc
 }
 
@@ -527,3 +589,87 @@ func (tc configDriftMonitorTestCase) onDriftFunc(t *testing.T, err error) {
 		assert.ErrorAs(t, err, &uErr)
 	}
 }
+
+func TestConfigDriftMonitorDeploymentCheck(t *testing.T) {
+	mc := helpers.CreateMachineConfigFromIgnition(ctrlcommon.NewIgnConfig())
+
+	var mu sync.Mutex
+	checkErr := error(&deploymentConfigDriftErr{
+		error:      fmt.Errorf("missing expected kernel arguments: [foo=bar]"),
+		Mismatches: []Mismatch{{Type: MismatchTypeKernelArguments, Message: "missing expected kernel arguments: [foo=bar]"}},
+	})
+	setCheckErr := func(err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		checkErr = err
+	}
+
+	drifts := make(chan error, 10)
+	warnings := make(chan error, 10)
+	errChan := make(chan error, 10)
+
+	cdm := NewConfigDriftMonitor()
+	go func() {
+		<-cdm.Done()
+	}()
+
+	require.NoError(t, cdm.Start(ConfigDriftMonitorOpts{
+		ErrChan:       errChan,
+		SystemdPath:   t.TempDir(),
+		MachineConfig: mc,
+		OnDrift: func(err error) {
+			drifts <- err
+		},
+		OnWarn: func(err error) {
+			warnings <- err
+		},
+		CheckDeployment: func(*mcfgv1.MachineConfig) error {
+			mu.Lock()
+			defer mu.Unlock()
+			return checkErr
+		},
+		DeploymentCheckInterval: 5 * time.Millisecond,
+	}))
+	defer cdm.Stop()
+
+	waitFor := func(ch <-chan error) error {
+		select {
+		case err := <-ch:
+			return err
+		case <-time.After(time.Second):
+			t.Fatalf("timed out waiting for the deployment check")
+			return nil
+		}
+	}
+
+	// The drift is reported once, even though it is checked repeatedly.
+	err := waitFor(drifts)
+	var cdErr *configDriftErr
+	require.ErrorAs(t, err, &cdErr)
+	assert.IsType(t, &deploymentConfigDriftErr{}, cdErr.error)
+
+	time.Sleep(50 * time.Millisecond)
+	assert.Len(t, drifts, 0)
+
+	// A different drift is reported again.
+	setCheckErr(&deploymentConfigDriftErr{
+		error:      fmt.Errorf("extensions not installed in deployment: [usbguard]"),
+		Mismatches: []Mismatch{{Type: MismatchTypeExtensions, Message: "extensions not installed in deployment: [usbguard]"}},
+	})
+	assert.ErrorContains(t, waitFor(drifts), "usbguard")
+
+	// Warnings alone do not degrade the node.
+	setCheckErr(&deploymentConfigDriftErr{
+		error:    fmt.Errorf("packages not requested by any extension are layered in deployment: [htop]"),
+		Warnings: []Mismatch{{Type: MismatchTypePackages, Message: "packages not requested by any extension are layered in deployment: [htop]"}},
+	})
+	var warning *configDriftWarning
+	require.ErrorAs(t, waitFor(warnings), &warning)
+	assert.Equal(t, MismatchTypePackages, warning.Type)
+	assert.Len(t, drifts, 0)
+
+	// Errors which are not config drifts go to the error channel.
+	setCheckErr(fmt.Errorf("rpm-ostree is not running"))
+	assert.ErrorContains(t, waitFor(errChan), "rpm-ostree is not running")
+	assert.Len(t, drifts, 0)
+}
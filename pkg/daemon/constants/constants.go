@@ -23,6 +23,9 @@ const (
 	DesiredDrainerAnnotationKey = "machineconfiguration.openshift.io/desiredDrain"
 	// LastAppliedDrainerAnnotationKey is set by the controller to indicate the last request applied
 	LastAppliedDrainerAnnotationKey = "machineconfiguration.openshift.io/lastAppliedDrain"
+	// NodeUpdatePhasesAnnotationKey is set by the MCD to record the phases of the update to the desired config,
+	// and when it entered each of them. The value is a JSON encoded NodeUpdateTimeline.
+	NodeUpdatePhasesAnnotationKey = "machineconfiguration.openshift.io/updatePhases"
 	// DrainerStateDrain is used for drainer annotation as a value to indicate needing a drain
 	DrainerStateDrain = "drain"
 	// DrainerStateUncordon is used for drainer annotation as a value to indicate needing an uncordon
@@ -56,6 +59,17 @@ const (
 	InitialNodeAnnotationsFilePath = "/etc/machine-config-daemon/node-annotations.json"
 	// InitialNodeAnnotationsBakPath defines the path of InitialNodeAnnotationsFilePath when the initial bootstrap is done. We leave it around for debugging and reconciling.
 	InitialNodeAnnotationsBakPath = "/etc/machine-config-daemon/node-annotation.json.bak"
+	// ContentEncryptionPublicKeyAnnotationKey is set by the daemon to the base64 encoded X25519 public key of the node,
+	// which the controller wraps the keys of rendered MachineConfigs with encrypted file contents for.
+	ContentEncryptionPublicKeyAnnotationKey = "machineconfiguration.openshift.io/contentEncryptionPublicKey"
+	// ContentEncryptionKeysAnnotationKey is set by the controller to the keys of the node's current and desired
+	// rendered MachineConfigs, wrapped for the node's public key. The value is JSON encoded ContentEncryptionKeys.
+	ContentEncryptionKeysAnnotationKey = "machineconfiguration.openshift.io/contentEncryptionKeys"
+	// ContentEncryptionPrivateKeyPath is where the daemon keeps the node's private key, see ContentEncryptionPublicKeyAnnotationKey.
+	ContentEncryptionPrivateKeyPath = "/etc/machine-config-daemon/content-encryption.key"
+	// BaseKernelArgumentsFilePath is where the daemon records the kernel arguments of the node which are not set by
+	// MachineConfigs, so that kernel arguments added by hand can be told apart from them.
+	BaseKernelArgumentsFilePath = "/etc/machine-config-daemon/base-kernel-arguments.json"
 
 	// IgnitionSystemdPresetFile is where Ignition writes initial enabled/disabled systemd unit configs
 	// This should be removed on boot after MCO takes over, so if any of these are deleted we can go back
@@ -0,0 +1,139 @@
+package daemon
+
+import (
+	"crypto/ecdh"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	ign3types "github.com/coreos/ignition/v2/config/v3_4/types"
+	mcfgv1 "github.com/openshift/machine-config-operator/pkg/apis/machineconfiguration.openshift.io/v1"
+	ctrlcommon "github.com/openshift/machine-config-operator/pkg/controller/common"
+	"github.com/openshift/machine-config-operator/pkg/daemon/constants"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+)
+
+// initializeContentEncryptionKey loads the key pair of the node, generating it
+// the first time, and publishes the public key for the node controller to wrap
+// the keys of rendered configs with encrypted file contents for. The private
+// key never leaves the node.
+func (dn *Daemon) initializeContentEncryptionKey() error {
+	key, err := loadOrGenerateContentEncryptionKey(constants.ContentEncryptionPrivateKeyPath)
+	if err != nil {
+		return fmt.Errorf("could not initialize content encryption key: %w", err)
+	}
+	dn.contentEncryptionKey = key
+
+	publicKey := ctrlcommon.EncodeContentEncryptionPublicKey(key.PublicKey())
+	if dn.node.Annotations[constants.ContentEncryptionPublicKeyAnnotationKey] == publicKey {
+		return nil
+	}
+	if _, err := dn.nodeWriter.SetAnnotations(map[string]string{constants.ContentEncryptionPublicKeyAnnotationKey: publicKey}); err != nil {
+		return fmt.Errorf("could not publish content encryption public key: %w", err)
+	}
+	klog.Infof("Published content encryption public key of node %s", dn.name)
+	return nil
+}
+
+func loadOrGenerateContentEncryptionKey(path string) (*ecdh.PrivateKey, error) {
+	key, err := LoadContentEncryptionKey(path)
+	if err == nil {
+		return key, nil
+	}
+	if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	key, err = ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	if err := writeFileAtomically(path, key.Bytes(), defaultDirectoryPermissions, 0o600, -1, -1); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// getMachineConfig returns the MachineConfig called name, with the file
+// contents decrypted if they are encrypted. The key is unwrapped from the node
+// annotation set by the node controller; until it is there an error is
+// returned, and the node is synced again once the annotation changes.
+func (dn *Daemon) getMachineConfig(name string) (*mcfgv1.MachineConfig, error) {
+	mc, err := dn.mcLister.Get(name)
+	if err != nil || !ctrlcommon.IsMachineConfigEncrypted(mc) {
+		return mc, err
+	}
+
+	if dn.contentEncryptionKey == nil {
+		return nil, fmt.Errorf("MachineConfig %s has encrypted file contents, but the node has no content encryption key", name)
+	}
+	return DecryptMachineConfigForNode(mc, dn.node, dn.contentEncryptionKey)
+}
+
+// LoadContentEncryptionKey reads the private key of the node from path.
+func LoadContentEncryptionKey(path string) (*ecdh.PrivateKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ecdh.X25519().NewPrivateKey(data)
+}
+
+// DecryptMachineConfigForNode decrypts the file contents of mc with its key,
+// unwrapped with the private key of the node from the node annotation set by
+// the node controller.
+func DecryptMachineConfigForNode(mc *mcfgv1.MachineConfig, node *corev1.Node, privateKey *ecdh.PrivateKey) (*mcfgv1.MachineConfig, error) {
+	var keys ctrlcommon.ContentEncryptionKeys
+	if value, ok := node.Annotations[constants.ContentEncryptionKeysAnnotationKey]; ok {
+		if err := json.Unmarshal([]byte(value), &keys); err != nil {
+			return nil, fmt.Errorf("could not parse %s annotation: %w", constants.ContentEncryptionKeysAnnotationKey, err)
+		}
+	}
+	wrapped, ok := keys.Keys[mc.Name]
+	if !ok || keys.PublicKey != ctrlcommon.EncodeContentEncryptionPublicKey(privateKey.PublicKey()) {
+		return nil, fmt.Errorf("the key of MachineConfig %s has not been handed out to the node yet", mc.Name)
+	}
+	key, err := ctrlcommon.UnwrapMachineConfigKey(privateKey, wrapped)
+	if err != nil {
+		return nil, fmt.Errorf("could not unwrap the key of MachineConfig %s: %w", mc.Name, err)
+	}
+	return ctrlcommon.DecryptMachineConfig(mc, key)
+}
+
+// encryptedFilePaths returns the paths of the files whose contents are still
+// encrypted.
+func encryptedFilePaths(ignConfig ign3types.Config) []string {
+	paths := []string{}
+	for _, file := range ignConfig.Storage.Files {
+		if file.Contents.Source != nil && ctrlcommon.IsEncryptedFileSource(*file.Contents.Source) {
+			paths = append(paths, file.Path)
+		}
+	}
+	return paths
+}
+
+// withoutFiles returns copies of mc and its Ignition config without the files
+// at the given paths.
+func withoutFiles(mc *mcfgv1.MachineConfig, ignConfig ign3types.Config, paths []string) (*mcfgv1.MachineConfig, ign3types.Config, error) {
+	skip := map[string]bool{}
+	for _, path := range paths {
+		skip[path] = true
+	}
+	files := []ign3types.File{}
+	for _, file := range ignConfig.Storage.Files {
+		if !skip[file.Path] {
+			files = append(files, file)
+		}
+	}
+	ignConfig.Storage.Files = files
+
+	raw, err := json.Marshal(ignConfig)
+	if err != nil {
+		return nil, ignConfig, err
+	}
+	out := mc.DeepCopy()
+	out.Spec.Config.Raw = raw
+	return out, ignConfig, nil
+}
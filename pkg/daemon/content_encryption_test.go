@@ -0,0 +1,62 @@
+package daemon
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"testing"
+
+	ign3types "github.com/coreos/ignition/v2/config/v3_4/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+
+	ctrlcommon "github.com/openshift/machine-config-operator/pkg/controller/common"
+	"github.com/openshift/machine-config-operator/pkg/daemon/constants"
+	mcfglistersv1 "github.com/openshift/machine-config-operator/pkg/generated/listers/machineconfiguration.openshift.io/v1"
+	"github.com/openshift/machine-config-operator/test/helpers"
+)
+
+func TestGetMachineConfigDecrypts(t *testing.T) {
+	keyPath := filepath.Join(t.TempDir(), "content-encryption.key")
+	privateKey, err := loadOrGenerateContentEncryptionKey(keyPath)
+	require.NoError(t, err)
+	reloaded, err := loadOrGenerateContentEncryptionKey(keyPath)
+	require.NoError(t, err)
+	assert.True(t, privateKey.Equal(reloaded))
+
+	key := ctrlcommon.DeriveMachineConfigKey([]byte("content-encryption-key"), "rendered-worker-1")
+	mc := helpers.NewMachineConfig("rendered-worker-1", nil, "", []ign3types.File{
+		ctrlcommon.NewIgnFile("/var/lib/kubelet/config.json", "pull-secret"),
+	})
+	mc.Annotations = map[string]string{ctrlcommon.EncryptedFilesAnnotationKey: "/var/lib/kubelet/config.json"}
+	require.NoError(t, ctrlcommon.EncryptMachineConfigFiles(mc, key, []string{"/var/lib/kubelet/config.json"}))
+
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	require.NoError(t, indexer.Add(mc))
+	dn := &Daemon{
+		mcLister:             mcfglistersv1.NewMachineConfigLister(indexer),
+		contentEncryptionKey: privateKey,
+		node:                 &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-0", Annotations: map[string]string{}}},
+	}
+
+	// Without a key handed out the config can not be used yet.
+	_, err = dn.getMachineConfig("rendered-worker-1")
+	assert.Error(t, err)
+
+	publicKey := ctrlcommon.EncodeContentEncryptionPublicKey(privateKey.PublicKey())
+	wrapped, err := ctrlcommon.WrapMachineConfigKey(publicKey, key)
+	require.NoError(t, err)
+	keys, err := json.Marshal(ctrlcommon.ContentEncryptionKeys{PublicKey: publicKey, Keys: map[string]string{"rendered-worker-1": wrapped}})
+	require.NoError(t, err)
+	dn.node.Annotations[constants.ContentEncryptionKeysAnnotationKey] = string(keys)
+
+	decrypted, err := dn.getMachineConfig("rendered-worker-1")
+	require.NoError(t, err)
+	ignCfg, err := ctrlcommon.ParseAndConvertConfig(decrypted.Spec.Config.Raw)
+	require.NoError(t, err)
+	contents, err := ctrlcommon.GetIgnitionFileDataByPath(&ignCfg, "/var/lib/kubelet/config.json")
+	require.NoError(t, err)
+	assert.Equal(t, "pull-secret", string(contents))
+}
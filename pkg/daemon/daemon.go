@@ -3,6 +3,7 @@ package daemon
 import (
 	"bufio"
 	"context"
+	"crypto/ecdh"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -87,6 +88,8 @@ type Daemon struct {
 
 	// skipReboot skips the reboot after a sync, only valid with onceFrom != ""
 	skipReboot bool
+	// rebootSkipped is true when a reboot was required but skipped due to skipReboot
+	rebootSkipped bool
 
 	kubeletHealthzEnabled  bool
 	kubeletHealthzEndpoint string
@@ -130,6 +133,10 @@ type Daemon struct {
 
 	// Used for Hypershift
 	hypershiftConfigMap string
+
+	// contentEncryptionKey is the private key of the node that the keys of
+	// rendered configs with encrypted file contents are wrapped for.
+	contentEncryptionKey *ecdh.PrivateKey
 }
 
 // CoreOSDaemon protects the methods that should only be called on CoreOS variants
@@ -595,6 +602,9 @@ func (dn *Daemon) initializeNode() error {
 	} else {
 		klog.Infof("Node %s is not labeled %s", dn.node.Name, ctrlcommon.MasterLabel)
 	}
+	if err := dn.initializeContentEncryptionKey(); err != nil {
+		return err
+	}
 	dn.nodeInitialized = true
 	return nil
 }
@@ -916,7 +926,11 @@ func (dn *Daemon) syncNodeHypershift(key string) error {
 		return fmt.Errorf("parsing new Ignition config failed: %w", err)
 	}
 	diffFileSet := ctrlcommon.CalculateConfigFileDiffs(&oldIgnConfig, &newIgnConfig)
-	actions, err := calculatePostConfigChangeAction(mcDiff, diffFileSet)
+	restartServices, err := getRestartServices(&oldIgnConfig, &newIgnConfig, &desiredConfig)
+	if err != nil {
+		return err
+	}
+	actions, err := calculatePostConfigChangeAction(mcDiff, diffFileSet, restartServices)
 	if err != nil {
 		return err
 	}
@@ -992,9 +1006,25 @@ func (dn *Daemon) syncNodeHypershift(key string) error {
 }
 
 // RunOnceFrom is the primary entrypoint for the non-cluster case
-func (dn *Daemon) RunOnceFrom(onceFrom string, skipReboot bool) error {
-	dn.skipReboot = skipReboot
-	configi, contentFrom, err := dn.senseAndLoadOnceFrom(onceFrom)
+func (dn *Daemon) RunOnceFrom(onceFrom string, opts OnceFromOptions) (retErr error) {
+	dn.skipReboot = opts.SkipReboot
+	report := &OnceFromReport{Source: onceFrom}
+	if opts.ReportPath != "" {
+		defer func() {
+			if retErr != nil {
+				report.Error = retErr.Error()
+			}
+			report.RebootPending = dn.rebootSkipped || dn.rebootQueued
+			if err := writeOnceFromReport(opts.ReportPath, report); err != nil {
+				if retErr == nil {
+					retErr = err
+					return
+				}
+				klog.Errorf("%v", err)
+			}
+		}()
+	}
+	configi, contentFrom, err := dn.senseAndLoadOnceFrom(onceFrom, opts, report)
 	if err != nil {
 		klog.Warningf("Unable to decipher onceFrom config type: %s", err)
 		return err
@@ -1002,9 +1032,14 @@ func (dn *Daemon) RunOnceFrom(onceFrom string, skipReboot bool) error {
 	switch c := configi.(type) {
 	case ign3types.Config:
 		klog.V(2).Info("Daemon running directly from Ignition")
+		report.ConfigType = "Ignition"
+		report.Applied = appliedContentFromIgnition(c)
+		report.Skipped = ignitionSectionsSkippedByOnceFrom(c)
 		return dn.runOnceFromIgnition(c)
 	case mcfgv1.MachineConfig:
 		klog.V(2).Info("Daemon running directly from MachineConfig")
+		report.ConfigType = "MachineConfig"
+		fillOnceFromReportForMachineConfig(&c, report)
 		return dn.runOnceFromMachineConfig(c, contentFrom)
 	}
 	return fmt.Errorf("unsupported onceFrom type provided")
@@ -1165,6 +1200,15 @@ func (dn *Daemon) onConfigDrift(err error) {
 	}
 }
 
+// Called whenever the on-disk config has drifted on a path which the config
+// drift policy of the current machineconfig only warns about.
+func (dn *Daemon) onConfigDriftWarning(err error) {
+	if dn.nodeWriter != nil {
+		dn.nodeWriter.Eventf(corev1.EventTypeWarning, "ConfigDriftWarning", err.Error())
+	}
+	klog.Warning(err)
+}
+
 func (dn *Daemon) startConfigDriftMonitor() {
 	// Even though the Config Drift Monitor object ensures that only a single
 	// Config Drift Watcher is running at any given time, other things, such as
@@ -1183,11 +1227,19 @@ func (dn *Daemon) startConfigDriftMonitor() {
 
 	opts := ConfigDriftMonitorOpts{
 		OnDrift:       dn.onConfigDrift,
+		OnWarn:        dn.onConfigDriftWarning,
 		SystemdPath:   pathSystemd,
 		ErrChan:       dn.exitCh,
 		MachineConfig: currentConfig,
 	}
 
+	// Kernel arguments, extensions, kernel type and OS image are only managed
+	// on CoreOS variants.
+	if dn.os.IsCoreOSVariant() && dn.NodeUpdaterClient != nil {
+		coreOSDaemon := CoreOSDaemon{dn}
+		opts.CheckDeployment = coreOSDaemon.checkDeploymentForDrift
+	}
+
 	if err := dn.configDriftMonitor.Start(opts); err != nil {
 		dn.exitCh <- fmt.Errorf("could not start Config Drift Monitor: %w", err)
 		return
@@ -1350,7 +1402,7 @@ func (dn *Daemon) getStateAndConfigs() (*stateAndConfigs, error) {
 	if err != nil {
 		return nil, err
 	}
-	currentConfig, err := dn.mcLister.Get(currentConfigName)
+	currentConfig, err := dn.getMachineConfig(currentConfigName)
 	if err != nil {
 		return nil, err
 	}
@@ -1385,7 +1437,7 @@ func (dn *Daemon) getStateAndConfigs() (*stateAndConfigs, error) {
 		desiredConfig = currentConfig
 		klog.Infof("Current+desired config: %s", currentConfigName)
 	} else {
-		desiredConfig, err = dn.mcLister.Get(desiredConfigName)
+		desiredConfig, err = dn.getMachineConfig(desiredConfigName)
 		if err != nil {
 			return nil, err
 		}
@@ -1714,11 +1766,15 @@ func (dn *Daemon) checkStateOnFirstRun() error {
 		}
 	}
 
+	// Whether we came up after rebooting into a config which has not been
+	// reported as current yet.
+	rebootedIntoUpdate := false
 	if currentOnDisk != nil && state.currentConfig.GetName() != currentOnDisk.GetName() {
 		// The on disk state (if available) is always considered truth.
 		// We want to handle the case where etcd state was restored from a backup.
 		logSystem("Disk currentConfig %s overrides node's currentConfig annotation %s", currentOnDisk.GetName(), state.currentConfig.GetName())
 		state.currentConfig = currentOnDisk
+		rebootedIntoUpdate = true
 	}
 
 	// Validate the on-disk state against what we *expect*.
@@ -1740,6 +1796,10 @@ func (dn *Daemon) checkStateOnFirstRun() error {
 		return err
 	}
 
+	if rebootedIntoUpdate {
+		dn.recordUpdatePhase(state.currentConfig.GetName(), mcfgv1.NodeUpdatePhasePostRebootValidation)
+	}
+
 	if err := dn.validateOnDiskState(state.currentConfig); err != nil {
 		wErr := fmt.Errorf("unexpected on-disk state validating against %s: %w", state.currentConfig.GetName(), err)
 		dn.nodeWriter.Eventf(corev1.EventTypeWarning, "OnDiskStateValidationFailed", wErr.Error())
@@ -1748,6 +1808,12 @@ func (dn *Daemon) checkStateOnFirstRun() error {
 
 	logSystem("Validated on-disk state")
 
+	if rebootedIntoUpdate && dn.os.IsCoreOSVariant() {
+		if err := refreshBaseKernelArguments(constants.BaseKernelArgumentsFilePath, state.currentConfig.Spec.KernelArguments); err != nil {
+			klog.Warningf("Could not record base kernel arguments: %v", err)
+		}
+	}
+
 	// We've validated state. Now, ensure that node is in desired state
 	var inDesiredConfig bool
 	if inDesiredConfig, err = dn.updateConfigAndState(state); err != nil {
@@ -1895,7 +1961,7 @@ func (dn *Daemon) prepUpdateFromCluster() (*mcfgv1.MachineConfig, *mcfgv1.Machin
 	if err != nil {
 		return nil, nil, err
 	}
-	desiredConfig, err := dn.mcLister.Get(desiredConfigName)
+	desiredConfig, err := dn.getMachineConfig(desiredConfigName)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -1905,7 +1971,7 @@ func (dn *Daemon) prepUpdateFromCluster() (*mcfgv1.MachineConfig, *mcfgv1.Machin
 	if err != nil {
 		return nil, nil, err
 	}
-	currentConfig, err := dn.mcLister.Get(currentConfigName)
+	currentConfig, err := dn.getMachineConfig(currentConfigName)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -1967,10 +2033,23 @@ func (dn *Daemon) completeUpdate(desiredConfigName string) error {
 
 	logSystem("Update completed for config %s and node has been successfully uncordoned", desiredConfigName)
 	dn.nodeWriter.Eventf(corev1.EventTypeNormal, "Uncordon", fmt.Sprintf("Update completed for config %s and node has been uncordoned", desiredConfigName))
+	dn.recordUpdatePhase(desiredConfigName, mcfgv1.NodeUpdatePhaseUncordoned)
 
 	return nil
 }
 
+// recordUpdatePhase records that the node entered a phase of the update to
+// targetConfig. The timeline is informational, so failing to record it does
+// not fail the update.
+func (dn *Daemon) recordUpdatePhase(targetConfig string, phase mcfgv1.NodeUpdatePhase) {
+	if dn.nodeWriter == nil {
+		return
+	}
+	if err := dn.nodeWriter.SetUpdatePhase(targetConfig, phase); err != nil {
+		klog.Warningf("Failed to record update phase %s for config %s: %v", phase, targetConfig, err)
+	}
+}
+
 // triggerUpdateWithMachineConfig starts the update. It queries the cluster for
 // the current and desired config if they weren't passed.
 func (dn *Daemon) triggerUpdateWithMachineConfig(currentConfig, desiredConfig *mcfgv1.MachineConfig, skipCertificateWrite bool) error {
@@ -1979,7 +2058,7 @@ func (dn *Daemon) triggerUpdateWithMachineConfig(currentConfig, desiredConfig *m
 		if err != nil {
 			return err
 		}
-		currentConfig, err = dn.mcLister.Get(ccAnnotation)
+		currentConfig, err = dn.getMachineConfig(ccAnnotation)
 		if err != nil {
 			return err
 		}
@@ -1990,7 +2069,7 @@ func (dn *Daemon) triggerUpdateWithMachineConfig(currentConfig, desiredConfig *m
 		if err != nil {
 			return err
 		}
-		desiredConfig, err = dn.mcLister.Get(dcAnnotation)
+		desiredConfig, err = dn.getMachineConfig(dcAnnotation)
 		if err != nil {
 			return err
 		}
@@ -2007,35 +2086,152 @@ func (dn *Daemon) triggerUpdateWithMachineConfig(currentConfig, desiredConfig *m
 // validateKernelArguments checks that the current boot has all arguments specified
 // in the target machineconfig.
 func (dn *CoreOSDaemon) validateKernelArguments(currentConfig *mcfgv1.MachineConfig) error {
+	missing, rpmostreeKargs, err := getMissingKernelArguments(currentConfig.Spec.KernelArguments)
+	if err != nil {
+		return err
+	}
+	if len(missing) > 0 {
+		cmdlinebytes, err := os.ReadFile(CmdLineFile)
+		if err != nil {
+			klog.Warningf("Failed to read %s: %v", CmdLineFile, err)
+		} else {
+			klog.Infof("Booted command line: %s", string(cmdlinebytes))
+		}
+		klog.Infof("Current ostree kargs: %s", rpmostreeKargs)
+		klog.Infof("Expected MachineConfig kargs: %v", parseKernelArguments(currentConfig.Spec.KernelArguments))
+		return fmt.Errorf("missing expected kernel arguments: %v", missing)
+	}
+	if err := ensureBaseKernelArguments(constants.BaseKernelArgumentsFilePath, splitKernelArguments(rpmostreeKargs), currentConfig.Spec.KernelArguments); err != nil {
+		klog.Warningf("Could not record base kernel arguments: %v", err)
+	}
+	return nil
+}
+
+// ensureBaseKernelArguments records the current kernel arguments which are not
+// set by the MachineConfig as the base kernel arguments of the node, unless
+// they were recorded before. They are recorded again after every update the
+// node rebooted into, see refreshBaseKernelArguments.
+func ensureBaseKernelArguments(path string, current, kargs []string) error {
+	if _, err := os.Stat(path); err == nil || !os.IsNotExist(err) {
+		return err
+	}
+	return writeBaseKernelArguments(path, current, kargs)
+}
+
+// refreshBaseKernelArguments records the kernel arguments of the booted
+// deployment which are not set by the MachineConfig as the base kernel
+// arguments of the node. OS updates may change them, so this is done after
+// rebooting into an update.
+func refreshBaseKernelArguments(path string, kargs []string) error {
 	rpmostreeKargsBytes, err := runGetOut("rpm-ostree", "kargs")
 	if err != nil {
 		return err
 	}
+	return writeBaseKernelArguments(path, splitKernelArguments(strings.TrimSpace(string(rpmostreeKargsBytes))), kargs)
+}
+
+func writeBaseKernelArguments(path string, current, kargs []string) error {
+	_, base := diffKernelArguments(current, nil, parseKernelArguments(kargs))
+	data, err := json.Marshal(base)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomicallyWithDefaults(path, data)
+}
+
+// readBaseKernelArguments returns the recorded base kernel arguments of the
+// node, or nil if they were not recorded yet.
+func readBaseKernelArguments(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	base := []string{}
+	if err := json.Unmarshal(data, &base); err != nil {
+		return nil, fmt.Errorf("could not parse %s: %w", path, err)
+	}
+	return base, nil
+}
+
+// getKernelArgumentsDrift returns the kernel arguments from kargs which are
+// not set in the current deployment, and the ones set in the current
+// deployment which are neither in kargs nor base kernel arguments of the node,
+// e.g. added with "rpm-ostree kargs --append". Unexpected kernel arguments are
+// only reported once the base kernel arguments were recorded.
+func getKernelArgumentsDrift(kargs []string) ([]string, []string, error) {
+	rpmostreeKargsBytes, err := runGetOut("rpm-ostree", "kargs")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	base, err := readBaseKernelArguments(constants.BaseKernelArgumentsFilePath)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	missing, unexpected := diffKernelArguments(splitKernelArguments(strings.TrimSpace(string(rpmostreeKargsBytes))), base, parseKernelArguments(kargs))
+	if base == nil {
+		unexpected = nil
+	}
+	return missing, unexpected, nil
+}
+
+// diffKernelArguments returns the expected kernel arguments which are not in
+// current, and the ones in current which are neither expected nor in base.
+// Kernel arguments may be repeated, e.g. console, so each occurrence in
+// current is matched with at most one in base or expected.
+func diffKernelArguments(current, base, expected []string) ([]string, []string) {
+	found := make(map[string]bool)
+	for _, arg := range current {
+		found[arg] = true
+	}
+	missing := []string{}
+	for _, arg := range expected {
+		if !found[arg] {
+			missing = append(missing, arg)
+		}
+	}
+
+	known := make(map[string]int)
+	for _, arg := range append(append([]string{}, base...), expected...) {
+		known[arg]++
+	}
+	unexpected := []string{}
+	for _, arg := range current {
+		if known[arg] > 0 {
+			known[arg]--
+			continue
+		}
+		unexpected = append(unexpected, arg)
+	}
+	return missing, unexpected
+}
+
+// getMissingKernelArguments returns the kernel arguments from kargs which are
+// not set in the current deployment, along with the current rpm-ostree kargs.
+func getMissingKernelArguments(kargs []string) ([]string, string, error) {
+	rpmostreeKargsBytes, err := runGetOut("rpm-ostree", "kargs")
+	if err != nil {
+		return nil, "", err
+	}
 	rpmostreeKargs := strings.TrimSpace(string(rpmostreeKargsBytes))
 	foundArgsArray := strings.Split(rpmostreeKargs, " ")
 	foundArgs := make(map[string]bool)
 	for _, arg := range foundArgsArray {
 		foundArgs[arg] = true
 	}
-	expected := parseKernelArguments(currentConfig.Spec.KernelArguments)
+	expected := parseKernelArguments(kargs)
 	missing := []string{}
 	for _, karg := range expected {
 		if _, ok := foundArgs[karg]; !ok {
 			missing = append(missing, karg)
 		}
 	}
-	if len(missing) > 0 {
-		cmdlinebytes, err := os.ReadFile(CmdLineFile)
-		if err != nil {
-			klog.Warningf("Failed to read %s: %v", CmdLineFile, err)
-		} else {
-			klog.Infof("Booted command line: %s", string(cmdlinebytes))
-		}
-		klog.Infof("Current ostree kargs: %s", rpmostreeKargs)
-		klog.Infof("Expected MachineConfig kargs: %v", expected)
-		return fmt.Errorf("missing expected kernel arguments: %v", missing)
-	}
-	return nil
+	return missing, rpmostreeKargs, nil
 }
 
 // Implementation of validateOnDiskState which checks a few conditions
@@ -2053,7 +2249,19 @@ func (dn *Daemon) validateOnDiskStateImpl(currentConfig *mcfgv1.MachineConfig) e
 		}
 	}
 
-	return validateOnDiskState(currentConfig, pathSystemd)
+	onWarn := func(err error) {
+		var warning *configDriftWarning
+		if errors.As(err, &warning) {
+			recordConfigDrift(warning.Mismatch, ctrlcommon.ConfigDriftActionWarn)
+		}
+		dn.onConfigDriftWarning(err)
+	}
+
+	degraded, err := validateOnDiskMismatches(currentConfig, pathSystemd, onWarn)
+	for _, mismatch := range degraded {
+		recordConfigDrift(mismatch, ctrlcommon.ConfigDriftActionDegrade)
+	}
+	return err
 }
 
 // validateOnDiskState compares the on-disk state against what a configuration
@@ -2119,39 +2327,21 @@ func ValidPath(path string) bool {
 }
 
 // senseAndLoadOnceFrom gets a hold of the content for supported onceFrom configurations,
-// parses to verify the type, and returns back the genericInterface, the type description,
-// if it was local or remote, and error.
-func (dn *Daemon) senseAndLoadOnceFrom(onceFrom string) (interface{}, onceFromOrigin, error) {
-	var (
-		content     []byte
-		contentFrom onceFromOrigin
-	)
-	// Read the content from a remote endpoint if requested
-	/* #nosec */
-	if strings.HasPrefix(onceFrom, "http://") || strings.HasPrefix(onceFrom, "https://") {
+// verifies its checksum and signature if requested, parses to verify the type, and
+// returns back the genericInterface, the type description, if it was local or remote, and error.
+func (dn *Daemon) senseAndLoadOnceFrom(onceFrom string, opts OnceFromOptions, report *OnceFromReport) (interface{}, onceFromOrigin, error) {
+	contentFrom := onceFromLocalConfig
+	if isRemoteOnceFrom(onceFrom) {
 		contentFrom = onceFromRemoteConfig
-		resp, err := http.Get(onceFrom)
-		if err != nil {
-			return nil, contentFrom, err
-		}
-		defer resp.Body.Close()
-		// Read the body content from the request
-		content, err = io.ReadAll(resp.Body)
-		if err != nil {
-			return nil, contentFrom, err
-		}
+	}
 
-	} else {
-		// Otherwise read it from a local file
-		contentFrom = onceFromLocalConfig
-		absoluteOnceFrom, err := filepath.Abs(filepath.Clean(onceFrom))
-		if err != nil {
-			return nil, contentFrom, err
-		}
-		content, err = os.ReadFile(absoluteOnceFrom)
-		if err != nil {
-			return nil, contentFrom, err
-		}
+	content, err := fetchOnceFromContent(onceFrom)
+	if err != nil {
+		return nil, contentFrom, err
+	}
+
+	if err := verifyOnceFromContent(onceFrom, content, opts, report); err != nil {
+		return nil, contentFrom, err
 	}
 
 	// Try each supported parser
@@ -4,11 +4,13 @@ import (
 	"context"
 	"fmt"
 	"reflect"
+	"strings"
 	"time"
 
 	"github.com/BurntSushi/toml"
 	"github.com/containers/image/v5/pkg/sysregistriesv2"
 	ign3types "github.com/coreos/ignition/v2/config/v3_4/types"
+	mcfgv1 "github.com/openshift/machine-config-operator/pkg/apis/machineconfiguration.openshift.io/v1"
 	ctrlcommon "github.com/openshift/machine-config-operator/pkg/controller/common"
 	"github.com/openshift/machine-config-operator/pkg/daemon/constants"
 	corev1 "k8s.io/api/core/v1"
@@ -68,6 +70,7 @@ func (dn *Daemon) performDrain() error {
 	if err := dn.nodeWriter.SetDesiredDrainer(desiredDrainAnnotationValue); err != nil {
 		return fmt.Errorf("Could not set drain annotation: %w", err)
 	}
+	dn.recordUpdatePhase(desiredConfigName, mcfgv1.NodeUpdatePhaseDrainRequested)
 
 	ctx := context.TODO()
 
@@ -91,6 +94,7 @@ func (dn *Daemon) performDrain() error {
 	}
 
 	logSystem("drain complete")
+	dn.recordUpdatePhase(desiredConfigName, mcfgv1.NodeUpdatePhaseDrained)
 	t := time.Since(startTime).Seconds()
 	klog.Infof("Successful drain took %v seconds", t)
 
@@ -115,6 +119,16 @@ func isDrainRequired(actions, diffFileSet []string, oldIgnConfig, newIgnConfig i
 	} else if ctrlcommon.InSlice(postConfigChangeActionNone, actions) {
 		return false, nil
 	}
+	// Restarting the units applying node settings does not affect workloads.
+	restartsOnly := len(actions) > 0
+	for _, action := range actions {
+		if !strings.HasPrefix(action, postConfigChangeActionRestartPrefix) {
+			restartsOnly = false
+		}
+	}
+	if restartsOnly {
+		return false, nil
+	}
 	// For any unhandled cases, default to drain
 	return true, nil
 }
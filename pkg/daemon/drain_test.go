@@ -356,6 +356,13 @@ location = "mirror.com/repo/test-img-14"
 			newConfig:      machineConfigs["mc1"],
 			expectedAction: true,
 		},
+		{
+			// skip drain: only node settings units are restarted
+			actions:        []string{postConfigChangeActionRestartPrefix + "chronyd.service", postConfigChangeActionRestartPrefix + "systemd-sysctl.service"},
+			oldConfig:      machineConfigs["mc1"],
+			newConfig:      machineConfigs["mc1"],
+			expectedAction: false,
+		},
 		// below tests are run when only crio reload action is present
 		{
 			// skip drain: no changes in registry config
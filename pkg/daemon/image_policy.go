@@ -0,0 +1,132 @@
+package daemon
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/containers/image/v5/docker/policyconfiguration"
+	"github.com/containers/image/v5/docker/reference"
+)
+
+const (
+	// The containers-policy.json(5) file which governs which images may be pulled.
+	containersPolicyPath = "/etc/containers/policy.json"
+
+	// The rpm-ostree image reference prefixes. Unverified images are pulled
+	// without any signature checks while signed images are verified by
+	// rpm-ostree according to the containers policy.
+	ostreeUnverifiedRegistryPrefix = "ostree-unverified-registry:"
+	ostreeImageSignedPrefix        = "ostree-image-signed:docker://"
+)
+
+// The containers-policy.json(5) requirement types which we care about.
+const (
+	policyRequirementReject         = "reject"
+	policyRequirementSignedBy       = "signedBy"
+	policyRequirementSigstoreSigned = "sigstoreSigned"
+)
+
+type policyRequirement struct {
+	Type string `json:"type"`
+}
+
+// containersPolicy is the subset of containers-policy.json(5) needed to
+// determine whether an image must be signed.
+type containersPolicy struct {
+	Default    []policyRequirement                       `json:"default"`
+	Transports map[string]map[string][]policyRequirement `json:"transports"`
+}
+
+// requirementsFor returns the requirements of the most specific scope which
+// matches the given image, in the same order as the containers/image library.
+func (p containersPolicy) requirementsFor(ref reference.Named) ([]policyRequirement, error) {
+	scopes, ok := p.Transports["docker"]
+	if !ok {
+		return p.Default, nil
+	}
+
+	identity, err := policyconfiguration.DockerReferenceIdentity(ref)
+	if err != nil {
+		return nil, err
+	}
+
+	names := append([]string{identity}, policyconfiguration.DockerReferenceNamespaces(ref)...)
+	// An empty scope is the default for the transport.
+	names = append(names, "")
+
+	for _, name := range names {
+		if reqs, ok := scopes[name]; ok {
+			return reqs, nil
+		}
+	}
+
+	return p.Default, nil
+}
+
+// getLayeredImageReference returns the rpm-ostree image reference to rebase
+// onto for the given image pullspec. If the containers policy requires
+// signatures for the image, the image is verified by rpm-ostree; if the policy
+// rejects the image, an error is returned. Without a policy, the image is
+// pulled unverified as before.
+func getLayeredImageReference(policyPath, imgURL string) (string, error) {
+	unverified := ostreeUnverifiedRegistryPrefix + imgURL
+
+	data, err := os.ReadFile(policyPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return unverified, nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("could not read containers policy %s: %w", policyPath, err)
+	}
+
+	policy := containersPolicy{}
+	if err := json.Unmarshal(data, &policy); err != nil {
+		return "", fmt.Errorf("could not parse containers policy %s: %w", policyPath, err)
+	}
+
+	ref, err := reference.ParseNormalizedNamed(imgURL)
+	if err != nil {
+		return "", fmt.Errorf("could not parse image %s: %w", imgURL, err)
+	}
+	ref = reference.TagNameOnly(ref)
+
+	reqs, err := policy.requirementsFor(ref)
+	if err != nil {
+		return "", fmt.Errorf("could not evaluate containers policy for image %s: %w", imgURL, err)
+	}
+
+	signed := false
+	for _, req := range reqs {
+		switch req.Type {
+		case policyRequirementReject:
+			return "", fmt.Errorf("image %s is rejected by containers policy %s", imgURL, policyPath)
+		case policyRequirementSignedBy, policyRequirementSigstoreSigned:
+			signed = true
+		}
+	}
+
+	if signed {
+		return ostreeImageSignedPrefix + imgURL, nil
+	}
+
+	return unverified, nil
+}
+
+// trimLayeredImageReference strips the rpm-ostree transport prefix from an
+// image reference, returning the image pullspec.
+func trimLayeredImageReference(imgRef string) string {
+	if strings.HasPrefix(imgRef, ostreeImageSignedPrefix) {
+		return strings.TrimPrefix(imgRef, ostreeImageSignedPrefix)
+	}
+
+	// right now they start with "ostree-unverified-registry:", so scrape that off
+	tokens := strings.SplitN(imgRef, ":", 2)
+	if len(tokens) > 1 {
+		return tokens[1]
+	}
+
+	return ""
+}
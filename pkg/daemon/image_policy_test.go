@@ -0,0 +1,158 @@
+package daemon
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/openshift/machine-config-operator/pkg/daemon/constants"
+)
+
+func TestGetLayeredImageReference(t *testing.T) {
+	t.Parallel()
+
+	img := "registry.example.com/mco/os-image@sha256:12e89d631c0ca1700262583acfb856b6e7dbe94800cb38035d68ee5cc912411c"
+
+	testCases := []struct {
+		name        string
+		policy      string
+		expected    string
+		errExpected bool
+	}{
+		{
+			name:     "No policy",
+			expected: "ostree-unverified-registry:" + img,
+		},
+		{
+			name:     "Insecure default",
+			policy:   `{"default": [{"type": "insecureAcceptAnything"}]}`,
+			expected: "ostree-unverified-registry:" + img,
+		},
+		{
+			name: "Sigstore signatures required for repository",
+			policy: `{
+				"default": [{"type": "insecureAcceptAnything"}],
+				"transports": {
+					"docker": {
+						"registry.example.com/mco/os-image": [{"type": "sigstoreSigned", "keyPath": "/etc/pki/cosign.pub"}]
+					}
+				}
+			}`,
+			expected: "ostree-image-signed:docker://" + img,
+		},
+		{
+			name: "GPG signatures required for registry",
+			policy: `{
+				"default": [{"type": "insecureAcceptAnything"}],
+				"transports": {
+					"docker": {
+						"registry.example.com": [{"type": "signedBy", "keyType": "GPGKeys", "keyPath": "/etc/pki/key.gpg"}]
+					}
+				}
+			}`,
+			expected: "ostree-image-signed:docker://" + img,
+		},
+		{
+			name: "Signatures required for wildcard domain",
+			policy: `{
+				"default": [{"type": "insecureAcceptAnything"}],
+				"transports": {
+					"docker": {
+						"*.example.com": [{"type": "sigstoreSigned", "keyPath": "/etc/pki/cosign.pub"}]
+					}
+				}
+			}`,
+			expected: "ostree-image-signed:docker://" + img,
+		},
+		{
+			name: "More specific scope takes precedence",
+			policy: `{
+				"default": [{"type": "insecureAcceptAnything"}],
+				"transports": {
+					"docker": {
+						"registry.example.com": [{"type": "sigstoreSigned", "keyPath": "/etc/pki/cosign.pub"}],
+						"registry.example.com/mco": [{"type": "insecureAcceptAnything"}]
+					}
+				}
+			}`,
+			expected: "ostree-unverified-registry:" + img,
+		},
+		{
+			name: "Signatures required for another registry",
+			policy: `{
+				"default": [{"type": "insecureAcceptAnything"}],
+				"transports": {
+					"docker": {
+						"quay.io": [{"type": "sigstoreSigned", "keyPath": "/etc/pki/cosign.pub"}]
+					}
+				}
+			}`,
+			expected: "ostree-unverified-registry:" + img,
+		},
+		{
+			name: "Transport default",
+			policy: `{
+				"default": [{"type": "insecureAcceptAnything"}],
+				"transports": {
+					"docker": {
+						"": [{"type": "sigstoreSigned", "keyPath": "/etc/pki/cosign.pub"}]
+					}
+				}
+			}`,
+			expected: "ostree-image-signed:docker://" + img,
+		},
+		{
+			name:        "Rejected",
+			policy:      `{"default": [{"type": "reject"}]}`,
+			errExpected: true,
+		},
+		{
+			name:        "Malformed policy",
+			policy:      `{"default": `,
+			errExpected: true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			policyPath := filepath.Join(t.TempDir(), "policy.json")
+			if testCase.policy != "" {
+				require.NoError(t, os.WriteFile(policyPath, []byte(testCase.policy), 0o644))
+			}
+
+			imgRef, err := getLayeredImageReference(policyPath, img)
+			if testCase.errExpected {
+				assert.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, testCase.expected, imgRef)
+			assert.Equal(t, img, trimLayeredImageReference(imgRef))
+		})
+	}
+}
+
+// Tests that only the image named by the desired image annotation of the node
+// is considered to be built on-cluster.
+func TestIsOnClusterBuiltImage(t *testing.T) {
+	img := "registry.example.com/mco/os-image@sha256:12e89d631c0ca1700262583acfb856b6e7dbe94800cb38035d68ee5cc912411c"
+
+	dn := &Daemon{}
+	assert.False(t, dn.isOnClusterBuiltImage(img))
+
+	dn.node = &corev1.Node{ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{}}}
+	assert.False(t, dn.isOnClusterBuiltImage(img))
+
+	dn.node.Annotations[constants.DesiredImageAnnotationKey] = img
+	assert.True(t, dn.isOnClusterBuiltImage(img))
+	assert.False(t, dn.isOnClusterBuiltImage("quay.io/openshift-release-dev/ocp-v4.0-art-dev@sha256:1234"))
+}
@@ -2,6 +2,7 @@ package daemon
 
 import (
 	"fmt"
+	"strings"
 
 	ctrlcommon "github.com/openshift/machine-config-operator/pkg/controller/common"
 	"github.com/prometheus/client_golang/prometheus"
@@ -50,8 +51,102 @@ var (
 			Name: "mcd_update_state",
 			Help: "completed update config or error",
 		}, []string{"config", "err"})
+
+	// mcdConfigDrifts tallys detected config drifts
+	mcdConfigDrifts = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "mcd_config_drifts_total",
+			Help: "Total number of config drifts detected, by drift type, path class and config drift policy action.",
+		}, []string{"type", "path_class", "action"})
+
+	// mcdConfigDriftLastDetected logs when a config drift was last detected
+	mcdConfigDriftLastDetected = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "mcd_config_drift_last_detected_timestamp_seconds",
+			Help: "Unix time at which a config drift was last detected, by drift type and path class.",
+		}, []string{"type", "path_class"})
+
+	// mcdConfigDriftDetected flags nodes that drifted from their current config
+	mcdConfigDriftDetected = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "mcd_config_drift_detected",
+			Help: "1 if a config drift which degrades the node was detected since the Config Drift Monitor started, 0 otherwise.",
+		})
 )
 
+// configDriftPathClasses maps path prefixes to the path_class label of the
+// config drift metrics, which keeps its cardinality bounded. The first
+// matching prefix wins.
+var configDriftPathClasses = []struct {
+	prefix string
+	class  string
+}{
+	{"/etc/kubernetes/", "kubernetes"},
+	{"/etc/crio/", "crio"},
+	{"/etc/containers/", "containers"},
+	{"/etc/NetworkManager/", "networkmanager"},
+	{"/etc/ssh/", "ssh"},
+	{"/etc/pki/", "pki"},
+	{"/etc/sysctl.d/", "sysctl"},
+	{"/etc/modprobe.d/", "modprobe"},
+	{"/etc/systemd/", "systemd"},
+	{"/etc/", "etc"},
+	{"/var/", "var"},
+	{"/usr/local/", "usr-local"},
+}
+
+// configDriftMetricType returns the type label of the config drift metrics
+// for a mismatch: file_content, file_mode, file_missing, unit, dropin,
+// kernel_arguments, extensions, kernel_type or os_image.
+func configDriftMetricType(mismatch Mismatch) string {
+	switch mismatch.Type {
+	case MismatchTypeFile:
+		if mismatch.Reason == "" {
+			return "file"
+		}
+		return "file_" + string(mismatch.Reason)
+	case MismatchTypeKernelArguments:
+		return "kernel_arguments"
+	case MismatchTypeKernelType:
+		return "kernel_type"
+	case MismatchTypeOSImage:
+		return "os_image"
+	default:
+		return string(mismatch.Type)
+	}
+}
+
+// configDriftPathClass returns the path_class label of the config drift
+// metrics for a mismatch.
+func configDriftPathClass(mismatch Mismatch) string {
+	switch mismatch.Type {
+	case MismatchTypeUnit, MismatchTypeDropin:
+		return "systemd"
+	case MismatchTypeFile:
+		for _, pathClass := range configDriftPathClasses {
+			if strings.HasPrefix(mismatch.Path, pathClass.prefix) {
+				return pathClass.class
+			}
+		}
+		return "other"
+	default:
+		return "deployment"
+	}
+}
+
+// recordConfigDrift updates the config drift metrics for a mismatch handled
+// with the given config drift policy action.
+func recordConfigDrift(mismatch Mismatch, action ctrlcommon.ConfigDriftAction) {
+	driftType := configDriftMetricType(mismatch)
+	pathClass := configDriftPathClass(mismatch)
+
+	mcdConfigDrifts.WithLabelValues(driftType, pathClass, strings.ToLower(string(action))).Inc()
+	mcdConfigDriftLastDetected.WithLabelValues(driftType, pathClass).SetToCurrentTime()
+	if action == ctrlcommon.ConfigDriftActionDegrade {
+		mcdConfigDriftDetected.Set(1)
+	}
+}
+
 // Updates metric with new labels & timestamp, deletes any existing
 // gauges stored in the metric prior to doing so.
 // More context: https://issues.redhat.com/browse/OCPBUGS-1662
@@ -70,6 +165,9 @@ func RegisterMCDMetrics() error {
 		kubeletHealthState,
 		mcdRebootErr,
 		mcdUpdateState,
+		mcdConfigDrifts,
+		mcdConfigDriftLastDetected,
+		mcdConfigDriftDetected,
 	})
 
 	if err != nil {
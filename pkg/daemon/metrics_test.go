@@ -0,0 +1,78 @@
+package daemon
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+
+	ctrlcommon "github.com/openshift/machine-config-operator/pkg/controller/common"
+)
+
+func TestConfigDriftMetricLabels(t *testing.T) {
+	testCases := []struct {
+		mismatch  Mismatch
+		driftType string
+		pathClass string
+	}{
+		{
+			mismatch:  Mismatch{Type: MismatchTypeFile, Path: "/etc/kubernetes/kubelet.conf", Reason: MismatchReasonContent},
+			driftType: "file_content",
+			pathClass: "kubernetes",
+		},
+		{
+			mismatch:  Mismatch{Type: MismatchTypeFile, Path: "/etc/crio/crio.conf.d/00-default", Reason: MismatchReasonMode},
+			driftType: "file_mode",
+			pathClass: "crio",
+		},
+		{
+			mismatch:  Mismatch{Type: MismatchTypeFile, Path: "/etc/foo", Reason: MismatchReasonMissing},
+			driftType: "file_missing",
+			pathClass: "etc",
+		},
+		{
+			mismatch:  Mismatch{Type: MismatchTypeFile, Path: "/opt/foo"},
+			driftType: "file",
+			pathClass: "other",
+		},
+		{
+			mismatch:  Mismatch{Type: MismatchTypeDropin, Path: "/etc/systemd/system/kubelet.service.d/10-foo.conf", Reason: MismatchReasonContent},
+			driftType: "dropin",
+			pathClass: "systemd",
+		},
+		{
+			mismatch:  Mismatch{Type: MismatchTypeKernelArguments},
+			driftType: "kernel_arguments",
+			pathClass: "deployment",
+		},
+		{
+			mismatch:  Mismatch{Type: MismatchTypeOSImage},
+			driftType: "os_image",
+			pathClass: "deployment",
+		},
+	}
+
+	for _, testCase := range testCases {
+		testCase := testCase
+		t.Run(testCase.driftType, func(t *testing.T) {
+			assert.Equal(t, testCase.driftType, configDriftMetricType(testCase.mismatch))
+			assert.Equal(t, testCase.pathClass, configDriftPathClass(testCase.mismatch))
+		})
+	}
+}
+
+func TestRecordConfigDrift(t *testing.T) {
+	mcdConfigDrifts.Reset()
+	mcdConfigDriftDetected.Set(0)
+
+	mismatch := Mismatch{Type: MismatchTypeFile, Path: "/etc/foo", Reason: MismatchReasonContent}
+
+	recordConfigDrift(mismatch, ctrlcommon.ConfigDriftActionWarn)
+	assert.Equal(t, float64(1), testutil.ToFloat64(mcdConfigDrifts.WithLabelValues("file_content", "etc", "warn")))
+	assert.Equal(t, float64(0), testutil.ToFloat64(mcdConfigDriftDetected))
+
+	recordConfigDrift(mismatch, ctrlcommon.ConfigDriftActionDegrade)
+	assert.Equal(t, float64(1), testutil.ToFloat64(mcdConfigDrifts.WithLabelValues("file_content", "etc", "degrade")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(mcdConfigDriftDetected))
+	assert.NotZero(t, testutil.ToFloat64(mcdConfigDriftLastDetected.WithLabelValues("file_content", "etc")))
+}
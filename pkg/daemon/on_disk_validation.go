@@ -2,6 +2,7 @@ package daemon
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -14,36 +15,55 @@ import (
 	"k8s.io/klog/v2"
 )
 
-// Validates that the on-disk state matches a given MachineConfig.
-func validateOnDiskState(currentConfig *mcfgv1.MachineConfig, systemdPath string) error {
-	// And the rest of the disk state
+// Validates that the on-disk state matches a given MachineConfig. If the
+// MachineConfig has a config drift policy, drift on ignored paths is skipped
+// and drift on warn-only paths is passed to onWarn instead of being returned.
+func validateOnDiskState(currentConfig *mcfgv1.MachineConfig, systemdPath string, onWarn func(error)) error {
+	_, err := validateOnDiskMismatches(currentConfig, systemdPath, onWarn)
+	return err
+}
+
+// validateOnDiskMismatches checks every file, unit and dropin and applies the
+// config drift policy action of its path to any mismatch, like
+// validateOnDiskState. It also returns the mismatches which degrade the node,
+// so that callers can record them without checking the on-disk state again.
+func validateOnDiskMismatches(currentConfig *mcfgv1.MachineConfig, systemdPath string, onWarn func(error)) ([]Mismatch, error) {
+	policy, err := ctrlcommon.GetConfigDriftPolicy(currentConfig)
+	if err != nil {
+		return nil, err
+	}
+
 	// We want to verify the disk state in the spec version that it was created with,
 	// to remove possibilities of behaviour changes due to translation
-	ignconfigi, err := ctrlcommon.IgnParseWrapper(currentConfig.Spec.Config.Raw)
+	mismatches, err := collectOnDiskMismatches(currentConfig, systemdPath)
 	if err != nil {
-		return fmt.Errorf("failed to parse Ignition for validation: %w", err)
+		return nil, err
 	}
 
-	switch typedConfig := ignconfigi.(type) {
-	case ign3types.Config:
-		if err := checkV3Files(ignconfigi.(ign3types.Config).Storage.Files); err != nil {
-			return &fileConfigDriftErr{err}
-		}
-		if err := checkV3Units(ignconfigi.(ign3types.Config).Systemd.Units, systemdPath); err != nil {
-			return &unitConfigDriftErr{err}
-		}
-		return nil
-	case ign2types.Config:
-		if err := checkV2Files(ignconfigi.(ign2types.Config).Storage.Files); err != nil {
-			return &fileConfigDriftErr{err}
-		}
-		if err := checkV2Units(ignconfigi.(ign2types.Config).Systemd.Units, systemdPath); err != nil {
-			return &unitConfigDriftErr{err}
+	degraded := []Mismatch{}
+	var degradeErr error
+	for _, mismatch := range mismatches {
+		switch policy.ActionForPath(mismatch.Path) {
+		case ctrlcommon.ConfigDriftActionIgnore:
+			klog.V(4).Infof("Ignoring config drift on %s per config drift policy: %s", mismatch.Path, mismatch.Message)
+		case ctrlcommon.ConfigDriftActionWarn:
+			if onWarn != nil {
+				onWarn(&configDriftWarning{mismatch})
+			}
+		default:
+			degraded = append(degraded, mismatch)
+			if degradeErr != nil {
+				continue
+			}
+			if mismatch.Type == MismatchTypeFile {
+				degradeErr = &fileConfigDriftErr{fmt.Errorf("%s", mismatch.Message)}
+			} else {
+				degradeErr = &unitConfigDriftErr{fmt.Errorf("%s", mismatch.Message)}
+			}
 		}
-		return nil
-	default:
-		return fmt.Errorf("unexpected type for ignition config: %v", typedConfig)
 	}
+
+	return degraded, degradeErr
 }
 
 // Checks that the ondisk state for a systemd dropin matches the expected state.
@@ -224,10 +244,10 @@ func checkV2Files(files []ign2types.File) error {
 func checkFileContentsAndMode(filePath string, expectedContent []byte, mode os.FileMode) error {
 	fi, err := os.Lstat(filePath)
 	if err != nil {
-		return fmt.Errorf("could not stat file %q: %w", filePath, err)
+		return &fileMismatchErr{MismatchReasonMissing, fmt.Errorf("could not stat file %q: %w", filePath, err)}
 	}
 	if fi.Mode() != mode {
-		return fmt.Errorf("mode mismatch for file: %q; expected: %[2]v/%[2]d/%#[2]o; received: %[3]v/%[3]d/%#[3]o", filePath, mode, fi.Mode())
+		return &fileMismatchErr{MismatchReasonMode, fmt.Errorf("mode mismatch for file: %q; expected: %[2]v/%[2]d/%#[2]o; received: %[3]v/%[3]d/%#[3]o", filePath, mode, fi.Mode())}
 	}
 	contents, err := os.ReadFile(filePath)
 	if err != nil {
@@ -235,11 +255,32 @@ func checkFileContentsAndMode(filePath string, expectedContent []byte, mode os.F
 	}
 	if !bytes.Equal(contents, expectedContent) {
 		klog.Errorf("content mismatch for file %q (-want +got):\n%s", filePath, cmp.Diff(expectedContent, contents))
-		return fmt.Errorf("content mismatch for file %q", filePath)
+		return &fileMismatchErr{MismatchReasonContent, fmt.Errorf("content mismatch for file %q", filePath)}
 	}
 	return nil
 }
 
+// fileMismatchErr is returned by checkFileContentsAndMode when a file is
+// missing or its mode or contents differ.
+type fileMismatchErr struct {
+	reason MismatchReason
+	error
+}
+
+func (e *fileMismatchErr) Unwrap() error {
+	return e.error
+}
+
+// mismatchReason returns why a file did not match, or the empty string if
+// it cannot be determined from err.
+func mismatchReason(err error) MismatchReason {
+	var fmErr *fileMismatchErr
+	if errors.As(err, &fmErr) {
+		return fmErr.reason
+	}
+	return ""
+}
+
 // Gets the absolute path for a systemd unit and dropin, given a root path.
 func getIgn2SystemdDropinPath(systemdPath string, unit ign2types.Unit, dropin ign2types.SystemdDropin) string {
 	return filepath.Join(getSystemdPath(systemdPath), unit.Name+".d", dropin.Name)
@@ -0,0 +1,266 @@
+package daemon
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	ign3types "github.com/coreos/ignition/v2/config/v3_4/types"
+	"github.com/google/renameio"
+	"k8s.io/klog/v2"
+
+	mcfgv1 "github.com/openshift/machine-config-operator/pkg/apis/machineconfiguration.openshift.io/v1"
+	ctrlcommon "github.com/openshift/machine-config-operator/pkg/controller/common"
+)
+
+const (
+	// onceFromSignatureSuffix is appended to the onceFrom location to find its
+	// detached signature when no explicit signature location is given.
+	onceFromSignatureSuffix = ".sig"
+)
+
+// OnceFromOptions configures how RunOnceFrom fetches, verifies and reports on
+// the config it applies.
+type OnceFromOptions struct {
+	// SkipReboot skips the reboot after a sync.
+	SkipReboot bool
+	// SHA256 is the expected hex encoded sha256 checksum of the fetched
+	// content. Ignored when empty.
+	SHA256 string
+	// PublicKeyPath is the path of a PEM encoded public key (ECDSA, RSA or
+	// Ed25519) used to verify a detached signature of the content. When set,
+	// content without a valid signature is rejected.
+	PublicKeyPath string
+	// Signature is the local path or URL of the detached signature. Defaults to
+	// the onceFrom location with a .sig suffix.
+	Signature string
+	// ReportPath is where a JSON report describing the run is written. Ignored
+	// when empty.
+	ReportPath string
+}
+
+// OnceFromReport is the machine-readable result of a onceFrom run.
+type OnceFromReport struct {
+	// Source is the onceFrom path or URL.
+	Source string `json:"source"`
+	// ConfigType is either "Ignition" or "MachineConfig".
+	ConfigType string `json:"configType,omitempty"`
+	// SHA256 is the hex encoded checksum of the fetched content.
+	SHA256 string `json:"sha256,omitempty"`
+	// ChecksumVerified is true if the content matched a pinned checksum.
+	ChecksumVerified bool `json:"checksumVerified"`
+	// SignatureVerified is true if the content had a valid detached signature.
+	SignatureVerified bool `json:"signatureVerified"`
+	// Applied lists what was written to the node.
+	Applied OnceFromAppliedContent `json:"applied"`
+	// Skipped lists config sections that onceFrom does not apply.
+	Skipped []string `json:"skipped,omitempty"`
+	// RebootPending is true if a reboot is needed but was skipped.
+	RebootPending bool `json:"rebootPending"`
+	// Error holds the error that aborted the run, if any.
+	Error string `json:"error,omitempty"`
+}
+
+// OnceFromAppliedContent lists the content applied by a onceFrom run.
+type OnceFromAppliedContent struct {
+	Files           []string `json:"files,omitempty"`
+	Units           []string `json:"units,omitempty"`
+	KernelArguments []string `json:"kernelArguments,omitempty"`
+	Extensions      []string `json:"extensions,omitempty"`
+	KernelType      string   `json:"kernelType,omitempty"`
+	OSImageURL      string   `json:"osImageURL,omitempty"`
+}
+
+// isRemoteOnceFrom returns true if the onceFrom location is a URL.
+func isRemoteOnceFrom(location string) bool {
+	return strings.HasPrefix(location, "http://") || strings.HasPrefix(location, "https://")
+}
+
+// fetchOnceFromContent reads the content at a local path or URL.
+func fetchOnceFromContent(location string) ([]byte, error) {
+	if isRemoteOnceFrom(location) {
+		/* #nosec */
+		resp, err := http.Get(location)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("fetching %s: unexpected status %s", location, resp.Status)
+		}
+		return io.ReadAll(resp.Body)
+	}
+
+	absolute, err := filepath.Abs(filepath.Clean(location))
+	if err != nil {
+		return nil, err
+	}
+	return os.ReadFile(absolute)
+}
+
+// verifyOnceFromContent checks the content against the pinned checksum and the
+// detached signature configured in opts, recording the results in report.
+func verifyOnceFromContent(onceFrom string, content []byte, opts OnceFromOptions, report *OnceFromReport) error {
+	sum := sha256.Sum256(content)
+	report.SHA256 = hex.EncodeToString(sum[:])
+
+	if opts.SHA256 != "" {
+		if !strings.EqualFold(strings.TrimSpace(opts.SHA256), report.SHA256) {
+			return fmt.Errorf("checksum mismatch for %s: expected sha256 %s, got %s", onceFrom, opts.SHA256, report.SHA256)
+		}
+		report.ChecksumVerified = true
+		klog.Infof("onceFrom content matches pinned sha256 %s", report.SHA256)
+	}
+
+	if opts.PublicKeyPath == "" {
+		return nil
+	}
+
+	keyData, err := os.ReadFile(opts.PublicKeyPath)
+	if err != nil {
+		return fmt.Errorf("reading public key: %w", err)
+	}
+
+	sigLocation := opts.Signature
+	if sigLocation == "" {
+		sigLocation = onceFrom + onceFromSignatureSuffix
+	}
+	sig, err := fetchOnceFromContent(sigLocation)
+	if err != nil {
+		return fmt.Errorf("fetching signature from %s: %w", sigLocation, err)
+	}
+
+	if err := verifyDetachedSignature(keyData, content, sig); err != nil {
+		return fmt.Errorf("signature verification of %s failed: %w", onceFrom, err)
+	}
+	report.SignatureVerified = true
+	klog.Infof("onceFrom content signature verified with %s", opts.PublicKeyPath)
+
+	return nil
+}
+
+// verifyDetachedSignature verifies a signature over content with a PEM encoded
+// public key. The signature may be raw or base64 encoded, which makes it
+// compatible with blobs signed by "cosign sign-blob" and "openssl dgst -sha256 -sign".
+func verifyDetachedSignature(keyData, content, sig []byte) error {
+	block, _ := pem.Decode(keyData)
+	if block == nil {
+		return fmt.Errorf("no PEM data found in public key")
+	}
+	pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return fmt.Errorf("parsing public key: %w", err)
+	}
+
+	if decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(sig))); err == nil {
+		sig = decoded
+	}
+
+	digest := sha256.Sum256(content)
+
+	switch key := pub.(type) {
+	case *ecdsa.PublicKey:
+		if !ecdsa.VerifyASN1(key, digest[:], sig) {
+			return fmt.Errorf("invalid ECDSA signature")
+		}
+	case *rsa.PublicKey:
+		if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], sig); err != nil {
+			return fmt.Errorf("invalid RSA signature: %w", err)
+		}
+	case ed25519.PublicKey:
+		if !ed25519.Verify(key, content, sig) {
+			return fmt.Errorf("invalid Ed25519 signature")
+		}
+	default:
+		return fmt.Errorf("unsupported public key type %T", pub)
+	}
+
+	return nil
+}
+
+// ignitionSectionsSkippedByOnceFrom lists the sections of an Ignition config
+// that onceFrom does not apply when running directly from Ignition.
+func ignitionSectionsSkippedByOnceFrom(cfg ign3types.Config) []string {
+	skipped := []string{}
+	sections := []struct {
+		name  string
+		count int
+	}{
+		{"storage.directories", len(cfg.Storage.Directories)},
+		{"storage.links", len(cfg.Storage.Links)},
+		{"storage.disks", len(cfg.Storage.Disks)},
+		{"storage.filesystems", len(cfg.Storage.Filesystems)},
+		{"storage.raid", len(cfg.Storage.Raid)},
+		{"storage.luks", len(cfg.Storage.Luks)},
+		{"passwd.users", len(cfg.Passwd.Users)},
+		{"passwd.groups", len(cfg.Passwd.Groups)},
+		{"kernelArguments", len(cfg.KernelArguments.ShouldExist) + len(cfg.KernelArguments.ShouldNotExist)},
+	}
+	for _, section := range sections {
+		if section.count > 0 {
+			skipped = append(skipped, section.name)
+		}
+	}
+	return skipped
+}
+
+// appliedContentFromIgnition lists the files and units onceFrom writes from an
+// Ignition config.
+func appliedContentFromIgnition(cfg ign3types.Config) OnceFromAppliedContent {
+	applied := OnceFromAppliedContent{}
+	for _, f := range cfg.Storage.Files {
+		applied.Files = append(applied.Files, f.Path)
+	}
+	for _, u := range cfg.Systemd.Units {
+		applied.Units = append(applied.Units, u.Name)
+	}
+	return applied
+}
+
+// fillOnceFromReportForMachineConfig records what a MachineConfig run applies.
+func fillOnceFromReportForMachineConfig(mc *mcfgv1.MachineConfig, report *OnceFromReport) {
+	ignConfig, err := ctrlcommon.ParseAndConvertConfig(mc.Spec.Config.Raw)
+	if err == nil {
+		report.Applied = appliedContentFromIgnition(ignConfig)
+		// Unlike the pure Ignition case, the update path handles SSH keys for
+		// the core user, so only report the sections it ignores.
+		for _, section := range ignitionSectionsSkippedByOnceFrom(ignConfig) {
+			if section != "passwd.users" {
+				report.Skipped = append(report.Skipped, section)
+			}
+		}
+	}
+	report.Applied.KernelArguments = mc.Spec.KernelArguments
+	report.Applied.Extensions = mc.Spec.Extensions
+	report.Applied.KernelType = mc.Spec.KernelType
+	report.Applied.OSImageURL = mc.Spec.OSImageURL
+}
+
+// writeOnceFromReport atomically writes the report as JSON to path.
+func writeOnceFromReport(path string, report *OnceFromReport) error {
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshaling onceFrom report: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("creating onceFrom report directory: %w", err)
+	}
+	if err := renameio.WriteFile(path, data, 0o644); err != nil {
+		return fmt.Errorf("writing onceFrom report: %w", err)
+	}
+	klog.Infof("Wrote onceFrom report to %s", path)
+	return nil
+}
@@ -0,0 +1,164 @@
+package daemon
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+
+	ign3types "github.com/coreos/ignition/v2/config/v3_4/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writePublicKey(t *testing.T, dir string, pub interface{}) string {
+	t.Helper()
+
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	require.NoError(t, err)
+
+	path := filepath.Join(dir, "key.pub")
+	require.NoError(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0o644))
+	return path
+}
+
+func TestVerifyOnceFromContent(t *testing.T) {
+	content := []byte(`{"ignition":{"version":"3.4.0"}}`)
+	sum := sha256.Sum256(content)
+	checksum := hex.EncodeToString(sum[:])
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	ecSig, err := ecdsa.SignASN1(rand.Reader, ecKey, sum[:])
+	require.NoError(t, err)
+
+	edPub, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	edSig := ed25519.Sign(edKey, content)
+
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name              string
+		opts              func(t *testing.T, dir, source string) OnceFromOptions
+		errExpected       bool
+		checksumVerified  bool
+		signatureVerified bool
+	}{
+		{
+			name: "no verification requested",
+			opts: func(*testing.T, string, string) OnceFromOptions {
+				return OnceFromOptions{}
+			},
+		},
+		{
+			name: "matching checksum",
+			opts: func(*testing.T, string, string) OnceFromOptions {
+				return OnceFromOptions{SHA256: checksum}
+			},
+			checksumVerified: true,
+		},
+		{
+			name: "mismatched checksum",
+			opts: func(*testing.T, string, string) OnceFromOptions {
+				return OnceFromOptions{SHA256: "deadbeef"}
+			},
+			errExpected: true,
+		},
+		{
+			name: "base64 ECDSA signature next to source",
+			opts: func(t *testing.T, dir, source string) OnceFromOptions {
+				require.NoError(t, os.WriteFile(source+onceFromSignatureSuffix, []byte(base64.StdEncoding.EncodeToString(ecSig)), 0o644))
+				return OnceFromOptions{PublicKeyPath: writePublicKey(t, dir, &ecKey.PublicKey)}
+			},
+			signatureVerified: true,
+		},
+		{
+			name: "raw Ed25519 signature at explicit path",
+			opts: func(t *testing.T, dir, _ string) OnceFromOptions {
+				sigPath := filepath.Join(dir, "custom.sig")
+				require.NoError(t, os.WriteFile(sigPath, edSig, 0o644))
+				return OnceFromOptions{SHA256: checksum, PublicKeyPath: writePublicKey(t, dir, edPub), Signature: sigPath}
+			},
+			checksumVerified:  true,
+			signatureVerified: true,
+		},
+		{
+			name: "signature from another key",
+			opts: func(t *testing.T, dir, source string) OnceFromOptions {
+				require.NoError(t, os.WriteFile(source+onceFromSignatureSuffix, ecSig, 0o644))
+				return OnceFromOptions{PublicKeyPath: writePublicKey(t, dir, &otherKey.PublicKey)}
+			},
+			errExpected: true,
+		},
+		{
+			name: "missing signature",
+			opts: func(t *testing.T, dir, _ string) OnceFromOptions {
+				return OnceFromOptions{PublicKeyPath: writePublicKey(t, dir, &ecKey.PublicKey)}
+			},
+			errExpected: true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			dir := t.TempDir()
+			source := filepath.Join(dir, "config.ign")
+			require.NoError(t, os.WriteFile(source, content, 0o644))
+
+			fetched, err := fetchOnceFromContent(source)
+			require.NoError(t, err)
+
+			report := &OnceFromReport{Source: source}
+			err = verifyOnceFromContent(source, fetched, testCase.opts(t, dir, source), report)
+			if testCase.errExpected {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+
+			assert.Equal(t, checksum, report.SHA256)
+			assert.Equal(t, testCase.checksumVerified, report.ChecksumVerified)
+			assert.Equal(t, testCase.signatureVerified, report.SignatureVerified)
+		})
+	}
+}
+
+func TestOnceFromReportForIgnition(t *testing.T) {
+	cfg := ign3types.Config{}
+	cfg.Storage.Files = []ign3types.File{{Node: ign3types.Node{Path: "/etc/foo"}}}
+	cfg.Storage.Links = []ign3types.Link{{Node: ign3types.Node{Path: "/etc/bar"}}}
+	cfg.Systemd.Units = []ign3types.Unit{{Name: "foo.service"}}
+
+	report := &OnceFromReport{
+		Source:     "/tmp/config.ign",
+		ConfigType: "Ignition",
+		Applied:    appliedContentFromIgnition(cfg),
+		Skipped:    ignitionSectionsSkippedByOnceFrom(cfg),
+	}
+
+	assert.Equal(t, []string{"/etc/foo"}, report.Applied.Files)
+	assert.Equal(t, []string{"foo.service"}, report.Applied.Units)
+	assert.Equal(t, []string{"storage.links"}, report.Skipped)
+
+	path := filepath.Join(t.TempDir(), "reports", "report.json")
+	require.NoError(t, writeOnceFromReport(path, report))
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+
+	written := &OnceFromReport{}
+	require.NoError(t, json.Unmarshal(data, written))
+	assert.Equal(t, report, written)
+}
@@ -136,25 +136,29 @@ func (r *RpmOstreeClient) GetBootedOSImageURL() (string, string, string, error)
 		return "", "", "", err
 	}
 
+	osImageURL := getDeploymentOSImageURL(bootedDeployment)
+
+	baseChecksum := bootedDeployment.GetBaseChecksum()
+	return osImageURL, bootedDeployment.Version, baseChecksum, nil
+}
+
+// getDeploymentOSImageURL returns the image URL of an rpm-ostree deployment, or
+// the empty string if it was not deployed from a pivot:// origin or a container image.
+func getDeploymentOSImageURL(deployment *rpmostreeclient.Deployment) string {
 	// the canonical image URL is stored in the custom origin field.
 	osImageURL := ""
-	if len(bootedDeployment.CustomOrigin) > 0 {
-		if strings.HasPrefix(bootedDeployment.CustomOrigin[0], "pivot://") {
-			osImageURL = bootedDeployment.CustomOrigin[0][len("pivot://"):]
+	if len(deployment.CustomOrigin) > 0 {
+		if strings.HasPrefix(deployment.CustomOrigin[0], "pivot://") {
+			osImageURL = deployment.CustomOrigin[0][len("pivot://"):]
 		}
 	}
 
 	// we have container images now, make sure we can parse those too
-	if bootedDeployment.ContainerImageReference != "" {
-		// right now they start with "ostree-unverified-registry:", so scrape that off
-		tokens := strings.SplitN(bootedDeployment.ContainerImageReference, ":", 2)
-		if len(tokens) > 1 {
-			osImageURL = tokens[1]
-		}
+	if deployment.ContainerImageReference != "" {
+		osImageURL = trimLayeredImageReference(deployment.ContainerImageReference)
 	}
 
-	baseChecksum := bootedDeployment.GetBaseChecksum()
-	return osImageURL, bootedDeployment.Version, baseChecksum, nil
+	return osImageURL
 }
 
 func podmanInspect(imgURL string) (imgdata *imageInspection, err error) {
@@ -230,10 +234,19 @@ func RpmOstreeIsNewEnoughForLayering() (bool, error) {
 	return false, nil
 }
 
-// RebaseLayered rebases system or errors if already rebased
-func (r *RpmOstreeClient) RebaseLayered(imgURL string) (err error) {
-	klog.Infof("Executing rebase to %s", imgURL)
-	return runRpmOstree("rebase", "--experimental", "ostree-unverified-registry:"+imgURL)
+// RebaseLayered rebases system or errors if already rebased. The containers
+// policy is only enforced if enforcePolicy is set; otherwise the image is
+// pulled unverified.
+func (r *RpmOstreeClient) RebaseLayered(imgURL string, enforcePolicy bool) (err error) {
+	imgRef := ostreeUnverifiedRegistryPrefix + imgURL
+	if enforcePolicy {
+		if imgRef, err = getLayeredImageReference(containersPolicyPath, imgURL); err != nil {
+			return err
+		}
+	}
+
+	klog.Infof("Executing rebase to %s", imgRef)
+	return runRpmOstree("rebase", "--experimental", imgRef)
 }
 
 // useKubeletConfigSecrets gives the rpm-ostree client access to secrets in the kubelet config.json by symlinking so that
@@ -11,6 +11,7 @@ import (
 	"os/user"
 	"path/filepath"
 	"reflect"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -49,6 +50,9 @@ const (
 	postConfigChangeActionReloadCrio = "reload crio"
 	// Rebooting is still the default scenario for any other change
 	postConfigChangeActionReboot = "reboot"
+	// The "restart <unit>" actions restart the systemd unit applying files rendered from node settings,
+	// see ctrlcommon.RestartServicesAnnotationKey
+	postConfigChangeActionRestartPrefix = "restart "
 
 	// GPGNoRebootPath is the path MCO expects will contain GPG key updates. MCO will attempt to only reload crio for
 	// changes to this path. Note that other files added to the parent directory will not be handled specially
@@ -67,6 +71,10 @@ func reloadService(name string) error {
 	return runCmdSync("systemctl", "reload", name)
 }
 
+func restartService(name string) error {
+	return runCmdSync("systemctl", "restart", name)
+}
+
 // performPostConfigChangeAction takes action based on what postConfigChangeAction has been asked.
 // For non-reboot action, it applies configuration, updates node's config and state.
 // In the end uncordon node to schedule workload.
@@ -74,6 +82,7 @@ func reloadService(name string) error {
 func (dn *Daemon) performPostConfigChangeAction(postConfigChangeActions []string, configName string) error {
 	if ctrlcommon.InSlice(postConfigChangeActionReboot, postConfigChangeActions) {
 		logSystem("Rebooting node")
+		dn.recordUpdatePhase(configName, mcfgv1.NodeUpdatePhaseRebooting)
 		return dn.reboot(fmt.Sprintf("Node will reboot into config %s", configName))
 	}
 
@@ -100,6 +109,25 @@ func (dn *Daemon) performPostConfigChangeAction(postConfigChangeActions []string
 		logSystem("%s config reloaded successfully! Desired config %s has been applied, skipping reboot", serviceName, configName)
 	}
 
+	for _, action := range postConfigChangeActions {
+		if !strings.HasPrefix(action, postConfigChangeActionRestartPrefix) {
+			continue
+		}
+		serviceName := strings.TrimPrefix(action, postConfigChangeActionRestartPrefix)
+
+		if err := restartService(serviceName); err != nil {
+			if dn.nodeWriter != nil {
+				dn.nodeWriter.Eventf(corev1.EventTypeWarning, "FailedServiceRestart", fmt.Sprintf("Restarting %s service failed. Error: %v", serviceName, err))
+			}
+			return fmt.Errorf("could not apply update: restarting %s failed. Error: %w", serviceName, err)
+		}
+
+		if dn.nodeWriter != nil {
+			dn.nodeWriter.Eventf(corev1.EventTypeNormal, "SkipReboot", "Config changes do not require reboot. Service %s was restarted.", serviceName)
+		}
+		logSystem("%s restarted successfully! Desired config %s has been applied, skipping reboot", serviceName, configName)
+	}
+
 	// We are here, which means reboot was not needed to apply the configuration.
 
 	// Get current state of node, in case of an error reboot
@@ -319,12 +347,15 @@ func (dn *CoreOSDaemon) applyOSChanges(mcDiff machineConfigDiff, oldConfig, newC
 				klog.Errorf("Failed to create event with reason 'OSUpdateStaged': %v", err)
 			}
 		}
+		dn.recordUpdatePhase(newConfig.GetName(), mcfgv1.NodeUpdatePhaseOSStaged)
 	}
 
 	return nil
 }
 
-func calculatePostConfigChangeActionFromFileDiffs(diffFileSet []string) (actions []string) {
+// calculatePostConfigChangeActionFromFileDiffs returns the actions for the changed files. restartServices maps the
+// paths of files rendered from node settings to the systemd unit to restart when they change.
+func calculatePostConfigChangeActionFromFileDiffs(diffFileSet []string, restartServices map[string]string) (actions []string) {
 	filesPostConfigChangeActionNone := []string{
 		caBundleFilePath,
 		"/var/lib/kubelet/config.json",
@@ -336,20 +367,48 @@ func calculatePostConfigChangeActionFromFileDiffs(diffFileSet []string) (actions
 	}
 
 	actions = []string{postConfigChangeActionNone}
+	addAction := func(action string) {
+		if actions[0] == postConfigChangeActionNone {
+			actions = nil
+		}
+		if !ctrlcommon.InSlice(action, actions) {
+			actions = append(actions, action)
+		}
+	}
 	for _, path := range diffFileSet {
 		if ctrlcommon.InSlice(path, filesPostConfigChangeActionNone) {
 			continue
 		} else if ctrlcommon.InSlice(path, filesPostConfigChangeActionReloadCrio) {
-			actions = []string{postConfigChangeActionReloadCrio}
+			addAction(postConfigChangeActionReloadCrio)
+		} else if unit, ok := restartServices[path]; ok {
+			addAction(postConfigChangeActionRestartPrefix + unit)
 		} else {
 			actions = []string{postConfigChangeActionReboot}
 			return
 		}
 	}
+	// The file diffs come in random order.
+	sort.Strings(actions)
 	return
 }
 
-func calculatePostConfigChangeAction(diff *machineConfigDiff, diffFileSet []string) ([]string, error) {
+// getRestartServices returns the units to restart by path when updating from
+// oldIgnConfig to the Ignition config of newConfig. Only the units of the new
+// config count: files removed from node settings, e.g. a kernel module to load,
+// are only undone by a reboot, and so are entries removed from sysctl and
+// modules-load files which are kept.
+func getRestartServices(oldIgnConfig, newIgnConfig *ign3types.Config, newConfig *mcfgv1.MachineConfig) (map[string]string, error) {
+	restartServices, err := ctrlcommon.GetRestartServices(newConfig)
+	if err != nil {
+		return nil, err
+	}
+	if err := ctrlcommon.RemoveUnrevertedRestarts(restartServices, oldIgnConfig, newIgnConfig); err != nil {
+		return nil, err
+	}
+	return restartServices, nil
+}
+
+func calculatePostConfigChangeAction(diff *machineConfigDiff, diffFileSet []string, restartServices map[string]string) ([]string, error) {
 	// If a machine-config-daemon-force file is present, it means the user wants to
 	// move to desired state without additional validation. We will reboot the node in
 	// this case regardless of what MachineConfig diff is.
@@ -361,13 +420,18 @@ func calculatePostConfigChangeAction(diff *machineConfigDiff, diffFileSet []stri
 		return []string{postConfigChangeActionReboot}, nil
 	}
 
+	return calculatePostConfigChangeActionFromDiff(diff, diffFileSet, restartServices), nil
+}
+
+// calculatePostConfigChangeActionFromDiff returns the actions for the diff, regardless of the state of the node.
+func calculatePostConfigChangeActionFromDiff(diff *machineConfigDiff, diffFileSet []string, restartServices map[string]string) []string {
 	if diff.osUpdate || diff.kargs || diff.fips || diff.units || diff.kernelType || diff.extensions {
 		// must reboot
-		return []string{postConfigChangeActionReboot}, nil
+		return []string{postConfigChangeActionReboot}
 	}
 
 	// We don't actually have to consider ssh keys changes, which is the only section of passwd that is allowed to change
-	return calculatePostConfigChangeActionFromFileDiffs(diffFileSet), nil
+	return calculatePostConfigChangeActionFromFileDiffs(diffFileSet, restartServices)
 }
 
 // update the node to the provided node configuration.
@@ -423,7 +487,11 @@ func (dn *Daemon) update(oldConfig, newConfig *mcfgv1.MachineConfig, skipCertifi
 	logSystem("Starting update from %s to %s: %+v", oldConfigName, newConfigName, diff)
 
 	diffFileSet := ctrlcommon.CalculateConfigFileDiffs(&oldIgnConfig, &newIgnConfig)
-	actions, err := calculatePostConfigChangeAction(diff, diffFileSet)
+	restartServices, err := getRestartServices(&oldIgnConfig, &newIgnConfig, newConfig)
+	if err != nil {
+		return err
+	}
+	actions, err := calculatePostConfigChangeAction(diff, diffFileSet, restartServices)
 	if err != nil {
 		return err
 	}
@@ -445,6 +513,7 @@ func (dn *Daemon) update(oldConfig, newConfig *mcfgv1.MachineConfig, skipCertifi
 	if err := dn.updateFiles(oldIgnConfig, newIgnConfig, skipCertificateWrite); err != nil {
 		return err
 	}
+	dn.recordUpdatePhase(newConfigName, mcfgv1.NodeUpdatePhaseFilesWritten)
 
 	defer func() {
 		if retErr != nil {
@@ -673,8 +742,20 @@ func canonicalizeKernelType(kernelType string) string {
 	return ctrlcommon.KernelTypeDefault
 }
 
-// newMachineConfigDiff compares two MachineConfig objects.
+// newMachineConfigDiff compares two MachineConfig objects. The presence of the
+// force file counts as an OS update.
 func newMachineConfigDiff(oldConfig, newConfig *mcfgv1.MachineConfig) (*machineConfigDiff, error) {
+	mcDiff, err := compareMachineConfigs(oldConfig, newConfig)
+	if err != nil {
+		return nil, err
+	}
+	mcDiff.osUpdate = mcDiff.osUpdate || forceFileExists()
+	return mcDiff, nil
+}
+
+// compareMachineConfigs compares two MachineConfig objects, regardless of the
+// state of the node.
+func compareMachineConfigs(oldConfig, newConfig *mcfgv1.MachineConfig) (*machineConfigDiff, error) {
 	oldIgn, err := ctrlcommon.ParseAndConvertConfig(oldConfig.Spec.Config.Raw)
 	if err != nil {
 		return nil, fmt.Errorf("parsing old Ignition config failed with error: %w", err)
@@ -689,9 +770,8 @@ func newMachineConfigDiff(oldConfig, newConfig *mcfgv1.MachineConfig) (*machineC
 	kargsEmpty := len(oldConfig.Spec.KernelArguments) == 0 && len(newConfig.Spec.KernelArguments) == 0
 	extensionsEmpty := len(oldConfig.Spec.Extensions) == 0 && len(newConfig.Spec.Extensions) == 0
 
-	force := forceFileExists()
 	return &machineConfigDiff{
-		osUpdate:   oldConfig.Spec.OSImageURL != newConfig.Spec.OSImageURL || force,
+		osUpdate:   oldConfig.Spec.OSImageURL != newConfig.Spec.OSImageURL,
 		kargs:      !(kargsEmpty || reflect.DeepEqual(oldConfig.Spec.KernelArguments, newConfig.Spec.KernelArguments)),
 		fips:       oldConfig.Spec.FIPS != newConfig.Spec.FIPS,
 		passwd:     !reflect.DeepEqual(oldIgn.Passwd, newIgn.Passwd),
@@ -711,20 +791,42 @@ func newMachineConfigDiff(oldConfig, newConfig *mcfgv1.MachineConfig) (*machineC
 // directories, links, and systemd units sections of the included ignition
 // config currently.
 func reconcilable(oldConfig, newConfig *mcfgv1.MachineConfig) (*machineConfigDiff, error) {
+	if err := reconcilableIgnition(oldConfig, newConfig); err != nil {
+		return nil, err
+	}
+
+	// FIPS section
+	// We do not allow update to FIPS for a running cluster, so any changes here will be an error
+	if err := checkFIPS(oldConfig, newConfig); err != nil {
+		return nil, err
+	}
+
+	// we made it through all the checks. reconcile away!
+	klog.V(2).Info("Configs are reconcilable")
+	mcDiff, err := newMachineConfigDiff(oldConfig, newConfig)
+	if err != nil {
+		return nil, fmt.Errorf("error creating machineConfigDiff: %w", err)
+	}
+	return mcDiff, nil
+}
+
+// reconcilableIgnition checks that the only changes to the Ignition configs
+// are ones we know how to do in-place.
+func reconcilableIgnition(oldConfig, newConfig *mcfgv1.MachineConfig) error {
 	// The parser will try to translate versions less than maxVersion to maxVersion, or output an err.
 	// The ignition output in case of success will always have maxVersion
 	oldIgn, err := ctrlcommon.ParseAndConvertConfig(oldConfig.Spec.Config.Raw)
 	if err != nil {
-		return nil, fmt.Errorf("parsing old Ignition config failed with error: %w", err)
+		return fmt.Errorf("parsing old Ignition config failed with error: %w", err)
 	}
 	newIgn, err := ctrlcommon.ParseAndConvertConfig(newConfig.Spec.Config.Raw)
 	if err != nil {
-		return nil, fmt.Errorf("parsing new Ignition config failed with error: %w", err)
+		return fmt.Errorf("parsing new Ignition config failed with error: %w", err)
 	}
 
 	// Check if this is a generally valid Ignition Config
 	if err := ctrlcommon.ValidateIgnition(newIgn); err != nil {
-		return nil, err
+		return err
 	}
 
 	// Passwd section
@@ -736,21 +838,21 @@ func reconcilable(oldConfig, newConfig *mcfgv1.MachineConfig) (*machineConfigDif
 
 	if passwdChanged {
 		if !reflect.DeepEqual(oldIgn.Passwd.Groups, newIgn.Passwd.Groups) {
-			return nil, fmt.Errorf("ignition Passwd Groups section contains changes")
+			return fmt.Errorf("ignition Passwd Groups section contains changes")
 		}
 		if !reflect.DeepEqual(oldIgn.Passwd.Users, newIgn.Passwd.Users) {
 			// there is an update to Users, we must verify that it is ONLY making an acceptable
 			// change to the SSHAuthorizedKeys for the user "core"
 			for _, user := range newIgn.Passwd.Users {
 				if user.Name != constants.CoreUserName {
-					return nil, fmt.Errorf("ignition passwd user section contains unsupported changes: non-core user")
+					return fmt.Errorf("ignition passwd user section contains unsupported changes: non-core user")
 				}
 			}
 			// We don't want to panic if the "new" users is empty, and it's still reconcilable because the absence of a user here does not mean "remove the user from the system"
 			if len(newIgn.Passwd.Users) != 0 {
 				klog.Infof("user data to be verified before ssh update: %v", newIgn.Passwd.Users[len(newIgn.Passwd.Users)-1])
 				if err := verifyUserFields(newIgn.Passwd.Users[len(newIgn.Passwd.Users)-1]); err != nil {
-					return nil, err
+					return err
 				}
 			}
 		}
@@ -760,7 +862,7 @@ func reconcilable(oldConfig, newConfig *mcfgv1.MachineConfig) (*machineConfigDif
 
 	// ignition now supports kernel args, but the MCO doesn't implement them yet
 	if !reflect.DeepEqual(oldIgn.KernelArguments, newIgn.KernelArguments) {
-		return nil, fmt.Errorf("ignition kargs section contains changes")
+		return fmt.Errorf("ignition kargs section contains changes")
 	}
 
 	// Storage section
@@ -768,23 +870,23 @@ func reconcilable(oldConfig, newConfig *mcfgv1.MachineConfig) (*machineConfigDif
 	// we can only reconcile files right now. make sure the sections we can't
 	// fix aren't changed.
 	if !reflect.DeepEqual(oldIgn.Storage.Disks, newIgn.Storage.Disks) {
-		return nil, fmt.Errorf("ignition disks section contains changes")
+		return fmt.Errorf("ignition disks section contains changes")
 	}
 	if !reflect.DeepEqual(oldIgn.Storage.Filesystems, newIgn.Storage.Filesystems) {
-		return nil, fmt.Errorf("ignition filesystems section contains changes")
+		return fmt.Errorf("ignition filesystems section contains changes")
 	}
 	if !reflect.DeepEqual(oldIgn.Storage.Raid, newIgn.Storage.Raid) {
-		return nil, fmt.Errorf("ignition raid section contains changes")
+		return fmt.Errorf("ignition raid section contains changes")
 	}
 	if !reflect.DeepEqual(oldIgn.Storage.Directories, newIgn.Storage.Directories) {
-		return nil, fmt.Errorf("ignition directories section contains changes")
+		return fmt.Errorf("ignition directories section contains changes")
 	}
 	if !reflect.DeepEqual(oldIgn.Storage.Links, newIgn.Storage.Links) {
 		// This means links have been added, as opposed as being removed as it happened with
 		// https://bugzilla.redhat.com/show_bug.cgi?id=1677198. This doesn't really change behavior
 		// since we still don't support links but we allow old MC to remove links when upgrading.
 		if len(newIgn.Storage.Links) != 0 {
-			return nil, fmt.Errorf("ignition links section contains changes")
+			return fmt.Errorf("ignition links section contains changes")
 		}
 	}
 
@@ -792,11 +894,11 @@ func reconcilable(oldConfig, newConfig *mcfgv1.MachineConfig) (*machineConfigDif
 	// have to force a reprovision since it's not idempotent
 	for _, f := range newIgn.Storage.Files {
 		if len(f.Append) > 0 {
-			return nil, fmt.Errorf("ignition file %v includes append", f.Path)
+			return fmt.Errorf("ignition file %v includes append", f.Path)
 		}
 		// We also disallow writing some special files
 		if f.Path == constants.MachineConfigDaemonForceFile {
-			return nil, fmt.Errorf("cannot create %s via Ignition", f.Path)
+			return fmt.Errorf("cannot create %s via Ignition", f.Path)
 		}
 	}
 
@@ -804,19 +906,7 @@ func reconcilable(oldConfig, newConfig *mcfgv1.MachineConfig) (*machineConfigDif
 
 	// we can reconcile any state changes in the systemd section.
 
-	// FIPS section
-	// We do not allow update to FIPS for a running cluster, so any changes here will be an error
-	if err := checkFIPS(oldConfig, newConfig); err != nil {
-		return nil, err
-	}
-
-	// we made it through all the checks. reconcile away!
-	klog.V(2).Info("Configs are reconcilable")
-	mcDiff, err := newMachineConfigDiff(oldConfig, newConfig)
-	if err != nil {
-		return nil, fmt.Errorf("error creating machineConfigDiff: %w", err)
-	}
-	return mcDiff, nil
+	return nil
 }
 
 // verifyUserFields returns nil for the user Name = "core" if 1 or more SSHKeys exist for
@@ -1844,13 +1934,20 @@ func (dn *Daemon) updateLayeredOS(config *mcfgv1.MachineConfig) error {
 		if err := dn.InplaceUpdateViaNewContainer(newURL); err != nil {
 			return err
 		}
-	} else if err := dn.NodeUpdaterClient.RebaseLayered(newURL); err != nil {
+	} else if err := dn.NodeUpdaterClient.RebaseLayered(newURL, dn.isOnClusterBuiltImage(newURL)); err != nil {
 		return fmt.Errorf("failed to update OS to %s : %w", newURL, err)
 	}
 
 	return nil
 }
 
+// isOnClusterBuiltImage returns whether imgURL is an image built on-cluster,
+// which are rolled out through the desired image annotation of the node. Only
+// for these images the containers policy is enforced, see RebaseLayered.
+func (dn *Daemon) isOnClusterBuiltImage(imgURL string) bool {
+	return dn.node != nil && dn.node.Annotations[constants.DesiredImageAnnotationKey] == imgURL
+}
+
 // Synchronously invoke a command, writing its stdout to our stdout,
 // and gathering stderr into a buffer which will be returned in err
 // in case of error.
@@ -1914,6 +2011,7 @@ func (dn *Daemon) reboot(rationale string) error {
 	dn.Close()
 
 	if dn.skipReboot {
+		dn.rebootSkipped = true
 		return nil
 	}
 
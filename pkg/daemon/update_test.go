@@ -665,6 +665,13 @@ func TestCalculatePostConfigChangeAction(t *testing.T) {
 		"policy2":         ctrlcommon.NewIgnFile("/etc/containers/policy.json", "policy2"),
 		"containers-gpg1": ctrlcommon.NewIgnFile("/etc/machine-config-daemon/no-reboot/containers-gpg.pub", "containers-gpg1"),
 		"containers-gpg2": ctrlcommon.NewIgnFile("/etc/machine-config-daemon/no-reboot/containers-gpg.pub", "containers-gpg2"),
+		"sysctl1":         ctrlcommon.NewIgnFile("/etc/sysctl.d/99-mco-99-test.conf", "vm.swappiness = 10\n"),
+		"sysctl2":         ctrlcommon.NewIgnFile("/etc/sysctl.d/99-mco-99-test.conf", "vm.swappiness = 20\n"),
+		"sysctl3":         ctrlcommon.NewIgnFile("/etc/sysctl.d/99-mco-99-test.conf", "vm.swappiness = 20\nnet.ipv4.ip_forward = 1\n"),
+		"modules1":        ctrlcommon.NewIgnFile("/etc/modules-load.d/mco-99-test.conf", "br_netfilter\n"),
+		"modules2":        ctrlcommon.NewIgnFile("/etc/modules-load.d/mco-99-test.conf", "br_netfilter\nip_vs\n"),
+		"chrony1":         ctrlcommon.NewIgnFile("/etc/chrony.conf", "server a iburst\n"),
+		"chrony2":         ctrlcommon.NewIgnFile("/etc/chrony.conf", "server b iburst\n"),
 	}
 
 	tests := []struct {
@@ -744,6 +751,42 @@ func TestCalculatePostConfigChangeAction(t *testing.T) {
 			newConfig:      helpers.NewMachineConfig("01-test", nil, "dummy://", []ign3types.File{files["containers-gpg2"]}),
 			expectedAction: []string{postConfigChangeActionReloadCrio},
 		},
+		{
+			// test that changing node settings restarts their units
+			oldConfig:      helpers.NewMachineConfig("00-test", nil, "dummy://", []ign3types.File{files["sysctl1"], files["chrony1"], files["registries1"]}),
+			newConfig:      withRestartServices(helpers.NewMachineConfig("01-test", nil, "dummy://", []ign3types.File{files["sysctl2"], files["chrony2"], files["registries2"]})),
+			expectedAction: []string{postConfigChangeActionReloadCrio, postConfigChangeActionRestartPrefix + "chronyd.service", postConfigChangeActionRestartPrefix + "systemd-sysctl.service"},
+		},
+		{
+			// test that node settings files without a unit in the new config are reboot
+			oldConfig:      helpers.NewMachineConfig("00-test", nil, "dummy://", []ign3types.File{files["sysctl1"]}),
+			newConfig:      helpers.NewMachineConfig("01-test", nil, "dummy://", []ign3types.File{files["sysctl2"]}),
+			expectedAction: []string{postConfigChangeActionReboot},
+		},
+		{
+			// test that adding a sysctl restarts systemd-sysctl
+			oldConfig:      helpers.NewMachineConfig("00-test", nil, "dummy://", []ign3types.File{files["sysctl1"]}),
+			newConfig:      withRestartServices(helpers.NewMachineConfig("01-test", nil, "dummy://", []ign3types.File{files["sysctl3"]})),
+			expectedAction: []string{postConfigChangeActionRestartPrefix + "systemd-sysctl.service"},
+		},
+		{
+			// test that removing a sysctl is reboot, systemd-sysctl does not reset it
+			oldConfig:      helpers.NewMachineConfig("00-test", nil, "dummy://", []ign3types.File{files["sysctl3"]}),
+			newConfig:      withRestartServices(helpers.NewMachineConfig("01-test", nil, "dummy://", []ign3types.File{files["sysctl2"]})),
+			expectedAction: []string{postConfigChangeActionReboot},
+		},
+		{
+			// test that adding a kernel module restarts systemd-modules-load
+			oldConfig:      helpers.NewMachineConfig("00-test", nil, "dummy://", []ign3types.File{files["modules1"]}),
+			newConfig:      withRestartServices(helpers.NewMachineConfig("01-test", nil, "dummy://", []ign3types.File{files["modules2"]})),
+			expectedAction: []string{postConfigChangeActionRestartPrefix + "systemd-modules-load.service"},
+		},
+		{
+			// test that removing a kernel module is reboot, systemd-modules-load does not unload it
+			oldConfig:      helpers.NewMachineConfig("00-test", nil, "dummy://", []ign3types.File{files["modules2"]}),
+			newConfig:      withRestartServices(helpers.NewMachineConfig("01-test", nil, "dummy://", []ign3types.File{files["modules1"]})),
+			expectedAction: []string{postConfigChangeActionReboot},
+		},
 	}
 
 	for idx, test := range tests {
@@ -761,7 +804,11 @@ func TestCalculatePostConfigChangeAction(t *testing.T) {
 				t.Errorf("error creating machineConfigDiff: %v", err)
 			}
 			diffFileSet := ctrlcommon.CalculateConfigFileDiffs(&oldIgnConfig, &newIgnConfig)
-			calculatedAction, err := calculatePostConfigChangeAction(mcDiff, diffFileSet)
+			restartServices, err := getRestartServices(&oldIgnConfig, &newIgnConfig, test.newConfig)
+			if err != nil {
+				t.Errorf("error getting restart services: %v", err)
+			}
+			calculatedAction, err := calculatePostConfigChangeAction(mcDiff, diffFileSet, restartServices)
 
 			if !reflect.DeepEqual(test.expectedAction, calculatedAction) {
 				t.Errorf("Failed calculating config change action: expected: %v but result is: %v. Error: %v", test.expectedAction, calculatedAction, err)
@@ -770,6 +817,14 @@ func TestCalculatePostConfigChangeAction(t *testing.T) {
 	}
 }
 
+// withRestartServices records the units of the node settings files used in the tests on config.
+func withRestartServices(config *mcfgv1.MachineConfig) *mcfgv1.MachineConfig {
+	config.Annotations = map[string]string{
+		ctrlcommon.RestartServicesAnnotationKey: `{"/etc/chrony.conf":"chronyd.service","/etc/sysctl.d/99-mco-99-test.conf":"systemd-sysctl.service","/etc/modules-load.d/mco-99-test.conf":"systemd-modules-load.service"}`,
+	}
+	return config
+}
+
 // checkReconcilableResults is a shortcut for verifying results that should be reconcilable
 func checkReconcilableResults(t *testing.T, key string, reconcilableError error) {
 	if reconcilableError != nil {
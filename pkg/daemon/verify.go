@@ -0,0 +1,442 @@
+package daemon
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	ign2types "github.com/coreos/ignition/config/v2_2/types"
+	ign3types "github.com/coreos/ignition/v2/config/v3_4/types"
+	rpmostreeclient "github.com/coreos/rpmostree-client-go/pkg/client"
+	"k8s.io/klog/v2"
+
+	mcoResourceRead "github.com/openshift/machine-config-operator/lib/resourceread"
+	mcfgv1 "github.com/openshift/machine-config-operator/pkg/apis/machineconfiguration.openshift.io/v1"
+	ctrlcommon "github.com/openshift/machine-config-operator/pkg/controller/common"
+	"github.com/openshift/machine-config-operator/pkg/daemon/osrelease"
+)
+
+// MismatchType identifies which part of a MachineConfig does not match the node.
+type MismatchType string
+
+const (
+	// MismatchTypeFile is a file whose contents or mode differ.
+	MismatchTypeFile MismatchType = "file"
+	// MismatchTypeUnit is a systemd unit whose contents or mask differ.
+	MismatchTypeUnit MismatchType = "unit"
+	// MismatchTypeDropin is a systemd dropin whose contents differ.
+	MismatchTypeDropin MismatchType = "dropin"
+	// MismatchTypeKernelArguments is a set of missing or unexpected kernel arguments.
+	MismatchTypeKernelArguments MismatchType = "kernelArguments"
+	// MismatchTypeExtensions is a set of extensions which are not installed.
+	MismatchTypeExtensions MismatchType = "extensions"
+	// MismatchTypePackages is a set of packages layered outside of the MCO.
+	MismatchTypePackages MismatchType = "packages"
+	// MismatchTypeKernelType is a kernel type which is not the one deployed.
+	MismatchTypeKernelType MismatchType = "kernelType"
+	// MismatchTypeOSImage is an OS image which is not the one booted.
+	MismatchTypeOSImage MismatchType = "osImageURL"
+)
+
+// MismatchReason tells how a file, unit or dropin differs.
+type MismatchReason string
+
+const (
+	// MismatchReasonMissing is a file which does not exist.
+	MismatchReasonMissing MismatchReason = "missing"
+	// MismatchReasonMode is a file whose mode differs.
+	MismatchReasonMode MismatchReason = "mode"
+	// MismatchReasonContent is a file whose contents differ.
+	MismatchReasonContent MismatchReason = "content"
+)
+
+// Mismatch describes a single difference between the node and a MachineConfig.
+type Mismatch struct {
+	Type    MismatchType   `json:"type"`
+	Path    string         `json:"path,omitempty"`
+	Reason  MismatchReason `json:"reason,omitempty"`
+	Message string         `json:"message"`
+}
+
+// VerifyReport is the result of verifying a node against a MachineConfig.
+type VerifyReport struct {
+	// MachineConfig is the name of the MachineConfig the node was verified against.
+	MachineConfig string `json:"machineConfig"`
+	// Mismatches lists every difference that was found.
+	Mismatches []Mismatch `json:"mismatches"`
+	// Errors lists the checks that could not be run.
+	Errors []string `json:"errors,omitempty"`
+	// Warnings lists differences which do not make the node diverge from the
+	// MachineConfig, such as packages layered outside of the MCO.
+	Warnings []Mismatch `json:"warnings,omitempty"`
+	// SkippedFiles lists the files whose contents are encrypted and were not
+	// compared, because the MachineConfig could not be decrypted.
+	SkippedFiles []string `json:"skippedFiles,omitempty"`
+}
+
+// Matches returns true if all checks ran and no mismatch was found.
+func (r *VerifyReport) Matches() bool {
+	return len(r.Mismatches) == 0 && len(r.Errors) == 0
+}
+
+// Verify compares the node against the given MachineConfig without mutating
+// it. Unlike validateOnDiskState, it does not stop at the first mismatch and
+// reports every difference it finds.
+func (dn *Daemon) Verify(mc *mcfgv1.MachineConfig) *VerifyReport {
+	report := &VerifyReport{
+		MachineConfig: mc.GetName(),
+		Mismatches:    []Mismatch{},
+	}
+
+	mc, report.SkippedFiles = withoutEncryptedFiles(mc)
+
+	onDisk, err := collectOnDiskMismatches(mc, pathSystemd)
+	if err != nil {
+		report.Errors = append(report.Errors, err.Error())
+	}
+	report.Mismatches = append(report.Mismatches, onDisk...)
+
+	if !dn.os.IsCoreOSVariant() || dn.NodeUpdaterClient == nil {
+		klog.Infof("Not booted into a CoreOS variant, skipping kernel arguments, extensions, kernel type and OS image checks")
+		return report
+	}
+
+	missing, unexpected, err := getKernelArgumentsDrift(mc.Spec.KernelArguments)
+	if err != nil {
+		report.Errors = append(report.Errors, fmt.Sprintf("could not read kernel arguments: %v", err))
+	} else {
+		report.Mismatches = append(report.Mismatches, kernelArgumentsMismatches(missing, unexpected)...)
+	}
+
+	booted, _, err := dn.NodeUpdaterClient.GetBootedAndStagedDeployment()
+	if err != nil {
+		report.Errors = append(report.Errors, fmt.Sprintf("could not get booted deployment: %v", err))
+	} else {
+		mismatches, warnings := deploymentMismatches(booted, mc, dn.os)
+		report.Mismatches = append(report.Mismatches, mismatches...)
+		report.Warnings = append(report.Warnings, warnings...)
+	}
+
+	if mc.Spec.OSImageURL != "" && !dn.checkOS(mc.Spec.OSImageURL) {
+		report.Mismatches = append(report.Mismatches, Mismatch{
+			Type:    MismatchTypeOSImage,
+			Message: fmt.Sprintf("expected target osImageURL %q, have %q (%q)", mc.Spec.OSImageURL, dn.bootedOSImageURL, dn.bootedOSCommit),
+		})
+	}
+
+	return report
+}
+
+// withoutEncryptedFiles returns a copy of mc without the files whose contents
+// are still encrypted, which would never match the node, and their paths. If
+// the config cannot be parsed, mc is returned as is for collectOnDiskMismatches
+// to report the error.
+func withoutEncryptedFiles(mc *mcfgv1.MachineConfig) (*mcfgv1.MachineConfig, []string) {
+	if !ctrlcommon.IsMachineConfigEncrypted(mc) {
+		return mc, nil
+	}
+
+	ignConfig, err := ctrlcommon.ParseAndConvertConfig(mc.Spec.Config.Raw)
+	if err != nil {
+		return mc, nil
+	}
+	skipped := encryptedFilePaths(ignConfig)
+	if len(skipped) == 0 {
+		return mc, nil
+	}
+	out, _, err := withoutFiles(mc, ignConfig, skipped)
+	if err != nil {
+		return mc, nil
+	}
+	return out, skipped
+}
+
+// deploymentMismatches compares the packages requested in an rpm-ostree
+// deployment to the extensions and kernel type of a MachineConfig. Packages
+// which are not part of any extension the MCO knows of are returned as
+// warnings: OKD layers additional packages on FCOS on purpose, see
+// generateExtensionsArgs.
+func deploymentMismatches(deployment *rpmostreeclient.Deployment, mc *mcfgv1.MachineConfig, hostos osrelease.OperatingSystem) ([]Mismatch, []Mismatch) {
+	mismatches := []Mismatch{}
+	warnings := []Mismatch{}
+	requested := make(map[string]bool)
+	hasRealtimeKernel := false
+	for _, pkg := range deployment.RequestedPackages {
+		requested[pkg] = true
+		if strings.HasPrefix(pkg, "kernel-rt-") {
+			hasRealtimeKernel = true
+		}
+	}
+
+	missingExtensions := []string{}
+	expected := make(map[string]bool)
+	supportedExtensions := getSupportedExtensions()
+	for _, ext := range mc.Spec.Extensions {
+		pkgs := []string{ext}
+		// Mirrors generateExtensionsArgs: RHCOS maps extensions to a package
+		// list while FCOS installs them one to one.
+		if hostos.IsEL() {
+			pkgs = supportedExtensions[ext]
+		}
+		missing := false
+		for _, pkg := range pkgs {
+			expected[pkg] = true
+			if !requested[pkg] {
+				missing = true
+			}
+		}
+		if missing {
+			missingExtensions = append(missingExtensions, ext)
+		}
+	}
+	if len(missingExtensions) > 0 {
+		sort.Strings(missingExtensions)
+		mismatches = append(mismatches, Mismatch{
+			Type:    MismatchTypeExtensions,
+			Message: fmt.Sprintf("extensions not installed in deployment %s: %v", deployment.ID, missingExtensions),
+		})
+	}
+
+	// The packages of extensions which are not configured, e.g. left behind
+	// by "rpm-ostree install", are drift. Other packages layered by hand are
+	// not managed by the MCO; the realtime kernel packages are covered below.
+	managed := make(map[string]bool)
+	if hostos.IsEL() {
+		for _, pkgs := range supportedExtensions {
+			for _, pkg := range pkgs {
+				managed[pkg] = true
+			}
+		}
+	}
+	unexpectedPackages := []string{}
+	unmanagedPackages := []string{}
+	for _, pkg := range deployment.RequestedPackages {
+		switch {
+		case expected[pkg] || strings.HasPrefix(pkg, "kernel-rt-"):
+		case managed[pkg]:
+			unexpectedPackages = append(unexpectedPackages, pkg)
+		default:
+			unmanagedPackages = append(unmanagedPackages, pkg)
+		}
+	}
+	if len(unexpectedPackages) > 0 {
+		sort.Strings(unexpectedPackages)
+		mismatches = append(mismatches, Mismatch{
+			Type:    MismatchTypeExtensions,
+			Message: fmt.Sprintf("packages of extensions which are not configured are layered in deployment %s: %v", deployment.ID, unexpectedPackages),
+		})
+	}
+	if len(unmanagedPackages) > 0 {
+		sort.Strings(unmanagedPackages)
+		warnings = append(warnings, Mismatch{
+			Type:    MismatchTypePackages,
+			Message: fmt.Sprintf("packages not requested by any extension are layered in deployment %s: %v", deployment.ID, unmanagedPackages),
+		})
+	}
+
+	// Only RHCOS and SCOS support switching kernels, see switchKernel.
+	if hostos.IsEL() {
+		kernelType := canonicalizeKernelType(mc.Spec.KernelType)
+		if kernelType == ctrlcommon.KernelTypeRealtime && !hasRealtimeKernel {
+			mismatches = append(mismatches, Mismatch{
+				Type:    MismatchTypeKernelType,
+				Message: fmt.Sprintf("expected kernel type %s, deployment %s uses the default kernel", kernelType, deployment.ID),
+			})
+		} else if kernelType == ctrlcommon.KernelTypeDefault && hasRealtimeKernel {
+			mismatches = append(mismatches, Mismatch{
+				Type:    MismatchTypeKernelType,
+				Message: fmt.Sprintf("expected kernel type %s, deployment %s uses the %s kernel", kernelType, deployment.ID, ctrlcommon.KernelTypeRealtime),
+			})
+		}
+	}
+
+	return mismatches, warnings
+}
+
+// stagedDeploymentMismatches reports a staged deployment whose OS image is
+// neither the booted one nor the one of the MachineConfig, e.g. after a manual
+// "rpm-ostree rebase". The booted OS image itself is validated against the
+// MachineConfig when the daemon starts.
+func stagedDeploymentMismatches(booted, staged *rpmostreeclient.Deployment, mc *mcfgv1.MachineConfig) []Mismatch {
+	if staged == nil {
+		return []Mismatch{}
+	}
+
+	stagedURL := getDeploymentOSImageURL(staged)
+	if stagedURL == "" || stagedURL == getDeploymentOSImageURL(booted) || stagedURL == mc.Spec.OSImageURL {
+		return []Mismatch{}
+	}
+
+	return []Mismatch{
+		{
+			Type:    MismatchTypeOSImage,
+			Message: fmt.Sprintf("expected osImageURL %q, deployment %s is staged with %q", mc.Spec.OSImageURL, staged.ID, stagedURL),
+		},
+	}
+}
+
+// checkDeploymentForDrift compares the rpm-ostree deployment the node will
+// boot into next, and the current kernel arguments, to the given
+// MachineConfig. Mismatches and warnings are returned as a
+// deploymentConfigDriftErr.
+func (dn *CoreOSDaemon) checkDeploymentForDrift(mc *mcfgv1.MachineConfig) error {
+	booted, staged, err := dn.NodeUpdaterClient.GetBootedAndStagedDeployment()
+	if err != nil {
+		return fmt.Errorf("could not get booted and staged deployments: %w", err)
+	}
+
+	deployment := booted
+	if staged != nil {
+		deployment = staged
+	}
+
+	mismatches, warnings := deploymentMismatches(deployment, mc, dn.os)
+	mismatches = append(mismatches, stagedDeploymentMismatches(booted, staged, mc)...)
+
+	missing, unexpected, err := getKernelArgumentsDrift(mc.Spec.KernelArguments)
+	if err != nil {
+		return fmt.Errorf("could not read kernel arguments: %w", err)
+	}
+	mismatches = append(mismatches, kernelArgumentsMismatches(missing, unexpected)...)
+
+	if len(mismatches) == 0 && len(warnings) == 0 {
+		return nil
+	}
+
+	messages := []string{}
+	for _, mismatch := range append(append([]Mismatch{}, mismatches...), warnings...) {
+		messages = append(messages, mismatch.Message)
+	}
+
+	return &deploymentConfigDriftErr{
+		error:      fmt.Errorf("deployment does not match MachineConfig %s: %s", mc.Name, strings.Join(messages, "; ")),
+		Mismatches: mismatches,
+		Warnings:   warnings,
+	}
+}
+
+// kernelArgumentsMismatches returns the mismatches for missing and unexpected
+// kernel arguments.
+func kernelArgumentsMismatches(missing, unexpected []string) []Mismatch {
+	mismatches := []Mismatch{}
+	if len(missing) > 0 {
+		mismatches = append(mismatches, Mismatch{
+			Type:    MismatchTypeKernelArguments,
+			Message: fmt.Sprintf("missing expected kernel arguments: %v", missing),
+		})
+	}
+	if len(unexpected) > 0 {
+		mismatches = append(mismatches, Mismatch{
+			Type:    MismatchTypeKernelArguments,
+			Message: fmt.Sprintf("unexpected kernel arguments: %v", unexpected),
+		})
+	}
+	return mismatches
+}
+
+// collectOnDiskMismatches runs the same checks as validateOnDiskState, but
+// checks every file, unit and dropin individually and collects all the
+// mismatches instead of returning the first one.
+func collectOnDiskMismatches(mc *mcfgv1.MachineConfig, systemdPath string) ([]Mismatch, error) {
+	ignconfigi, err := ctrlcommon.IgnParseWrapper(mc.Spec.Config.Raw)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse Ignition for validation: %w", err)
+	}
+
+	switch typedConfig := ignconfigi.(type) {
+	case ign3types.Config:
+		return collectV3Mismatches(typedConfig, systemdPath), nil
+	case ign2types.Config:
+		return collectV2Mismatches(typedConfig, systemdPath), nil
+	default:
+		return nil, fmt.Errorf("unexpected type for ignition config: %v", typedConfig)
+	}
+}
+
+func collectV3Mismatches(cfg ign3types.Config, systemdPath string) []Mismatch {
+	mismatches := []Mismatch{}
+
+	for _, f := range cfg.Storage.Files {
+		if err := checkV3Files([]ign3types.File{f}); err != nil {
+			mismatches = append(mismatches, Mismatch{Type: MismatchTypeFile, Path: f.Path, Reason: mismatchReason(err), Message: err.Error()})
+		}
+	}
+
+	for _, unit := range cfg.Systemd.Units {
+		for _, dropin := range unit.Dropins {
+			if err := checkV3Dropin(systemdPath, unit, dropin); err != nil {
+				mismatches = append(mismatches, Mismatch{
+					Type:    MismatchTypeDropin,
+					Path:    getIgn3SystemdDropinPath(systemdPath, unit, dropin),
+					Reason:  mismatchReason(err),
+					Message: err.Error(),
+				})
+			}
+		}
+
+		unitOnly := unit
+		unitOnly.Dropins = nil
+		if err := checkV3Unit(unitOnly, systemdPath); err != nil {
+			mismatches = append(mismatches, Mismatch{
+				Type:    MismatchTypeUnit,
+				Path:    getIgn3SystemdUnitPath(systemdPath, unit),
+				Reason:  mismatchReason(err),
+				Message: err.Error(),
+			})
+		}
+	}
+
+	return mismatches
+}
+
+func collectV2Mismatches(cfg ign2types.Config, systemdPath string) []Mismatch {
+	mismatches := []Mismatch{}
+
+	// Like checkV2Files, only the last entry for a given path is relevant.
+	checkedFiles := make(map[string]bool)
+	for i := len(cfg.Storage.Files) - 1; i >= 0; i-- {
+		f := cfg.Storage.Files[i]
+		if checkedFiles[f.Path] {
+			continue
+		}
+		checkedFiles[f.Path] = true
+		if err := checkV2Files([]ign2types.File{f}); err != nil {
+			mismatches = append(mismatches, Mismatch{Type: MismatchTypeFile, Path: f.Path, Reason: mismatchReason(err), Message: err.Error()})
+		}
+	}
+
+	for _, unit := range cfg.Systemd.Units {
+		for _, dropin := range unit.Dropins {
+			path := getIgn2SystemdDropinPath(systemdPath, unit, dropin)
+			if err := checkFileContentsAndMode(path, []byte(dropin.Contents), defaultFilePermissions); err != nil {
+				mismatches = append(mismatches, Mismatch{Type: MismatchTypeDropin, Path: path, Reason: mismatchReason(err), Message: err.Error()})
+			}
+		}
+
+		unitOnly := unit
+		unitOnly.Dropins = nil
+		if err := checkV2Unit(unitOnly, systemdPath); err != nil {
+			mismatches = append(mismatches, Mismatch{
+				Type:    MismatchTypeUnit,
+				Path:    getIgn2SystemdUnitPath(systemdPath, unit),
+				Reason:  mismatchReason(err),
+				Message: err.Error(),
+			})
+		}
+	}
+
+	return mismatches
+}
+
+// ReadMachineConfigFrom reads a MachineConfig from a local path or URL.
+func ReadMachineConfigFrom(location string) (*mcfgv1.MachineConfig, error) {
+	content, err := fetchOnceFromContent(location)
+	if err != nil {
+		return nil, fmt.Errorf("could not read MachineConfig from %s: %w", location, err)
+	}
+	mc, err := mcoResourceRead.ReadMachineConfigV1(content)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse MachineConfig from %s: %w", location, err)
+	}
+	return mc, nil
+}
@@ -0,0 +1,319 @@
+package daemon
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	ign3types "github.com/coreos/ignition/v2/config/v3_4/types"
+	rpmostreeclient "github.com/coreos/rpmostree-client-go/pkg/client"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vincent-petithory/dataurl"
+
+	mcfgv1 "github.com/openshift/machine-config-operator/pkg/apis/machineconfiguration.openshift.io/v1"
+	ctrlcommon "github.com/openshift/machine-config-operator/pkg/controller/common"
+	"github.com/openshift/machine-config-operator/pkg/daemon/osrelease"
+	"github.com/openshift/machine-config-operator/test/helpers"
+)
+
+func TestCollectOnDiskMismatches(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	matchingFile := filepath.Join(tmpDir, "matching")
+	require.NoError(t, os.WriteFile(matchingFile, []byte("hello"), defaultFilePermissions))
+
+	driftedFile := filepath.Join(tmpDir, "drifted")
+	require.NoError(t, os.WriteFile(driftedFile, []byte("goodbye"), defaultFilePermissions))
+
+	missingFile := filepath.Join(tmpDir, "missing")
+
+	systemdPath := filepath.Join(tmpDir, "systemd")
+	require.NoError(t, os.MkdirAll(filepath.Join(systemdPath, "foo.service.d"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(systemdPath, "foo.service"), []byte("[Unit]"), defaultFilePermissions))
+	require.NoError(t, os.WriteFile(filepath.Join(systemdPath, "foo.service.d", "10-foo.conf"), []byte("drifted"), defaultFilePermissions))
+
+	mode := int(defaultFilePermissions)
+	newFile := func(path string) ign3types.File {
+		return ign3types.File{
+			Node: ign3types.Node{Path: path},
+			FileEmbedded1: ign3types.FileEmbedded1{
+				Contents: ign3types.Resource{Source: helpers.StrToPtr(dataurl.EncodeBytes([]byte("hello")))},
+				Mode:     &mode,
+			},
+		}
+	}
+
+	ignConfig := ctrlcommon.NewIgnConfig()
+	ignConfig.Storage.Files = []ign3types.File{newFile(matchingFile), newFile(driftedFile), newFile(missingFile)}
+	ignConfig.Systemd.Units = []ign3types.Unit{
+		{
+			Name:     "foo.service",
+			Contents: helpers.StrToPtr("[Unit]"),
+			Dropins: []ign3types.Dropin{
+				{Name: "10-foo.conf", Contents: helpers.StrToPtr("expected")},
+			},
+		},
+	}
+
+	mc := helpers.CreateMachineConfigFromIgnition(ignConfig)
+
+	mismatches, err := collectOnDiskMismatches(mc, systemdPath)
+	require.NoError(t, err)
+
+	found := map[MismatchType][]string{}
+	reasons := map[string]MismatchReason{}
+	for _, mismatch := range mismatches {
+		found[mismatch.Type] = append(found[mismatch.Type], mismatch.Path)
+		reasons[mismatch.Path] = mismatch.Reason
+	}
+
+	assert.Equal(t, map[MismatchType][]string{
+		MismatchTypeFile:   {driftedFile, missingFile},
+		MismatchTypeDropin: {filepath.Join(systemdPath, "foo.service.d", "10-foo.conf")},
+	}, found)
+	assert.Equal(t, MismatchReasonContent, reasons[driftedFile])
+	assert.Equal(t, MismatchReasonMissing, reasons[missingFile])
+
+	// Mismatches on warn-only paths are passed to onWarn, the ones which
+	// degrade the node are returned with the error.
+	mc.Annotations = map[string]string{ctrlcommon.ConfigDriftPolicyAnnotationKey: `[{"path": "` + missingFile + `", "action": "Warn"}]`}
+	warned := []string{}
+	degraded, err := validateOnDiskMismatches(mc, systemdPath, func(err error) {
+		var warning *configDriftWarning
+		require.ErrorAs(t, err, &warning)
+		warned = append(warned, warning.Path)
+	})
+	var fErr *fileConfigDriftErr
+	assert.ErrorAs(t, err, &fErr)
+	assert.Equal(t, []string{missingFile}, warned)
+	degradedPaths := []string{}
+	for _, mismatch := range degraded {
+		degradedPaths = append(degradedPaths, mismatch.Path)
+	}
+	assert.Equal(t, []string{driftedFile, filepath.Join(systemdPath, "foo.service.d", "10-foo.conf")}, degradedPaths)
+}
+
+// Tests that files with contents that could not be decrypted are skipped
+// instead of being reported as drifted.
+func TestWithoutEncryptedFiles(t *testing.T) {
+	key := ctrlcommon.DeriveMachineConfigKey([]byte("content-encryption-key"), "rendered-worker-1")
+	mc := helpers.NewMachineConfig("rendered-worker-1", nil, "", []ign3types.File{
+		ctrlcommon.NewIgnFile("/var/lib/kubelet/config.json", "pull-secret"),
+		ctrlcommon.NewIgnFile("/etc/chrony.conf", "pool example.com iburst\n"),
+	})
+
+	unchanged, skipped := withoutEncryptedFiles(mc)
+	assert.Equal(t, mc, unchanged)
+	assert.Empty(t, skipped)
+
+	mc.Annotations = map[string]string{ctrlcommon.EncryptedFilesAnnotationKey: "/var/lib/kubelet/config.json"}
+	require.NoError(t, ctrlcommon.EncryptMachineConfigFiles(mc, key, []string{"/var/lib/kubelet/config.json"}))
+
+	stripped, skipped := withoutEncryptedFiles(mc)
+	assert.Equal(t, []string{"/var/lib/kubelet/config.json"}, skipped)
+	ignCfg, err := ctrlcommon.ParseAndConvertConfig(stripped.Spec.Config.Raw)
+	require.NoError(t, err)
+	require.Len(t, ignCfg.Storage.Files, 1)
+	assert.Equal(t, "/etc/chrony.conf", ignCfg.Storage.Files[0].Path)
+
+	// A decrypted copy keeps the annotation, but has nothing to skip.
+	decrypted, err := ctrlcommon.DecryptMachineConfig(mc, key)
+	require.NoError(t, err)
+	unchanged, skipped = withoutEncryptedFiles(decrypted)
+	assert.Equal(t, decrypted, unchanged)
+	assert.Empty(t, skipped)
+}
+
+func TestDeploymentMismatches(t *testing.T) {
+	rhcos, err := osrelease.LoadOSRelease("ID=rhcos\nVERSION_ID=9.2\n", "ID=rhcos\nVERSION_ID=9.2\n")
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name       string
+		packages   []string
+		mcSpec     mcfgv1.MachineConfigSpec
+		mismatches []MismatchType
+		warnings   []MismatchType
+	}{
+		{
+			name:   "default kernel, no extensions",
+			mcSpec: mcfgv1.MachineConfigSpec{},
+		},
+		{
+			name:     "extensions installed",
+			packages: []string{"usbguard", "krb5-workstation", "libkadm5"},
+			mcSpec:   mcfgv1.MachineConfigSpec{Extensions: []string{"usbguard", "kerberos"}},
+		},
+		{
+			name:       "extension partially installed",
+			packages:   []string{"krb5-workstation"},
+			mcSpec:     mcfgv1.MachineConfigSpec{Extensions: []string{"kerberos"}},
+			mismatches: []MismatchType{MismatchTypeExtensions},
+		},
+		{
+			name:       "realtime kernel expected",
+			mcSpec:     mcfgv1.MachineConfigSpec{KernelType: ctrlcommon.KernelTypeRealtime},
+			mismatches: []MismatchType{MismatchTypeKernelType},
+		},
+		{
+			name:       "realtime kernel installed by hand",
+			packages:   []string{"kernel-rt-core", "kernel-rt-modules"},
+			mcSpec:     mcfgv1.MachineConfigSpec{},
+			mismatches: []MismatchType{MismatchTypeKernelType},
+		},
+		{
+			name:       "package of an extension which is not configured",
+			packages:   []string{"usbguard", "kata-containers"},
+			mcSpec:     mcfgv1.MachineConfigSpec{Extensions: []string{"usbguard"}},
+			mismatches: []MismatchType{MismatchTypeExtensions},
+		},
+		{
+			name:     "package layered outside of the MCO",
+			packages: []string{"usbguard", "htop"},
+			mcSpec:   mcfgv1.MachineConfigSpec{Extensions: []string{"usbguard"}},
+			warnings: []MismatchType{MismatchTypePackages},
+		},
+		{
+			name:     "realtime kernel installed",
+			packages: []string{"kernel-rt-core", "kernel-rt-modules"},
+			mcSpec:   mcfgv1.MachineConfigSpec{KernelType: ctrlcommon.KernelTypeRealtime},
+		},
+	}
+
+	for _, testCase := range testCases {
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			deployment := &rpmostreeclient.Deployment{ID: "rhcos-1", RequestedPackages: testCase.packages}
+			mc := &mcfgv1.MachineConfig{Spec: testCase.mcSpec}
+
+			mismatches, warnings := deploymentMismatches(deployment, mc, rhcos)
+			types := []MismatchType{}
+			for _, mismatch := range mismatches {
+				types = append(types, mismatch.Type)
+			}
+			warningTypes := []MismatchType{}
+			for _, warning := range warnings {
+				warningTypes = append(warningTypes, warning.Type)
+			}
+
+			if testCase.mismatches == nil {
+				testCase.mismatches = []MismatchType{}
+			}
+			if testCase.warnings == nil {
+				testCase.warnings = []MismatchType{}
+			}
+			assert.Equal(t, testCase.mismatches, types)
+			assert.Equal(t, testCase.warnings, warningTypes)
+		})
+	}
+}
+
+func TestStagedDeploymentMismatches(t *testing.T) {
+	booted := &rpmostreeclient.Deployment{ID: "rhcos-1", ContainerImageReference: "ostree-unverified-registry:registry.example.com/os@sha256:booted"}
+	mc := &mcfgv1.MachineConfig{Spec: mcfgv1.MachineConfigSpec{OSImageURL: "registry.example.com/os@sha256:booted"}}
+
+	testCases := []struct {
+		name       string
+		staged     *rpmostreeclient.Deployment
+		mismatches int
+	}{
+		{
+			name: "nothing staged",
+		},
+		{
+			name:   "staged from the booted image",
+			staged: &rpmostreeclient.Deployment{ID: "rhcos-2", ContainerImageReference: booted.ContainerImageReference},
+		},
+		{
+			name:       "staged from another image",
+			staged:     &rpmostreeclient.Deployment{ID: "rhcos-2", ContainerImageReference: "ostree-unverified-registry:registry.example.com/os@sha256:other"},
+			mismatches: 1,
+		},
+		{
+			name:       "staged from a pivot origin",
+			staged:     &rpmostreeclient.Deployment{ID: "rhcos-2", CustomOrigin: []string{"pivot://registry.example.com/os@sha256:other"}},
+			mismatches: 1,
+		},
+	}
+
+	for _, testCase := range testCases {
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			mismatches := stagedDeploymentMismatches(booted, testCase.staged, mc)
+			assert.Len(t, mismatches, testCase.mismatches)
+			for _, mismatch := range mismatches {
+				assert.Equal(t, MismatchTypeOSImage, mismatch.Type)
+			}
+		})
+	}
+}
+
+func TestDiffKernelArguments(t *testing.T) {
+	current := []string{"BOOT_IMAGE=/vmlinuz", "rw", "console=tty0", "console=ttyS0", "hugepages=4", "nosmt"}
+
+	testCases := []struct {
+		name       string
+		base       []string
+		expected   []string
+		missing    []string
+		unexpected []string
+	}{
+		{
+			name:       "matching",
+			base:       []string{"BOOT_IMAGE=/vmlinuz", "rw", "console=tty0", "console=ttyS0"},
+			expected:   []string{"hugepages=4", "nosmt"},
+			missing:    []string{},
+			unexpected: []string{},
+		},
+		{
+			name:       "missing and added by hand",
+			base:       []string{"BOOT_IMAGE=/vmlinuz", "rw", "console=tty0", "console=ttyS0"},
+			expected:   []string{"hugepages=4", "audit=1"},
+			missing:    []string{"audit=1"},
+			unexpected: []string{"nosmt"},
+		},
+		{
+			name:       "repeated kernel argument added by hand",
+			base:       []string{"BOOT_IMAGE=/vmlinuz", "rw", "console=tty0"},
+			expected:   []string{"hugepages=4", "nosmt"},
+			missing:    []string{},
+			unexpected: []string{"console=ttyS0"},
+		},
+	}
+
+	for _, testCase := range testCases {
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			missing, unexpected := diffKernelArguments(current, testCase.base, testCase.expected)
+			assert.Equal(t, testCase.missing, missing)
+			assert.Equal(t, testCase.unexpected, unexpected)
+		})
+	}
+}
+
+func TestEnsureBaseKernelArguments(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "base-kernel-arguments.json")
+
+	base, err := readBaseKernelArguments(path)
+	require.NoError(t, err)
+	assert.Nil(t, base)
+
+	require.NoError(t, ensureBaseKernelArguments(path, []string{"rw", "nosmt", "hugepages=4"}, []string{"hugepages=4"}))
+	base, err = readBaseKernelArguments(path)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"rw", "nosmt"}, base)
+
+	// Kernel arguments added by hand later on are not recorded.
+	require.NoError(t, ensureBaseKernelArguments(path, []string{"rw", "nosmt", "hugepages=4", "audit=1"}, []string{"hugepages=4"}))
+	base, err = readBaseKernelArguments(path)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"rw", "nosmt"}, base)
+
+	// After rebooting into an update, the base kernel arguments of the new
+	// deployment replace the recorded ones.
+	require.NoError(t, writeBaseKernelArguments(path, []string{"rw", "mitigations=auto", "hugepages=4"}, []string{"hugepages=4"}))
+	base, err = readBaseKernelArguments(path)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"rw", "mitigations=auto"}, base)
+}
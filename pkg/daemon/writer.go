@@ -2,6 +2,7 @@ package daemon
 
 import (
 	"fmt"
+	"time"
 
 	"k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/client-go/tools/record"
@@ -14,6 +15,7 @@ import (
 	"k8s.io/client-go/tools/clientcmd"
 
 	"github.com/openshift/machine-config-operator/internal"
+	mcfgv1 "github.com/openshift/machine-config-operator/pkg/apis/machineconfiguration.openshift.io/v1"
 	ctrlcommon "github.com/openshift/machine-config-operator/pkg/controller/common"
 	"github.com/openshift/machine-config-operator/pkg/daemon/constants"
 	corev1 "k8s.io/api/core/v1"
@@ -34,9 +36,17 @@ type response struct {
 	err  error
 }
 
+// updatePhase is a phase of an update which the node entered
+type updatePhase struct {
+	targetConfig string
+	phase        mcfgv1.NodeUpdatePhase
+	time         time.Time
+}
+
 // message wraps a client and responseChannel
 type message struct {
 	annos           map[string]string
+	updatePhase     *updatePhase
 	responseChannel chan response
 }
 
@@ -51,6 +61,8 @@ type clusterNodeWriter struct {
 	// cached reference to node object - TODO change the daemon to read this too
 	node     *corev1.Node
 	recorder record.EventRecorder
+	// the update timeline last written to the node. Only accessed from Run.
+	updateTimeline *ctrlcommon.NodeUpdateTimeline
 }
 
 // NodeWriter is the interface to implement a single writer to Kubernetes to prevent race conditions
@@ -62,6 +74,7 @@ type NodeWriter interface {
 	SetDegraded(err error) error
 	SetAnnotations(annos map[string]string) (*corev1.Node, error)
 	SetDesiredDrainer(value string) error
+	SetUpdatePhase(targetConfig string, phase mcfgv1.NodeUpdatePhase) error
 	Eventf(eventtype, reason, messageFmt string, args ...interface{})
 }
 
@@ -126,7 +139,12 @@ func (nw *clusterNodeWriter) Run(stop <-chan struct{}) {
 		case <-stop:
 			return
 		case msg := <-nw.writer:
-			r := implSetNodeAnnotations(nw.client, nw.lister, nw.nodeName, msg.annos)
+			var r response
+			if msg.updatePhase != nil {
+				r = nw.implSetUpdatePhase(msg.updatePhase)
+			} else {
+				r = implSetNodeAnnotations(nw.client, nw.lister, nw.nodeName, msg.annos)
+			}
 			msg.responseChannel <- r
 		}
 	}
@@ -235,6 +253,26 @@ func (nw *clusterNodeWriter) SetDesiredDrainer(value string) error {
 	return r.err
 }
 
+// SetUpdatePhase records that the node entered the given phase of the update
+// to targetConfig in the update phases annotation, and emits an event for it.
+func (nw *clusterNodeWriter) SetUpdatePhase(targetConfig string, phase mcfgv1.NodeUpdatePhase) error {
+	respChan := make(chan response, 1)
+	nw.writer <- message{
+		updatePhase: &updatePhase{
+			targetConfig: targetConfig,
+			phase:        phase,
+			time:         time.Now(),
+		},
+		responseChannel: respChan,
+	}
+	r := <-respChan
+	if r.err != nil {
+		return r.err
+	}
+	nw.Eventf(corev1.EventTypeNormal, string(phase), "Node entered update phase %s for config %s", phase, targetConfig)
+	return nil
+}
+
 func (nw *clusterNodeWriter) Eventf(eventtype, reason, messageFmt string, args ...interface{}) {
 	if nw.node == nil {
 		return
@@ -242,6 +280,39 @@ func (nw *clusterNodeWriter) Eventf(eventtype, reason, messageFmt string, args .
 	nw.recorder.Eventf(getNodeRef(nw.node), eventtype, reason, messageFmt, args...)
 }
 
+// implSetUpdatePhase appends the phase to the update timeline of the node. The
+// timeline we last wrote is preferred over the one in the lister, which may
+// not have caught up with our previous write yet.
+func (nw *clusterNodeWriter) implSetUpdatePhase(p *updatePhase) response {
+	base := nw.updateTimeline
+	if base == nil || base.TargetConfig != p.targetConfig {
+		// We may have restarted since the last phase was recorded, e.g. after
+		// rebooting into the target config.
+		node, err := nw.lister.Get(nw.nodeName)
+		if err != nil {
+			return response{err: err}
+		}
+		base, err = ctrlcommon.GetNodeUpdateTimeline(node)
+		if err != nil {
+			klog.Warningf("Discarding update timeline: %v", err)
+		}
+	}
+
+	timeline := base.AddPhase(p.targetConfig, p.phase, p.time)
+	var encodeErr error
+	node, err := internal.UpdateNodeRetry(nw.client, nw.lister, nw.nodeName, func(node *corev1.Node) {
+		encodeErr = ctrlcommon.SetNodeUpdateTimeline(node, timeline)
+	})
+	if err == nil {
+		err = encodeErr
+	}
+	if err != nil {
+		return response{err: err}
+	}
+	nw.updateTimeline = timeline
+	return response{node: node}
+}
+
 func implSetNodeAnnotations(client corev1client.NodeInterface, lister corev1lister.NodeLister, nodeName string, m map[string]string) response {
 	node, err := internal.UpdateNodeRetry(client, lister, nodeName, func(node *corev1.Node) {
 		for k, v := range m {
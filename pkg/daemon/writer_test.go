@@ -0,0 +1,61 @@
+package daemon
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	corev1lister "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+
+	mcfgv1 "github.com/openshift/machine-config-operator/pkg/apis/machineconfiguration.openshift.io/v1"
+	ctrlcommon "github.com/openshift/machine-config-operator/pkg/controller/common"
+)
+
+// Tests that update phases accumulate in the node annotation even though the
+// lister does not see our own writes.
+func TestSetUpdatePhase(t *testing.T) {
+	node := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-0"}}
+
+	var previous *ctrlcommon.NodeUpdateTimeline
+	previous = previous.AddPhase("rendered-worker-1", mcfgv1.NodeUpdatePhaseRebooting, time.Now())
+	require.NoError(t, ctrlcommon.SetNodeUpdateTimeline(node, previous))
+
+	kubeClient := k8sfake.NewSimpleClientset(node)
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	require.NoError(t, indexer.Add(node))
+
+	nw := &clusterNodeWriter{
+		nodeName: node.Name,
+		client:   kubeClient.CoreV1().Nodes(),
+		lister:   corev1lister.NewNodeLister(indexer),
+	}
+
+	phases := []mcfgv1.NodeUpdatePhase{
+		mcfgv1.NodeUpdatePhaseDrainRequested,
+		mcfgv1.NodeUpdatePhaseDrained,
+		mcfgv1.NodeUpdatePhaseFilesWritten,
+	}
+	for _, phase := range phases {
+		r := nw.implSetUpdatePhase(&updatePhase{targetConfig: "rendered-worker-2", phase: phase, time: time.Now()})
+		require.NoError(t, r.err)
+	}
+
+	updated, err := kubeClient.CoreV1().Nodes().Get(context.TODO(), node.Name, metav1.GetOptions{})
+	require.NoError(t, err)
+
+	timeline, err := ctrlcommon.GetNodeUpdateTimeline(updated)
+	require.NoError(t, err)
+	assert.Equal(t, "rendered-worker-2", timeline.TargetConfig)
+
+	recorded := []mcfgv1.NodeUpdatePhase{}
+	for _, transition := range timeline.Phases {
+		recorded = append(recorded, transition.Phase)
+	}
+	assert.Equal(t, phases, recorded)
+}
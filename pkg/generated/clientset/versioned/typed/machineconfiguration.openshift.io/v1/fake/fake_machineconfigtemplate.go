@@ -0,0 +1,116 @@
+// Code generated by client-gen. DO NOT EDIT.
+
+package fake
+
+import (
+	"context"
+
+	v1 "github.com/openshift/machine-config-operator/pkg/apis/machineconfiguration.openshift.io/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	labels "k8s.io/apimachinery/pkg/labels"
+	types "k8s.io/apimachinery/pkg/types"
+	watch "k8s.io/apimachinery/pkg/watch"
+	testing "k8s.io/client-go/testing"
+)
+
+// FakeMachineConfigTemplates implements MachineConfigTemplateInterface
+type FakeMachineConfigTemplates struct {
+	Fake *FakeMachineconfigurationV1
+}
+
+var machineconfigtemplatesResource = v1.SchemeGroupVersion.WithResource("machineconfigtemplates")
+
+var machineconfigtemplatesKind = v1.SchemeGroupVersion.WithKind("MachineConfigTemplate")
+
+// Get takes name of the machineConfigTemplate, and returns the corresponding machineConfigTemplate object, and an error if there is any.
+func (c *FakeMachineConfigTemplates) Get(ctx context.Context, name string, options metav1.GetOptions) (result *v1.MachineConfigTemplate, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewRootGetAction(machineconfigtemplatesResource, name), &v1.MachineConfigTemplate{})
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*v1.MachineConfigTemplate), err
+}
+
+// List takes label and field selectors, and returns the list of MachineConfigTemplates that match those selectors.
+func (c *FakeMachineConfigTemplates) List(ctx context.Context, opts metav1.ListOptions) (result *v1.MachineConfigTemplateList, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewRootListAction(machineconfigtemplatesResource, machineconfigtemplatesKind, opts), &v1.MachineConfigTemplateList{})
+	if obj == nil {
+		return nil, err
+	}
+
+	label, _, _ := testing.ExtractFromListOptions(opts)
+	if label == nil {
+		label = labels.Everything()
+	}
+	list := &v1.MachineConfigTemplateList{ListMeta: obj.(*v1.MachineConfigTemplateList).ListMeta}
+	for _, item := range obj.(*v1.MachineConfigTemplateList).Items {
+		if label.Matches(labels.Set(item.Labels)) {
+			list.Items = append(list.Items, item)
+		}
+	}
+	return list, err
+}
+
+// Watch returns a watch.Interface that watches the requested machineConfigTemplates.
+func (c *FakeMachineConfigTemplates) Watch(ctx context.Context, opts metav1.ListOptions) (watch.Interface, error) {
+	return c.Fake.
+		InvokesWatch(testing.NewRootWatchAction(machineconfigtemplatesResource, opts))
+}
+
+// Create takes the representation of a machineConfigTemplate and creates it.  Returns the server's representation of the machineConfigTemplate, and an error, if there is any.
+func (c *FakeMachineConfigTemplates) Create(ctx context.Context, machineConfigTemplate *v1.MachineConfigTemplate, opts metav1.CreateOptions) (result *v1.MachineConfigTemplate, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewRootCreateAction(machineconfigtemplatesResource, machineConfigTemplate), &v1.MachineConfigTemplate{})
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*v1.MachineConfigTemplate), err
+}
+
+// Update takes the representation of a machineConfigTemplate and updates it. Returns the server's representation of the machineConfigTemplate, and an error, if there is any.
+func (c *FakeMachineConfigTemplates) Update(ctx context.Context, machineConfigTemplate *v1.MachineConfigTemplate, opts metav1.UpdateOptions) (result *v1.MachineConfigTemplate, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewRootUpdateAction(machineconfigtemplatesResource, machineConfigTemplate), &v1.MachineConfigTemplate{})
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*v1.MachineConfigTemplate), err
+}
+
+// UpdateStatus was generated because the type contains a Status member.
+// Add a +genclient:noStatus comment above the type to avoid generating UpdateStatus().
+func (c *FakeMachineConfigTemplates) UpdateStatus(ctx context.Context, machineConfigTemplate *v1.MachineConfigTemplate, opts metav1.UpdateOptions) (*v1.MachineConfigTemplate, error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewRootUpdateSubresourceAction(machineconfigtemplatesResource, "status", machineConfigTemplate), &v1.MachineConfigTemplate{})
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*v1.MachineConfigTemplate), err
+}
+
+// Delete takes name of the machineConfigTemplate and deletes it. Returns an error if one occurs.
+func (c *FakeMachineConfigTemplates) Delete(ctx context.Context, name string, opts metav1.DeleteOptions) error {
+	_, err := c.Fake.
+		Invokes(testing.NewRootDeleteActionWithOptions(machineconfigtemplatesResource, name, opts), &v1.MachineConfigTemplate{})
+	return err
+}
+
+// DeleteCollection deletes a collection of objects.
+func (c *FakeMachineConfigTemplates) DeleteCollection(ctx context.Context, opts metav1.DeleteOptions, listOpts metav1.ListOptions) error {
+	action := testing.NewRootDeleteCollectionAction(machineconfigtemplatesResource, listOpts)
+
+	_, err := c.Fake.Invokes(action, &v1.MachineConfigTemplateList{})
+	return err
+}
+
+// Patch applies the patch and returns the patched machineConfigTemplate.
+func (c *FakeMachineConfigTemplates) Patch(ctx context.Context, name string, pt types.PatchType, data []byte, opts metav1.PatchOptions, subresources ...string) (result *v1.MachineConfigTemplate, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewRootPatchSubresourceAction(machineconfigtemplatesResource, name, pt, data, subresources...), &v1.MachineConfigTemplate{})
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*v1.MachineConfigTemplate), err
+}
@@ -0,0 +1,168 @@
+// Code generated by client-gen. DO NOT EDIT.
+
+package v1
+
+import (
+	"context"
+	"time"
+
+	v1 "github.com/openshift/machine-config-operator/pkg/apis/machineconfiguration.openshift.io/v1"
+	scheme "github.com/openshift/machine-config-operator/pkg/generated/clientset/versioned/scheme"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	types "k8s.io/apimachinery/pkg/types"
+	watch "k8s.io/apimachinery/pkg/watch"
+	rest "k8s.io/client-go/rest"
+)
+
+// MachineConfigTemplatesGetter has a method to return a MachineConfigTemplateInterface.
+// A group's client should implement this interface.
+type MachineConfigTemplatesGetter interface {
+	MachineConfigTemplates() MachineConfigTemplateInterface
+}
+
+// MachineConfigTemplateInterface has methods to work with MachineConfigTemplate resources.
+type MachineConfigTemplateInterface interface {
+	Create(ctx context.Context, machineConfigTemplate *v1.MachineConfigTemplate, opts metav1.CreateOptions) (*v1.MachineConfigTemplate, error)
+	Update(ctx context.Context, machineConfigTemplate *v1.MachineConfigTemplate, opts metav1.UpdateOptions) (*v1.MachineConfigTemplate, error)
+	UpdateStatus(ctx context.Context, machineConfigTemplate *v1.MachineConfigTemplate, opts metav1.UpdateOptions) (*v1.MachineConfigTemplate, error)
+	Delete(ctx context.Context, name string, opts metav1.DeleteOptions) error
+	DeleteCollection(ctx context.Context, opts metav1.DeleteOptions, listOpts metav1.ListOptions) error
+	Get(ctx context.Context, name string, opts metav1.GetOptions) (*v1.MachineConfigTemplate, error)
+	List(ctx context.Context, opts metav1.ListOptions) (*v1.MachineConfigTemplateList, error)
+	Watch(ctx context.Context, opts metav1.ListOptions) (watch.Interface, error)
+	Patch(ctx context.Context, name string, pt types.PatchType, data []byte, opts metav1.PatchOptions, subresources ...string) (result *v1.MachineConfigTemplate, err error)
+	MachineConfigTemplateExpansion
+}
+
+// machineConfigTemplates implements MachineConfigTemplateInterface
+type machineConfigTemplates struct {
+	client rest.Interface
+}
+
+// newMachineConfigTemplates returns a MachineConfigTemplates
+func newMachineConfigTemplates(c *MachineconfigurationV1Client) *machineConfigTemplates {
+	return &machineConfigTemplates{
+		client: c.RESTClient(),
+	}
+}
+
+// Get takes name of the machineConfigTemplate, and returns the corresponding machineConfigTemplate object, and an error if there is any.
+func (c *machineConfigTemplates) Get(ctx context.Context, name string, options metav1.GetOptions) (result *v1.MachineConfigTemplate, err error) {
+	result = &v1.MachineConfigTemplate{}
+	err = c.client.Get().
+		Resource("machineconfigtemplates").
+		Name(name).
+		VersionedParams(&options, scheme.ParameterCodec).
+		Do(ctx).
+		Into(result)
+	return
+}
+
+// List takes label and field selectors, and returns the list of MachineConfigTemplates that match those selectors.
+func (c *machineConfigTemplates) List(ctx context.Context, opts metav1.ListOptions) (result *v1.MachineConfigTemplateList, err error) {
+	var timeout time.Duration
+	if opts.TimeoutSeconds != nil {
+		timeout = time.Duration(*opts.TimeoutSeconds) * time.Second
+	}
+	result = &v1.MachineConfigTemplateList{}
+	err = c.client.Get().
+		Resource("machineconfigtemplates").
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Timeout(timeout).
+		Do(ctx).
+		Into(result)
+	return
+}
+
+// Watch returns a watch.Interface that watches the requested machineConfigTemplates.
+func (c *machineConfigTemplates) Watch(ctx context.Context, opts metav1.ListOptions) (watch.Interface, error) {
+	var timeout time.Duration
+	if opts.TimeoutSeconds != nil {
+		timeout = time.Duration(*opts.TimeoutSeconds) * time.Second
+	}
+	opts.Watch = true
+	return c.client.Get().
+		Resource("machineconfigtemplates").
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Timeout(timeout).
+		Watch(ctx)
+}
+
+// Create takes the representation of a machineConfigTemplate and creates it.  Returns the server's representation of the machineConfigTemplate, and an error, if there is any.
+func (c *machineConfigTemplates) Create(ctx context.Context, machineConfigTemplate *v1.MachineConfigTemplate, opts metav1.CreateOptions) (result *v1.MachineConfigTemplate, err error) {
+	result = &v1.MachineConfigTemplate{}
+	err = c.client.Post().
+		Resource("machineconfigtemplates").
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Body(machineConfigTemplate).
+		Do(ctx).
+		Into(result)
+	return
+}
+
+// Update takes the representation of a machineConfigTemplate and updates it. Returns the server's representation of the machineConfigTemplate, and an error, if there is any.
+func (c *machineConfigTemplates) Update(ctx context.Context, machineConfigTemplate *v1.MachineConfigTemplate, opts metav1.UpdateOptions) (result *v1.MachineConfigTemplate, err error) {
+	result = &v1.MachineConfigTemplate{}
+	err = c.client.Put().
+		Resource("machineconfigtemplates").
+		Name(machineConfigTemplate.Name).
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Body(machineConfigTemplate).
+		Do(ctx).
+		Into(result)
+	return
+}
+
+// UpdateStatus was generated because the type contains a Status member.
+// Add a +genclient:noStatus comment above the type to avoid generating UpdateStatus().
+func (c *machineConfigTemplates) UpdateStatus(ctx context.Context, machineConfigTemplate *v1.MachineConfigTemplate, opts metav1.UpdateOptions) (result *v1.MachineConfigTemplate, err error) {
+	result = &v1.MachineConfigTemplate{}
+	err = c.client.Put().
+		Resource("machineconfigtemplates").
+		Name(machineConfigTemplate.Name).
+		SubResource("status").
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Body(machineConfigTemplate).
+		Do(ctx).
+		Into(result)
+	return
+}
+
+// Delete takes name of the machineConfigTemplate and deletes it. Returns an error if one occurs.
+func (c *machineConfigTemplates) Delete(ctx context.Context, name string, opts metav1.DeleteOptions) error {
+	return c.client.Delete().
+		Resource("machineconfigtemplates").
+		Name(name).
+		Body(&opts).
+		Do(ctx).
+		Error()
+}
+
+// DeleteCollection deletes a collection of objects.
+func (c *machineConfigTemplates) DeleteCollection(ctx context.Context, opts metav1.DeleteOptions, listOpts metav1.ListOptions) error {
+	var timeout time.Duration
+	if listOpts.TimeoutSeconds != nil {
+		timeout = time.Duration(*listOpts.TimeoutSeconds) * time.Second
+	}
+	return c.client.Delete().
+		Resource("machineconfigtemplates").
+		VersionedParams(&listOpts, scheme.ParameterCodec).
+		Timeout(timeout).
+		Body(&opts).
+		Do(ctx).
+		Error()
+}
+
+// Patch applies the patch and returns the patched machineConfigTemplate.
+func (c *machineConfigTemplates) Patch(ctx context.Context, name string, pt types.PatchType, data []byte, opts metav1.PatchOptions, subresources ...string) (result *v1.MachineConfigTemplate, err error) {
+	result = &v1.MachineConfigTemplate{}
+	err = c.client.Patch(pt).
+		Resource("machineconfigtemplates").
+		Name(name).
+		SubResource(subresources...).
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Body(data).
+		Do(ctx).
+		Into(result)
+	return
+}
@@ -17,6 +17,7 @@ type MachineconfigurationV1Interface interface {
 	KubeletConfigsGetter
 	MachineConfigsGetter
 	MachineConfigPoolsGetter
+	MachineConfigTemplatesGetter
 }
 
 // MachineconfigurationV1Client is used to interact with features provided by the machineconfiguration.openshift.io group.
@@ -44,6 +45,10 @@ func (c *MachineconfigurationV1Client) MachineConfigPools() MachineConfigPoolInt
 	return newMachineConfigPools(c)
 }
 
+func (c *MachineconfigurationV1Client) MachineConfigTemplates() MachineConfigTemplateInterface {
+	return newMachineConfigTemplates(c)
+}
+
 // NewForConfig creates a new MachineconfigurationV1Client for the given config.
 // NewForConfig is equivalent to NewForConfigAndClient(c, httpClient),
 // where httpClient was generated with rest.HTTPClientFor(c).
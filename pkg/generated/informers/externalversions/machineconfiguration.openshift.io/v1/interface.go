@@ -18,6 +18,8 @@ type Interface interface {
 	MachineConfigs() MachineConfigInformer
 	// MachineConfigPools returns a MachineConfigPoolInformer.
 	MachineConfigPools() MachineConfigPoolInformer
+	// MachineConfigTemplates returns a MachineConfigTemplateInformer.
+	MachineConfigTemplates() MachineConfigTemplateInformer
 }
 
 type version struct {
@@ -55,3 +57,8 @@ func (v *version) MachineConfigs() MachineConfigInformer {
 func (v *version) MachineConfigPools() MachineConfigPoolInformer {
 	return &machineConfigPoolInformer{factory: v.factory, tweakListOptions: v.tweakListOptions}
 }
+
+// MachineConfigTemplates returns a MachineConfigTemplateInformer.
+func (v *version) MachineConfigTemplates() MachineConfigTemplateInformer {
+	return &machineConfigTemplateInformer{factory: v.factory, tweakListOptions: v.tweakListOptions}
+}
@@ -21,3 +21,7 @@ type MachineConfigListerExpansion interface{}
 // MachineConfigPoolListerExpansion allows custom methods to be added to
 // MachineConfigPoolLister.
 type MachineConfigPoolListerExpansion interface{}
+
+// MachineConfigTemplateListerExpansion allows custom methods to be added to
+// MachineConfigTemplateLister.
+type MachineConfigTemplateListerExpansion interface{}
@@ -0,0 +1,52 @@
+// Code generated by lister-gen. DO NOT EDIT.
+
+package v1
+
+import (
+	v1 "github.com/openshift/machine-config-operator/pkg/apis/machineconfiguration.openshift.io/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/tools/cache"
+)
+
+// MachineConfigTemplateLister helps list MachineConfigTemplates.
+// All objects returned here must be treated as read-only.
+type MachineConfigTemplateLister interface {
+	// List lists all MachineConfigTemplates in the indexer.
+	// Objects returned here must be treated as read-only.
+	List(selector labels.Selector) (ret []*v1.MachineConfigTemplate, err error)
+	// Get retrieves the MachineConfigTemplate from the index for a given name.
+	// Objects returned here must be treated as read-only.
+	Get(name string) (*v1.MachineConfigTemplate, error)
+	MachineConfigTemplateListerExpansion
+}
+
+// machineConfigTemplateLister implements the MachineConfigTemplateLister interface.
+type machineConfigTemplateLister struct {
+	indexer cache.Indexer
+}
+
+// NewMachineConfigTemplateLister returns a new MachineConfigTemplateLister.
+func NewMachineConfigTemplateLister(indexer cache.Indexer) MachineConfigTemplateLister {
+	return &machineConfigTemplateLister{indexer: indexer}
+}
+
+// List lists all MachineConfigTemplates in the indexer.
+func (s *machineConfigTemplateLister) List(selector labels.Selector) (ret []*v1.MachineConfigTemplate, err error) {
+	err = cache.ListAll(s.indexer, selector, func(m interface{}) {
+		ret = append(ret, m.(*v1.MachineConfigTemplate))
+	})
+	return ret, err
+}
+
+// Get retrieves the MachineConfigTemplate from the index for a given name.
+func (s *machineConfigTemplateLister) Get(name string) (*v1.MachineConfigTemplate, error) {
+	obj, exists, err := s.indexer.GetByKey(name)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, errors.NewNotFound(v1.Resource("machineconfigtemplate"), name)
+	}
+	return obj.(*v1.MachineConfigTemplate), nil
+}
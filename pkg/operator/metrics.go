@@ -43,8 +43,59 @@ var (
 			Name: "mco_unavailable_machine_count",
 			Help: "total number of unavailable machines in specified pool",
 		}, []string{"pool"})
+	// mcoPoolDesiredConfigChangeTime is when the desired config of the pool last changed
+	mcoPoolDesiredConfigChangeTime = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "mco_pool_desired_config_change_timestamp_seconds",
+			Help: "unix time at which the desired rendered config of a specified pool last changed",
+		}, []string{"pool"})
+	// mcoNodeUpdateDuration is how long nodes take to go from a new desired config to done
+	mcoNodeUpdateDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "mco_node_update_duration_seconds",
+			Help:    "time taken by nodes in a specified pool to apply a new desired config",
+			Buckets: prometheus.ExponentialBuckets(30, 2, 10),
+		}, []string{"pool"})
+	// mcoNodeUpdatePhaseDuration is how long each phase of a node update takes
+	// drain, apply, reboot, uncordon
+	mcoNodeUpdatePhaseDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "mco_node_update_phase_duration_seconds",
+			Help:    "time taken by each phase of a node update in a specified pool",
+			Buckets: prometheus.ExponentialBuckets(5, 2, 12),
+		}, []string{"pool", "phase"})
+	// mcoNodeStateCount is the number of nodes in the pool per MCD state
+	mcoNodeStateCount = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "mco_node_state_count",
+			Help: "total number of nodes in a specified pool per machine config daemon state",
+		}, []string{"pool", "state"})
+	// mcoRenderedConfigCount is the number of rendered configs owned by the pool
+	mcoRenderedConfigCount = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "mco_rendered_config_count",
+			Help: "total number of rendered machine configs of a specified pool",
+		}, []string{"pool"})
+	// mcoPausedPoolPendingChanges is 1 if the pool is paused with a config that has not been rolled out
+	mcoPausedPoolPendingChanges = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "mco_paused_pool_pending_changes",
+			Help: "whether a specified pool is paused with a desired config that has not been rolled out",
+		}, []string{"pool"})
 )
 
 func RegisterMCOMetrics() error {
-	return ctrlcommon.RegisterMetrics([]prometheus.Collector{mcoState, mcoMachineCount, mcoUpdatedMachineCount, mcoDegradedMachineCount, mcoUnavailableMachineCount})
+	return ctrlcommon.RegisterMetrics([]prometheus.Collector{
+		mcoState,
+		mcoMachineCount,
+		mcoUpdatedMachineCount,
+		mcoDegradedMachineCount,
+		mcoUnavailableMachineCount,
+		mcoPoolDesiredConfigChangeTime,
+		mcoNodeUpdateDuration,
+		mcoNodeUpdatePhaseDuration,
+		mcoNodeStateCount,
+		mcoRenderedConfigCount,
+		mcoPausedPoolPendingChanges,
+	})
 }
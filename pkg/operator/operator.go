@@ -114,6 +114,8 @@ type Operator struct {
 	stopCh <-chan struct{}
 
 	renderConfig *renderConfig
+
+	rolloutTracker *rolloutTracker
 }
 
 // New returns a new machine config operator.
@@ -148,15 +150,16 @@ func New(
 	eventBroadcaster.StartRecordingToSink(&coreclientsetv1.EventSinkImpl{Interface: kubeClient.CoreV1().Events("")})
 
 	optr := &Operator{
-		namespace:     namespace,
-		name:          name,
-		imagesFile:    imagesFile,
-		vStore:        newVersionStore(),
-		client:        client,
-		kubeClient:    kubeClient,
-		apiExtClient:  apiExtClient,
-		configClient:  configClient,
-		eventRecorder: ctrlcommon.NamespacedEventRecorder(eventBroadcaster.NewRecorder(scheme.Scheme, corev1.EventSource{Component: "machineconfigoperator"})),
+		namespace:      namespace,
+		name:           name,
+		imagesFile:     imagesFile,
+		vStore:         newVersionStore(),
+		rolloutTracker: newRolloutTracker(),
+		client:         client,
+		kubeClient:     kubeClient,
+		apiExtClient:   apiExtClient,
+		configClient:   configClient,
+		eventRecorder:  ctrlcommon.NamespacedEventRecorder(eventBroadcaster.NewRecorder(scheme.Scheme, corev1.EventSource{Component: "machineconfigoperator"})),
 		libgoRecorder: events.NewRecorder(kubeClient.CoreV1().Events(ctrlcommon.MCONamespace), "machine-config-operator", &corev1.ObjectReference{
 			Kind:       "Deployment",
 			Name:       "machine-config-operator",
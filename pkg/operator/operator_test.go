@@ -24,6 +24,7 @@ func TestMetrics(t *testing.T) {
 		eventRecorder: &record.FakeRecorder{},
 	}
 	optr.vStore = newVersionStore()
+	optr.rolloutTracker = newRolloutTracker()
 
 	p1, p2 := helpers.NewMachineConfigPool("master", nil, helpers.MasterSelector, "v0"), helpers.NewMachineConfigPool("worker", nil, helpers.WorkerSelector, "v0")
 	p2.Status.MachineCount = 2
@@ -35,6 +36,7 @@ func TestMetrics(t *testing.T) {
 
 	nodeIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
 	optr.nodeLister = corelisterv1.NewNodeLister(nodeIndexer)
+	optr.mcLister = newFakeMCLister()
 	nodeIndexer.Add(&corev1.Node{
 		ObjectMeta: metav1.ObjectMeta{Name: "first-node", Labels: map[string]string{"node-role/worker": ""}},
 		Status: corev1.NodeStatus{
@@ -0,0 +1,295 @@
+package operator
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/klog/v2"
+
+	mcfgv1 "github.com/openshift/machine-config-operator/pkg/apis/machineconfiguration.openshift.io/v1"
+	ctrlcommon "github.com/openshift/machine-config-operator/pkg/controller/common"
+	daemonconsts "github.com/openshift/machine-config-operator/pkg/daemon/constants"
+)
+
+// nodeUpdatePhase is a step of applying a new MachineConfig to a node.
+type nodeUpdatePhase string
+
+const (
+	nodeUpdatePhaseDrain    nodeUpdatePhase = "drain"
+	nodeUpdatePhaseApply    nodeUpdatePhase = "apply"
+	nodeUpdatePhaseReboot   nodeUpdatePhase = "reboot"
+	nodeUpdatePhaseUncordon nodeUpdatePhase = "uncordon"
+
+	// Used for nodes which have no MCD state yet.
+	unknownMCDState = "Unknown"
+)
+
+var mcdStates = []string{
+	daemonconsts.MachineConfigDaemonStateDone,
+	daemonconsts.MachineConfigDaemonStateWorking,
+	daemonconsts.MachineConfigDaemonStateDegraded,
+	daemonconsts.MachineConfigDaemonStateUnreconcilable,
+	unknownMCDState,
+}
+
+// nodeRollout is an update of a node which is in progress.
+type nodeRollout struct {
+	pool          string
+	desiredConfig string
+	bootID        string
+	started       time.Time
+	phase         nodeUpdatePhase
+	phaseStarted  time.Time
+	// The time spent in each phase so far. A phase may be entered more than
+	// once, e.g. the MCD prepares the update before asking for a drain.
+	phaseDurations map[nodeUpdatePhase]time.Duration
+}
+
+// poolConfigChange is when a pool was last seen targeting a new rendered config.
+type poolConfigChange struct {
+	config  string
+	changed time.Time
+}
+
+// rolloutTracker follows pools and node updates across syncs so that their
+// durations can be measured. The state is only kept in memory, so updates
+// which are in progress when the operator restarts are measured from when the
+// new operator first sees them.
+type rolloutTracker struct {
+	mu    sync.Mutex
+	nodes map[string]*nodeRollout
+	pools map[string]poolConfigChange
+}
+
+func newRolloutTracker() *rolloutTracker {
+	return &rolloutTracker{
+		nodes: map[string]*nodeRollout{},
+		pools: map[string]poolConfigChange{},
+	}
+}
+
+// observePool returns when the desired config of the pool last changed. The
+// first time a pool is seen, created is used, which is the creation time of
+// its desired rendered config.
+func (rt *rolloutTracker) observePool(pool *mcfgv1.MachineConfigPool, created, now time.Time) time.Time {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+
+	desired := pool.Spec.Configuration.Name
+	last, ok := rt.pools[pool.Name]
+	switch {
+	case !ok && !created.IsZero():
+		last = poolConfigChange{config: desired, changed: created}
+	case !ok || last.config != desired:
+		last = poolConfigChange{config: desired, changed: now}
+	}
+	rt.pools[pool.Name] = last
+	return last.changed
+}
+
+// forgetPoolsExcept drops pools which no longer exist, along with their metrics.
+func (rt *rolloutTracker) forgetPoolsExcept(pools sets.String) {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+
+	for name := range rt.pools {
+		if pools.Has(name) {
+			continue
+		}
+		delete(rt.pools, name)
+		for _, vec := range []*prometheus.GaugeVec{mcoPoolDesiredConfigChangeTime, mcoRenderedConfigCount, mcoPausedPoolPendingChanges, mcoNodeStateCount} {
+			vec.DeletePartialMatch(prometheus.Labels{"pool": name})
+		}
+	}
+}
+
+// observeNode records the current update phase of the node. The durations of
+// the update and of each of its phases are observed once the node is done.
+// Updates which are superseded by a new desired config are not observed.
+func (rt *rolloutTracker) observeNode(pool string, node *corev1.Node, now time.Time) {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+
+	current := node.Annotations[daemonconsts.CurrentMachineConfigAnnotationKey]
+	desired := node.Annotations[daemonconsts.DesiredMachineConfigAnnotationKey]
+	state := node.Annotations[daemonconsts.MachineConfigDaemonStateAnnotationKey]
+
+	rollout, ok := rt.nodes[node.Name]
+
+	if desired == "" || (current == desired && state == daemonconsts.MachineConfigDaemonStateDone) {
+		if ok && rollout.desiredConfig == desired {
+			rollout.finishPhase(now)
+			for phase, duration := range rollout.phaseDurations {
+				mcoNodeUpdatePhaseDuration.WithLabelValues(rollout.pool, string(phase)).Observe(duration.Seconds())
+			}
+			mcoNodeUpdateDuration.WithLabelValues(rollout.pool).Observe(now.Sub(rollout.started).Seconds())
+			klog.V(4).Infof("Node %s finished updating to %s in %s", node.Name, desired, now.Sub(rollout.started))
+		}
+		delete(rt.nodes, node.Name)
+		return
+	}
+
+	if !ok || rollout.desiredConfig != desired {
+		rollout = &nodeRollout{
+			pool:           pool,
+			desiredConfig:  desired,
+			bootID:         node.Status.NodeInfo.BootID,
+			started:        now,
+			phaseDurations: map[nodeUpdatePhase]time.Duration{},
+		}
+		rt.nodes[node.Name] = rollout
+	}
+
+	phase := getNodeUpdatePhase(node, rollout.bootID)
+	if phase != rollout.phase {
+		rollout.finishPhase(now)
+		rollout.phase = phase
+		rollout.phaseStarted = now
+	}
+}
+
+// forgetNodesExcept drops updates of nodes which no longer exist.
+func (rt *rolloutTracker) forgetNodesExcept(nodes sets.String) {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+
+	for name := range rt.nodes {
+		if !nodes.Has(name) {
+			delete(rt.nodes, name)
+		}
+	}
+}
+
+// finishPhase adds the time spent in the current phase to its total.
+func (r *nodeRollout) finishPhase(now time.Time) {
+	if r.phase == "" {
+		return
+	}
+	r.phaseDurations[r.phase] += now.Sub(r.phaseStarted)
+	r.phase = ""
+}
+
+// getNodeUpdatePhase determines which phase of the update the node is in from
+// the drain annotations, its readiness and its boot ID. bootID is the boot ID
+// of the node when the update started.
+func getNodeUpdatePhase(node *corev1.Node, bootID string) nodeUpdatePhase {
+	desiredDrain := node.Annotations[daemonconsts.DesiredDrainerAnnotationKey]
+	lastAppliedDrain := node.Annotations[daemonconsts.LastAppliedDrainerAnnotationKey]
+
+	if desiredDrain != lastAppliedDrain {
+		if strings.HasPrefix(desiredDrain, daemonconsts.DrainerStateUncordon) {
+			return nodeUpdatePhaseUncordon
+		}
+		if strings.HasPrefix(desiredDrain, daemonconsts.DrainerStateDrain) {
+			return nodeUpdatePhaseDrain
+		}
+	}
+
+	// Once the node has started rebooting, the time until the MCD asks for
+	// it to be uncordoned counts towards the reboot.
+	if !isNodeReady(node) || (bootID != "" && node.Status.NodeInfo.BootID != bootID) {
+		return nodeUpdatePhaseReboot
+	}
+
+	return nodeUpdatePhaseApply
+}
+
+func isNodeReady(node *corev1.Node) bool {
+	for _, condition := range node.Status.Conditions {
+		if condition.Type == corev1.NodeReady {
+			return condition.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// getMCDState returns the MCD state of the node for the node state metric.
+func getMCDState(node *corev1.Node) string {
+	state := node.Annotations[daemonconsts.MachineConfigDaemonStateAnnotationKey]
+	for _, known := range mcdStates {
+		if state == known {
+			return state
+		}
+	}
+	return unknownMCDState
+}
+
+// syncRolloutMetrics publishes the rollout progress of each pool.
+func (optr *Operator) syncRolloutMetrics(pools []*mcfgv1.MachineConfigPool) error {
+	now := time.Now()
+
+	mcs, err := optr.mcLister.List(labels.Everything())
+	if err != nil {
+		return err
+	}
+
+	renderedConfigs := map[string]int{}
+	for _, mc := range mcs {
+		if ref := metav1.GetControllerOf(mc); ref != nil && ref.Kind == "MachineConfigPool" {
+			renderedConfigs[ref.Name]++
+		}
+	}
+
+	poolNames := sets.NewString()
+	for _, pool := range pools {
+		poolNames.Insert(pool.Name)
+
+		var created time.Time
+		if mc, err := optr.mcLister.Get(pool.Spec.Configuration.Name); err == nil {
+			created = mc.CreationTimestamp.Time
+		}
+		changed := optr.rolloutTracker.observePool(pool, created, now)
+		mcoPoolDesiredConfigChangeTime.WithLabelValues(pool.Name).Set(float64(changed.Unix()))
+
+		mcoRenderedConfigCount.WithLabelValues(pool.Name).Set(float64(renderedConfigs[pool.Name]))
+
+		pending := 0.0
+		if pool.Spec.Paused && pool.Spec.Configuration.Name != pool.Status.Configuration.Name {
+			pending = 1
+		}
+		mcoPausedPoolPendingChanges.WithLabelValues(pool.Name).Set(pending)
+	}
+	optr.rolloutTracker.forgetPoolsExcept(poolNames)
+
+	nodes, err := optr.nodeLister.List(labels.Everything())
+	if err != nil {
+		return err
+	}
+
+	stateCounts := map[string]map[string]int{}
+	for _, pool := range pools {
+		stateCounts[pool.Name] = map[string]int{}
+	}
+
+	nodeNames := sets.NewString()
+	for _, node := range nodes {
+		// The node controller reports nodes it can not place in a pool, they
+		// are only left out of the metrics here.
+		pool, err := ctrlcommon.FindPrimaryPoolForNode(pools, node)
+		if err != nil {
+			klog.Warningf("Could not get the pool of node %s, skipping it in the rollout metrics: %v", node.Name, err)
+			continue
+		}
+		if pool == nil {
+			continue
+		}
+		nodeNames.Insert(node.Name)
+		stateCounts[pool.Name][getMCDState(node)]++
+		optr.rolloutTracker.observeNode(pool.Name, node, now)
+	}
+	optr.rolloutTracker.forgetNodesExcept(nodeNames)
+
+	for pool, counts := range stateCounts {
+		for _, state := range mcdStates {
+			mcoNodeStateCount.WithLabelValues(pool, state).Set(float64(counts[state]))
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,243 @@
+package operator
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	mcfgv1 "github.com/openshift/machine-config-operator/pkg/apis/machineconfiguration.openshift.io/v1"
+	daemonconsts "github.com/openshift/machine-config-operator/pkg/daemon/constants"
+	"github.com/openshift/machine-config-operator/test/helpers"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	corelisterv1 "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// getHistogram returns the sample count and sum of the given histogram.
+func getHistogram(t *testing.T, observer prometheus.Observer) (uint64, float64) {
+	t.Helper()
+
+	metric := &dto.Metric{}
+	require.NoError(t, observer.(prometheus.Metric).Write(metric))
+	return metric.GetHistogram().GetSampleCount(), metric.GetHistogram().GetSampleSum()
+}
+
+type rolloutTestNode struct {
+	node *corev1.Node
+}
+
+func newRolloutTestNode(name, config string) *rolloutTestNode {
+	return &rolloutTestNode{
+		node: &corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: name,
+				Annotations: map[string]string{
+					daemonconsts.CurrentMachineConfigAnnotationKey:     config,
+					daemonconsts.DesiredMachineConfigAnnotationKey:     config,
+					daemonconsts.MachineConfigDaemonStateAnnotationKey: daemonconsts.MachineConfigDaemonStateDone,
+					daemonconsts.DesiredDrainerAnnotationKey:           "uncordon-" + config,
+					daemonconsts.LastAppliedDrainerAnnotationKey:       "uncordon-" + config,
+				},
+			},
+			Status: corev1.NodeStatus{
+				NodeInfo:   corev1.NodeSystemInfo{BootID: "boot-1"},
+				Conditions: []corev1.NodeCondition{{Type: corev1.NodeReady, Status: corev1.ConditionTrue}},
+			},
+		},
+	}
+}
+
+func (n *rolloutTestNode) annotate(key, value string) *rolloutTestNode {
+	n.node.Annotations[key] = value
+	return n
+}
+
+func (n *rolloutTestNode) setReady(ready bool, bootID string) *rolloutTestNode {
+	n.node.Status.Conditions[0].Status = corev1.ConditionFalse
+	if ready {
+		n.node.Status.Conditions[0].Status = corev1.ConditionTrue
+	}
+	n.node.Status.NodeInfo.BootID = bootID
+	return n
+}
+
+// Tests that the duration of an update and of each of its phases is observed
+// once the node is done.
+func TestRolloutTrackerObserveNode(t *testing.T) {
+	pool := "rollout-test"
+	rt := newRolloutTracker()
+	n := newRolloutTestNode("node-0", "rendered-1")
+
+	start := time.Now()
+	at := func(minutes int) time.Time {
+		return start.Add(time.Duration(minutes) * time.Minute)
+	}
+
+	steps := []struct {
+		minutes int
+		update  func()
+		phase   nodeUpdatePhase
+	}{
+		// The node is told to update and the MCD prepares the update.
+		{0, func() {
+			n.annotate(daemonconsts.DesiredMachineConfigAnnotationKey, "rendered-2").
+				annotate(daemonconsts.MachineConfigDaemonStateAnnotationKey, daemonconsts.MachineConfigDaemonStateWorking)
+		}, nodeUpdatePhaseApply},
+		{1, func() { n.annotate(daemonconsts.DesiredDrainerAnnotationKey, "drain-rendered-2") }, nodeUpdatePhaseDrain},
+		{4, func() { n.annotate(daemonconsts.LastAppliedDrainerAnnotationKey, "drain-rendered-2") }, nodeUpdatePhaseApply},
+		{6, func() { n.setReady(false, "boot-1") }, nodeUpdatePhaseReboot},
+		{10, func() { n.setReady(true, "boot-2") }, nodeUpdatePhaseReboot},
+		{11, func() { n.annotate(daemonconsts.DesiredDrainerAnnotationKey, "uncordon-rendered-2") }, nodeUpdatePhaseUncordon},
+		{12, func() {
+			n.annotate(daemonconsts.LastAppliedDrainerAnnotationKey, "uncordon-rendered-2").
+				annotate(daemonconsts.CurrentMachineConfigAnnotationKey, "rendered-2").
+				annotate(daemonconsts.MachineConfigDaemonStateAnnotationKey, daemonconsts.MachineConfigDaemonStateDone)
+		}, ""},
+	}
+
+	for _, step := range steps {
+		step.update()
+		rt.observeNode(pool, n.node, at(step.minutes))
+		if step.phase == "" {
+			assert.NotContains(t, rt.nodes, "node-0")
+			continue
+		}
+		require.Contains(t, rt.nodes, "node-0")
+		assert.Equal(t, step.phase, rt.nodes["node-0"].phase, "at minute %d", step.minutes)
+	}
+
+	count, sum := getHistogram(t, mcoNodeUpdateDuration.WithLabelValues(pool))
+	assert.Equal(t, uint64(1), count)
+	assert.Equal(t, (12 * time.Minute).Seconds(), sum)
+
+	expected := map[nodeUpdatePhase]time.Duration{
+		nodeUpdatePhaseDrain:    3 * time.Minute,
+		nodeUpdatePhaseApply:    3 * time.Minute,
+		nodeUpdatePhaseReboot:   5 * time.Minute,
+		nodeUpdatePhaseUncordon: time.Minute,
+	}
+	for phase, duration := range expected {
+		count, sum := getHistogram(t, mcoNodeUpdatePhaseDuration.WithLabelValues(pool, string(phase)))
+		assert.Equal(t, uint64(1), count, string(phase))
+		assert.Equal(t, duration.Seconds(), sum, string(phase))
+	}
+}
+
+// Tests that updates which are superseded by a new desired config are not observed.
+func TestRolloutTrackerSupersededUpdate(t *testing.T) {
+	pool := "rollout-superseded-test"
+	rt := newRolloutTracker()
+	n := newRolloutTestNode("node-0", "rendered-1")
+	now := time.Now()
+
+	n.annotate(daemonconsts.DesiredMachineConfigAnnotationKey, "rendered-2")
+	rt.observeNode(pool, n.node, now)
+
+	n.annotate(daemonconsts.DesiredMachineConfigAnnotationKey, "rendered-3")
+	rt.observeNode(pool, n.node, now.Add(time.Minute))
+	assert.Equal(t, now.Add(time.Minute), rt.nodes["node-0"].started)
+
+	n.annotate(daemonconsts.CurrentMachineConfigAnnotationKey, "rendered-3")
+	rt.observeNode(pool, n.node, now.Add(3*time.Minute))
+
+	count, sum := getHistogram(t, mcoNodeUpdateDuration.WithLabelValues(pool))
+	assert.Equal(t, uint64(1), count)
+	assert.Equal(t, (2 * time.Minute).Seconds(), sum)
+
+	// Nodes which go away are forgotten.
+	n.annotate(daemonconsts.DesiredMachineConfigAnnotationKey, "rendered-4")
+	rt.observeNode(pool, n.node, now.Add(4*time.Minute))
+	rt.forgetNodesExcept(sets.NewString())
+	assert.Empty(t, rt.nodes)
+}
+
+// Tests that the time the desired config of a pool changed is tracked.
+func TestRolloutTrackerObservePool(t *testing.T) {
+	rt := newRolloutTracker()
+	created := time.Date(2023, time.June, 1, 0, 0, 0, 0, time.UTC)
+	now := created.Add(time.Hour)
+
+	pool := helpers.NewMachineConfigPool("worker", nil, helpers.WorkerSelector, "rendered-worker-1")
+	assert.Equal(t, created, rt.observePool(pool, created, now))
+	assert.Equal(t, created, rt.observePool(pool, created, now.Add(time.Minute)))
+
+	pool.Spec.Configuration.Name = "rendered-worker-2"
+	assert.Equal(t, now.Add(2*time.Minute), rt.observePool(pool, created, now.Add(2*time.Minute)))
+	assert.Equal(t, now.Add(2*time.Minute), rt.observePool(pool, created, now.Add(3*time.Minute)))
+
+	// Without a rendered config, the first observation is used.
+	other := helpers.NewMachineConfigPool("infra", nil, helpers.InfraSelector, "rendered-infra-1")
+	assert.Equal(t, now, rt.observePool(other, time.Time{}, now))
+}
+
+// Tests that the per-pool rollout metrics are published.
+func TestSyncRolloutMetrics(t *testing.T) {
+	paused := helpers.NewMachineConfigPool("rollout-paused", nil, helpers.InfraSelector, "rendered-rollout-paused-1")
+	paused.Spec.Paused = true
+	paused.Spec.Configuration.Name = "rendered-rollout-paused-2"
+
+	rendered := []*mcfgv1.MachineConfig{}
+	for i := 1; i <= 2; i++ {
+		mc := helpers.NewMachineConfig(fmt.Sprintf("rendered-rollout-paused-%d", i), nil, "", nil)
+		mc.CreationTimestamp = metav1.NewTime(time.Date(2023, time.June, i, 0, 0, 0, 0, time.UTC))
+		mc.OwnerReferences = []metav1.OwnerReference{*metav1.NewControllerRef(paused, mcfgv1.SchemeGroupVersion.WithKind("MachineConfigPool"))}
+		rendered = append(rendered, mc)
+	}
+
+	optr := &Operator{rolloutTracker: newRolloutTracker()}
+	optr.mcLister = newFakeMCLister(rendered...)
+
+	nodeIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	optr.nodeLister = corelisterv1.NewNodeLister(nodeIndexer)
+	for i, state := range []string{daemonconsts.MachineConfigDaemonStateDone, daemonconsts.MachineConfigDaemonStateWorking, ""} {
+		n := newRolloutTestNode(fmt.Sprintf("infra-%d", i), "rendered-rollout-paused-1").annotate(daemonconsts.MachineConfigDaemonStateAnnotationKey, state)
+		n.node.Labels = map[string]string{"node-role/infra": ""}
+		nodeIndexer.Add(n.node)
+	}
+
+	require.NoError(t, optr.syncRolloutMetrics([]*mcfgv1.MachineConfigPool{paused}))
+
+	assert.Equal(t, float64(1), testutil.ToFloat64(mcoPausedPoolPendingChanges.WithLabelValues("rollout-paused")))
+	assert.Equal(t, float64(2), testutil.ToFloat64(mcoRenderedConfigCount.WithLabelValues("rollout-paused")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(mcoNodeStateCount.WithLabelValues("rollout-paused", daemonconsts.MachineConfigDaemonStateDone)))
+	assert.Equal(t, float64(1), testutil.ToFloat64(mcoNodeStateCount.WithLabelValues("rollout-paused", daemonconsts.MachineConfigDaemonStateWorking)))
+	assert.Equal(t, float64(1), testutil.ToFloat64(mcoNodeStateCount.WithLabelValues("rollout-paused", unknownMCDState)))
+	assert.Equal(t, float64(0), testutil.ToFloat64(mcoNodeStateCount.WithLabelValues("rollout-paused", daemonconsts.MachineConfigDaemonStateDegraded)))
+	assert.Equal(t, float64(rendered[1].CreationTimestamp.Unix()), testutil.ToFloat64(mcoPoolDesiredConfigChangeTime.WithLabelValues("rollout-paused")))
+
+	// The metrics of deleted pools are removed.
+	require.NoError(t, optr.syncRolloutMetrics(nil))
+	assert.False(t, mcoPausedPoolPendingChanges.DeleteLabelValues("rollout-paused"))
+	assert.False(t, mcoNodeStateCount.DeleteLabelValues("rollout-paused", daemonconsts.MachineConfigDaemonStateDone))
+}
+
+// Tests that a node which can not be placed in a pool does not fail the sync.
+func TestSyncRolloutMetricsSkipsAmbiguousNodes(t *testing.T) {
+	infra := helpers.NewMachineConfigPool("rollout-infra", nil, helpers.InfraSelector, "rendered-rollout-infra-1")
+	other := helpers.NewMachineConfigPool("rollout-other", nil, metav1.AddLabelToSelector(&metav1.LabelSelector{}, "node-role/other", ""), "rendered-rollout-other-1")
+
+	optr := &Operator{rolloutTracker: newRolloutTracker()}
+	optr.mcLister = newFakeMCLister()
+
+	nodeIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	optr.nodeLister = corelisterv1.NewNodeLister(nodeIndexer)
+	ambiguous := newRolloutTestNode("ambiguous", "rendered-rollout-infra-1").annotate(daemonconsts.MachineConfigDaemonStateAnnotationKey, daemonconsts.MachineConfigDaemonStateDone)
+	ambiguous.node.Labels = map[string]string{"node-role/infra": "", "node-role/other": ""}
+	nodeIndexer.Add(ambiguous.node)
+	n := newRolloutTestNode("infra", "rendered-rollout-infra-1").annotate(daemonconsts.MachineConfigDaemonStateAnnotationKey, daemonconsts.MachineConfigDaemonStateDone)
+	n.node.Labels = map[string]string{"node-role/infra": ""}
+	nodeIndexer.Add(n.node)
+
+	require.NoError(t, optr.syncRolloutMetrics([]*mcfgv1.MachineConfigPool{infra, other}))
+	assert.Equal(t, float64(1), testutil.ToFloat64(mcoNodeStateCount.WithLabelValues("rollout-infra", daemonconsts.MachineConfigDaemonStateDone)))
+	assert.Equal(t, float64(0), testutil.ToFloat64(mcoNodeStateCount.WithLabelValues("rollout-other", daemonconsts.MachineConfigDaemonStateDone)))
+
+	require.NoError(t, optr.syncRolloutMetrics(nil))
+}
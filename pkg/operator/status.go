@@ -73,6 +73,7 @@ func (optr *Operator) syncRelatedObjects() error {
 		{Group: "machineconfiguration.openshift.io", Resource: "controllerconfigs"},
 		{Group: "machineconfiguration.openshift.io", Resource: "kubeletconfigs"},
 		{Group: "machineconfiguration.openshift.io", Resource: "containerruntimeconfigs"},
+		{Group: "machineconfiguration.openshift.io", Resource: "machineconfigtemplates"},
 		{Group: "machineconfiguration.openshift.io", Resource: "machineconfigs"},
 		// gathered because the machineconfigs created container bootstrap credentials and node configuration that gets reflected via the API and is needed for debugging
 		{Group: "", Resource: "nodes"},
@@ -299,6 +300,8 @@ func (optr *Operator) syncUpgradeableStatus() error {
 		Reason: asExpectedReason,
 	}
 
+	blockers := []upgradeBlocker{}
+
 	var updating, degraded bool
 	for _, pool := range pools {
 		// collect updating status but continue to check each pool to see if any pool is degraded
@@ -308,21 +311,36 @@ func (optr *Operator) syncUpgradeableStatus() error {
 		degraded = isPoolStatusConditionTrue(pool, mcfgv1.MachineConfigPoolDegraded)
 		// degraded should get top billing in the clusteroperator status, if we find this, set it and update
 		if degraded {
-			coStatus.Status = configv1.ConditionFalse
-			coStatus.Reason = "DegradedPool"
-			coStatus.Message = "One or more machine config pools are degraded, please see `oc get mcp` for further details and resolve before upgrading"
+			blockers = append(blockers, upgradeBlocker{
+				reason:  "DegradedPool",
+				message: "One or more machine config pools are degraded, please see `oc get mcp` for further details and resolve before upgrading",
+			})
 			break
 		}
 	}
 	// updating and degraded can occur together, in that case defer to the degraded Reason that is already set above
 	if updating && !degraded {
+		blockers = append(blockers, upgradeBlocker{
+			reason:  "PoolUpdating",
+			message: "One or more machine config pools are updating, please see `oc get mcp` for further details",
+		})
+	}
+
+	// Report every MCO-specific risk at once so that admins can resolve all of
+	// them before retrying the upgrade.
+	mcoBlockers, err := optr.getUpgradeBlockers(pools)
+	if err != nil {
+		return fmt.Errorf("could not check upgrade blockers: %w", err)
+	}
+	blockers = append(blockers, mcoBlockers...)
+
+	if len(blockers) != 0 {
 		coStatus.Status = configv1.ConditionFalse
-		coStatus.Reason = "PoolUpdating"
-		coStatus.Message = "One or more machine config pools are updating, please see `oc get mcp` for further details"
+		coStatus.Reason, coStatus.Message = unionUpgradeBlockers(blockers)
 	}
 
-	// don't overwrite status if updating or degraded
-	if !updating && !degraded {
+	// don't overwrite status if anything blocks the upgrade
+	if len(blockers) == 0 {
 		skewStatus, status, err := optr.isKubeletSkewSupported(pools)
 		if err != nil {
 			klog.Errorf("Error checking version skew: %v, kubelet skew status: %v, status reason: %v, status message: %v", err, skewStatus, status.Reason, status.Message)
@@ -380,7 +398,7 @@ func (optr *Operator) syncMetrics() error {
 		mcoDegradedMachineCount.WithLabelValues(pool.Name).Set(float64(pool.Status.DegradedMachineCount))
 		mcoUnavailableMachineCount.WithLabelValues(pool.Name).Set(float64(pool.Status.UnavailableMachineCount))
 	}
-	return nil
+	return optr.syncRolloutMetrics(pools)
 }
 
 // isKubeletSkewSupported checks the version skew of kube-apiserver and node kubelet version.
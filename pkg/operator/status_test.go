@@ -628,6 +628,7 @@ func TestOperatorSyncStatus(t *testing.T) {
 			eventRecorder: &record.FakeRecorder{},
 		}
 		optr.vStore = newVersionStore()
+		optr.rolloutTracker = newRolloutTracker()
 		optr.mcpLister = &mockMCPLister{
 			pools: []*mcfgv1.MachineConfigPool{
 				helpers.NewMachineConfigPool("master", nil, helpers.MasterSelector, "v0"),
@@ -637,6 +638,7 @@ func TestOperatorSyncStatus(t *testing.T) {
 
 		nodeIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
 		optr.nodeLister = corelisterv1.NewNodeLister(nodeIndexer)
+		optr.mcLister = newFakeMCLister()
 		nodeIndexer.Add(&corev1.Node{
 			ObjectMeta: metav1.ObjectMeta{Name: "first-node", Labels: map[string]string{"node-role/worker": ""}},
 			Status: corev1.NodeStatus{
@@ -699,6 +701,7 @@ func TestInClusterBringUpStayOnErr(t *testing.T) {
 		eventRecorder: &record.FakeRecorder{},
 	}
 	optr.vStore = newVersionStore()
+	optr.rolloutTracker = newRolloutTracker()
 	optr.vStore.Set("operator", "test-version")
 	optr.mcpLister = &mockMCPLister{
 		pools: []*mcfgv1.MachineConfigPool{
@@ -708,6 +711,7 @@ func TestInClusterBringUpStayOnErr(t *testing.T) {
 	}
 	nodeIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
 	optr.nodeLister = corelisterv1.NewNodeLister(nodeIndexer)
+	optr.mcLister = newFakeMCLister()
 	nodeIndexer.Add(&corev1.Node{
 		ObjectMeta: metav1.ObjectMeta{Name: "first-node", Labels: map[string]string{"node-role/worker": ""}},
 		Status: corev1.NodeStatus{
@@ -761,6 +765,7 @@ func TestKubeletSkewUnSupported(t *testing.T) {
 		eventRecorder: &record.FakeRecorder{},
 	}
 	optr.vStore = newVersionStore()
+	optr.rolloutTracker = newRolloutTracker()
 	optr.vStore.Set("operator", "test-version")
 	optr.mcpLister = &mockMCPLister{
 		pools: []*mcfgv1.MachineConfigPool{
@@ -770,6 +775,7 @@ func TestKubeletSkewUnSupported(t *testing.T) {
 	}
 	nodeIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
 	optr.nodeLister = corelisterv1.NewNodeLister(nodeIndexer)
+	optr.mcLister = newFakeMCLister()
 	nodeIndexer.Add(&corev1.Node{
 		ObjectMeta: metav1.ObjectMeta{Name: "first-node", Labels: map[string]string{"node-role/worker": ""}},
 		Status: corev1.NodeStatus{
@@ -848,6 +854,7 @@ func TestCustomPoolKubeletSkewUnSupported(t *testing.T) {
 		eventRecorder: &record.FakeRecorder{},
 	}
 	optr.vStore = newVersionStore()
+	optr.rolloutTracker = newRolloutTracker()
 	optr.vStore.Set("operator", "test-version")
 	optr.mcpLister = &mockMCPLister{
 		pools: []*mcfgv1.MachineConfigPool{
@@ -858,6 +865,7 @@ func TestCustomPoolKubeletSkewUnSupported(t *testing.T) {
 	}
 	nodeIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
 	optr.nodeLister = corelisterv1.NewNodeLister(nodeIndexer)
+	optr.mcLister = newFakeMCLister()
 	nodeIndexer.Add(&corev1.Node{
 		ObjectMeta: metav1.ObjectMeta{Name: "custom", Labels: map[string]string{"node-role/custom": ""}},
 		Status: corev1.NodeStatus{
@@ -935,6 +943,7 @@ func TestKubeletSkewSupported(t *testing.T) {
 		eventRecorder: &record.FakeRecorder{},
 	}
 	optr.vStore = newVersionStore()
+	optr.rolloutTracker = newRolloutTracker()
 	optr.vStore.Set("operator", "test-version")
 	optr.mcpLister = &mockMCPLister{
 		pools: []*mcfgv1.MachineConfigPool{
@@ -944,6 +953,7 @@ func TestKubeletSkewSupported(t *testing.T) {
 	}
 	nodeIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
 	optr.nodeLister = corelisterv1.NewNodeLister(nodeIndexer)
+	optr.mcLister = newFakeMCLister()
 	nodeIndexer.Add(&corev1.Node{
 		ObjectMeta: metav1.ObjectMeta{Name: "first-node", Labels: map[string]string{"node-role/worker": ""}},
 		Status: corev1.NodeStatus{
@@ -93,14 +93,16 @@ const (
 	mcdKubeRbacProxyPrometheusRoleBindingPath = "manifests/machineconfigdaemon/prometheus-rolebinding-target.yaml"
 
 	// Machine Config Server manifest paths
-	mcsClusterRoleManifestPath                    = "manifests/machineconfigserver/clusterrole.yaml"
-	mcsClusterRoleBindingManifestPath             = "manifests/machineconfigserver/clusterrolebinding.yaml"
-	mcsCSRBootstrapRoleBindingManifestPath        = "manifests/machineconfigserver/csr-bootstrap-role-binding.yaml"
-	mcsCSRRenewalRoleBindingManifestPath          = "manifests/machineconfigserver/csr-renewal-role-binding.yaml"
-	mcsServiceAccountManifestPath                 = "manifests/machineconfigserver/sa.yaml"
-	mcsNodeBootstrapperServiceAccountManifestPath = "manifests/machineconfigserver/node-bootstrapper-sa.yaml"
-	mcsNodeBootstrapperTokenManifestPath          = "manifests/machineconfigserver/node-bootstrapper-token.yaml"
-	mcsDaemonsetManifestPath                      = "manifests/machineconfigserver/daemonset.yaml"
+	mcsClusterRoleManifestPath                     = "manifests/machineconfigserver/clusterrole.yaml"
+	mcsClusterRoleBindingManifestPath              = "manifests/machineconfigserver/clusterrolebinding.yaml"
+	mcsContentEncryptionKeyRoleManifestPath        = "manifests/machineconfigserver/content-encryption-key-role.yaml"
+	mcsContentEncryptionKeyRoleBindingManifestPath = "manifests/machineconfigserver/content-encryption-key-role-binding.yaml"
+	mcsCSRBootstrapRoleBindingManifestPath         = "manifests/machineconfigserver/csr-bootstrap-role-binding.yaml"
+	mcsCSRRenewalRoleBindingManifestPath           = "manifests/machineconfigserver/csr-renewal-role-binding.yaml"
+	mcsServiceAccountManifestPath                  = "manifests/machineconfigserver/sa.yaml"
+	mcsNodeBootstrapperServiceAccountManifestPath  = "manifests/machineconfigserver/node-bootstrapper-sa.yaml"
+	mcsNodeBootstrapperTokenManifestPath           = "manifests/machineconfigserver/node-bootstrapper-token.yaml"
+	mcsDaemonsetManifestPath                       = "manifests/machineconfigserver/daemonset.yaml"
 )
 
 type syncFunc struct {
@@ -835,6 +837,12 @@ func (optr *Operator) syncMachineConfigServer(config *renderConfig) error {
 		clusterRoles: []string{
 			mcsClusterRoleManifestPath,
 		},
+		roles: []string{
+			mcsContentEncryptionKeyRoleManifestPath,
+		},
+		roleBindings: []string{
+			mcsContentEncryptionKeyRoleBindingManifestPath,
+		},
 		clusterRoleBindings: []string{
 			mcsClusterRoleBindingManifestPath,
 			mcsCSRBootstrapRoleBindingManifestPath,
@@ -0,0 +1,291 @@
+package operator
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	ign2types "github.com/coreos/ignition/config/v2_2/types"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	intstrutil "k8s.io/apimachinery/pkg/util/intstr"
+
+	mcfgv1 "github.com/openshift/machine-config-operator/pkg/apis/machineconfiguration.openshift.io/v1"
+	ctrlcommon "github.com/openshift/machine-config-operator/pkg/controller/common"
+	daemonconsts "github.com/openshift/machine-config-operator/pkg/daemon/constants"
+)
+
+const (
+	pausedPoolPendingConfigReason  = "PausedPoolPendingConfig"
+	unreconcilableNodesReason      = "UnreconcilableNodes"
+	deprecatedIgnitionConfigReason = "DeprecatedIgnitionConfig"
+	layeredPoolBuildFailedReason   = "LayeredPoolBuildFailed"
+	certificateExpiringReason      = "CertificateExpiring"
+	customPoolUnschedulableReason  = "CustomPoolUnschedulable"
+
+	// Certificates which expire within this window block upgrades, since the
+	// upgrade may not finish before they expire.
+	certExpiryUpgradeThreshold = 30 * 24 * time.Hour
+
+	// Set by the build controller on the BuildFailed condition while a failed
+	// build is waiting to be retried.
+	buildRetryPendingReason = "BuildRetryPending"
+)
+
+// upgradeBlocker describes a condition which makes upgrading the cluster
+// unsafe, along with how admins can resolve it.
+type upgradeBlocker struct {
+	reason  string
+	message string
+}
+
+// unionUpgradeBlockers combines the given blockers into a single Upgradeable=False
+// condition. The reasons are joined with "::" so that all of them are visible.
+func unionUpgradeBlockers(blockers []upgradeBlocker) (string, string) {
+	reasons := make([]string, 0, len(blockers))
+	messages := make([]string, 0, len(blockers))
+	for _, blocker := range blockers {
+		reasons = append(reasons, blocker.reason)
+		messages = append(messages, blocker.message)
+	}
+	return strings.Join(reasons, "::"), strings.Join(messages, "\n")
+}
+
+// getUpgradeBlockers runs the MCO-specific upgrade checks against the given pools.
+func (optr *Operator) getUpgradeBlockers(pools []*mcfgv1.MachineConfigPool) ([]upgradeBlocker, error) {
+	blockers := []upgradeBlocker{}
+
+	if blocker := checkPausedPools(pools); blocker != nil {
+		blockers = append(blockers, *blocker)
+	}
+
+	nodesByPool := map[string][]*corev1.Node{}
+	for _, pool := range pools {
+		nodes, err := optr.GetAllManagedNodes([]*mcfgv1.MachineConfigPool{pool})
+		if err != nil {
+			return nil, err
+		}
+		nodesByPool[pool.Name] = nodes
+	}
+
+	if blocker := checkUnreconcilableNodes(pools, nodesByPool); blocker != nil {
+		blockers = append(blockers, *blocker)
+	}
+
+	mcs, err := optr.mcLister.List(labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+
+	if blocker := checkDeprecatedIgnitionConfigs(mcs); blocker != nil {
+		blockers = append(blockers, *blocker)
+	}
+
+	if blocker := checkLayeredPoolBuilds(pools); blocker != nil {
+		blockers = append(blockers, *blocker)
+	}
+
+	if blocker := checkCertExpirys(pools, time.Now()); blocker != nil {
+		blockers = append(blockers, *blocker)
+	}
+
+	blocker, err := checkCustomPoolSchedulability(pools, nodesByPool)
+	if err != nil {
+		return nil, err
+	}
+	if blocker != nil {
+		blockers = append(blockers, *blocker)
+	}
+
+	return blockers, nil
+}
+
+// checkPausedPools finds paused pools which have a rendered config that has not
+// been rolled out yet. The upgrade cannot complete until they are unpaused.
+func checkPausedPools(pools []*mcfgv1.MachineConfigPool) *upgradeBlocker {
+	names := []string{}
+	for _, pool := range pools {
+		if pool.Spec.Paused && pool.Spec.Configuration.Name != pool.Status.Configuration.Name {
+			names = append(names, pool.Name)
+		}
+	}
+	if len(names) == 0 {
+		return nil
+	}
+	sort.Strings(names)
+	return &upgradeBlocker{
+		reason:  pausedPoolPendingConfigReason,
+		message: fmt.Sprintf("Paused machine config pools have pending configuration: %s. Unpause them and let them finish updating before upgrading", strings.Join(names, ", ")),
+	}
+}
+
+// checkUnreconcilableNodes finds nodes whose daemon could not apply their
+// current MachineConfig.
+func checkUnreconcilableNodes(pools []*mcfgv1.MachineConfigPool, nodesByPool map[string][]*corev1.Node) *upgradeBlocker {
+	names := []string{}
+	seen := map[string]bool{}
+	for _, pool := range pools {
+		for _, node := range nodesByPool[pool.Name] {
+			if seen[node.Name] {
+				continue
+			}
+			seen[node.Name] = true
+			if node.Annotations[daemonconsts.MachineConfigDaemonStateAnnotationKey] == daemonconsts.MachineConfigDaemonStateUnreconcilable {
+				names = append(names, node.Name)
+			}
+		}
+	}
+	if len(names) == 0 {
+		return nil
+	}
+	sort.Strings(names)
+	return &upgradeBlocker{
+		reason:  unreconcilableNodesReason,
+		message: fmt.Sprintf("Nodes are unreconcilable: %s. See the %s annotation of each node and fix or remove the offending MachineConfig before upgrading", strings.Join(names, ", "), daemonconsts.MachineConfigDaemonReasonAnnotationKey),
+	}
+}
+
+// checkDeprecatedIgnitionConfigs finds MachineConfigs which are still written
+// with Ignition spec 2.x.
+func checkDeprecatedIgnitionConfigs(mcs []*mcfgv1.MachineConfig) *upgradeBlocker {
+	names := []string{}
+	for _, mc := range mcs {
+		if len(mc.Spec.Config.Raw) == 0 {
+			continue
+		}
+		// Invalid configs are reported by the render controller.
+		ignCfg, err := ctrlcommon.IgnParseWrapper(mc.Spec.Config.Raw)
+		if err != nil {
+			continue
+		}
+		if _, ok := ignCfg.(ign2types.Config); ok {
+			names = append(names, mc.Name)
+		}
+	}
+	if len(names) == 0 {
+		return nil
+	}
+	sort.Strings(names)
+	return &upgradeBlocker{
+		reason:  deprecatedIgnitionConfigReason,
+		message: fmt.Sprintf("MachineConfigs use the deprecated Ignition spec 2.x: %s. Convert them to Ignition spec 3.x before upgrading", strings.Join(names, ", ")),
+	}
+}
+
+// checkLayeredPoolBuilds finds layered pools whose last image build failed.
+func checkLayeredPoolBuilds(pools []*mcfgv1.MachineConfigPool) *upgradeBlocker {
+	names := []string{}
+	for _, pool := range pools {
+		if !ctrlcommon.IsLayeredPool(pool) {
+			continue
+		}
+		condition := mcfgv1.GetMachineConfigPoolCondition(pool.Status, mcfgv1.MachineConfigPoolBuildFailed)
+		if condition != nil && condition.Status == corev1.ConditionTrue && condition.Reason != buildRetryPendingReason {
+			names = append(names, pool.Name)
+		}
+	}
+	if len(names) == 0 {
+		return nil
+	}
+	sort.Strings(names)
+	return &upgradeBlocker{
+		reason:  layeredPoolBuildFailedReason,
+		message: fmt.Sprintf("The last image build failed for layered machine config pools: %s. See the BuildFailed condition of each pool and fix the build before upgrading", strings.Join(names, ", ")),
+	}
+}
+
+// checkCertExpirys finds certificates tracked by the pools which expire within
+// certExpiryUpgradeThreshold of now. A certificate does not block the upgrade
+// while another certificate with the same subject in its bundle, such as the
+// new CA during a rotation, is valid beyond the threshold.
+func checkCertExpirys(pools []*mcfgv1.MachineConfigPool, now time.Time) *upgradeBlocker {
+	type certKey struct {
+		bundle  string
+		subject string
+	}
+	latest := map[certKey]time.Time{}
+	for _, pool := range pools {
+		for _, cert := range pool.Status.CertExpirys {
+			expiry, err := parseCertExpiry(cert.Expiry)
+			if err != nil {
+				continue
+			}
+			key := certKey{bundle: cert.Bundle, subject: cert.Subject}
+			if expiry.After(latest[key]) {
+				latest[key] = expiry
+			}
+		}
+	}
+
+	expiring := []string{}
+	for key, expiry := range latest {
+		if expiry.Sub(now) > certExpiryUpgradeThreshold {
+			continue
+		}
+		expiring = append(expiring, fmt.Sprintf("%s (%s) expires at %s", key.bundle, key.subject, expiry.UTC().Format(time.RFC3339)))
+	}
+	if len(expiring) == 0 {
+		return nil
+	}
+	sort.Strings(expiring)
+	return &upgradeBlocker{
+		reason:  certificateExpiringReason,
+		message: fmt.Sprintf("Certificates are about to expire: %s. Wait for them to be rotated before upgrading", strings.Join(expiring, "; ")),
+	}
+}
+
+// parseCertExpiry parses the expiry of a CertExpiry, which is the string form
+// of a time.Time.
+func parseCertExpiry(expiry string) (time.Time, error) {
+	return time.Parse("2006-01-02 15:04:05 -0700 MST", expiry)
+}
+
+// checkCustomPoolSchedulability finds custom pools whose maxUnavailable allows
+// all of their nodes to be cordoned at once during the upgrade, which leaves
+// no schedulable node for the workloads that target the pool. Single node
+// pools are skipped since they cannot be updated any other way.
+func checkCustomPoolSchedulability(pools []*mcfgv1.MachineConfigPool, nodesByPool map[string][]*corev1.Node) (*upgradeBlocker, error) {
+	names := []string{}
+	for _, pool := range pools {
+		if pool.Name == ctrlcommon.MachineConfigPoolMaster || pool.Name == ctrlcommon.MachineConfigPoolWorker {
+			continue
+		}
+		nodes := nodesByPool[pool.Name]
+		if len(nodes) < 2 {
+			continue
+		}
+		maxUnavail, err := getPoolMaxUnavailable(pool, len(nodes))
+		if err != nil {
+			return nil, fmt.Errorf("could not get maxUnavailable for pool %s: %w", pool.Name, err)
+		}
+		if maxUnavail >= len(nodes) {
+			names = append(names, pool.Name)
+		}
+	}
+	if len(names) == 0 {
+		return nil, nil
+	}
+	sort.Strings(names)
+	return &upgradeBlocker{
+		reason:  customPoolUnschedulableReason,
+		message: fmt.Sprintf("All nodes of custom machine config pools would be unschedulable at once during the upgrade: %s. Lower their maxUnavailable before upgrading", strings.Join(names, ", ")),
+	}, nil
+}
+
+// getPoolMaxUnavailable computes how many nodes of the pool may be updated at
+// once, the same way the node controller does.
+func getPoolMaxUnavailable(pool *mcfgv1.MachineConfigPool, nodeCount int) (int, error) {
+	intOrPercent := intstrutil.FromInt(1)
+	if pool.Spec.MaxUnavailable != nil {
+		intOrPercent = *pool.Spec.MaxUnavailable
+	}
+	maxUnavail, err := intstrutil.GetScaledValueFromIntOrPercent(&intOrPercent, nodeCount, false)
+	if err != nil {
+		return 0, err
+	}
+	if maxUnavail == 0 {
+		maxUnavail = 1
+	}
+	return maxUnavail, nil
+}
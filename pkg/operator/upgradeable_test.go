@@ -0,0 +1,334 @@
+package operator
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	configv1 "github.com/openshift/api/config/v1"
+	fakeconfigclientset "github.com/openshift/client-go/config/clientset/versioned/fake"
+	cov1helpers "github.com/openshift/library-go/pkg/config/clusteroperator/v1helpers"
+	mcfgv1 "github.com/openshift/machine-config-operator/pkg/apis/machineconfiguration.openshift.io/v1"
+	ctrlcommon "github.com/openshift/machine-config-operator/pkg/controller/common"
+	daemonconsts "github.com/openshift/machine-config-operator/pkg/daemon/constants"
+	mcfglistersv1 "github.com/openshift/machine-config-operator/pkg/generated/listers/machineconfiguration.openshift.io/v1"
+	"github.com/openshift/machine-config-operator/test/helpers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/uuid"
+	corelisterv1 "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+)
+
+func newFakeMCLister(mcs ...*mcfgv1.MachineConfig) mcfglistersv1.MachineConfigLister {
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	for _, mc := range mcs {
+		indexer.Add(mc)
+	}
+	return mcfglistersv1.NewMachineConfigLister(indexer)
+}
+
+func newUpgradeableTestNode(name, role, state string) *corev1.Node {
+	return &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Labels:      map[string]string{fmt.Sprintf("node-role/%s", role): ""},
+			Annotations: map[string]string{daemonconsts.MachineConfigDaemonStateAnnotationKey: state},
+		},
+		Status: corev1.NodeStatus{
+			NodeInfo: corev1.NodeSystemInfo{
+				KubeletVersion: "v1.21",
+			},
+		},
+	}
+}
+
+func TestCheckPausedPools(t *testing.T) {
+	pending := helpers.NewMachineConfigPool("worker", nil, helpers.WorkerSelector, "rendered-worker-1")
+	pending.Spec.Paused = true
+	pending.Spec.Configuration.Name = "rendered-worker-2"
+
+	current := helpers.NewMachineConfigPool("infra", nil, helpers.InfraSelector, "rendered-infra-1")
+	current.Spec.Paused = true
+
+	unpaused := helpers.NewMachineConfigPool("master", nil, helpers.MasterSelector, "rendered-master-1")
+	unpaused.Spec.Configuration.Name = "rendered-master-2"
+
+	assert.Nil(t, checkPausedPools([]*mcfgv1.MachineConfigPool{current, unpaused}))
+
+	blocker := checkPausedPools([]*mcfgv1.MachineConfigPool{pending, current, unpaused})
+	require.NotNil(t, blocker)
+	assert.Equal(t, pausedPoolPendingConfigReason, blocker.reason)
+	assert.Contains(t, blocker.message, "worker")
+	assert.NotContains(t, blocker.message, "infra")
+}
+
+func TestCheckUnreconcilableNodes(t *testing.T) {
+	pools := []*mcfgv1.MachineConfigPool{
+		helpers.NewMachineConfigPool("worker", nil, helpers.WorkerSelector, "v0"),
+	}
+
+	nodesByPool := map[string][]*corev1.Node{
+		"worker": {
+			newUpgradeableTestNode("node-a", "worker", daemonconsts.MachineConfigDaemonStateDone),
+			newUpgradeableTestNode("node-b", "worker", daemonconsts.MachineConfigDaemonStateUnreconcilable),
+		},
+	}
+
+	blocker := checkUnreconcilableNodes(pools, nodesByPool)
+	require.NotNil(t, blocker)
+	assert.Equal(t, unreconcilableNodesReason, blocker.reason)
+	assert.Contains(t, blocker.message, "node-b")
+	assert.NotContains(t, blocker.message, "node-a")
+
+	nodesByPool["worker"] = nodesByPool["worker"][:1]
+	assert.Nil(t, checkUnreconcilableNodes(pools, nodesByPool))
+}
+
+func TestCheckDeprecatedIgnitionConfigs(t *testing.T) {
+	ign2 := &mcfgv1.MachineConfig{
+		ObjectMeta: metav1.ObjectMeta{Name: "99-worker-ign2"},
+		Spec: mcfgv1.MachineConfigSpec{
+			Config: runtime.RawExtension{Raw: []byte(`{"ignition":{"version":"2.2.0"}}`)},
+		},
+	}
+	ign3 := helpers.NewMachineConfig("99-worker-ign3", nil, "", nil)
+	empty := &mcfgv1.MachineConfig{ObjectMeta: metav1.ObjectMeta{Name: "99-worker-kargs"}}
+
+	assert.Nil(t, checkDeprecatedIgnitionConfigs([]*mcfgv1.MachineConfig{ign3, empty}))
+
+	blocker := checkDeprecatedIgnitionConfigs([]*mcfgv1.MachineConfig{ign2, ign3, empty})
+	require.NotNil(t, blocker)
+	assert.Equal(t, deprecatedIgnitionConfigReason, blocker.reason)
+	assert.Contains(t, blocker.message, "99-worker-ign2")
+	assert.NotContains(t, blocker.message, "99-worker-ign3")
+}
+
+func TestCheckLayeredPoolBuilds(t *testing.T) {
+	newLayeredPool := func(name, reason string) *mcfgv1.MachineConfigPool {
+		pool := helpers.NewMachineConfigPool(name, nil, helpers.WorkerSelector, "v0")
+		pool.Labels[ctrlcommon.LayeringEnabledPoolLabel] = ""
+		if reason != "" {
+			mcfgv1.SetMachineConfigPoolCondition(&pool.Status, *mcfgv1.NewMachineConfigPoolCondition(mcfgv1.MachineConfigPoolBuildFailed, corev1.ConditionTrue, reason, ""))
+		}
+		return pool
+	}
+
+	testCases := []struct {
+		name     string
+		pool     *mcfgv1.MachineConfigPool
+		expected bool
+	}{
+		{
+			name: "Not layered",
+			pool: helpers.NewMachineConfigPool("worker", nil, helpers.WorkerSelector, "v0"),
+		},
+		{
+			name: "Build not failed",
+			pool: newLayeredPool("worker", ""),
+		},
+		{
+			name: "Retry pending",
+			pool: newLayeredPool("worker", buildRetryPendingReason),
+		},
+		{
+			name:     "Build failed",
+			pool:     newLayeredPool("worker", "BuildFailed"),
+			expected: true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			blocker := checkLayeredPoolBuilds([]*mcfgv1.MachineConfigPool{testCase.pool})
+			if !testCase.expected {
+				assert.Nil(t, blocker)
+				return
+			}
+
+			require.NotNil(t, blocker)
+			assert.Equal(t, layeredPoolBuildFailedReason, blocker.reason)
+		})
+	}
+}
+
+func TestCheckCertExpirys(t *testing.T) {
+	now := time.Date(2023, time.June, 1, 0, 0, 0, 0, time.UTC)
+
+	pool := helpers.NewMachineConfigPool("worker", nil, helpers.WorkerSelector, "v0")
+	pool.Status.CertExpirys = []mcfgv1.CertExpiry{
+		{Bundle: "KubeAPIServerServingCAData", Subject: "CN=later", Expiry: now.Add(365 * 24 * time.Hour).String()},
+		{Bundle: "KubeAPIServerServingCAData", Subject: "CN=invalid", Expiry: "soon"},
+	}
+
+	assert.Nil(t, checkCertExpirys([]*mcfgv1.MachineConfigPool{pool}, now))
+
+	pool.Status.CertExpirys = append(pool.Status.CertExpirys, mcfgv1.CertExpiry{
+		Bundle: "KubeAPIServerServingCAData", Subject: "CN=soon", Expiry: now.Add(7 * 24 * time.Hour).String(),
+	})
+
+	blocker := checkCertExpirys([]*mcfgv1.MachineConfigPool{pool}, now)
+	require.NotNil(t, blocker)
+	assert.Equal(t, certificateExpiringReason, blocker.reason)
+	assert.Contains(t, blocker.message, "CN=soon")
+	assert.Contains(t, blocker.message, "2023-06-08T00:00:00Z")
+	assert.NotContains(t, blocker.message, "CN=later")
+}
+
+// Tests that an expiring CA does not block the upgrade while the CA which
+// replaces it, with the same subject, is in the same bundle.
+func TestCheckCertExpirysDuringRotation(t *testing.T) {
+	now := time.Date(2023, time.June, 1, 0, 0, 0, 0, time.UTC)
+
+	pool := helpers.NewMachineConfigPool("worker", nil, helpers.WorkerSelector, "v0")
+	pool.Status.CertExpirys = []mcfgv1.CertExpiry{
+		{Bundle: "KubeAPIServerServingCAData", Subject: "CN=kube-apiserver-lb-signer", Expiry: now.Add(7 * 24 * time.Hour).String()},
+		{Bundle: "KubeAPIServerServingCAData", Subject: "CN=kube-apiserver-lb-signer", Expiry: now.Add(365 * 24 * time.Hour).String()},
+	}
+
+	assert.Nil(t, checkCertExpirys([]*mcfgv1.MachineConfigPool{pool}, now))
+
+	// The same subject in another bundle is not a replacement.
+	pool.Status.CertExpirys = append(pool.Status.CertExpirys, mcfgv1.CertExpiry{
+		Bundle: "RootCAData", Subject: "CN=kube-apiserver-lb-signer", Expiry: now.Add(7 * 24 * time.Hour).String(),
+	})
+
+	blocker := checkCertExpirys([]*mcfgv1.MachineConfigPool{pool}, now)
+	require.NotNil(t, blocker)
+	assert.Contains(t, blocker.message, "RootCAData (CN=kube-apiserver-lb-signer)")
+	assert.NotContains(t, blocker.message, "KubeAPIServerServingCAData")
+}
+
+func TestCheckCustomPoolSchedulability(t *testing.T) {
+	percent := intstr.FromString("50%")
+	all := intstr.FromString("100%")
+
+	testCases := []struct {
+		name           string
+		poolName       string
+		maxUnavailable *intstr.IntOrString
+		nodeCount      int
+		expected       bool
+	}{
+		{
+			name:      "Single node custom pool",
+			poolName:  "infra",
+			nodeCount: 1,
+		},
+		{
+			name:           "Single node custom pool with percentage",
+			poolName:       "infra",
+			maxUnavailable: &percent,
+			nodeCount:      1,
+		},
+		{
+			name:           "Custom pool with maxUnavailable covering all nodes",
+			poolName:       "infra",
+			maxUnavailable: &all,
+			nodeCount:      3,
+			expected:       true,
+		},
+		{
+			name:      "Multi node custom pool",
+			poolName:  "infra",
+			nodeCount: 3,
+		},
+		{
+			name:           "Custom pool with percentage",
+			poolName:       "infra",
+			maxUnavailable: &percent,
+			nodeCount:      2,
+		},
+		{
+			name:      "Empty custom pool",
+			poolName:  "infra",
+			nodeCount: 0,
+		},
+		{
+			name:           "Worker pool with maxUnavailable covering all nodes",
+			poolName:       "worker",
+			maxUnavailable: &all,
+			nodeCount:      3,
+		},
+	}
+
+	for _, testCase := range testCases {
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			pool := helpers.NewMachineConfigPool(testCase.poolName, nil, helpers.InfraSelector, "v0")
+			pool.Spec.MaxUnavailable = testCase.maxUnavailable
+
+			nodes := []*corev1.Node{}
+			for i := 0; i < testCase.nodeCount; i++ {
+				nodes = append(nodes, newUpgradeableTestNode(fmt.Sprintf("node-%d", i), testCase.poolName, daemonconsts.MachineConfigDaemonStateDone))
+			}
+
+			blocker, err := checkCustomPoolSchedulability([]*mcfgv1.MachineConfigPool{pool}, map[string][]*corev1.Node{testCase.poolName: nodes})
+			require.NoError(t, err)
+			if !testCase.expected {
+				assert.Nil(t, blocker)
+				return
+			}
+
+			require.NotNil(t, blocker)
+			assert.Equal(t, customPoolUnschedulableReason, blocker.reason)
+		})
+	}
+}
+
+// Tests that all of the upgrade blockers are reported in the Upgradeable condition.
+func TestSyncUpgradeableStatusReportsAllBlockers(t *testing.T) {
+	customSelector := metav1.AddLabelToSelector(&metav1.LabelSelector{}, "node-role/custom", "")
+
+	custom := helpers.NewMachineConfigPool("custom", nil, customSelector, "v0")
+	maxUnavailable := intstr.FromInt(2)
+	custom.Spec.MaxUnavailable = &maxUnavailable
+
+	paused := helpers.NewMachineConfigPool("workers", nil, helpers.WorkerSelector, "rendered-worker-1")
+	paused.Spec.Paused = true
+	paused.Spec.Configuration.Name = "rendered-worker-2"
+
+	optr := &Operator{
+		eventRecorder: &record.FakeRecorder{},
+	}
+	optr.mcpLister = &mockMCPLister{
+		pools: []*mcfgv1.MachineConfigPool{
+			helpers.NewMachineConfigPool("master", nil, helpers.MasterSelector, "v0"),
+			paused,
+			custom,
+		},
+	}
+
+	nodeIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	optr.nodeLister = corelisterv1.NewNodeLister(nodeIndexer)
+	nodeIndexer.Add(newUpgradeableTestNode("worker-0", "worker", daemonconsts.MachineConfigDaemonStateUnreconcilable))
+	nodeIndexer.Add(newUpgradeableTestNode("custom-0", "custom", daemonconsts.MachineConfigDaemonStateDone))
+	nodeIndexer.Add(newUpgradeableTestNode("custom-1", "custom", daemonconsts.MachineConfigDaemonStateDone))
+	optr.mcLister = newFakeMCLister()
+
+	coName := fmt.Sprintf("test-%s", uuid.NewUUID())
+	co := &configv1.ClusterOperator{ObjectMeta: metav1.ObjectMeta{Name: coName}}
+	cov1helpers.SetStatusCondition(&co.Status.Conditions, configv1.ClusterOperatorStatusCondition{Type: configv1.OperatorUpgradeable, Status: configv1.ConditionUnknown})
+	optr.name = coName
+	optr.configClient = fakeconfigclientset.NewSimpleClientset(co)
+
+	require.NoError(t, optr.syncUpgradeableStatus())
+
+	co, err := optr.configClient.ConfigV1().ClusterOperators().Get(context.TODO(), coName, metav1.GetOptions{})
+	require.NoError(t, err)
+
+	upgradeable := cov1helpers.FindStatusCondition(co.Status.Conditions, configv1.OperatorUpgradeable)
+	require.NotNil(t, upgradeable)
+	assert.Equal(t, configv1.ConditionFalse, upgradeable.Status)
+	assert.Equal(t, "PausedPoolPendingConfig::UnreconcilableNodes::CustomPoolUnschedulable", upgradeable.Reason)
+	assert.Contains(t, upgradeable.Message, "workers")
+	assert.Contains(t, upgradeable.Message, "worker-0")
+	assert.Contains(t, upgradeable.Message, "custom")
+}
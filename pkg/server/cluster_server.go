@@ -1,6 +1,7 @@
 package server
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -14,6 +15,7 @@ import (
 	ctrlcommon "github.com/openshift/machine-config-operator/pkg/controller/common"
 	mcfginformers "github.com/openshift/machine-config-operator/pkg/generated/informers/externalversions"
 	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/tools/cache"
 	clientcmdv1 "k8s.io/client-go/tools/clientcmd/api/v1"
@@ -37,6 +39,10 @@ type clusterServer struct {
 	controllerConfigLister  v1.ControllerConfigLister
 
 	kubeconfigFunc kubeconfigFunc
+
+	// contentEncryptionKeyFunc returns the key the file contents of rendered
+	// configs are encrypted with, see ctrlcommon.EncryptSensitiveFilesAnnotationKey.
+	contentEncryptionKeyFunc func() ([]byte, error)
 }
 
 const minResyncPeriod = 20 * time.Minute
@@ -64,6 +70,7 @@ func NewClusterServer(kubeConfig, apiserverURL string) (Server, error) {
 	}
 
 	machineConfigClient := clientsBuilder.MachineConfigClientOrDie("machine-config-shared-informer")
+	kubeClient := clientsBuilder.KubeClientOrDie("machine-config-server")
 	sharedInformerFactory := mcfginformers.NewSharedInformerFactory(machineConfigClient, resyncPeriod()())
 
 	mcpInformer, mcInformer, ccInformer :=
@@ -88,6 +95,13 @@ func NewClusterServer(kubeConfig, apiserverURL string) (Server, error) {
 		machineConfigLister:     mcLister,
 		controllerConfigLister:  ccLister,
 		kubeconfigFunc:          func() ([]byte, []byte, error) { return kubeconfigFromSecret(bootstrapTokenDir, apiserverURL) },
+		contentEncryptionKeyFunc: func() ([]byte, error) {
+			secret, err := kubeClient.CoreV1().Secrets(ctrlcommon.MCONamespace).Get(context.TODO(), ctrlcommon.ContentEncryptionKeySecretName, metav1.GetOptions{})
+			if err != nil {
+				return nil, err
+			}
+			return secret.Data[ctrlcommon.ContentEncryptionKeySecretKey], nil
+		},
 	}, nil
 }
 
@@ -114,6 +128,18 @@ func (cs *clusterServer) GetConfig(cr poolRequest) (*runtime.RawExtension, error
 	if err != nil {
 		return nil, fmt.Errorf("could not fetch config %s, err: %w", currConf, err)
 	}
+	// Ignition can not decrypt file contents, so new nodes get them in
+	// plaintext like before; the daemon decrypts on its own afterwards.
+	if ctrlcommon.IsMachineConfigEncrypted(mc) {
+		key, err := cs.contentEncryptionKeyFunc()
+		if err != nil {
+			return nil, fmt.Errorf("could not get content encryption key: %w", err)
+		}
+		mc, err = ctrlcommon.DecryptMachineConfig(mc, ctrlcommon.DeriveMachineConfigKey(key, mc.Name))
+		if err != nil {
+			return nil, err
+		}
+	}
 	ignConf, err := ctrlcommon.ParseAndConvertConfig(mc.Spec.Config.Raw)
 	if err != nil {
 		return nil, fmt.Errorf("parsing Ignition config failed with error: %w", err)